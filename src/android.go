@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runPullAndroid implements the "pull-android" subcommand. It pulls the
+// WhatsApp Media directory from a device over adb and returns the arguments
+// the regular processing run should continue with.
+func runPullAndroid(args []string) []string {
+	fs := flag.NewFlagSet("pull-android", flag.ExitOnError)
+	serial := fs.String("serial", "", "Device serial (as listed by 'adb devices') when several are connected")
+	source := fs.String("source", "", "Media directory on the device (default: auto-detect)")
+	dest := fs.String("dest", "WhatsApp_Media", "Local working directory to pull media into")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd pull-android [flags] [-- processing flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Pulls WhatsApp media from a connected Android device with adb, then processes\n")
+		fmt.Fprintf(os.Stderr, "the pulled directory. Flags after -- are passed to the normal processing run.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExample:\n")
+		fmt.Fprintf(os.Stderr, "  wappd pull-android -dest ./phone -- -m -out ./restored\n")
+	}
+	fs.Parse(args)
+
+	remoteDir := *source
+	if remoteDir == "" {
+		found, err := processor.FindAndroidMediaDir(*serial)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		remoteDir = found
+	}
+
+	fmt.Printf("Pulling %s to %s...\n", remoteDir, *dest)
+	if err := processor.PullAndroidMedia(*serial, remoteDir, *dest); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Println()
+
+	return append([]string{"-d", *dest}, fs.Args()...)
+}
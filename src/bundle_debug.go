@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runBundleDebug implements the "bundle-debug" subcommand, which packs how
+// wappd sees a file (never the file itself) into a zip for a bug report
+func runBundleDebug(args []string) int {
+	fs := flag.NewFlagSet("bundle-debug", flag.ExitOnError)
+	output := fs.String("o", "", "Path of the zip to write (default: wappd-debug-<file name>.zip)")
+	configFile := fs.String("cf", "", "Path to config file (default: wappd.json next to the file)")
+	profile := fs.String("profile", "", "Apply the named profile from the config file")
+	preset := fs.String("preset", "", "Start from the named built-in preset")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd bundle-debug [-o <zip>] [-cf <config>] [-profile <name>] [-preset <name>] <file>\n\n")
+		fmt.Fprintf(os.Stderr, "Writes a zip to attach to a bug report: the wappd version, what \"doctor\"\n")
+		fmt.Fprintf(os.Stderr, "reports for the file, its segment/atom structure and the config in effect.\n")
+		fmt.Fprintf(os.Stderr, "No pixel or sample data is included. Nothing is modified.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	filePath := fs.Arg(0)
+	zipPath := *output
+	if zipPath == "" {
+		zipPath = "wappd-debug-" + strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + ".zip"
+	}
+
+	dc, err := loadDoctorConfig(filePath, *configFile, *profile, *preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	d, err := processor.New(dc.config).Diagnose(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var environment, diagnosis strings.Builder
+	writeEnvironment(&environment)
+	dc.write(&environment)
+	writeDiagnosis(&diagnosis, d.RedactLocation())
+	bundle := processor.DebugBundle{
+		Created:     time.Now(),
+		Environment: environment.String(),
+		Diagnosis:   strings.TrimPrefix(diagnosis.String(), "\n"),
+		Config:      dc.fileConfig,
+	}
+	bundle.Inspection, bundle.InspectErr = processor.Inspect(filePath)
+
+	if err := processor.WriteDebugBundle(zipPath, bundle); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s (no pixel data; check it before sharing, file and folder names are included)\n", zipPath)
+	return 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runCompare implements the "compare" subcommand, which reports how the
+// media of two copies of an archive differ
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print every file's comparison as JSON")
+	all := fs.Bool("all", false, "Also list identical files")
+	includeDocuments := fs.Bool("include-documents", false, "Also compare WhatsApp documents")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd compare [-json] [-all] [-include-documents] <dir> <other-dir>\n\n")
+		fmt.Fprintf(os.Stderr, "Pairs the media files of two directories (e.g. a processed archive and the\n")
+		fmt.Fprintf(os.Stderr, "phone's copy) by path or name and reports which differ only by metadata\n")
+		fmt.Fprintf(os.Stderr, "(dates, EXIF...) and which by content. Nothing is modified.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	left, right := fs.Arg(0), fs.Arg(1)
+	entries, err := processor.CompareDirs(left, right, *includeDocuments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		if entries == nil {
+			entries = []processor.CompareEntry{}
+		}
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(out))
+		return 0
+	}
+
+	counts := map[string]int{}
+	for _, e := range entries {
+		counts[e.Kind]++
+		switch e.Kind {
+		case processor.CompareContent:
+			if e.Error != "" {
+				fmt.Printf("  ✗ %s ↔ %s: %s\n", e.Left, e.Right, e.Error)
+			} else {
+				fmt.Printf("  ≠ %s ↔ %s: content differs\n", e.Left, e.Right)
+			}
+		case processor.CompareMetadata:
+			fmt.Printf("  ~ %s ↔ %s: metadata only\n", e.Left, e.Right)
+		case processor.CompareOnlyLeft:
+			fmt.Printf("  < %s: only in %s\n", e.Left, left)
+		case processor.CompareOnlyRight:
+			fmt.Printf("  > %s: only in %s\n", e.Right, right)
+		case processor.CompareIdentical:
+			if *all {
+				fmt.Printf("  = %s ↔ %s\n", e.Left, e.Right)
+			}
+		}
+	}
+
+	fmt.Printf("\n%d identical, %d differ only by metadata, %d differ in content, %d only in %s, %d only in %s\n",
+		counts[processor.CompareIdentical], counts[processor.CompareMetadata], counts[processor.CompareContent],
+		counts[processor.CompareOnlyLeft], left, counts[processor.CompareOnlyRight], right)
+	return 0
+}
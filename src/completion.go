@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// subcommands are the subcommands main dispatches, for completion
+var subcommands = []string{"help", "init", "doctor", "inspect", "dates", "compare", "verify", "runs", "restore-times", "retry", "recover", "pull-android", "gen-samples", "bundle-debug", "completion"}
+
+// runCompletion implements the "completion" subcommand, which prints a shell
+// completion script for the main command's flags and the subcommands
+func runCompletion(args []string, cli *cliFlags) int {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd completion bash|zsh|fish\n\n")
+		fmt.Fprintf(os.Stderr, "Prints a completion script for the shell, e.g.:\n")
+		fmt.Fprintf(os.Stderr, "  source <(wappd completion bash)\n")
+		fmt.Fprintf(os.Stderr, "  wappd completion fish > ~/.config/fish/completions/wappd.fish\n")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	switch fs.Arg(0) {
+	case "bash":
+		writeBashCompletion(os.Stdout, cli)
+	case "zsh":
+		writeZshCompletion(os.Stdout, cli)
+	case "fish":
+		writeFishCompletion(os.Stdout, cli)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unsupported shell %q (use bash, zsh or fish)\n", fs.Arg(0))
+		return 2
+	}
+	return 0
+}
+
+// writeBashCompletion writes a bash completion script: subcommands first,
+// long flags after a dash, files otherwise
+func writeBashCompletion(w io.Writer, cli *cliFlags) {
+	var names []string
+	for _, f := range cli.all() {
+		names = append(names, "--"+f.long)
+	}
+	fmt.Fprintf(w, "# bash completion for wappd\n")
+	fmt.Fprintf(w, "_wappd() {\n")
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintf(w, "    elif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\") $(compgen -f -- \"$cur\"))\n", strings.Join(subcommands, " "))
+	fmt.Fprintf(w, "    else\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o filenames -F _wappd wappd\n")
+}
+
+// writeZshCompletion writes a zsh completion script describing every flag
+func writeZshCompletion(w io.Writer, cli *cliFlags) {
+	escape := strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]", ":", "\\:")
+	fmt.Fprintf(w, "#compdef wappd\n\n")
+	fmt.Fprintf(w, "_wappd() {\n")
+	fmt.Fprintf(w, "    _arguments \\\n")
+	fmt.Fprintf(w, "        '1::command:(%s)' \\\n", strings.Join(subcommands, " "))
+	for _, f := range cli.all() {
+		spec := fmt.Sprintf("--%s[%s]", f.long, escape.Replace(f.usage))
+		if !f.isBool() {
+			spec += ":value:_files"
+		}
+		fmt.Fprintf(w, "        '%s' \\\n", spec)
+	}
+	fmt.Fprintf(w, "        '*:file:_files'\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "compdef _wappd wappd\n")
+}
+
+// writeFishCompletion writes a fish completion script describing every flag
+// under its long and short names
+func writeFishCompletion(w io.Writer, cli *cliFlags) {
+	escape := strings.NewReplacer("\\", "\\\\", "'", "\\'")
+	fmt.Fprintf(w, "# fish completion for wappd\n")
+	fmt.Fprintf(w, "complete -c wappd -n __fish_use_subcommand -a '%s'\n", strings.Join(subcommands, " "))
+	for _, f := range cli.all() {
+		line := "complete -c wappd -l " + f.long
+		for _, short := range f.short {
+			line += " -o " + short
+		}
+		if !f.isBool() {
+			line += " -r"
+		}
+		fmt.Fprintf(w, "%s -d '%s'\n", line, escape.Replace(f.usage))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runDates implements the "dates" subcommand, which lists the filename,
+// embedded and modification dates of every media file in a directory
+func runDates(args []string) int {
+	fs := flag.NewFlagSet("dates", flag.ExitOnError)
+	dirPath := fs.String("d", ".", "Directory to list")
+	configFile := fs.String("cf", "", "Path to config file (default: wappd.json in the directory)")
+	asJSON := fs.Bool("json", false, "Print the listing as JSON")
+	mismatches := fs.Bool("mismatches", false, "Only list files whose dates fall on different days")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd dates [-d <dir>] [-cf <config>] [-json] [-mismatches]\n\n")
+		fmt.Fprintf(os.Stderr, "Lists, for every media file, the date in its name, the date embedded in it\n")
+		fmt.Fprintf(os.Stderr, "(EXIF, mvhd...) and its modification time. Files whose dates fall on\n")
+		fmt.Fprintf(os.Stderr, "different days are marked with !. Nothing is modified.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	// Custom patterns and timezones come from the config a run would use
+	configPath := *configFile
+	if configPath == "" {
+		configPath = filepath.Join(*dirPath, processor.ConfigFileName())
+	}
+	fileConfig, err := processor.LoadConfigFileFromPath(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	config := processor.MergeConfig(fileConfig, processor.Config{InputDir: *dirPath})
+
+	files, err := processor.NewScanner(config).Files(*dirPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading directory: %v\n", err)
+		return 1
+	}
+	proc := processor.New(config)
+	listings := []processor.DateListing{}
+	failed := 0
+	for _, file := range files {
+		l, err := proc.ListDates(file)
+		if err != nil {
+			// One file gone or unreadable doesn't stop the listing
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed++
+			continue
+		}
+		if *mismatches && !l.Mismatch {
+			continue
+		}
+		listings = append(listings, l)
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(listings, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(out))
+		return datesStatus(failed)
+	}
+
+	if len(listings) == 0 {
+		fmt.Printf("No media files to list in %s\n", *dirPath)
+		return datesStatus(failed)
+	}
+	fmt.Printf("%-20s  %-25s  %-20s  %s\n", "FILENAME", "EMBEDDED", "MODIFIED", "FILE")
+	for _, l := range listings {
+		embedded := l.EmbeddedDate
+		if l.Error != "" {
+			embedded = "unreadable"
+		}
+		mark := ""
+		if l.Mismatch {
+			mark = "! "
+		}
+		fmt.Printf("%-20s  %-25s  %-20s  %s%s\n",
+			orDash(l.FilenameDate), orDash(embedded), l.ModTime.Format("2006-01-02T15:04:05"), mark, l.File)
+	}
+	return datesStatus(failed)
+}
+
+// datesStatus is the exit status of a listing that couldn't list failed files
+func datesStatus(failed int) int {
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// orDash shows a missing value as "-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+	"github.com/apercova/wappd/version"
+)
+
+// runDoctor implements the "doctor" subcommand, which reports the
+// environment and, with -f, how wappd sees a file, for bug reports
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	filePath := fs.String("f", "", "File to diagnose")
+	configFile := fs.String("cf", "", "Path to config file (default: wappd.json next to the file)")
+	profile := fs.String("profile", "", "Apply the named profile from the config file")
+	preset := fs.String("preset", "", "Start from the named built-in preset")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd doctor [-f <file>] [-cf <config>] [-profile <name>] [-preset <name>]\n\n")
+		fmt.Fprintf(os.Stderr, "Prints the wappd version, available external tools and, for a file, its real\n")
+		fmt.Fprintf(os.Stderr, "container, embedded dates, matching filename pattern and what processing it\n")
+		fmt.Fprintf(os.Stderr, "would do. Nothing is modified. Please include the output in bug reports.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	writeEnvironment(os.Stdout)
+	if *filePath == "" {
+		return 0
+	}
+
+	dc, err := loadDoctorConfig(*filePath, *configFile, *profile, *preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	dc.write(os.Stdout)
+
+	d, err := processor.New(dc.config).Diagnose(*filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	writeDiagnosis(os.Stdout, d)
+	return 0
+}
+
+// writeEnvironment writes the wappd version, platform and external tools
+func writeEnvironment(w io.Writer) {
+	fmt.Fprintln(w, "Environment:")
+	fmt.Fprintf(w, "  wappd:     %s\n", version.Get().String())
+	fmt.Fprintf(w, "  platform:  %s/%s (%s)\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+	fmt.Fprintf(w, "  exiftool:  %s\n", availability(processor.ExiftoolAvailable()))
+	fmt.Fprintf(w, "  ffmpeg:    %s\n", availability(processor.FFmpegAvailable()))
+}
+
+// doctorConfig is the config a file is diagnosed with and where it came from
+type doctorConfig struct {
+	path       string                // Config file found ("" when none)
+	preset     string                // Preset applied ("" when none)
+	fileConfig *processor.ConfigFile // The config file after -profile and -preset
+	config     processor.Config
+}
+
+// loadDoctorConfig loads the config a normal run on filePath would pick up:
+// configFile or the wappd.json next to it, with profile and preset applied
+func loadDoctorConfig(filePath, configFile, profile, preset string) (doctorConfig, error) {
+	dc := doctorConfig{path: configFile, preset: preset}
+	if dc.path == "" {
+		dc.path = filepath.Join(filepath.Dir(filePath), processor.ConfigFileName())
+	}
+	fileConfig, err := processor.LoadConfigFileFromPath(dc.path)
+	if err != nil {
+		return dc, err
+	}
+	if fileConfig == nil {
+		dc.path = ""
+	}
+	if profile != "" {
+		if fileConfig == nil {
+			return dc, fmt.Errorf("-profile %s needs a config file defining it", profile)
+		}
+		if fileConfig, err = processor.ApplyProfile(fileConfig, profile); err != nil {
+			return dc, err
+		}
+	}
+	if preset != "" {
+		if fileConfig, err = processor.ApplyPreset(fileConfig, preset); err != nil {
+			return dc, err
+		}
+	}
+	dc.fileConfig = fileConfig
+	dc.config = processor.MergeConfig(fileConfig, processor.Config{InputDir: filepath.Dir(filePath)})
+	return dc, nil
+}
+
+// write writes the config file and preset lines of the environment
+func (dc doctorConfig) write(w io.Writer) {
+	if dc.path != "" {
+		fmt.Fprintf(w, "  config:    %s\n", dc.path)
+	} else {
+		fmt.Fprintf(w, "  config:    none\n")
+	}
+	if dc.preset != "" {
+		fmt.Fprintf(w, "  preset:    %s\n", dc.preset)
+	}
+}
+
+// writeDiagnosis writes how wappd sees a file: its container, filename
+// pattern, embedded dates and what processing would do
+func writeDiagnosis(w io.Writer, d processor.Diagnosis) {
+	fmt.Fprintf(w, "\nFile: %s\n", d.File)
+	fmt.Fprintf(w, "  size:      %d bytes\n", d.Size)
+	fmt.Fprintf(w, "  modified:  %s\n", d.ModTime.Format(time.RFC3339))
+	switch {
+	case d.Container == "":
+		fmt.Fprintf(w, "  container: unknown\n")
+	case d.ActualExt != "":
+		fmt.Fprintf(w, "  container: %s (extension is wrong, should be %s)\n", d.Container, d.ActualExt)
+	default:
+		fmt.Fprintf(w, "  container: %s\n", d.Container)
+	}
+	if length, err := processor.VideoDuration(d.File); err == nil {
+		fmt.Fprintf(w, "  duration:  %s\n", processor.FormatDuration(length))
+	} else if !errors.Is(err, processor.ErrNoDuration) {
+		fmt.Fprintf(w, "  duration:  unknown (%v)\n", err)
+	}
+	if d.Pattern != "" {
+		fmt.Fprintf(w, "  pattern:   %s → %s\n", d.Pattern, d.FilenameDate)
+	} else {
+		fmt.Fprintf(w, "  pattern:   no default pattern matches the filename\n")
+	}
+
+	fmt.Fprintln(w, "  embedded dates:")
+	switch {
+	case d.DatesErr != nil:
+		fmt.Fprintf(w, "    could not be read: %v\n", d.DatesErr)
+	case len(d.Dates) == 0:
+		fmt.Fprintln(w, "    none")
+	}
+	for _, date := range d.Dates {
+		fmt.Fprintf(w, "    %s: %s\n", date.Source, date.Value)
+	}
+
+	fmt.Fprintln(w, "  processing would:")
+	for _, action := range d.Actions {
+		fmt.Fprintf(w, "    - %s\n", action)
+	}
+}
+
+// availability describes whether an external tool was found on PATH
+func availability(found bool) string {
+	if found {
+		return "found"
+	}
+	return "not found"
+}
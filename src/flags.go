@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// cliFlags defines the flags of the main command on a flag set. Every flag
+// has a GNU-style long name and may keep the short names it has always had
+// (either form takes one dash or two); help and shell completion list the
+// flags under the group they were defined in.
+type cliFlags struct {
+	fs     *flag.FlagSet
+	groups []string
+	flags  map[string][]cliFlag // Group → flags in definition order
+	group  string
+}
+
+// cliFlag is one flag with all its names
+type cliFlag struct {
+	long  string
+	short []string
+	usage string
+	value flag.Value
+}
+
+// newCLIFlags defines flags on fs
+func newCLIFlags(fs *flag.FlagSet) *cliFlags {
+	return &cliFlags{fs: fs, flags: make(map[string][]cliFlag)}
+}
+
+// Group starts the help group the flags defined next belong to
+func (c *cliFlags) Group(name string) {
+	c.group = name
+	c.groups = append(c.groups, name)
+}
+
+// Var defines a flag under its long name and each short name. names is the
+// long name optionally followed by short ones, comma-separated ("dir,d").
+func (c *cliFlags) Var(value flag.Value, names, usage string) {
+	list := strings.Split(names, ",")
+	for _, name := range list {
+		c.fs.Var(value, name, usage)
+	}
+	c.flags[c.group] = append(c.flags[c.group], cliFlag{long: list[0], short: list[1:], usage: usage, value: value})
+}
+
+// String defines a string flag (see Var)
+func (c *cliFlags) String(names, value, usage string) *string {
+	p := new(string)
+	c.StringVar(p, names, value, usage)
+	return p
+}
+
+// StringVar defines a string flag stored in p (see Var)
+func (c *cliFlags) StringVar(p *string, names, value, usage string) {
+	scratch := flag.NewFlagSet("", flag.ContinueOnError)
+	scratch.StringVar(p, "value", value, usage)
+	c.Var(scratch.Lookup("value").Value, names, usage)
+}
+
+// Bool defines a bool flag (see Var)
+func (c *cliFlags) Bool(names string, value bool, usage string) *bool {
+	p := new(bool)
+	scratch := flag.NewFlagSet("", flag.ContinueOnError)
+	scratch.BoolVar(p, "value", value, usage)
+	c.Var(scratch.Lookup("value").Value, names, usage)
+	return p
+}
+
+// Int defines an int flag (see Var)
+func (c *cliFlags) Int(names string, value int, usage string) *int {
+	p := new(int)
+	scratch := flag.NewFlagSet("", flag.ContinueOnError)
+	scratch.IntVar(p, "value", value, usage)
+	c.Var(scratch.Lookup("value").Value, names, usage)
+	return p
+}
+
+// all returns every flag, in help order
+func (c *cliFlags) all() []cliFlag {
+	var flags []cliFlag
+	for _, group := range c.groups {
+		flags = append(flags, c.flags[group]...)
+	}
+	return flags
+}
+
+// isBool reports whether a flag takes no value
+func (f cliFlag) isBool() bool {
+	b, ok := f.value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// PrintDefaults writes the flags by group, like flag.PrintDefaults but with
+// every name of a flag on one line: "  -d, --dir value"
+func (c *cliFlags) PrintDefaults(w io.Writer) {
+	for _, group := range c.groups {
+		fmt.Fprintf(w, "%s flags:\n", group)
+		for _, f := range c.flags[group] {
+			var names []string
+			for _, short := range f.short {
+				names = append(names, "-"+short)
+			}
+			names = append(names, "--"+f.long)
+			line := "  " + strings.Join(names, ", ")
+			valueName, usage := flag.UnquoteUsage(c.fs.Lookup(f.long))
+			if valueName != "" {
+				line += " " + valueName
+			}
+			fmt.Fprintf(w, "%s\n    \t%s", line, strings.ReplaceAll(usage, "\n", "\n    \t"))
+			if def := c.fs.Lookup(f.long).DefValue; def != "" && def != "false" && def != "0" && def != "[]" {
+				if valueName == "string" {
+					def = fmt.Sprintf("%q", def)
+				}
+				fmt.Fprintf(w, " (default %s)", def)
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w)
+	}
+}
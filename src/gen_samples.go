@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runGenSamples implements the "gen-samples" subcommand, which writes a set
+// of synthetic WhatsApp-style files to try options on safely
+func runGenSamples(args []string) int {
+	fs := flag.NewFlagSet("gen-samples", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd gen-samples <dir>\n\n")
+		fmt.Fprintf(os.Stderr, "Writes synthetic WhatsApp-style JPEG, MP4 and 3GP files with known names\n")
+		fmt.Fprintf(os.Stderr, "and contents under <dir>/WhatsApp/Media, and lists what each one exercises\n")
+		fmt.Fprintf(os.Stderr, "in <dir>/%s. Existing files are never overwritten.\n\n", processor.SamplesReadme)
+		fmt.Fprintf(os.Stderr, "Example:\n")
+		fmt.Fprintf(os.Stderr, "  wappd gen-samples ./samples && wappd -d ./samples -out ./samples-out -v\n")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	dir := fs.Arg(0)
+	written, err := processor.GenerateSamples(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, path := range written {
+		fmt.Printf("  + %s\n", path)
+	}
+	fmt.Printf("\nWrote %d sample(s); see %s for what each one exercises\n", len(processor.Samples), filepath.Join(dir, processor.SamplesReadme))
+	return 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// helpFiles holds the text of each help topic, help/<topic>.txt
+//
+//go:embed help/*.txt
+var helpFiles embed.FS
+
+// helpTopics lists the help topics in the order "wappd help" shows them
+var helpTopics = []struct {
+	name    string
+	summary string
+}{
+	{"examples", "Common ways to run wappd"},
+	{"patterns", "Filenames dates are read from, and custom patterns"},
+	{"config", "The wappd.json config file, profiles, targets and presets"},
+	{"formats", "Supported formats and the metadata written to each"},
+}
+
+// runHelp implements the "help" subcommand, which prints a help topic or,
+// without one, lists them
+func runHelp(args []string) int {
+	fs := flag.NewFlagSet("help", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd help [topic]\n")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		printHelpTopics()
+		return 0
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	topic, err := helpFiles.ReadFile("help/" + fs.Arg(0) + ".txt")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: no help topic %q\n\n", fs.Arg(0))
+		printHelpTopics()
+		return 2
+	}
+	os.Stdout.Write(topic)
+	return 0
+}
+
+// printHelpTopics lists the help topics
+func printHelpTopics() {
+	fmt.Println("Help topics (wappd help <topic>):")
+	for _, topic := range helpTopics {
+		fmt.Printf("  %-10s %s\n", topic.name, topic.summary)
+	}
+	fmt.Println("\nRun 'wappd -h' for the flags and '<subcommand> -h' for a subcommand's.")
+}
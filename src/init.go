@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runInit implements the "init" subcommand, which asks a few questions and
+// writes a commented wappd.json into the chosen media directory
+func runInit(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	force := fs.Bool("force", false, "Overwrite an existing wappd.json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd init [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Asks a few questions and writes a wappd.json with your defaults into the\n")
+		fmt.Fprintf(os.Stderr, "media directory, so later runs only need 'wappd -d <dir>'.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	in := bufio.NewReader(os.Stdin)
+	out := os.Stdout
+
+	fmt.Fprintln(out, "wappd setup - press Enter to accept the [default]")
+	fmt.Fprintln(out)
+
+	dir := ask(in, out, "Directory with your WhatsApp media", ".")
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", dir)
+		return 1
+	}
+	configPath := filepath.Join(dir, processor.ConfigFileName())
+	if _, err := os.Stat(configPath); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists (use -force to replace it)\n", configPath)
+		return 1
+	}
+
+	config := &processor.ConfigFile{}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Where should fixed files go?")
+	fmt.Fprintln(out, "  1) Copies next to the originals, with a _modified suffix")
+	fmt.Fprintln(out, "  2) Copies in a separate output directory")
+	fmt.Fprintln(out, "  3) Overwrite the original files")
+	switch ask(in, out, "Choice", "1") {
+	case "1":
+	case "2":
+		// Relative paths resolve against the directory wappd is run from, so
+		// suggest one that works from anywhere
+		def := filepath.Join(dir, "processed")
+		if abs, err := filepath.Abs(def); err == nil {
+			def = abs
+		}
+		config.OutputDir = ask(in, out, "Output directory", def)
+	case "3":
+		config.OverrideOriginal = boolPtr(true)
+	default:
+		fmt.Fprintln(os.Stderr, "Error: please answer 1, 2 or 3")
+		return 1
+	}
+
+	fmt.Fprintln(out)
+	config.OverwriteExif = boolPtr(askYesNo(in, out, "Replace dates that are already set in a file's metadata?", false))
+	config.UpdateModified = boolPtr(askYesNo(in, out, "Also set each file's modified time to its WhatsApp date?", true))
+
+	if err := os.WriteFile(configPath, processor.FormatCommentedConfig(config), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", configPath, err)
+		return 1
+	}
+
+	fmt.Fprintf(out, "\nWrote %s\n", configPath)
+	fmt.Fprintf(out, "Preview the changes with:  wappd -d %s --dry-run\n", dir)
+	fmt.Fprintf(out, "Then apply them with:      wappd -d %s\n", dir)
+	return 0
+}
+
+// ask prints a question and returns the trimmed answer, or def when the
+// answer is empty or input has ended
+func ask(in *bufio.Reader, out io.Writer, question, def string) string {
+	fmt.Fprintf(out, "%s [%s]: ", question, def)
+	line, _ := in.ReadString('\n')
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// askYesNo asks a yes/no question, re-asking until the answer is recognized
+func askYesNo(in *bufio.Reader, out io.Writer, question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		switch strings.ToLower(ask(in, out, question, hint)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		case strings.ToLower(hint):
+			return def
+		}
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
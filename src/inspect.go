@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runInspect implements the "inspect" subcommand, which prints the JPEG
+// segments or MP4 atoms of a file with their offsets, sizes and dates
+func runInspect(args []string) int {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the structure as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd inspect [-json] <file>\n\n")
+		fmt.Fprintf(os.Stderr, "Prints the JPEG segments or MP4/MOV/M4A atoms of a file with their offsets,\n")
+		fmt.Fprintf(os.Stderr, "sizes and decoded date fields. Nothing is modified.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	inspection, err := processor.Inspect(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(inspection, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(out))
+		return 0
+	}
+
+	fmt.Printf("%s: %s, %d bytes\n\n", inspection.File, inspection.Container, inspection.Size)
+	fmt.Printf("%10s %10s  %s\n", "OFFSET", "SIZE", "NAME")
+	printInspectNodes(inspection.Nodes, 0)
+	return 0
+}
+
+// printInspectNodes prints nodes as an indented tree, dates under each node
+func printInspectNodes(nodes []processor.InspectNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, node := range nodes {
+		fmt.Printf("%10d %10d  %s%s\n", node.Offset, node.Size, indent, node.Name)
+
+		names := make([]string, 0, len(node.Dates))
+		for name := range node.Dates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%10s %10s  %s  %s: %s\n", "", "", indent, name, node.Dates[name])
+		}
+
+		printInspectNodes(node.Children, depth+1)
+	}
+}
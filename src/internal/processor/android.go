@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// AndroidMediaDirs lists where WhatsApp keeps its Media directory on Android,
+// newest layout first (scoped storage on Android 11+, then the legacy path).
+// WhatsApp Business uses the same layout under its own package name.
+var AndroidMediaDirs = []string{
+	"/sdcard/Android/media/com.whatsapp/WhatsApp/Media",
+	"/sdcard/WhatsApp/Media",
+	"/sdcard/Android/media/com.whatsapp.w4b/WhatsApp Business/Media",
+	"/sdcard/WhatsApp Business/Media",
+}
+
+// AdbArgs builds adb arguments, targeting a specific device when serial is set
+func AdbArgs(serial string, args ...string) []string {
+	if serial == "" {
+		return args
+	}
+	return append([]string{"-s", serial}, args...)
+}
+
+// FindAndroidMediaDir returns the first WhatsApp Media directory present on
+// the connected device
+func FindAndroidMediaDir(serial string) (string, error) {
+	if _, err := exec.LookPath("adb"); err != nil {
+		return "", fmt.Errorf("adb is required but was not found on PATH")
+	}
+
+	for _, dir := range AndroidMediaDirs {
+		// "ls -d" exits non-zero when the directory doesn't exist
+		cmd := exec.Command("adb", AdbArgs(serial, "shell", "ls", "-d", "'"+dir+"'")...)
+		out, err := cmd.CombinedOutput()
+		if err == nil && strings.TrimSpace(string(out)) == dir {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf("no WhatsApp Media directory found on device (is it connected and authorized?)")
+}
+
+// PullAndroidMedia copies a directory from the device into destDir using adb pull
+func PullAndroidMedia(serial, remoteDir, destDir string) error {
+	if _, err := exec.LookPath("adb"); err != nil {
+		return fmt.Errorf("adb is required but was not found on PATH")
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %v", err)
+	}
+
+	cmd := exec.Command("adb", AdbArgs(serial, "pull", "-a", remoteDir, destDir)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("adb pull failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+package processor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveExtensions lists the supported archive extensions, longest first
+var archiveExtensions = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// archiveExt returns the archive extension of path, or "" if it isn't one
+func archiveExt(path string) string {
+	lower := strings.ToLower(path)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// IsArchive checks if the path looks like a supported archive (ZIP, tar or tar.gz)
+func IsArchive(path string) bool {
+	return archiveExt(path) != ""
+}
+
+// IsZipArchive checks if the path looks like a ZIP archive (by extension)
+func IsZipArchive(path string) bool {
+	return archiveExt(path) == ".zip"
+}
+
+// isTarArchive checks if the path looks like a tar archive, compressed or not
+func isTarArchive(path string) bool {
+	ext := archiveExt(path)
+	return ext == ".tar" || ext == ".tar.gz" || ext == ".tgz"
+}
+
+// isGzipped checks if the archive is gzip-compressed
+func isGzipped(path string) bool {
+	ext := archiveExt(path)
+	return ext == ".tar.gz" || ext == ".tgz"
+}
+
+// IsSupportedMediaFile checks if the file has a supported image, video or audio extension
+func IsSupportedMediaFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return isImageFormat(ext) || isVideoFormat(ext) || isAudioFormat(ext)
+}
+
+// ArchiveExtractDir returns the default directory media from an archive is
+// extracted to: the archive path without extension plus "_modified"
+func ArchiveExtractDir(archivePath string) string {
+	return archivePath[:len(archivePath)-len(archiveExt(archivePath))] + "_modified"
+}
+
+// ArchiveRepackPath returns the path a processed archive is repacked to: the
+// extract directory with the original archive extension. A directory named
+// "." or ".." is made absolute first, so "-out ." packs to "<cwd>.zip".
+func ArchiveRepackPath(archivePath, extractDir string) string {
+	dir := filepath.Clean(extractDir)
+	if base := filepath.Base(dir); base == "." || base == ".." {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+	}
+	return dir + archiveExt(archivePath)
+}
+
+// ValidateArchiveExtractDir checks that media from archivePath can be
+// extracted to extractDir: extracting into the archive's own directory, or a
+// directory holding it, would mix the extracted media with the files already
+// there and rewrite them in place
+func ValidateArchiveExtractDir(archivePath, extractDir string) error {
+	archiveDir, err := filepath.Abs(filepath.Dir(archivePath))
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(extractDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(dir, archiveDir); err == nil && (rel == "." || !strings.HasPrefix(rel, "..")) {
+		return fmt.Errorf("output directory %s holds the archive %s: extract to a directory of its own", extractDir, filepath.Base(archivePath))
+	}
+	return nil
+}
+
+// ListArchiveMedia returns the paths that supported media entries in an archive
+// would have once extracted to destDir, without extracting anything
+func ListArchiveMedia(archivePath, destDir string) ([]string, error) {
+	if isTarArchive(archivePath) {
+		return walkTarMedia(archivePath, destDir, false)
+	}
+	return ListZipMedia(archivePath, destDir)
+}
+
+// ExtractArchiveMedia extracts supported media from a ZIP or tar archive into
+// destDir, preserving relative paths, and returns the extracted file paths
+func ExtractArchiveMedia(archivePath, destDir string) ([]string, error) {
+	if isTarArchive(archivePath) {
+		return walkTarMedia(archivePath, destDir, true)
+	}
+	return ExtractZipMedia(archivePath, destDir)
+}
+
+// PackArchive repacks files into a new archive at archivePath, using the
+// format implied by its extension
+func PackArchive(archivePath, baseDir string, files []string) error {
+	if isTarArchive(archivePath) {
+		return PackTar(archivePath, baseDir, files)
+	}
+	return PackZip(archivePath, baseDir, files)
+}
+
+// safeArchivePath joins an archive entry name onto destDir, rejecting entries
+// that would escape it (zip-slip)
+func safeArchivePath(destDir, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry escapes destination: %s", name)
+	}
+	return filepath.Join(destDir, cleaned), nil
+}
+
+// ListZipMedia returns the paths that supported media entries in a ZIP archive
+// would have once extracted to destDir, without extracting anything
+func ListZipMedia(zipPath, destDir string) ([]string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %v", err)
+	}
+	defer r.Close()
+
+	var files []string
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !IsSupportedMediaFile(f.Name) {
+			continue
+		}
+		target, err := safeArchivePath(destDir, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, target)
+	}
+
+	return files, nil
+}
+
+// ExtractZipMedia extracts supported media entries from a ZIP archive into
+// destDir, preserving relative paths, and returns the extracted file paths
+func ExtractZipMedia(zipPath, destDir string) ([]string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %v", err)
+	}
+	defer r.Close()
+
+	var files []string
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !IsSupportedMediaFile(f.Name) {
+			continue
+		}
+
+		target, err := safeArchivePath(destDir, f.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := extractZipEntry(f, target); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %v", f.Name, err)
+		}
+		files = append(files, target)
+	}
+
+	return files, nil
+}
+
+// extractZipEntry writes a single ZIP entry to target
+func extractZipEntry(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// Keep the archived modification time so -m isn't the only source of truth
+	return os.Chtimes(target, f.Modified, f.Modified)
+}
+
+// PackZip writes the given files into a new ZIP archive at zipPath, storing
+// each one relative to baseDir
+func PackZip(zipPath, baseDir string, files []string) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to create zip archive: %v", err)
+	}
+
+	w := zip.NewWriter(out)
+	for _, file := range files {
+		if err := addFileToZip(w, baseDir, file); err != nil {
+			w.Close()
+			out.Close()
+			return fmt.Errorf("failed to add %s to zip archive: %v", file, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to finalize zip archive: %v", err)
+	}
+	return out.Close()
+}
+
+// addFileToZip adds a single file to a ZIP writer, named relative to baseDir
+func addFileToZip(w *zip.Writer, baseDir, file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(baseDir, file)
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(rel)
+	header.Method = zip.Deflate
+
+	dst, err := w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// walkTarMedia streams through a tar (optionally gzip-compressed) archive and
+// returns the destination paths of supported media entries, extracting them
+// when extract is true
+func walkTarMedia(tarPath, destDir string, extract bool) ([]string, error) {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tar archive: %v", err)
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	if isGzipped(tarPath) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %v", err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	tr := tar.NewReader(src)
+	var files []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive: %v", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !IsSupportedMediaFile(header.Name) {
+			continue
+		}
+
+		target, err := safeArchivePath(destDir, header.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if extract {
+			if err := extractTarEntry(tr, header, target); err != nil {
+				return nil, fmt.Errorf("failed to extract %s: %v", header.Name, err)
+			}
+		}
+		files = append(files, target)
+	}
+
+	return files, nil
+}
+
+// extractTarEntry writes the current tar entry to target
+func extractTarEntry(tr *tar.Reader, header *tar.Header, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, tr); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(target, header.ModTime, header.ModTime)
+}
+
+// PackTar writes the given files into a new tar archive at tarPath (gzip
+// compressed for .tar.gz/.tgz), storing each one relative to baseDir
+func PackTar(tarPath, baseDir string, files []string) error {
+	out, err := os.Create(tarPath)
+	if err != nil {
+		return fmt.Errorf("failed to create tar archive: %v", err)
+	}
+
+	var dst io.Writer = out
+	var gz *gzip.Writer
+	if isGzipped(tarPath) {
+		gz = gzip.NewWriter(out)
+		dst = gz
+	}
+
+	tw := tar.NewWriter(dst)
+	for _, file := range files {
+		if err := addFileToTar(tw, baseDir, file); err != nil {
+			tw.Close()
+			out.Close()
+			return fmt.Errorf("failed to add %s to tar archive: %v", file, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to finalize tar archive: %v", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to finalize gzip stream: %v", err)
+		}
+	}
+	return out.Close()
+}
+
+// addFileToTar adds a single file to a tar writer, named relative to baseDir
+func addFileToTar(tw *tar.Writer, baseDir, file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(baseDir, file)
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(rel)
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(tw, src)
+	return err
+}
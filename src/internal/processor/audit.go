@@ -0,0 +1,240 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one line of an audit log: a file a run changed, with its
+// hashes and modification times around the change
+type AuditEntry struct {
+	Time          string `json:"time"` // When the change was recorded, RFC 3339 UTC
+	InputFile     string `json:"inputFile"`
+	OutputFile    string `json:"outputFile"`
+	PreHash       string `json:"preHash"`
+	PostHash      string `json:"postHash"`
+	ModTimeBefore string `json:"modTimeBefore,omitempty"` // RFC 3339 with nanoseconds
+	ModTimeAfter  string `json:"modTimeAfter,omitempty"`
+	DateWritten   string `json:"dateWritten"`
+	Backend       string `json:"backend,omitempty"`
+
+	// SHA-256 of the previous line ("" on the first), chaining the lines
+	// so that editing, removing or reordering any of them is detected
+	Prev string `json:"prev"`
+}
+
+// auditLockWait is how long an append waits for other runs appending to
+// the same audit log
+const auditLockWait = 30 * time.Second
+
+// AuditLog appends entries to an audit log as the changes happen. Appends
+// are serialized within the run and, through a file lock, with other runs
+// appending to the same log, so the hash chain never forks.
+type AuditLog struct {
+	mu    sync.Mutex
+	f     *os.File
+	count int
+}
+
+// OpenAuditLog opens the audit log at path for appending, creating it and
+// its directory if needed. The log is only ever appended to.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	return &AuditLog{f: f}, nil
+}
+
+// Append appends an entry for r if it changed a file (written, copied or
+// re-timed), and reports whether it did. The entry is synced to disk
+// before Append returns.
+func (l *AuditLog) Append(r ProcessResult, now time.Time) (bool, error) {
+	entry, changed := newAuditEntry(r, now)
+	if !changed {
+		return false, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	unlock, err := lockAuditLog(l.f)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	// Another run may have appended since: chain to the log's last line
+	if entry.Prev, err = lastAuditHash(l.f); err != nil {
+		return false, err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode audit entry: %v", err)
+	}
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return false, fmt.Errorf("failed to write audit log: %v", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return false, fmt.Errorf("failed to write audit log: %v", err)
+	}
+	l.count++
+	return true, nil
+}
+
+// Count returns how many entries this AuditLog appended
+func (l *AuditLog) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+// Close closes the log file
+func (l *AuditLog) Close() error {
+	return l.f.Close()
+}
+
+// lockAuditLog takes the audit log's file lock, waiting up to auditLockWait
+// for other runs' appends, and returns its release. Where locks aren't
+// supported, appends from different runs aren't kept apart.
+func lockAuditLog(f *os.File) (func(), error) {
+	deadline := time.Now().Add(auditLockWait)
+	for {
+		err := flock(f)
+		switch {
+		case err == nil:
+			return func() { funlock(f) }, nil
+		case errors.Is(err, errLockUnsupported):
+			return func() {}, nil
+		case errors.Is(err, ErrLocked) && time.Now().Before(deadline):
+			time.Sleep(10 * time.Millisecond)
+		case errors.Is(err, ErrLocked):
+			return nil, fmt.Errorf("audit log is %w", ErrLocked)
+		default:
+			return nil, fmt.Errorf("failed to lock audit log: %v", err)
+		}
+	}
+}
+
+// AppendAuditLog appends an entry for every file results changed (written,
+// copied or re-timed) to the audit log at path, creating it and its
+// directory if needed, and returns how many were appended
+func AppendAuditLog(path string, results []ProcessResult, now time.Time) (int, error) {
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		return 0, err
+	}
+	defer auditLog.Close()
+	for _, r := range results {
+		if _, err := auditLog.Append(r, now); err != nil {
+			return auditLog.Count(), err
+		}
+	}
+	return auditLog.Count(), nil
+}
+
+// newAuditEntry describes a result for the audit log; changed is false for
+// results that didn't change any file
+func newAuditEntry(r ProcessResult, now time.Time) (AuditEntry, bool) {
+	if !r.Success || r.Skipped || r.PostHash == "" {
+		return AuditEntry{}, false
+	}
+	entry := AuditEntry{
+		Time:        now.UTC().Format(time.RFC3339),
+		InputFile:   r.InputFile,
+		OutputFile:  r.OutputFile,
+		PreHash:     r.PreHash,
+		PostHash:    r.PostHash,
+		DateWritten: r.DateTime.Format("2006-01-02T15:04:05"),
+		Backend:     r.Backend,
+	}
+	if !r.OriginalModTime.IsZero() {
+		entry.ModTimeBefore = r.OriginalModTime.UTC().Format(time.RFC3339Nano)
+	}
+	retimed := false
+	if info, err := os.Stat(r.OutputFile); err == nil {
+		entry.ModTimeAfter = info.ModTime().UTC().Format(time.RFC3339Nano)
+		retimed = !info.ModTime().Equal(r.OriginalModTime)
+	}
+	changed := r.OutputFile != r.InputFile || r.PreHash != r.PostHash || retimed
+	return entry, changed
+}
+
+// auditLineHash returns the hex SHA-256 of a log line, without its newline
+func auditLineHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// lastAuditHash returns the hash of the last line of an audit log, "" when
+// it is empty. Only the end of the log is read, growing the window until
+// it holds the whole line.
+func lastAuditHash(f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to read audit log: %v", err)
+	}
+	size := info.Size()
+	for window := int64(4096); ; window *= 2 {
+		window = min(window, size)
+		data := make([]byte, window)
+		if _, err := f.ReadAt(data, size-window); err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read audit log: %v", err)
+		}
+		data = bytes.TrimRight(data, "\n")
+		start := bytes.LastIndexByte(data, '\n')
+		if start < 0 && window < size {
+			continue // The line starts before the window
+		}
+		if len(data) == 0 {
+			return "", nil
+		}
+		return auditLineHash(data[start+1:]), nil
+	}
+}
+
+// VerifyAuditLog checks that every line of the audit log at path chains to
+// the one before it, and returns how many entries it holds and the hash of
+// the last one. The error names the first line that was edited, removed or
+// reordered. Lines cut from the end of the log leave a valid chain: compare
+// the last hash with one noted earlier to detect that.
+func VerifyAuditLog(path string) (int, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	prev := ""
+	n := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var entry AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return n, prev, fmt.Errorf("line %d: invalid entry: %v", n+1, err)
+		}
+		if entry.Prev != prev {
+			return n, prev, fmt.Errorf("line %d: chain broken (a line before it was changed, removed or reordered)", n+1)
+		}
+		prev = auditLineHash(line)
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, prev, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	return n, prev, nil
+}
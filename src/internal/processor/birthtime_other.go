@@ -0,0 +1,10 @@
+//go:build !windows
+
+package processor
+
+import "time"
+
+// setBirthTime has nothing left to do here: macOS moves the birth time back
+// when the modification time is set earlier than it, and Linux offers no
+// way to set it
+func setBirthTime(path string, t time.Time) error { return nil }
@@ -0,0 +1,379 @@
+package processor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CloudTarget describes an s3:// or gs:// output location
+type CloudTarget struct {
+	Scheme string // "s3" or "gs"
+	Bucket string
+	Prefix string // Key prefix without leading or trailing slash
+}
+
+// CloudCredentials holds HMAC credentials and the endpoint for an S3-compatible API
+type CloudCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Endpoint        string // Base URL, e.g. https://s3.us-east-1.amazonaws.com
+}
+
+// UploadResult holds the result of uploading a single file
+type UploadResult struct {
+	File     string
+	URI      string
+	Attempts int
+	Error    error
+}
+
+// MaxObjectSize is the largest file a single PUT can upload; S3 rejects
+// anything bigger
+const MaxObjectSize = 5 << 30
+
+// StatusError is the error of an upload the server answered with a non-2xx
+// status
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string // Start of the response body, e.g. the S3 error code
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upload failed with status %s: %s", e.Status, e.Body)
+}
+
+// Temporary reports whether the same upload may succeed later: server
+// errors, throttling and request timeouts. Other statuses (403, 404
+// NoSuchBucket, 400) fail the same way every time.
+func (e *StatusError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= 500
+}
+
+// IsCloudTarget checks if the output path is an s3:// or gs:// URI
+func IsCloudTarget(output string) bool {
+	lower := strings.ToLower(output)
+	return strings.HasPrefix(lower, "s3://") || strings.HasPrefix(lower, "gs://")
+}
+
+// ParseCloudTarget parses an s3://bucket/prefix or gs://bucket/prefix URI
+func ParseCloudTarget(uri string) (*CloudTarget, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud URI: %v", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "s3" && scheme != "gs" {
+		return nil, fmt.Errorf("unsupported cloud scheme: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("cloud URI has no bucket: %s", uri)
+	}
+
+	return &CloudTarget{
+		Scheme: scheme,
+		Bucket: u.Host,
+		Prefix: strings.Trim(u.Path, "/"),
+	}, nil
+}
+
+// Key returns the object key for a file at rel, a path relative to the
+// directory being uploaded
+func (t *CloudTarget) Key(rel string) string {
+	key := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(rel)), "/")
+	if t.Prefix == "" {
+		return key
+	}
+	return t.Prefix + "/" + key
+}
+
+// URI returns the s3:// or gs:// URI of an object key
+func (t *CloudTarget) URI(key string) string {
+	return t.Scheme + "://" + t.Bucket + "/" + key
+}
+
+// LoadCloudCredentials reads credentials for the target scheme from the environment.
+// S3 uses AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION
+// and AWS_ENDPOINT_URL (for S3-compatible services). GCS uses HMAC keys from
+// GCS_HMAC_ACCESS_KEY and GCS_HMAC_SECRET against its XML interoperability API.
+func LoadCloudCredentials(scheme string) (*CloudCredentials, error) {
+	var creds CloudCredentials
+
+	if scheme == "gs" {
+		creds = CloudCredentials{
+			AccessKeyID:     os.Getenv("GCS_HMAC_ACCESS_KEY"),
+			SecretAccessKey: os.Getenv("GCS_HMAC_SECRET"),
+			Region:          "auto",
+			Endpoint:        "https://storage.googleapis.com",
+		}
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return nil, fmt.Errorf("GCS_HMAC_ACCESS_KEY and GCS_HMAC_SECRET must be set for gs:// output")
+		}
+		return &creds, nil
+	}
+
+	creds = CloudCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          os.Getenv("AWS_REGION"),
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set for s3:// output")
+	}
+	if creds.Region == "" {
+		creds.Region = "us-east-1"
+	}
+	if creds.Endpoint == "" {
+		creds.Endpoint = "https://s3." + creds.Region + ".amazonaws.com"
+	}
+	return &creds, nil
+}
+
+// Uploader uploads files to a cloud target with bounded concurrency and retries
+type Uploader struct {
+	Target  *CloudTarget
+	Creds   *CloudCredentials
+	Client  *http.Client
+	Workers int
+	Retries int
+	Backoff time.Duration // Base delay between retries, doubled on each attempt
+	Root    string        // Directory keys are relative to, so subdirectories are kept ("" = file names only)
+}
+
+// NewUploader creates an Uploader with default HTTP client and backoff
+func NewUploader(target *CloudTarget, creds *CloudCredentials, workers, retries int) *Uploader {
+	if workers < 1 {
+		workers = 1
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return &Uploader{
+		Target:  target,
+		Creds:   creds,
+		Client:  &http.Client{Timeout: 10 * time.Minute},
+		Workers: workers,
+		Retries: retries,
+		Backoff: time.Second,
+	}
+}
+
+// UploadFiles uploads all files and returns one result per file, in input order
+func (u *Uploader) UploadFiles(files []string) []UploadResult {
+	results := make([]UploadResult, len(files))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < u.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = u.uploadWithRetry(files[i])
+			}
+		}()
+	}
+
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// key returns the object key of a file: its path under Root, or its name
+// when it isn't under Root
+func (u *Uploader) key(file string) string {
+	rel := filepath.Base(file)
+	if u.Root != "" {
+		if r, err := filepath.Rel(u.Root, file); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			rel = r
+		}
+	}
+	return u.Target.Key(rel)
+}
+
+// uploadWithRetry uploads a single file, retrying attempts that failed in a
+// way that may not happen again (see retryable)
+func (u *Uploader) uploadWithRetry(file string) UploadResult {
+	key := u.key(file)
+	result := UploadResult{File: file, URI: u.Target.URI(key)}
+
+	delay := u.Backoff
+	for attempt := 0; attempt <= u.Retries; attempt++ {
+		result.Attempts = attempt + 1
+		result.Error = u.putObject(file, key)
+		if result.Error == nil || !retryable(result.Error) {
+			return result
+		}
+		if attempt < u.Retries {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return result
+}
+
+// retryable reports whether an upload error is worth another attempt:
+// transport errors and temporary statuses. Files that can't be read or are
+// too big fail the same way every time.
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// putObject uploads a file with a single SigV4-signed PUT request. The file
+// is read twice, to hash it for the signature and as the request body, and
+// never held in memory whole. Files over MaxObjectSize are refused before
+// anything is sent.
+func (u *Uploader) putObject(file, key string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	if info.Size() > MaxObjectSize {
+		return fmt.Errorf("file is %s, more than the 5 GiB a single upload accepts", FormatSize(info.Size()))
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+
+	endpoint, err := url.Parse(u.Creds.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %v", err)
+	}
+	endpoint.Path = path.Join("/", endpoint.Path, u.Target.Bucket, key)
+	endpoint.RawPath = awsURIEncodePath(endpoint.Path)
+
+	req, err := http.NewRequest(http.MethodPut, endpoint.String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	SignV4(req, hex.EncodeToString(h.Sum(nil)), u.Creds, time.Now().UTC())
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// SignV4 signs a request with AWS Signature Version 4 for the s3 service.
+// payloadHash is the hex SHA-256 of the body. Every header already set on
+// the request is signed.
+func SignV4(req *http.Request, payloadHash string, creds *CloudCredentials, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	// Canonical headers: lowercase names, sorted
+	var names []string
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.Join(strings.Fields(req.Header.Get(name)), " ") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncodePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := dateStamp + "/" + creds.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), dateStamp)
+	key = hmacSHA256(key, creds.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsURIEncodePath escapes a path the way SigV4 canonical requests do:
+// every byte but unreserved characters and the "/" separators
+func awsURIEncodePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
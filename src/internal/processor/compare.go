@@ -0,0 +1,312 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of CompareEntry
+const (
+	CompareIdentical = "identical"  // Byte-identical copies
+	CompareMetadata  = "metadata"   // Same media, different metadata (dates, EXIF, XMP...)
+	CompareContent   = "content"    // Different media
+	CompareOnlyLeft  = "only-left"  // Only in the first directory
+	CompareOnlyRight = "only-right" // Only in the second directory
+)
+
+// CompareEntry is how one file of two directories compares
+type CompareEntry struct {
+	Kind  string `json:"kind"`
+	Left  string `json:"left,omitempty"`  // Path in the first directory
+	Right string `json:"right,omitempty"` // Path in the second directory
+	Error string `json:"error,omitempty"` // Why the media couldn't be compared (Kind is content)
+}
+
+// CompareDirs pairs the media files of left and right, by relative path or
+// else by a name unique on both sides ("_modified" copies pair with their
+// originals), and reports whether each pair is identical, differs only by
+// metadata or differs in content. JPEG and MP4 media are compared without
+// their metadata; other formats byte for byte. Entries are sorted by path,
+// differences first.
+func CompareDirs(left, right string, includeDocuments bool) ([]CompareEntry, error) {
+	leftFiles, err := GetMediaFiles(left, includeDocuments)
+	if err != nil {
+		return nil, err
+	}
+	rightFiles, err := GetMediaFiles(right, includeDocuments)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CompareEntry
+	unpairedLeft, unpairedRight := make(map[string]string), make(map[string]string)
+	rightByRel := relativePaths(right, rightFiles)
+	for rel, path := range relativePaths(left, leftFiles) {
+		if other, ok := rightByRel[compareKey(rel)]; ok {
+			entries = append(entries, compareFiles(path, other))
+			delete(rightByRel, compareKey(rel))
+			continue
+		}
+		unpairedLeft[rel] = path
+	}
+	for rel, path := range rightByRel {
+		unpairedRight[rel] = path
+	}
+
+	// Files moved into other folders (year/month...) pair by a name unique
+	// on both sides
+	leftByName, rightByName := uniqueNames(unpairedLeft), uniqueNames(unpairedRight)
+	for name, path := range leftByName {
+		if other, ok := rightByName[name]; ok {
+			entries = append(entries, compareFiles(path, other))
+			delete(unpairedLeft, relOf(left, path))
+			delete(unpairedRight, relOf(right, other))
+		}
+	}
+	for _, path := range unpairedLeft {
+		entries = append(entries, CompareEntry{Kind: CompareOnlyLeft, Left: path})
+	}
+	for _, path := range unpairedRight {
+		entries = append(entries, CompareEntry{Kind: CompareOnlyRight, Right: path})
+	}
+
+	order := map[string]int{CompareContent: 0, CompareMetadata: 1, CompareOnlyLeft: 2, CompareOnlyRight: 3, CompareIdentical: 4}
+	sort.Slice(entries, func(a, b int) bool {
+		if order[entries[a].Kind] != order[entries[b].Kind] {
+			return order[entries[a].Kind] < order[entries[b].Kind]
+		}
+		return entries[a].Left+entries[a].Right < entries[b].Left+entries[b].Right
+	})
+	return entries, nil
+}
+
+// relativePaths indexes files by their path relative to dir, with any
+// "_modified" suffix dropped
+func relativePaths(dir string, files []string) map[string]string {
+	byRel := make(map[string]string, len(files))
+	for _, path := range files {
+		byRel[compareKey(relOf(dir, path))] = path
+	}
+	return byRel
+}
+
+// relOf returns path relative to dir, keyed like relativePaths
+func relOf(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		rel = path
+	}
+	return compareKey(rel)
+}
+
+// compareKey drops the "_modified" suffix wappd gives copies written next
+// to their originals
+func compareKey(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(strings.TrimSuffix(path, ext), "_modified") + ext
+}
+
+// uniqueNames indexes files by base name, leaving out names used twice
+func uniqueNames(files map[string]string) map[string]string {
+	byName := make(map[string]string)
+	seen := make(map[string]int)
+	for rel, path := range files {
+		name := filepath.Base(rel)
+		seen[name]++
+		byName[name] = path
+	}
+	for name, n := range seen {
+		if n > 1 {
+			delete(byName, name)
+		}
+	}
+	return byName
+}
+
+// compareFiles compares two copies of a file
+func compareFiles(left, right string) CompareEntry {
+	entry := CompareEntry{Left: left, Right: right, Kind: CompareContent}
+	leftHash, err := hashFile(OSFS, left)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	rightHash, err := hashFile(OSFS, right)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	if leftHash == rightHash {
+		entry.Kind = CompareIdentical
+		return entry
+	}
+
+	leftMedia, err := MediaHash(left)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	rightMedia, err := MediaHash(right)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	if leftMedia != "" && leftMedia == rightMedia {
+		entry.Kind = CompareMetadata
+	}
+	return entry
+}
+
+// MediaHash returns the SHA-256 of a JPEG's or MP4's media without its
+// metadata: for JPEGs, every segment but the APPn and comment ones, and
+// the image data; for MP4s, every atom but free space, with moov's udta
+// and meta dropped and the mvhd and tkhd times zeroed. Returns "" for other
+// formats.
+func MediaHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	header := make([]byte, 12)
+	n, _ := io.ReadFull(f, header)
+
+	switch SniffContainer(header[:n]) {
+	case ContainerJPEG:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return jpegMediaHash(data)
+	case ContainerMP4:
+		info, err := f.Stat()
+		if err != nil {
+			return "", err
+		}
+		return mp4MediaHash(f, info.Size())
+	}
+	return "", nil
+}
+
+// jpegMediaHash is MediaHash for a JPEG's bytes
+func jpegMediaHash(data []byte) (string, error) {
+	segments, err := ParseJPEGSegments(data)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, s := range segments {
+		if (s.Marker >= markerAPP0 && s.Marker <= 0xEF) || s.Marker == 0xFE {
+			continue
+		}
+		h.Write([]byte{0xFF, s.Marker})
+		h.Write(s.Payload)
+	}
+	h.Write(data[jpegImageDataStart(data):])
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// mp4MediaHash is MediaHash for an MP4 file of size bytes. The media data
+// is streamed; only moov is read into memory.
+func mp4MediaHash(f *os.File, size int64) (string, error) {
+	mdatStart, err := findMdatStart(f, size)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	header := make([]byte, 16)
+	for offset := int64(0); offset < size; {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return "", fmt.Errorf("MP4 atoms do not parse: %v", err)
+		}
+		atomSize, headerLen := int64(binary.BigEndian.Uint32(header[0:4])), int64(8)
+		switch atomSize {
+		case 0: // Atom extends to the end of the file
+			atomSize = size - offset
+		case 1:
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return "", fmt.Errorf("MP4 atoms do not parse: %v", err)
+			}
+			atomSize, headerLen = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+		if atomSize < headerLen || atomSize > size-offset {
+			return "", fmt.Errorf("MP4 atoms do not parse: invalid atom size %d", atomSize)
+		}
+		atomType := string(header[4:8])
+		body := io.NewSectionReader(f, offset+headerLen, atomSize-headerLen)
+
+		switch atomType {
+		case "free", "skip":
+		case "moov":
+			if atomSize > maxMoovSize {
+				return "", fmt.Errorf("moov of %d bytes is too large", atomSize)
+			}
+			moov := make([]byte, atomSize-headerLen)
+			if _, err := io.ReadFull(body, moov); err != nil {
+				return "", fmt.Errorf("failed to read moov: %v", err)
+			}
+			h.Write([]byte(atomType))
+			hashMoovMedia(h, moov, mdatStart)
+		default:
+			h.Write([]byte(atomType))
+			if _, err := io.Copy(h, body); err != nil {
+				return "", err
+			}
+		}
+		offset += atomSize
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findMdatStart returns the file offset of the first mdat's payload, 0
+// without one. Atom sizes are checked by mp4MediaHash.
+func findMdatStart(f *os.File, size int64) (int64, error) {
+	header := make([]byte, 16)
+	for offset := int64(0); offset+8 <= size; {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return 0, fmt.Errorf("MP4 atoms do not parse: %v", err)
+		}
+		atomSize, headerLen := int64(binary.BigEndian.Uint32(header[0:4])), int64(8)
+		if atomSize == 1 {
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, fmt.Errorf("MP4 atoms do not parse: %v", err)
+			}
+			atomSize, headerLen = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+		if string(header[4:8]) == "mdat" {
+			return offset + headerLen, nil
+		}
+		if atomSize < headerLen {
+			return 0, nil
+		}
+		offset += atomSize
+	}
+	return 0, nil
+}
+
+// hashMoovMedia writes a moov payload to h without its metadata: udta and
+// meta are dropped, the mvhd, tkhd and mdhd times zeroed and the stco/co64
+// chunk offsets made relative to mdatStart, so metadata growing a moov that
+// precedes the mdat doesn't change the hash
+func hashMoovMedia(h io.Writer, moov []byte, mdatStart int64) {
+	patchHeaderAtoms(moov, 0, allHeaderAtoms)
+	shiftChunkOffsets(moov, 0, -mdatStart)
+	for pos := 0; pos+8 <= len(moov); {
+		size, atomType, _, err := readAtomHeader(moov, pos)
+		if err != nil {
+			h.Write(moov[pos:])
+			return
+		}
+		if atomType != "udta" && atomType != "meta" && atomType != "free" {
+			h.Write(moov[pos : pos+int(size)])
+		}
+		pos += int(size)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 const (
@@ -18,11 +20,82 @@ func ConfigFileName() string {
 
 // ConfigFile represents the JSON configuration file structure
 type ConfigFile struct {
-	UpdateModified   *bool  `json:"updateModified,omitempty"`
-	OverwriteExif   *bool  `json:"overwriteExif,omitempty"`
-	OverrideOriginal *bool  `json:"overrideOriginal,omitempty"`
-	OutputDir        string `json:"outputDir,omitempty"`
-	Verbose          *bool  `json:"verbose,omitempty"`
+	UpdateModified       *bool    `json:"updateModified,omitempty"`
+	OverwriteExif        *bool    `json:"overwriteExif,omitempty"`
+	OverrideOriginal     *bool    `json:"overrideOriginal,omitempty"`
+	OutputDir            string   `json:"outputDir,omitempty"`
+	OutputTemplate       string   `json:"outputTemplate,omitempty"`
+	SanitizeNames        string   `json:"sanitizeNames,omitempty"`
+	Verbose              *bool    `json:"verbose,omitempty"`
+	ManifestPath         string   `json:"manifest,omitempty"`
+	AuditLog             string   `json:"auditLog,omitempty"`
+	Backend              string   `json:"backend,omitempty"`
+	AllowFFmpeg          *bool    `json:"allowFfmpeg,omitempty"`
+	Timezone             string   `json:"timezone,omitempty"`
+	Offset               string   `json:"offset,omitempty"`
+	IncludeDocuments     *bool    `json:"includeDocuments,omitempty"`
+	Stickers             string   `json:"stickers,omitempty"`
+	FixExtensions        *bool    `json:"fixExtensions,omitempty"`
+	MaxFailures          string   `json:"maxFailures,omitempty"`
+	Strict               *bool    `json:"strict,omitempty"`
+	IgnoreUnmatched      *bool    `json:"ignoreUnmatched,omitempty"`
+	TagSent              *bool    `json:"tagSent,omitempty"`
+	Tags                 []string `json:"tags,omitempty"`
+	SoftwareTag          *bool    `json:"softwareTag,omitempty"`
+	InferDates           *bool    `json:"inferDates,omitempty"`
+	SpreadTimes          *bool    `json:"disambiguateTimes,omitempty"`
+	SafeMode             *bool    `json:"safeMode,omitempty"`
+	Chown                string   `json:"chown,omitempty"`
+	SkipCorrect          string   `json:"skipCorrect,omitempty"`
+	NormalizeOrientation *bool    `json:"normalizeOrientation,omitempty"`
+	MtimeOnly            *bool    `json:"mtimeOnly,omitempty"`
+	NoCopy               *bool    `json:"noCopy,omitempty"`
+	DatePolicy           string   `json:"datePolicy,omitempty"`
+	ZeroMvhd             string   `json:"zeroMvhd,omitempty"`
+	Writers              []string `json:"writers,omitempty"`
+	VideoAtoms           []string `json:"videoAtoms,omitempty"`
+	GPS                  string   `json:"gps,omitempty"`
+	GPX                  string   `json:"gpx,omitempty"`
+	Settle               string   `json:"settle,omitempty"`
+	MemoryLimit          string   `json:"memoryLimit,omitempty"`
+	SidecarOnly          *bool    `json:"sidecarOnly,omitempty"`
+	RenameMap            string   `json:"renameMap,omitempty"`
+	Targets              []Target `json:"targets,omitempty"`
+
+	// Filename patterns tried before the built-in WhatsApp ones
+	Patterns []FilenamePattern `json:"patterns,omitempty"`
+
+	// Optional built-in pattern sets tried after the WhatsApp ones
+	ExtraPatterns []string `json:"extraPatterns,omitempty"`
+
+	// Extensions added to or (with a leading -) left out of directory scans
+	Extensions []string `json:"extensions,omitempty"`
+
+	// Also scan for files by their content, whatever their extension
+	DetectContent *bool `json:"detectContent,omitempty"`
+
+	// Directory levels scanned (1 = the directory's own files only)
+	MaxDepth *int `json:"maxDepth,omitempty"`
+
+	// Scan into symlinked directories
+	FollowSymlinks *bool `json:"followSymlinks,omitempty"`
+
+	// Names of directories left out of scans, with * and ? wildcards
+	SkipDirs []string `json:"skipDirs,omitempty"`
+
+	// Print per-stage timings for each file
+	Timings *bool `json:"timings,omitempty"`
+
+	// Named option sets applied on top of the other options by --profile
+	Profiles map[string]ConfigFile `json:"profiles,omitempty"`
+}
+
+// Target is one root directory of a multi-volume run. Options set on a
+// target override the top-level config file values for that directory only.
+type Target struct {
+	Dir   string `json:"dir"`
+	Media string `json:"media,omitempty"` // Only process this kind of media ("" = all)
+	ConfigFile
 }
 
 // LoadConfigFile loads configuration from wappd.json if it exists in the specified directory
@@ -39,34 +112,48 @@ func LoadConfigFileFromPath(configPath string) (*ConfigFile, error) {
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return nil, nil // No config file is fine
 	}
-	
+
 	// Read config file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
-	
+
+	data = stripJSONComments(data)
+	problems, err := checkConfigSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	// Values are only checked once every key has the right type
 	var config ConfigFile
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := json.Unmarshal(data, &config); err == nil {
+		problems = append(problems, ValidateConfigFile(&config)...)
+	} else if len(problems) == 0 {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
-	
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config file:\n  %s", strings.Join(problems, "\n  "))
+	}
+
 	return &config, nil
 }
 
 // MergeConfig merges config file values with CLI flags
 // CLI flags take precedence over config file values
 // For boolean flags: if CLI flag is true (explicitly set), it overrides config.
-//                    if CLI flag is false (default), config file value is used if present.
+//
+//	if CLI flag is false (default), config file value is used if present.
+//
 // For strings: if CLI flag is non-empty, it overrides config.
-//              if CLI flag is empty, config file value is used if present.
+//
+//	if CLI flag is empty, config file value is used if present.
 func MergeConfig(fileConfig *ConfigFile, cliConfig Config) Config {
 	result := cliConfig
-	
+
 	if fileConfig == nil {
 		return result
 	}
-	
+
 	// Boolean flags: CLI true overrides, CLI false allows config file default
 	if fileConfig.UpdateModified != nil {
 		if cliConfig.UpdateModified {
@@ -77,7 +164,7 @@ func MergeConfig(fileConfig *ConfigFile, cliConfig Config) Config {
 			result.UpdateModified = *fileConfig.UpdateModified
 		}
 	}
-	
+
 	if fileConfig.OverwriteExif != nil {
 		if cliConfig.OverwriteExif {
 			result.OverwriteExif = true
@@ -85,7 +172,7 @@ func MergeConfig(fileConfig *ConfigFile, cliConfig Config) Config {
 			result.OverwriteExif = *fileConfig.OverwriteExif
 		}
 	}
-	
+
 	if fileConfig.OverrideOriginal != nil {
 		if cliConfig.OverrideOriginal {
 			result.OverrideOriginal = true
@@ -93,7 +180,7 @@ func MergeConfig(fileConfig *ConfigFile, cliConfig Config) Config {
 			result.OverrideOriginal = *fileConfig.OverrideOriginal
 		}
 	}
-	
+
 	if fileConfig.Verbose != nil {
 		if cliConfig.Verbose {
 			result.Verbose = true
@@ -101,7 +188,7 @@ func MergeConfig(fileConfig *ConfigFile, cliConfig Config) Config {
 			result.Verbose = *fileConfig.Verbose
 		}
 	}
-	
+
 	// String flags: CLI non-empty overrides, CLI empty allows config file default
 	if fileConfig.OutputDir != "" {
 		if cliConfig.OutputDir != "" {
@@ -112,8 +199,511 @@ func MergeConfig(fileConfig *ConfigFile, cliConfig Config) Config {
 			result.OutputDir = fileConfig.OutputDir
 		}
 	}
-	
+
+	if fileConfig.OutputTemplate != "" && cliConfig.OutputTemplate == "" {
+		result.OutputTemplate = fileConfig.OutputTemplate
+	}
+
+	if fileConfig.SanitizeNames != "" && cliConfig.SanitizeNames == "" {
+		result.SanitizeNames = fileConfig.SanitizeNames
+	}
+
+	if fileConfig.ManifestPath != "" && cliConfig.ManifestPath == "" {
+		result.ManifestPath = fileConfig.ManifestPath
+	}
+
+	if fileConfig.AuditLog != "" && cliConfig.AuditLog == "" {
+		result.AuditLog = fileConfig.AuditLog
+	}
+
+	if fileConfig.AllowFFmpeg != nil && !cliConfig.AllowFFmpeg {
+		result.AllowFFmpeg = *fileConfig.AllowFFmpeg
+	}
+
+	if fileConfig.Timezone != "" && cliConfig.Timezone == "" {
+		result.Timezone = fileConfig.Timezone
+	}
+
+	if fileConfig.Offset != "" && cliConfig.Offset == "" {
+		result.Offset = fileConfig.Offset
+	}
+
+	if fileConfig.IncludeDocuments != nil && !cliConfig.IncludeDocuments {
+		result.IncludeDocuments = *fileConfig.IncludeDocuments
+	}
+
+	if fileConfig.Stickers != "" && cliConfig.Stickers == "" {
+		result.Stickers = fileConfig.Stickers
+	}
+
+	if fileConfig.FixExtensions != nil && !cliConfig.FixExtensions {
+		result.FixExtensions = *fileConfig.FixExtensions
+	}
+
+	if fileConfig.MaxFailures != "" && cliConfig.MaxFailures == "" {
+		result.MaxFailures = fileConfig.MaxFailures
+	}
+
+	if fileConfig.Strict != nil && !cliConfig.Strict {
+		result.Strict = *fileConfig.Strict
+	}
+
+	if fileConfig.IgnoreUnmatched != nil && !cliConfig.IgnoreUnmatched {
+		result.IgnoreUnmatched = *fileConfig.IgnoreUnmatched
+	}
+
+	if fileConfig.TagSent != nil && !cliConfig.TagSent {
+		result.TagSent = *fileConfig.TagSent
+	}
+
+	if len(fileConfig.Tags) > 0 && len(cliConfig.Tags) == 0 {
+		result.Tags = fileConfig.Tags
+	}
+
+	if fileConfig.SoftwareTag != nil && !cliConfig.SoftwareTag {
+		result.SoftwareTag = *fileConfig.SoftwareTag
+	}
+
+	if fileConfig.InferDates != nil && !cliConfig.InferDates {
+		result.InferDates = *fileConfig.InferDates
+	}
+
+	if fileConfig.SpreadTimes != nil && !cliConfig.SpreadTimes {
+		result.SpreadTimes = *fileConfig.SpreadTimes
+	}
+
+	if fileConfig.SafeMode != nil && !cliConfig.SafeMode {
+		result.SafeMode = *fileConfig.SafeMode
+	}
+
+	if fileConfig.Chown != "" && cliConfig.Chown == "" {
+		result.Chown = fileConfig.Chown
+	}
+
+	if fileConfig.SkipCorrect != "" && cliConfig.SkipCorrect == "" {
+		result.SkipCorrect = fileConfig.SkipCorrect
+	}
+
+	if fileConfig.NormalizeOrientation != nil && !cliConfig.NormalizeOrientation {
+		result.NormalizeOrientation = *fileConfig.NormalizeOrientation
+	}
+
+	if fileConfig.MtimeOnly != nil && !cliConfig.MtimeOnly {
+		result.MtimeOnly = *fileConfig.MtimeOnly
+	}
+
+	if fileConfig.NoCopy != nil && !cliConfig.NoCopy {
+		result.NoCopy = *fileConfig.NoCopy
+	}
+
+	if fileConfig.DatePolicy != "" && cliConfig.DatePolicy == "" {
+		result.DatePolicy = fileConfig.DatePolicy
+	}
+
+	if fileConfig.ZeroMvhd != "" && cliConfig.ZeroMvhd == "" {
+		result.ZeroMvhd = fileConfig.ZeroMvhd
+	}
+
+	if len(fileConfig.Writers) > 0 && len(cliConfig.Writers) == 0 {
+		result.Writers = fileConfig.Writers
+	}
+
+	if len(fileConfig.VideoAtoms) > 0 && len(cliConfig.VideoAtoms) == 0 {
+		result.VideoAtoms = fileConfig.VideoAtoms
+	}
+
+	if fileConfig.GPS != "" && cliConfig.GPS == "" {
+		result.GPS = fileConfig.GPS
+	}
+
+	if fileConfig.GPX != "" && cliConfig.GPX == "" {
+		result.GPX = fileConfig.GPX
+	}
+
+	if fileConfig.Settle != "" && cliConfig.Settle == "" {
+		result.Settle = fileConfig.Settle
+	}
+
+	if fileConfig.MemoryLimit != "" && cliConfig.MemoryLimit == "" {
+		result.MemoryLimit = fileConfig.MemoryLimit
+	}
+
+	if fileConfig.SidecarOnly != nil && !cliConfig.SidecarOnly {
+		result.SidecarOnly = *fileConfig.SidecarOnly
+	}
+
+	if fileConfig.RenameMap != "" && cliConfig.RenameMap == "" {
+		result.RenameMap = fileConfig.RenameMap
+	}
+
+	if fileConfig.Backend != "" && cliConfig.Backend == "" {
+		result.Backend = fileConfig.Backend
+	}
+
+	if len(fileConfig.Patterns) > 0 && len(cliConfig.Patterns) == 0 {
+		result.Patterns = fileConfig.Patterns
+	}
+
+	if len(fileConfig.ExtraPatterns) > 0 && len(cliConfig.ExtraPatterns) == 0 {
+		result.ExtraPatterns = fileConfig.ExtraPatterns
+	}
+
+	if len(fileConfig.Extensions) > 0 && len(cliConfig.Extensions) == 0 {
+		result.Extensions = fileConfig.Extensions
+	}
+
+	if fileConfig.DetectContent != nil && !cliConfig.DetectContent {
+		result.DetectContent = *fileConfig.DetectContent
+	}
+
+	if fileConfig.MaxDepth != nil && cliConfig.MaxDepth == 0 {
+		result.MaxDepth = *fileConfig.MaxDepth
+	}
+
+	if fileConfig.FollowSymlinks != nil && !cliConfig.FollowSymlinks {
+		result.FollowSymlinks = *fileConfig.FollowSymlinks
+	}
+
+	if len(fileConfig.SkipDirs) > 0 && len(cliConfig.SkipDirs) == 0 {
+		result.SkipDirs = fileConfig.SkipDirs
+	}
+
+	if fileConfig.Timings != nil && !cliConfig.Timings {
+		result.Timings = *fileConfig.Timings
+	}
+
 	// Note: DryRun is not in config file - always CLI-only for safety
-	
+
 	return result
 }
+
+// stripJSONComments blanks out // line comments outside of strings, so
+// config files written by "wappd init" (or by hand) can be annotated
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	inString := false
+	for i := 0; i < len(out); i++ {
+		switch {
+		case inString && out[i] == '\\':
+			i++
+		case out[i] == '"':
+			inString = !inString
+		case !inString && out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		}
+	}
+	return out
+}
+
+// configComments describes each config option in the file written by
+// FormatCommentedConfig, in output order
+var configComments = []struct {
+	key     string
+	comment string
+	value   func(c *ConfigFile) interface{}
+}{
+	{"updateModified", "Also set each file's last modified time to the extracted date", func(c *ConfigFile) interface{} { return c.UpdateModified }},
+	{"overwriteExif", "Replace EXIF/video dates that are already present", func(c *ConfigFile) interface{} { return c.OverwriteExif }},
+	{"gps", "Approximate position for JPEGs without one, \"lat,lon\" in decimal degrees", func(c *ConfigFile) interface{} { return c.GPS }},
+	{"gpx", "GPX or KML track whose point nearest each JPEG's date gives its position", func(c *ConfigFile) interface{} { return c.GPX }},
+	{"writers", "Writers allowed to change files (omit for all): jpegExif, mp4Mvhd, m4aDate, opusDate, pdfDate, pngChunks, webpExif, heicExif, gifXmp, mtime", func(c *ConfigFile) interface{} { return c.Writers }},
+	{"videoAtoms", "MP4 atoms videos and M4A files get the date in (omit for mvhd and tkhd, plus day for M4A): mvhd, tkhd, mdhd, day, keys", func(c *ConfigFile) interface{} { return c.VideoAtoms }},
+	{"zeroMvhd", "Make videos follow overwriteExif, counting an mvhd creation time of 0 or invalid as missing or existing", func(c *ConfigFile) interface{} { return c.ZeroMvhd }},
+	{"datePolicy", "Date to keep when the embedded one differs from the filename's: earliest, filename or existing", func(c *ConfigFile) interface{} { return c.DatePolicy }},
+	{"overrideOriginal", "Write over the original files instead of creating _modified copies", func(c *ConfigFile) interface{} { return c.OverrideOriginal }},
+	{"outputDir", "Directory processed copies are written to", func(c *ConfigFile) interface{} { return c.OutputDir }},
+	{"outputTemplate", "Output path of each file, e.g. {out}/{year}/{month}/{name}{ext}", func(c *ConfigFile) interface{} { return c.OutputTemplate }},
+	{"sanitizeNames", "Make output names valid on another filesystem: windows or macos", func(c *ConfigFile) interface{} { return c.SanitizeNames }},
+	{"verbose", "Print detailed processing information", func(c *ConfigFile) interface{} { return c.Verbose }},
+	{"manifest", "Write a SHA-256 manifest of processed files to this path", func(c *ConfigFile) interface{} { return c.ManifestPath }},
+	{"auditLog", "Append a hash-chained JSON line per changed file to this log", func(c *ConfigFile) interface{} { return c.AuditLog }},
+	{"backend", "Metadata writer: native, exiftool or auto", func(c *ConfigFile) interface{} { return c.Backend }},
+	{"allowFfmpeg", "Remux videos with ffmpeg when native editing fails", func(c *ConfigFile) interface{} { return c.AllowFFmpeg }},
+	{"timezone", "IANA timezone filename times are local to", func(c *ConfigFile) interface{} { return c.Timezone }},
+	{"offset", "Clock skew correction added to every date, e.g. +2h30m", func(c *ConfigFile) interface{} { return c.Offset }},
+	{"includeDocuments", "Also process WhatsApp documents (DOC-*.pdf etc.)", func(c *ConfigFile) interface{} { return c.IncludeDocuments }},
+	{"stickers", "Sticker handling: skip, mtime or process", func(c *ConfigFile) interface{} { return c.Stickers }},
+	{"fixExtensions", "Rename files whose content doesn't match their extension", func(c *ConfigFile) interface{} { return c.FixExtensions }},
+	{"strict", "Refuse to process a batch containing filenames no pattern matches", func(c *ConfigFile) interface{} { return c.Strict }},
+	{"ignoreUnmatched", "Skip filenames no pattern matches instead of counting them as failures", func(c *ConfigFile) interface{} { return c.IgnoreUnmatched }},
+	{"tagSent", "Record wappd:direction=sent in the metadata of files under a Sent folder", func(c *ConfigFile) interface{} { return c.TagSent }},
+	{"tags", "XMP keywords (dc:subject) added wherever metadata is written", func(c *ConfigFile) interface{} { return c.Tags }},
+	{"softwareTag", "Name wappd and its version in the EXIF Software tag of the metadata it writes", func(c *ConfigFile) interface{} { return c.SoftwareTag }},
+	{"inferDates", "Date unmatched files sitting between matched ones from their neighbors", func(c *ConfigFile) interface{} { return c.InferDates }},
+	{"disambiguateTimes", "Spread files that share a date one second apart, in name order", func(c *ConfigFile) interface{} { return c.SpreadTimes }},
+	{"safeMode", "Refuse to write anywhere symlinks lead outside the input and output directories", func(c *ConfigFile) interface{} { return c.SafeMode }},
+	{"chown", "Give outputs to this user:group (Unix), e.g. \"media:users\"", func(c *ConfigFile) interface{} { return c.Chown }},
+	{"skipCorrect", "Don't rewrite metadata already within this tolerance of the date, e.g. \"1s\"", func(c *ConfigFile) interface{} { return c.SkipCorrect }},
+	{"normalizeOrientation", "Rotate JPEG pixels to match their EXIF Orientation and reset it (re-encodes them)", func(c *ConfigFile) interface{} { return c.NormalizeOrientation }},
+	{"noCopy", "Refuse to write copies: only edit originals in place (with overrideOriginal)", func(c *ConfigFile) interface{} { return c.NoCopy }},
+	{"mtimeOnly", "Only set file times (modification, access and, where possible, creation) and never change file contents", func(c *ConfigFile) interface{} { return c.MtimeOnly }},
+	{"settle", "Wait this long before processing and retry files still being written, e.g. \"2s\"", func(c *ConfigFile) interface{} { return c.Settle }},
+	{"memoryLimit", "Cap the file buffers held at once and patch videos in place beyond it, e.g. \"256MiB\"", func(c *ConfigFile) interface{} { return c.MemoryLimit }},
+	{"sidecarOnly", "Never modify media: write each file's date to <file>.xmp and <file>.json sidecars instead", func(c *ConfigFile) interface{} { return c.SidecarOnly }},
+	{"renameMap", "With sidecarOnly, write the renames the run would have made to this CSV file", func(c *ConfigFile) interface{} { return c.RenameMap }},
+	{"maxFailures", "Abort the batch after this many failures, e.g. 5 or \"10%\"", func(c *ConfigFile) interface{} { return c.MaxFailures }},
+	{"patterns", "Filename patterns tried before the built-in ones, with the time and timezone of their dates", func(c *ConfigFile) interface{} { return c.Patterns }},
+	{"extraPatterns", "Optional built-in pattern sets tried after the WhatsApp ones: camera (IMG_20240501_123045)", func(c *ConfigFile) interface{} { return c.ExtraPatterns }},
+	{"extensions", "Extensions added to the ones scanned for, or left out with a leading -, e.g. [\"heic\", \"-gif\"]", func(c *ConfigFile) interface{} { return c.Extensions }},
+	{"detectContent", "Also scan for files whose first bytes are a known image or video container, whatever their extension", func(c *ConfigFile) interface{} { return c.DetectContent }},
+	{"maxDepth", "Directory levels scanned: 1 = the directory's own files only (0 = no limit)", func(c *ConfigFile) interface{} { return c.MaxDepth }},
+	{"followSymlinks", "Scan into symlinked directories (each real directory once)", func(c *ConfigFile) interface{} { return c.FollowSymlinks }},
+	{"skipDirs", "Names of directories left out of scans, with * and ? wildcards, e.g. [\".Statuses\", \"Backup*\"]", func(c *ConfigFile) interface{} { return c.SkipDirs }},
+	{"timings", "Print how long each file spent in each stage (parse, read, copy, write, chtimes); implies verbose", func(c *ConfigFile) interface{} { return c.Timings }},
+}
+
+// FormatCommentedConfig renders the options set in config as a wappd.json
+// with a // comment above each entry. Targets and profiles are not included.
+func FormatCommentedConfig(config *ConfigFile) []byte {
+	var entries []string
+	for _, option := range configComments {
+		value := option.value(config)
+		switch v := value.(type) {
+		case *bool:
+			if v == nil {
+				continue
+			}
+			value = *v
+		case string:
+			if v == "" {
+				continue
+			}
+		case []string:
+			if len(v) == 0 {
+				continue
+			}
+		case []FilenamePattern:
+			if len(v) == 0 {
+				continue
+			}
+		}
+		encoded, _ := json.Marshal(value)
+		entries = append(entries, fmt.Sprintf("  // %s\n  %q: %s", option.comment, option.key, encoded))
+	}
+
+	var b strings.Builder
+	b.WriteString("{\n")
+	b.WriteString("  // Generated by \"wappd init\". Command-line flags override these values.\n")
+	b.WriteString("  // Lines starting with // are comments.\n")
+	if len(entries) > 0 {
+		b.WriteString("\n")
+		b.WriteString(strings.Join(entries, ",\n\n"))
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// ApplyProfile returns config with the options of the named profile
+// applied on top. The result keeps config's targets but no profiles.
+func ApplyProfile(config *ConfigFile, name string) (*ConfigFile, error) {
+	profile, ok := config.Profiles[name]
+	if !ok {
+		if len(config.Profiles) == 0 {
+			return nil, fmt.Errorf("unknown profile %q (the config file defines no profiles)", name)
+		}
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(ProfileNames(config), ", "))
+	}
+	result := OverlayConfigFile(config, &profile)
+	result.Targets = config.Targets
+	return result, nil
+}
+
+// ProfileNames returns the names of the profiles in config, sorted
+func ProfileNames(config *ConfigFile) []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OverlayConfigFile returns base with every option set in override applied
+// on top. Either argument may be nil. Targets and profiles are never
+// inherited.
+func OverlayConfigFile(base, override *ConfigFile) *ConfigFile {
+	if base == nil && override == nil {
+		return nil
+	}
+	result := ConfigFile{}
+	if base != nil {
+		result = *base
+	}
+	result.Targets = nil
+	result.Profiles = nil
+	if override == nil {
+		return &result
+	}
+
+	if override.UpdateModified != nil {
+		result.UpdateModified = override.UpdateModified
+	}
+	if override.OverwriteExif != nil {
+		result.OverwriteExif = override.OverwriteExif
+	}
+	if override.OverrideOriginal != nil {
+		result.OverrideOriginal = override.OverrideOriginal
+	}
+	if override.OutputDir != "" {
+		result.OutputDir = override.OutputDir
+	}
+	if override.OutputTemplate != "" {
+		result.OutputTemplate = override.OutputTemplate
+	}
+	if override.SanitizeNames != "" {
+		result.SanitizeNames = override.SanitizeNames
+	}
+	if override.Verbose != nil {
+		result.Verbose = override.Verbose
+	}
+	if override.ManifestPath != "" {
+		result.ManifestPath = override.ManifestPath
+	}
+	if override.AuditLog != "" {
+		result.AuditLog = override.AuditLog
+	}
+	if override.Backend != "" {
+		result.Backend = override.Backend
+	}
+	if override.AllowFFmpeg != nil {
+		result.AllowFFmpeg = override.AllowFFmpeg
+	}
+	if override.Timezone != "" {
+		result.Timezone = override.Timezone
+	}
+	if override.Offset != "" {
+		result.Offset = override.Offset
+	}
+	if override.IncludeDocuments != nil {
+		result.IncludeDocuments = override.IncludeDocuments
+	}
+	if override.Stickers != "" {
+		result.Stickers = override.Stickers
+	}
+	if override.FixExtensions != nil {
+		result.FixExtensions = override.FixExtensions
+	}
+	if override.MaxFailures != "" {
+		result.MaxFailures = override.MaxFailures
+	}
+	if override.Strict != nil {
+		result.Strict = override.Strict
+	}
+	if override.IgnoreUnmatched != nil {
+		result.IgnoreUnmatched = override.IgnoreUnmatched
+	}
+	if override.TagSent != nil {
+		result.TagSent = override.TagSent
+	}
+	if override.Tags != nil {
+		result.Tags = override.Tags
+	}
+	if override.SoftwareTag != nil {
+		result.SoftwareTag = override.SoftwareTag
+	}
+	if override.InferDates != nil {
+		result.InferDates = override.InferDates
+	}
+	if override.SpreadTimes != nil {
+		result.SpreadTimes = override.SpreadTimes
+	}
+	if override.SafeMode != nil {
+		result.SafeMode = override.SafeMode
+	}
+	if override.Chown != "" {
+		result.Chown = override.Chown
+	}
+	if override.SkipCorrect != "" {
+		result.SkipCorrect = override.SkipCorrect
+	}
+	if override.NormalizeOrientation != nil {
+		result.NormalizeOrientation = override.NormalizeOrientation
+	}
+	if override.MtimeOnly != nil {
+		result.MtimeOnly = override.MtimeOnly
+	}
+	if override.NoCopy != nil {
+		result.NoCopy = override.NoCopy
+	}
+	if override.DatePolicy != "" {
+		result.DatePolicy = override.DatePolicy
+	}
+	if override.ZeroMvhd != "" {
+		result.ZeroMvhd = override.ZeroMvhd
+	}
+	if override.Writers != nil {
+		result.Writers = override.Writers
+	}
+	if override.VideoAtoms != nil {
+		result.VideoAtoms = override.VideoAtoms
+	}
+	if override.GPS != "" {
+		result.GPS = override.GPS
+	}
+	if override.GPX != "" {
+		result.GPX = override.GPX
+	}
+	if override.Settle != "" {
+		result.Settle = override.Settle
+	}
+	if override.MemoryLimit != "" {
+		result.MemoryLimit = override.MemoryLimit
+	}
+	if override.SidecarOnly != nil {
+		result.SidecarOnly = override.SidecarOnly
+	}
+	if override.RenameMap != "" {
+		result.RenameMap = override.RenameMap
+	}
+	if override.Patterns != nil {
+		result.Patterns = override.Patterns
+	}
+	if override.ExtraPatterns != nil {
+		result.ExtraPatterns = override.ExtraPatterns
+	}
+	if override.Extensions != nil {
+		result.Extensions = override.Extensions
+	}
+	if override.DetectContent != nil {
+		result.DetectContent = override.DetectContent
+	}
+	if override.MaxDepth != nil {
+		result.MaxDepth = override.MaxDepth
+	}
+	if override.FollowSymlinks != nil {
+		result.FollowSymlinks = override.FollowSymlinks
+	}
+	if override.SkipDirs != nil {
+		result.SkipDirs = override.SkipDirs
+	}
+	if override.Timings != nil {
+		result.Timings = override.Timings
+	}
+	return &result
+}
+
+// TargetOutputDirs returns the output directory for each input directory when
+// a single -out is shared by several targets: every target gets a
+// subdirectory named after its input directory, numbered on name clashes.
+// Works for both local paths and s3:// / gs:// URIs.
+func TargetOutputDirs(outputDir string, dirs []string) []string {
+	outputs := make([]string, len(dirs))
+	seen := make(map[string]int)
+	base := strings.TrimRight(outputDir, "/")
+	for i, dir := range dirs {
+		name := filepath.Base(filepath.Clean(dir))
+		if name == "." || name == "/" || name == "" {
+			name = "target"
+		}
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+		outputs[i] = base + "/" + name
+	}
+	return outputs
+}
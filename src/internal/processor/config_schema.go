@@ -0,0 +1,382 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/apercova/wappd/scan"
+)
+
+// configKeyTypes maps each JSON key of a config struct (embedded structs
+// included) to the Go type of its field
+func configKeyTypes(t reflect.Type) map[string]reflect.Type {
+	keys := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			for key, typ := range configKeyTypes(field.Type) {
+				keys[key] = typ
+			}
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = field.Type
+		}
+	}
+	return keys
+}
+
+var (
+	configFileKeys = configKeyTypes(reflect.TypeOf(ConfigFile{}))
+	targetKeys     = configKeyTypes(reflect.TypeOf(Target{}))
+	patternKeys    = configKeyTypes(reflect.TypeOf(FilenamePattern{}))
+)
+
+// checkConfigSchema reports unknown keys and values of the wrong JSON type
+// in a config file, one problem per entry
+func checkConfigSchema(data []byte) ([]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	problems := checkConfigObject(raw, configFileKeys, "")
+	if targets, ok := raw["targets"]; ok && jsonKind(targets) == "an array" {
+		var items []json.RawMessage
+		json.Unmarshal(targets, &items)
+		for i, item := range items {
+			prefix := fmt.Sprintf("targets[%d].", i)
+			var target map[string]json.RawMessage
+			if jsonKind(item) != "an object" || json.Unmarshal(item, &target) != nil {
+				problems = append(problems, fmt.Sprintf("%q: expected an object, got %s", strings.TrimSuffix(prefix, "."), jsonKind(item)))
+				continue
+			}
+			problems = append(problems, checkNestedConfigObject(target, targetKeys, prefix)...)
+		}
+	}
+	if profiles, ok := raw["profiles"]; ok && jsonKind(profiles) == "an object" {
+		var items map[string]json.RawMessage
+		json.Unmarshal(profiles, &items)
+		names := make([]string, 0, len(items))
+		for name := range items {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prefix := "profiles." + name + "."
+			var profile map[string]json.RawMessage
+			if jsonKind(items[name]) != "an object" || json.Unmarshal(items[name], &profile) != nil {
+				problems = append(problems, fmt.Sprintf("%q: expected an object, got %s", strings.TrimSuffix(prefix, "."), jsonKind(items[name])))
+				continue
+			}
+			problems = append(problems, checkNestedConfigObject(profile, configFileKeys, prefix)...)
+		}
+	}
+	return problems, nil
+}
+
+// checkNestedConfigObject checks a target or profile object, which may not
+// list targets or profiles of its own
+func checkNestedConfigObject(raw map[string]json.RawMessage, known map[string]reflect.Type, prefix string) []string {
+	var problems []string
+	for _, key := range []string{"targets", "profiles"} {
+		if _, nested := raw[key]; nested {
+			problems = append(problems, fmt.Sprintf("%q: %s can only be listed at the top level", prefix+key, key))
+			delete(raw, key)
+		}
+	}
+	return append(problems, checkConfigObject(raw, known, prefix)...)
+}
+
+// checkConfigObject checks the keys of one JSON object against the known
+// keys and their types; prefix locates the object in the file
+func checkConfigObject(raw map[string]json.RawMessage, known map[string]reflect.Type, prefix string) []string {
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		typ, ok := known[name]
+		if !ok {
+			problem := fmt.Sprintf("unknown key %q", prefix+name)
+			if suggestion := suggestConfigKey(name, known); suggestion != "" {
+				problem += fmt.Sprintf(" (did you mean %q?)", suggestion)
+			}
+			problems = append(problems, problem)
+			continue
+		}
+		value := raw[name]
+		if jsonKind(value) == "null" {
+			continue
+		}
+		// Targets and profiles are checked one by one by the caller
+		if name == "targets" {
+			if jsonKind(value) != "an array" {
+				problems = append(problems, fmt.Sprintf("%q: expected an array of objects, got %s", prefix+name, jsonKind(value)))
+			}
+			continue
+		}
+		if name == "profiles" {
+			if jsonKind(value) != "an object" {
+				problems = append(problems, fmt.Sprintf("%q: expected an object of named option sets, got %s", prefix+name, jsonKind(value)))
+			}
+			continue
+		}
+		if name == "patterns" && jsonKind(value) == "an array" {
+			problems = append(problems, checkPatternObjects(value, prefix+name)...)
+			continue
+		}
+		if err := json.Unmarshal(value, reflect.New(typ).Interface()); err != nil {
+			problems = append(problems, fmt.Sprintf("%q: expected %s, got %s", prefix+name, describeConfigType(typ), jsonKind(value)))
+		}
+	}
+	return problems
+}
+
+// checkPatternObjects checks each entry of a patterns array
+func checkPatternObjects(value json.RawMessage, key string) []string {
+	var items []json.RawMessage
+	json.Unmarshal(value, &items)
+	var problems []string
+	for i, item := range items {
+		prefix := fmt.Sprintf("%s[%d]", key, i)
+		var pattern map[string]json.RawMessage
+		if jsonKind(item) != "an object" || json.Unmarshal(item, &pattern) != nil {
+			problems = append(problems, fmt.Sprintf("%q: expected an object, got %s", prefix, jsonKind(item)))
+			continue
+		}
+		if _, ok := pattern["pattern"]; !ok {
+			problems = append(problems, fmt.Sprintf("%q: missing \"pattern\"", prefix))
+		}
+		problems = append(problems, checkConfigObject(pattern, patternKeys, prefix+".")...)
+	}
+	return problems
+}
+
+// jsonKind names the JSON type of a raw value
+func jsonKind(value json.RawMessage) string {
+	trimmed := strings.TrimSpace(string(value))
+	if trimmed == "" {
+		return "nothing"
+	}
+	switch trimmed[0] {
+	case '"':
+		return "a string"
+	case '{':
+		return "an object"
+	case '[':
+		return "an array"
+	case 't', 'f':
+		return "a boolean"
+	case 'n':
+		return "null"
+	}
+	return "a number"
+}
+
+// describeConfigType names the JSON value a config field accepts
+func describeConfigType(typ reflect.Type) string {
+	switch {
+	case typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Bool:
+		return "true or false"
+	case typ.Kind() == reflect.String:
+		return "a string"
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.String:
+		return "an array of strings"
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Struct:
+		return "an array of objects"
+	}
+	return typ.String()
+}
+
+// suggestConfigKey returns the known key closest to an unknown one, or ""
+// when none is close enough to be a likely typo
+func suggestConfigKey(name string, known map[string]reflect.Type) string {
+	keys := make([]string, 0, len(known))
+	for key := range known {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	best, bestDistance := "", 3
+	for _, key := range keys {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(key)); d < bestDistance {
+			best, bestDistance = key, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// ValidateConfigFile checks the option values of a loaded config file, its
+// targets and its profiles, returning one problem per invalid value
+func ValidateConfigFile(config *ConfigFile) []string {
+	problems := validateConfigValues(config, "")
+	for i, target := range config.Targets {
+		prefix := fmt.Sprintf("targets[%d].", i)
+		if strings.TrimSpace(target.Dir) == "" {
+			problems = append(problems, fmt.Sprintf("%q: every target needs a directory", prefix+"dir"))
+		}
+		if err := ValidateMediaKind(target.Media); err != nil {
+			problems = append(problems, fmt.Sprintf("%q: %v", prefix+"media", err))
+		}
+		problems = append(problems, validateConfigValues(&target.ConfigFile, prefix)...)
+	}
+	for _, name := range ProfileNames(config) {
+		profile := config.Profiles[name]
+		problems = append(problems, validateConfigValues(&profile, "profiles."+name+".")...)
+	}
+	return problems
+}
+
+// validateConfigValues checks the option values of one config level
+func validateConfigValues(config *ConfigFile, prefix string) []string {
+	var problems []string
+	add := func(key string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%q: %v", prefix+key, err))
+		}
+	}
+
+	add("backend", ValidateBackend(config.Backend))
+	add("stickers", ValidateStickerMode(config.Stickers))
+	_, err := LoadTimezone(config.Timezone)
+	add("timezone", err)
+	_, err = ParseClockOffset(config.Offset)
+	add("offset", err)
+	_, err = ParseFailureLimit(config.MaxFailures)
+	add("maxFailures", err)
+	add("tags", ValidateTags(config.Tags))
+	add("outputDir", validateOutputDir(config.OutputDir))
+	add("outputTemplate", ValidateOutputTemplate(config.OutputTemplate))
+	add("sanitizeNames", ValidateSanitizeMode(config.SanitizeNames))
+	add("datePolicy", ValidateDatePolicy(config.DatePolicy))
+	add("zeroMvhd", ValidateZeroMvhd(config.ZeroMvhd))
+	add("writers", ValidateWriters(config.Writers))
+	add("videoAtoms", ValidateVideoAtoms(config.VideoAtoms))
+	_, err = ParseGPS(config.GPS)
+	add("gps", err)
+	_, err = LoadTrack(config.GPX)
+	add("gpx", err)
+	add("manifest", validateReportPath(config.ManifestPath))
+	add("auditLog", validateReportPath(config.AuditLog))
+	add("renameMap", validateReportPath(config.RenameMap))
+	_, err = ParseOwner(config.Chown)
+	add("chown", err)
+	_, err = ParseCorrectTolerance(config.SkipCorrect)
+	add("skipCorrect", err)
+	_, err = ParseSettleTime(config.Settle)
+	add("settle", err)
+	_, err = ParseMemoryLimit(config.MemoryLimit)
+	add("memoryLimit", err)
+	add("extraPatterns", ValidateExtraPatterns(config.ExtraPatterns))
+	add("extensions", scan.ValidateExtensions(config.Extensions))
+	add("skipDirs", scan.ValidateSkipNames(config.SkipDirs))
+	if config.MaxDepth != nil && *config.MaxDepth < 0 {
+		add("maxDepth", fmt.Errorf("must be 0 (no limit) or more, got %d", *config.MaxDepth))
+	}
+	for i, pattern := range config.Patterns {
+		add(fmt.Sprintf("patterns[%d]", i), ValidateFilenamePattern(pattern))
+	}
+	if config.Strict != nil && *config.Strict && config.IgnoreUnmatched != nil && *config.IgnoreUnmatched {
+		problems = append(problems, fmt.Sprintf("%q and %q cannot both be true", prefix+"strict", prefix+"ignoreUnmatched"))
+	}
+	if config.OverwriteExif != nil && *config.OverwriteExif && config.DatePolicy != "" && config.DatePolicy != DatePolicyFilename {
+		problems = append(problems, fmt.Sprintf("%q contradicts %q %q", prefix+"overwriteExif", prefix+"datePolicy", config.DatePolicy))
+	}
+	if config.NoCopy != nil && *config.NoCopy && config.OverrideOriginal != nil && !*config.OverrideOriginal {
+		problems = append(problems, fmt.Sprintf("%q requires %q", prefix+"noCopy", prefix+"overrideOriginal"))
+	}
+	if config.MtimeOnly != nil && *config.MtimeOnly && len(config.Writers) > 0 && !slices.Contains(config.Writers, WriterMtime) {
+		problems = append(problems, fmt.Sprintf("%q needs %q in %q", prefix+"mtimeOnly", WriterMtime, prefix+"writers"))
+	}
+	if config.NormalizeOrientation != nil && *config.NormalizeOrientation && config.MtimeOnly != nil && *config.MtimeOnly {
+		problems = append(problems, fmt.Sprintf("%q and %q cannot both be true", prefix+"normalizeOrientation", prefix+"mtimeOnly"))
+	}
+	if config.NormalizeOrientation != nil && *config.NormalizeOrientation && config.Backend == BackendExiftool {
+		problems = append(problems, fmt.Sprintf("%q requires the native backend", prefix+"normalizeOrientation"))
+	}
+	if config.SidecarOnly != nil && *config.SidecarOnly {
+		names := []string{"updateModified", "normalizeOrientation", "mtimeOnly"}
+		for i, set := range []*bool{config.UpdateModified, config.NormalizeOrientation, config.MtimeOnly} {
+			if set != nil && *set {
+				problems = append(problems, fmt.Sprintf("%q and %q cannot both be true", prefix+"sidecarOnly", prefix+names[i]))
+			}
+		}
+	}
+	if config.RenameMap != "" && (config.SidecarOnly == nil || !*config.SidecarOnly) {
+		problems = append(problems, fmt.Sprintf("%q requires %q", prefix+"renameMap", prefix+"sidecarOnly"))
+	}
+	return problems
+}
+
+// validateOutputDir checks that an output directory is a valid cloud URI or
+// a path that is (or can become) a directory
+func validateOutputDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if IsCloudTarget(dir) {
+		_, err := ParseCloudTarget(dir)
+		return err
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		return fmt.Errorf("%s exists and is not a directory", dir)
+	}
+	return nil
+}
+
+// validateReportPath checks that a file written at the end of a run (the
+// manifest, audit log or rename map) can be: it isn't a directory, and
+// nothing but directories is in the way of creating its parent, which
+// happens when it is written. A run shouldn't fail only once every file is
+// processed.
+func validateReportPath(path string) error {
+	if path == "" {
+		return nil
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(dir); err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			return nil
+		}
+		if filepath.Dir(dir) == dir {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Console decorates the per-file lines printed during a run: status marks
+// in color and paths shortened to fit the terminal
+type Console struct {
+	Color bool // Color status marks with ANSI escapes
+	Width int  // Columns a line may take before its paths are shortened (0 = no limit)
+}
+
+// NewConsole returns the console for output to f. Colors are used only when
+// f is a terminal, NO_COLOR isn't set (https://no-color.org) and noColor is
+// false. Lines are fitted to the terminal's width, or to COLUMNS when set;
+// output to a file or pipe keeps full paths whatever COLUMNS says.
+func NewConsole(f *os.File, noColor bool) Console {
+	if !isTerminal(f) {
+		return Console{}
+	}
+	c := Console{Color: !noColor && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb", Width: terminalWidth(f)}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		c.Width = n
+	}
+	return c
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// markColors are the ANSI colors of the status marks
+var markColors = map[string]string{
+	"✓": "32", // Green: processed
+	"✗": "31", // Red: failed
+	"!": "33", // Yellow: warning
+	"~": "33", // Yellow: guessed
+	"=": "36", // Cyan: already correct
+	"-": "2",  // Dim: skipped
+}
+
+// Mark returns a status mark, colored when the console has colors
+func (c Console) Mark(mark string) string {
+	if code, ok := markColors[mark]; ok && c.Color {
+		return "\x1b[" + code + "m" + mark + "\x1b[0m"
+	}
+	return mark
+}
+
+// minPathWidth is the shortest a path is cut down to: enough to keep an
+// IMG-20240501-WA0001 name recognizable
+const minPathWidth = 24
+
+// Line formats a per-file line "  <mark> <text>", text being format applied
+// to paths followed by args. When the line is wider than Width, the longest
+// paths are shortened in the middle ("WhatsApp/Me…/IMG-20240501-WA0001.jpg")
+// until it fits, keeping their file names readable.
+func (c Console) Line(mark, format string, paths []string, args ...interface{}) string {
+	render := func(paths []string) string {
+		values := make([]interface{}, 0, len(paths)+len(args))
+		for _, p := range paths {
+			values = append(values, p)
+		}
+		return fmt.Sprintf(format, append(values, args...)...)
+	}
+	text := render(paths)
+	if c.Width > 0 {
+		if excess := utf8.RuneCountInString("  "+mark+" "+text) - c.Width; excess > 0 {
+			text = render(shortenPaths(paths, excess))
+		}
+	}
+	return "  " + c.Mark(mark) + " " + text
+}
+
+// shortenPaths cuts excess runes out of paths, one at a time from whichever
+// is longest, never below minPathWidth
+func shortenPaths(paths []string, excess int) []string {
+	keep := make([]int, len(paths))
+	for i, p := range paths {
+		keep[i] = utf8.RuneCountInString(p)
+	}
+	for ; excess > 0; excess-- {
+		longest := -1
+		for i, n := range keep {
+			if n > minPathWidth && (longest < 0 || n > keep[longest]) {
+				longest = i
+			}
+		}
+		if longest < 0 {
+			break
+		}
+		keep[longest]--
+	}
+	short := make([]string, len(paths))
+	for i, p := range paths {
+		short[i] = ellipsize(p, keep[i])
+	}
+	return short
+}
+
+// ellipsize shortens s to n runes by replacing its middle with "…". The
+// file name is kept whole when a few runes of the start still fit;
+// otherwise the end keeps twice as much as the start.
+func ellipsize(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	tail := utf8.RuneCountInString(filepath.Base(s)) + 1 // With its separator
+	head := n - 1 - tail
+	if head < 4 {
+		head = (n - 1) / 3
+		tail = n - 1 - head
+	}
+	return string(runes[:head]) + "…" + string(runes[len(runes)-tail:])
+}
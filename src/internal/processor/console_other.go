@@ -0,0 +1,9 @@
+//go:build !(linux || darwin)
+
+package processor
+
+import "os"
+
+// terminalWidth can't be queried portably on this platform (COLUMNS still
+// applies)
+func terminalWidth(f *os.File) int { return 0 }
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/apercova/wappd/scan"
+)
+
+// Container families, as identified from a file's leading bytes
+const (
+	ContainerJPEG = "jpeg"
+	ContainerPNG  = "png"
+	ContainerGIF  = "gif"
+	ContainerWebP = "webp"
+	ContainerMP4  = "mp4" // ISO base media: MP4, MOV, M4V, 3GP, M4A
+)
+
+// extensionContainers maps file extensions to their container family
+var extensionContainers = map[string]string{
+	".jpg": ContainerJPEG, ".jpeg": ContainerJPEG,
+	".png":  ContainerPNG,
+	".gif":  ContainerGIF,
+	".webp": ContainerWebP,
+	".mp4":  ContainerMP4, ".mov": ContainerMP4, ".m4v": ContainerMP4, ".3gp": ContainerMP4, ".m4a": ContainerMP4,
+}
+
+// containerExtensions is the canonical extension for each container family
+var containerExtensions = map[string]string{
+	ContainerJPEG: ".jpg",
+	ContainerPNG:  ".png",
+	ContainerGIF:  ".gif",
+	ContainerWebP: ".webp",
+	ContainerMP4:  ".mp4",
+}
+
+// imageBrands are ftyp major brands of HEIF/AVIF still images, which share
+// the ISO base media layout but aren't videos
+var imageBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true, "hevc": true,
+	"mif1": true, "msf1": true, "avif": true, "avis": true,
+}
+
+// SniffContainer identifies the container family from a file's first bytes
+// ("" when unknown)
+func SniffContainer(header []byte) string {
+	switch {
+	case len(header) >= 3 && header[0] == 0xFF && header[1] == 0xD8 && header[2] == 0xFF:
+		return ContainerJPEG
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return ContainerPNG
+	case bytes.HasPrefix(header, []byte("GIF87a")) || bytes.HasPrefix(header, []byte("GIF89a")):
+		return ContainerGIF
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return ContainerWebP
+	case len(header) >= 12 && string(header[4:8]) == "ftyp" && imageBrands[string(header[8:12])]:
+		return ""
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		return ContainerMP4
+	}
+	return ""
+}
+
+// DetectContainerMismatch reports the canonical extension of a file whose
+// content doesn't match its extension, e.g. ".mp4" for a WhatsApp "GIF"
+// saved as .gif but stored as an MP4, or ".jpg" for a JPEG without an
+// extension. Returns "" when they agree or either side is unknown; a file
+// with an extension of another format wappd knows (.opus, .pdf...) is
+// taken at its word.
+func DetectContainerMismatch(filePath string) string {
+	return detectContainerMismatch(OSFS, filePath)
+}
+
+// detectContainerMismatch is DetectContainerMismatch over an arbitrary filesystem
+func detectContainerMismatch(fsys FS, filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	expected, ok := extensionContainers[ext]
+	if !ok && (scan.IsMedia(ext) || scan.IsDocument(ext)) {
+		return ""
+	}
+
+	f, err := fsys.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	actual := sniffFile(f)
+	if actual == "" || actual == expected {
+		return ""
+	}
+	return containerExtensions[actual]
+}
+
+// sniffFile identifies the container family of the file r reads from its
+// first bytes
+func sniffFile(r io.Reader) string {
+	header := make([]byte, 12)
+	n, _ := io.ReadFull(r, header)
+	return SniffContainer(header[:n])
+}
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// copyBufferSize is the chunk size files on disk are copied in
+const copyBufferSize = 1 << 20
+
+// sparseBlockSize is the granularity holes are detected at when copying a
+// sparse file; runs of zero blocks are skipped instead of written
+const sparseBlockSize = 4 << 10
+
+// copyProgress returns the progress callback for copying filePath, or nil
+// when no OnCopyProgress hook is set
+func (p *Processor) copyProgress(filePath string) func(copied, total int64) {
+	if p.OnCopyProgress == nil {
+		return nil
+	}
+	return func(copied, total int64) { p.OnCopyProgress(filePath, copied, total) }
+}
+
+// streamFile copies src to dst on disk in copyBufferSize chunks, so a
+// multi-gigabyte video never sits in memory. When src is sparse, its holes
+// are kept as holes in dst. A failed copy removes the partial dst.
+func streamFile(src, dst string, info fs.FileInfo, progress func(copied, total int64)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	w := &copyWriter{f: out, sparse: isSparse(info), total: info.Size(), progress: progress}
+
+	// Hide in's WriterTo so the copy goes through our buffer
+	_, err = io.CopyBuffer(w, struct{ io.Reader }{in}, make([]byte, copyBufferSize))
+	if err == nil && w.sparse {
+		// A trailing hole was only seeked over; extend dst to cover it
+		err = out.Truncate(w.copied)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// copyWriter writes a copy to f, seeking over zero blocks of sparse files
+// and reporting progress after every chunk
+type copyWriter struct {
+	f        *os.File
+	sparse   bool
+	copied   int64
+	total    int64
+	progress func(copied, total int64)
+}
+
+func (w *copyWriter) Write(p []byte) (int, error) {
+	if err := w.write(p); err != nil {
+		return 0, err
+	}
+	w.copied += int64(len(p))
+	if w.progress != nil {
+		w.progress(w.copied, w.total)
+	}
+	return len(p), nil
+}
+
+// write stores p at the current offset, as one write per run of data blocks
+// and one seek per run of zero blocks
+func (w *copyWriter) write(p []byte) error {
+	if !w.sparse {
+		_, err := w.f.Write(p)
+		return err
+	}
+	for len(p) > 0 {
+		zero := isZeroBlock(p[:min(len(p), sparseBlockSize)])
+		n := 0
+		for n < len(p) && isZeroBlock(p[n:min(len(p), n+sparseBlockSize)]) == zero {
+			n = min(len(p), n+sparseBlockSize)
+		}
+		var err error
+		if zero {
+			_, err = w.f.Seek(int64(n), io.SeekCurrent)
+		} else {
+			_, err = w.f.Write(p[:n])
+		}
+		if err != nil {
+			return err
+		}
+		p = p[n:]
+	}
+	return nil
+}
+
+// isZeroBlock reports whether b holds only zero bytes
+func isZeroBlock(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ParseCorrectTolerance parses a SkipCorrect tolerance such as "1s" or "2m".
+// "" returns -1: every file is written.
+func ParseCorrectTolerance(s string) (time.Duration, error) {
+	if s == "" {
+		return -1, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid tolerance %q (expected a duration such as 1s or 2m)", s)
+	}
+	return d, nil
+}
+
+// alreadyCorrect reports whether the date the metadata writer would set is
+// already embedded in filePath, within the SkipCorrect tolerance, so the
+// write can be skipped. Files that would get more than a date (keywords, a
+// comment, a Software tag or a GPS position) are always written.
+func (p *Processor) alreadyCorrect(filePath string, dateTime time.Time, comment string) bool {
+	if p.correctTol < 0 || comment != "" || len(p.config.Tags) > 0 || p.config.SoftwareTag || p.gpsAt(dateTime) != nil {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if actual := detectContainerMismatch(p.fsys, filePath); actual != "" {
+		ext = actual
+	}
+	data, err := p.readMetadata(filePath, ext)
+	if err != nil {
+		return false
+	}
+	dates, _, err := readEmbeddedDates(data, ext)
+	if err != nil {
+		return false
+	}
+	found := map[string]string{}
+	for _, d := range dates {
+		found[d.Source] = strings.TrimRight(d.Value, "\x00 ")
+	}
+
+	// Every date the writer sets must already be there
+	within := func(source, layout string, want time.Time) bool {
+		value, ok := found[source]
+		if !ok || len(value) < len(layout) {
+			return false
+		}
+		got, err := time.ParseInLocation(layout, value[:len(layout)], want.Location())
+		if err != nil {
+			return false
+		}
+		diff := got.Sub(want)
+		return diff <= p.correctTol && -diff <= p.correctTol
+	}
+	wall := dateTime.Truncate(time.Second)
+	switch {
+	case ext == ".jpg" || ext == ".jpeg":
+		if p.config.Timezone != "" && found["EXIF OffsetTimeOriginal"] != strings.TrimRight(FormatOffsetTime(dateTime), "\x00") {
+			return false
+		}
+		if p.config.NormalizeOrientation && jpegOrientation(data) != 1 {
+			return false
+		}
+		return within("EXIF DateTimeOriginal", "2006:01:02 15:04:05", wall)
+	case ext == ".m4a":
+		return within("mvhd creation_time", "2006-01-02T15:04:05Z", wall.UTC()) &&
+			within("udta ©day", "2006-01-02T15:04:05Z", wall.UTC())
+	case isMovieFormat(ext):
+		return within("mvhd creation_time", "2006-01-02T15:04:05Z", wall.UTC())
+	case ext == ".opus":
+		return within("Opus DATE", "2006-01-02T15:04:05", wall)
+	case ext == ".pdf" && p.config.IncludeDocuments:
+		return within("PDF CreationDate", "D:20060102150405", wall)
+	}
+	return false
+}
+
+// isMovieFormat reports whether ext is one of the MP4-family videos the
+// native writer dates
+func isMovieFormat(ext string) bool {
+	return ext == ".mp4" || ext == ".mov" || ext == ".m4v" || ext == ".3gp"
+}
+
+// maxMoovSize caps the moov atoms read into memory. Hours of video keep
+// theirs to a few tens of megabytes; a bigger size is a corrupt header.
+const maxMoovSize = 512 << 20
+
+// readMetadata returns the part of a file its embedded dates are read from.
+// Only the moov atom of an MP4/MOV/M4A on disk is read, and only the
+// segments before the image data of a JPEG, so checking a large file
+// doesn't load its media data.
+func (p *Processor) readMetadata(filePath, ext string) ([]byte, error) {
+	isJPEG := ext == ".jpg" || ext == ".jpeg"
+	if !isOSFS(p.fsys) || !(isMovieFormat(ext) || ext == ".m4a" || isJPEG) {
+		return p.fsys.ReadFile(filePath)
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if isJPEG {
+		if header, ok := readJPEGHeader(f); ok {
+			return header, nil
+		}
+		// Not laid out as expected: leave it to the full parser
+		return p.fsys.ReadFile(filePath)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := info.Size()
+
+	header := make([]byte, 16)
+	for offset := int64(0); ; {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return nil, fmt.Errorf("moov %w", ErrAtomNotFound)
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		switch size {
+		case 0: // Atom extends to the end of the file
+			size = fileSize - offset
+		case 1: // 64-bit size follows the type
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+		// Sizes come from the file: never trust one past its end
+		if size < 8 || size > fileSize-offset {
+			return nil, fmt.Errorf("invalid atom size %d at offset %d", size, offset)
+		}
+		if string(header[4:8]) == "moov" {
+			if size > maxMoovSize {
+				return nil, fmt.Errorf("moov of %d bytes at offset %d is too large", size, offset)
+			}
+			moov := make([]byte, size)
+			if _, err := f.ReadAt(moov, offset); err != nil && err != io.EOF {
+				return nil, err
+			}
+			return moov, nil
+		}
+		offset += size
+	}
+}
+
+// readJPEGHeader reads a JPEG's segments up to the marker starting its
+// image data, which ParseJPEGSegments stops at. ok is false when the file
+// doesn't read as one segment after another.
+func readJPEGHeader(r io.Reader) (header []byte, ok bool) {
+	header = make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil || header[0] != 0xFF || header[1] != markerSOI {
+		return nil, false
+	}
+	marker := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, marker[:2]); err != nil || marker[0] != 0xFF {
+			return nil, false
+		}
+		if isImageDataMarker(marker[1]) {
+			return append(header, marker[:2]...), true
+		}
+		if _, err := io.ReadFull(r, marker[2:4]); err != nil {
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint16(marker[2:4]))
+		if length < 2 {
+			return nil, false
+		}
+		header = append(header, marker...)
+		payload := make([]byte, length-2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, false
+		}
+		header = append(header, payload...)
+	}
+}
@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReferenceExiftool is the reference tool "verify --against" supports
+const ReferenceExiftool = "exiftool"
+
+// VerifyUnchecked is the status of cross-check entries whose format has no
+// date tag the reference tool is asked for (e.g. files only re-timed)
+const VerifyUnchecked VerifyStatus = "unchecked"
+
+// crossCheckBatch is how many files are passed to one exiftool invocation
+const crossCheckBatch = 200
+
+// CrossCheckResult compares the date a manifest says was written to a file
+// with the date the reference tool reads from it
+type CrossCheckResult struct {
+	Entry    ManifestEntry
+	Tag      string // Tag compared, e.g. "EXIF:DateTimeOriginal"
+	Expected string // "2006-01-02T15:04:05"
+	Read     string // Same layout; "" when the tag is missing
+	Status   VerifyStatus
+}
+
+// ValidateReference checks that a "verify --against" tool is supported
+func ValidateReference(tool string) error {
+	if tool != ReferenceExiftool {
+		return fmt.Errorf("unknown reference tool %q (expected exiftool)", tool)
+	}
+	return nil
+}
+
+// CrossCheckManifest reads the dates of every output in manifest with
+// exiftool and compares them with the dates wappd wrote, so that a writer
+// bug is caught by an independent reader
+func CrossCheckManifest(manifest *Manifest) ([]CrossCheckResult, error) {
+	if !ExiftoolAvailable() {
+		return nil, fmt.Errorf("exiftool was not found on PATH")
+	}
+	var paths []string
+	for _, entry := range manifest.Entries {
+		paths = append(paths, entry.OutputFile)
+	}
+
+	read := map[string]map[string]string{}
+	for start := 0; start < len(paths); start += crossCheckBatch {
+		batch := paths[start:min(start+crossCheckBatch, len(paths))]
+		// exiftool exits non-zero when some files can't be read, but still
+		// reports the others
+		out, err := exec.Command("exiftool", ExiftoolReadArgs(batch)...).Output()
+		if err != nil && len(out) == 0 {
+			return nil, fmt.Errorf("exiftool failed: %v", err)
+		}
+		tags, err := ParseExiftoolDates(out)
+		if err != nil {
+			return nil, err
+		}
+		for file, values := range tags {
+			read[file] = values
+		}
+	}
+	return CrossCheckEntries(manifest.Entries, read), nil
+}
+
+// ExiftoolReadArgs builds the exiftool arguments that print, as JSON, the
+// date tags wappd writes in each of paths
+func ExiftoolReadArgs(paths []string) []string {
+	args := []string{
+		"-json", "-G",
+		"-EXIF:DateTimeOriginal", "-QuickTime:CreateDate", "-Vorbis:Date", "-PDF:CreateDate",
+		"--",
+	}
+	return append(args, paths...)
+}
+
+// ParseExiftoolDates parses the output of ExiftoolReadArgs into the tags
+// of each file, keyed by path and then by "Group:Tag"
+func ParseExiftoolDates(out []byte) (map[string]map[string]string, error) {
+	var records []map[string]interface{}
+	if err := json.Unmarshal(out, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse exiftool output: %v", err)
+	}
+	tags := map[string]map[string]string{}
+	for _, record := range records {
+		file, _ := record["SourceFile"].(string)
+		values := map[string]string{}
+		for key, value := range record {
+			if s, ok := value.(string); ok && key != "SourceFile" {
+				values[key] = s
+			}
+		}
+		tags[file] = values
+	}
+	return tags, nil
+}
+
+// CrossCheckEntries compares each manifest entry with the tags read from
+// its output, as returned by ParseExiftoolDates
+func CrossCheckEntries(entries []ManifestEntry, read map[string]map[string]string) []CrossCheckResult {
+	results := make([]CrossCheckResult, 0, len(entries))
+	for _, entry := range entries {
+		result := CrossCheckResult{Entry: entry}
+		tag, utc, optional := referenceDateTag(entry.OutputFile)
+		values, found := read[entry.OutputFile]
+		switch {
+		case !found:
+			result.Status = VerifyMissing
+		case tag == "":
+			result.Status = VerifyUnchecked
+		default:
+			result.Tag = tag
+			result.Expected = expectedDate(entry, utc)
+			result.Read = normalizeExiftoolDate(values[tag])
+			result.Status = VerifyOK
+			if result.Read == "" && optional {
+				result.Status = VerifyUnchecked
+			} else if result.Read != result.Expected {
+				result.Status = VerifyMismatch
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// referenceDateTag returns the exiftool tag holding the date wappd writes
+// to a file, whether it is stored in UTC rather than local time, and whether
+// it is optional: only written by the exiftool backend, so a file processed
+// without exiftool may lack it
+func referenceDateTag(path string) (tag string, utc, optional bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if actual := detectContainerMismatch(OSFS, path); actual != "" {
+		ext = actual
+	}
+	switch {
+	case ext == ".jpg" || ext == ".jpeg":
+		return "EXIF:DateTimeOriginal", false, false
+	case ext == ".png":
+		// exiftool writes PNG dates to the eXIf chunk
+		return "EXIF:DateTimeOriginal", false, true
+	case isMovieFormat(ext) || ext == ".m4a":
+		return "QuickTime:CreateDate", true, false
+	case ext == ".opus":
+		return "Vorbis:Date", false, false
+	case ext == ".pdf":
+		return "PDF:CreateDate", false, false
+	}
+	return "", false, false
+}
+
+// expectedDate returns the date an entry says was written, in UTC when the
+// tag stores UTC. Manifests without DateUTC were written before it was
+// recorded, when dates were always UTC.
+func expectedDate(entry ManifestEntry, utc bool) string {
+	if utc && entry.DateUTC != "" {
+		if t, err := time.Parse(time.RFC3339, entry.DateUTC); err == nil {
+			return t.UTC().Format("2006-01-02T15:04:05")
+		}
+	}
+	return entry.DateWritten
+}
+
+// normalizeExiftoolDate turns the dates exiftool prints ("2006:01:02
+// 15:04:05", with or without a zone, or ISO 8601 for Vorbis comments) into
+// "2006-01-02T15:04:05"; "" if value isn't a date
+func normalizeExiftoolDate(value string) string {
+	if len(value) < 19 {
+		return ""
+	}
+	b := []byte(value[:19])
+	b[4], b[7], b[10] = '-', '-', 'T'
+	if _, err := time.Parse("2006-01-02T15:04:05", string(b)); err != nil {
+		return ""
+	}
+	return string(b)
+}
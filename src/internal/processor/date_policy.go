@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DatePolicy values: which date a file gets when its metadata already holds
+// one that differs from its filename's
+const (
+	DatePolicyEarliest = "earliest" // The earlier of the two
+	DatePolicyFilename = "filename" // Always the filename's (like -ow)
+	DatePolicyExisting = "existing" // The embedded one, whenever there is one
+)
+
+// ValidateDatePolicy checks that a DatePolicy is known ("" keeps the
+// format-specific default: dates the native writer finds are kept unless
+// OverwriteExif is set)
+func ValidateDatePolicy(policy string) error {
+	switch policy {
+	case "", DatePolicyEarliest, DatePolicyFilename, DatePolicyExisting:
+		return nil
+	}
+	return fmt.Errorf("unknown date policy %q (expected earliest, filename or existing)", policy)
+}
+
+// embeddedDateLayouts are the sources applyDatePolicy reads a file's
+// embedded date from, in order of preference, with their value layouts
+var embeddedDateLayouts = []struct{ source, layout string }{
+	{"EXIF DateTimeOriginal", "2006:01:02 15:04:05"},
+	{"mvhd creation_time", time.RFC3339},
+	{"udta ©day", "2006-01-02T15:04:05Z"},
+	{"Opus DATE", "2006-01-02T15:04:05"},
+	{"PDF CreationDate", "D:20060102150405"},
+}
+
+// embeddedDate returns the date already stored in filePath's metadata, if
+// any. Wall-clock values are read in the location of like, the filename's
+// date.
+func (p *Processor) embeddedDate(filePath string, like time.Time) (time.Time, bool) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if actual := detectContainerMismatch(p.fsys, filePath); actual != "" {
+		ext = actual
+	}
+	data, err := p.readMetadata(filePath, ext)
+	if err != nil {
+		return time.Time{}, false
+	}
+	dates, _, err := readEmbeddedDates(data, ext)
+	if err != nil {
+		return time.Time{}, false
+	}
+	found := map[string]string{}
+	for _, d := range dates {
+		if d.Value != mvhdUnset {
+			found[d.Source] = strings.TrimRight(d.Value, "\x00 ")
+		}
+	}
+	for _, l := range embeddedDateLayouts {
+		value, ok := found[l.source]
+		if !ok || len(value) < len(l.layout) {
+			continue
+		}
+		if date, err := time.ParseInLocation(l.layout, value[:len(l.layout)], like.Location()); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// applyDatePolicy returns the date filePath should get under DatePolicy
+// given its filename's date, whether that is the date already embedded, and
+// whether it replaces an embedded date (the writers overwrite only then;
+// metadata without a readable date is left alone as without a policy)
+func (p *Processor) applyDatePolicy(filePath string, date time.Time) (time.Time, bool, bool) {
+	if p.config.DatePolicy == "" {
+		return date, false, false
+	}
+	existing, ok := p.embeddedDate(filePath, date)
+	switch {
+	case !ok:
+		return date, false, false
+	case p.config.DatePolicy == DatePolicyExisting,
+		p.config.DatePolicy == DatePolicyEarliest && existing.Before(date):
+		return existing, true, false
+	}
+	return date, false, true
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DateListing puts the dates of one file side by side, for `wappd dates`
+type DateListing struct {
+	File           string    `json:"file"`
+	FilenameDate   string    `json:"filenameDate,omitempty"`   // From the filename ("" when no pattern matches)
+	EmbeddedDate   string    `json:"embeddedDate,omitempty"`   // Stored in the file ("" when none)
+	EmbeddedSource string    `json:"embeddedSource,omitempty"` // Where EmbeddedDate was found, e.g. "EXIF DateTimeOriginal"
+	Error          string    `json:"error,omitempty"`          // Why embedded dates couldn't be read
+	ModTime        time.Time `json:"modTime"`
+	Mismatch       bool      `json:"mismatch"` // The dates found fall on different days
+}
+
+// mvhdUnset is how an mvhd time of 0 (never set) reads
+var mvhdUnset = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+
+// ListDates reads the filename, embedded and file system dates of a file
+// without modifying it. Embedded dates that can't be read leave their
+// reason in Error; only a file that can't be found is an error.
+func (p *Processor) ListDates(filePath string) (DateListing, error) {
+	l := DateListing{File: filePath}
+
+	info, err := p.fsys.Stat(filePath)
+	if err != nil {
+		return l, fmt.Errorf("failed to get file info: %v", err)
+	}
+	l.ModTime = info.ModTime()
+
+	if _, date, _, err := p.matchFilename(filepath.Base(filePath)); err == nil {
+		l.FilenameDate = date
+	}
+
+	// Only the headers are read, not the media data
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if actual := detectContainerMismatch(p.fsys, filePath); actual != "" {
+		ext = actual
+	}
+	var dates []MetadataDate
+	data, err := p.readMetadata(filePath, ext)
+	if err == nil {
+		dates, _, err = readEmbeddedDates(data, ext)
+	}
+	if err != nil {
+		l.Error = err.Error()
+	}
+	for _, d := range dates {
+		if d.Source == "EXIF OffsetTimeOriginal" || d.Value == mvhdUnset {
+			continue
+		}
+		l.EmbeddedDate, l.EmbeddedSource = d.Value, d.Source
+		break
+	}
+
+	days := map[string]bool{dateDay(l.ModTime.Format(time.RFC3339)): true}
+	for _, date := range []string{l.FilenameDate, l.EmbeddedDate} {
+		if date != "" {
+			days[dateDay(date)] = true
+		}
+	}
+	l.Mismatch = len(days) > 1
+	return l, nil
+}
+
+// dateDay returns the YYYY-MM-DD day a date string starts with, whether it
+// is ISO 8601 or EXIF ("2006:01:02 15:04:05") formatted
+func dateDay(date string) string {
+	if len(date) > 10 {
+		date = date[:10]
+	}
+	return strings.ReplaceAll(date, ":", "-")
+}
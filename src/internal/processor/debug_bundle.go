@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// debugBundleReadme opens every debug bundle, saying what it holds
+const debugBundleReadme = `wappd debug bundle
+
+Made by "wappd bundle-debug" to attach to a bug report. It describes one
+file without including it: no pixel or sample data, only the layout of its
+segments or atoms, the dates stored in it and how wappd would process it.
+
+  environment.txt  wappd version, platform and external tools
+  diagnosis.txt    what "wappd doctor -f <file>" reports
+  structure.json   segments/atoms with offsets, sizes and decoded dates
+                   (as "wappd inspect -json" prints them)
+  config.json      the config file the file would be processed with
+
+Check the contents before sharing: file names, folder names and the
+config are included as they are. Positions and GPS tracks are left out
+of the config and diagnosis.
+`
+
+// redacted replaces the config values a bundle leaves out
+const redacted = "[redacted]"
+
+// DebugBundle is what "wappd bundle-debug" packs for a bug report
+type DebugBundle struct {
+	Created     time.Time
+	Environment string      // wappd version, platform and tools, as doctor prints them
+	Diagnosis   string      // How wappd sees the file, as doctor -f prints it
+	Inspection  *Inspection // Structure of the file (nil when it couldn't be inspected)
+	InspectErr  error       // Why the file couldn't be inspected
+	Config      *ConfigFile // Config file in effect (nil when none)
+}
+
+// WriteZip writes the bundle as a zip archive
+func (b DebugBundle) WriteZip(w io.Writer) error {
+	var inspection interface{} = b.Inspection
+	if b.Inspection == nil {
+		inspection = map[string]string{"error": fmt.Sprint(b.InspectErr)}
+	}
+	structure, err := json.MarshalIndent(inspection, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode structure: %v", err)
+	}
+	config, err := json.MarshalIndent(redactLocation(b.Config), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %v", err)
+	}
+
+	zw := zip.NewWriter(w)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{"README.txt", []byte(debugBundleReadme)},
+		{"environment.txt", []byte(b.Environment)},
+		{"diagnosis.txt", []byte(b.Diagnosis)},
+		{"structure.json", append(structure, '\n')},
+		{"config.json", append(config, '\n')},
+	} {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: b.Created})
+		if err != nil {
+			return fmt.Errorf("failed to write debug bundle: %v", err)
+		}
+		if _, err := f.Write(entry.data); err != nil {
+			return fmt.Errorf("failed to write debug bundle: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write debug bundle: %v", err)
+	}
+	return nil
+}
+
+// redactLocation returns a copy of config without the gps position and gpx
+// track, of its profiles and targets too: they say where someone has been
+func redactLocation(config *ConfigFile) *ConfigFile {
+	if config == nil {
+		return nil
+	}
+	c := *config
+	if c.GPS != "" {
+		c.GPS = redacted
+	}
+	if c.GPX != "" {
+		c.GPX = redacted
+	}
+	if c.Profiles != nil {
+		c.Profiles = make(map[string]ConfigFile, len(config.Profiles))
+		for name, profile := range config.Profiles {
+			c.Profiles[name] = *redactLocation(&profile)
+		}
+	}
+	if c.Targets != nil {
+		c.Targets = make([]Target, len(config.Targets))
+		for i, target := range config.Targets {
+			target.ConfigFile = *redactLocation(&target.ConfigFile)
+			c.Targets[i] = target
+		}
+	}
+	return &c
+}
+
+// WriteDebugBundle writes the bundle as a zip archive at path
+func WriteDebugBundle(path string, b DebugBundle) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create debug bundle: %v", err)
+	}
+	if err := b.WriteZip(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,273 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apercova/wappd/scan"
+)
+
+// MetadataDate is a date already stored in a file and where it was found
+type MetadataDate struct {
+	Source string // e.g. "EXIF DateTimeOriginal" or "mvhd creation_time"
+	Value  string
+}
+
+// Diagnosis describes how wappd sees a single file, for bug reports
+type Diagnosis struct {
+	File         string
+	Size         int64
+	ModTime      time.Time
+	Container    string         // Container sniffed from the content ("" when unknown)
+	ActualExt    string         // Extension matching the content, when the filename's is wrong
+	Pattern      string         // Filename pattern that matched ("" when none)
+	FilenameDate string         // Date extracted from the filename
+	Dates        []MetadataDate // Dates already embedded in the file
+	DatesErr     error          // Why embedded dates couldn't be read
+	Plan         ProcessResult  // Dry-run result of processing the file
+	Actions      []string       // What processing would do, step by step
+	GPS          *GPSPoint      // Position processing would record (nil when none)
+}
+
+// RedactLocation returns d without the position processing would record,
+// for sharing it (see DebugBundle)
+func (d Diagnosis) RedactLocation() Diagnosis {
+	if d.GPS == nil {
+		return d
+	}
+	actions := make([]string, len(d.Actions))
+	for i, action := range d.Actions {
+		actions[i] = strings.ReplaceAll(action, d.GPS.String(), redacted)
+	}
+	d.Actions = actions
+	d.GPS = nil
+	return d
+}
+
+// Diagnose inspects a file without modifying it: its real container, the
+// dates already embedded in it, the filename pattern it matches and what
+// processing it with the current config would do
+func (p *Processor) Diagnose(filePath string) (Diagnosis, error) {
+	d := Diagnosis{File: filePath}
+
+	info, err := p.fsys.Stat(filePath)
+	if err != nil {
+		return d, fmt.Errorf("failed to get file info: %v", err)
+	}
+	d.Size = info.Size()
+	d.ModTime = info.ModTime()
+
+	data, err := p.fsys.ReadFile(filePath)
+	if err != nil {
+		return d, fmt.Errorf("failed to read file: %v", err)
+	}
+	header := data
+	if len(header) > 12 {
+		header = header[:12]
+	}
+	d.Container = SniffContainer(header)
+	d.ActualExt = detectContainerMismatch(p.fsys, filePath)
+	d.Pattern, d.FilenameDate, _, _ = p.matchFilename(filepath.Base(filePath))
+
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if d.ActualExt != "" {
+		ext = d.ActualExt
+	}
+	var protected bool
+	d.Dates, protected, d.DatesErr = readEmbeddedDates(data, ext)
+
+	// Processing in dry-run mode never touches the file
+	dry := *p
+	dry.config.DryRun = true
+	dry.config.Verbose = false
+	dry.logger = nil
+	dry.claims = nil // A diagnosis writes nothing, so it reserves no output
+	d.Plan = dry.processFile(filePath, nil)
+	d.Actions = p.describeActions(&d, ext, protected)
+	return d, nil
+}
+
+// readEmbeddedDates returns the dates stored in data for the given format,
+// and whether existing metadata stops the native writer without -ow
+func readEmbeddedDates(data []byte, ext string) ([]MetadataDate, bool, error) {
+	var dates []MetadataDate
+	switch {
+	case ext == ".jpg" || ext == ".jpeg":
+		segments, err := ParseJPEGSegments(data)
+		if err != nil {
+			return nil, false, err
+		}
+		_, app1 := FindAPP1Segment(segments)
+		if app1 == nil {
+			return nil, false, nil
+		}
+		tags, err := ReadEXIFDates(app1.Payload)
+		if err != nil {
+			return nil, true, err
+		}
+		return exifMetadataDates(tags), true, nil
+
+	case ext == ".png":
+		// Only exiftool writes PNG dates: nothing stops a native write
+		pngDates, err := ReadPNGDates(data)
+		return pngDates, false, err
+
+	case isVideoFormat(ext) || ext == ".m4a":
+		atoms, err := ParseMP4Atoms(data)
+		if err != nil {
+			return nil, false, err
+		}
+		moov := FindAtom(atoms, "moov")
+		if moov == nil {
+			return nil, false, fmt.Errorf("moov %w", ErrAtomNotFound)
+		}
+		if mvhd := FindAtomRecursive(*moov, "mvhd"); mvhd != nil {
+			created, modified, err := ReadMvhdTimes(mvhd.Data)
+			if err != nil {
+				return nil, false, err
+			}
+			dates = append(dates,
+				MetadataDate{"mvhd creation_time", created.Format(time.RFC3339)},
+				MetadataDate{"mvhd modification_time", modified.Format(time.RFC3339)})
+		}
+		day, err := ReadQuickTimeDay(data)
+		if err == nil {
+			dates = append(dates, MetadataDate{"udta ©day", day})
+		}
+		// Only the M4A writer keeps an existing ©day
+		return dates, ext == ".m4a" && err == nil, nil
+
+	case ext == ".opus":
+		pages, err := parseOggPages(data)
+		if err != nil {
+			return nil, false, err
+		}
+		idx, err := findOpusTagsPage(pages)
+		if err != nil {
+			return nil, false, err
+		}
+		tags, err := parseOpusTags(pages[idx].Data)
+		if err != nil {
+			return nil, false, err
+		}
+		date, ok := tags.date()
+		if !ok {
+			return nil, false, nil
+		}
+		return []MetadataDate{{"Opus DATE", date}}, true, nil
+
+	case ext == ".pdf":
+		date, err := ReadPDFCreationDate(data)
+		if err != nil {
+			return nil, false, nil
+		}
+		return []MetadataDate{{"PDF CreationDate", date}}, true, nil
+	}
+	return nil, false, nil
+}
+
+// exifMetadataDates lists the date tags ReadEXIFDates found, in the order
+// doctor and dates show them
+func exifMetadataDates(tags map[string]string) []MetadataDate {
+	var dates []MetadataDate
+	for _, name := range []string{"DateTimeOriginal", "DateTimeDigitized", "DateTime", "OffsetTimeOriginal"} {
+		if value, ok := tags[name]; ok {
+			dates = append(dates, MetadataDate{"EXIF " + name, value})
+		}
+	}
+	return dates
+}
+
+// describeActions explains, step by step, what processing would do with a
+// diagnosed file, noting in d the position it would record
+func (p *Processor) describeActions(d *Diagnosis, ext string, protected bool) []string {
+	plan := d.Plan
+	if plan.Skipped {
+		return []string{fmt.Sprintf("skip the file (%s)", plan.SkipReason)}
+	}
+	if plan.Error != nil {
+		return []string{fmt.Sprintf("fail: %v", plan.Error)}
+	}
+
+	var actions []string
+	if plan.OutputFile == d.File {
+		actions = append(actions, "edit the file in place")
+	} else {
+		actions = append(actions, fmt.Sprintf("write a copy to %s", plan.OutputFile))
+	}
+	if d.ActualExt != "" {
+		if p.config.FixExtensions {
+			actions = append(actions, fmt.Sprintf("give the output the %s extension matching its content", d.ActualExt))
+		} else {
+			actions = append(actions, fmt.Sprintf("keep the wrong extension (content is %s; use --fix-extensions to rename)", d.Container))
+		}
+	}
+
+	date := plan.DateTime.Format(time.RFC3339)
+	sticker := IsSticker(d.File)
+	mtimeOnly := p.config.MtimeOnly || sticker && p.config.Stickers == StickersMtime
+	isDocument := p.config.IncludeDocuments && scan.IsDocument(ext)
+
+	exiftool, err := useExiftool(ext, p.config.Backend)
+	overwrite := p.config.OverwriteExif || plan.ReplacedEmbedded
+	switch {
+	case mtimeOnly:
+	case !p.writerEnabled(formatWriter(ext)):
+		actions = append(actions, fmt.Sprintf("leave the metadata alone (%s)", disabledWriter(ext)))
+	case plan.AlreadyCorrect:
+		actions = append(actions, fmt.Sprintf("leave the metadata alone (it already holds %s)", date))
+	case err != nil:
+		actions = append(actions, fmt.Sprintf("fail: %v", err))
+	case exiftool:
+		actions = append(actions, fmt.Sprintf("write %s into the metadata with exiftool", date))
+	case isMovieFormat(ext) && !overwrite && p.keepsMvhd(d.File):
+		actions = append(actions, "keep the existing mvhd creation time (use -ow to overwrite)")
+	case isVideoFormat(ext):
+		actions = append(actions, fmt.Sprintf("set the mvhd creation time to %s", date))
+	case ext == ".m4a":
+		if protected && !overwrite {
+			actions = append(actions, fmt.Sprintf("set the mvhd creation time to %s and keep the existing ©day (use -ow to overwrite)", date))
+		} else {
+			actions = append(actions, fmt.Sprintf("set the mvhd creation time and ©day to %s", date))
+		}
+	case ext == ".opus", ext == ".jpg" || ext == ".jpeg", ext == ".pdf" && p.config.IncludeDocuments:
+		if protected && !overwrite {
+			actions = append(actions, "keep the existing embedded date (use -ow to overwrite)")
+		} else {
+			actions = append(actions, fmt.Sprintf("write %s into the %s", date, nativeDateField(ext)))
+		}
+	default:
+		actions = append(actions, fmt.Sprintf("leave the content unchanged (no metadata writer for %s)", ext))
+	}
+
+	nativeJPEG := !exiftool && (ext == ".jpg" || ext == ".jpeg")
+	noMetadata := mtimeOnly || !p.writerEnabled(formatWriter(ext))
+	if plan.Sent && !noMetadata && (exiftool || nativeJPEG && (!protected || overwrite)) {
+		actions = append(actions, fmt.Sprintf("record %s in the UserComment", SentComment))
+	}
+	if len(p.config.Tags) > 0 && !noMetadata && (exiftool || nativeJPEG && (!protected || overwrite)) {
+		actions = append(actions, fmt.Sprintf("add the XMP keywords %s", strings.Join(p.config.Tags, ", ")))
+	}
+	if gps := p.gpsAt(plan.DateTime); gps != nil && !noMetadata && (exiftool && !isVideoFormat(ext) && ext != ".m4a" || nativeJPEG && (!protected || overwrite)) {
+		actions = append(actions, fmt.Sprintf("record the GPS position %s unless the file has one", gps))
+		d.GPS = gps
+	}
+
+	if (p.config.UpdateModified || isDocument || mtimeOnly) && p.writerEnabled(WriterMtime) {
+		actions = append(actions, fmt.Sprintf("set the file modification time to %s", date))
+	}
+	return actions
+}
+
+// nativeDateField names the date field the native writer sets for ext
+func nativeDateField(ext string) string {
+	switch ext {
+	case ".opus":
+		return "Opus DATE comment"
+	case ".pdf":
+		return "PDF CreationDate"
+	}
+	return "EXIF DateTimeOriginal"
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DisambiguateTimes spreads files that would get identical dates (forwarded
+// albums, or date-only names that all land on midnight) one second apart:
+// within each group of equal dates, the files are ordered by name and the
+// n-th one is moved n seconds later. dates maps each path to its filename
+// date; the result maps paths to the shift to add (files alone on their
+// date are left out).
+func DisambiguateTimes(dates map[string]string) map[string]time.Duration {
+	groups := make(map[string][]string)
+	for path, date := range dates {
+		groups[date] = append(groups[date], path)
+	}
+
+	shifts := make(map[string]time.Duration)
+	for _, paths := range groups {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Slice(paths, func(i, j int) bool {
+			bi, bj := filepath.Base(paths[i]), filepath.Base(paths[j])
+			if bi != bj {
+				return bi < bj
+			}
+			return paths[i] < paths[j]
+		})
+		for i, path := range paths[1:] {
+			shifts[path] = time.Duration(i+1) * time.Second
+		}
+	}
+	return shifts
+}
+
+// batchDates returns the filename date (or inferred date) of every file of
+// a batch whose date can be determined
+func (p *Processor) batchDates(filePaths []string) map[string]string {
+	dates := make(map[string]string, len(filePaths))
+	for _, path := range filePaths {
+		if date, err := p.FilenameDate(filepath.Base(path)); err == nil {
+			dates[path] = date
+		} else if date, ok := p.inferred[path]; ok {
+			dates[path] = date
+		}
+	}
+	return dates
+}
@@ -0,0 +1,167 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of DuplicateGroup
+const (
+	DuplicateIdentical = "identical" // Byte-identical files
+	DuplicateSimilar   = "similar"   // Same WhatsApp name stem, different bytes
+)
+
+// DuplicateGroup is a set of files that are copies of one another
+type DuplicateGroup struct {
+	Kind  string
+	Key   string   // SHA-256 for identical files, the shared name stem for similar ones
+	Files []string // Sorted
+	Size  int64    // Bytes of each file (identical groups only)
+}
+
+// Redundant returns the bytes the group's extra copies take: all but one
+// file of an identical group, nothing for similar files, which may differ
+// in what matters (an edit, a better quality)
+func (g DuplicateGroup) Redundant() int64 {
+	if g.Kind != DuplicateIdentical {
+		return 0
+	}
+	return g.Size * int64(len(g.Files)-1)
+}
+
+// waStemPatterns match the part of a WhatsApp name identifying one media
+// item, before the counters and suffixes that copies of it get ("(1)",
+// "-edited", " 2"); the first group is the stem, which must not run on into
+// more digits
+var waStemPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^((?:IMG|VID|AUD|PTT|STK|DOC)-\d{8}-WA(?:Business)?\d{4})(?:\D|$)`),
+	regexp.MustCompile(`^(WhatsApp (?:Business )?(?:Image|Video|Audio) \d{4}-\d{2}-\d{2} at \d{1,2}\.\d{2}\.\d{2}(?: [AP]M)?)(?:\D|$)`),
+}
+
+// waStem returns the WhatsApp name stem of a file with its extension, e.g.
+// "IMG-20240501-WA0001.jpg" for "IMG-20240501-WA0001 (1).jpg"; "" when the
+// name isn't a WhatsApp one
+func waStem(path string) string {
+	name := filepath.Base(path)
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, re := range waStemPatterns {
+		if m := re.FindStringSubmatch(name); m != nil {
+			return m[1] + ext
+		}
+	}
+	return ""
+}
+
+// FindDuplicates reports the byte-identical files among paths, and the
+// files that share a WhatsApp name stem (same type, date and sequence
+// number) without being identical, such as re-downloads and edited copies.
+// Nothing is deleted or changed. Identical groups come first, largest
+// redundancy first; files that can't be read are left out.
+func FindDuplicates(paths []string) []DuplicateGroup {
+	// Only files of equal size can be identical, so most are never hashed
+	sizes := make(map[string]int64)
+	bySize := make(map[int64][]string)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if _, seen := sizes[path]; seen {
+			continue
+		}
+		sizes[path] = info.Size()
+		bySize[info.Size()] = append(bySize[info.Size()], path)
+	}
+
+	var groups []DuplicateGroup
+	identical := make(map[string]string) // Path -> hash, for files with a copy
+	for size, candidates := range bySize {
+		if len(candidates) < 2 {
+			continue
+		}
+		byHash := make(map[string][]string)
+		for _, path := range candidates {
+			if hash, err := hashFile(OSFS, path); err == nil {
+				byHash[hash] = append(byHash[hash], path)
+			}
+		}
+		for hash, files := range byHash {
+			if len(files) < 2 {
+				continue
+			}
+			sort.Strings(files)
+			groups = append(groups, DuplicateGroup{Kind: DuplicateIdentical, Key: hash, Files: files, Size: size})
+			for _, f := range files {
+				identical[f] = hash
+			}
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Redundant() != groups[j].Redundant() {
+			return groups[i].Redundant() > groups[j].Redundant()
+		}
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+
+	// Similar files: one stem, more than one distinct content. Identical
+	// copies among them are already reported and count once here.
+	byStem := make(map[string][]string)
+	for path := range sizes {
+		if stem := waStem(path); stem != "" {
+			key := filepath.Join(filepath.Dir(path), stem)
+			byStem[key] = append(byStem[key], path)
+		}
+	}
+	var similar []DuplicateGroup
+	for key, files := range byStem {
+		contents := make(map[string]bool)
+		for _, f := range files {
+			content := identical[f]
+			if content == "" {
+				content = f
+			}
+			contents[content] = true
+		}
+		if len(contents) < 2 {
+			continue
+		}
+		sort.Strings(files)
+		similar = append(similar, DuplicateGroup{Kind: DuplicateSimilar, Key: filepath.Base(key), Files: files})
+	}
+	sort.Slice(similar, func(i, j int) bool { return similar[i].Files[0] < similar[j].Files[0] })
+	return append(groups, similar...)
+}
+
+// DuplicatePaths returns the files results are about as they are now: each
+// output when it exists, otherwise its input
+func DuplicatePaths(results []ProcessResult) []string {
+	paths := make([]string, 0, len(results))
+	for _, r := range results {
+		path := r.InputFile
+		if r.OutputFile != "" {
+			if _, err := os.Stat(r.OutputFile); err == nil {
+				path = r.OutputFile
+			}
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// FormatSize renders a byte count in binary units, e.g. "1.5 MiB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoDuration is returned by VideoDuration for files that aren't
+// MP4-family videos or M4A audio
+var ErrNoDuration = errors.New("not an MP4, MOV, 3GP or M4A file")
+
+// maxMovieDuration is the longest duration MovieDuration accepts; longer
+// ones come from corrupt headers rather than real recordings
+const maxMovieDuration = 7 * 24 * time.Hour
+
+// MovieDuration converts an mvhd timescale and duration to a time.Duration,
+// rejecting values no intact file holds: a zero timescale, an unknown
+// duration or one longer than a week
+func MovieDuration(timescale uint32, duration uint64) (time.Duration, error) {
+	if timescale == 0 {
+		return 0, fmt.Errorf("mvhd timescale is 0")
+	}
+	if duration == ^uint64(0) {
+		return 0, fmt.Errorf("mvhd duration is unknown")
+	}
+	if duration/uint64(timescale) > uint64(maxMovieDuration/time.Second) {
+		return 0, fmt.Errorf("mvhd duration of %d units at %d per second is implausible", duration, timescale)
+	}
+	// Whole seconds and the remainder apart, so fine timescales can't overflow
+	ts := uint64(timescale)
+	return time.Duration(duration/ts)*time.Second + time.Duration(duration%ts)*time.Second/time.Duration(ts), nil
+}
+
+// VideoDuration returns the length of an MP4/MOV/3GP video or M4A file on
+// disk from its mvhd, reading only the moov atom. Fragmented files, whose
+// mvhd leaves the duration to the fragments, have a duration of 0. An error
+// other than ErrNoDuration points at a corrupt or misparsed container.
+func VideoDuration(path string) (time.Duration, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if actual := detectContainerMismatch(OSFS, path); actual != "" {
+		ext = actual
+	}
+	if !isMovieFormat(ext) && ext != ".m4a" {
+		return 0, ErrNoDuration
+	}
+
+	p := &Processor{fsys: OSFS}
+	data, err := p.readMetadata(path, ext)
+	if err != nil {
+		return 0, err
+	}
+	atoms, err := ParseMP4Atoms(data)
+	if err != nil {
+		return 0, err
+	}
+	moov := FindAtom(atoms, "moov")
+	if moov == nil {
+		return 0, fmt.Errorf("moov %w", ErrAtomNotFound)
+	}
+	mvhd := FindAtomRecursive(*moov, "mvhd")
+	if mvhd == nil {
+		return 0, fmt.Errorf("mvhd %w in moov", ErrAtomNotFound)
+	}
+	timescale, duration, err := ReadMvhdDuration(mvhd.Data)
+	if err != nil {
+		return 0, err
+	}
+	if duration == 0 && FindAtomRecursive(*moov, "mvex") != nil {
+		return 0, nil
+	}
+	return MovieDuration(timescale, duration)
+}
+
+// FormatDuration renders a media length as m:ss, or h:mm:ss from an hour
+func FormatDuration(d time.Duration) string {
+	s := int64(d.Round(time.Second) / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,29 @@
+package processor
+
+import "errors"
+
+// Errors returned (wrapped) by the pipeline, for errors.Is checks. Messages
+// wrapping them keep naming the file, atom or format involved.
+var (
+	// ErrNoPatternMatch is returned when no default WhatsApp pattern matches a
+	// filename
+	ErrNoPatternMatch = errors.New("no default pattern matched filename")
+
+	// ErrUnsupportedContainer is returned for files whose container (or a
+	// version of one of its structures) no native writer or reader handles
+	ErrUnsupportedContainer = errors.New("unsupported container")
+
+	// ErrAtomNotFound is returned when an MP4/MOV atom the date lives in, or
+	// leads to it, is missing, e.g. "moov atom not found"
+	ErrAtomNotFound = errors.New("atom not found")
+
+	// ErrExifExists is set in ProcessResult.Kept when a writer left a date
+	// already in the file alone because OverwriteExif is off. It is not a
+	// failure.
+	ErrExifExists = errors.New("date already set (use -ow to overwrite)")
+
+	// ErrSegmentTooLarge is returned when EXIF or XMP data is more than the
+	// 64 KB a JPEG APP1 segment holds. The file is left alone rather than
+	// written with a length field that wraps around.
+	ErrSegmentTooLarge = errors.New("too large for a JPEG segment")
+)
@@ -1,59 +1,179 @@
 package processor
 
 import (
+	"bytes"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/apercova/wappd/version"
 )
 
-// updateExifData updates EXIF data for images and videos
-func updateExifData(filePath string, dateTime time.Time, config Config) error {
+// updateExifData updates EXIF data for images and videos and returns the
+// backend that handled the file ("" when the file type was skipped). A
+// non-empty comment is recorded as UserComment where the writer supports it.
+// A date already in the file that is kept yields an error wrapping
+// ErrExifExists, with nothing written; overwrite replaces it as
+// OverwriteExif does.
+func (p *Processor) updateExifData(filePath string, dateTime time.Time, comment string, overwrite bool) (string, error) {
+	config := p.config
+	config.OverwriteExif = config.OverwriteExif || overwrite
 	ext := strings.ToLower(filepath.Ext(filePath))
 
+	// Route by the actual container when the extension is wrong (e.g. an
+	// MP4 saved as .gif), so it goes through the right writer
+	if actual := detectContainerMismatch(p.fsys, filePath); actual != "" {
+		ext = actual
+	}
+
+	// Leave formats whose writer isn't enabled alone, whatever the backend
+	if !p.writerEnabled(formatWriter(ext)) {
+		if config.Verbose {
+			p.logf("  Skipping metadata update for %s (%s)\n", filepath.Base(filePath), disabledWriter(ext))
+		}
+		return "", nil
+	}
+
+	// Hand off to exiftool when selected (or when auto and there's no native writer)
+	exiftool, err := useExiftool(ext, config.Backend)
+	if err != nil {
+		return "", err
+	}
+	if exiftool {
+		if !isOSFS(p.fsys) {
+			return BackendExiftool, fmt.Errorf("exiftool backend requires the OS filesystem")
+		}
+		if err := updateWithExiftool(filePath, dateTime, config, comment, p.gpsAt(dateTime)); err != nil {
+			return BackendExiftool, err
+		}
+		if config.Verbose {
+			p.logf("  Updated metadata with exiftool for: %s\n", filepath.Base(filePath))
+		}
+		return BackendExiftool, nil
+	}
+
 	// Handle video files (MP4, MOV, M4V, 3GP)
 	if ext == ".mp4" || ext == ".mov" || ext == ".m4v" || ext == ".3gp" {
+		if !config.OverwriteExif && p.keepsMvhd(filePath) {
+			if config.Verbose {
+				p.logf("  Video creation date already set in %s (use -ow to overwrite)\n", filepath.Base(filePath))
+			}
+			return BackendNative, fmt.Errorf("mvhd: %w", ErrExifExists)
+		}
 		if config.DryRun {
 			if config.Verbose {
-				fmt.Printf("  [DRY-RUN] Would update video creation date for: %s\n", filepath.Base(filePath))
+				p.logf("  [DRY-RUN] Would update video creation date for: %s\n", filepath.Base(filePath))
 			}
-			return nil
+			return BackendNative, nil
+		}
+		atoms := p.videoAtoms(ext)
+		err := updateVideoMetadata(p.fsys, filePath, dateTime, p.mappedFrom(), atoms)
+		if err == nil && atoms.day {
+			err = updateQuickTimeDay(p.fsys, filePath, dateTime, config.OverwriteExif)
 		}
-		err := UpdateVideoMetadata(filePath, dateTime)
 		if err != nil {
-			return fmt.Errorf("failed to update video metadata: %v", err)
+			// Fall back to remuxing with ffmpeg when allowed
+			if !config.AllowFFmpeg || !FFmpegAvailable() || !isOSFS(p.fsys) {
+				return BackendNative, fmt.Errorf("failed to update video metadata: %w", err)
+			}
+			if config.Verbose {
+				p.logf("  Native video update failed (%v), remuxing with ffmpeg: %s\n", err, filepath.Base(filePath))
+			}
+			tmpPath := ffmpegTempPath(filePath)
+			p.track(tmpPath)
+			err = remuxWithFFmpeg(filePath, dateTime)
+			p.untrack(tmpPath)
+			if err != nil {
+				return BackendFFmpeg, fmt.Errorf("failed to update video metadata with ffmpeg: %v", err)
+			}
+			return BackendFFmpeg, nil
 		}
 		if config.Verbose {
-			fmt.Printf("  Updated video creation date for: %s\n", filepath.Base(filePath))
+			p.logf("  Updated video creation date for: %s\n", filepath.Base(filePath))
 		}
-		return nil
+		return BackendNative, nil
+	}
+
+	// Handle audio files (WhatsApp voice notes)
+	if ext == ".m4a" || ext == ".opus" {
+		if config.DryRun {
+			if config.Verbose {
+				p.logf("  [DRY-RUN] Would update audio creation date for: %s\n", filepath.Base(filePath))
+			}
+			return BackendNative, nil
+		}
+		if ext == ".m4a" {
+			if err := updateM4AMetadata(p.fsys, filePath, dateTime, config.OverwriteExif, p.videoAtoms(ext)); err != nil {
+				return BackendNative, fmt.Errorf("failed to update audio metadata: %w", err)
+			}
+		} else {
+			updated, err := updateOpusDate(p.fsys, filePath, dateTime, config.OverwriteExif)
+			if err != nil {
+				return BackendNative, fmt.Errorf("failed to update audio metadata: %w", err)
+			}
+			if !updated {
+				if config.Verbose {
+					p.logf("  DATE comment already exists in %s (use -ow to overwrite)\n", filepath.Base(filePath))
+				}
+				return BackendNative, fmt.Errorf("DATE comment: %w", ErrExifExists)
+			}
+		}
+		if config.Verbose {
+			p.logf("  Updated audio creation date for: %s\n", filepath.Base(filePath))
+		}
+		return BackendNative, nil
+	}
+
+	// Handle PDF documents (Info dictionary), only in document mode
+	if ext == ".pdf" && config.IncludeDocuments {
+		if config.DryRun {
+			return BackendNative, nil
+		}
+		updated, err := updatePDFCreationDate(p.fsys, filePath, dateTime, config.OverwriteExif)
+		if err != nil {
+			return BackendNative, fmt.Errorf("failed to update PDF creation date: %w", err)
+		}
+		if !updated {
+			if config.Verbose {
+				p.logf("  PDF CreationDate already exists in %s (use -ow to overwrite)\n", filepath.Base(filePath))
+			}
+			return BackendNative, fmt.Errorf("PDF CreationDate: %w", ErrExifExists)
+		}
+		if config.Verbose {
+			p.logf("  Updated PDF CreationDate for: %s\n", filepath.Base(filePath))
+		}
+		return BackendNative, nil
 	}
 
 	// Handle JPEG files (EXIF)
 	if ext == ".jpg" || ext == ".jpeg" {
-		return updateJPEGExif(filePath, dateTime, config)
+		return BackendNative, p.updateJPEGExif(filePath, dateTime, comment, config.OverwriteExif)
 	}
 
 	// Skip other formats
 	if config.Verbose {
-		fmt.Printf("  Skipping metadata update for unsupported file type: %s\n", filepath.Base(filePath))
+		p.logf("  Skipping metadata update for unsupported file type: %s\n", filepath.Base(filePath))
 	}
-	return nil
+	return "", nil
 }
 
-// updateJPEGExif updates EXIF data for JPEG files
-func updateJPEGExif(filePath string, dateTime time.Time, config Config) error {
+// updateJPEGExif updates EXIF data for JPEG files, replacing existing EXIF
+// data when overwrite is set
+func (p *Processor) updateJPEGExif(filePath string, dateTime time.Time, comment string, overwrite bool) error {
+	config := p.config
+	config.OverwriteExif = overwrite
+
 	// In dry-run mode, skip actual file operations
 	if config.DryRun {
 		if config.Verbose {
-			fmt.Printf("  [DRY-RUN] Would update EXIF DateTimeOriginal for: %s\n", filepath.Base(filePath))
+			p.logf("  [DRY-RUN] Would update EXIF DateTimeOriginal for: %s\n", filepath.Base(filePath))
 		}
 		return nil
 	}
 
 	// Read the JPEG file
-	data, err := os.ReadFile(filePath)
+	data, err := p.fsys.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %v", err)
 	}
@@ -68,43 +188,115 @@ func updateJPEGExif(filePath string, dateTime time.Time, config Config) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse JPEG segments: %v", err)
 	}
-	
+
 	_, existingAPP1 := FindAPP1Segment(segments)
 
 	// If EXIF exists and we're not overwriting, skip
 	if existingAPP1 != nil && !config.OverwriteExif {
 		if config.Verbose {
-			fmt.Printf("  EXIF already exists in %s (use -ow to overwrite)\n", filepath.Base(filePath))
+			p.logf("  EXIF already exists in %s (use -ow to overwrite)\n", filepath.Base(filePath))
 		}
-		return nil
+		return fmt.Errorf("EXIF: %w", ErrExifExists)
+	}
+
+	// The new EXIF replaces the old one: keep its Orientation, or rotate the
+	// pixels to match it and reset it with NormalizeOrientation
+	orientation := 1
+	if existingAPP1 != nil {
+		orientation, _ = ReadEXIFOrientation(existingAPP1.Payload)
+	}
+	source := data
+	if config.NormalizeOrientation && orientation != 1 {
+		source, err = NormalizeJPEGOrientation(data, orientation)
+		if err != nil {
+			return fmt.Errorf("failed to normalize orientation: %v", err)
+		}
+		if config.Verbose {
+			p.logf("  Rotated pixels of %s (EXIF Orientation %d)\n", filepath.Base(filePath), orientation)
+		}
+		orientation = 1
 	}
 
 	// Create EXIF segment
-	exifPayload, err := CreateEXIFSegment(dateTime)
+	opts := EXIFOptions{WithOffset: config.Timezone != "", UserComment: comment, Orientation: uint16(orientation)}
+	if config.SoftwareTag {
+		opts.Software = version.Get().Software()
+	}
+
+	// Ratings given in other tools survive the new EXIF
+	if existingAPP1 != nil {
+		opts.Rating, opts.RatingPercent, _ = ReadEXIFRating(existingAPP1.Payload)
+	}
+
+	// A recorded position survives too, and an approximate one never
+	// replaces it
+	opts.GPS = p.gpsAt(dateTime)
+	if existingAPP1 != nil {
+		if recorded, _ := ReadEXIFGPS(existingAPP1.Payload); recorded != nil {
+			opts.GPS = recorded
+		}
+	}
+	exifPayload, err := CreateEXIFSegmentWithOptions(dateTime, opts)
 	if err != nil {
-		return fmt.Errorf("failed to create EXIF segment: %v", err)
+		return fmt.Errorf("failed to create EXIF segment: %w", err)
 	}
 
 	// Insert EXIF segment into JPEG
-	newJPEG, err := InsertEXIFSegment(data, exifPayload)
+	newJPEG, err := InsertEXIFSegment(source, exifPayload)
 	if err != nil {
-		return fmt.Errorf("failed to insert EXIF segment: %v", err)
+		return fmt.Errorf("failed to insert EXIF segment: %w", err)
+	}
+
+	// Tag the image for photo managers while its metadata is being written
+	if len(config.Tags) > 0 {
+		packet := BuildXMPPacket(config.Tags)
+		_, existingXMP := FindXMPSegment(segments)
+		var mergeErr error
+		if existingXMP != nil && config.OverwriteExif {
+			// Keep what other tools curated (face regions, ratings) and
+			// only add the keywords
+			packet, mergeErr = MergeXMPKeywords(existingXMP.Payload[len(xmpIdentifier):], config.Tags)
+		}
+		switch {
+		case existingXMP != nil && !config.OverwriteExif:
+			if config.Verbose {
+				p.logf("  XMP already exists in %s, keywords not added (use -ow to add them)\n", filepath.Base(filePath))
+			}
+		case mergeErr != nil:
+			if config.Verbose {
+				p.logf("  Keywords not added to %s: %v\n", filepath.Base(filePath), mergeErr)
+			}
+		default:
+			newJPEG, err = InsertXMPSegment(newJPEG, packet)
+			if err != nil {
+				return fmt.Errorf("failed to insert XMP segment: %w", err)
+			}
+		}
+	}
+
+	// Metadata that is already as it would be written is left alone, so
+	// re-runs don't touch the file
+	if bytes.Equal(newJPEG, data) {
+		if config.Verbose {
+			p.logf("  EXIF already up to date in %s\n", filepath.Base(filePath))
+		}
+		return nil
 	}
 
 	// Write the modified JPEG back to file
 	// Preserve original file permissions
-	info, err := os.Stat(filePath)
+	info, err := p.fsys.Stat(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to get file info: %v", err)
 	}
 
-	err = os.WriteFile(filePath, newJPEG, info.Mode())
+	err = p.fsys.WriteFile(filePath, newJPEG, info.Mode())
 	if err != nil {
 		return fmt.Errorf("failed to write file: %v", err)
 	}
 
 	if config.Verbose {
-		fmt.Printf("  Updated EXIF DateTimeOriginal for: %s\n", filepath.Base(filePath))
+		p.logf("  Updated EXIF DateTimeOriginal for: %s\n", filepath.Base(filePath))
 	}
 	return nil
 }
@@ -124,3 +316,11 @@ func isVideoFormat(ext string) bool {
 	}
 	return videoExts[ext]
 }
+
+// isAudioFormat checks if the file is an audio recording
+func isAudioFormat(ext string) bool {
+	audioExts := map[string]bool{
+		".opus": true, ".m4a": true, ".aac": true,
+	}
+	return audioExts[ext]
+}
@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// exifDateTags are the date tags reported by ReadEXIFDates, by name
+var exifDateTags = map[uint16]string{
+	tagDateTime:           "DateTime",
+	tagDateTimeOriginal:   "DateTimeOriginal",
+	tagDateTimeDigitized:  "DateTimeDigitized",
+	tagOffsetTimeOriginal: "OffsetTimeOriginal",
+}
+
+// ReadEXIFDates returns the date tags of an EXIF APP1 payload (with or
+// without the "Exif\0\0" prefix), keyed by tag name. IFD0 and the Exif
+// sub-IFD are searched.
+func ReadEXIFDates(payload []byte) (map[string]string, error) {
+	tiff, order, err := parseTIFFHeader(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make(map[string]string)
+	exifIFD, err := readEXIFIFDDates(tiff, order, order.Uint32(tiff[4:8]), dates)
+	if err != nil {
+		return nil, err
+	}
+	if exifIFD != 0 {
+		if _, err := readEXIFIFDDates(tiff, order, exifIFD, dates); err != nil {
+			return nil, fmt.Errorf("Exif IFD: %v", err)
+		}
+	}
+	return dates, nil
+}
+
+// parseTIFFHeader returns the TIFF data of an EXIF APP1 payload (with or
+// without the "Exif\0\0" prefix) and its byte order
+func parseTIFFHeader(payload []byte) ([]byte, binary.ByteOrder, error) {
+	tiff := bytes.TrimPrefix(payload, []byte("Exif\x00\x00"))
+	if len(tiff) < 8 {
+		return nil, nil, fmt.Errorf("EXIF data too short")
+	}
+	switch string(tiff[0:2]) {
+	case "II":
+		return tiff, binary.LittleEndian, nil
+	case "MM":
+		return tiff, binary.BigEndian, nil
+	}
+	return nil, nil, fmt.Errorf("invalid TIFF byte order")
+}
+
+// readEXIFIFDDates collects the ASCII date tags of the IFD at offset into
+// dates and returns the Exif sub-IFD offset, if the IFD points to one
+func readEXIFIFDDates(tiff []byte, order binary.ByteOrder, offset uint32, dates map[string]string) (uint32, error) {
+	if int(offset)+2 > len(tiff) {
+		return 0, fmt.Errorf("IFD offset %d beyond data", offset)
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(tiff) {
+		return 0, fmt.Errorf("IFD truncated")
+	}
+
+	var exifIFD uint32
+	for i := 0; i < count; i++ {
+		entry := tiff[start+i*12 : start+(i+1)*12]
+		tag := order.Uint16(entry[0:2])
+		if tag == tagExifIFD {
+			exifIFD = order.Uint32(entry[8:12])
+			continue
+		}
+		name, ok := exifDateTags[tag]
+		if !ok || order.Uint16(entry[2:4]) != typeASCII {
+			continue
+		}
+
+		n := int(order.Uint32(entry[4:8]))
+		value := entry[8:12]
+		if n > 4 {
+			at := int(order.Uint32(entry[8:12]))
+			if at+n > len(tiff) {
+				return 0, fmt.Errorf("%s value beyond data", name)
+			}
+			value = tiff[at : at+n]
+		} else {
+			value = value[:n]
+		}
+		dates[name] = string(bytes.TrimRight(value, "\x00"))
+	}
+	return exifIFD, nil
+}
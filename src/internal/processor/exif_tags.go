@@ -7,20 +7,38 @@ import (
 
 const (
 	// Tag IDs
-	tagImageWidth      = 0x0100
-	tagImageLength     = 0x0101
-	tagOrientation     = 0x0112
-	tagExifIFD         = 0x8769
-	tagDateTimeOriginal = 0x9003
-	tagDateTimeDigitized = 0x9004
-	tagDateTime        = 0x0132
+	tagImageWidth         = 0x0100
+	tagImageLength        = 0x0101
+	tagOrientation        = 0x0112
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagDateTimeDigitized  = 0x9004
+	tagDateTime           = 0x0132
+	tagOffsetTimeOriginal = 0x9011
+	tagUserComment        = 0x9286
+	tagSoftware           = 0x0131
+	tagGPSIFD             = 0x8825
+	tagRating             = 0x4746
+	tagRatingPercent      = 0x4749
+
+	// GPS IFD tag IDs
+	tagGPSVersionID    = 0x0000
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+	tagGPSAltitudeRef  = 0x0005
+	tagGPSAltitude     = 0x0006
+	tagGPSTimeStamp    = 0x0007
+	tagGPSDateStamp    = 0x001D
 
 	// Tag Types
-	typeByte   = 1
-	typeASCII  = 2
-	typeShort  = 3
-	typeLong   = 4
-	typeRational = 5
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
 )
 
 // TagEntry represents a 12-byte EXIF tag entry
@@ -51,10 +69,10 @@ func FormatDateTimeOriginal(t time.Time) string {
 // Returns: [entry count (2)] + [entries (12*N)] + [next IFD offset (4)]
 func CreateIFD(entries []TagEntry, nextIFDOffset uint32, byteOrder binary.ByteOrder) []byte {
 	buf := make([]byte, 2+len(entries)*12+4)
-	
+
 	// Entry count
 	byteOrder.PutUint16(buf[0:2], uint16(len(entries)))
-	
+
 	// Tag entries
 	offset := 2
 	for _, entry := range entries {
@@ -62,10 +80,10 @@ func CreateIFD(entries []TagEntry, nextIFDOffset uint32, byteOrder binary.ByteOr
 		copy(buf[offset:offset+12], entryBytes)
 		offset += 12
 	}
-	
+
 	// Next IFD offset
 	byteOrder.PutUint32(buf[offset:offset+4], nextIFDOffset)
-	
+
 	return buf
 }
 
@@ -2,44 +2,192 @@ package processor
 
 import (
 	"encoding/binary"
+	"fmt"
+	"math"
 	"time"
 )
 
+// EXIFOptions selects the optional tags of a created EXIF segment
+type EXIFOptions struct {
+	WithOffset    bool      // Record the UTC offset in OffsetTimeOriginal
+	UserComment   string    // ASCII UserComment ("" = none)
+	Software      string    // Software tag naming the writer ("" = none)
+	Orientation   uint16    // EXIF Orientation, 1-8 (0 = 1, upright)
+	GPS           *GPSPoint // Position for a GPS IFD (nil = none)
+	Rating        uint16    // Rating in stars, 1-5 (0 = none)
+	RatingPercent uint16    // RatingPercent, 1-99 (0 = none)
+}
+
 // CreateEXIFSegment creates a complete EXIF APP1 segment payload
 // Format: "Exif\0\0" + TIFF Header + IFD0 + ExifIFD + data values
 func CreateEXIFSegment(dateTime time.Time) ([]byte, error) {
+	return CreateEXIFSegmentWithOptions(dateTime, EXIFOptions{})
+}
+
+// CreateEXIFSegmentWithOffset creates an EXIF APP1 segment payload that also
+// records the UTC offset of dateTime's location in OffsetTimeOriginal, so the
+// local DateTimeOriginal can be placed on the timeline unambiguously
+func CreateEXIFSegmentWithOffset(dateTime time.Time) ([]byte, error) {
+	return CreateEXIFSegmentWithOptions(dateTime, EXIFOptions{WithOffset: true})
+}
+
+// CreateEXIFSegmentWithComment creates an EXIF APP1 segment payload that
+// also carries an ASCII UserComment, with or without OffsetTimeOriginal
+func CreateEXIFSegmentWithComment(dateTime time.Time, withOffset bool, comment string) ([]byte, error) {
+	return CreateEXIFSegmentWithOptions(dateTime, EXIFOptions{WithOffset: withOffset, UserComment: comment})
+}
+
+// CreateEXIFSegmentWithOptions builds the EXIF payload with the optional
+// tags selected in opts
+func CreateEXIFSegmentWithOptions(dateTime time.Time, opts EXIFOptions) ([]byte, error) {
 	byteOrder := binary.LittleEndian // Use little-endian (most common)
 
 	// Format DateTimeOriginal string
 	dateTimeStr := FormatDateTimeOriginal(dateTime)
 	dateTimeBytes := []byte(dateTimeStr)
+	offsetBytes := []byte(FormatOffsetTime(dateTime))
+	// UserComment starts with an 8-byte character code
+	commentBytes := append([]byte("ASCII\x00\x00\x00"), opts.UserComment...)
+	softwareBytes := append([]byte(opts.Software), 0)
+
+	ifd0EntryCount := 4
+	if opts.Software != "" {
+		ifd0EntryCount++
+	}
+	if opts.Rating != 0 {
+		ifd0EntryCount++
+	}
+	if opts.RatingPercent != 0 {
+		ifd0EntryCount++
+	}
+	gpsEntryCount := 0
+	if opts.GPS != nil {
+		ifd0EntryCount++
+		gpsEntryCount = 5
+		if opts.GPS.Alt != nil {
+			gpsEntryCount += 2
+		}
+		if opts.GPS.Time != nil {
+			gpsEntryCount += 2
+		}
+	}
+	exifEntryCount := 1
+	if opts.WithOffset {
+		exifEntryCount++
+	}
+	if opts.UserComment != "" {
+		exifEntryCount++
+	}
 
 	// Calculate offsets
 	// TIFF header: 8 bytes
 	// IFD0: 2 (count) + entries*12 + 4 (next IFD offset)
 	// ExifIFD: 2 (count) + entries*12 + 4 (next IFD offset)
-	// Data values follow IFDs
-
-	ifd0Offset := 8 // After TIFF header
-	exifIFDOffset := ifd0Offset + 2 + 4*12 + 4 // IFD0: count + 4 entries + next offset
-	dateTimeOffset := exifIFDOffset + 2 + 1*12 + 4 // ExifIFD: count + 1 entry + next offset
+	// GPS IFD (optional): 2 (count) + entries*12 + 4 (next IFD offset)
+	// Data values follow IFDs, in the order they are appended below
+
+	ifd0Offset := 8                                           // After TIFF header
+	exifIFDOffset := ifd0Offset + 2 + ifd0EntryCount*12 + 4   // IFD0: count + entries + next offset
+	gpsIFDOffset := exifIFDOffset + 2 + exifEntryCount*12 + 4 // ExifIFD: count + entries + next offset
+	dataOffset := gpsIFDOffset
+	if opts.GPS != nil {
+		dataOffset += 2 + gpsEntryCount*12 + 4
+	}
+	var data []byte
+	addData := func(value []byte) uint32 {
+		offset := dataOffset + len(data)
+		data = append(data, value...)
+		return uint32(offset)
+	}
 
-	// Create IFD0 entries
+	// Create IFD0 entries (sorted by tag ID)
 	// Entry 1: ImageWidth (placeholder - use 0)
 	// Entry 2: ImageLength (placeholder - use 0)
 	// Entry 3: Orientation (default 1)
-	// Entry 4: ExifIFD pointer
+	// Entry 4: Software (optional)
+	// Entry 5: Rating (optional)
+	// Entry 6: RatingPercent (optional)
+	// Entry 7: ExifIFD pointer
+	// Entry 8: GPS IFD pointer (optional)
+	orientation := uint32(opts.Orientation)
+	if orientation == 0 {
+		orientation = 1
+	}
 	ifd0Entries := []TagEntry{
 		{TagID: tagImageWidth, TagType: typeLong, Count: 1, Value: 0},
 		{TagID: tagImageLength, TagType: typeLong, Count: 1, Value: 0},
-		{TagID: tagOrientation, TagType: typeShort, Count: 1, Value: 1},
-		{TagID: tagExifIFD, TagType: typeLong, Count: 1, Value: uint32(exifIFDOffset)},
+		{TagID: tagOrientation, TagType: typeShort, Count: 1, Value: orientation},
 	}
 
-	// Create ExifIFD entries
+	// Create ExifIFD entries (sorted by tag ID)
 	// Entry 1: DateTimeOriginal
+	// Entry 2: OffsetTimeOriginal (optional)
+	// Entry 3: UserComment (optional)
 	exifIFDEntries := []TagEntry{
-		{TagID: tagDateTimeOriginal, TagType: typeASCII, Count: uint32(len(dateTimeBytes)), Value: uint32(dateTimeOffset)},
+		{TagID: tagDateTimeOriginal, TagType: typeASCII, Count: uint32(len(dateTimeBytes)), Value: addData(dateTimeBytes)},
+	}
+	if opts.WithOffset {
+		exifIFDEntries = append(exifIFDEntries,
+			TagEntry{TagID: tagOffsetTimeOriginal, TagType: typeASCII, Count: uint32(len(offsetBytes)), Value: addData(offsetBytes)})
+	}
+	if opts.UserComment != "" {
+		exifIFDEntries = append(exifIFDEntries,
+			TagEntry{TagID: tagUserComment, TagType: typeUndefined, Count: uint32(len(commentBytes)), Value: addData(commentBytes)})
+	}
+	if opts.Software != "" {
+		ifd0Entries = append(ifd0Entries,
+			TagEntry{TagID: tagSoftware, TagType: typeASCII, Count: uint32(len(softwareBytes)), Value: addData(softwareBytes)})
+	}
+	if opts.Rating != 0 {
+		ifd0Entries = append(ifd0Entries,
+			TagEntry{TagID: tagRating, TagType: typeShort, Count: 1, Value: uint32(opts.Rating)})
+	}
+	if opts.RatingPercent != 0 {
+		ifd0Entries = append(ifd0Entries,
+			TagEntry{TagID: tagRatingPercent, TagType: typeShort, Count: 1, Value: uint32(opts.RatingPercent)})
+	}
+	ifd0Entries = append(ifd0Entries,
+		TagEntry{TagID: tagExifIFD, TagType: typeLong, Count: 1, Value: uint32(exifIFDOffset)})
+
+	// GPS IFD entries: version 2.3, then each coordinate's hemisphere and
+	// degrees/minutes/seconds, and the altitude and fix time when known.
+	// Values of up to 4 bytes are stored in the entry itself, in byte order.
+	var gpsIFD []byte
+	if opts.GPS != nil {
+		latRef, lonRef := uint32('N'), uint32('E')
+		if opts.GPS.Lat < 0 {
+			latRef = 'S'
+		}
+		if opts.GPS.Lon < 0 {
+			lonRef = 'W'
+		}
+		gpsEntries := []TagEntry{
+			{TagID: tagGPSVersionID, TagType: typeByte, Count: 4, Value: byteOrder.Uint32([]byte{2, 3, 0, 0})},
+			{TagID: tagGPSLatitudeRef, TagType: typeASCII, Count: 2, Value: latRef},
+			{TagID: tagGPSLatitude, TagType: typeRational, Count: 3, Value: addData(gpsRationals(opts.GPS.Lat, byteOrder))},
+			{TagID: tagGPSLongitudeRef, TagType: typeASCII, Count: 2, Value: lonRef},
+			{TagID: tagGPSLongitude, TagType: typeRational, Count: 3, Value: addData(gpsRationals(opts.GPS.Lon, byteOrder))},
+		}
+		if alt := opts.GPS.Alt; alt != nil {
+			ref := uint32(0) // Above sea level
+			if *alt < 0 {
+				ref = 1
+			}
+			value := make([]byte, 8)
+			byteOrder.PutUint32(value, uint32(math.Round(math.Abs(*alt)*100)))
+			byteOrder.PutUint32(value[4:], 100)
+			gpsEntries = append(gpsEntries,
+				TagEntry{TagID: tagGPSAltitudeRef, TagType: typeByte, Count: 1, Value: ref},
+				TagEntry{TagID: tagGPSAltitude, TagType: typeRational, Count: 1, Value: addData(value)})
+		}
+		if fix := opts.GPS.Time; fix != nil {
+			gpsEntries = append(gpsEntries,
+				TagEntry{TagID: tagGPSTimeStamp, TagType: typeRational, Count: 3, Value: addData(gpsTimeRationals(*fix, byteOrder))},
+				TagEntry{TagID: tagGPSDateStamp, TagType: typeASCII, Count: 11, Value: addData(append([]byte(fix.UTC().Format("2006:01:02")), 0))})
+		}
+		ifd0Entries = append(ifd0Entries,
+			TagEntry{TagID: tagGPSIFD, TagType: typeLong, Count: 1, Value: uint32(gpsIFDOffset)})
+		gpsIFD = CreateIFD(gpsEntries, 0, byteOrder)
 	}
 
 	// Build IFD0
@@ -66,8 +214,16 @@ func CreateEXIFSegment(dateTime time.Time) ([]byte, error) {
 	// ExifIFD
 	buf = append(buf, exifIFD...)
 
-	// Data values (DateTimeOriginal string)
-	buf = append(buf, dateTimeBytes...)
+	// GPS IFD (empty without a position)
+	buf = append(buf, gpsIFD...)
+
+	// Data values (DateTimeOriginal string, then the optional tags' values)
+	buf = append(buf, data...)
+
+	// Only the UserComment can grow this past what a segment holds
+	if len(buf) > maxSegmentPayload {
+		return nil, fmt.Errorf("EXIF data with a %d-byte UserComment is %d bytes: %w (at most %d)", len(opts.UserComment), len(buf), ErrSegmentTooLarge, maxSegmentPayload)
+	}
 
 	return buf, nil
 }
@@ -109,7 +265,7 @@ func CreateEXIFSegmentWithDefaults(dateTime time.Time, imageWidth, imageLength u
 
 	// Calculate offsets
 	ifd0Offset := 8
-	exifIFDOffset := ifd0Offset + 2 + 4*12 + 4 // IFD0: count + 4 entries + next offset
+	exifIFDOffset := ifd0Offset + 2 + 4*12 + 4     // IFD0: count + 4 entries + next offset
 	dateTimeOffset := exifIFDOffset + 2 + 1*12 + 4 // ExifIFD: count + 1 entry + next offset
 
 	// Create IFD0 entries
@@ -0,0 +1,191 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apercova/wappd/version"
+)
+
+// Metadata writing backends
+const (
+	BackendNative   = "native"   // Pure-Go writers (default)
+	BackendExiftool = "exiftool" // Always shell out to exiftool
+	BackendAuto     = "auto"     // Native where supported, exiftool for everything else
+)
+
+// ValidateBackend checks that the backend name is known ("" means native)
+func ValidateBackend(backend string) error {
+	switch backend {
+	case "", BackendNative, BackendExiftool, BackendAuto:
+		return nil
+	}
+	return fmt.Errorf("unknown backend %q (expected native, exiftool or auto)", backend)
+}
+
+// ExiftoolAvailable checks if exiftool is installed and on PATH
+func ExiftoolAvailable() bool {
+	_, err := exec.LookPath("exiftool")
+	return err == nil
+}
+
+// hasNativeWriter checks if the pure-Go writers can update this extension
+func hasNativeWriter(ext string) bool {
+	switch ext {
+	case ".jpg", ".jpeg", ".mp4", ".mov", ".m4v", ".3gp", ".m4a", ".opus":
+		return true
+	}
+	return false
+}
+
+// useExiftool decides whether a file should be written with exiftool
+func useExiftool(ext, backend string) (bool, error) {
+	switch backend {
+	case BackendExiftool:
+		if !ExiftoolAvailable() {
+			return false, fmt.Errorf("backend exiftool selected but exiftool was not found on PATH")
+		}
+		return true, nil
+	case BackendAuto:
+		return !hasNativeWriter(ext) && ExiftoolAvailable(), nil
+	}
+	return false, nil
+}
+
+// ExiftoolArgs builds the exiftool arguments that write dateTime into filePath.
+// Without overwrite, exiftool only creates date tags that don't exist yet.
+// With withOffset, images also get OffsetTime* tags from dateTime's zone.
+func ExiftoolArgs(filePath string, dateTime time.Time, overwrite, withOffset bool) []string {
+	stamp := dateTime.Format("2006:01:02 15:04:05")
+
+	args := []string{"-overwrite_original", "-P", "-q"}
+	if !overwrite {
+		args = append(args, "-wm", "cg")
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if isVideoFormat(ext) || ext == ".m4a" {
+		// QuickTime dates are stored in UTC, like the native writer
+		utcStamp := dateTime.UTC().Format("2006:01:02 15:04:05") + "+00:00"
+		args = append(args, "-api", "QuickTimeUTC",
+			"-QuickTime:CreateDate="+utcStamp,
+			"-QuickTime:ModifyDate="+utcStamp,
+			"-QuickTime:TrackCreateDate="+utcStamp,
+			"-QuickTime:MediaCreateDate="+utcStamp)
+	} else {
+		args = append(args, "-AllDates="+stamp)
+		if withOffset {
+			offset := dateTime.Format("-07:00")
+			args = append(args, "-OffsetTime="+offset, "-OffsetTimeOriginal="+offset, "-OffsetTimeDigitized="+offset)
+		}
+	}
+	return append(args, filePath)
+}
+
+// ExiftoolCommentArgs builds the exiftool arguments that record comment in
+// filePath: EXIF UserComment for images, XMP UserComment for QuickTime files
+func ExiftoolCommentArgs(filePath, comment string) []string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if isVideoFormat(ext) || ext == ".m4a" {
+		return []string{"-XMP-exif:UserComment=" + comment}
+	}
+	return []string{"-EXIF:UserComment=" + comment}
+}
+
+// ExiftoolKeywordArgs builds the exiftool arguments that add keywords as
+// XMP dc:subject
+func ExiftoolKeywordArgs(keywords []string) []string {
+	var args []string
+	for _, keyword := range keywords {
+		args = append(args, "-XMP-dc:Subject+="+keyword)
+	}
+	return args
+}
+
+// ExiftoolSoftwareArgs builds the exiftool arguments that record software
+// in the EXIF Software tag of images (QuickTime files have no such tag)
+func ExiftoolSoftwareArgs(filePath, software string) []string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if isVideoFormat(ext) || ext == ".m4a" {
+		return nil
+	}
+	return []string{"-EXIF:Software=" + software}
+}
+
+// ExiftoolGPSArgs builds the exiftool arguments that record gps, with its
+// altitude and fix time when known, in the EXIF GPS tags of images;
+// QuickTime files aren't geotagged. They are run with -wm cg, so a position
+// already in the file is never replaced.
+func ExiftoolGPSArgs(filePath string, gps *GPSPoint) []string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if gps == nil || isVideoFormat(ext) || ext == ".m4a" {
+		return nil
+	}
+	latRef, lonRef := "N", "E"
+	if gps.Lat < 0 {
+		latRef = "S"
+	}
+	if gps.Lon < 0 {
+		lonRef = "W"
+	}
+	args := []string{
+		fmt.Sprintf("-GPSLatitude=%.6f", math.Abs(gps.Lat)), "-GPSLatitudeRef=" + latRef,
+		fmt.Sprintf("-GPSLongitude=%.6f", math.Abs(gps.Lon)), "-GPSLongitudeRef=" + lonRef,
+	}
+	if gps.Alt != nil {
+		altRef := "0" // Above sea level
+		if *gps.Alt < 0 {
+			altRef = "1"
+		}
+		args = append(args, fmt.Sprintf("-GPSAltitude=%.2f", math.Abs(*gps.Alt)), "-GPSAltitudeRef#="+altRef)
+	}
+	if gps.Time != nil {
+		fix := gps.Time.UTC()
+		args = append(args, "-GPSDateStamp="+fix.Format("2006:01:02"), "-GPSTimeStamp="+fix.Format("15:04:05.000"))
+	}
+	return args
+}
+
+// updateWithExiftool writes the date, and comment, keywords and a GPS
+// position if set, into a file using exiftool
+func updateWithExiftool(filePath string, dateTime time.Time, config Config, comment string, gps *GPSPoint) error {
+	cmd := exec.Command("exiftool", ExiftoolUpdateArgs(filePath, dateTime, config, comment, gps)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("exiftool failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ExiftoolUpdateArgs builds the full exiftool command line of
+// updateWithExiftool
+func ExiftoolUpdateArgs(filePath string, dateTime time.Time, config Config, comment string, gps *GPSPoint) []string {
+	args := ExiftoolArgs(filePath, dateTime, config.OverwriteExif, config.Timezone != "")
+	var extra []string
+	if comment != "" {
+		extra = append(extra, ExiftoolCommentArgs(filePath, comment)...)
+	}
+	extra = append(extra, ExiftoolKeywordArgs(config.Tags)...)
+	if config.SoftwareTag {
+		extra = append(extra, ExiftoolSoftwareArgs(filePath, version.Get().Software())...)
+	}
+	// Without overwrite -wm cg already keeps existing tags; with it, the
+	// position is written by a second command that keeps them anyway
+	gpsArgs := ExiftoolGPSArgs(filePath, gps)
+	if !config.OverwriteExif {
+		extra, gpsArgs = append(extra, gpsArgs...), nil
+	}
+	if len(extra) > 0 {
+		// Options go before the file name, which ExiftoolArgs puts last
+		args = append(args[:len(args)-1], extra...)
+		args = append(args, filePath)
+	}
+	if len(gpsArgs) > 0 {
+		args = append(args, "-execute", "-overwrite_original", "-P", "-q", "-wm", "cg")
+		args = append(append(args, gpsArgs...), filePath)
+	}
+	return args
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SkipReasonAborted is the SkipReason of files left unprocessed because the
+// batch was aborted after too many failures
+const SkipReasonAborted = "batch aborted after too many failures"
+
+// FailureLimit is how many failures a batch tolerates before it is aborted:
+// either a file count or a percentage of the batch
+type FailureLimit struct {
+	Count   int
+	Percent float64 // Used instead of Count when > 0
+}
+
+// ParseFailureLimit parses a --max-failures value: a file count ("5") or a
+// percentage of the batch ("10%"). An empty string means no limit (nil).
+func ParseFailureLimit(s string) (*FailureLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if strings.HasSuffix(s, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			return nil, fmt.Errorf("invalid max failures %q: percentage must be between 0 and 100", s)
+		}
+		return &FailureLimit{Percent: pct}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid max failures %q: expected a file count or a percentage like 10%%", s)
+	}
+	return &FailureLimit{Count: n}, nil
+}
+
+// Max returns how many failures a batch of total files may have; one more
+// aborts it. A nil limit never aborts (-1).
+func (l *FailureLimit) Max(total int) int {
+	if l == nil {
+		return -1
+	}
+	if l.Percent > 0 {
+		return int(math.Floor(float64(total) * l.Percent / 100))
+	}
+	return l.Count
+}
+
+// String formats the limit as it was given
+func (l *FailureLimit) String() string {
+	if l == nil {
+		return ""
+	}
+	if l.Percent > 0 {
+		return strconv.FormatFloat(l.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.Itoa(l.Count)
+}
@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackendFFmpeg identifies files whose metadata was written by remuxing with ffmpeg
+const BackendFFmpeg = "ffmpeg"
+
+// FFmpegAvailable checks if ffmpeg is installed and on PATH
+func FFmpegAvailable() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+// ffmpegTempPath returns the path ffmpeg remuxes into before replacing the
+// original; it keeps the extension so ffmpeg picks the same container format
+func ffmpegTempPath(filePath string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + ".wappd-remux" + ext
+}
+
+// FFmpegArgs builds the ffmpeg arguments that copy all streams from input to
+// output unchanged while setting the container creation_time
+func FFmpegArgs(input, output string, dateTime time.Time) []string {
+	return []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", input,
+		"-map", "0", "-c", "copy", "-map_metadata", "0",
+		"-metadata", "creation_time=" + dateTime.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		output,
+	}
+}
+
+// remuxWithFFmpeg rewrites a video with ffmpeg to set its creation time,
+// replacing the original only once ffmpeg has succeeded
+func remuxWithFFmpeg(filePath string, dateTime time.Time) error {
+	tmpPath := ffmpegTempPath(filePath)
+
+	cmd := exec.Command("ffmpeg", FFmpegArgs(filePath, tmpPath, dateTime)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, info.Mode()); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	preserveOwner(tmpPath, info)
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace file with remuxed copy: %v", err)
+	}
+	return nil
+}
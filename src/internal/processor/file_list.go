@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFileList reads the paths of a file list, one per line, as written by
+// WriteFileList ("-" reads standard input). Blank lines and lines starting
+// with # are ignored.
+func ReadFileList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file list: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file list: %v", err)
+	}
+	return paths, nil
+}
+
+// FailedPaths returns the input paths of the results that failed (skipped
+// files aren't failures)
+func FailedPaths(results []ProcessResult) []string {
+	var paths []string
+	for _, r := range results {
+		if !r.Success && !r.Skipped {
+			paths = append(paths, r.InputFile)
+		}
+	}
+	return paths
+}
+
+// FilesUnder returns the listed paths that lie in dir or below it, for
+// splitting a file list across the targets it was recorded from
+func FilesUnder(dir string, paths []string) []string {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	var under []string
+	for _, p := range paths {
+		if abs, err := filepath.Abs(p); err == nil && isWithin(root, abs) {
+			under = append(under, p)
+		}
+	}
+	return under
+}
+
+// WriteFileList writes paths one per line, for ReadFileList. An empty list
+// still writes the (empty) file, so scripts can tell nothing failed.
+func WriteFileList(path string, paths []string) error {
+	var b strings.Builder
+	for _, p := range paths {
+		b.WriteString(p)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write file list: %v", err)
+	}
+	return nil
+}
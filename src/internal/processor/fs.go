@@ -0,0 +1,212 @@
+package processor
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FS is the filesystem the processing pipeline reads and writes through.
+// Reads follow io/fs; names are the paths handed to the processor (OS paths
+// for OSFS), not the slash-separated names fs.ValidPath requires.
+type FS interface {
+	fs.StatFS
+	fs.ReadFileFS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Remove(name string) error
+	Rename(oldpath, newpath string) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// OSFS is the FS backed by the operating system (the default)
+var OSFS FS = osFS{}
+
+// osFS implements FS with the os package
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)            { return os.Open(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (osFS) ReadFile(name string) ([]byte, error)         { return os.ReadFile(name) }
+func (osFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (osFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// isOSFS reports whether fsys is the operating system filesystem, which
+// external tools (exiftool, ffmpeg) need to see the files
+func isOSFS(fsys FS) bool {
+	_, ok := fsys.(osFS)
+	return ok
+}
+
+// MemFS is an in-memory FS, for tests and for embedding the pipeline over
+// data that never touches disk
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string]*memEntry
+	clock Clock
+}
+
+// memEntry is a file or directory held by MemFS
+type memEntry struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMemFS creates an empty in-memory filesystem whose modification times
+// come from clock (SystemClock when nil)
+func NewMemFS(clock ...Clock) *MemFS {
+	m := &MemFS{files: make(map[string]*memEntry), clock: SystemClock}
+	if len(clock) > 0 && clock[0] != nil {
+		m.clock = clock[0]
+	}
+	return m
+}
+
+// Files lists the names of all regular files, sorted
+func (m *MemFS) Files() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var names []string
+	for name, e := range m.files {
+		if !e.mode.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *MemFS) lookup(op, name string) (*memEntry, error) {
+	e, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{Reader: bytes.NewReader(e.data), info: memInfo{filepath.Base(name), *e}}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memInfo{filepath.Base(name), *e}, nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, err := m.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(e.data), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if e, ok := m.files[name]; ok && e.mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m.files[name] = &memEntry{data: bytes.Clone(data), mode: perm & fs.ModePerm, modTime: m.clock.Now()}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if e, ok := m.files[dir]; ok && !e.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+		}
+		if _, ok := m.files[dir]; !ok {
+			m.files[dir] = &memEntry{mode: fs.ModeDir | perm&fs.ModePerm, modTime: m.clock.Now()}
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return nil
+		}
+	}
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.lookup("remove", name); err != nil {
+		return err
+	}
+	delete(m.files, filepath.Clean(name))
+	return nil
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.lookup("rename", oldpath)
+	if err != nil {
+		return err
+	}
+	if e.mode.IsDir() {
+		return &fs.PathError{Op: "rename", Path: oldpath, Err: fs.ErrInvalid}
+	}
+	delete(m.files, filepath.Clean(oldpath))
+	m.files[filepath.Clean(newpath)] = e
+	return nil
+}
+
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+	e.modTime = mtime
+	return nil
+}
+
+// memFile is an open MemFS file
+type memFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+// memInfo describes a MemFS entry
+type memInfo struct {
+	name string
+	e    memEntry
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.e.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.e.mode }
+func (i memInfo) ModTime() time.Time { return i.e.modTime }
+func (i memInfo) IsDir() bool        { return i.e.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
@@ -0,0 +1,384 @@
+package processor
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trackMaxGap is how far from the nearest track point a file's date may be
+// for the file to be placed there; files further away get the GPS fallback,
+// if any
+const trackMaxGap = time.Hour
+
+// gpsSecondsScale is the denominator of the seconds written to EXIF GPS
+// coordinates (1/10000 of a second is well under a millimetre)
+const gpsSecondsScale = 10000
+
+// GPSPoint is a position in decimal degrees, north and east positive. A
+// position read back from EXIF also keeps its altitude and fix time.
+type GPSPoint struct {
+	Lat  float64    `json:"lat"`
+	Lon  float64    `json:"lon"`
+	Alt  *float64   `json:"alt,omitempty"`  // Metres above sea level (nil = unknown)
+	Time *time.Time `json:"time,omitempty"` // UTC time of the fix (nil = unknown)
+}
+
+// String formats the point as GPS takes it, e.g. "40.416775,-3.703790"
+func (g GPSPoint) String() string {
+	return fmt.Sprintf("%.6f,%.6f", g.Lat, g.Lon)
+}
+
+// ParseGPS parses a GPS position such as "40.4168,-3.7038"; "" is nil, meaning
+// no position
+func ParseGPS(s string) (*GPSPoint, error) {
+	if s == "" {
+		return nil, nil
+	}
+	lat, lon, ok := strings.Cut(s, ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid GPS position %q (expected lat,lon such as 40.4168,-3.7038)", s)
+	}
+	return newGPSPoint(strings.TrimSpace(lat), strings.TrimSpace(lon))
+}
+
+// newGPSPoint parses and range-checks decimal degrees
+func newGPSPoint(latStr, lonStr string) (*GPSPoint, error) {
+	lat, err1 := strconv.ParseFloat(latStr, 64)
+	lon, err2 := strconv.ParseFloat(lonStr, 64)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("invalid GPS position %s,%s (expected decimal degrees)", latStr, lonStr)
+	}
+	if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return nil, fmt.Errorf("GPS position %s,%s out of range (latitude ±90, longitude ±180)", latStr, lonStr)
+	}
+	return &GPSPoint{Lat: lat, Lon: lon}, nil
+}
+
+// trackPoint is a position of a Track at a time
+type trackPoint struct {
+	time time.Time
+	GPSPoint
+}
+
+// Track is a recorded path, from a GPX or KML file, sorted by time
+type Track struct {
+	points []trackPoint
+}
+
+// LoadTrack reads the timed points of a GPX file (trkpt elements) or a KML
+// file (gx:Track when/coord pairs); "" is nil, meaning no track
+func LoadTrack(path string) (*Track, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open track: %v", err)
+	}
+	defer f.Close()
+
+	var points []trackPoint
+	if strings.EqualFold(filepath.Ext(path), ".kml") {
+		points, err = readKMLTrack(xml.NewDecoder(f))
+	} else {
+		points, err = readGPXTrack(xml.NewDecoder(f))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read track %s: %v", filepath.Base(path), err)
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("track %s has no timed points", filepath.Base(path))
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+	return &Track{points: points}, nil
+}
+
+// readGPXTrack collects the trkpt elements of a GPX file that have a time
+func readGPXTrack(d *xml.Decoder) ([]trackPoint, error) {
+	var points []trackPoint
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return points, nil
+			}
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "trkpt" {
+			continue
+		}
+		var pt struct {
+			Lat  string `xml:"lat,attr"`
+			Lon  string `xml:"lon,attr"`
+			Time string `xml:"time"`
+		}
+		if err := d.DecodeElement(&pt, &start); err != nil {
+			return nil, err
+		}
+		if pt.Time == "" {
+			continue
+		}
+		point, err := newGPSPoint(pt.Lat, pt.Lon)
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(pt.Time))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trkpt time %q", pt.Time)
+		}
+		points = append(points, trackPoint{t, *point})
+	}
+}
+
+// readKMLTrack collects the when/coord pairs of the gx:Track elements of a
+// KML file; a coord is "lon lat [alt]"
+func readKMLTrack(d *xml.Decoder) ([]trackPoint, error) {
+	var points []trackPoint
+	var whens, coords []string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return points, nil
+			}
+			return nil, err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "Track":
+				whens, coords = nil, nil
+			case "when", "coord":
+				var value string
+				if err := d.DecodeElement(&value, &el); err != nil {
+					return nil, err
+				}
+				if el.Name.Local == "when" {
+					whens = append(whens, strings.TrimSpace(value))
+				} else {
+					coords = append(coords, strings.TrimSpace(value))
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local != "Track" {
+				continue
+			}
+			if len(whens) != len(coords) {
+				return nil, fmt.Errorf("gx:Track has %d when and %d coord elements", len(whens), len(coords))
+			}
+			for i, when := range whens {
+				fields := strings.Fields(coords[i])
+				if len(fields) < 2 {
+					return nil, fmt.Errorf("invalid gx:coord %q", coords[i])
+				}
+				point, err := newGPSPoint(fields[1], fields[0])
+				if err != nil {
+					return nil, err
+				}
+				t, err := time.Parse(time.RFC3339, when)
+				if err != nil {
+					return nil, fmt.Errorf("invalid when %q", when)
+				}
+				points = append(points, trackPoint{t, *point})
+			}
+		}
+	}
+}
+
+// At returns the track point nearest to t, or nil when the nearest is more
+// than trackMaxGap away
+func (tr *Track) At(t time.Time) *GPSPoint {
+	i := sort.Search(len(tr.points), func(i int) bool { return !tr.points[i].time.Before(t) })
+	var best *trackPoint
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(tr.points) {
+			continue
+		}
+		if best == nil || absDuration(tr.points[j].time.Sub(t)) < absDuration(best.time.Sub(t)) {
+			best = &tr.points[j]
+		}
+	}
+	if best == nil || absDuration(best.time.Sub(t)) > trackMaxGap {
+		return nil
+	}
+	point := best.GPSPoint
+	return &point
+}
+
+// absDuration returns the magnitude of d
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// gpsAt returns the position to record for a file dated dateTime: from the
+// GPX track when a point is close enough, otherwise the GPS position, or
+// nil without either
+func (p *Processor) gpsAt(dateTime time.Time) *GPSPoint {
+	if p.gpsTrack != nil {
+		if point := p.gpsTrack.At(dateTime); point != nil {
+			return point
+		}
+	}
+	return p.gps
+}
+
+// gpsRationals encodes one coordinate's magnitude as the three EXIF
+// RATIONALs degrees, minutes and seconds
+func gpsRationals(degrees float64, order binary.ByteOrder) []byte {
+	degrees = math.Abs(degrees)
+	d := math.Floor(degrees)
+	m := math.Floor((degrees - d) * 60)
+	s := math.Round(((degrees-d)*60 - m) * 60 * gpsSecondsScale)
+	// Rounding up to a whole minute carries over
+	if s >= 60*gpsSecondsScale {
+		s -= 60 * gpsSecondsScale
+		if m++; m == 60 {
+			m, d = 0, d+1
+		}
+	}
+	buf := make([]byte, 24)
+	for i, v := range [][2]uint32{{uint32(d), 1}, {uint32(m), 1}, {uint32(s), gpsSecondsScale}} {
+		order.PutUint32(buf[i*8:], v[0])
+		order.PutUint32(buf[i*8+4:], v[1])
+	}
+	return buf
+}
+
+// ReadEXIFGPS returns the GPS position of an EXIF APP1 payload, or nil when
+// it has none
+func ReadEXIFGPS(payload []byte) (*GPSPoint, error) {
+	tiff, order, err := parseTIFFHeader(payload)
+	if err != nil {
+		return nil, err
+	}
+	entries := func(offset uint32) ([][]byte, error) {
+		if int(offset)+2 > len(tiff) {
+			return nil, fmt.Errorf("IFD offset %d beyond data", offset)
+		}
+		count := int(order.Uint16(tiff[offset:]))
+		start := int(offset) + 2
+		if start+count*12 > len(tiff) {
+			return nil, fmt.Errorf("IFD truncated")
+		}
+		var list [][]byte
+		for i := 0; i < count; i++ {
+			list = append(list, tiff[start+i*12:start+(i+1)*12])
+		}
+		return list, nil
+	}
+
+	ifd0, err := entries(order.Uint32(tiff[4:8]))
+	if err != nil {
+		return nil, err
+	}
+	var gpsIFD uint32
+	for _, entry := range ifd0 {
+		if order.Uint16(entry[0:2]) == tagGPSIFD {
+			gpsIFD = order.Uint32(entry[8:12])
+		}
+	}
+	if gpsIFD == 0 {
+		return nil, nil
+	}
+	gps, err := entries(gpsIFD)
+	if err != nil {
+		return nil, fmt.Errorf("GPS IFD: %v", err)
+	}
+
+	refs := map[uint16]byte{}
+	coords := map[uint16]float64{}
+	var alt *float64
+	var clock []float64
+	var date string
+	// rationals reads the count RATIONALs an entry points at
+	rationals := func(entry []byte, count int) ([]float64, error) {
+		at := int(order.Uint32(entry[8:12]))
+		if at+count*8 > len(tiff) {
+			return nil, fmt.Errorf("GPS value beyond data")
+		}
+		values := make([]float64, count)
+		for i := range values {
+			num, den := order.Uint32(tiff[at+i*8:]), order.Uint32(tiff[at+i*8+4:])
+			if den != 0 {
+				values[i] = float64(num) / float64(den)
+			}
+		}
+		return values, nil
+	}
+	for _, entry := range gps {
+		tag, kind, count := order.Uint16(entry[0:2]), order.Uint16(entry[2:4]), order.Uint32(entry[4:8])
+		switch {
+		case (tag == tagGPSLatitudeRef || tag == tagGPSLongitudeRef) && kind == typeASCII,
+			tag == tagGPSAltitudeRef && kind == typeByte:
+			refs[tag] = entry[8]
+		case (tag == tagGPSLatitude || tag == tagGPSLongitude) && kind == typeRational && count == 3:
+			dms, err := rationals(entry, 3)
+			if err != nil {
+				return nil, err
+			}
+			coords[tag] = dms[0] + dms[1]/60 + dms[2]/3600
+		case tag == tagGPSAltitude && kind == typeRational && count == 1:
+			value, err := rationals(entry, 1)
+			if err != nil {
+				return nil, err
+			}
+			alt = &value[0]
+		case tag == tagGPSTimeStamp && kind == typeRational && count == 3:
+			var err error
+			if clock, err = rationals(entry, 3); err != nil {
+				return nil, err
+			}
+		case tag == tagGPSDateStamp && kind == typeASCII && count == 11:
+			at := int(order.Uint32(entry[8:12]))
+			if at+10 <= len(tiff) {
+				date = string(tiff[at : at+10])
+			}
+		}
+	}
+	lat, okLat := coords[tagGPSLatitude]
+	lon, okLon := coords[tagGPSLongitude]
+	if !okLat || !okLon {
+		return nil, nil
+	}
+	if refs[tagGPSLatitudeRef] == 'S' {
+		lat = -lat
+	}
+	if refs[tagGPSLongitudeRef] == 'W' {
+		lon = -lon
+	}
+	point := &GPSPoint{Lat: lat, Lon: lon, Alt: alt}
+	if alt != nil && refs[tagGPSAltitudeRef] == 1 {
+		*alt = -*alt
+	}
+	if day, err := time.Parse("2006:01:02", date); err == nil && clock != nil {
+		fix := day.Add(time.Duration(clock[0]*float64(time.Hour) + clock[1]*float64(time.Minute) + clock[2]*float64(time.Second)))
+		point.Time = &fix
+	}
+	return point, nil
+}
+
+// gpsTimeRationals encodes the time of day of t, in UTC, as the three EXIF
+// RATIONALs of GPSTimeStamp: hours, minutes and milliseconds-precise seconds
+func gpsTimeRationals(t time.Time, order binary.ByteOrder) []byte {
+	t = t.UTC()
+	buf := make([]byte, 24)
+	millis := uint32(t.Second()*1000 + t.Nanosecond()/int(time.Millisecond))
+	for i, v := range [][2]uint32{{uint32(t.Hour()), 1}, {uint32(t.Minute()), 1}, {millis, 1000}} {
+		order.PutUint32(buf[i*8:], v[0])
+		order.PutUint32(buf[i*8+4:], v[1])
+	}
+	return buf
+}
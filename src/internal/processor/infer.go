@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// InferDates assigns a date to files whose names no pattern matches when
+// they sit between two matched files of the same sequence in name order
+// (e.g. edited copies kept next to the originals). A sequence is the files
+// of one directory whose names share the part before the first digit
+// ("IMG-", "VID-"). The date is interpolated linearly between the two
+// neighbors by position. Returns the inferred dates, in the ISO form
+// ExtractDateFromFilename uses, by path.
+func InferDates(filePaths []string) map[string]string {
+	return inferDatesWith(filePaths, ExtractDateFromFilename)
+}
+
+// inferDates is InferDates with the filename matcher to tell matched files
+// by, so configured and extra patterns count as matches
+func (p *Processor) inferDates(filePaths []string) map[string]string {
+	return inferDatesWith(filePaths, p.FilenameDate)
+}
+
+// inferDatesWith implements InferDates, dating names with match
+func inferDatesWith(filePaths []string, match func(filename string) (string, error)) map[string]string {
+	sequences := make(map[string][]string)
+	for _, path := range filePaths {
+		key := filepath.Dir(path) + string(filepath.Separator) + sequencePrefix(filepath.Base(path))
+		sequences[key] = append(sequences[key], path)
+	}
+
+	inferred := make(map[string]string)
+	for _, paths := range sequences {
+		sort.Slice(paths, func(i, j int) bool {
+			return filepath.Base(paths[i]) < filepath.Base(paths[j])
+		})
+
+		// Parsed dates of matched files; zero for unmatched ones
+		dates := make([]time.Time, len(paths))
+		for i, path := range paths {
+			if dateStr, err := match(filepath.Base(path)); err == nil {
+				if t, err := parseISODateTime(dateStr); err == nil {
+					dates[i] = t
+				}
+			}
+		}
+
+		prev := -1
+		for i := range paths {
+			if !dates[i].IsZero() {
+				prev = i
+				continue
+			}
+			if prev < 0 {
+				continue
+			}
+			next := i + 1
+			for next < len(paths) && dates[next].IsZero() {
+				next++
+			}
+			if next == len(paths) {
+				break
+			}
+			// Step first: the span times the position can overflow for
+			// neighbors years apart
+			step := dates[next].Sub(dates[prev]) / time.Duration(next-prev)
+			at := dates[prev].Add(step * time.Duration(i-prev)).Truncate(time.Second)
+			inferred[paths[i]] = at.Format("2006-01-02T15:04:05")
+		}
+	}
+	return inferred
+}
+
+// sequencePrefix returns the part of a filename before its first digit,
+// which names of one sequence share
+func sequencePrefix(name string) string {
+	if i := strings.IndexFunc(name, unicode.IsDigit); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
@@ -0,0 +1,6 @@
+//go:build !(linux || darwin)
+
+package processor
+
+// freeInodes can't be queried portably on this platform
+func freeInodes(dir string) int64 { return -1 }
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package processor
+
+import "syscall"
+
+// freeInodes returns how many more files the filesystem holding dir can
+// create, or -1 when it doesn't say (some network filesystems report none)
+func freeInodes(dir string) int64 {
+	var st syscall.Statfs_t
+	if syscall.Statfs(dir, &st) != nil || st.Files == 0 {
+		return -1
+	}
+	return int64(st.Ffree)
+}
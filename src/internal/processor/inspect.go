@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+)
+
+// InspectNode is one JPEG segment or MP4 atom of an inspected file
+type InspectNode struct {
+	Name     string            `json:"name"`
+	Offset   uint64            `json:"offset"`
+	Size     uint64            `json:"size"`
+	Dates    map[string]string `json:"dates,omitempty"` // Decoded date fields
+	Children []InspectNode     `json:"children,omitempty"`
+}
+
+// Inspection is the parsed structure of a JPEG or MP4 file
+type Inspection struct {
+	File      string        `json:"file"`
+	Size      int64         `json:"size"`
+	Container string        `json:"container"`
+	Nodes     []InspectNode `json:"nodes"`
+}
+
+// jpegMarkerNames names the JPEG markers that aren't numbered APPn/SOFn
+var jpegMarkerNames = map[byte]string{
+	0xC4: "DHT",
+	0xCC: "DAC",
+	0xDA: "SOS",
+	0xDB: "DQT",
+	0xDD: "DRI",
+	0xFE: "COM",
+}
+
+// jpegAppIdentifiers labels APPn segments by their payload identifier
+var jpegAppIdentifiers = []struct {
+	prefix string
+	label  string
+}{
+	{"JFIF\x00", "JFIF"},
+	{"Exif\x00\x00", "Exif"},
+	{"http://ns.adobe.com/xap/1.0/\x00", "XMP"},
+	{"ICC_PROFILE\x00", "ICC"},
+}
+
+// Inspect parses a JPEG or MP4 (MOV, M4A, 3GP...) file into its segments or
+// atoms, with offsets, sizes and decoded date fields
+func Inspect(filePath string) (*Inspection, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	inspection, err := InspectData(data)
+	if err != nil {
+		return nil, err
+	}
+	inspection.File = filePath
+	return inspection, nil
+}
+
+// InspectData is Inspect over in-memory file contents
+func InspectData(data []byte) (*Inspection, error) {
+	header := data
+	if len(header) > 12 {
+		header = header[:12]
+	}
+	inspection := &Inspection{Size: int64(len(data)), Container: SniffContainer(header)}
+
+	var err error
+	switch inspection.Container {
+	case ContainerJPEG:
+		inspection.Nodes, err = inspectJPEG(data)
+	case ContainerMP4:
+		var atoms []Atom
+		atoms, err = ParseMP4Atoms(data)
+		inspection.Nodes = inspectAtoms(atoms)
+	default:
+		return nil, fmt.Errorf("%w (only JPEG and MP4 files can be inspected)", ErrUnsupportedContainer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inspection, nil
+}
+
+// inspectJPEG lists the segments before the image data, then the image data
+// (frame header, scans and EOI) as a single node
+func inspectJPEG(data []byte) ([]InspectNode, error) {
+	segments, err := ParseJPEGSegments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []InspectNode{{Name: "SOI", Offset: 0, Size: 2}}
+	end := 2
+	for _, seg := range segments {
+		node := InspectNode{
+			Name:   jpegMarkerName(seg),
+			Offset: uint64(seg.Offset),
+			Size:   uint64(seg.Length) + 2,
+		}
+		if seg.Marker == markerAPP1 && bytes.HasPrefix(seg.Payload, []byte("Exif\x00\x00")) {
+			if dates, err := ReadEXIFDates(seg.Payload); err == nil && len(dates) > 0 {
+				node.Dates = dates
+			}
+		}
+		nodes = append(nodes, node)
+		end = seg.Offset + int(seg.Length) + 2
+	}
+	if end < len(data) {
+		nodes = append(nodes, InspectNode{Name: "image data", Offset: uint64(end), Size: uint64(len(data) - end)})
+	}
+	return nodes, nil
+}
+
+// jpegMarkerName describes a segment marker, e.g. "APP1 (Exif)"
+func jpegMarkerName(seg JPEGSegment) string {
+	switch {
+	case seg.Marker >= 0xE0 && seg.Marker <= 0xEF:
+		name := fmt.Sprintf("APP%d", seg.Marker-0xE0)
+		for _, app := range jpegAppIdentifiers {
+			if bytes.HasPrefix(seg.Payload, []byte(app.prefix)) {
+				return name + " (" + app.label + ")"
+			}
+		}
+		return name
+	case seg.Marker >= 0xC0 && seg.Marker <= 0xCF && jpegMarkerNames[seg.Marker] == "":
+		return fmt.Sprintf("SOF%d", seg.Marker-0xC0)
+	case jpegMarkerNames[seg.Marker] != "":
+		return jpegMarkerNames[seg.Marker]
+	}
+	return fmt.Sprintf("marker 0x%02X", seg.Marker)
+}
+
+// inspectAtoms converts parsed atoms to nodes, decoding the header dates of
+// mvhd, tkhd and mdhd and the ©day text
+func inspectAtoms(atoms []Atom) []InspectNode {
+	nodes := make([]InspectNode, 0, len(atoms))
+	for _, atom := range atoms {
+		node := InspectNode{
+			Name:     atom.Type,
+			Offset:   atom.Offset,
+			Size:     atom.Size,
+			Children: inspectAtoms(atom.Children),
+		}
+		switch atom.Type {
+		case "mvhd", "tkhd", "mdhd":
+			// All three share the version/flags + creation/modification layout
+			if created, modified, err := ReadMvhdTimes(atom.Data); err == nil {
+				node.Dates = map[string]string{
+					"creation_time":     created.Format(time.RFC3339),
+					"modification_time": modified.Format(time.RFC3339),
+				}
+			}
+		case quickTimeDayAtom:
+			if day, err := parseQuickTimeDay(atom.Data); err == nil {
+				node.Dates = map[string]string{"value": day}
+			}
+		}
+		if len(node.Children) == 0 {
+			node.Children = nil
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
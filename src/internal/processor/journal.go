@@ -0,0 +1,313 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalExt is the extension of per-run journal files
+const journalExt = ".journal"
+
+// DefaultJournalStaleAfter is how old a journal must be before its files are
+// treated as leftovers of a crashed run, where neither the journal's lock
+// nor its process can be checked. Running processes rewrite their journal
+// with every file, so only a dead run's journal grows this old.
+const DefaultJournalStaleAfter = time.Hour
+
+// journalPIDPrefix starts the journal line holding the process ID of the
+// run writing it
+const journalPIDPrefix = "pid\t"
+
+// journalEditPrefix starts the journal lines of files edited in place,
+// followed by the file's size before the edit, a tab and its path
+const journalEditPrefix = "edit\t"
+
+// Journal records the temporary files a run is writing (partial output
+// copies, ffmpeg remux files) so that a later run can remove them if this
+// one crashes, and the originals it is editing in place so that a later
+// run can spot the ones the crash left damaged. Each run keeps its own
+// journal file, listing one path per line, and removes it when it finishes
+// cleanly. The run holds a lock on its journal until Close, which is how
+// other runs tell a live run's journal from a crashed one's.
+type Journal struct {
+	mu    sync.Mutex
+	path  string
+	lock  *FileLock // Held while the run lives (nil where locks aren't supported)
+	files map[string]bool
+	edits map[string]int64 // Size of each file edited in place, before the edit
+}
+
+// DefaultJournalDir returns the directory journals are kept in by default:
+// wappd/journal under the user cache directory
+func DefaultJournalDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "wappd", "journal"), nil
+}
+
+// NewJournal creates an empty journal for this run in dir, locked until
+// Close
+func NewJournal(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %v", err)
+	}
+	name := fmt.Sprintf("%d-%d%s", os.Getpid(), time.Now().UnixNano(), journalExt)
+	j := &Journal{path: filepath.Join(dir, name), files: make(map[string]bool), edits: make(map[string]int64)}
+
+	// Locked before anything is written, so no other run can take the new
+	// journal for an abandoned one
+	f, err := os.OpenFile(j.path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write journal: %v", err)
+	}
+	if err := flock(f); err == nil {
+		j.lock = &FileLock{f: f}
+	} else {
+		f.Close()
+		if !errors.Is(err, errLockUnsupported) {
+			os.Remove(j.path)
+			return nil, fmt.Errorf("failed to lock journal: %v", err)
+		}
+	}
+	if err := j.save(); err != nil {
+		j.lock.Unlock()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Path returns the journal file's path
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Add records path as a temporary file that is about to be written
+func (j *Journal) Add(path string) error {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.files[path] = true
+	return j.save()
+}
+
+// Edit records path as an original of size bytes that is about to be
+// edited in place. Unlike temporary files it is never removed; a later
+// recovery scan only checks that it still parses.
+func (j *Journal) Edit(path string, size int64) error {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.edits[path] = size
+	return j.save()
+}
+
+// Done records that path is complete (or already removed) and no longer
+// needs cleaning up or checking
+func (j *Journal) Done(path string) error {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.files, path)
+	delete(j.edits, path)
+	return j.save()
+}
+
+// Close removes the journal file at the end of a run and releases its
+// lock. Files still listed are left for the next run's cleanup.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	defer func() {
+		j.lock.Unlock()
+		j.lock = nil
+	}()
+	if len(j.files) > 0 || len(j.edits) > 0 {
+		return nil
+	}
+	return os.Remove(j.path)
+}
+
+// save rewrites the journal file with the current entries; the caller holds mu
+func (j *Journal) save() error {
+	paths := make([]string, 0, len(j.files))
+	for path := range j.files {
+		paths = append(paths, path)
+	}
+	edits := make([]JournalEdit, 0, len(j.edits))
+	for path, size := range j.edits {
+		edits = append(edits, JournalEdit{Path: path, Size: size})
+	}
+	return writeJournal(j.path, os.Getpid(), paths, edits)
+}
+
+// JournalEdit is a file a run was editing in place
+type JournalEdit struct {
+	Path string
+	Size int64 // Size before the edit
+}
+
+// readJournal parses a journal file into its temporary files and its
+// files edited in place
+func readJournal(path string) (files []string, edits []JournalEdit, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read journal: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, journalPIDPrefix) {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, journalEditPrefix); ok {
+			size, path, ok := strings.Cut(rest, "\t")
+			n, err := strconv.ParseInt(size, 10, 64)
+			if !ok || err != nil {
+				return nil, nil, fmt.Errorf("invalid journal entry %q", line)
+			}
+			edits = append(edits, JournalEdit{Path: path, Size: n})
+			continue
+		}
+		files = append(files, line)
+	}
+	return files, edits, nil
+}
+
+// journalPID returns the process ID recorded in a journal (0 if none)
+func journalPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, journalPIDPrefix); ok {
+			pid, _ := strconv.Atoi(rest)
+			return pid
+		}
+	}
+	return 0
+}
+
+// writeJournal writes a journal file recording pid (0 = none) and listing
+// files and edits, sorted
+func writeJournal(path string, pid int, files []string, edits []JournalEdit) error {
+	sort.Strings(files)
+	sort.Slice(edits, func(a, b int) bool { return edits[a].Path < edits[b].Path })
+	var b strings.Builder
+	if pid > 0 {
+		fmt.Fprintf(&b, "%s%d\n", journalPIDPrefix, pid)
+	}
+	for _, file := range files {
+		b.WriteString(file)
+		b.WriteByte('\n')
+	}
+	for _, e := range edits {
+		fmt.Fprintf(&b, "%s%d\t%s\n", journalEditPrefix, e.Size, e.Path)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	return nil
+}
+
+// CleanupJournals removes the files listed in the journals under dir whose
+// run is gone (see claimJournal), then the journals themselves. Journals of
+// runs still in progress are left alone, however old. Originals the crashed
+// run was editing in place are never removed: a journal keeps listing those
+// that no longer parse, for ScanJournals and RestoreFromBackup, and is
+// removed once none are left. Returns the leftover files that were removed.
+func CleanupJournals(dir string, staleAfter time.Duration, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal directory: %v", err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != journalExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		journalPath := filepath.Join(dir, entry.Name())
+		release, abandoned := claimJournal(journalPath, info.ModTime(), staleAfter, now)
+		if !abandoned {
+			continue
+		}
+		files, edits, err := readJournal(journalPath)
+		if err != nil {
+			release()
+			return removed, err
+		}
+		err = cleanupJournal(journalPath, info.ModTime(), files, edits, &removed)
+		release()
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// cleanupJournal removes the leftover files of an abandoned journal, adding
+// them to removed, then the journal unless it lists damaged originals
+func cleanupJournal(journalPath string, modTime time.Time, files []string, edits []JournalEdit, removed *[]string) error {
+	for _, path := range files {
+		if err := os.Remove(path); err == nil {
+			*removed = append(*removed, path)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove leftover %s: %v", path, err)
+		}
+	}
+	if damaged := damagedEdits(edits); len(damaged) > 0 {
+		if err := writeJournal(journalPath, 0, nil, damaged); err != nil {
+			return err
+		}
+		// Keep the journal stale, so it isn't taken for a live run's where
+		// only its age can tell
+		os.Chtimes(journalPath, modTime, modTime)
+		return nil
+	}
+	if err := os.Remove(journalPath); err != nil {
+		return fmt.Errorf("failed to remove journal: %v", err)
+	}
+	return nil
+}
+
+// claimJournal reports whether the run that wrote the journal at path is
+// gone, so its leftovers can be cleaned up. A run holds a lock on its
+// journal while it lives; where locks aren't supported, the journal is
+// abandoned when its recorded process no longer exists or, if that can't be
+// told either, when it was last written more than staleAfter before now.
+// release drops the lock claimJournal took, keeping other cleanups out
+// meanwhile.
+func claimJournal(path string, modTime time.Time, staleAfter time.Duration, now time.Time) (release func(), abandoned bool) {
+	lock, err := LockFile(path)
+	if err != nil {
+		return func() {}, false // Locked by a live run, or unreadable
+	}
+	if lock != nil {
+		return func() { lock.Unlock() }, true
+	}
+	if alive, known := processAlive(journalPID(path)); known {
+		return func() {}, !alive
+	}
+	return func() {}, now.Sub(modTime) >= staleAfter
+}
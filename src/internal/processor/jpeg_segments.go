@@ -7,18 +7,25 @@ import (
 )
 
 const (
-	markerSOI = 0xD8 // Start of Image
-	markerEOI = 0xD9 // End of Image
+	markerSOI  = 0xD8 // Start of Image
+	markerEOI  = 0xD9 // End of Image
 	markerAPP1 = 0xE1 // APP1 segment (EXIF)
 	markerAPP0 = 0xE0 // APP0 segment (JFIF)
 	markerSOF0 = 0xC0 // Start of Frame (baseline)
 	markerSOF1 = 0xC1 // Start of Frame (extended)
 	markerSOF2 = 0xC2 // Start of Frame (progressive)
 	markerSOF3 = 0xC3 // Start of Frame (lossless)
+	markerDHT  = 0xC4 // Define Huffman Table
+	markerDAC  = 0xCC // Define Arithmetic Coding conditioning
+	markerSOS  = 0xDA // Start of Scan
 )
 
+// maxSegmentPayload is the most a segment holds after its 2-byte length
+const maxSegmentPayload = 0xFFFF - 2
+
 // JPEGSegment represents a JPEG segment
 type JPEGSegment struct {
+	Offset  int    // Position of the segment's 0xFF marker in the parsed data
 	Marker  byte   // Marker type (0xE1 for APP1, etc.)
 	Length  uint16 // Segment length (including length bytes)
 	Payload []byte // Segment data (excluding marker and length)
@@ -26,17 +33,41 @@ type JPEGSegment struct {
 
 // ParseJPEGSegments parses a JPEG file and extracts all segments
 func ParseJPEGSegments(data []byte) ([]JPEGSegment, error) {
+	segments, _, err := scanJPEGSegments(data)
+	return segments, err
+}
+
+// isImageDataMarker reports whether a marker starts the image data, which
+// segment parsing stops at: a SOFn frame header, SOS or EOI. DHT, JPG and
+// DAC share the SOFn range but are ordinary segments.
+func isImageDataMarker(marker byte) bool {
+	switch {
+	case marker == markerEOI || marker == markerSOS:
+		return true
+	case marker >= markerSOF0 && marker <= 0xCF:
+		return marker != markerDHT && marker != 0xC8 && marker != markerDAC
+	}
+	return false
+}
+
+// scanJPEGSegments parses the segments before the image data and returns
+// them with the offset the last one ends at (2, right after SOI, when there
+// are none). Everything from that offset on is the image data: bytes between
+// the last segment and the SOF or SOS marker belong to it, so reassembling
+// the segments with it never drops them.
+func scanJPEGSegments(data []byte) ([]JPEGSegment, int, error) {
 	if len(data) < 2 {
-		return nil, fmt.Errorf("invalid JPEG: file too short")
+		return nil, 0, fmt.Errorf("invalid JPEG: file too short")
 	}
 
 	// Verify SOI marker
 	if data[0] != 0xFF || data[1] != markerSOI {
-		return nil, fmt.Errorf("invalid JPEG: missing SOI marker")
+		return nil, 0, fmt.Errorf("invalid JPEG: missing SOI marker")
 	}
 
 	var segments []JPEGSegment
 	pos := 2 // Start after SOI
+	end := pos
 
 	for pos < len(data) {
 		// Find next marker (0xFF followed by non-0xFF byte)
@@ -50,46 +81,44 @@ func ParseJPEGSegments(data []byte) ([]JPEGSegment, error) {
 
 		marker := data[pos+1]
 
-		// EOI marks end of image
-		if marker == markerEOI {
-			break
-		}
-
-		// SOF markers indicate start of image data - stop parsing segments
-		if marker >= markerSOF0 && marker <= markerSOF3 {
+		// Frame headers, scans and EOI start the image data - stop parsing
+		// segments
+		if isImageDataMarker(marker) {
 			break
 		}
 
 		// Read segment length (2 bytes, big-endian)
 		if pos+3 >= len(data) {
-			return nil, fmt.Errorf("invalid JPEG: incomplete segment length")
+			return nil, end, fmt.Errorf("invalid JPEG: incomplete segment length")
 		}
 
 		length := binary.BigEndian.Uint16(data[pos+2 : pos+4])
 		if length < 2 {
-			return nil, fmt.Errorf("invalid JPEG: invalid segment length")
+			return nil, end, fmt.Errorf("invalid JPEG: invalid segment length")
 		}
 
 		// Extract payload (length includes the 2 length bytes)
 		payloadStart := pos + 4
 		payloadEnd := pos + 2 + int(length)
 		if payloadEnd > len(data) {
-			return nil, fmt.Errorf("invalid JPEG: segment extends beyond file")
+			return nil, end, fmt.Errorf("invalid JPEG: segment extends beyond file")
 		}
 
 		payload := make([]byte, payloadEnd-payloadStart)
 		copy(payload, data[payloadStart:payloadEnd])
 
 		segments = append(segments, JPEGSegment{
+			Offset:  pos,
 			Marker:  marker,
 			Length:  length,
 			Payload: payload,
 		})
 
 		pos = payloadEnd
+		end = pos
 	}
 
-	return segments, nil
+	return segments, end, nil
 }
 
 // FindAPP1Segment finds the EXIF APP1 segment
@@ -116,11 +145,11 @@ func ReassembleJPEG(segments []JPEGSegment, imageData []byte) []byte {
 	for _, seg := range segments {
 		buf.WriteByte(0xFF)
 		buf.WriteByte(seg.Marker)
-		
+
 		lengthBytes := make([]byte, 2)
 		binary.BigEndian.PutUint16(lengthBytes, seg.Length)
 		buf.Write(lengthBytes)
-		
+
 		buf.Write(seg.Payload)
 	}
 
@@ -137,8 +166,8 @@ func ReassembleJPEG(segments []JPEGSegment, imageData []byte) []byte {
 
 // InsertEXIFSegment inserts or replaces EXIF APP1 segment
 func InsertEXIFSegment(data []byte, exifPayload []byte) ([]byte, error) {
-	// Parse segments (this stops at SOF markers)
-	segments, err := ParseJPEGSegments(data)
+	// Parse segments (this stops at the image data)
+	segments, imageStart, err := scanJPEGSegments(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse JPEG: %v", err)
 	}
@@ -146,14 +175,10 @@ func InsertEXIFSegment(data []byte, exifPayload []byte) ([]byte, error) {
 	// Find existing APP1 segment
 	app1Index, _ := FindAPP1Segment(segments)
 
-	// Calculate APP1 segment length (payload + 2 bytes for length field)
-	app1Length := uint16(len(exifPayload) + 2)
-
 	// Create new APP1 segment
-	newAPP1 := JPEGSegment{
-		Marker:  markerAPP1,
-		Length:  app1Length,
-		Payload: exifPayload,
+	newAPP1, err := newAPP1Segment("EXIF data", exifPayload)
+	if err != nil {
+		return nil, err
 	}
 
 	// Replace existing APP1 or insert new one
@@ -168,44 +193,31 @@ func InsertEXIFSegment(data []byte, exifPayload []byte) ([]byte, error) {
 		segments = newSegments
 	}
 
-	// Calculate where segments end in original file
-	segmentsEnd := 2 // Start after SOI
-	for pos := 2; pos < len(data); {
-		// Find marker
-		if pos >= len(data)-1 {
-			break
-		}
-		if data[pos] != 0xFF {
-			pos++
-			continue
-		}
-		
-		marker := data[pos+1]
-		
-		// Stop at SOF markers (start of image data)
-		if marker >= markerSOF0 && marker <= markerSOF3 {
-			segmentsEnd = pos
-			break
-		}
-		
-		// Stop at EOI
-		if marker == markerEOI {
-			segmentsEnd = pos
-			break
-		}
-		
-		// Skip this segment
-		if pos+3 < len(data) {
-			length := binary.BigEndian.Uint16(data[pos+2 : pos+4])
-			pos += 2 + int(length)
-		} else {
-			break
-		}
+	// Extract image data (everything after the last segment)
+	imageData := data[imageStart:]
+
+	// Reassemble JPEG, checking the image data comes through whole right
+	// after the segments
+	out := ReassembleJPEG(segments, imageData)
+	if _, outStart, err := scanJPEGSegments(out); err != nil || !bytes.HasPrefix(out[outStart:], imageData) {
+		return nil, fmt.Errorf("invalid JPEG: image data would not survive reassembly")
 	}
+	return out, nil
+}
 
-	// Extract image data (everything from segmentsEnd to end)
-	imageData := data[segmentsEnd:]
+// newAPP1Segment wraps payload in an APP1 segment, failing with
+// ErrSegmentTooLarge when its length doesn't fit the 16-bit length field.
+// what names the payload in the error.
+func newAPP1Segment(what string, payload []byte) (JPEGSegment, error) {
+	if len(payload) > maxSegmentPayload {
+		return JPEGSegment{}, fmt.Errorf("%s is %d bytes: %w (at most %d)", what, len(payload), ErrSegmentTooLarge, maxSegmentPayload)
+	}
+	return JPEGSegment{Marker: markerAPP1, Length: uint16(len(payload) + 2), Payload: payload}, nil
+}
 
-	// Reassemble JPEG
-	return ReassembleJPEG(segments, imageData), nil
+// jpegImageDataStart returns where the segments before the image data end
+// (see scanJPEGSegments)
+func jpegImageDataStart(data []byte) int {
+	_, end, _ := scanJPEGSegments(data)
+	return end
 }
@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked is returned (wrapped) when another process holds a lock
+var ErrLocked = errors.New("locked by another process")
+
+// errLockUnsupported is returned by flock when the filesystem (e.g. some
+// network mounts) or platform has no advisory locks; callers proceed unlocked
+var errLockUnsupported = errors.New("advisory locks not supported")
+
+// dirLockName is the lock file taken in a directory edited in place
+const dirLockName = ".wappd.lock"
+
+// FileLock is an exclusive advisory lock. It only keeps out other wappd
+// runs (and tools that take the same locks); it can't stop programs that
+// ignore locks.
+type FileLock struct {
+	f      *os.File
+	remove bool // Remove the file on Unlock (directory lock files)
+}
+
+// LockFile takes an exclusive advisory lock on an existing file without
+// waiting. It returns an error wrapping ErrLocked when another process
+// holds the lock, and a nil lock when locks aren't supported there.
+func LockFile(path string) (*FileLock, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s for locking: %v", path, err)
+	}
+	if err := flock(f); err != nil {
+		f.Close()
+		if errors.Is(err, errLockUnsupported) {
+			return nil, nil
+		}
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%s is %w", path, ErrLocked)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %v", path, err)
+	}
+	return &FileLock{f: f}, nil
+}
+
+// LockDir takes the directory-level lock held while files in dir are
+// edited in place, so two runs can't rewrite the same originals. The lock
+// file is removed again by Unlock.
+func LockDir(dir string) (*FileLock, error) {
+	path := filepath.Join(dir, dirLockName)
+	for attempt := 0; attempt < 3; attempt++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lock file: %v", err)
+		}
+		if err := flock(f); err != nil {
+			f.Close()
+			if errors.Is(err, errLockUnsupported) {
+				return nil, nil
+			}
+			if errors.Is(err, ErrLocked) {
+				return nil, fmt.Errorf("%s is %w", dir, ErrLocked)
+			}
+			return nil, fmt.Errorf("failed to lock %s: %v", dir, err)
+		}
+		// The previous holder may have removed the file between our open
+		// and lock; only a lock on the file still at path counts
+		held, errHeld := f.Stat()
+		current, errCurrent := os.Stat(path)
+		if errHeld == nil && errCurrent == nil && os.SameFile(held, current) {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			return &FileLock{f: f, remove: true}, nil
+		}
+		funlock(f)
+		f.Close()
+	}
+	return nil, fmt.Errorf("%s is %w", dir, ErrLocked)
+}
+
+// Unlock releases the lock. It is safe to call on a nil lock.
+func (l *FileLock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	if l.remove {
+		os.Remove(l.f.Name())
+	}
+	err := funlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package processor
+
+import "os"
+
+// flock reports that advisory locks aren't available on this platform, so
+// files are processed unlocked
+func flock(f *os.File) error {
+	return errLockUnsupported
+}
+
+// processAlive can't check processes on this platform
+func processAlive(pid int) (alive, known bool) {
+	return false, false
+}
+
+// funlock is never reached without flock support
+func funlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,246 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// quickTimeDayAtom is the QuickTime user data atom holding the recording date
+const quickTimeDayAtom = "\xa9day"
+
+// UpdateM4AMetadata updates the creation date of an M4A audio file (e.g. a
+// WhatsApp AUD-*.m4a voice note): the mvhd timestamps, like videos, plus a
+// ©day entry in moov/udta. An existing ©day is only replaced when overwrite
+// is true.
+func UpdateM4AMetadata(filePath string, dateTime time.Time, overwrite bool) error {
+	return updateM4AMetadata(OSFS, filePath, dateTime, overwrite, newVideoAtomSet(nil, ".m4a"))
+}
+
+// updateM4AMetadata is UpdateM4AMetadata over an arbitrary filesystem,
+// writing the atoms in atoms
+func updateM4AMetadata(fsys FS, filePath string, dateTime time.Time, overwrite bool, atoms videoAtomSet) error {
+	if err := updateVideoMetadata(fsys, filePath, dateTime, mmapMinSize, atoms); err != nil {
+		return err
+	}
+	if !atoms.day {
+		return nil
+	}
+	return updateQuickTimeDay(fsys, filePath, dateTime, overwrite)
+}
+
+// updateQuickTimeDay sets the moov/udta ©day of an MP4/M4A file to dateTime
+// in UTC. An existing ©day is only replaced when overwrite is true.
+func updateQuickTimeDay(fsys FS, filePath string, dateTime time.Time, overwrite bool) error {
+	data, err := fsys.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+
+	newData, changed, err := setQuickTimeDay(data, dateTime.UTC().Format("2006-01-02T15:04:05Z"), overwrite)
+	if err != nil {
+		return fmt.Errorf("failed to update udta: %v", err)
+	}
+	if !changed {
+		return nil
+	}
+
+	info, err := fsys.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %v", err)
+	}
+	if err := fsys.WriteFile(filePath, newData, info.Mode()); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	return nil
+}
+
+// ReadQuickTimeDay returns the moov/udta ©day value of an MP4/M4A file
+func ReadQuickTimeDay(data []byte) (string, error) {
+	atoms, err := ParseMP4Atoms(data)
+	if err != nil {
+		return "", err
+	}
+	moov := FindAtom(atoms, "moov")
+	if moov == nil {
+		return "", fmt.Errorf("moov %w", ErrAtomNotFound)
+	}
+	udta := FindAtom(moov.Children, "udta")
+	if udta == nil {
+		return "", fmt.Errorf("udta %w", ErrAtomNotFound)
+	}
+	day := FindAtom(udta.Children, quickTimeDayAtom)
+	if day == nil {
+		return "", fmt.Errorf("©day %w", ErrAtomNotFound)
+	}
+	return parseQuickTimeDay(day.Data)
+}
+
+// parseQuickTimeDay returns the text of a ©day atom's data
+func parseQuickTimeDay(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", fmt.Errorf("©day atom too short")
+	}
+	n := int(binary.BigEndian.Uint16(data[0:2]))
+	if 4+n > len(data) {
+		return "", fmt.Errorf("©day atom truncated")
+	}
+	return string(data[4 : 4+n]), nil
+}
+
+// quickTimeDay builds a ©day atom: 16-bit string length, 16-bit language
+// code (0x55C4 = undetermined) and the text
+func quickTimeDay(value string) []byte {
+	buf := make([]byte, 12, 12+len(value))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(12+len(value)))
+	copy(buf[4:8], quickTimeDayAtom)
+	binary.BigEndian.PutUint16(buf[8:10], uint16(len(value)))
+	binary.BigEndian.PutUint16(buf[10:12], 0x55C4)
+	return append(buf, value...)
+}
+
+// setQuickTimeDay rewrites moov so its udta carries a ©day atom with value.
+// Growing moov shifts everything after it, so when the media data follows
+// moov the stco/co64 chunk offsets are moved by the same amount.
+func setQuickTimeDay(data []byte, value string, overwrite bool) ([]byte, bool, error) {
+	moovPos := -1
+	var moovSize uint64
+	var moovHeader int
+	for pos := 0; pos+8 <= len(data); {
+		size, atomType, headerLen, err := readAtomHeader(data, pos)
+		if err != nil {
+			return nil, false, err
+		}
+		if atomType == "moov" {
+			moovPos, moovSize, moovHeader = pos, size, headerLen
+			break
+		}
+		pos += int(size)
+	}
+	if moovPos < 0 {
+		return nil, false, fmt.Errorf("moov %w", ErrAtomNotFound)
+	}
+
+	moovBody := data[moovPos+moovHeader : moovPos+int(moovSize)]
+	var children bytes.Buffer
+	foundUdta := false
+	for pos := 0; pos+8 <= len(moovBody); {
+		size, atomType, _, err := readAtomHeader(moovBody, pos)
+		if err != nil {
+			return nil, false, err
+		}
+		child := moovBody[pos : pos+int(size)]
+		if atomType == "udta" {
+			udta, changed, err := replaceUdtaDay(child, value, overwrite)
+			if err != nil || !changed {
+				return nil, false, err
+			}
+			child = udta
+			foundUdta = true
+		}
+		children.Write(child)
+		pos += int(size)
+	}
+	if !foundUdta {
+		children.Write(wrapAtom("udta", quickTimeDay(value)))
+	}
+
+	newMoov := wrapAtom("moov", children.Bytes())
+	delta := int64(len(newMoov)) - int64(moovSize)
+
+	// Chunk offsets are absolute: only media stored after moov moves
+	if delta != 0 {
+		moovEnd := uint64(moovPos) + moovSize
+		if err := shiftChunkOffsets(newMoov[8:], moovEnd, delta); err != nil {
+			return nil, false, err
+		}
+	}
+
+	out := make([]byte, 0, len(data)+int(delta))
+	out = append(out, data[:moovPos]...)
+	out = append(out, newMoov...)
+	out = append(out, data[moovPos+int(moovSize):]...)
+	return out, true, nil
+}
+
+// replaceUdtaDay returns udta with any ©day replaced by one holding value.
+// changed is false when a ©day exists and overwrite is not set.
+func replaceUdtaDay(udta []byte, value string, overwrite bool) ([]byte, bool, error) {
+	_, _, headerLen, err := readAtomHeader(udta, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	body := udta[headerLen:]
+
+	var children bytes.Buffer
+	for pos := 0; pos+8 <= len(body); {
+		size, atomType, _, err := readAtomHeader(body, pos)
+		if err != nil {
+			return nil, false, err
+		}
+		if atomType == quickTimeDayAtom {
+			if !overwrite {
+				return nil, false, nil
+			}
+		} else {
+			children.Write(body[pos : pos+int(size)])
+		}
+		pos += int(size)
+	}
+	children.Write(quickTimeDay(value))
+	return wrapAtom("udta", children.Bytes()), true, nil
+}
+
+// wrapAtom prefixes body with a 32-bit atom header
+func wrapAtom(atomType string, body []byte) []byte {
+	buf := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(8+len(body)))
+	copy(buf[4:8], atomType)
+	return append(buf, body...)
+}
+
+// shiftChunkOffsets adds delta to every stco/co64 entry in a container body
+// that points at or beyond threshold
+func shiftChunkOffsets(body []byte, threshold uint64, delta int64) error {
+	for pos := 0; pos+8 <= len(body); {
+		size, atomType, headerLen, err := readAtomHeader(body, pos)
+		if err != nil {
+			return err
+		}
+		payload := body[pos+headerLen : pos+int(size)]
+
+		switch {
+		case atomType == "stco" || atomType == "co64":
+			if len(payload) < 8 {
+				return fmt.Errorf("%s atom too short", atomType)
+			}
+			count := int(binary.BigEndian.Uint32(payload[4:8]))
+			width := 4
+			if atomType == "co64" {
+				width = 8
+			}
+			if 8+count*width > len(payload) {
+				return fmt.Errorf("%s atom truncated", atomType)
+			}
+			for i := 0; i < count; i++ {
+				entry := payload[8+i*width : 8+(i+1)*width]
+				if width == 4 {
+					if off := uint64(binary.BigEndian.Uint32(entry)); off >= threshold {
+						binary.BigEndian.PutUint32(entry, uint32(int64(off)+delta))
+					}
+				} else {
+					if off := binary.BigEndian.Uint64(entry); off >= threshold {
+						binary.BigEndian.PutUint64(entry, uint64(int64(off)+delta))
+					}
+				}
+			}
+		case isContainerAtom(atomType) && atomType != "udta":
+			if err := shiftChunkOffsets(payload, threshold, delta); err != nil {
+				return err
+			}
+		}
+		pos += int(size)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestEntry records the hashes of a processed file before and after writing
+type ManifestEntry struct {
+	InputFile   string `json:"inputFile"`
+	OutputFile  string `json:"outputFile"`
+	PreHash     string `json:"preHash"`
+	PostHash    string `json:"postHash"`
+	DateWritten string `json:"dateWritten"`
+	DateUTC     string `json:"dateUTC,omitempty"`  // DateWritten as an instant, RFC 3339 UTC
+	Sent        bool   `json:"sent,omitempty"`     // Recorded with TagSent
+	Inferred    bool   `json:"inferred,omitempty"` // Date inferred from neighboring files
+	Kept        bool   `json:"kept,omitempty"`     // The date already in the file was kept: DateWritten is that one
+
+	// Atoms a video was dated in, when not the default mvhd and tkhd (see
+	// Config.VideoAtoms)
+	VideoAtoms []string `json:"videoAtoms,omitempty"`
+}
+
+// Manifest is the SHA-256 manifest written for a processing run
+type Manifest struct {
+	Generated string          `json:"generated"`
+	Algorithm string          `json:"algorithm"`
+	Relative  bool            `json:"relative,omitempty"` // Entry paths are relative to the manifest's directory
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+// VerifyStatus describes the outcome of verifying a single manifest entry
+type VerifyStatus string
+
+const (
+	VerifyOK       VerifyStatus = "ok"
+	VerifyMismatch VerifyStatus = "mismatch"
+	VerifyMissing  VerifyStatus = "missing"
+)
+
+// VerifyResult holds the result of verifying a single manifest entry
+type VerifyResult struct {
+	Entry      ManifestEntry
+	Status     VerifyStatus
+	ActualHash string
+	Error      error
+}
+
+// HashFile returns the hex-encoded SHA-256 digest of a file
+func HashFile(path string) (string, error) {
+	return hashFile(OSFS, path)
+}
+
+// hashFile is HashFile over an arbitrary filesystem
+func hashFile(fsys FS, path string) (string, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// BuildManifest creates a manifest from successful, non dry-run processing results
+func BuildManifest(results []ProcessResult) Manifest {
+	return BuildManifestAt(results, time.Now())
+}
+
+// BuildManifestAt is BuildManifest with an explicit generation time, e.g.
+// from Processor.Now
+func BuildManifestAt(results []ProcessResult, generated time.Time) Manifest {
+	manifest := Manifest{
+		Generated: generated.UTC().Format(time.RFC3339),
+		Algorithm: "sha256",
+		Entries:   []ManifestEntry{},
+	}
+
+	for _, r := range results {
+		if !r.Success || r.PostHash == "" {
+			continue
+		}
+		// A kept date is what the file holds, not the filename's
+		date, kept := r.DateTime, r.Kept != nil && !r.KeptDate.IsZero()
+		if kept {
+			date = r.KeptDate
+		}
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			InputFile:   r.InputFile,
+			OutputFile:  r.OutputFile,
+			PreHash:     r.PreHash,
+			PostHash:    r.PostHash,
+			DateWritten: date.Format("2006-01-02T15:04:05"),
+			DateUTC:     date.UTC().Format(time.RFC3339),
+			Sent:        r.Sent,
+			Inferred:    r.Inferred,
+			Kept:        kept,
+			VideoAtoms:  r.VideoAtoms,
+		})
+	}
+
+	return manifest
+}
+
+// WriteManifest writes a manifest as indented JSON to the given path,
+// creating its directory if needed. Entry paths are written relative to that
+// directory, so the manifest can be verified from anywhere and moved along
+// with the files it lists
+func WriteManifest(path string, manifest Manifest) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	entries := make([]ManifestEntry, len(manifest.Entries))
+	for i, entry := range manifest.Entries {
+		entry.InputFile = relativeTo(dir, entry.InputFile)
+		entry.OutputFile = relativeTo(dir, entry.OutputFile)
+		entries[i] = entry
+	}
+	manifest.Entries = entries
+	manifest.Relative = true
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
+
+// LoadManifest reads a manifest previously written by WriteManifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+
+	// Older manifests hold paths relative to the directory they were written
+	// from, which is all we can resolve them against
+	if manifest.Relative {
+		dir := filepath.Dir(path)
+		for i := range manifest.Entries {
+			manifest.Entries[i].InputFile = resolveFrom(dir, manifest.Entries[i].InputFile)
+			manifest.Entries[i].OutputFile = resolveFrom(dir, manifest.Entries[i].OutputFile)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// relativeTo returns file relative to the absolute directory dir. Cloud URIs
+// and files on another volume are returned as they are
+func relativeTo(dir, file string) string {
+	if file == "" || IsCloudTarget(file) {
+		return file
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return abs
+	}
+	return filepath.ToSlash(rel)
+}
+
+// resolveFrom undoes relativeTo for a manifest in dir
+func resolveFrom(dir, file string) string {
+	if file == "" || IsCloudTarget(file) || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, filepath.FromSlash(file))
+}
+
+// VerifyManifest re-hashes every output file in the manifest and compares it
+// against the recorded post-processing hash
+func VerifyManifest(manifest *Manifest) []VerifyResult {
+	results := make([]VerifyResult, 0, len(manifest.Entries))
+
+	for _, entry := range manifest.Entries {
+		result := VerifyResult{Entry: entry}
+
+		hash, err := HashFile(entry.OutputFile)
+		if err != nil {
+			result.Status = VerifyMissing
+			result.Error = err
+		} else if hash != entry.PostHash {
+			result.Status = VerifyMismatch
+			result.ActualHash = hash
+		} else {
+			result.Status = VerifyOK
+			result.ActualHash = hash
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
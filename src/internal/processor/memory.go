@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// bufferCopies is how many copies of a file a buffered metadata write holds
+// at once: the bytes kept for write validation, the bytes the writer reads
+// and the rewritten bytes
+const bufferCopies = 3
+
+// minMemoryLimit is the smallest MemoryLimit accepted
+const minMemoryLimit = 1 << 20
+
+// ParseMemoryLimit parses a MemoryLimit such as "256MiB", "512M" or "1G"
+// (all binary units) into bytes; "" is 0, meaning no limit
+func ParseMemoryLimit(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	number := strings.TrimRight(s, "KMGiBkmgib")
+	unit := strings.ToUpper(s[len(number):])
+	if unit = strings.TrimSuffix(unit, "IB"); len(unit) == len(s)-len(number) {
+		unit = strings.TrimSuffix(unit, "B")
+	}
+	shift := map[string]uint{"": 0, "K": 10, "M": 20, "G": 30}
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	bits, ok := shift[unit]
+	if err != nil || !ok || n <= 0 || n > (1<<62)>>bits {
+		return 0, fmt.Errorf("invalid memory limit %q (expected a size such as 256MiB or 1G)", s)
+	}
+	if n<<bits < minMemoryLimit {
+		return 0, fmt.Errorf("memory limit %q is too small (at least 1MiB)", s)
+	}
+	return n << bits, nil
+}
+
+// mappedFrom returns the size from which videos are patched through a
+// memory mapping: mmapMinSize, or less when buffering a video would take
+// more than the memory limit allows
+func (p *Processor) mappedFrom() int64 {
+	if p.memoryLimit > 0 {
+		return min(mmapMinSize, p.memoryLimit/bufferCopies)
+	}
+	return mmapMinSize
+}
+
+// memoryCost estimates how much memory processing filePath takes: nothing
+// to speak of when it is patched through a memory mapping (copies are
+// streamed), otherwise bufferCopies times its size
+func (p *Processor) memoryCost(filePath string) int64 {
+	if p.patchesMapped(filePath) {
+		return 0
+	}
+	info, err := p.fsys.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size() * bufferCopies
+}
+
+// memBudget hands out bytes of a memory limit to the files being processed
+// at once, so a batch only runs as many files concurrently as fit in it
+type memBudget struct {
+	mu    sync.Mutex
+	freed *sync.Cond
+	limit int64
+	used  int64
+}
+
+// newMemBudget creates a memBudget of limit bytes
+func newMemBudget(limit int64) *memBudget {
+	b := &memBudget{limit: limit}
+	b.freed = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire waits until n bytes are free and takes them, returning how many
+// were taken. A file larger than the whole limit waits for every other file
+// to finish and then runs alone.
+func (b *memBudget) acquire(n int64) int64 {
+	n = min(n, b.limit)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used > 0 && b.used+n > b.limit {
+		b.freed.Wait()
+	}
+	b.used += n
+	return n
+}
+
+// release returns n bytes taken with acquire
+func (b *memBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.freed.Broadcast()
+}
@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stage names a step of processing a file that custom stages run after
+type Stage string
+
+const (
+	StageExtract   Stage = "extract"   // The date was read from the filename and adjusted
+	StageTransform Stage = "transform" // The output path was worked out
+	StageValidate  Stage = "validate"  // Nothing is written yet: the last chance to refuse
+	StageWrite     Stage = "write"     // The output was copied and its metadata written
+	StageVerify    Stage = "verify"    // The output was checked and hashed
+)
+
+// stageOrder lists the stages in the order a file goes through them
+var stageOrder = []Stage{StageExtract, StageTransform, StageValidate, StageWrite, StageVerify}
+
+// FileContext is the file being processed, as custom stages see it.
+// Extract stages may change DateTime, transform stages OutputPath, which is
+// then checked like a template's: it may only be the input with
+// OverrideOriginal, and only be a copy without NoCopy.
+type FileContext struct {
+	Stage      Stage
+	InputPath  string
+	OutputPath string    // Empty during the extract stage; the staging path in a transaction
+	DateTime   time.Time // The date that will be written
+	DryRun     bool      // Write and verify stages don't run in dry-run mode
+}
+
+// StageFunc is a custom stage; returning an error fails the file
+type StageFunc func(ctx *FileContext) error
+
+// ValidateStage checks that stage is one of the Stage constants
+func ValidateStage(stage Stage) error {
+	for _, s := range stageOrder {
+		if s == stage {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid stage %q (want extract, transform, validate, write or verify)", stage)
+}
+
+// runStage runs the custom stages registered for stage in the order they
+// were added, stopping at the first that fails
+func (p *Processor) runStage(stage Stage, ctx *FileContext) error {
+	ctx.Stage = stage
+	for _, fn := range p.stages[stage] {
+		if err := fn(ctx); err != nil {
+			return fmt.Errorf("%s stage: %v", stage, err)
+		}
+	}
+	return nil
+}
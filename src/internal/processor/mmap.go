@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mmapMinSize is the size from which videos on disk are patched through a
+// memory mapping instead of being read and rewritten whole
+const mmapMinSize = 64 << 20
+
+// errMmapUnsupported is returned by mmapFile when the platform or the
+// filesystem can't map the file; callers fall back to a full read/write
+var errMmapUnsupported = errors.New("memory mapping not supported")
+
+// useMappedPatch reports whether the video at filePath is patched through a
+// memory mapping: it is on disk and at least minSize bytes
+func useMappedPatch(fsys FS, filePath string, minSize int64) bool {
+	if !isOSFS(fsys) || !mmapSupported {
+		return false
+	}
+	info, err := fsys.Stat(filePath)
+	return err == nil && info.Mode().IsRegular() && info.Size() >= minSize
+}
+
+// patchesMapped reports whether the metadata write for filePath only
+// patches header bytes in place (a large video on the native writer), so
+// the file doesn't need to be buffered for write validation
+func (p *Processor) patchesMapped(filePath string) bool {
+	if p.config.Backend == BackendExiftool {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if actual := detectContainerMismatch(p.fsys, filePath); actual != "" {
+		ext = actual
+	}
+	switch ext {
+	case ".mp4", ".mov", ".m4v", ".3gp":
+		return p.videoAtoms(ext).inPlace() && useMappedPatch(p.fsys, filePath, p.mappedFrom())
+	}
+	return false
+}
+
+// patchVideoMapped sets the header atom dates of a video by mapping the
+// file and patching the header bytes in place. The moov is patched in a
+// copy first and only copied back once every atom is, so a failure leaves
+// the file as it was. It returns errMmapUnsupported when the file can't be
+// mapped.
+func patchVideoMapped(filePath string, dateTime time.Time, atoms videoAtomSet) error {
+	f, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	data, unmap, err := mmapFile(f)
+	if err != nil {
+		return err
+	}
+	moov, patchErr := findMoovPayload(data)
+	if patchErr == nil {
+		patched := append([]byte(nil), moov...)
+		if patchErr = patchMoov(patched, dateTime, atoms); patchErr == nil {
+			copy(moov, patched)
+		}
+	}
+	if err := unmap(); err != nil && patchErr == nil {
+		patchErr = fmt.Errorf("failed to unmap file: %v", err)
+	}
+	if patchErr != nil {
+		return patchErr
+	}
+
+	// Flush the patched pages like a rewrite would have
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	return nil
+}
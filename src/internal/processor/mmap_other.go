@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package processor
+
+import "os"
+
+// mmapSupported reports that files are always read and rewritten whole on
+// this platform
+const mmapSupported = false
+
+// mmapFile is never reached without mmap support
+func mmapFile(f *os.File) ([]byte, func() error, error) {
+	return nil, nil, errMmapUnsupported
+}
@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package processor
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+)
+
+// mmapSupported reports whether mmapFile can map files on this platform
+const mmapSupported = true
+
+// mmapFile maps f read-write and shared, so writes to the returned bytes
+// reach the file. unmap must be called before f is closed.
+func mmapFile(f *os.File) (data []byte, unmap func() error, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get file info: %v", err)
+	}
+	if info.Size() == 0 || info.Size() > math.MaxInt {
+		return nil, nil, errMmapUnsupported
+	}
+	data, err = syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		// Filesystems without mmap (some FUSE and network mounts) or an
+		// address space too small for the file
+		return nil, nil, errMmapUnsupported
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
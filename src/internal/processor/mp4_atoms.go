@@ -3,6 +3,7 @@ package processor
 import (
 	"encoding/binary"
 	"fmt"
+	"time"
 )
 
 const (
@@ -13,12 +14,46 @@ const (
 
 // Atom represents an MP4 atom/box
 type Atom struct {
-	Size     uint32 // Atom size (including header)
+	Offset   uint64 // Position of the atom header in the parsed data
+	Size     uint64 // Atom size (including header; 64-bit for extended-size atoms)
 	Type     string // Atom type (4 characters)
 	Data     []byte // Atom data (excluding header)
 	Children []Atom // Child atoms (for container atoms)
 }
 
+// readAtomHeader reads the atom header at pos and returns the total atom size,
+// its type and the header length (8 bytes, or 16 for 64-bit extended sizes)
+func readAtomHeader(data []byte, pos int) (uint64, string, int, error) {
+	if pos+8 > len(data) {
+		return 0, "", 0, fmt.Errorf("atom header extends beyond data")
+	}
+
+	size := uint64(binary.BigEndian.Uint32(data[pos : pos+4]))
+	atomType := string(data[pos+4 : pos+8])
+	headerLen := 8
+
+	if size == 0 {
+		// Size 0 means extends to end of file
+		size = uint64(len(data) - pos)
+	} else if size == 1 {
+		// Size 1 means a 64-bit extended size follows the type
+		if pos+16 > len(data) {
+			return 0, "", 0, fmt.Errorf("invalid atom: extended size extends beyond file")
+		}
+		size = binary.BigEndian.Uint64(data[pos+8 : pos+16])
+		headerLen = 16
+	}
+
+	if size < uint64(headerLen) {
+		return 0, "", 0, fmt.Errorf("invalid atom: size %d smaller than header", size)
+	}
+	if size > uint64(len(data)-pos) {
+		return 0, "", 0, fmt.Errorf("invalid atom: size %d extends beyond file", size)
+	}
+
+	return size, atomType, headerLen, nil
+}
+
 // ParseMP4Atoms parses MP4 file and extracts atoms
 func ParseMP4Atoms(data []byte) ([]Atom, error) {
 	if len(data) == 0 {
@@ -37,42 +72,25 @@ func ParseMP4Atoms(data []byte) ([]Atom, error) {
 		}
 
 		// Read atom header
-		size := binary.BigEndian.Uint32(data[pos : pos+4])
-		atomType := string(data[pos+4 : pos+8])
-
-		// Handle special size values
-		if size == 0 {
-			// Size 0 means extends to end of file
-			size = uint32(len(data) - pos)
-		} else if size == 1 {
-			// Size 1 means extended size follows (64-bit)
-			if pos+16 > len(data) {
-				return nil, fmt.Errorf("invalid atom: extended size extends beyond file")
-			}
-			// For simplicity, we'll handle this case by reading the extended size
-			// But for most cases, we can skip this complexity
-			return nil, fmt.Errorf("extended size atoms not yet supported")
+		size, atomType, headerLen, err := readAtomHeader(data, pos)
+		if err != nil {
+			return nil, err
 		}
 
-		if int(size) > len(data)-pos {
-			return nil, fmt.Errorf("invalid atom: size %d extends beyond file", size)
-		}
-
-		// Extract atom data (excluding 8-byte header)
-		atomData := make([]byte, size-8)
-		if size > 8 {
-			copy(atomData, data[pos+8:pos+int(size)])
-		}
+		// Extract atom data (excluding header)
+		atomData := make([]byte, int(size)-headerLen)
+		copy(atomData, data[pos+headerLen:pos+int(size)])
 
 		atom := Atom{
-			Size: size,
-			Type: atomType,
-			Data: atomData,
+			Offset: uint64(pos),
+			Size:   size,
+			Type:   atomType,
+			Data:   atomData,
 		}
 
 		// Parse child atoms for container atoms
 		if isContainerAtom(atomType) && len(atomData) > 0 {
-			children, err := parseChildAtoms(atomData)
+			children, err := parseChildAtoms(atomData, uint64(pos+headerLen))
 			if err == nil {
 				atom.Children = children
 			}
@@ -95,12 +113,16 @@ func isContainerAtom(atomType string) bool {
 		"stbl": true, // Sample table atom
 		"edts": true, // Edit atom
 		"udta": true, // User data atom
+		"mvex": true, // Movie extends atom (fragmented MP4)
+		"moof": true, // Movie fragment atom
+		"traf": true, // Track fragment atom
 	}
 	return containerAtoms[atomType]
 }
 
-// parseChildAtoms parses child atoms from parent atom data
-func parseChildAtoms(data []byte) ([]Atom, error) {
+// parseChildAtoms parses child atoms from parent atom data, which starts at
+// base in the parsed file
+func parseChildAtoms(data []byte, base uint64) ([]Atom, error) {
 	var atoms []Atom
 	pos := 0
 
@@ -114,33 +136,24 @@ func parseChildAtoms(data []byte) ([]Atom, error) {
 			break
 		}
 
-		size := binary.BigEndian.Uint32(data[pos : pos+4])
-		atomType := string(data[pos+4 : pos+8])
-
-		if size == 0 {
-			size = uint32(len(data) - pos)
-		} else if size == 1 {
-			return nil, fmt.Errorf("extended size atoms not yet supported in children")
-		}
-
-		if int(size) > len(data)-pos {
+		size, atomType, headerLen, err := readAtomHeader(data, pos)
+		if err != nil {
 			break // Invalid size
 		}
 
-		atomData := make([]byte, size-8)
-		if size > 8 {
-			copy(atomData, data[pos+8:pos+int(size)])
-		}
+		atomData := make([]byte, int(size)-headerLen)
+		copy(atomData, data[pos+headerLen:pos+int(size)])
 
 		atom := Atom{
-			Size: size,
-			Type: atomType,
-			Data: atomData,
+			Offset: base + uint64(pos),
+			Size:   size,
+			Type:   atomType,
+			Data:   atomData,
 		}
 
 		// Recursively parse children if container
 		if isContainerAtom(atomType) && len(atomData) > 0 {
-			children, err := parseChildAtoms(atomData)
+			children, err := parseChildAtoms(atomData, base+uint64(pos+headerLen))
 			if err == nil {
 				atom.Children = children
 			}
@@ -185,3 +198,70 @@ func UnixToQuickTime(unixTime int64) uint32 {
 func QuickTimeToUnix(qtTime uint32) int64 {
 	return int64(qtTime) - quickTimeEpochOffset
 }
+
+// IsFragmentedMP4 checks if the parsed atoms describe a fragmented MP4: the
+// init segment's moov declares an mvex, or movie fragments (moof) follow it
+func IsFragmentedMP4(atoms []Atom) bool {
+	if FindAtom(atoms, "moof") != nil {
+		return true
+	}
+	if moov := FindAtom(atoms, "moov"); moov != nil {
+		return FindAtomRecursive(*moov, "mvex") != nil
+	}
+	return false
+}
+
+// ReadMvhdTimes returns the creation and modification times stored in an
+// mvhd atom's data (version 0 or 1)
+func ReadMvhdTimes(mvhd []byte) (created, modified time.Time, err error) {
+	if len(mvhd) < 4 {
+		return time.Time{}, time.Time{}, fmt.Errorf("mvhd atom too short")
+	}
+	var c, m int64
+	switch mvhd[0] {
+	case 0:
+		if len(mvhd) < 12 {
+			return time.Time{}, time.Time{}, fmt.Errorf("mvhd atom too short")
+		}
+		c = int64(binary.BigEndian.Uint32(mvhd[4:8]))
+		m = int64(binary.BigEndian.Uint32(mvhd[8:12]))
+	case 1:
+		if len(mvhd) < 20 {
+			return time.Time{}, time.Time{}, fmt.Errorf("mvhd atom too short")
+		}
+		c = int64(binary.BigEndian.Uint64(mvhd[4:12]))
+		m = int64(binary.BigEndian.Uint64(mvhd[12:20]))
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: mvhd version %d", ErrUnsupportedContainer, mvhd[0])
+	}
+	return time.Unix(c-quickTimeEpochOffset, 0).UTC(), time.Unix(m-quickTimeEpochOffset, 0).UTC(), nil
+}
+
+// ReadMvhdDuration returns the timescale (time units per second) and the
+// duration, in those units, stored in an mvhd atom's data (version 0 or 1).
+// An unknown duration is all ones.
+func ReadMvhdDuration(mvhd []byte) (timescale uint32, duration uint64, err error) {
+	if len(mvhd) < 4 {
+		return 0, 0, fmt.Errorf("mvhd atom too short")
+	}
+	switch mvhd[0] {
+	case 0:
+		if len(mvhd) < 20 {
+			return 0, 0, fmt.Errorf("mvhd atom too short")
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[12:16])
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+		if duration == 0xFFFFFFFF {
+			duration = ^uint64(0)
+		}
+	case 1:
+		if len(mvhd) < 32 {
+			return 0, 0, fmt.Errorf("mvhd atom too short")
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[20:24])
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	default:
+		return 0, 0, fmt.Errorf("%w: mvhd version %d", ErrUnsupportedContainer, mvhd[0])
+	}
+	return timescale, duration, nil
+}
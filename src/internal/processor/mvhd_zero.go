@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ZeroMvhd values: what a video's mvhd creation time holding no real date
+// (see MvhdUnset) counts as once videos follow -ow
+const (
+	ZeroMvhdMissing  = "missing"  // No date: written without -ow
+	ZeroMvhdExisting = "existing" // Existing data: only replaced with -ow
+)
+
+// MvhdUnset values: why a video's mvhd creation time holds no real date
+const (
+	MvhdZero    = "zero"    // Never set, reading as 1904-01-01
+	MvhdInvalid = "invalid" // Before 1970 or in the future, e.g. a Unix time stored as a QuickTime one
+)
+
+// ValidateZeroMvhd checks that a ZeroMvhd policy is known ("" keeps
+// rewriting every video's mvhd, whatever it holds)
+func ValidateZeroMvhd(policy string) error {
+	switch policy {
+	case "", ZeroMvhdMissing, ZeroMvhdExisting:
+		return nil
+	}
+	return fmt.Errorf("unknown zero mvhd policy %q (expected missing or existing)", policy)
+}
+
+// ClassifyMvhdTime returns MvhdZero or MvhdInvalid when an mvhd creation
+// time read with ReadMvhdTimes holds no real date, "" when it does. Times
+// more than a day after now are invalid.
+func ClassifyMvhdTime(created, now time.Time) string {
+	switch {
+	case created.Unix() == -quickTimeEpochOffset:
+		return MvhdZero
+	case created.Before(time.Unix(0, 0)) || created.After(now.AddDate(0, 0, 1)):
+		return MvhdInvalid
+	}
+	return ""
+}
+
+// mvhdState reads the mvhd creation time of a video: whether it was found,
+// and MvhdZero or MvhdInvalid when it holds no real date
+func (p *Processor) mvhdState(filePath string) (found bool, unset string) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if actual := detectContainerMismatch(p.fsys, filePath); actual != "" {
+		ext = actual
+	}
+	if !isMovieFormat(ext) {
+		return false, ""
+	}
+	data, err := p.readMetadata(filePath, ext)
+	if err != nil {
+		return false, ""
+	}
+	atoms, err := ParseMP4Atoms(data)
+	if err != nil {
+		return false, ""
+	}
+	moov := FindAtom(atoms, "moov")
+	if moov == nil {
+		return false, ""
+	}
+	mvhd := FindAtomRecursive(*moov, "mvhd")
+	if mvhd == nil {
+		return false, ""
+	}
+	created, _, err := ReadMvhdTimes(mvhd.Data)
+	if err != nil {
+		return false, ""
+	}
+	return true, ClassifyMvhdTime(created, p.clock.Now())
+}
+
+// keepsMvhd reports whether a video's mvhd creation time is existing data
+// that only -ow replaces: with a ZeroMvhd policy, a real date always is,
+// and one that is zero or invalid is with ZeroMvhdExisting
+func (p *Processor) keepsMvhd(filePath string) bool {
+	if p.config.ZeroMvhd == "" || p.config.OverwriteExif {
+		return false
+	}
+	found, unset := p.mvhdState(filePath)
+	return found && (unset == "" || p.config.ZeroMvhd == ZeroMvhdExisting)
+}
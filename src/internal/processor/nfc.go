@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// NormalizeNFC composes the decomposed characters in s, so a name synced
+// from macOS, which stores "é" as "e" plus a combining acute accent (NFD),
+// matches a pattern typed with the precomposed "é" (NFC). Characters are
+// composed as they come, which is NFC for names in canonical order, as
+// macOS writes them; s is returned as is when it has nothing to compose.
+func NormalizeNFC(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return s
+	}
+
+	out := make([]rune, 0, len(s))
+	starter := -1   // Index in out of the last starter
+	lastClass := -1 // Combining class of out's last rune, when it isn't the starter
+	for _, r := range s {
+		class := combiningClass(r)
+		// A rune composes with the starter unless a rune between them has
+		// a class of 0 or at least its own
+		if starter >= 0 && (starter == len(out)-1 || (lastClass != 0 && lastClass < class)) {
+			if composed, ok := compose(out[starter], r); ok {
+				out[starter] = composed
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+			lastClass = -1
+		} else {
+			lastClass = class
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// Hangul syllable composition (Unicode 3.12)
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+// compose returns the character a starter and the rune following it
+// compose to, if any
+func compose(starter, r rune) (rune, bool) {
+	if l := starter - hangulLBase; l >= 0 && l < hangulLCount {
+		if v := r - hangulVBase; v >= 0 && v < hangulVCount {
+			return hangulSBase + (l*hangulVCount+v)*hangulTCount, true
+		}
+	}
+	if s := starter - hangulSBase; s >= 0 && s < hangulSCount && s%hangulTCount == 0 {
+		if t := r - hangulTBase; t > 0 && t < hangulTCount {
+			return starter + t, true
+		}
+	}
+	composed, ok := compositions[[2]rune{starter, r}]
+	return composed, ok
+}
+
+// combiningClass returns the canonical combining class of r (0 for
+// starters)
+func combiningClass(r rune) int {
+	i := sort.Search(len(combiningClassRanges), func(i int) bool { return combiningClassRanges[i].hi >= r })
+	if i < len(combiningClassRanges) && combiningClassRanges[i].lo <= r {
+		return int(combiningClassRanges[i].ccc)
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseClockOffset parses a clock skew correction such as "+2h30m", "-45m"
+// or "+1d6h". It accepts Go duration syntax plus an optional leading day
+// component ("d" = 24h). An empty string means no offset.
+func ParseClockOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	sign := time.Duration(1)
+	rest := s
+	if strings.HasPrefix(rest, "+") || strings.HasPrefix(rest, "-") {
+		if rest[0] == '-' {
+			sign = -1
+		}
+		rest = rest[1:]
+	}
+
+	var days time.Duration
+	if i := strings.Index(rest, "d"); i >= 0 {
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid offset %q: bad day count", s)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		rest = rest[i+1:]
+	}
+
+	var d time.Duration
+	if rest != "" {
+		var err error
+		d, err = time.ParseDuration(rest)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid offset %q: expected e.g. +2h30m, -45m or +1d6h", s)
+		}
+	}
+
+	return sign * (days + d), nil
+}
@@ -0,0 +1,301 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// oggPage is a single parsed Ogg page
+type oggPage struct {
+	Offset   int    // Byte offset of the page in the file
+	Length   int    // Total page length (header + segment table + data)
+	Header   []byte // Fixed 27-byte header
+	Segments []byte // Lacing values
+	Data     []byte // Page body
+}
+
+// parseOggPages splits an Ogg bitstream into pages
+func parseOggPages(data []byte) ([]oggPage, error) {
+	var pages []oggPage
+	pos := 0
+
+	for pos < len(data) {
+		if pos+27 > len(data) || string(data[pos:pos+4]) != "OggS" {
+			return nil, fmt.Errorf("invalid Ogg page at offset %d", pos)
+		}
+
+		segCount := int(data[pos+26])
+		if pos+27+segCount > len(data) {
+			return nil, fmt.Errorf("truncated Ogg segment table at offset %d", pos)
+		}
+		segments := data[pos+27 : pos+27+segCount]
+
+		bodyLen := 0
+		for _, s := range segments {
+			bodyLen += int(s)
+		}
+		bodyStart := pos + 27 + segCount
+		if bodyStart+bodyLen > len(data) {
+			return nil, fmt.Errorf("truncated Ogg page body at offset %d", pos)
+		}
+
+		pages = append(pages, oggPage{
+			Offset:   pos,
+			Length:   27 + segCount + bodyLen,
+			Header:   data[pos : pos+27],
+			Segments: segments,
+			Data:     data[bodyStart : bodyStart+bodyLen],
+		})
+		pos = bodyStart + bodyLen
+	}
+
+	return pages, nil
+}
+
+// oggCRCTable is the lookup table for the Ogg CRC-32 (poly 0x04c11db7, unreflected)
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = (r << 1) ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// oggCRC computes the checksum of a page whose CRC field is zeroed
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = (crc << 8) ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// buildOggPage assembles a page with the given header fields and a single
+// complete packet as its body, recomputing the lacing values and CRC
+func buildOggPage(header []byte, packet []byte) ([]byte, error) {
+	// Lacing: 255-byte segments, terminated by a segment < 255
+	var segments []byte
+	remaining := len(packet)
+	for remaining >= 255 {
+		segments = append(segments, 255)
+		remaining -= 255
+	}
+	segments = append(segments, byte(remaining))
+	if len(segments) > 255 {
+		return nil, fmt.Errorf("comment packet too large for a single Ogg page")
+	}
+
+	page := make([]byte, 0, 27+len(segments)+len(packet))
+	page = append(page, header[:26]...)
+	page = append(page, byte(len(segments)))
+	page = append(page, segments...)
+	page = append(page, packet...)
+
+	binary.LittleEndian.PutUint32(page[22:26], 0)
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+	return page, nil
+}
+
+// opusTags is a parsed OpusTags comment header packet
+type opusTags struct {
+	Vendor   string
+	Comments []string
+	Trailer  []byte // Binary data after the comments, preserved as-is
+}
+
+// parseOpusTags parses an OpusTags packet
+func parseOpusTags(packet []byte) (*opusTags, error) {
+	if len(packet) < 16 || string(packet[0:8]) != "OpusTags" {
+		return nil, fmt.Errorf("not an OpusTags packet")
+	}
+
+	pos := 8
+	readLen := func() (int, error) {
+		if pos+4 > len(packet) {
+			return 0, fmt.Errorf("truncated OpusTags packet")
+		}
+		n := int(binary.LittleEndian.Uint32(packet[pos : pos+4]))
+		pos += 4
+		if n < 0 || pos+n > len(packet) {
+			return 0, fmt.Errorf("truncated OpusTags packet")
+		}
+		return n, nil
+	}
+
+	vendorLen, err := readLen()
+	if err != nil {
+		return nil, err
+	}
+	tags := &opusTags{Vendor: string(packet[pos : pos+vendorLen])}
+	pos += vendorLen
+
+	if pos+4 > len(packet) {
+		return nil, fmt.Errorf("truncated OpusTags packet")
+	}
+	count := int(binary.LittleEndian.Uint32(packet[pos : pos+4]))
+	pos += 4
+
+	for i := 0; i < count; i++ {
+		n, err := readLen()
+		if err != nil {
+			return nil, err
+		}
+		tags.Comments = append(tags.Comments, string(packet[pos:pos+n]))
+		pos += n
+	}
+
+	tags.Trailer = packet[pos:]
+	return tags, nil
+}
+
+// bytes serializes the tags back into an OpusTags packet
+func (t *opusTags) bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OpusTags")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(t.Vendor)))
+	buf.WriteString(t.Vendor)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(t.Comments)))
+	for _, c := range t.Comments {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(c)))
+		buf.WriteString(c)
+	}
+	buf.Write(t.Trailer)
+	return buf.Bytes()
+}
+
+// date returns the value of the first DATE comment, if any
+func (t *opusTags) date() (string, bool) {
+	for _, c := range t.Comments {
+		if key, value, ok := strings.Cut(c, "="); ok && strings.EqualFold(key, "DATE") {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// setDate replaces all DATE comments with a single one holding value
+func (t *opusTags) setDate(value string) {
+	comments := make([]string, 0, len(t.Comments)+1)
+	for _, c := range t.Comments {
+		if key, _, ok := strings.Cut(c, "="); ok && strings.EqualFold(key, "DATE") {
+			continue
+		}
+		comments = append(comments, c)
+	}
+	t.Comments = append(comments, "DATE="+value)
+}
+
+// findOpusTagsPage returns the index of the page carrying the OpusTags packet.
+// Only the common layout is supported: the packet starts and ends on one page.
+func findOpusTagsPage(pages []oggPage) (int, error) {
+	if len(pages) < 2 || !bytes.HasPrefix(pages[0].Data, []byte("OpusHead")) {
+		return -1, fmt.Errorf("file is not an Ogg Opus stream")
+	}
+	page := pages[1]
+	if !bytes.HasPrefix(page.Data, []byte("OpusTags")) {
+		return -1, fmt.Errorf("OpusTags header not found")
+	}
+	if len(page.Segments) == 0 || page.Segments[len(page.Segments)-1] == 255 {
+		return -1, fmt.Errorf("OpusTags header spanning several Ogg pages is not supported")
+	}
+	return 1, nil
+}
+
+// ReadOpusDate returns the DATE comment of an Ogg Opus file
+func ReadOpusDate(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	return readOpusDate(data)
+}
+
+// readOpusDate returns the DATE comment of Ogg Opus data
+func readOpusDate(data []byte) (string, error) {
+	pages, err := parseOggPages(data)
+	if err != nil {
+		return "", err
+	}
+	idx, err := findOpusTagsPage(pages)
+	if err != nil {
+		return "", err
+	}
+	tags, err := parseOpusTags(pages[idx].Data)
+	if err != nil {
+		return "", err
+	}
+	date, ok := tags.date()
+	if !ok {
+		return "", fmt.Errorf("no DATE comment")
+	}
+	return date, nil
+}
+
+// UpdateOpusDate writes a DATE comment into the OpusTags header of an Ogg
+// Opus file (e.g. a WhatsApp voice note). An existing DATE is only replaced
+// when overwrite is true. Returns whether the file was modified.
+func UpdateOpusDate(filePath string, dateTime time.Time, overwrite bool) (bool, error) {
+	return updateOpusDate(OSFS, filePath, dateTime, overwrite)
+}
+
+// updateOpusDate is UpdateOpusDate over an arbitrary filesystem
+func updateOpusDate(fsys FS, filePath string, dateTime time.Time, overwrite bool) (bool, error) {
+	data, err := fsys.ReadFile(filePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	pages, err := parseOggPages(data)
+	if err != nil {
+		return false, err
+	}
+	idx, err := findOpusTagsPage(pages)
+	if err != nil {
+		return false, err
+	}
+	page := pages[idx]
+
+	tags, err := parseOpusTags(page.Data)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := tags.date(); exists && !overwrite {
+		return false, nil
+	}
+	tags.setDate(dateTime.Format("2006-01-02T15:04:05"))
+
+	newPage, err := buildOggPage(page.Header, tags.bytes())
+	if err != nil {
+		return false, err
+	}
+
+	// Page sequence numbers are unchanged, so only this page needs rewriting
+	newData := make([]byte, 0, len(data)-page.Length+len(newPage))
+	newData = append(newData, data[:page.Offset]...)
+	newData = append(newData, newPage...)
+	newData = append(newData, data[page.Offset+page.Length:]...)
+	if bytes.Equal(newData, data) {
+		return false, nil
+	}
+
+	info, err := fsys.Stat(filePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to get file info: %v", err)
+	}
+	if err := fsys.WriteFile(filePath, newData, info.Mode()); err != nil {
+		return false, fmt.Errorf("failed to write file: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"log"
+)
+
+// Option customizes a Processor created with New
+type Option func(*Processor)
+
+// WithOutputDir sets the directory processed files are written to
+func WithOutputDir(dir string) Option {
+	return func(p *Processor) { p.config.OutputDir = dir }
+}
+
+// WithDryRun enables or disables dry-run mode
+func WithDryRun(dryRun bool) Option {
+	return func(p *Processor) { p.config.DryRun = dryRun }
+}
+
+// WithConcurrency sets how many files ProcessFiles handles at once (default 1)
+func WithConcurrency(n int) Option {
+	return func(p *Processor) {
+		if n < 1 {
+			n = 1
+		}
+		p.concurrency = n
+	}
+}
+
+// WithLogger sends verbose output to logger instead of standard output
+func WithLogger(logger *log.Logger) Option {
+	return func(p *Processor) { p.logger = logger }
+}
+
+// WithFS runs the pipeline over fsys instead of the OS filesystem, e.g. a
+// MemFS in tests. The exiftool and ffmpeg backends need OSFS.
+func WithFS(fsys FS) Option {
+	return func(p *Processor) { p.fsys = fsys }
+}
+
+// WithClock sets the clock used to timestamp results and reports
+func WithClock(clock Clock) Option {
+	return func(p *Processor) { p.clock = clock }
+}
+
+// WithJournal records the temporary files of each run in journal, so that
+// leftovers of a crashed run can be removed with CleanupJournals
+func WithJournal(journal *Journal) Option {
+	return func(p *Processor) { p.journal = journal }
+}
+
+// WithTransaction writes every output to tx's staging area instead of its
+// final path; the caller commits or rolls back tx after the batch
+func WithTransaction(tx *Transaction) Option {
+	return func(p *Processor) { p.tx = tx }
+}
+
+// WithAuditLog appends an entry to auditLog for every file changed, as soon
+// as it is. Outputs that aren't final when their file is done (staged in a
+// transaction, uploaded later) are left for the caller to append.
+func WithAuditLog(auditLog *AuditLog) Option {
+	return func(p *Processor) { p.audit = auditLog }
+}
+
+// WithStage adds fn to the custom stages run after stage, e.g. a policy
+// check at StageValidate that refuses files before anything is written.
+// Stages added for the same step run in the order they were added.
+func WithStage(stage Stage, fn StageFunc) Option {
+	return func(p *Processor) {
+		if err := ValidateStage(stage); err != nil && p.stagesErr == nil {
+			p.stagesErr = err
+		}
+		if p.stages == nil {
+			p.stages = make(map[Stage][]StageFunc)
+		}
+		p.stages[stage] = append(p.stages[stage], fn)
+	}
+}
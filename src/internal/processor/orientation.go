@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+)
+
+// normalizeQuality is the JPEG quality images are re-encoded at when their
+// pixels are rotated; the original quality isn't recorded in the file
+const normalizeQuality = 95
+
+// ReadEXIFOrientation returns the Orientation tag (1-8) of an EXIF APP1
+// payload, or 1 when it has none
+func ReadEXIFOrientation(payload []byte) (int, error) {
+	tiff, order, err := parseTIFFHeader(payload)
+	if err != nil {
+		return 1, err
+	}
+	offset := order.Uint32(tiff[4:8])
+	if int(offset)+2 > len(tiff) {
+		return 1, fmt.Errorf("IFD offset %d beyond data", offset)
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(tiff) {
+		return 1, fmt.Errorf("IFD truncated")
+	}
+	for i := 0; i < count; i++ {
+		entry := tiff[start+i*12 : start+(i+1)*12]
+		if order.Uint16(entry[0:2]) != tagOrientation || order.Uint16(entry[2:4]) != typeShort {
+			continue
+		}
+		if o := int(order.Uint16(entry[8:10])); o >= 1 && o <= 8 {
+			return o, nil
+		}
+		return 1, nil
+	}
+	return 1, nil
+}
+
+// jpegOrientation returns the EXIF Orientation of a JPEG, 1 when it has no
+// EXIF or no Orientation tag
+func jpegOrientation(data []byte) int {
+	segments, err := ParseJPEGSegments(data)
+	if err != nil {
+		return 1
+	}
+	_, app1 := FindAPP1Segment(segments)
+	if app1 == nil {
+		return 1
+	}
+	o, _ := ReadEXIFOrientation(app1.Payload)
+	return o
+}
+
+// NormalizeJPEGOrientation rotates and flips the pixels of a JPEG so it
+// displays upright without its EXIF Orientation (2-8). The image is
+// re-encoded, so this is lossy; its APPn and comment segments (EXIF, XMP,
+// ICC profile...) are kept as they were, and the caller resets Orientation.
+func NormalizeJPEGOrientation(data []byte, orientation int) ([]byte, error) {
+	if orientation <= 1 || orientation > 8 {
+		return data, nil
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JPEG: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, orientImage(img, orientation), &jpeg.Options{Quality: normalizeQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode JPEG: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	original, err := ParseJPEGSegments(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JPEG: %v", err)
+	}
+	tables, err := ParseJPEGSegments(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse encoded JPEG: %v", err)
+	}
+	var segments []JPEGSegment
+	for _, seg := range original {
+		if (seg.Marker >= markerAPP0 && seg.Marker <= 0xEF) || seg.Marker == 0xFE {
+			segments = append(segments, seg)
+		}
+	}
+	segments = append(segments, tables...)
+	return ReassembleJPEG(segments, encoded[jpegImageDataStart(encoded):]), nil
+}
+
+// orientImage applies the transform EXIF Orientation o asks viewers to make
+func orientImage(img image.Image, o int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 { // 5-8 swap width and height
+		dw, dh = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // Mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // Rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // Mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // Mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // Rotated 90° clockwise to display
+				dx, dy = h-1-y, x
+			case 7: // Mirrored along the top-right diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // Rotated 90° counterclockwise to display
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			out.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output templates equivalent to the -o / -out / _modified rules
+const (
+	templateSuffix  = "{dir}/{name}_modified{ext}" // Copy next to the original
+	templateInPlace = "{dir}/{name}{ext}"          // Overwrite the original (-o)
+	templateFlat    = "{out}/{name}{ext}"          // Copy into the output directory (-out)
+)
+
+// TemplateKeepTree copies into the output directory keeping the input's
+// subdirectories
+const TemplateKeepTree = "{out}/{rel}/{name}{ext}"
+
+// TemplateRenameHash is the output template of --rename-hash: date folders
+// and content-addressed names, so identical files get the same name
+const TemplateRenameHash = "{out}/{year}/{month}/{date}_{hash}{ext}"
+
+// hashNameLength is how many hex digits of the SHA-256 {hash} keeps
+const hashNameLength = 12
+
+// templatePlaceholder matches a {placeholder} in an output template
+var templatePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// templateFields are the placeholders an output template may use
+var templateFields = map[string]bool{
+	"dir":   true, // Directory of the input file
+	"out":   true, // Output directory (-out), or {dir} without one
+	"rel":   true, // Input file's directory relative to the input directory
+	"name":  true, // File name without extension
+	"ext":   true, // Extension, with the dot
+	"year":  true, // Date of the file: 2024
+	"month": true, // 05
+	"day":   true, // 01
+	"date":  true, // 2024-05-01
+	"hash":  true, // First hashNameLength hex digits of the input's SHA-256
+}
+
+// ValidateOutputTemplate checks that an output template only uses known
+// placeholders and includes {name} or {hash}, so files can't all map to one
+// path
+func ValidateOutputTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	hasName := false
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(template, -1) {
+		if !templateFields[match[1]] {
+			return fmt.Errorf("unknown placeholder %s in output template (expected {dir}, {out}, {rel}, {name}, {ext}, {year}, {month}, {day}, {date} or {hash})", match[0])
+		}
+		hasName = hasName || match[1] == "name" || match[1] == "hash"
+	}
+	if !hasName {
+		return fmt.Errorf("output template %q must include {name} or {hash}", template)
+	}
+	return nil
+}
+
+// outputTemplate returns the configured template, or the one matching the
+// -o / -out options
+func (p *Processor) outputTemplate() string {
+	if p.config.OutputTemplate != "" {
+		return p.config.OutputTemplate
+	}
+	if p.config.OutputDir == "" {
+		if p.config.OverrideOriginal {
+			return templateInPlace
+		}
+		return templateSuffix
+	}
+	absInputDir, _ := filepath.Abs(p.config.InputDir)
+	absOutputDir, _ := filepath.Abs(p.config.OutputDir)
+	if absOutputDir == absInputDir {
+		return templateSuffix
+	}
+	return templateFlat
+}
+
+// expandOutputTemplate evaluates template for one input file and its date.
+// hash is the input's SHA-256, needed only when the template uses {hash}.
+func (p *Processor) expandOutputTemplate(template, inputPath, hash string, date time.Time) string {
+	dir := filepath.Dir(inputPath)
+	out := p.config.OutputDir
+	if out == "" {
+		out = dir
+	}
+	rel, err := filepath.Rel(p.config.InputDir, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = "."
+	}
+	base := filepath.Base(inputPath)
+	ext := filepath.Ext(base)
+
+	values := map[string]string{
+		"dir":   dir,
+		"out":   out,
+		"rel":   rel,
+		"name":  strings.TrimSuffix(base, ext),
+		"ext":   ext,
+		"year":  date.Format("2006"),
+		"month": date.Format("01"),
+		"day":   date.Format("02"),
+		"date":  date.Format("2006-01-02"),
+	}
+	if len(hash) >= hashNameLength {
+		values["hash"] = hash[:hashNameLength]
+	}
+	expanded := templatePlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		return values[match[1:len(match)-1]]
+	})
+	return filepath.Clean(filepath.FromSlash(expanded))
+}
+
+// hashesNames reports whether output names depend on the input's hash
+func (p *Processor) hashesNames() bool {
+	return strings.Contains(p.outputTemplate(), "{hash}")
+}
+
+// outputClaims are the output paths taken by the files of a run
+type outputClaims struct {
+	mu    sync.Mutex
+	paths map[string]string // Output path -> the input writing it
+}
+
+// claimOutput reserves outputPath for inputPath, so two files of a run never
+// write the same output, even from concurrent workers. With {hash} names a
+// path already taken holds identical bytes, and the file is a duplicate
+// (claimOutput returns "" and the input that holds the path); otherwise the
+// name gets a _2, _3, ... suffix until it is free.
+func (p *Processor) claimOutput(outputPath, inputPath string) (claimed, holder string) {
+	if p.claims == nil {
+		return outputPath, ""
+	}
+	p.claims.mu.Lock()
+	defer p.claims.mu.Unlock()
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	path := outputPath
+	for n := 2; ; n++ {
+		owner, taken := p.claims.paths[path]
+		if !taken || owner == inputPath {
+			p.claims.paths[path] = inputPath
+			return path, ""
+		}
+		if p.hashesNames() {
+			return "", owner
+		}
+		path = fmt.Sprintf("%s_%d%s", base, n, ext)
+	}
+}
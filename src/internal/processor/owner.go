@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Owner is the user and group outputs are given with --chown. An ID of -1
+// leaves that part of the ownership unchanged.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// ParseOwner parses a --chown value: "user:group", "user" or ":group", each
+// a name or a numeric ID. "" returns nil (outputs keep the input's owner
+// where permitted).
+func ParseOwner(spec string) (*Owner, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if !chownSupported {
+		return nil, fmt.Errorf("changing file owners is only supported on Unix")
+	}
+	userPart, groupPart, _ := strings.Cut(spec, ":")
+	if userPart == "" && groupPart == "" {
+		return nil, fmt.Errorf("invalid owner %q (expected user:group)", spec)
+	}
+	owner := &Owner{UID: -1, GID: -1}
+	if userPart != "" {
+		id, err := lookupID(userPart, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid owner %q: %v", spec, err)
+		}
+		owner.UID = id
+	}
+	if groupPart != "" {
+		id, err := lookupID(groupPart, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid owner %q: %v", spec, err)
+		}
+		owner.GID = id
+	}
+	return owner, nil
+}
+
+// lookupID returns a numeric ID as is, and looks names up with lookup
+func lookupID(s string, lookup func(name string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil {
+		if id < 0 {
+			return 0, fmt.Errorf("negative ID %d", id)
+		}
+		return id, nil
+	}
+	idStr, err := lookup(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(idStr)
+}
+
+// chown gives path to the owner
+func (o *Owner) chown(path string) error {
+	if err := os.Chown(path, o.UID, o.GID); err != nil {
+		return fmt.Errorf("failed to change owner: %v", err)
+	}
+	return nil
+}
+
+// mkdirAllOwned creates dir and any missing parents like MkdirAll, giving
+// the directories it created to owner (nil leaves them to the running user)
+func mkdirAllOwned(fsys FS, dir string, owner *Owner) error {
+	if owner == nil || !isOSFS(fsys) {
+		return fsys.MkdirAll(dir, 0755)
+	}
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := fsys.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if err := owner.chown(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package processor
+
+import "io/fs"
+
+// chownSupported reports that file owners are left alone on this platform
+const chownSupported = false
+
+// preserveOwner has nothing to preserve without Unix owners
+func preserveOwner(path string, info fs.FileInfo) {}
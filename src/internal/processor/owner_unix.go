@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package processor
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// chownSupported reports that file owners can be changed on this platform
+const chownSupported = true
+
+// preserveOwner gives path the owner and group of the file described by
+// info. Only root may give files away, so otherwise just the group is
+// kept, when the running user belongs to it; failures are ignored.
+func preserveOwner(path string, info fs.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	if os.Chown(path, int(st.Uid), int(st.Gid)) != nil {
+		os.Chown(path, -1, int(st.Gid))
+	}
+}
@@ -0,0 +1,197 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FilenamePattern is a configured filename pattern, tried before the
+// built-in WhatsApp ones. Pattern is either a format with a {date}
+// placeholder (e.g. "Screenshot_{date}") or a regex with a named group
+// "date"; both capture the date as YYYYMMDD. A regex may also capture the
+// time of day as HHMMSS in a group "time", which takes precedence over Time.
+type FilenamePattern struct {
+	Pattern  string `json:"pattern"`
+	Time     string `json:"time,omitempty"`     // Time of day given to matched dates, e.g. "12:00" ("" = midnight)
+	Timezone string `json:"timezone,omitempty"` // IANA zone the date is local to ("" = the global timezone)
+}
+
+// compiledPattern is a FilenamePattern ready for matching
+type compiledPattern struct {
+	source   string
+	regex    *regexp.Regexp
+	clock    string         // Time of day as 15:04:05 ("" = none)
+	location *time.Location // nil = the processor's timezone
+}
+
+// ValidateFilenamePattern checks that a configured pattern, its time and
+// its timezone are valid
+func ValidateFilenamePattern(pattern FilenamePattern) error {
+	_, err := compilePattern(pattern)
+	return err
+}
+
+// compilePattern turns a configured pattern into a regex with a date group
+func compilePattern(pattern FilenamePattern) (*compiledPattern, error) {
+	if strings.TrimSpace(pattern.Pattern) == "" {
+		return nil, fmt.Errorf("pattern must not be empty")
+	}
+
+	// Names are matched in NFC (see matchFilename), so patterns are too
+	expr := NormalizeNFC(pattern.Pattern)
+	if strings.Contains(expr, "{date}") {
+		parts := strings.Split(expr, "{date}")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		expr = strings.Join(parts, `(?P<date>\d{8})`)
+	}
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern.Pattern, err)
+	}
+	if regex.SubexpIndex("date") < 0 {
+		return nil, fmt.Errorf("pattern %q has no {date} placeholder or (?P<date>...) group", pattern.Pattern)
+	}
+
+	compiled := &compiledPattern{source: pattern.Pattern, regex: regex}
+	if pattern.Time != "" {
+		clock, err := parseTimeOfDay(pattern.Time)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %v", pattern.Pattern, err)
+		}
+		compiled.clock = clock
+	}
+	if pattern.Timezone != "" {
+		compiled.location, err = LoadTimezone(pattern.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %v", pattern.Pattern, err)
+		}
+	}
+	return compiled, nil
+}
+
+// compilePatterns compiles the configured patterns, in order
+func compilePatterns(patterns []FilenamePattern) ([]*compiledPattern, error) {
+	compiled := make([]*compiledPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		c, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// parseTimeOfDay accepts "15:04" or "15:04:05" and returns the latter form
+func parseTimeOfDay(s string) (string, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("15:04:05"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid time %q: expected HH:MM or HH:MM:SS", s)
+}
+
+// match returns the ISO date (or datetime, with a configured time) the
+// pattern finds in a filename without its extension
+func (c *compiledPattern) match(nameWithoutExt string) (string, bool) {
+	matches := c.regex.FindStringSubmatch(nameWithoutExt)
+	if matches == nil {
+		return "", false
+	}
+	dateStr, err := convertDateFormat(matches[c.regex.SubexpIndex("date")])
+	if err != nil {
+		return "", false
+	}
+	if _, err := time.Parse("2006-01-02", dateStr); err != nil {
+		return "", false
+	}
+	clock := c.clock
+	if i := c.regex.SubexpIndex("time"); i >= 0 && matches[i] != "" {
+		t, err := time.Parse("150405", matches[i])
+		if err != nil {
+			return "", false
+		}
+		clock = t.Format("15:04:05")
+	}
+	if clock != "" {
+		dateStr += "T" + clock
+	}
+	return dateStr, true
+}
+
+// Built-in pattern sets enabled with ExtraPatterns
+const (
+	ExtraPatternsCamera = "camera" // IMG_20240501_123045.jpg and the like
+)
+
+// extraPatternSets are the optional built-in patterns, by set name. They
+// are tried after the WhatsApp patterns, so WhatsApp names always win.
+var extraPatternSets = map[string][]FilenamePattern{
+	ExtraPatternsCamera: {
+		{Pattern: `^(?:IMG|VID|PXL)_(?P<date>\d{8})_(?P<time>\d{6})`},
+	},
+}
+
+// ValidateExtraPatterns checks that every ExtraPatterns set name is known
+func ValidateExtraPatterns(names []string) error {
+	_, err := compileExtraPatterns(names)
+	return err
+}
+
+// compileExtraPatterns compiles the built-in pattern sets named in names
+func compileExtraPatterns(names []string) ([]*compiledPattern, error) {
+	var compiled []*compiledPattern
+	for _, name := range names {
+		set, ok := extraPatternSets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown extra pattern set %q (expected camera)", name)
+		}
+		c, err := compilePatterns(set)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, c...)
+	}
+	return compiled, nil
+}
+
+// matchFilename finds the date in a filename: configured patterns first,
+// then the built-in WhatsApp ones, then the ExtraPatterns sets. It returns
+// the pattern that matched, the date as an ISO date or datetime, and the
+// timezone the date is local to.
+func (p *Processor) matchFilename(filename string) (pattern, date string, location *time.Location, err error) {
+	// Names synced from macOS arrive decomposed (NFD)
+	filename = NormalizeNFC(filename)
+	nameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
+	for _, c := range p.patterns {
+		if date, ok := c.match(nameWithoutExt); ok {
+			location = c.location
+			if location == nil {
+				location = p.location
+			}
+			return c.source, date, location, nil
+		}
+	}
+	pattern, date, err = MatchDefaultPattern(filename)
+	if err != nil {
+		for _, c := range p.extras {
+			if date, ok := c.match(nameWithoutExt); ok {
+				return c.source, date, p.location, nil
+			}
+		}
+	}
+	return pattern, date, p.location, err
+}
+
+// FilenameDate returns the date processing would extract from a filename,
+// using the configured patterns before the built-in ones
+func (p *Processor) FilenameDate(filename string) (string, error) {
+	_, date, _, err := p.matchFilename(filename)
+	return date, err
+}
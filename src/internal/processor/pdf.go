@@ -0,0 +1,218 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	pdfStartXrefRe    = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	pdfRefRe          = regexp.MustCompile(`^\s*(\d+)\s+(\d+)\s+R`)
+	pdfCreationDateRe = regexp.MustCompile(`/CreationDate\s*(\((?:\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>)`)
+)
+
+// pdfTrailer holds the trailer entries needed to append an incremental update
+type pdfTrailer struct {
+	Dict      []byte // Raw trailer dictionary contents (between << and >>)
+	Size      int
+	StartXref int
+	InfoNum   int // 0 when the document has no Info dictionary
+	InfoGen   int
+}
+
+// FormatPDFDate formats t as a PDF date string: D:YYYYMMDDHHmmSS+HH'mm'
+func FormatPDFDate(t time.Time) string {
+	_, offset := t.Zone()
+	if offset == 0 {
+		return "D:" + t.Format("20060102150405") + "Z"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("D:%s%c%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, offset%3600/60)
+}
+
+// readPDFTrailer locates the last cross-reference section and its trailer.
+// Only classic xref tables are supported; cross-reference streams (PDF 1.5+
+// object streams) would need a stream-based incremental update.
+func readPDFTrailer(data []byte) (*pdfTrailer, error) {
+	tail := data
+	if len(tail) > 1024 {
+		tail = tail[len(tail)-1024:]
+	}
+	m := pdfStartXrefRe.FindSubmatch(tail)
+	if m == nil {
+		return nil, fmt.Errorf("startxref not found")
+	}
+	startXref, _ := strconv.Atoi(string(m[1]))
+	if startXref <= 0 || startXref >= len(data) {
+		return nil, fmt.Errorf("invalid startxref offset %d", startXref)
+	}
+	if !bytes.HasPrefix(data[startXref:], []byte("xref")) {
+		return nil, fmt.Errorf("cross-reference streams are not supported")
+	}
+
+	rel := bytes.Index(data[startXref:], []byte("trailer"))
+	if rel < 0 {
+		return nil, fmt.Errorf("trailer not found")
+	}
+	dict, err := pdfDictAt(data, startXref+rel+len("trailer"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trailer: %v", err)
+	}
+	if bytes.Contains(dict, []byte("/Encrypt")) {
+		return nil, fmt.Errorf("encrypted PDFs are not supported")
+	}
+
+	trailer := &pdfTrailer{Dict: dict, StartXref: startXref}
+	if i := bytes.Index(dict, []byte("/Size")); i >= 0 {
+		fmt.Sscanf(string(dict[i+len("/Size"):]), "%d", &trailer.Size)
+	}
+	if trailer.Size <= 0 {
+		return nil, fmt.Errorf("trailer has no /Size")
+	}
+	if i := bytes.Index(dict, []byte("/Info")); i >= 0 {
+		ref := pdfRefRe.FindSubmatch(dict[i+len("/Info"):])
+		if ref == nil {
+			return nil, fmt.Errorf("unsupported /Info entry")
+		}
+		trailer.InfoNum, _ = strconv.Atoi(string(ref[1]))
+		trailer.InfoGen, _ = strconv.Atoi(string(ref[2]))
+	}
+	return trailer, nil
+}
+
+// pdfDictAt returns the contents of the dictionary starting at (or after
+// whitespace following) pos, honoring nested dictionaries
+func pdfDictAt(data []byte, pos int) ([]byte, error) {
+	start := bytes.Index(data[pos:], []byte("<<"))
+	if start < 0 {
+		return nil, fmt.Errorf("dictionary not found")
+	}
+	start += pos
+	depth := 0
+	for i := start; i+1 < len(data); i++ {
+		switch {
+		case data[i] == '<' && data[i+1] == '<':
+			depth++
+			i++
+		case data[i] == '>' && data[i+1] == '>':
+			depth--
+			i++
+			if depth == 0 {
+				return data[start+2 : i-1], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unterminated dictionary")
+}
+
+// readPDFInfo returns the contents of the Info dictionary object num/gen,
+// using the last definition of the object in the file
+func readPDFInfo(data []byte, num, gen int) ([]byte, error) {
+	header := regexp.MustCompile(fmt.Sprintf(`(?:^|\s)%d\s+%d\s+obj`, num, gen))
+	locs := header.FindAllIndex(data, -1)
+	if len(locs) == 0 {
+		return nil, fmt.Errorf("Info object %d %d not found", num, gen)
+	}
+	return pdfDictAt(data, locs[len(locs)-1][1])
+}
+
+// ReadPDFCreationDate returns the /CreationDate of a PDF's Info dictionary
+func ReadPDFCreationDate(data []byte) (string, error) {
+	trailer, err := readPDFTrailer(data)
+	if err != nil {
+		return "", err
+	}
+	if trailer.InfoNum == 0 {
+		return "", fmt.Errorf("no Info dictionary")
+	}
+	info, err := readPDFInfo(data, trailer.InfoNum, trailer.InfoGen)
+	if err != nil {
+		return "", err
+	}
+	m := pdfCreationDateRe.FindSubmatch(info)
+	if m == nil {
+		return "", fmt.Errorf("no /CreationDate")
+	}
+	value := string(m[1])
+	if value[0] == '(' {
+		value = value[1 : len(value)-1]
+	}
+	return value, nil
+}
+
+// UpdatePDFCreationDate sets /CreationDate in the Info dictionary of a PDF
+// (e.g. a WhatsApp DOC-*.pdf) by appending an incremental update, so the
+// original bytes are left untouched. An existing date is only replaced when
+// overwrite is true and it differs. Returns whether the file was modified.
+func UpdatePDFCreationDate(filePath string, dateTime time.Time, overwrite bool) (bool, error) {
+	return updatePDFCreationDate(OSFS, filePath, dateTime, overwrite)
+}
+
+// updatePDFCreationDate is UpdatePDFCreationDate over an arbitrary filesystem
+func updatePDFCreationDate(fsys FS, filePath string, dateTime time.Time, overwrite bool) (bool, error) {
+	data, err := fsys.ReadFile(filePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return false, fmt.Errorf("file is not a valid PDF")
+	}
+
+	trailer, err := readPDFTrailer(data)
+	if err != nil {
+		return false, err
+	}
+
+	// Reuse the Info object number, or allocate a new one
+	num, gen := trailer.InfoNum, trailer.InfoGen
+	var entries []byte
+	if num != 0 {
+		info, err := readPDFInfo(data, num, gen)
+		if err != nil {
+			return false, err
+		}
+		if m := pdfCreationDateRe.FindSubmatch(info); m != nil {
+			// Re-runs with overwrite mustn't stack identical updates
+			if !overwrite || string(m[1]) == "("+FormatPDFDate(dateTime)+")" {
+				return false, nil
+			}
+		}
+		entries = bytes.TrimSpace(pdfCreationDateRe.ReplaceAll(info, nil))
+	} else {
+		num, gen = trailer.Size, 0
+	}
+
+	newSize := trailer.Size
+	if num >= newSize {
+		newSize = num + 1
+	}
+
+	var update bytes.Buffer
+	if data[len(data)-1] != '\n' && data[len(data)-1] != '\r' {
+		update.WriteByte('\n')
+	}
+	objOffset := len(data) + update.Len()
+	fmt.Fprintf(&update, "%d %d obj\n<< %s /CreationDate (%s) >>\nendobj\n", num, gen, entries, FormatPDFDate(dateTime))
+	xrefOffset := len(data) + update.Len()
+	fmt.Fprintf(&update, "xref\n%d 1\n%010d %05d n \n", num, objOffset, gen)
+
+	// Carry the previous trailer forward, overriding Size/Info/Prev
+	trailerDict := regexp.MustCompile(`/(Size|Prev)\s+\d+|/Info\s+\d+\s+\d+\s+R`).ReplaceAll(trailer.Dict, nil)
+	fmt.Fprintf(&update, "trailer\n<< %s /Size %d /Info %d %d R /Prev %d >>\n", bytes.TrimSpace(trailerDict), newSize, num, gen, trailer.StartXref)
+	fmt.Fprintf(&update, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	info, err := fsys.Stat(filePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to get file info: %v", err)
+	}
+	if err := fsys.WriteFile(filePath, append(data, update.Bytes()...), info.Mode()); err != nil {
+		return false, fmt.Errorf("failed to write file: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,268 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LibraryAsset is a processed file to import into a self-hosted photo library
+type LibraryAsset struct {
+	File     string
+	DateTime time.Time
+}
+
+// LibraryAssetsFromResults collects the successfully written outputs of a run
+func LibraryAssetsFromResults(results []ProcessResult) []LibraryAsset {
+	var assets []LibraryAsset
+	for _, r := range results {
+		if r.Success && r.OutputFile != "" {
+			assets = append(assets, LibraryAsset{File: r.OutputFile, DateTime: r.DateTime})
+		}
+	}
+	return assets
+}
+
+// ImmichClient uploads assets to an Immich server via its REST API
+type ImmichClient struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+// NewImmichClient creates an ImmichClient for the given server URL and API key
+func NewImmichClient(baseURL, apiKey string) *ImmichClient {
+	return &ImmichClient{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		APIKey:  apiKey,
+		Client:  &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// UploadAssets uploads each asset with its corrected creation date
+func (c *ImmichClient) UploadAssets(assets []LibraryAsset) []UploadResult {
+	results := make([]UploadResult, 0, len(assets))
+	for _, asset := range assets {
+		id, err := c.uploadAsset(asset)
+		result := UploadResult{File: asset.File, Attempts: 1, Error: err}
+		if err == nil {
+			result.URI = c.BaseURL + "/photos/" + id
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// uploadAsset sends a single multipart POST /api/assets request and returns the asset ID
+func (c *ImmichClient) uploadAsset(asset LibraryAsset) (string, error) {
+	info, err := os.Stat(asset.File)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	takenAt := asset.DateTime.Format(time.RFC3339)
+	fields := map[string]string{
+		"deviceAssetId":  fmt.Sprintf("%s-%d", filepath.Base(asset.File), info.Size()),
+		"deviceId":       "wappd",
+		"fileCreatedAt":  takenAt,
+		"fileModifiedAt": takenAt,
+	}
+
+	body, err := newMultipartUpload("assetData", asset.File, fields)
+	if err != nil {
+		return "", err
+	}
+	req, err := newUploadRequest(http.MethodPost, c.BaseURL+"/api/assets", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("x-api-key", c.APIKey)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("immich upload failed with status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var created struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to parse immich response: %v", err)
+	}
+	return created.ID, nil
+}
+
+// PhotoPrismClient uploads assets to a PhotoPrism server and triggers an import
+type PhotoPrismClient struct {
+	BaseURL string
+	Token   string // App password or access token
+	UserUID string
+	Client  *http.Client
+}
+
+// NewPhotoPrismClient creates a PhotoPrismClient for the given server, token and user UID
+func NewPhotoPrismClient(baseURL, token, userUID string) *PhotoPrismClient {
+	return &PhotoPrismClient{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+		UserUID: userUID,
+		Client:  &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// UploadAssets uploads all assets into a single upload batch, then asks
+// PhotoPrism to import it. PhotoPrism reads the dates from the corrected
+// EXIF/video metadata, so no extra date fields are sent.
+func (c *PhotoPrismClient) UploadAssets(assets []LibraryAsset) []UploadResult {
+	batch := fmt.Sprintf("wappd%d", time.Now().UnixNano())
+	uploadURL := fmt.Sprintf("%s/api/v1/users/%s/upload/%s", c.BaseURL, c.UserUID, batch)
+
+	results := make([]UploadResult, 0, len(assets))
+	uploaded := 0
+	for _, asset := range assets {
+		err := c.uploadFile(uploadURL, asset.File)
+		if err == nil {
+			uploaded++
+		}
+		results = append(results, UploadResult{File: asset.File, URI: uploadURL, Attempts: 1, Error: err})
+	}
+
+	if uploaded == 0 {
+		return results
+	}
+
+	// Importing is a separate step; if it fails, every uploaded file is affected
+	if err := c.importBatch(uploadURL); err != nil {
+		for i := range results {
+			if results[i].Error == nil {
+				results[i].Error = err
+			}
+		}
+	}
+	return results
+}
+
+// uploadFile POSTs a single file into the upload batch
+func (c *PhotoPrismClient) uploadFile(uploadURL, file string) error {
+	body, err := newMultipartUpload("files", file, nil)
+	if err != nil {
+		return err
+	}
+	req, err := newUploadRequest(http.MethodPost, uploadURL, body)
+	if err != nil {
+		return err
+	}
+	return c.do(req)
+}
+
+// importBatch PUTs the upload batch to start importing it into the library
+func (c *PhotoPrismClient) importBatch(uploadURL string) error {
+	req, err := http.NewRequest(http.MethodPut, uploadURL, strings.NewReader(`{"albums":[]}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.do(req); err != nil {
+		return fmt.Errorf("photoprism import failed: %v", err)
+	}
+	return nil
+}
+
+// do sends an authenticated request and checks for a 2xx response
+func (c *PhotoPrismClient) do(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("photoprism request failed with status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// multipartUpload is a multipart/form-data body that streams a file from
+// disk between its fields and the closing boundary, so uploading a large
+// video never holds it in memory. Its length is known up front, so requests
+// carry a Content-Length rather than a chunked body.
+type multipartUpload struct {
+	io.Reader
+	file          *os.File
+	ContentLength int64
+	ContentType   string
+}
+
+// Close closes the file being streamed
+func (m *multipartUpload) Close() error {
+	return m.file.Close()
+}
+
+// newMultipartUpload opens file as the fileField part of a multipart body,
+// after the extra fields
+func newMultipartUpload(fileField, file string, fields map[string]string) (*multipartUpload, error) {
+	var head bytes.Buffer
+	w := multipart.NewWriter(&head)
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := w.CreateFormFile(fileField, filepath.Base(file)); err != nil {
+		return nil, err
+	}
+	// Closing the writer only adds the closing boundary
+	var tail bytes.Buffer
+	headLen := head.Len()
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	tail.Write(head.Bytes()[headLen:])
+	head.Truncate(headLen)
+
+	src, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	info, err := src.Stat()
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("failed to stat file: %v", err)
+	}
+	return &multipartUpload{
+		Reader:        io.MultiReader(&head, src, &tail),
+		file:          src,
+		ContentLength: int64(head.Len()) + info.Size() + int64(tail.Len()),
+		ContentType:   w.FormDataContentType(),
+	}, nil
+}
+
+// newUploadRequest builds a request streaming body
+func newUploadRequest(method, url string, body *multipartUpload) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.ContentLength = body.ContentLength
+	req.Header.Set("Content-Type", body.ContentType)
+	return req, nil
+}
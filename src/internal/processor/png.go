@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pngSignature starts every PNG file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// pngCreationTime is the tEXt keyword the PNG spec reserves for the time the
+// image was created
+const pngCreationTime = "Creation Time"
+
+// ReadPNGDates returns the dates a PNG stores: the EXIF date tags of its
+// eXIf chunk (where exiftool writes them) and its "Creation Time" tEXt
+// chunk. Chunks are read up to IEND; their CRCs aren't checked.
+func ReadPNGDates(data []byte) ([]MetadataDate, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, fmt.Errorf("invalid PNG: missing signature")
+	}
+	var dates []MetadataDate
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		start := pos + 8
+		if length < 0 || length > len(data)-start-4 {
+			return nil, fmt.Errorf("invalid PNG: %s chunk extends beyond file", chunkType)
+		}
+		chunk := data[start : start+length]
+		switch chunkType {
+		case "eXIf":
+			tags, err := ReadEXIFDates(chunk)
+			if err != nil {
+				return nil, fmt.Errorf("eXIf: %v", err)
+			}
+			dates = append(dates, exifMetadataDates(tags)...)
+		case "tEXt":
+			if keyword, text, ok := bytes.Cut(chunk, []byte{0}); ok && string(keyword) == pngCreationTime {
+				dates = append(dates, MetadataDate{"PNG " + pngCreationTime, pngCreationDate(latin1(text))})
+			}
+		case "IEND":
+			return dates, nil
+		}
+		pos = start + length + 4 // CRC
+	}
+	return dates, nil
+}
+
+// latin1 decodes the ISO 8859-1 text of a tEXt chunk
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// pngCreationDate returns a Creation Time in RFC 1123 form, as the PNG spec
+// recommends, as RFC 3339 so it reads like the other dates; anything else
+// (exiftool writes "2006:01:02 15:04:05") is returned as it is
+func pngCreationDate(text string) string {
+	text = strings.TrimSpace(text)
+	for _, layout := range []string{time.RFC1123, time.RFC1123Z} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return text
+}
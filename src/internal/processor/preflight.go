@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FSHealth describes the filesystem outputs are written to, as found by
+// CheckOutputFS before a run starts
+type FSHealth struct {
+	Dir           string // Closest existing directory to the output directory
+	Writable      bool
+	WriteErr      error // Why a probe file couldn't be created
+	CaseSensitive bool  // Names differing only in case are distinct files
+	FreeInodes    int64 // Files that can still be created; -1 when unknown
+}
+
+// CheckOutputFS probes the filesystem of dir, or of its closest existing
+// parent when dir is yet to be created, by creating and removing a small
+// file there
+func CheckOutputFS(dir string) FSHealth {
+	if dir == "" {
+		dir = "."
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	h := FSHealth{Dir: dir, CaseSensitive: true, FreeInodes: freeInodes(dir)}
+
+	f, err := os.CreateTemp(dir, ".wappd-preflight-*")
+	if err != nil {
+		h.WriteErr = err
+		return h
+	}
+	probe := f.Name()
+	f.Close()
+	defer os.Remove(probe)
+	h.Writable = true
+
+	// The probe's name is lower case, so finding it under an upper-case
+	// name means the filesystem folds case
+	upper := filepath.Join(dir, strings.ToUpper(filepath.Base(probe)))
+	if a, err := os.Stat(upper); err == nil {
+		if b, err := os.Stat(probe); err == nil && os.SameFile(a, b) {
+			h.CaseSensitive = false
+		}
+	}
+	return h
+}
+
+// Check returns an error explaining why a run can't create the given
+// number of new files here, or nil when it can
+func (h FSHealth) Check(files int) error {
+	if !h.Writable {
+		return fmt.Errorf("output directory %s is not writable: %v (is it a read-only or network mount?)", h.Dir, h.WriteErr)
+	}
+	if h.FreeInodes >= 0 && h.FreeInodes < int64(files) {
+		return fmt.Errorf("output filesystem at %s has room for only %d more file(s), %d needed (free inodes exhausted)", h.Dir, h.FreeInodes, files)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// presets are the option sets shipped with wappd, selected with --preset.
+// They are config file levels of their own, below any config file.
+var presets = map[string]ConfigFile{
+	// Never touch originals or metadata that is already there, stay inside
+	// the given directories and stop at the first failure
+	"safe": {
+		OverrideOriginal: boolPtr(false),
+		OverwriteExif:    boolPtr(false),
+		SafeMode:         boolPtr(true),
+		SkipCorrect:      "1s",
+		MaxFailures:      "0",
+	},
+	// Fix everything in place: rewrite metadata and file times, correct
+	// extensions, date unmatched files from their neighbours and fall back
+	// to exiftool and ffmpeg
+	"aggressive": {
+		OverrideOriginal: boolPtr(true),
+		OverwriteExif:    boolPtr(true),
+		UpdateModified:   boolPtr(true),
+		FixExtensions:    boolPtr(true),
+		InferDates:       boolPtr(true),
+		Backend:          BackendAuto,
+		AllowFFmpeg:      boolPtr(true),
+	},
+	// Copies sorted into year/month folders with portable names, distinct
+	// times and file times to match, for long-term storage
+	"archive": {
+		OverrideOriginal: boolPtr(false),
+		OutputTemplate:   "{out}/{year}/{month}/{name}{ext}",
+		SanitizeNames:    SanitizeWindows,
+		UpdateModified:   boolPtr(true),
+		SpreadTimes:      boolPtr(true),
+		SoftwareTag:      boolPtr(true),
+		SafeMode:         boolPtr(true),
+	},
+}
+
+// boolPtr returns a pointer to b, for config file options
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// PresetNames returns the names of the built-in presets, sorted
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Preset returns the options of the named built-in preset
+func Preset(name string) (*ConfigFile, error) {
+	preset, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+	}
+	return &preset, nil
+}
+
+// ApplyPreset returns config on top of the options of the named built-in
+// preset, so the config file (and any profile applied to it) still wins.
+// config may be nil; the result keeps its targets but no profiles.
+func ApplyPreset(config *ConfigFile, name string) (*ConfigFile, error) {
+	preset, err := Preset(name)
+	if err != nil {
+		return nil, err
+	}
+	result := OverlayConfigFile(preset, config)
+	if config != nil {
+		result.Targets = config.Targets
+	}
+	return result, nil
+}
@@ -1,62 +1,473 @@
 package processor
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/apercova/wappd/scan"
 )
 
 // Config holds all processor configuration
 type Config struct {
-	UpdateModified   bool
-	OverwriteExif    bool
-	OverrideOriginal bool
-	OutputDir        string
-	InputDir         string
-	Verbose          bool
-	DryRun           bool
+	UpdateModified       bool
+	OverwriteExif        bool
+	OverrideOriginal     bool
+	OutputDir            string
+	OutputTemplate       string // Output path per file, e.g. "{out}/{year}/{name}{ext}" ("" = from -o/-out)
+	SanitizeNames        string // Make output names valid on another filesystem: windows or macos ("" = as is)
+	InputDir             string
+	Verbose              bool
+	DryRun               bool
+	ManifestPath         string
+	AuditLog             string // Append a hash-chained JSON line per changed file to this log (see AppendAuditLog)
+	Backend              string
+	AllowFFmpeg          bool
+	Timezone             string   // IANA zone filename times are local to ("" = UTC)
+	Offset               string   // Clock skew correction added to every date, e.g. "+2h30m"
+	IncludeDocuments     bool     // Also process WhatsApp documents (DOC-*.pdf etc.)
+	Stickers             string   // Sticker handling: skip, mtime or process ("" = skip)
+	FixExtensions        bool     // Rename files whose content doesn't match their extension
+	MaxFailures          string   // Abort the batch after this many failures, e.g. "5" or "10%" ("" = never)
+	Strict               bool     // Refuse to process a batch containing filenames no pattern matches
+	IgnoreUnmatched      bool     // Skip filenames no pattern matches instead of failing them
+	TagSent              bool     // Record SentComment in the metadata of files under a Sent folder
+	Tags                 []string // XMP dc:subject keywords added wherever metadata is written
+	SoftwareTag          bool     // Name wappd and its version in the EXIF Software tag it creates
+	InferDates           bool     // Date unmatched files from their matched neighbors (see InferDates)
+	SpreadTimes          bool     // Spread identical dates one second apart (see DisambiguateTimes)
+	SafeMode             bool     // Refuse outputs that resolve (through symlinks) outside InputDir and OutputDir
+	Chown                string   // Give outputs to "user:group" (see ParseOwner; "" = keep the input's owner where permitted)
+	SkipCorrect          string   // Don't rewrite metadata already within this tolerance of the date, e.g. "1s" ("" = always write)
+	NormalizeOrientation bool     // Rotate JPEG pixels to match their EXIF Orientation and reset it to 1
+	Settle               string   // Wait this long and defer files still changing, e.g. "2s" ("" = don't wait)
+	Writers              []string // Writers allowed to change files, e.g. jpegExif, mtime (see ValidateWriters; empty = all)
+	VideoAtoms           []string // MP4 atoms videos and M4A files get the date in, e.g. mvhd, day (see ValidateVideoAtoms; empty = mvhd and tkhd, plus day for M4A)
+	ZeroMvhd             string   // What an mvhd creation time of 0 or invalid counts as: missing or existing ("" = rewrite every mvhd)
+	DatePolicy           string   // Date to keep when the embedded one differs: earliest, filename or existing ("" = see OverwriteExif)
+	GPS                  string   // Approximate position for JPEGs, "lat,lon" in decimal degrees (see ParseGPS)
+	GPX                  string   // GPX or KML track giving JPEGs the position nearest their date (see LoadTrack)
+	NoCopy               bool     // Fail files whose output would be a copy rather than the original
+	MtimeOnly            bool     // Only set file times from the dates, never write metadata (see setBirthTime)
+	MemoryLimit          string   // Cap the file buffers held at once, e.g. "256MiB" (see ParseMemoryLimit; "" = no cap)
+	SidecarOnly          bool     // Never modify media: write the dates to XMP and JSON sidecars instead (see writeSidecars)
+	RenameMap            string   // With SidecarOnly, write the renames the run would have made to this CSV (see WriteRenameMap)
+
+	// Filename patterns tried before the built-in WhatsApp ones
+	Patterns []FilenamePattern
+
+	// Optional built-in pattern sets tried after the WhatsApp ones, e.g.
+	// "camera" (see ValidateExtraPatterns)
+	ExtraPatterns []string
+
+	// Extensions added to ("heic") or left out of ("-gif") the ones a
+	// directory scan finds (see scan.ValidateExtensions)
+	Extensions []string
+
+	// Directory levels a scan looks in: 1 = the directory's own files only
+	// (0 = no limit)
+	MaxDepth int
+
+	// Scan into symlinked directories (each real directory once)
+	FollowSymlinks bool
+
+	// Names of directories a scan leaves out, with * and ? wildcards, e.g.
+	// ".Statuses" (see scan.ValidateSkipNames)
+	SkipDirs []string
+
+	// Also scan for files whose content is a known container, whatever
+	// their extension (e.g. extensionless files recovered from an SD card)
+	DetectContent bool
+
+	// Print how long each file spends in each stage (read, parse, copy,
+	// write, chtimes)
+	Timings bool
 }
 
 // ProcessResult holds the result of processing a single file
 type ProcessResult struct {
-	InputFile  string
-	OutputFile string
-	Success    bool
-	Error      error
+	InputFile   string
+	OutputFile  string
+	Success     bool
+	Error       error
+	DateTime    time.Time
+	PreHash     string
+	PostHash    string
+	Backend     string    // Metadata backend that handled the file
+	Skipped     bool      // File was deliberately left untouched
+	SkipReason  string    // Why the file was skipped
+	ActualExt   string    // Extension matching the content, when the filename's is wrong
+	ProcessedAt time.Time // When processing finished, from the processor's clock
+	Sent        bool      // File is under a Sent folder (only with TagSent)
+	Inferred    bool      // Date was inferred from neighboring files, not read from the name
+	Sanitized   bool      // Output name was changed to be valid under SanitizeNames
+
+	// Embedded date already matched (SkipCorrect) or was kept (DatePolicy),
+	// so the metadata wasn't rewritten; the file may still have been copied
+	// and its time set
+	AlreadyCorrect bool
+
+	// DatePolicy chose the date already embedded over the filename's
+	KeptEmbedded bool
+
+	// DatePolicy chose the filename's date over one already embedded, which
+	// the writers overwrite as with OverwriteExif
+	ReplacedEmbedded bool
+
+	// MvhdZero or MvhdInvalid when the video's mvhd creation time held no
+	// real date before processing
+	MvhdUnset string
+
+	// The atoms a video or M4A file was dated in, when Config.VideoAtoms
+	// chose them (nil = the defaults), so verify compares those headers
+	VideoAtoms []string
+
+	// Wraps ErrExifExists when the writer kept the date already in the file
+	// because OverwriteExif is off; the file still counts as a success
+	Kept error
+
+	// With Kept: the date left in the file, when it can be read back
+	KeptDate time.Time
+
+	// Modification time of the input before it was processed (not set in
+	// dry-run mode), so restore-times can put it back
+	OriginalModTime time.Time
+
+	// With SidecarOnly: the sidecars written (not in dry-run mode) and the
+	// path the file would have been written to; OutputFile is the untouched
+	// input
+	Sidecars    []string
+	PlannedPath string
+
+	// How long each stage took (only with Timings)
+	Timings *StageTimings
 }
 
 // Processor handles file processing
 type Processor struct {
-	config Config
+	config      Config
+	location    *time.Location
+	locationErr error
+	offset      time.Duration
+	offsetErr   error
+	patterns    []*compiledPattern
+	patternsErr error
+	extras      []*compiledPattern // ExtraPatterns sets, sharing patternsErr
+	maxFailures *FailureLimit
+	maxFailErr  error
+	owner       *Owner
+	ownerErr    error
+	correctTol  time.Duration // -1 when SkipCorrect is off
+	correctErr  error
+	policyErr   error
+	zeroErr     error
+	writersErr  error
+	atomsErr    error
+	stages      map[Stage][]StageFunc // Custom stages added with WithStage
+	stagesErr   error
+	gps         *GPSPoint
+	gpsTrack    *Track
+	gpsErr      error
+	settle      time.Duration
+	settleErr   error
+	memoryLimit int64 // Bytes; 0 without a MemoryLimit
+	memoryErr   error
+	aborted     int32 // Set atomically once ProcessFiles exceeds maxFailures
+	concurrency int
+	logger      *log.Logger
+	clock       Clock
+	fsys        FS
+	journal     *Journal
+	tx          *Transaction
+	audit       *AuditLog
+	claims      *outputClaims
+	inferred    map[string]string        // Dates inferred for the current ProcessFiles batch
+	shifts      map[string]time.Duration // Shifts spreading identical dates of the batch
+
+	// Optional hooks for embedding applications, e.g. to show progress.
+	// They are called from ProcessFile, concurrently when ProcessFiles runs
+	// with WithConcurrency > 1.
+	OnFileStart func(filePath string)            // Before a file is processed
+	OnFileDone  func(result ProcessResult)       // After every file, whatever the outcome
+	OnError     func(filePath string, err error) // After a file fails
+
+	// OnCopyProgress is called while a file is copied to its output path
+	// (not when editing originals in place), with the bytes copied so far
+	// and the file size
+	OnCopyProgress func(filePath string, copied, total int64)
 }
 
-// New creates a new Processor
-func New(config Config) *Processor {
-	return &Processor{config: config}
+// New creates a new Processor from config, adjusted by any options
+func New(config Config, opts ...Option) *Processor {
+	p := &Processor{config: config, concurrency: 1, clock: SystemClock, fsys: OSFS, claims: &outputClaims{paths: map[string]string{}}}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.location, p.locationErr = LoadTimezone(p.config.Timezone)
+	p.offset, p.offsetErr = ParseClockOffset(p.config.Offset)
+	p.patterns, p.patternsErr = compilePatterns(p.config.Patterns)
+	if p.patternsErr == nil {
+		p.extras, p.patternsErr = compileExtraPatterns(p.config.ExtraPatterns)
+	}
+	p.maxFailures, p.maxFailErr = ParseFailureLimit(p.config.MaxFailures)
+	p.owner, p.ownerErr = ParseOwner(p.config.Chown)
+	p.correctTol, p.correctErr = ParseCorrectTolerance(p.config.SkipCorrect)
+	p.policyErr = ValidateDatePolicy(p.config.DatePolicy)
+	p.zeroErr = ValidateZeroMvhd(p.config.ZeroMvhd)
+	p.writersErr = ValidateWriters(p.config.Writers)
+	p.atomsErr = ValidateVideoAtoms(p.config.VideoAtoms)
+	p.gps, p.gpsErr = ParseGPS(p.config.GPS)
+	if p.gpsErr == nil {
+		p.gpsTrack, p.gpsErr = LoadTrack(p.config.GPX)
+	}
+	p.settle, p.settleErr = ParseSettleTime(p.config.Settle)
+	p.memoryLimit, p.memoryErr = ParseMemoryLimit(p.config.MemoryLimit)
+	return p
 }
 
-// ProcessFiles processes multiple files and returns results
+// ProcessFiles processes multiple files and returns results in input order.
+// Once more files fail than MaxFailures allows, the remaining files are not
+// processed and come back skipped with SkipReasonAborted. With Settle,
+// files still being written are retried after the others, and skipped with
+// SkipReasonGrowing if they keep changing. With MemoryLimit, only as many
+// files run concurrently as their buffers fit in it.
 func (p *Processor) ProcessFiles(filePaths []string) []ProcessResult {
-	results := make([]ProcessResult, 0, len(filePaths))
+	results := make([]ProcessResult, len(filePaths))
+	for _, err := range []error{p.maxFailErr, p.settleErr, p.memoryErr} {
+		if err != nil {
+			for i, filePath := range filePaths {
+				results[i] = ProcessResult{InputFile: filePath, Error: err}
+			}
+			return results
+		}
+	}
 
-	for _, filePath := range filePaths {
-		result := p.ProcessFile(filePath)
-		results = append(results, result)
+	atomic.StoreInt32(&p.aborted, 0)
+	p.inferred = nil
+	if p.config.InferDates {
+		p.inferred = p.inferDates(filePaths)
+	}
+	p.shifts = nil
+	if p.config.SpreadTimes {
+		p.shifts = DisambiguateTimes(p.batchDates(filePaths))
+	}
+	b := p.newBatch(len(filePaths))
+	process := func(i int) {
+		results[i] = p.processInBatch(b, filePaths[i])
 	}
 
+	pending := make([]int, len(filePaths))
+	for i := range pending {
+		pending[i] = i
+	}
+	if p.settle <= 0 {
+		p.runBatch(pending, process)
+		return results
+	}
+	for round := 0; len(pending) > 0; round++ {
+		stable, growing := p.settled(filePaths, pending)
+		p.runBatch(stable, process)
+		if round == settleRounds {
+			for _, i := range growing {
+				results[i] = ProcessResult{InputFile: filePaths[i], Skipped: true, SkipReason: SkipReasonGrowing}
+			}
+			break
+		}
+		if len(growing) > 0 && p.config.Verbose {
+			p.logf("  %d file(s) still being written, checking again in %s\n", len(growing), p.settle)
+		}
+		pending = growing
+	}
 	return results
 }
 
+// batch is the state the files of one ProcessFiles or ProcessStream call
+// share
+type batch struct {
+	budget      *memBudget
+	maxFailures int64 // Failures allowed before the rest is skipped (-1 = no limit)
+	failures    int64
+}
+
+// newBatch starts a batch of total files (total only matters to percentage
+// failure limits)
+func (p *Processor) newBatch(total int) *batch {
+	b := &batch{maxFailures: int64(p.maxFailures.Max(total))}
+	if p.memoryLimit > 0 && p.concurrency > 1 {
+		b.budget = newMemBudget(p.memoryLimit)
+	}
+	return b
+}
+
+// processInBatch processes a file of a batch: skipped once the batch has
+// aborted, within its memory budget, counting towards its failure limit
+func (p *Processor) processInBatch(b *batch, filePath string) ProcessResult {
+	if atomic.LoadInt32(&p.aborted) != 0 {
+		return ProcessResult{InputFile: filePath, Skipped: true, SkipReason: SkipReasonAborted}
+	}
+	if b.budget != nil {
+		n := b.budget.acquire(p.memoryCost(filePath))
+		defer b.budget.release(n)
+	}
+	result := p.ProcessFile(filePath)
+	if result.Error != nil && b.maxFailures >= 0 && atomic.AddInt64(&b.failures, 1) > b.maxFailures {
+		atomic.StoreInt32(&p.aborted, 1)
+	}
+	return result
+}
+
+// runBatch calls process for each of indices, on as many goroutines as the
+// processor's concurrency allows
+func (p *Processor) runBatch(indices []int, process func(int)) {
+	if p.concurrency <= 1 {
+		for _, i := range indices {
+			process(i)
+		}
+		return
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < p.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				process(i)
+			}
+		}()
+	}
+	for _, i := range indices {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Aborted reports whether the last ProcessFiles call stopped early because
+// too many files failed
+func (p *Processor) Aborted() bool {
+	return atomic.LoadInt32(&p.aborted) != 0
+}
+
+// Now returns the current time according to the processor's clock
+func (p *Processor) Now() time.Time {
+	return p.clock.Now()
+}
+
+// track records a temporary file in the run's journal, if there is one.
+// Only OS files are journaled: cleanup happens on disk.
+func (p *Processor) track(path string) {
+	if p.journal == nil || !isOSFS(p.fsys) {
+		return
+	}
+	if err := p.journal.Add(path); err != nil && p.config.Verbose {
+		p.logf("  Warning: %v\n", err)
+	}
+}
+
+// trackEdit records an original about to be edited in place in the run's
+// journal, with its size, so a crash mid-write can be found and repaired
+func (p *Processor) trackEdit(path string) {
+	if p.journal == nil || !isOSFS(p.fsys) {
+		return
+	}
+	info, err := p.fsys.Stat(path)
+	if err != nil {
+		return
+	}
+	if err := p.journal.Edit(path, info.Size()); err != nil && p.config.Verbose {
+		p.logf("  Warning: %v\n", err)
+	}
+}
+
+// untrack removes a finished temporary file (or edit) from the run's journal
+func (p *Processor) untrack(path string) {
+	if p.journal == nil || !isOSFS(p.fsys) {
+		return
+	}
+	if err := p.journal.Done(path); err != nil && p.config.Verbose {
+		p.logf("  Warning: %v\n", err)
+	}
+}
+
+// logf prints verbose output to the configured logger or standard output
+func (p *Processor) logf(format string, args ...any) {
+	if p.logger != nil {
+		p.logger.Printf(format, args...)
+		return
+	}
+	fmt.Printf(format, args...)
+}
+
 // ProcessFile processes a single file
 func (p *Processor) ProcessFile(filePath string) ProcessResult {
+	if p.OnFileStart != nil {
+		p.OnFileStart(filePath)
+	}
+
+	var timer *stageTimer
+	if p.config.Timings {
+		timer = newStageTimer()
+	}
+	result := p.processFile(filePath, timer)
+	result.ProcessedAt = p.clock.Now()
+	if result.Timings = timer.done(); result.Timings != nil {
+		p.logf("  Timing %s: %s\n", filepath.Base(filePath), result.Timings)
+	}
+	if p.audit != nil && result.Success && !p.config.DryRun {
+		if _, err := p.audit.Append(result, result.ProcessedAt); err != nil {
+			result.Success = false
+			result.Error = fmt.Errorf("failed to record the change in the audit log: %w", err)
+		}
+	}
+
+	if result.Error != nil && p.OnError != nil {
+		p.OnError(filePath, result.Error)
+	}
+	if p.OnFileDone != nil {
+		p.OnFileDone(result)
+	}
+	return result
+}
+
+// processFile does the work of ProcessFile, without the hooks, attributing
+// the time it takes to timer's stages
+func (p *Processor) processFile(filePath string, timer *stageTimer) ProcessResult {
 	result := ProcessResult{InputFile: filePath}
 
+	// Stickers aren't photos: leave them alone unless configured otherwise
+	sticker := IsSticker(filePath)
+	if sticker && (p.config.Stickers == "" || p.config.Stickers == StickersSkip) {
+		result.Skipped = true
+		result.SkipReason = "sticker"
+		return result
+	}
+
 	// Extract date from filename
-	dateStr, err := ExtractDateFromFilename(filepath.Base(filePath))
+	if p.patternsErr != nil {
+		result.Error = p.patternsErr
+		return result
+	}
+	_, dateStr, location, err := p.matchFilename(filepath.Base(filePath))
+	if inferred, ok := p.inferred[filePath]; ok && errors.Is(err, ErrNoPatternMatch) {
+		dateStr, err = inferred, nil
+		result.Inferred = true
+	}
 	if err != nil {
+		if p.config.IgnoreUnmatched && errors.Is(err, ErrNoPatternMatch) {
+			result.Skipped = true
+			result.SkipReason = SkipReasonUnmatched
+			return result
+		}
 		result.Error = err
 		return result
 	}
@@ -68,81 +479,471 @@ func (p *Processor) ProcessFile(filePath string) ProcessResult {
 		return result
 	}
 
+	// Interpret the filename's wall-clock time in the configured timezone
+	if p.locationErr != nil {
+		result.Error = p.locationErr
+		return result
+	}
+	if location != nil {
+		parsedDateTime = ResolveLocalTime(parsedDateTime, location)
+	}
+
+	// Correct for a phone clock that was off
+	if p.offsetErr != nil {
+		result.Error = p.offsetErr
+		return result
+	}
+	parsedDateTime = parsedDateTime.Add(p.offset)
+	if p.ownerErr != nil {
+		result.Error = p.ownerErr
+		return result
+	}
+	if p.correctErr != nil {
+		result.Error = p.correctErr
+		return result
+	}
+	if p.policyErr != nil {
+		result.Error = p.policyErr
+		return result
+	}
+	if p.gpsErr != nil {
+		result.Error = p.gpsErr
+		return result
+	}
+	if p.zeroErr != nil {
+		result.Error = p.zeroErr
+		return result
+	}
+	if p.writersErr != nil {
+		result.Error = p.writersErr
+		return result
+	}
+	if p.atomsErr != nil {
+		result.Error = p.atomsErr
+		return result
+	}
+	if p.stagesErr != nil {
+		result.Error = p.stagesErr
+		return result
+	}
+
+	// Keep files that share a date apart, in name order
+	parsedDateTime = parsedDateTime.Add(p.shifts[filePath])
+
+	// Keep an embedded date that differs when DatePolicy says so
+	parsedDateTime, result.KeptEmbedded, result.ReplacedEmbedded = p.applyDatePolicy(filePath, parsedDateTime)
+
+	// Let custom extract stages adjust the date
+	stage := &FileContext{InputPath: filePath, DateTime: parsedDateTime, DryRun: p.config.DryRun}
+	if err := p.runStage(StageExtract, stage); err != nil {
+		result.Error = err
+		return result
+	}
+	parsedDateTime = stage.DateTime
+	result.DateTime = parsedDateTime
+
+	// Content-addressed names need the input's hash; it's kept as the
+	// pre-hash so the file isn't read twice
+	inputHash := ""
+	if p.hashesNames() {
+		if inputHash, err = hashFile(p.fsys, filePath); err != nil {
+			result.Error = fmt.Errorf("failed to hash input file: %v", err)
+			return result
+		}
+	}
+
 	// Determine output path
-	outputPath, err := p.determineOutputPath(filePath, p.config.OutputDir)
+	outputPath, err := p.determineOutputPath(filePath, inputHash, parsedDateTime)
 	if err != nil {
 		result.Error = err
 		return result
 	}
 
-	// In dry-run mode, skip all file operations
+	// Detect containers saved under the wrong extension (WhatsApp "GIFs"
+	// that are really MP4s) and optionally give the output the right one
+	replacesOriginal := outputPath == filePath
+	if actual := detectContainerMismatch(p.fsys, filePath); actual != "" {
+		result.ActualExt = actual
+		if p.config.FixExtensions {
+			outputPath = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + actual
+			if _, err := p.fsys.Stat(outputPath); err == nil {
+				result.Error = fmt.Errorf("cannot rename to %s: file already exists", filepath.Base(outputPath))
+				return result
+			}
+		}
+	}
+
+	// Keep the output name valid on the filesystem it will be moved to
+	if sanitized := p.sanitizeOutputPath(outputPath, filePath); sanitized != outputPath && sanitized != filepath.Clean(outputPath) {
+		outputPath = sanitized
+		result.Sanitized = true
+	}
+
+	// Let custom transform stages move the output. A moved output goes
+	// through the same checks as the template's (and the sandbox below),
+	// and only replaces the original if it is the original.
+	stage.OutputPath = outputPath
+	if err := p.runStage(StageTransform, stage); err != nil {
+		result.Error = err
+		return result
+	}
+	if stage.OutputPath != outputPath {
+		if outputPath, err = p.checkOutputPath(filePath, filepath.Clean(stage.OutputPath)); err != nil {
+			result.Error = fmt.Errorf("%s stage: %v", StageTransform, err)
+			return result
+		}
+		replacesOriginal = outputPath == filePath
+	}
+
+	// Don't follow symlinks out of the directories the run was given
+	if err := p.checkSandbox(outputPath); err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Keep files of the run from writing the same output
+	claimed, holder := p.claimOutput(outputPath, filePath)
+	if claimed == "" {
+		result.Skipped = true
+		result.SkipReason = fmt.Sprintf("%s %s", SkipReasonDuplicateOutput, holder)
+		return result
+	}
+	outputPath = claimed
+
+	// Media under a Sent folder was sent by the phone's owner
+	comment := ""
+	if p.config.TagSent && IsSent(filePath) {
+		result.Sent = true
+		comment = SentComment
+	}
+
+	// Leave metadata that already holds the date alone, as when DatePolicy
+	// kept it (stickers in mtime mode, and every file with MtimeOnly, get no
+	// metadata anyway)
+	mtimeOnly := p.config.MtimeOnly || sticker && p.config.Stickers == StickersMtime
+	keptOnly := result.KeptEmbedded && comment == "" && len(p.config.Tags) == 0 && !p.config.SoftwareTag && !p.config.NormalizeOrientation && p.gpsAt(parsedDateTime) == nil
+	if !mtimeOnly && (keptOnly || p.alreadyCorrect(filePath, parsedDateTime, comment)) {
+		result.AlreadyCorrect = true
+	}
+
+	// Videos whose mvhd never got a real date are reported distinctly
+	_, result.MvhdUnset = p.mvhdState(filePath)
+
+	// Custom validate stages get the last word before anything is written
+	if err := p.runStage(StageValidate, stage); err != nil {
+		result.Error = err
+		return result
+	}
+	timer.lap(stageParse)
+
+	// SidecarOnly leaves the media bytes and times alone: the date goes into
+	// sidecars, and the output path only into the rename map
+	if p.config.SidecarOnly {
+		result.OutputFile = filePath
+		result.PlannedPath = outputPath
+		if !p.config.DryRun {
+			hash := inputHash
+			if hash == "" {
+				if hash, err = hashFile(p.fsys, filePath); err != nil {
+					result.Error = fmt.Errorf("failed to hash input file: %v", err)
+					return result
+				}
+			}
+			if p.hashes() {
+				result.PreHash, result.PostHash = hash, hash
+			}
+			if result.Sidecars, err = p.writeSidecars(filePath, hash, outputPath, parsedDateTime, result, comment); err != nil {
+				result.Error = err
+				return result
+			}
+		}
+		result.Success = true
+		return result
+	}
+
+	// In dry-run mode, skip all file operations, but predict whether the
+	// metadata write would fail
 	if p.config.DryRun {
 		result.OutputFile = outputPath
+		if !mtimeOnly && !result.AlreadyCorrect {
+			backend, err := p.rehearseWrite(filePath, parsedDateTime, comment, result.ReplacedEmbedded)
+			result.Backend = backend
+			if err != nil {
+				result.Error = err
+				return result
+			}
+		}
 		result.Success = true
 		return result
 	}
 
-	// If output dir differs from input, ensure it exists
-	if p.config.OutputDir != "" {
-		if err := os.MkdirAll(p.config.OutputDir, 0755); err != nil {
+	// Keep other wappd runs off the file while it is read and rewritten
+	if isOSFS(p.fsys) {
+		lock, err := LockFile(filePath)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		defer lock.Unlock()
+	}
+
+	// Remember the file time that processing (or -m) is about to change
+	if info, err := p.fsys.Stat(filePath); err == nil {
+		result.OriginalModTime = info.ModTime()
+	}
+
+	// Hash the original bytes before anything is touched
+	if p.hashes() {
+		result.PreHash = inputHash
+		if result.PreHash == "" {
+			if result.PreHash, err = hashFile(p.fsys, filePath); err != nil {
+				result.Error = fmt.Errorf("failed to hash input file: %v", err)
+				return result
+			}
+		}
+	}
+	timer.lap(stageRead)
+
+	// In a transaction the output is written to the staging area and only
+	// moved into place (replacing a renamed original) when the batch commits
+	finalPath := outputPath
+	if p.tx != nil {
+		replaces := ""
+		if replacesOriginal && outputPath != filePath {
+			replaces = filePath
+		}
+		outputPath = p.tx.Stage(finalPath, replaces)
+		// A failed file must not be committed with the rest
+		defer func() {
+			if !result.Success {
+				p.fsys.Remove(outputPath)
+			}
+		}()
+	}
+
+	// Ensure the output's directory exists (templates may add subdirectories)
+	if outputPath != filePath && p.tx == nil {
+		if err := mkdirAllOwned(p.fsys, filepath.Dir(outputPath), p.owner); err != nil {
 			result.Error = fmt.Errorf("failed to create output directory: %v", err)
 			return result
 		}
 	}
 
-	// Copy file to output location if different
+	// Copy file to output location if different. The copy is journaled
+	// while it is being written, so a crash doesn't leave it half-done.
 	if outputPath != filePath {
-		if err := copyFile(filePath, outputPath); err != nil {
+		p.track(outputPath)
+		defer p.untrack(outputPath)
+		if err := copyFile(p.fsys, filePath, outputPath, p.copyProgress(filePath)); err != nil {
 			result.Error = fmt.Errorf("failed to copy file: %v", err)
 			return result
 		}
 	}
+	timer.lap(stageCopy)
 
-	// Update EXIF data
-	if err := updateExifData(outputPath, parsedDateTime, p.config); err != nil {
-		// Attempt cleanup on failure
-		if outputPath != filePath {
-			os.Remove(outputPath)
+	// Update EXIF data (stickers in mtime mode only get the file time)
+	if !mtimeOnly && !result.AlreadyCorrect {
+		// An original edited in place is journaled until the write is done
+		if outputPath == filePath {
+			p.trackEdit(outputPath)
+			defer p.untrack(outputPath)
+		}
+
+		// Keep the pre-write bytes to validate against and restore from.
+		// Large videos patched through a memory mapping only get their
+		// header dates rewritten, so they aren't buffered.
+		var before []byte
+		if !p.patchesMapped(outputPath) {
+			var err error
+			if before, err = p.fsys.ReadFile(outputPath); err != nil {
+				result.Error = fmt.Errorf("failed to read file: %v", err)
+				return result
+			}
+		}
+		timer.lap(stageRead)
+
+		backend, err := p.updateExifData(outputPath, parsedDateTime, comment, result.ReplacedEmbedded)
+		result.Backend = backend
+		if backend == BackendNative && len(p.config.VideoAtoms) > 0 {
+			ext := result.ActualExt
+			if ext == "" {
+				ext = strings.ToLower(filepath.Ext(outputPath))
+			}
+			if isMovieFormat(ext) || ext == ".m4a" {
+				result.VideoAtoms = p.config.VideoAtoms
+			}
+		}
+		if errors.Is(err, ErrExifExists) {
+			result.Kept, err = err, nil
+			if kept, ok := p.embeddedDate(outputPath, parsedDateTime); ok {
+				result.KeptDate = kept.In(parsedDateTime.Location())
+			}
+		}
+		if err != nil {
+			// Attempt cleanup on failure
+			if outputPath != filePath {
+				p.fsys.Remove(outputPath)
+			}
+			result.Error = fmt.Errorf("failed to update EXIF data: %w", err)
+			return result
+		}
+
+		// Make sure the writer didn't break the file; undo the write if it did
+		if before != nil {
+			if err := p.validateOutput(outputPath, before); err != nil {
+				result.Error = err
+				return result
+			}
 		}
-		result.Error = fmt.Errorf("failed to update EXIF data: %v", err)
-		return result
 	}
+	timer.lap(stageWrite)
 
-	// Update file modification time if requested
-	if p.config.UpdateModified {
-		if err := os.Chtimes(outputPath, parsedDateTime, parsedDateTime); err != nil {
+	// Update file modification time if requested. For documents (and
+	// stickers in mtime mode) the file time is the only date to fix, so it
+	// is always updated.
+	isDocument := p.config.IncludeDocuments && scan.IsDocument(strings.ToLower(filepath.Ext(filePath)))
+	if (p.config.UpdateModified || isDocument || mtimeOnly) && p.writerEnabled(WriterMtime) {
+		if err := p.fsys.Chtimes(outputPath, parsedDateTime, parsedDateTime); err != nil {
 			result.Error = fmt.Errorf("failed to update modification time: %v", err)
 			return result
 		}
+		if p.config.MtimeOnly && isOSFS(p.fsys) {
+			if err := setBirthTime(outputPath, parsedDateTime); err != nil {
+				result.Error = fmt.Errorf("failed to update creation time: %v", err)
+				return result
+			}
+		}
+	}
+	timer.lap(stageChtimes)
+
+	// Hand the output to the --chown user (staged outputs keep it on commit)
+	if p.owner != nil && isOSFS(p.fsys) {
+		if err := p.owner.chown(outputPath); err != nil {
+			result.Error = err
+			return result
+		}
 	}
 
-	result.OutputFile = outputPath
+	// Run custom write stages on the written output; a failed stage doesn't
+	// leave a copy behind (changes to an original in place stay)
+	stage.OutputPath = outputPath
+	if err := p.runStage(StageWrite, stage); err != nil {
+		if outputPath != filePath && p.tx == nil {
+			p.fsys.Remove(outputPath)
+		}
+		result.Error = err
+		return result
+	}
+
+	// Hash the written bytes for the manifest and audit log
+	if p.hashes() {
+		postHash, err := hashFile(p.fsys, outputPath)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to hash output file: %v", err)
+			return result
+		}
+		result.PostHash = postHash
+	}
+	timer.lap(stageRead)
+
+	// Custom verify stages run before a renamed original is removed
+	if err := p.runStage(StageVerify, stage); err != nil {
+		if outputPath != filePath && p.tx == nil {
+			p.fsys.Remove(outputPath)
+		}
+		result.Error = err
+		return result
+	}
+
+	// A renamed copy replaces the original when overriding originals
+	if replacesOriginal && outputPath != filePath && p.tx == nil {
+		if err := p.fsys.Remove(filePath); err != nil {
+			result.Error = fmt.Errorf("failed to remove original after renaming: %v", err)
+			return result
+		}
+	}
+
+	result.OutputFile = finalPath
 	result.Success = true
 	return result
 }
 
+// hashes reports whether inputs and outputs are hashed, for the manifest or
+// the audit log
+func (p *Processor) hashes() bool {
+	return p.config.ManifestPath != "" || p.config.AuditLog != ""
+}
+
+// validateOutput re-decodes a written file and compares it with its
+// pre-write bytes, restoring them if the write corrupted the file
+func (p *Processor) validateOutput(path string, before []byte) error {
+	after, err := p.fsys.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read written file: %v", err)
+	}
+	if bytes.Equal(before, after) {
+		return nil
+	}
+	verr := validateWrite(before, after)
+	if verr == nil {
+		return nil
+	}
+
+	info, err := p.fsys.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v (restore failed: %v)", ErrWriteValidation, verr, err)
+	}
+	if err := p.fsys.WriteFile(path, before, info.Mode()); err != nil {
+		return fmt.Errorf("%w: %v (restore failed: %v)", ErrWriteValidation, verr, err)
+	}
+	return fmt.Errorf("%w: %v (original restored)", ErrWriteValidation, verr)
+}
+
+// SkipReasonUnmatched is the SkipReason of files skipped with IgnoreUnmatched
+const SkipReasonUnmatched = "no filename pattern matched"
+
+// SkipReasonDuplicateOutput starts the SkipReason of files whose {hash}
+// output is already written by an identical file of the run, which follows
+const SkipReasonDuplicateOutput = "identical to"
+
+// defaultPattern is a built-in WhatsApp filename pattern
+type defaultPattern struct {
+	regex     *regexp.Regexp
+	dateGroup int
+	timeGroup int
+	converter func(string, string) string
+}
+
+// defaultPatterns are the WhatsApp filename patterns, tried in order. They
+// aren't anchored, so the counters and suffixes copies get ("-WA0000 1",
+// "-WA0012(1)", "-edited") and the counters of WhatsApp Business exports
+// ("-WABusiness0001") still match.
+var defaultPatterns = []defaultPattern{
+	{regexp.MustCompile(`IMG-(\d{8})-WA`), 1, 0, func(d, t string) string { ds, _ := convertDateFormat(d); return ds }},
+	{regexp.MustCompile(`VID-(\d{8})-WA`), 1, 0, func(d, t string) string { ds, _ := convertDateFormat(d); return ds }},
+	{regexp.MustCompile(`PTT-(\d{8})-WA`), 1, 0, func(d, t string) string { ds, _ := convertDateFormat(d); return ds }},
+	{regexp.MustCompile(`AUD-(\d{8})-WA`), 1, 0, func(d, t string) string { ds, _ := convertDateFormat(d); return ds }},
+	{regexp.MustCompile(`DOC-(\d{8})-WA`), 1, 0, func(d, t string) string { ds, _ := convertDateFormat(d); return ds }},
+	{regexp.MustCompile(`STK-(\d{8})-WA`), 1, 0, func(d, t string) string { ds, _ := convertDateFormat(d); return ds }},
+	{regexp.MustCompile(`WhatsApp (?:Business )?Image (\d{4}-\d{2}-\d{2}) at (\d{1,2}\.\d{2}\.\d{2}) (AM|PM)`), 1, 2, func(d, t string) string { return convertDateTimeFormat(d, t) }},
+	{regexp.MustCompile(`WhatsApp (?:Business )?Video (\d{4}-\d{2}-\d{2}) at (\d{1,2}\.\d{2}\.\d{2}) (AM|PM)`), 1, 2, func(d, t string) string { return convertDateTimeFormat(d, t) }},
+}
+
 // ExtractDateFromFilename extracts date using default WhatsApp patterns
 func ExtractDateFromFilename(filename string) (string, error) {
-	// Remove extension for pattern matching
-	nameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
-
-	// Try default patterns
-	patterns := []struct {
-		regex     string
-		dateGroup int
-		timeGroup int
-		timeFormat string
-		converter func(string, string) string
-	}{
-		{`IMG-(\d{8})-WA`, 1, 0, "", func(d, t string) string { ds, _ := convertDateFormat(d); return ds }},
-		{`VID-(\d{8})-WA`, 1, 0, "", func(d, t string) string { ds, _ := convertDateFormat(d); return ds }},
-		{`WhatsApp Image (\d{4}-\d{2}-\d{2}) at (\d{1,2}\.\d{2}\.\d{2}) (AM|PM)`, 1, 2, "3.04.05 PM", func(d, t string) string { return convertDateTimeFormat(d, t) }},
-		{`WhatsApp Video (\d{4}-\d{2}-\d{2}) at (\d{1,2}\.\d{2}\.\d{2}) (AM|PM)`, 1, 2, "3.04.05 PM", func(d, t string) string { return convertDateTimeFormat(d, t) }},
-	}
-
-	for _, pat := range patterns {
-		re := regexp.MustCompile(pat.regex)
-		matches := re.FindStringSubmatch(nameWithoutExt)
+	_, date, err := MatchDefaultPattern(filename)
+	return date, err
+}
+
+// MatchDefaultPattern returns the default WhatsApp pattern matching
+// filename and the date it extracts
+func MatchDefaultPattern(filename string) (pattern, date string, err error) {
+	// Remove extension for pattern matching, and compose characters a
+	// macOS sync decomposed
+	nameWithoutExt := NormalizeNFC(strings.TrimSuffix(filename, filepath.Ext(filename)))
+
+	for _, pat := range defaultPatterns {
+		matches := pat.regex.FindStringSubmatch(nameWithoutExt)
 		if len(matches) > pat.dateGroup {
 			dateStr := matches[pat.dateGroup]
 			timeStr := ""
@@ -152,11 +953,33 @@ func ExtractDateFromFilename(filename string) (string, error) {
 					timeStr += " " + matches[pat.timeGroup+1]
 				}
 			}
-			return pat.converter(dateStr, timeStr), nil
+			return pat.regex.String(), pat.converter(dateStr, timeStr), nil
 		}
 	}
 
-	return "", fmt.Errorf("no default pattern matched filename: %s", filename)
+	return "", "", fmt.Errorf("%w: %s", ErrNoPatternMatch, filename)
+}
+
+// UnmatchedFiles returns the files whose names no default pattern matches,
+// leaving out stickers the processor would skip anyway
+func (p *Processor) UnmatchedFiles(filePaths []string) []string {
+	var inferred map[string]string
+	if p.config.InferDates {
+		inferred = p.inferDates(filePaths)
+	}
+	var unmatched []string
+	for _, filePath := range filePaths {
+		if _, ok := inferred[filePath]; ok {
+			continue
+		}
+		if IsSticker(filePath) && (p.config.Stickers == "" || p.config.Stickers == StickersSkip) {
+			continue
+		}
+		if _, _, _, err := p.matchFilename(filepath.Base(filePath)); err != nil {
+			unmatched = append(unmatched, filePath)
+		}
+	}
+	return unmatched
 }
 
 // convertDateFormat converts YYYYMMDD to YYYY-MM-DD
@@ -188,78 +1011,76 @@ func parseISODateTime(dateStr string) (time.Time, error) {
 	return time.Parse("2006-01-02", dateStr)
 }
 
-// determineOutputPath determines the output file path based on configuration
-func (p *Processor) determineOutputPath(inputPath, outputDir string) (string, error) {
-	absInputDir, _ := filepath.Abs(p.config.InputDir)
+// determineOutputPath evaluates the output template for a file, its hash
+// (see expandOutputTemplate) and its date
+func (p *Processor) determineOutputPath(inputPath, hash string, date time.Time) (string, error) {
+	return p.checkOutputPath(inputPath, p.expandOutputTemplate(p.outputTemplate(), inputPath, hash, date))
+}
 
-	// If no output dir specified
-	if outputDir == "" {
-		if p.config.OverrideOriginal {
-			return inputPath, nil
+// checkOutputPath refuses an output path the options don't allow: the input
+// itself without OverrideOriginal, or a copy with NoCopy
+func (p *Processor) checkOutputPath(inputPath, outputPath string) (string, error) {
+	if outputPath == filepath.Clean(inputPath) {
+		if !p.config.OverrideOriginal {
+			return "", fmt.Errorf("output maps %s onto itself (use -o to overwrite originals)", inputPath)
 		}
-		// Add suffix to original location
-		return addSuffixToPath(inputPath), nil
+		return inputPath, nil
 	}
-
-	// Output dir specified
-	absOutputDir, _ := filepath.Abs(outputDir)
-
-	// If output dir is same as input dir, add suffix
-	if absOutputDir == absInputDir {
-		return addSuffixToPath(inputPath), nil
+	if p.config.NoCopy {
+		return "", fmt.Errorf("output would be a copy at %s (--no-copy only edits originals in place)", outputPath)
 	}
-
-	// Use original filename in output directory
-	filename := filepath.Base(inputPath)
-	return filepath.Join(outputDir, filename), nil
+	return outputPath, nil
 }
 
-// addSuffixToPath adds a "_modified" suffix before file extension
-func addSuffixToPath(filePath string) string {
-	ext := filepath.Ext(filePath)
-	nameWithoutExt := strings.TrimSuffix(filePath, ext)
-	return nameWithoutExt + "_modified" + ext
-}
-
-// copyFile copies a file from src to dst, preserving original file permissions
-func copyFile(src, dst string) error {
-	data, err := os.ReadFile(src)
+// copyFile copies a file from src to dst, preserving original file
+// permissions. Files on disk are streamed rather than held in memory (see
+// streamFile). progress, when set, is called as the copy advances with the
+// bytes copied so far and the size of src.
+func copyFile(fsys FS, src, dst string, progress func(copied, total int64)) error {
+	// Get original file permissions
+	info, err := fsys.Stat(src)
 	if err != nil {
 		return err
 	}
-	
-	// Get original file permissions
-	info, err := os.Stat(src)
+	if isOSFS(fsys) {
+		if err := streamFile(src, dst, info, progress); err != nil {
+			return err
+		}
+		// The umask may have narrowed the permissions, and the copy belongs
+		// to whoever runs wappd (root on many NAS boxes) until given back
+		os.Chmod(dst, info.Mode().Perm())
+		preserveOwner(dst, info)
+		return nil
+	}
+
+	data, err := fsys.ReadFile(src)
 	if err != nil {
 		return err
 	}
-	
+
 	// Write file with original permissions
-	return os.WriteFile(dst, data, info.Mode())
+	if err := fsys.WriteFile(dst, data, info.Mode()); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(int64(len(data)), int64(len(data)))
+	}
+	return nil
 }
 
-// GetImageVideoFiles returns all image and video files in a directory
+// GetImageVideoFiles returns all image, video and audio files in a directory
 func GetImageVideoFiles(dirPath string) ([]string, error) {
-	var files []string
-	supportedExts := map[string]bool{
-		".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true, ".webp": true,
-		".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".flv": true, ".m4v": true, ".3gp": true,
-	}
-
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			ext := strings.ToLower(filepath.Ext(path))
-			if supportedExts[ext] {
-				files = append(files, path)
-			}
-		}
+	return GetMediaFiles(dirPath, false)
+}
 
-		return nil
-	})
+// GetMediaFiles returns all image, video and audio files in a directory,
+// plus documents (PDF, Office files) when includeDocuments is set
+func GetMediaFiles(dirPath string, includeDocuments bool) ([]string, error) {
+	return scan.Scanner{IncludeDocuments: includeDocuments}.Files(dirPath)
+}
 
-	return files, err
+// WalkMediaFiles calls fn with each file GetMediaFiles would return, as the
+// walk finds it, without building the list
+func WalkMediaFiles(dirPath string, includeDocuments bool, fn func(path string) error) error {
+	return scan.Scanner{IncludeDocuments: includeDocuments}.Walk(dirPath, fn)
 }
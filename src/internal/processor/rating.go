@@ -0,0 +1,35 @@
+package processor
+
+import "fmt"
+
+// ReadEXIFRating returns the Rating (stars, 0-5) and RatingPercent tags of
+// an EXIF APP1 payload's IFD0, as Windows and photo managers write them;
+// 0 for a tag that is missing
+func ReadEXIFRating(payload []byte) (rating, percent uint16, err error) {
+	tiff, order, err := parseTIFFHeader(payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	offset := order.Uint32(tiff[4:8])
+	if int(offset)+2 > len(tiff) {
+		return 0, 0, fmt.Errorf("IFD offset %d beyond data", offset)
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(tiff) {
+		return 0, 0, fmt.Errorf("IFD truncated")
+	}
+	for i := 0; i < count; i++ {
+		entry := tiff[start+i*12 : start+(i+1)*12]
+		if order.Uint16(entry[2:4]) != typeShort {
+			continue
+		}
+		switch order.Uint16(entry[0:2]) {
+		case tagRating:
+			rating = order.Uint16(entry[8:10])
+		case tagRatingPercent:
+			percent = order.Uint16(entry[8:10])
+		}
+	}
+	return rating, percent, nil
+}
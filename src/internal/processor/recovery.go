@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DamagedFile is an original a crashed run was editing in place that no
+// longer parses as its container
+type DamagedFile struct {
+	JournalEdit
+	Journal string // Journal file listing it
+	Problem error  // Why it doesn't parse
+}
+
+// checkStructure reports why the file at path no longer parses as its
+// container (JPEG or MP4 family), or nil if it does. Files of other
+// formats, and files that are gone, aren't checked.
+func checkStructure(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	container := SniffContainer(data)
+	if container == "" {
+		container = extensionContainers[strings.ToLower(filepath.Ext(path))]
+	}
+	if container == "" {
+		return nil
+	}
+	if _, ok, err := readMediaSignature(data, container); ok && err != nil {
+		return err
+	}
+	return nil
+}
+
+// damagedEdits returns the edits whose file no longer parses
+func damagedEdits(edits []JournalEdit) []JournalEdit {
+	var damaged []JournalEdit
+	for _, e := range edits {
+		if checkStructure(e.Path) != nil {
+			damaged = append(damaged, e)
+		}
+	}
+	return damaged
+}
+
+// ScanJournals checks the originals listed as edited in place by the
+// journals under dir whose run is gone (see claimJournal) and returns those
+// that no longer parse
+func ScanJournals(dir string, staleAfter time.Duration, now time.Time) ([]DamagedFile, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal directory: %v", err)
+	}
+
+	var damaged []DamagedFile
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != journalExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		journalPath := filepath.Join(dir, entry.Name())
+		release, abandoned := claimJournal(journalPath, info.ModTime(), staleAfter, now)
+		if !abandoned {
+			continue
+		}
+		_, edits, err := readJournal(journalPath)
+		release()
+		if err != nil {
+			return damaged, err
+		}
+		for _, e := range edits {
+			if problem := checkStructure(e.Path); problem != nil {
+				damaged = append(damaged, DamagedFile{JournalEdit: e, Journal: journalPath, Problem: problem})
+			}
+		}
+	}
+	return damaged, nil
+}
+
+// FindBackup looks under backupDir for a copy of d from before the edit: a
+// file with the same name and original size that parses. Several matches
+// must have identical contents.
+func FindBackup(d DamagedFile, backupDir string) (string, error) {
+	name := filepath.Base(d.Path)
+	var matches []string
+	err := filepath.WalkDir(backupDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || entry.Name() != name {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() != d.Size {
+			return nil
+		}
+		if filepath.Clean(path) != filepath.Clean(d.Path) && checkStructure(path) == nil {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search backups: %v", err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no backup of %s (%d bytes) found in %s", name, d.Size, backupDir)
+	}
+	first, err := os.ReadFile(matches[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %v", err)
+	}
+	for _, other := range matches[1:] {
+		data, err := os.ReadFile(other)
+		if err != nil {
+			return "", fmt.Errorf("failed to read backup: %v", err)
+		}
+		if !bytes.Equal(first, data) {
+			return "", fmt.Errorf("%d different backups of %s found in %s (%s, %s...)", len(matches), name, backupDir, matches[0], other)
+		}
+	}
+	return matches[0], nil
+}
+
+// RestoreFromBackup copies the backup FindBackup picks over the damaged
+// file and removes it from its journal. With dryRun, only reports the
+// backup that would be restored.
+func RestoreFromBackup(d DamagedFile, backupDir string, dryRun bool) (string, error) {
+	backup, err := FindBackup(d, backupDir)
+	if err != nil || dryRun {
+		return backup, err
+	}
+	if err := copyFile(OSFS, backup, d.Path, nil); err != nil {
+		return backup, fmt.Errorf("failed to restore %s: %v", d.Path, err)
+	}
+	return backup, forgetEdit(d.Journal, d.Path)
+}
+
+// forgetEdit removes path from the edits listed by a crashed run's journal,
+// removing the journal once nothing is left in it
+func forgetEdit(journalPath, path string) error {
+	info, err := os.Stat(journalPath)
+	if err != nil {
+		return fmt.Errorf("failed to read journal: %v", err)
+	}
+	files, edits, err := readJournal(journalPath)
+	if err != nil {
+		return err
+	}
+	kept := edits[:0]
+	for _, e := range edits {
+		if e.Path != path {
+			kept = append(kept, e)
+		}
+	}
+	if len(files) == 0 && len(kept) == 0 {
+		if err := os.Remove(journalPath); err != nil {
+			return fmt.Errorf("failed to remove journal: %v", err)
+		}
+		return nil
+	}
+	if err := writeJournal(journalPath, 0, files, kept); err != nil {
+		return err
+	}
+	// Keep the journal as stale as it was
+	os.Chtimes(journalPath, info.ModTime(), info.ModTime())
+	return nil
+}
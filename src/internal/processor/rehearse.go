@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// rehearseWrite predicts, for a dry run, whether writing dateTime into
+// filePath would fail: the native writer runs on an in-memory copy of the
+// file and the result is validated like a real write, so dry-run numbers
+// match the eventual run. Returns the backend that would handle the file.
+// Large videos, patched in place by the real run, only have their header
+// atoms checked, and exiftool is only checked for availability. overwrite is
+// passed on to updateExifData.
+func (p *Processor) rehearseWrite(filePath string, dateTime time.Time, comment string, overwrite bool) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if actual := detectContainerMismatch(p.fsys, filePath); actual != "" {
+		ext = actual
+	}
+	if !p.writerEnabled(formatWriter(ext)) {
+		return "", nil
+	}
+	exiftool, err := useExiftool(ext, p.config.Backend)
+	if err != nil || exiftool {
+		return BackendExiftool, err
+	}
+
+	// Movies that fail natively are remuxed with ffmpeg when allowed
+	backend, err := p.rehearseNative(filePath, ext, dateTime, comment, overwrite)
+	if err != nil && isMovieFormat(ext) && p.config.AllowFFmpeg && FFmpegAvailable() && isOSFS(p.fsys) {
+		return BackendFFmpeg, nil
+	}
+	return backend, err
+}
+
+// rehearseNative runs the native writer for rehearseWrite
+func (p *Processor) rehearseNative(filePath, ext string, dateTime time.Time, comment string, overwrite bool) (string, error) {
+	if isMovieFormat(ext) && useMappedPatch(p.fsys, filePath, p.mappedFrom()) {
+		return BackendNative, p.checkMovieHeaders(filePath, ext)
+	}
+
+	data, err := p.fsys.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	mem := NewMemFS()
+	mem.WriteFile(filePath, data, 0644)
+	config := p.config
+	config.DryRun = false
+	config.Verbose = false
+	config.GPX = "" // Already loaded; the track is shared below
+	rehearsal := New(config, WithFS(mem), WithClock(p.clock))
+	rehearsal.gpsTrack = p.gpsTrack
+
+	backend, err := rehearsal.updateExifData(filePath, dateTime, comment, overwrite)
+	if errors.Is(err, ErrExifExists) {
+		return backend, nil
+	}
+	if err != nil {
+		return backend, fmt.Errorf("failed to update EXIF data: %w", err)
+	}
+	return backend, rehearsal.validateOutput(filePath, data)
+}
+
+// checkMovieHeaders checks what patchVideoMapped needs of a video on disk
+// without reading its media data: an ftyp atom first and a moov holding an
+// mvhd whose dates can be patched
+func (p *Processor) checkMovieHeaders(filePath, ext string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	header := make([]byte, 8)
+	_, err = f.ReadAt(header, 0)
+	f.Close()
+	if err != nil || binary.BigEndian.Uint32(header[0:4]) < 8 {
+		return fmt.Errorf("file too short to be a valid MP4/MOV/3GP")
+	}
+	if string(header[4:8]) != "ftyp" {
+		// The writer's own error for a styp segment or a missing ftyp
+		return patchMovieHeaders(header, time.Time{}, defaultVideoAtoms)
+	}
+
+	moov, err := p.readMetadata(filePath, ext)
+	if err != nil {
+		return err
+	}
+	_, _, headerLen, err := readAtomHeader(moov, 0)
+	if err != nil {
+		return fmt.Errorf("failed to parse MP4 atoms: %v", err)
+	}
+	found, err := patchHeaderAtoms(moov[headerLen:], UnixToQuickTime(time.Now().Unix()), p.videoAtoms(ext))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("mvhd %w in moov", ErrAtomNotFound)
+	}
+	return nil
+}
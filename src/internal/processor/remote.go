@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IsRemoteSource checks if the input path is an sftp:// or smb:// URL
+func IsRemoteSource(input string) bool {
+	lower := strings.ToLower(input)
+	return strings.HasPrefix(lower, "sftp://") || strings.HasPrefix(lower, "smb://")
+}
+
+// RemoteBaseName returns the last path element of a remote source URL, used to
+// name the local output directory (e.g. sftp://phone/DCIM -> "DCIM")
+func RemoteBaseName(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "remote"
+	}
+	base := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if base == "" || base == "/" || base == "." {
+		return u.Hostname()
+	}
+	return base
+}
+
+// RemoteFetchCommand builds the external command used to copy a remote source
+// into destDir. SFTP sources are fetched with scp, SMB shares with smbclient;
+// both tools only read from the remote side.
+func RemoteFetchCommand(source, destDir string) (string, []string, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid remote source URL: %v", err)
+	}
+	if u.Hostname() == "" {
+		return "", nil, fmt.Errorf("remote source URL has no host: %s", source)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "sftp":
+		host := u.Hostname()
+		if u.User != nil && u.User.Username() != "" {
+			host = u.User.Username() + "@" + host
+		}
+		remotePath := u.Path
+		if remotePath == "" {
+			remotePath = "."
+		}
+		args := []string{"-r", "-q", "-p"}
+		if u.Port() != "" {
+			args = append(args, "-P", u.Port())
+		}
+		args = append(args, host+":"+remotePath, destDir)
+		return "scp", args, nil
+
+	case "smb":
+		// smb://[user@]host/share[/path]
+		parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+		if parts[0] == "" {
+			return "", nil, fmt.Errorf("SMB source URL has no share name: %s", source)
+		}
+		share := "//" + u.Hostname() + "/" + parts[0]
+		dir := ""
+		if len(parts) == 2 {
+			dir = parts[1]
+		}
+
+		// smbclient splits -c on ";" whatever the quoting, and has no
+		// escape for a quote inside a quoted path
+		for _, p := range []string{dir, destDir} {
+			if strings.ContainsAny(p, ";\"\r\n") {
+				return "", nil, fmt.Errorf("SMB path %q contains a character smbclient can't take in a command (; \" or a line break)", p)
+			}
+		}
+		commands := "prompt OFF; recurse ON; lcd \"" + destDir + "\";"
+		if dir != "" {
+			commands += " cd \"" + dir + "\";"
+		}
+		commands += " mget *"
+
+		args := []string{share}
+		if u.Port() != "" {
+			args = append(args, "-p", u.Port())
+		}
+		if u.User != nil && u.User.Username() != "" {
+			args = append(args, "-U", u.User.Username())
+		} else {
+			args = append(args, "-N")
+		}
+		args = append(args, "-c", commands)
+		return "smbclient", args, nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported remote source scheme: %s", u.Scheme)
+}
+
+// RemoteOrigin returns the URL of a file FetchRemoteSource copied from source
+// into destDir. scp copies the source itself into destDir, while smbclient
+// copies the directory's contents.
+func RemoteOrigin(source, destDir, fetched string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		return source
+	}
+	rel, err := filepath.Rel(destDir, fetched)
+	if err != nil {
+		return source
+	}
+	dir := u.Path
+	if strings.ToLower(u.Scheme) == "sftp" {
+		dir = path.Dir(strings.TrimSuffix(u.Path, "/"))
+	}
+	u.Path = path.Join("/", dir, filepath.ToSlash(rel))
+	return u.String()
+}
+
+// FetchRemoteSource copies a remote source into destDir using the matching
+// external tool, which must be installed and on PATH
+func FetchRemoteSource(source, destDir string) error {
+	name, args, err := RemoteFetchCommand(source, destDir)
+	if err != nil {
+		return err
+	}
+
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s is required to fetch %s but was not found on PATH", name, source)
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin // allow password prompts
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReportItem is one file of an HTML report
+type ReportItem struct {
+	Name      string
+	Path      string // Output file, or the input when there is none
+	Status    string // "success", "failed", "skipped" or "correct"
+	Detail    string // Error, skip reason or note
+	Before    string // Modification time before processing
+	After     string // Date written
+	Length    string // Duration of videos and M4A audio; why it's unknown when the header is suspicious
+	Thumbnail template.URL
+}
+
+// htmlReport is the data the report template renders
+type htmlReport struct {
+	Generated string
+	DryRun    bool
+	Counts    map[string]int
+	Items     []ReportItem
+
+	Duplicates []DuplicateGroup
+	Redundant  string // Total DuplicateGroup.Redundant, formatted
+}
+
+// WriteHTMLReport writes a self-contained HTML page reviewing results: a
+// thumbnail of every image with its old and new date and what happened to
+// it, for people who'd rather look at photos than read logs. thumbs makes
+// the thumbnails; nil makes them without a cache. dups, from FindDuplicates,
+// adds a section listing them.
+func WriteHTMLReport(path string, results []ProcessResult, dups []DuplicateGroup, generated time.Time, dryRun bool, thumbs *Thumbnailer) error {
+	if thumbs == nil {
+		thumbs = NewThumbnailer("", DefaultThumbnailSize)
+	}
+	report := htmlReport{
+		Generated: generated.Format("2006-01-02 15:04:05"),
+		DryRun:    dryRun,
+		Counts:    map[string]int{},
+
+		Duplicates: dups,
+	}
+	var redundant int64
+	for _, g := range dups {
+		redundant += g.Redundant()
+	}
+	report.Redundant = FormatSize(redundant)
+	for _, r := range results {
+		item := newReportItem(r, thumbs)
+		report.Counts[item.Status]++
+		report.Items = append(report.Items, item)
+	}
+
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, report); err != nil {
+		return fmt.Errorf("failed to render HTML report: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write HTML report: %v", err)
+	}
+	return nil
+}
+
+// newReportItem describes one result for the report
+func newReportItem(r ProcessResult, thumbs *Thumbnailer) ReportItem {
+	item := ReportItem{Name: filepath.Base(r.InputFile), Path: r.InputFile}
+	if r.OutputFile != "" {
+		if _, err := os.Stat(r.OutputFile); err == nil {
+			item.Path = r.OutputFile
+		}
+	}
+
+	switch {
+	case r.Skipped:
+		item.Status, item.Detail = "skipped", r.SkipReason
+	case !r.Success:
+		item.Status = "failed"
+		if r.Error != nil {
+			item.Detail = r.Error.Error()
+		}
+	case r.AlreadyCorrect:
+		item.Status, item.Detail = "correct", "date was already right"
+	default:
+		item.Status = "success"
+		if r.Inferred {
+			item.Detail = "date inferred from neighboring files"
+		}
+	}
+
+	// Files the run didn't get to still have their original time
+	before := r.OriginalModTime
+	if before.IsZero() {
+		if info, err := os.Stat(r.InputFile); err == nil {
+			before = info.ModTime()
+		}
+	}
+	if !before.IsZero() {
+		item.Before = before.Format("2006-01-02 15:04:05")
+	}
+	if r.Success && !r.DateTime.IsZero() {
+		item.After = r.DateTime.Format("2006-01-02 15:04:05")
+	}
+	if d, err := VideoDuration(item.Path); err == nil && d > 0 {
+		item.Length = FormatDuration(d)
+	} else if err != nil && !errors.Is(err, ErrNoDuration) {
+		item.Length = "unknown (" + err.Error() + ")"
+	}
+	item.Thumbnail = thumbnailURI(thumbs, item.Path)
+	return item
+}
+
+// thumbnailURI returns a JPEG thumbnail of a file as a data: URI, or "" for
+// files no thumbnail can be made of
+func thumbnailURI(thumbs *Thumbnailer, path string) template.URL {
+	data, err := thumbs.Thumbnail(path)
+	if err != nil {
+		return ""
+	}
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>wappd report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+.summary span { margin-right: 1.5em; }
+.grid { display: flex; flex-wrap: wrap; gap: 1em; margin-top: 1.5em; }
+.card { width: 200px; border: 1px solid #ccc; border-radius: 6px; padding: 8px; font-size: 13px; }
+.card .thumb { height: 160px; display: flex; align-items: center; justify-content: center; background: #f4f4f4; }
+.card img { max-width: 100%; max-height: 160px; }
+.card .name { font-weight: bold; word-break: break-all; margin: 6px 0; }
+.success { border-left: 6px solid #2a9d3a; }
+.correct { border-left: 6px solid #4a7bd0; }
+.skipped { border-left: 6px solid #aaa; }
+.failed { border-left: 6px solid #d03a3a; }
+.detail { color: #666; }
+</style>
+</head>
+<body>
+<h1>wappd report</h1>
+<p>Generated {{.Generated}}{{if .DryRun}} — dry run, nothing was changed{{end}}</p>
+<p class="summary">
+<span>{{index .Counts "success"}} updated</span>
+<span>{{index .Counts "correct"}} already correct</span>
+<span>{{index .Counts "skipped"}} skipped</span>
+<span>{{index .Counts "failed"}} failed</span>
+</p>
+<div class="grid">
+{{range .Items}}<div class="card {{.Status}}">
+<div class="thumb">{{if .Thumbnail}}<img src="{{.Thumbnail}}" alt="{{.Name}}">{{else}}no preview{{end}}</div>
+<div class="name" title="{{.Path}}">{{.Name}}</div>
+<div>Before: {{if .Before}}{{.Before}}{{else}}unknown{{end}}</div>
+<div>After: {{if .After}}{{.After}}{{else}}unchanged{{end}}</div>
+{{if .Length}}<div>Length: {{.Length}}</div>
+{{end}}<div>{{.Status}}{{if .Detail}} <span class="detail">({{.Detail}})</span>{{end}}</div>
+</div>
+{{end}}</div>
+{{if .Duplicates}}<h2>Duplicates</h2>
+<p>Identical copies take {{.Redundant}}. Nothing was deleted.</p>
+<ul class="duplicates">
+{{range .Duplicates}}<li>{{if eq .Kind "identical"}}{{len .Files}} identical copies{{else}}{{len .Files}} versions of {{.Key}}{{end}}:
+<ul>{{range .Files}}<li>{{.}}</li>{{end}}</ul></li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TimeRestoreResult is the outcome of restoring the modification time of
+// one file of a recorded run
+type TimeRestoreResult struct {
+	File    string    // File whose modification time was put back
+	ModTime time.Time // Modification time it had before the run
+	Error   error
+}
+
+// RestoreModTimes puts back the modification times a run recorded for the
+// files it edited in place (including originals it renamed, e.g. with
+// FixExtensions). It is no undo: the metadata written into those files
+// stays. Copies written next to or away from the originals are left alone:
+// they didn't exist before the run. With dryRun, only reports what would
+// be restored.
+func RestoreModTimes(record RunRecord, dryRun bool) []TimeRestoreResult {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) || record.WorkDir == "" {
+			return path
+		}
+		return filepath.Join(record.WorkDir, path)
+	}
+
+	var results []TimeRestoreResult
+	for _, e := range record.Entries {
+		if e.Status != RunStatusSuccess || e.OriginalModTime == "" || e.OutputFile == "" {
+			continue
+		}
+		input, output := resolve(e.InputFile), resolve(e.OutputFile)
+		if output != input {
+			// A renamed original is gone; a copy leaves it in place
+			if _, err := os.Stat(input); !os.IsNotExist(err) {
+				continue
+			}
+		}
+
+		result := TimeRestoreResult{File: output}
+		modTime, err := time.Parse(time.RFC3339Nano, e.OriginalModTime)
+		if err != nil {
+			result.Error = fmt.Errorf("invalid original modification time %q: %v", e.OriginalModTime, err)
+		} else {
+			result.ModTime = modTime
+			if !dryRun {
+				if err := os.Chtimes(output, modTime, modTime); err != nil {
+					result.Error = fmt.Errorf("failed to restore modification time: %v", err)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
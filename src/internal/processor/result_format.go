@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Statuses FormatResult gives {status}
+const (
+	ResultOK      = "ok"      // Processed
+	ResultCorrect = "correct" // Metadata already held the date and wasn't rewritten
+	ResultSkipped = "skipped"
+	ResultFailed  = "failed"
+)
+
+// resultFields are the placeholders a result format may use
+var resultFields = map[string]bool{
+	"status":  true, // ok, correct, skipped or failed
+	"input":   true, // Input path
+	"output":  true, // Output path ("" when skipped or failed)
+	"name":    true, // Input file name
+	"date":    true, // Date given to the file: 2024-05-01 12:00:00
+	"backend": true, // Metadata backend that wrote the file
+	"error":   true, // Why the file failed or was skipped
+}
+
+// ValidateResultFormat checks that a per-file line format only uses known
+// placeholders
+func ValidateResultFormat(format string) error {
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(format, -1) {
+		if !resultFields[match[1]] {
+			return fmt.Errorf("unknown placeholder %s in format (expected {status}, {input}, {output}, {name}, {date}, {backend} or {error})", match[0])
+		}
+	}
+	return nil
+}
+
+// FormatResult renders a result as a line of format, e.g.
+// "{status} {input} -> {output} {date}". A literal \t or \n in format
+// becomes a tab or newline, for formats given on a command line; the values
+// substituted are left as they are.
+func FormatResult(format string, r ProcessResult) string {
+	format = formatEscapes.Replace(format)
+	fields := map[string]string{
+		"input":   r.InputFile,
+		"name":    filepath.Base(r.InputFile),
+		"backend": r.Backend,
+	}
+	switch {
+	case r.Skipped:
+		fields["status"], fields["error"] = ResultSkipped, r.SkipReason
+	case !r.Success:
+		fields["status"] = ResultFailed
+		if r.Error != nil {
+			fields["error"] = r.Error.Error()
+		}
+	case r.AlreadyCorrect:
+		fields["status"], fields["output"] = ResultCorrect, r.OutputFile
+	default:
+		fields["status"], fields["output"] = ResultOK, r.OutputFile
+	}
+	if !r.DateTime.IsZero() {
+		fields["date"] = r.DateTime.Format("2006-01-02 15:04:05")
+	}
+	return templatePlaceholder.ReplaceAllStringFunc(format, func(placeholder string) string {
+		return fields[strings.Trim(placeholder, "{}")]
+	})
+}
+
+// formatEscapes unescapes the \t and \n of a result format
+var formatEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
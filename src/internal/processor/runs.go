@@ -0,0 +1,296 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRunsDir is where each run's directory is created by default,
+// relative to the working directory
+var DefaultRunsDir = filepath.Join(".wappd", "runs")
+
+// Files kept in a run directory, next to the run's journal
+const (
+	RunReportName = "report.json" // RunRecord of the run
+	RunLogName    = "run.log"     // Warnings, errors and verbose processing output
+	RunThumbsName = "thumbnails"  // Cached thumbnails of the run's files
+	RunFailedName = "failed.txt"  // Files that failed, written by retry for --files-from
+)
+
+// SecretFlags are the flags whose values are credentials. Run records keep
+// "[redacted]" in their place, and retry leaves them out to be given again.
+var SecretFlags = []string{"api-key", "photoprism-token"}
+
+// IsSecretFlag reports whether arg is one of SecretFlags, as -name, --name
+// or either with =value, returning whether the value is in arg itself
+func IsSecretFlag(arg string) (secret, inline bool) {
+	if !strings.HasPrefix(arg, "-") {
+		return false, false
+	}
+	name, _, inline := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+	for _, flag := range SecretFlags {
+		if name == flag {
+			return true, inline
+		}
+	}
+	return false, false
+}
+
+// RedactArgs returns args with the values of SecretFlags replaced with
+// "[redacted]"
+func RedactArgs(args []string) []string {
+	redactedArgs := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		secret, inline := IsSecretFlag(arg)
+		switch {
+		case !secret:
+			redactedArgs = append(redactedArgs, arg)
+		case inline:
+			name, _, _ := strings.Cut(arg, "=")
+			redactedArgs = append(redactedArgs, name+"="+redacted)
+		default:
+			redactedArgs = append(redactedArgs, arg)
+			if i+1 < len(args) {
+				redactedArgs = append(redactedArgs, redacted)
+				i++
+			}
+		}
+	}
+	return redactedArgs
+}
+
+// runIDFormat is the timestamp run IDs start with; they sort by start time
+const runIDFormat = "20060102-150405"
+
+// Statuses of a RunEntry
+const (
+	RunStatusSuccess = "success"
+	RunStatusFailed  = "failed"
+	RunStatusSkipped = "skipped"
+)
+
+// RunEntry records what a run did to one file: enough to inspect the run
+// later or to restore its file times (the output path, the hashes around
+// the write and the original modification time)
+type RunEntry struct {
+	InputFile  string `json:"inputFile"`
+	OutputFile string `json:"outputFile,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	SkipReason string `json:"skipReason,omitempty"`
+	Date       string `json:"date,omitempty"` // Date written, RFC 3339
+	Backend    string `json:"backend,omitempty"`
+	PreHash    string `json:"preHash,omitempty"`  // With -manifest
+	PostHash   string `json:"postHash,omitempty"` // With -manifest
+
+	// Modification time of the input before the run, RFC 3339 with
+	// nanoseconds; restore-times puts it back on files edited in place
+	OriginalModTime string `json:"originalModTime,omitempty"`
+}
+
+// RunRecord is the report of one run. Finished is empty while the run is
+// in progress, or when it crashed or aborted.
+type RunRecord struct {
+	ID       string     `json:"id"`
+	Started  string     `json:"started"`
+	Finished string     `json:"finished,omitempty"`
+	WorkDir  string     `json:"workDir"`
+	Args     []string   `json:"args"` // Secrets redacted (see RedactArgs)
+	Entries  []RunEntry `json:"entries"`
+}
+
+// Counts returns how many of the run's files succeeded, failed and were skipped
+func (r RunRecord) Counts() (success, failed, skipped int) {
+	for _, e := range r.Entries {
+		switch e.Status {
+		case RunStatusSuccess:
+			success++
+		case RunStatusFailed:
+			failed++
+		case RunStatusSkipped:
+			skipped++
+		}
+	}
+	return success, failed, skipped
+}
+
+// Failed returns the input paths of the run's failed files, as recorded
+// (relative paths are relative to WorkDir)
+func (r RunRecord) Failed() []string {
+	var paths []string
+	for _, e := range r.Entries {
+		if e.Status == RunStatusFailed {
+			paths = append(paths, e.InputFile)
+		}
+	}
+	return paths
+}
+
+// Run is the directory a run keeps its report, journal and log in
+type Run struct {
+	mu     sync.Mutex
+	dir    string
+	record RunRecord
+}
+
+// NewRun creates the directory of a run started at now under runsDir,
+// named after the start time, and writes its initial report. args are
+// recorded with the values of SecretFlags redacted.
+func NewRun(runsDir string, args []string, now time.Time) (*Run, error) {
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create runs directory: %v", err)
+	}
+	base := now.Format(runIDFormat)
+	id := base
+	for n := 2; ; n++ {
+		err := os.Mkdir(filepath.Join(runsDir, id), 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create run directory: %v", err)
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	workDir, _ := os.Getwd()
+	r := &Run{
+		dir: filepath.Join(runsDir, id),
+		record: RunRecord{
+			ID:      id,
+			Started: now.Format(time.RFC3339),
+			WorkDir: workDir,
+			Args:    RedactArgs(args),
+			Entries: []RunEntry{},
+		},
+	}
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Dir returns the run's directory
+func (r *Run) Dir() string {
+	return r.dir
+}
+
+// ID returns the run's ID, the name of its directory
+func (r *Run) ID() string {
+	return r.record.ID
+}
+
+// AddResults records processing results in the run's report
+func (r *Run) AddResults(results []ProcessResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, res := range results {
+		r.record.Entries = append(r.record.Entries, newRunEntry(res))
+	}
+	return r.save()
+}
+
+// Finish marks the run as finished at now
+func (r *Run) Finish(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record.Finished = now.Format(time.RFC3339)
+	return r.save()
+}
+
+// newRunEntry converts a processing result to a report entry
+func newRunEntry(res ProcessResult) RunEntry {
+	e := RunEntry{
+		InputFile:  res.InputFile,
+		OutputFile: res.OutputFile,
+		Backend:    res.Backend,
+		PreHash:    res.PreHash,
+		PostHash:   res.PostHash,
+	}
+	switch {
+	case res.Skipped:
+		e.Status = RunStatusSkipped
+		e.SkipReason = res.SkipReason
+	case res.Success:
+		e.Status = RunStatusSuccess
+	default:
+		e.Status = RunStatusFailed
+		if res.Error != nil {
+			e.Error = res.Error.Error()
+		}
+	}
+	if !res.DateTime.IsZero() {
+		e.Date = res.DateTime.Format(time.RFC3339)
+	}
+	if !res.OriginalModTime.IsZero() {
+		e.OriginalModTime = res.OriginalModTime.Format(time.RFC3339Nano)
+	}
+	return e
+}
+
+// save rewrites the report; the caller holds mu (or owns r)
+func (r *Run) save() error {
+	data, err := json.MarshalIndent(r.record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run report: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, RunReportName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run report: %v", err)
+	}
+	return nil
+}
+
+// RunDirs returns the run directories under runsDir, oldest first
+func RunDirs(runsDir string) ([]string, error) {
+	entries, err := os.ReadDir(runsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs directory: %v", err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(runsDir, entry.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// LoadRun reads the report of the run in dir
+func LoadRun(dir string) (RunRecord, error) {
+	var record RunRecord
+	data, err := os.ReadFile(filepath.Join(dir, RunReportName))
+	if err != nil {
+		return record, fmt.Errorf("failed to read run report: %v", err)
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("failed to parse run report %s: %v", filepath.Join(dir, RunReportName), err)
+	}
+	return record, nil
+}
+
+// ListRuns loads the reports of every run under runsDir, oldest first.
+// Directories without a readable report are skipped.
+func ListRuns(runsDir string) ([]RunRecord, error) {
+	dirs, err := RunDirs(runsDir)
+	if err != nil {
+		return nil, err
+	}
+	var records []RunRecord
+	for _, dir := range dirs {
+		if record, err := LoadRun(dir); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
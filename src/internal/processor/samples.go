@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SamplesReadme is the file GenerateSamples describes the samples in
+const SamplesReadme = "SAMPLES.txt"
+
+// SamplesModTime is the modification time every sample is written with, as
+// if the files had just been copied off a phone
+var SamplesModTime = time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+
+// Sample is a synthetic file GenerateSamples writes
+type Sample struct {
+	Path string // Relative to the samples directory, with / separators
+	Note string // What the sample exercises
+	data func() []byte
+}
+
+// samplesMedia is where the samples go: a WhatsApp tree, so both -d and
+// --whatsapp-root work on the samples directory
+const samplesMedia = "WhatsApp/Media/"
+
+// Samples lists the files GenerateSamples writes. Their bytes are fixed, so
+// a bug report can name a sample and be reproduced anywhere.
+var Samples = []Sample{
+	{samplesMedia + "WhatsApp Images/IMG-20240501-WA0001.jpg", "JPEG without EXIF: gets DateTimeOriginal 2024-05-01", func() []byte { return sampleJPEG(time.Time{}) }},
+	{samplesMedia + "WhatsApp Images/IMG-20240502-WA0002.jpg", "JPEG already dated 2023-12-25 10:30: kept unless -ow (see --date-policy)", func() []byte { return sampleJPEG(time.Date(2023, 12, 25, 10, 30, 0, 0, time.UTC)) }},
+	{samplesMedia + "WhatsApp Images/IMG-20240503-WA0003.jpg", "JPEG already dated 2024-05-03 (see --skip-correct)", func() []byte { return sampleJPEG(time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)) }},
+	{samplesMedia + "WhatsApp Images/IMG-20240504-WA0004.gif", "WhatsApp \"GIF\" that is really an MP4 (see --fix-extensions)", func() []byte { return sampleMP4("mp42", SamplesModTime) }},
+	{samplesMedia + "WhatsApp Images/IMG_20240505_143012.jpg", "Camera name: only dated with --extra-patterns camera", func() []byte { return sampleJPEG(time.Time{}) }},
+	{samplesMedia + "WhatsApp Images/holiday.jpg", "No date in the name: fails (see --ignore-unmatched and --infer-dates)", func() []byte { return sampleJPEG(time.Time{}) }},
+	{samplesMedia + "WhatsApp Images/Sent/IMG-20240506-WA0005.jpg", "Sent from the phone (see --tag-sent)", func() []byte { return sampleJPEG(time.Time{}) }},
+	{samplesMedia + "WhatsApp Video/VID-20240507-WA0001.mp4", "MP4 whose mvhd/tkhd hold the copy date: rewritten to 2024-05-07", func() []byte { return sampleMP4("mp42", SamplesModTime) }},
+	{samplesMedia + "WhatsApp Video/VID-20240508-WA0002.mp4", "MP4 with an mvhd creation time of 0 (see --zero-mvhd)", func() []byte { return sampleMP4("mp42", time.Time{}) }},
+	{samplesMedia + "WhatsApp Video/VID-20240509-WA0003.3gp", "3GP video", func() []byte { return sampleMP4("3gp4", SamplesModTime) }},
+}
+
+// GenerateSamples writes the Samples under dir, plus a SamplesReadme
+// listing them, and returns the paths written. It never overwrites: an
+// existing sample is an error and nothing further is written.
+func GenerateSamples(dir string) ([]string, error) {
+	var written []string
+	var readme strings.Builder
+	readme.WriteString("Synthetic WhatsApp-style samples written by \"wappd gen-samples\".\n")
+	readme.WriteString("They have the structure of real files, not viewable pictures.\n\n")
+	for _, sample := range Samples {
+		path := filepath.Join(dir, filepath.FromSlash(sample.Path))
+		if err := writeSample(path, sample.data()); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+		fmt.Fprintf(&readme, "%s\n    %s\n", sample.Path, sample.Note)
+	}
+	path := filepath.Join(dir, SamplesReadme)
+	if err := writeSample(path, []byte(readme.String())); err != nil {
+		return written, err
+	}
+	return append(written, path), nil
+}
+
+// writeSample creates path with data and SamplesModTime, failing if it
+// exists
+func writeSample(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write sample: %v", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write sample: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write sample: %v", err)
+	}
+	return os.Chtimes(path, SamplesModTime, SamplesModTime)
+}
+
+// sampleJPEG returns a minimal 1x1 JPEG (SOI, SOF0, EOI), with an EXIF
+// DateTimeOriginal of dated unless it's zero
+func sampleJPEG(dated time.Time) []byte {
+	data := []byte{
+		0xFF, 0xD8, // SOI
+		0xFF, 0xC0, 0x00, 0x0B, 0x08, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x11, 0x00, // SOF0
+		0xFF, 0xD9, // EOI
+	}
+	if dated.IsZero() {
+		return data
+	}
+	exif, err := CreateEXIFSegment(dated)
+	if err != nil {
+		panic(err) // Fixed input: can't fail
+	}
+	if data, err = InsertEXIFSegment(data, exif); err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// sampleMP4 returns ftyp(brand) + moov(mvhd, trak(tkhd)) + mdat, with
+// created as the movie and track creation times (zero writes 0)
+func sampleMP4(brand string, created time.Time) []byte {
+	var when uint32
+	if !created.IsZero() {
+		when = UnixToQuickTime(created.Unix())
+	}
+	matrix := []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000}
+
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[4:8], when)
+	binary.BigEndian.PutUint32(mvhd[8:12], when)
+	binary.BigEndian.PutUint32(mvhd[12:16], 1000) // Timescale
+	binary.BigEndian.PutUint32(mvhd[16:20], 1000) // Duration: 1s
+	binary.BigEndian.PutUint32(mvhd[20:24], 0x00010000)
+	binary.BigEndian.PutUint16(mvhd[24:26], 0x0100)
+	for i, v := range matrix {
+		binary.BigEndian.PutUint32(mvhd[36+4*i:], v)
+	}
+	binary.BigEndian.PutUint32(mvhd[96:100], 2) // Next track ID
+
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[0:4], 0x00000003) // Enabled, in movie
+	binary.BigEndian.PutUint32(tkhd[4:8], when)
+	binary.BigEndian.PutUint32(tkhd[8:12], when)
+	binary.BigEndian.PutUint32(tkhd[12:16], 1) // Track ID
+	binary.BigEndian.PutUint32(tkhd[20:24], 1000)
+	for i, v := range matrix {
+		binary.BigEndian.PutUint32(tkhd[40+4*i:], v)
+	}
+	binary.BigEndian.PutUint32(tkhd[76:80], 1<<16) // 1x1
+	binary.BigEndian.PutUint32(tkhd[80:84], 1<<16)
+
+	ftyp := sampleBox("ftyp", []byte(brand), make([]byte, 4), []byte(brand), []byte("isom"))
+	moov := sampleBox("moov", sampleBox("mvhd", mvhd), sampleBox("trak", sampleBox("tkhd", tkhd)))
+	return append(append(ftyp, moov...), sampleBox("mdat", []byte("wappd sample"))...)
+}
+
+// sampleBox builds an MP4 box of type boxType around payload
+func sampleBox(boxType string, payload ...[]byte) []byte {
+	box := make([]byte, 8)
+	copy(box[4:8], boxType)
+	for _, p := range payload {
+		box = append(box, p...)
+	}
+	binary.BigEndian.PutUint32(box[0:4], uint32(len(box)))
+	return box
+}
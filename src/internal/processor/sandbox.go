@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkSandbox implements SafeMode: it refuses an output path that, once
+// symlinks are resolved, lies outside the input and output directories.
+// A symlinked folder (or file) inside the input tree can otherwise make an
+// in-place edit or a copy land anywhere on the disk.
+func (p *Processor) checkSandbox(outputPath string) error {
+	if !p.config.SafeMode || !isOSFS(p.fsys) {
+		return nil
+	}
+	var roots []string
+	for _, dir := range []string{p.config.InputDir, p.config.OutputDir} {
+		if dir == "" {
+			continue
+		}
+		root, err := resolvePath(dir)
+		if err != nil {
+			return fmt.Errorf("safe mode: failed to resolve %s: %v", dir, err)
+		}
+		roots = append(roots, root)
+	}
+
+	target, err := resolvePath(outputPath)
+	if err != nil {
+		return fmt.Errorf("safe mode: failed to resolve %s: %v", outputPath, err)
+	}
+	for _, root := range roots {
+		if isWithin(root, target) {
+			return nil
+		}
+	}
+	return fmt.Errorf("safe mode: %s resolves to %s, outside %s", outputPath, target, strings.Join(roots, " and "))
+}
+
+// resolvePath returns the absolute path of path with every symlink resolved.
+// Parts of the path that don't exist yet (output directories a template
+// adds) are appended to the resolved part that does.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return filepath.Join(abs, rest), nil
+		}
+		rest = filepath.Join(filepath.Base(abs), rest)
+		abs = parent
+	}
+}
+
+// isWithin reports whether path is root or lies below it
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
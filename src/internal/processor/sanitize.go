@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Filesystems output names can be made valid for (SanitizeNames)
+const (
+	SanitizeWindows = "windows" // NTFS/exFAT rules: no <>:"\|?*, reserved device names, trailing dots
+	SanitizeMacOS   = "macos"   // No colons (Finder shows them as slashes)
+)
+
+// maxNameBytes is the longest file name most filesystems accept
+const maxNameBytes = 255
+
+// windowsReserved are the device names Windows refuses as file names, with
+// or without an extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ValidateSanitizeMode checks that the name sanitizing mode is known (""
+// leaves names as they are)
+func ValidateSanitizeMode(mode string) error {
+	switch mode {
+	case "", SanitizeWindows, SanitizeMacOS:
+		return nil
+	}
+	return fmt.Errorf("unknown sanitize mode %q (expected windows or macos)", mode)
+}
+
+// SanitizeName makes one path component valid on the mode's filesystem:
+// illegal characters become "_", reserved names get a "_" appended and
+// names longer than 255 bytes are shortened, keeping the extension
+func SanitizeName(name, mode string) string {
+	switch mode {
+	case SanitizeWindows:
+		name = strings.Map(func(r rune) rune {
+			if r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r) {
+				return '_'
+			}
+			return r
+		}, name)
+		// Windows drops trailing dots and spaces, so names differing only
+		// in them would collide
+		name = strings.TrimRight(name, ". ")
+		if name == "" {
+			name = "_"
+		}
+		stem, rest, _ := strings.Cut(name, ".")
+		if windowsReserved[strings.ToUpper(strings.TrimRight(stem, " "))] {
+			name = stem + "_"
+			if rest != "" {
+				name += "." + rest
+			}
+		}
+	case SanitizeMacOS:
+		name = strings.ReplaceAll(name, ":", "_")
+	default:
+		return name
+	}
+	return truncateName(name)
+}
+
+// truncateName shortens name to maxNameBytes, cutting the part before the
+// extension on a character boundary
+func truncateName(name string) string {
+	if len(name) <= maxNameBytes {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) >= maxNameBytes/2 {
+		ext = ""
+	}
+	stem := name[:len(name)-len(ext)]
+	cut := maxNameBytes - len(ext)
+	for cut > 0 && !utf8.RuneStart(stem[cut]) {
+		cut--
+	}
+	return stem[:cut] + ext
+}
+
+// sanitizeOutputPath applies SanitizeNames to the file name and to the
+// directories an output template adds below the output (or input file's)
+// directory; the directories the user gave are left alone
+func (p *Processor) sanitizeOutputPath(outputPath, inputPath string) string {
+	mode := p.config.SanitizeNames
+	if mode == "" {
+		return outputPath
+	}
+	root, rel := filepath.Dir(outputPath), filepath.Base(outputPath)
+	for _, dir := range []string{p.config.OutputDir, filepath.Dir(inputPath)} {
+		if dir == "" {
+			continue
+		}
+		if r, err := filepath.Rel(dir, outputPath); err == nil && r != "." && !strings.HasPrefix(r, "..") {
+			root, rel = dir, r
+			break
+		}
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		parts[i] = SanitizeName(part, mode)
+	}
+	return filepath.Join(root, filepath.Join(parts...))
+}
@@ -0,0 +1,35 @@
+package processor
+
+import (
+	"os"
+
+	"github.com/apercova/wappd/scan"
+)
+
+// NewScanner returns the scanner a run with config uses to find files
+func NewScanner(config Config) scan.Scanner {
+	s := scan.Scanner{
+		IncludeDocuments: config.IncludeDocuments,
+		MaxDepth:         config.MaxDepth,
+		FollowSymlinks:   config.FollowSymlinks,
+		Extensions:       config.Extensions,
+	}
+	if len(config.SkipDirs) > 0 {
+		s.SkipDirs = scan.SkipNames(config.SkipDirs...)
+	}
+	if config.DetectContent {
+		s.Detect = func(path string) bool { return sniffPath(path) != "" }
+	}
+	return s
+}
+
+// sniffPath identifies the container family of a file from its first bytes
+// ("" when unknown or unreadable)
+func sniffPath(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return sniffFile(f)
+}
@@ -0,0 +1,20 @@
+package processor
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// SentComment is recorded in the metadata of files the phone's owner sent
+const SentComment = "wappd:direction=sent"
+
+// IsSent reports whether a file is media the phone's owner sent: WhatsApp
+// keeps those in a Sent folder inside each media folder
+func IsSent(path string) bool {
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if strings.EqualFold(dir, "sent") {
+			return true
+		}
+	}
+	return false
+}
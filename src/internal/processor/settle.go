@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+)
+
+// SkipReasonGrowing is the skip reason of files still changing after every
+// settle round
+const SkipReasonGrowing = "still being written"
+
+// settleRounds is how many times files found changing are checked again
+// before they are skipped
+const settleRounds = 3
+
+// ParseSettleTime parses a Settle duration such as "2s"; "" is 0, meaning
+// files are processed without checking they are complete
+func ParseSettleTime(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid settle time %q (expected a duration such as 2s)", s)
+	}
+	return d, nil
+}
+
+// fileState is what settled compares to tell that a file changed
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// settled stats the files at indices, waits for the settle time and stats
+// them again. Files whose size or modification time changed meanwhile are
+// still being written (by a sync client, a transfer or the phone) and are
+// returned as growing; the rest, including files that can't be read, which
+// processing reports, are stable.
+func (p *Processor) settled(filePaths []string, indices []int) (stable, growing []int) {
+	before := make(map[int]fileState, len(indices))
+	for _, i := range indices {
+		if info, err := p.fsys.Stat(filePaths[i]); err == nil {
+			before[i] = fileState{info.Size(), info.ModTime()}
+		}
+	}
+	time.Sleep(p.settle)
+	for _, i := range indices {
+		state, ok := before[i]
+		info, err := p.fsys.Stat(filePaths[i])
+		if ok && err == nil && (info.Size() != state.size || !info.ModTime().Equal(state.modTime)) {
+			growing = append(growing, i)
+		} else {
+			stable = append(stable, i)
+		}
+	}
+	return stable, growing
+}
@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sidecar is what SidecarOnly records about a file in its JSON sidecar; the
+// XMP sidecar holds the same date, tags and position
+type Sidecar struct {
+	File     string    `json:"file"`               // The original, which is left untouched
+	SHA256   string    `json:"sha256"`             // Hash of the original's bytes
+	Date     string    `json:"date"`               // Date from the filename, with its UTC offset under Timezone
+	DateUTC  string    `json:"dateUTC"`            // Date as an instant, RFC 3339 UTC
+	Inferred bool      `json:"inferred,omitempty"` // Date inferred from neighboring files
+	RenameTo string    `json:"renameTo,omitempty"` // Path the file would have been written to
+	Sent     bool      `json:"sent,omitempty"`     // Under a Sent folder (with TagSent)
+	Comment  string    `json:"comment,omitempty"`  // UserComment the metadata would have carried
+	Tags     []string  `json:"tags,omitempty"`
+	GPS      *GPSPoint `json:"gps,omitempty"`
+}
+
+// RenameEntry is one line of a rename map
+type RenameEntry struct {
+	Original string
+	Renamed  string
+	Date     string
+}
+
+// sidecarBase returns the path a file's sidecars are named after: the file
+// itself, or its place under OutputDir when there is one, so read-only
+// sources get nothing written next to them
+func (p *Processor) sidecarBase(filePath string) string {
+	if p.config.OutputDir == "" {
+		return filePath
+	}
+	rel, err := filepath.Rel(p.config.InputDir, filePath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(filePath)
+	}
+	return filepath.Join(p.config.OutputDir, rel)
+}
+
+// writeSidecars writes the "<file>.xmp" and "<file>.json" sidecars of a file
+// whose bytes hash to hash and returns their paths
+func (p *Processor) writeSidecars(filePath, hash, outputPath string, dateTime time.Time, result ProcessResult, comment string) ([]string, error) {
+	date := dateTime.Format("2006-01-02T15:04:05")
+	if p.config.Timezone != "" {
+		date = dateTime.Format("2006-01-02T15:04:05-07:00")
+	}
+	sidecar := Sidecar{
+		File:     filePath,
+		SHA256:   hash,
+		Date:     date,
+		DateUTC:  dateTime.UTC().Format(time.RFC3339),
+		Inferred: result.Inferred,
+		Sent:     result.Sent,
+		Comment:  comment,
+		Tags:     p.config.Tags,
+		GPS:      p.gpsAt(dateTime),
+	}
+	if outputPath != filePath {
+		sidecar.RenameTo = outputPath
+	}
+
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sidecar: %v", err)
+	}
+	base := p.sidecarBase(filePath)
+	if err := mkdirAllOwned(p.fsys, filepath.Dir(base), p.owner); err != nil {
+		return nil, fmt.Errorf("failed to create sidecar directory: %v", err)
+	}
+	paths := []string{base + ".xmp", base + ".json"}
+	for i, contents := range [][]byte{BuildSidecarXMP(sidecar), append(data, '\n')} {
+		if err := p.fsys.WriteFile(paths[i], contents, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write sidecar: %v", err)
+		}
+	}
+	return paths, nil
+}
+
+// BuildSidecarXMP builds the XMP sidecar of a file: its date as
+// exif:DateTimeOriginal, xmp:CreateDate and photoshop:DateCreated (the
+// fields photo managers read from sidecars), its tags as dc:subject and its
+// comment and position when it has them
+func BuildSidecarXMP(s Sidecar) []byte {
+	var b bytes.Buffer
+	text := func(open, value, close string) {
+		b.WriteString(open)
+		xml.EscapeText(&b, []byte(value))
+		b.WriteString(close)
+	}
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	b.WriteString("    xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	b.WriteString("    xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n")
+	b.WriteString("    xmlns:exif=\"http://ns.adobe.com/exif/1.0/\"\n")
+	b.WriteString("    xmlns:photoshop=\"http://ns.adobe.com/photoshop/1.0/\">\n")
+	text("   <exif:DateTimeOriginal>", s.Date, "</exif:DateTimeOriginal>\n")
+	text("   <xmp:CreateDate>", s.Date, "</xmp:CreateDate>\n")
+	text("   <photoshop:DateCreated>", s.Date, "</photoshop:DateCreated>\n")
+	if s.GPS != nil {
+		text("   <exif:GPSLatitude>", xmpCoordinate(s.GPS.Lat, "N", "S"), "</exif:GPSLatitude>\n")
+		text("   <exif:GPSLongitude>", xmpCoordinate(s.GPS.Lon, "E", "W"), "</exif:GPSLongitude>\n")
+	}
+	if s.Comment != "" {
+		text("   <exif:UserComment>\n    <rdf:Alt>\n     <rdf:li xml:lang=\"x-default\">", s.Comment, "</rdf:li>\n    </rdf:Alt>\n   </exif:UserComment>\n")
+	}
+	if len(s.Tags) > 0 {
+		b.WriteString("   <dc:subject>\n    <rdf:Bag>\n")
+		for _, tag := range s.Tags {
+			text("     <rdf:li>", tag, "</rdf:li>\n")
+		}
+		b.WriteString("    </rdf:Bag>\n   </dc:subject>\n")
+	}
+	b.WriteString("  </rdf:Description>\n")
+	b.WriteString(" </rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>\n")
+	return b.Bytes()
+}
+
+// xmpCoordinate formats a coordinate the way XMP's exif namespace takes it:
+// degrees, decimal minutes and a hemisphere letter, e.g. "40,25.0065N"
+func xmpCoordinate(degrees float64, positive, negative string) string {
+	hemisphere := positive
+	if degrees < 0 {
+		hemisphere = negative
+	}
+	degrees = math.Abs(degrees)
+	d := math.Floor(degrees)
+	return fmt.Sprintf("%d,%.4f%s", int(d), (degrees-d)*60, hemisphere)
+}
+
+// BuildRenameMap lists the successful SidecarOnly results whose output would
+// have been another path
+func BuildRenameMap(results []ProcessResult) []RenameEntry {
+	var entries []RenameEntry
+	for _, r := range results {
+		if !r.Success || r.PlannedPath == "" || r.PlannedPath == r.InputFile {
+			continue
+		}
+		entries = append(entries, RenameEntry{
+			Original: r.InputFile,
+			Renamed:  r.PlannedPath,
+			Date:     r.DateTime.Format("2006-01-02T15:04:05"),
+		})
+	}
+	return entries
+}
+
+// WriteRenameMap writes a rename map as CSV with an "original,renamed,date"
+// header, for applying the renames later or elsewhere. Its directory is
+// created if needed.
+func WriteRenameMap(path string, entries []RenameEntry) error {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"original", "renamed", "date"})
+	for _, e := range entries {
+		w.Write([]string{e.Original, e.Renamed, e.Date})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to encode rename map: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to write rename map: %v", err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write rename map: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Orders results can be reported in (--sort)
+const (
+	SortInput  = "input"  // Order the files were given in (the default)
+	SortName   = "name"   // Input path
+	SortDate   = "date"   // Date written, files without one last
+	SortStatus = "status" // Failed, then skipped, then successful files
+)
+
+// ValidateSortOrder checks a --sort value; "" means SortInput
+func ValidateSortOrder(order string) error {
+	switch order {
+	case "", SortInput, SortName, SortDate, SortStatus:
+		return nil
+	}
+	return fmt.Errorf("invalid sort order %q (expected input, name, date or status)", order)
+}
+
+// SortResults returns results in the given order. Results with equal keys
+// keep their input order, so the same batch is always reported the same
+// way whatever the concurrency.
+func SortResults(results []ProcessResult, order string) []ProcessResult {
+	sorted := append([]ProcessResult(nil), results...)
+	var less func(a, b ProcessResult) bool
+	switch order {
+	case SortName:
+		less = func(a, b ProcessResult) bool { return a.InputFile < b.InputFile }
+	case SortDate:
+		less = func(a, b ProcessResult) bool {
+			if a.DateTime.IsZero() || b.DateTime.IsZero() {
+				return !a.DateTime.IsZero() && b.DateTime.IsZero()
+			}
+			return a.DateTime.Before(b.DateTime)
+		}
+	case SortStatus:
+		less = func(a, b ProcessResult) bool { return resultRank(a) < resultRank(b) }
+	default:
+		return sorted
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// resultRank orders results by status for SortStatus
+func resultRank(r ProcessResult) int {
+	switch {
+	case r.Skipped:
+		return 1
+	case !r.Success:
+		return 0
+	}
+	return 2
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package processor
+
+import "io/fs"
+
+// isSparse reports that holes can't be detected on this platform, so
+// files are copied densely
+func isSparse(info fs.FileInfo) bool {
+	return false
+}
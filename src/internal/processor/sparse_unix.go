@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package processor
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// isSparse reports whether the file has holes: fewer 512-byte blocks are
+// allocated than its size needs
+func isSparse(info fs.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int64(st.Blocks)*512 < st.Size
+}
@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Sticker handling modes
+const (
+	StickersSkip    = "skip"    // Leave stickers untouched (default)
+	StickersMtime   = "mtime"   // Only set the file modification time
+	StickersProcess = "process" // Treat stickers like any other image
+)
+
+// ValidateStickerMode checks that the sticker mode is known ("" means skip)
+func ValidateStickerMode(mode string) error {
+	switch mode {
+	case "", StickersSkip, StickersMtime, StickersProcess:
+		return nil
+	}
+	return fmt.Errorf("unknown sticker mode %q (expected skip, mtime or process)", mode)
+}
+
+// IsSticker reports whether a file is a WhatsApp sticker: named STK-* or
+// stored under a Stickers directory (e.g. "WhatsApp Stickers")
+func IsSticker(path string) bool {
+	if strings.HasPrefix(filepath.Base(path), "STK-") {
+		return true
+	}
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		dir = strings.ToLower(dir)
+		if dir == "stickers" || strings.HasSuffix(dir, " stickers") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Streams reports whether ProcessStream can process files as they arrive.
+// Inferring dates, spreading times, settling and percentage failure limits
+// all need the whole batch first; with any of them ProcessStream collects
+// the paths and processes them like ProcessFiles.
+func (p *Processor) Streams() bool {
+	return !p.config.InferDates && !p.config.SpreadTimes && p.settle <= 0 &&
+		(p.maxFailures == nil || p.maxFailures.Percent == 0)
+}
+
+// ProcessStream processes the files sent on paths, starting as soon as the
+// first arrives instead of once the list is complete, so a huge tree starts
+// right away and only the results are kept. Results come back in the order
+// the paths were sent. paths must be closed once everything is sent; it's
+// always drained, even after an abort.
+func (p *Processor) ProcessStream(paths <-chan string) []ProcessResult {
+	if !p.Streams() {
+		var filePaths []string
+		for path := range paths {
+			filePaths = append(filePaths, path)
+		}
+		return p.ProcessFiles(filePaths)
+	}
+
+	var results []ProcessResult
+	for _, err := range []error{p.maxFailErr, p.settleErr, p.memoryErr} {
+		if err != nil {
+			for path := range paths {
+				results = append(results, ProcessResult{InputFile: path, Error: err})
+			}
+			return results
+		}
+	}
+
+	atomic.StoreInt32(&p.aborted, 0)
+	p.inferred = nil
+	p.shifts = nil
+	b := p.newBatch(0)
+	if p.concurrency <= 1 {
+		for path := range paths {
+			results = append(results, p.processInBatch(b, path))
+		}
+		return results
+	}
+
+	type job struct {
+		i    int
+		path string
+	}
+	jobs := make(chan job)
+	var mu sync.Mutex // Guards results, which grows as workers fill it in
+	var wg sync.WaitGroup
+	for w := 0; w < p.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result := p.processInBatch(b, j.path)
+				mu.Lock()
+				results[j.i] = result
+				mu.Unlock()
+			}
+		}()
+	}
+	i := 0
+	for path := range paths {
+		mu.Lock()
+		results = append(results, ProcessResult{InputFile: path})
+		mu.Unlock()
+		jobs <- job{i, path}
+		i++
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
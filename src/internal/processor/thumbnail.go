@@ -0,0 +1,191 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Thumbnails of GIFs
+	"image/jpeg"
+	_ "image/png" // Thumbnails of PNGs
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultThumbnailSize is the longest side of thumbnails, in pixels
+const DefaultThumbnailSize = 160
+
+// ErrNoThumbnail is returned for files no thumbnail can be made of: formats
+// Go can't decode (WebP, videos) when ffmpeg isn't installed, and non-media
+var ErrNoThumbnail = errors.New("no thumbnail available")
+
+// Thumbnailer makes small JPEG previews of photos and videos. JPEG, PNG and
+// GIF are decoded natively; WebP images and a keyframe of videos are
+// extracted with ffmpeg when it is on PATH. Thumbnails are cached in a
+// directory, keyed by the file's path, size and modification time, so
+// reports of the same files don't decode them again.
+type Thumbnailer struct {
+	cacheDir string // "" disables the cache
+	size     int
+	ffmpeg   bool
+}
+
+// NewThumbnailer returns a Thumbnailer making thumbnails whose longest side
+// is size pixels, cached under cacheDir ("" for no cache)
+func NewThumbnailer(cacheDir string, size int) *Thumbnailer {
+	if size <= 0 {
+		size = DefaultThumbnailSize
+	}
+	return &Thumbnailer{cacheDir: cacheDir, size: size, ffmpeg: FFmpegAvailable()}
+}
+
+// Thumbnail returns a JPEG thumbnail of the file at path
+func (t *Thumbnailer) Thumbnail(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if !isThumbnailFormat(ext) {
+		return nil, ErrNoThumbnail
+	}
+
+	cached := ""
+	if t.cacheDir != "" {
+		cached = filepath.Join(t.cacheDir, t.cacheKey(path, info)+".jpg")
+		if data, err := os.ReadFile(cached); err == nil {
+			return data, nil
+		}
+	}
+
+	data, err := t.generate(path, ext)
+	if err != nil {
+		return nil, err
+	}
+	if cached != "" {
+		// A thumbnail that can't be cached is still returned
+		t.store(cached, data)
+	}
+	return data, nil
+}
+
+// isThumbnailFormat reports whether ext is a photo or video format a
+// thumbnail may be made of
+func isThumbnailFormat(ext string) bool {
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return isVideoFormat(ext)
+}
+
+// cacheKey identifies a version of a file at the Thumbnailer's size
+func (t *Thumbnailer) cacheKey(path string, info os.FileInfo) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d\x00%d", abs, info.Size(), info.ModTime().UnixNano(), t.size)
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// generate makes a thumbnail, natively when Go can decode the file and with
+// ffmpeg otherwise
+func (t *Thumbnailer) generate(path, ext string) ([]byte, error) {
+	if !isVideoFormat(ext) {
+		data, err := t.decode(path)
+		if err == nil || !t.ffmpeg {
+			return data, err
+		}
+	}
+	if !t.ffmpeg {
+		return nil, ErrNoThumbnail
+	}
+	out, err := exec.Command("ffmpeg", FFmpegThumbnailArgs(path, t.size, isVideoFormat(ext))...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to extract a thumbnail: %v", err)
+	}
+	if len(out) == 0 {
+		return nil, ErrNoThumbnail
+	}
+	return out, nil
+}
+
+// decode scales an image Go can decode to a JPEG thumbnail
+func (t *Thumbnailer) decode(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, ErrNoThumbnail
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(img, t.size), &jpeg.Options{Quality: 75}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// store writes a thumbnail to the cache, through a temporary file so a
+// concurrent reader never sees half of it
+func (t *Thumbnailer) store(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// FFmpegThumbnailArgs builds the ffmpeg arguments that write a JPEG of the
+// first frame of input (the first keyframe of videos) to stdout, scaled so
+// its longest side is at most size pixels
+func FFmpegThumbnailArgs(input string, size int, keyframe bool) []string {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	if keyframe {
+		args = append(args, "-skip_frame", "nokey")
+	}
+	s := strconv.Itoa(size)
+	return append(args,
+		"-i", input,
+		"-frames:v", "1",
+		"-vf", "scale='min("+s+",iw)':'min("+s+",ih)':force_original_aspect_ratio=decrease",
+		"-f", "image2pipe", "-c:v", "mjpeg",
+		"-",
+	)
+}
+
+// scaleImage shrinks img so its longest side is at most size pixels,
+// sampling the nearest source pixel
+func scaleImage(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+	thumb := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			thumb.Set(x, y, img.At(b.Min.X+x*w/tw, b.Min.Y+y*h/th))
+		}
+	}
+	return thumb
+}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoadTimezone loads an IANA timezone (e.g. "Europe/Madrid") or "Local".
+// An empty name returns nil: filename times are then written as UTC, as before.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", name, err)
+	}
+	return loc, nil
+}
+
+// ResolveLocalTime interprets the wall-clock fields of naive (as extracted
+// from a filename) as local time in loc, handling DST transitions explicitly:
+//   - a time skipped by a spring-forward transition is moved forward by the
+//     length of the gap (02:30 in a 02:00→03:00 gap becomes 03:30)
+//   - a time repeated by a fall-back transition resolves to the earlier
+//     instant (the first occurrence, still on summer time)
+func ResolveLocalTime(naive time.Time, loc *time.Location) time.Time {
+	wall := time.Date(naive.Year(), naive.Month(), naive.Day(),
+		naive.Hour(), naive.Minute(), naive.Second(), naive.Nanosecond(), time.UTC).Unix()
+
+	// Offsets in effect a day either side cover any transition near this time
+	_, before := time.Unix(wall-86400, 0).In(loc).Zone()
+	_, after := time.Unix(wall+86400, 0).In(loc).Zone()
+
+	var resolved int64
+	found := false
+	for _, offset := range []int{before, after} {
+		candidate := wall - int64(offset)
+		if _, actual := time.Unix(candidate, 0).In(loc).Zone(); actual != offset {
+			continue
+		}
+		if !found || candidate < resolved {
+			resolved = candidate
+			found = true
+		}
+	}
+
+	if !found {
+		// Nonexistent local time: apply the pre-transition offset, which lands
+		// the same distance past the transition as the wall clock was past it
+		resolved = wall - int64(before)
+	}
+
+	return time.Unix(resolved, int64(naive.Nanosecond())).In(loc)
+}
+
+// FormatOffsetTime formats the UTC offset of t as an EXIF OffsetTime string
+// ("+01:00", "-05:30"), null-terminated (7 bytes total)
+func FormatOffsetTime(t time.Time) string {
+	return t.Format("-07:00") + "\x00"
+}
@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+)
+
+// StageTimings is how long a file spent in each stage of processing (with
+// Config.Timings)
+type StageTimings struct {
+	Parse   time.Duration // Filename date, embedded metadata checks and output path
+	Read    time.Duration // Hashing the input and output, and buffering it for validation
+	Copy    time.Duration // Copying the input to its output
+	Write   time.Duration // Writing and validating metadata
+	Chtimes time.Duration // Setting file times
+	Total   time.Duration // The whole file, including what no stage covers (locks, hooks)
+}
+
+// String formats the timings as "parse 1.2ms, read 3ms, ..., total 9ms"
+func (t StageTimings) String() string {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	return fmt.Sprintf("parse %s, read %s, copy %s, write %s, chtimes %s, total %s",
+		round(t.Parse), round(t.Read), round(t.Copy), round(t.Write), round(t.Chtimes), round(t.Total))
+}
+
+// SumTimings adds up the stage timings of results (those without timings
+// count for nothing)
+func SumTimings(results []ProcessResult) StageTimings {
+	var sum StageTimings
+	for _, r := range results {
+		if t := r.Timings; t != nil {
+			sum.Parse += t.Parse
+			sum.Read += t.Read
+			sum.Copy += t.Copy
+			sum.Write += t.Write
+			sum.Chtimes += t.Chtimes
+			sum.Total += t.Total
+		}
+	}
+	return sum
+}
+
+// Processing stages a stageTimer attributes time to
+const (
+	stageParse = iota
+	stageRead
+	stageCopy
+	stageWrite
+	stageChtimes
+)
+
+// stageTimer measures the stages of one file. Its methods do nothing on a
+// nil timer, which is what files get without Config.Timings.
+type stageTimer struct {
+	start   time.Time
+	mark    time.Time // End of the last lap
+	timings StageTimings
+}
+
+// newStageTimer starts timing a file
+func newStageTimer() *stageTimer {
+	now := time.Now()
+	return &stageTimer{start: now, mark: now}
+}
+
+// lap adds the time since the last lap to stage
+func (t *stageTimer) lap(stage int) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	d := now.Sub(t.mark)
+	t.mark = now
+	switch stage {
+	case stageParse:
+		t.timings.Parse += d
+	case stageRead:
+		t.timings.Read += d
+	case stageCopy:
+		t.timings.Copy += d
+	case stageWrite:
+		t.timings.Write += d
+	case stageChtimes:
+		t.timings.Chtimes += d
+	}
+}
+
+// done stops the timer and returns the file's timings
+func (t *stageTimer) done() *StageTimings {
+	if t == nil {
+		return nil
+	}
+	t.timings.Total = time.Since(t.start)
+	return &t.timings
+}
@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Transaction stages the outputs of a batch so they can be moved into place
+// all together once every file succeeded, or discarded together. Outputs
+// are staged next to where they will end up, so that committing is a
+// series of renames on the same filesystem.
+type Transaction struct {
+	mu     sync.Mutex
+	fsys   FS
+	dir    string
+	owner  *Owner // Owner of the output directories Commit creates
+	staged []stagedOutput
+}
+
+// stagedOutput is one output waiting in the staging directory
+type stagedOutput struct {
+	staged   string // Path in the staging directory
+	final    string // Where the output goes on commit
+	replaces string // Original removed on commit (renamed outputs), if any
+}
+
+// NewTransaction creates a transaction staging outputs in dir, which is
+// created if needed and removed by Commit or Rollback
+func NewTransaction(fsys FS, dir string) (*Transaction, error) {
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	return &Transaction{fsys: fsys, dir: dir}, nil
+}
+
+// Dir returns the staging directory
+func (t *Transaction) Dir() string {
+	return t.dir
+}
+
+// SetOwner gives the output directories Commit creates to owner (--chown)
+func (t *Transaction) SetOwner(owner *Owner) {
+	t.owner = owner
+}
+
+// Stage returns the staging path for an output that belongs at final. If
+// replaces is set, that file is removed once the output is committed.
+func (t *Transaction) Stage(final, replaces string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	staged := filepath.Join(t.dir, fmt.Sprintf("%d-%s", len(t.staged), filepath.Base(final)))
+	t.staged = append(t.staged, stagedOutput{staged: staged, final: final, replaces: replaces})
+	return staged
+}
+
+// Commit moves every staged output into place, replacing existing files,
+// then removes the staging directory, and returns how many outputs were
+// moved. Staged paths whose file is missing (the file failed and was
+// cleaned up) are skipped.
+func (t *Transaction) Commit() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	committed := 0
+	for _, s := range t.staged {
+		if _, err := t.fsys.Stat(s.staged); err != nil {
+			continue
+		}
+		if err := mkdirAllOwned(t.fsys, filepath.Dir(s.final), t.owner); err != nil {
+			return committed, fmt.Errorf("failed to create %s: %v", filepath.Dir(s.final), err)
+		}
+		if err := t.move(s.staged, s.final); err != nil {
+			return committed, fmt.Errorf("failed to move %s into place: %v", s.final, err)
+		}
+		committed++
+		if s.replaces != "" && s.replaces != s.final {
+			if err := t.fsys.Remove(s.replaces); err != nil {
+				return committed, fmt.Errorf("failed to remove original after renaming: %v", err)
+			}
+		}
+	}
+	t.staged = nil
+	if err := t.fsys.Remove(t.dir); err != nil {
+		return committed, fmt.Errorf("failed to remove staging directory: %v", err)
+	}
+	return committed, nil
+}
+
+// move renames src to dst, falling back to copying when they are on
+// different filesystems. The copy keeps src's modification time.
+func (t *Transaction) move(src, dst string) error {
+	if err := t.fsys.Rename(src, dst); err == nil {
+		return nil
+	}
+	info, err := t.fsys.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(t.fsys, src, dst, nil); err != nil {
+		return err
+	}
+	if err := t.fsys.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return t.fsys.Remove(src)
+}
+
+// Rollback discards every staged output and the staging directory, leaving
+// inputs and existing outputs as they were
+func (t *Transaction) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.staged {
+		if err := t.fsys.Remove(s.staged); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove staged %s: %v", s.staged, err)
+		}
+	}
+	t.staged = nil
+	if err := t.fsys.Remove(t.dir); err != nil {
+		return fmt.Errorf("failed to remove staging directory: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/jpeg"
+)
+
+// ErrWriteValidation is returned (wrapped) when a written file no longer
+// decodes like the original did; the original bytes have been restored
+var ErrWriteValidation = errors.New("write validation failed")
+
+// mediaSignature captures the properties a metadata write must not change
+type mediaSignature struct {
+	Width, Height int    // JPEG dimensions, as displayed (after EXIF Orientation)
+	Timescale     uint32 // MP4 mvhd timescale
+	Duration      uint64 // MP4 mvhd duration
+}
+
+// readMediaSignature decodes data as the given container family. ok is
+// false for formats without a validator.
+func readMediaSignature(data []byte, container string) (sig mediaSignature, ok bool, err error) {
+	switch container {
+	case ContainerJPEG:
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return sig, true, fmt.Errorf("JPEG does not decode: %v", err)
+		}
+		b := img.Bounds()
+		// Orientations 5-8 display the image on its side, and stay valid
+		// when NormalizeOrientation rotates the pixels to match
+		if jpegOrientation(data) >= 5 {
+			return mediaSignature{Width: b.Dy(), Height: b.Dx()}, true, nil
+		}
+		return mediaSignature{Width: b.Dx(), Height: b.Dy()}, true, nil
+
+	case ContainerMP4:
+		atoms, err := ParseMP4Atoms(data)
+		if err != nil {
+			return sig, true, fmt.Errorf("MP4 atoms do not parse: %v", err)
+		}
+		moov := FindAtom(atoms, "moov")
+		if moov == nil {
+			return sig, true, fmt.Errorf("moov %w", ErrAtomNotFound)
+		}
+		mvhd := FindAtomRecursive(*moov, "mvhd")
+		if mvhd == nil {
+			return sig, true, fmt.Errorf("mvhd %w", ErrAtomNotFound)
+		}
+		d := mvhd.Data
+		switch {
+		case len(d) >= 20 && d[0] == 0:
+			sig.Timescale = binary.BigEndian.Uint32(d[12:16])
+			sig.Duration = uint64(binary.BigEndian.Uint32(d[16:20]))
+		case len(d) >= 32 && d[0] == 1:
+			sig.Timescale = binary.BigEndian.Uint32(d[20:24])
+			sig.Duration = binary.BigEndian.Uint64(d[24:32])
+		default:
+			return sig, true, fmt.Errorf("mvhd atom too short")
+		}
+		return sig, true, nil
+	}
+	return sig, false, nil
+}
+
+// validateWrite checks that after still decodes like before. A file whose
+// original doesn't decode either can't be checked and is accepted.
+func validateWrite(before, after []byte) error {
+	container := SniffContainer(before)
+	want, ok, err := readMediaSignature(before, container)
+	if !ok || err != nil {
+		return nil
+	}
+	got, _, err := readMediaSignature(after, container)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("media properties changed: %+v -> %+v", want, got)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package processor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Video atoms, as listed in Config.VideoAtoms: where the native video and
+// M4A writers put the date
+const (
+	VideoAtomMvhd = "mvhd" // Movie header creation and modification times
+	VideoAtomTkhd = "tkhd" // Every track header's times
+	VideoAtomMdhd = "mdhd" // Every track's media header times
+	VideoAtomDay  = "day"  // moov/udta ©day text, in UTC
+	VideoAtomKeys = "keys" // An existing com.apple.quicktime.creationdate in moov/meta
+)
+
+// knownVideoAtoms lists the atoms in the order ValidateVideoAtoms names them
+var knownVideoAtoms = []string{VideoAtomMvhd, VideoAtomTkhd, VideoAtomMdhd, VideoAtomDay, VideoAtomKeys}
+
+// ValidateVideoAtoms checks that every name in a VideoAtoms list is an atom
+// the writers update
+func ValidateVideoAtoms(names []string) error {
+	for _, name := range names {
+		known := false
+		for _, a := range knownVideoAtoms {
+			known = known || name == a
+		}
+		if !known {
+			return fmt.Errorf("unknown video atom %q (expected %s)", name, strings.Join(knownVideoAtoms, ", "))
+		}
+	}
+	return nil
+}
+
+// videoAtomSet is the atoms one video or M4A write updates
+type videoAtomSet struct {
+	mvhd, tkhd, mdhd, day, keys bool
+}
+
+// defaultVideoAtoms is what videos get without VideoAtoms; M4A files also
+// get ©day
+var defaultVideoAtoms = videoAtomSet{mvhd: true, tkhd: true}
+
+// newVideoAtomSet returns the atoms names selects, or the defaults for ext
+// when names is empty
+func newVideoAtomSet(names []string, ext string) videoAtomSet {
+	if len(names) == 0 {
+		set := defaultVideoAtoms
+		set.day = ext == ".m4a"
+		return set
+	}
+	var set videoAtomSet
+	for _, name := range names {
+		switch name {
+		case VideoAtomMvhd:
+			set.mvhd = true
+		case VideoAtomTkhd:
+			set.tkhd = true
+		case VideoAtomMdhd:
+			set.mdhd = true
+		case VideoAtomDay:
+			set.day = true
+		case VideoAtomKeys:
+			set.keys = true
+		}
+	}
+	return set
+}
+
+// allHeaderAtoms is every atom with creation and modification times
+var allHeaderAtoms = videoAtomSet{mvhd: true, tkhd: true, mdhd: true}
+
+// inPlace reports whether every atom in the set is patched without resizing
+// the file: adding ©day rewrites moov
+func (s videoAtomSet) inPlace() bool {
+	return !s.day
+}
+
+// videoAtoms returns the atoms the native writers update in a file with ext
+func (p *Processor) videoAtoms(ext string) videoAtomSet {
+	return newVideoAtomSet(p.config.VideoAtoms, ext)
+}
+
+// appleCreationDateKey is the QuickTime metadata key iPhones record the
+// capture time under
+const appleCreationDateKey = "com.apple.quicktime.creationdate"
+
+// patchAppleCreationDate rewrites the com.apple.quicktime.creationdate value
+// in a moov payload's meta atom, in place, keeping the layout of the value
+// already there. A moov without the key is left alone: adding one means
+// rebuilding meta, which exiftool does better.
+func patchAppleCreationDate(moov []byte, dateTime time.Time) error {
+	meta := childAtom(moov, "meta")
+	// QuickTime's meta is a plain container starting with hdlr; the ISO
+	// one has a version and flags first
+	if len(meta) >= 12 && string(meta[4:8]) != "hdlr" && string(meta[8:12]) == "hdlr" {
+		meta = meta[4:]
+	}
+	keys, ilst := childAtom(meta, "keys"), childAtom(meta, "ilst")
+	if len(keys) < 8 || ilst == nil {
+		return nil
+	}
+
+	// Keys are numbered from 1; ilst items are named after the number
+	index := uint32(0)
+	count := binary.BigEndian.Uint32(keys[4:8])
+	for i, pos := uint32(1), 8; i <= count && pos+8 <= len(keys); i++ {
+		size := int(binary.BigEndian.Uint32(keys[pos : pos+4]))
+		if size < 8 || pos+size > len(keys) {
+			return fmt.Errorf("keys atom truncated")
+		}
+		if string(keys[pos+8:pos+size]) == appleCreationDateKey {
+			index = i
+			break
+		}
+		pos += size
+	}
+	if index == 0 {
+		return nil
+	}
+	var name [4]byte
+	binary.BigEndian.PutUint32(name[:], index)
+	data := childAtom(childAtom(ilst, string(name[:])), "data")
+	if len(data) < 8 {
+		return fmt.Errorf("%s has no data atom", appleCreationDateKey)
+	}
+
+	// The value follows the type indicator and locale
+	value := data[8:]
+	var text string
+	switch {
+	case len(value) == 24:
+		text = dateTime.Format("2006-01-02T15:04:05-0700")
+	case len(value) == 25:
+		text = dateTime.Format("2006-01-02T15:04:05-07:00")
+	case len(value) == 20 && value[19] == 'Z':
+		text = dateTime.UTC().Format("2006-01-02T15:04:05Z")
+	default:
+		return fmt.Errorf("%s value %q can't be rewritten in place", appleCreationDateKey, value)
+	}
+	copy(value, text)
+	return nil
+}
+
+// childAtom returns the payload of the first child of type atomType in a
+// container payload (nil when there's none). The payload shares data's
+// memory, so writing to it patches data.
+func childAtom(data []byte, atomType string) []byte {
+	for pos := 0; pos+8 <= len(data); {
+		size, t, headerLen, err := readAtomHeader(data, pos)
+		if err != nil {
+			return nil
+		}
+		if t == atomType {
+			return data[pos+headerLen : pos+int(size)]
+		}
+		pos += int(size)
+	}
+	return nil
+}
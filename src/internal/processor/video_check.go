@@ -0,0 +1,126 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// VideoHeaderTime is the creation time of one movie, track or media header
+type VideoHeaderTime struct {
+	Atom    string // Path of the atom in moov, e.g. "trak[1]/tkhd"
+	Created string // UTC, "2006-01-02T15:04:05"
+	Written bool   // The header is in the atoms the file was dated in (mvhd and tkhd by default), so it's compared; others are only shown
+}
+
+// VideoDateCheck compares the header times of a processed video with the
+// date its manifest entry says was written
+type VideoDateCheck struct {
+	Entry    ManifestEntry
+	Expected string            // UTC, "2006-01-02T15:04:05"
+	Headers  []VideoHeaderTime // mvhd, then each track's tkhd and mdhd
+	Status   VerifyStatus      // VerifyMissing when the headers couldn't be read
+	Error    error
+}
+
+// CheckVideoDates decodes the mvhd, tkhd and mdhd creation times of every
+// MP4/MOV/3GP video and M4A file in manifest and compares those in the
+// entry's VideoAtoms (mvhd and tkhd when it has none) with the dates wappd
+// wrote, normalized to UTC as the headers store them. Only the moov atom of
+// each file is read. Other files are left out.
+func CheckVideoDates(manifest *Manifest) []VideoDateCheck {
+	p := &Processor{fsys: OSFS}
+	var results []VideoDateCheck
+	for _, entry := range manifest.Entries {
+		ext := strings.ToLower(filepath.Ext(entry.OutputFile))
+		if actual := detectContainerMismatch(OSFS, entry.OutputFile); actual != "" {
+			ext = actual
+		}
+		if !isMovieFormat(ext) && ext != ".m4a" {
+			continue
+		}
+		moov, err := p.readMetadata(entry.OutputFile, ext)
+		if err != nil {
+			results = append(results, VideoDateCheck{Entry: entry, Expected: expectedDate(entry, true), Status: VerifyMissing, Error: err})
+			continue
+		}
+		results = append(results, CheckVideoHeaders(entry, moov))
+	}
+	return results
+}
+
+// CheckVideoHeaders compares the header times in data, a video file or its
+// moov atom, with the date entry says was written, in the headers of
+// entry's VideoAtoms. Fragmented files are
+// checked through their init segment's moov: the fragments carry no dates.
+// Version 1 headers (64-bit times) and extended-size atoms are read alike.
+func CheckVideoHeaders(entry ManifestEntry, data []byte) VideoDateCheck {
+	check := VideoDateCheck{Entry: entry, Expected: expectedDate(entry, true), Status: VerifyMissing}
+	atoms, err := ParseMP4Atoms(data)
+	if err != nil {
+		check.Error = err
+		return check
+	}
+	moov := FindAtom(atoms, "moov")
+	if moov == nil {
+		check.Error = fmt.Errorf("moov %w", ErrAtomNotFound)
+		return check
+	}
+	if check.Headers, err = videoHeaderTimes(*moov, newVideoAtomSet(entry.VideoAtoms, "")); err != nil {
+		check.Error = err
+		return check
+	}
+	if len(check.Headers) == 0 || check.Headers[0].Atom != "mvhd" {
+		check.Error = fmt.Errorf("mvhd %w in moov", ErrAtomNotFound)
+		return check
+	}
+
+	check.Status = VerifyOK
+	for _, h := range check.Headers {
+		if h.Written && h.Created != check.Expected {
+			check.Status = VerifyMismatch
+		}
+	}
+	return check
+}
+
+// videoHeaderTimes decodes the creation times of a moov's mvhd and of each
+// track's tkhd and mdhd, which share the version/flags + creation time
+// layout ReadMvhdTimes reads, marking those in written
+func videoHeaderTimes(moov Atom, written videoAtomSet) ([]VideoHeaderTime, error) {
+	var headers []VideoHeaderTime
+	add := func(path string, atom *Atom, written bool) error {
+		if atom == nil {
+			return nil
+		}
+		created, _, err := ReadMvhdTimes(atom.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		headers = append(headers, VideoHeaderTime{path, created.Format("2006-01-02T15:04:05"), written})
+		return nil
+	}
+
+	if err := add("mvhd", FindAtom(moov.Children, "mvhd"), written.mvhd); err != nil {
+		return nil, err
+	}
+	track := 0
+	for _, trak := range moov.Children {
+		if trak.Type != "trak" {
+			continue
+		}
+		track++
+		if err := add(fmt.Sprintf("trak[%d]/tkhd", track), FindAtom(trak.Children, "tkhd"), written.tkhd); err != nil {
+			return nil, err
+		}
+		if err := add(fmt.Sprintf("trak[%d]/mdia/mdhd", track), FindAtomRecursive(trak, "mdhd"), written.mdhd); err != nil {
+			return nil, err
+		}
+	}
+	return headers, nil
+}
+
+// String formats a header time as "trak[1]/tkhd 2024-05-01T08:00:00Z"
+func (h VideoHeaderTime) String() string {
+	return h.Atom + " " + h.Created + "Z"
+}
@@ -1,62 +1,53 @@
 package processor
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
-	"os"
 	"time"
 )
 
 // UpdateVideoMetadata updates creation date in MP4/MOV/3GP video files
 func UpdateVideoMetadata(filePath string, dateTime time.Time) error {
-	// Read the video file
-	data, err := readFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %v", err)
-	}
-
-	// Verify it's an MP4/MOV/3GP file (starts with ftyp atom)
-	if len(data) < 8 {
-		return fmt.Errorf("file too short to be a valid MP4/MOV/3GP")
-	}
+	return updateVideoMetadata(OSFS, filePath, dateTime, mmapMinSize, defaultVideoAtoms)
+}
 
-	// Check for ftyp atom (first atom should be ftyp)
-	firstType := string(data[4:8])
-	if firstType != "ftyp" {
-		return fmt.Errorf("file does not appear to be a valid MP4/MOV/3GP (missing ftyp atom)")
+// updateVideoMetadata is UpdateVideoMetadata over an arbitrary filesystem,
+// patching the header atoms in atoms (©day is left to updateQuickTimeDay)
+func updateVideoMetadata(fsys FS, filePath string, dateTime time.Time, mapFrom int64, atoms videoAtomSet) error {
+	// Files on disk of mapFrom bytes or more are patched through a memory
+	// mapping, so only the header pages are touched. Fall back to a full read/write when the
+	// platform or filesystem can't map the file.
+	if useMappedPatch(fsys, filePath, mapFrom) {
+		err := patchVideoMapped(filePath, dateTime, atoms)
+		if err != errMmapUnsupported {
+			return err
+		}
 	}
 
-	// Parse atoms
-	atoms, err := ParseMP4Atoms(data)
+	// Read the video file
+	data, err := fsys.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to parse MP4 atoms: %v", err)
-	}
-
-	// Find moov atom
-	moovAtom := FindAtom(atoms, "moov")
-	if moovAtom == nil {
-		return fmt.Errorf("moov atom not found")
+		return fmt.Errorf("failed to read file: %v", err)
 	}
 
-	// Find mvhd atom within moov
-	mvhdAtom := FindAtomRecursive(*moovAtom, "mvhd")
-	if mvhdAtom == nil {
-		return fmt.Errorf("mvhd atom not found in moov")
+	// Patch a copy of the headers
+	newData := make([]byte, len(data))
+	copy(newData, data)
+	if err := patchMovieHeaders(newData, dateTime, atoms); err != nil {
+		return err
 	}
-
-	// Update mvhd creation time
-	newData, err := updateMvhdCreationTime(data, *mvhdAtom, dateTime)
-	if err != nil {
-		return fmt.Errorf("failed to update mvhd: %v", err)
+	if bytes.Equal(newData, data) {
+		return nil
 	}
 
 	// Write file back
-	info, err := getFileInfo(filePath)
+	info, err := fsys.Stat(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to get file info: %v", err)
 	}
 
-	err = writeFile(filePath, newData, info.Mode())
+	err = fsys.WriteFile(filePath, newData, info.Mode())
 	if err != nil {
 		return fmt.Errorf("failed to write file: %v", err)
 	}
@@ -64,149 +55,136 @@ func UpdateVideoMetadata(filePath string, dateTime time.Time) error {
 	return nil
 }
 
-// updateMvhdCreationTime updates the creation time in mvhd atom
-func updateMvhdCreationTime(data []byte, mvhdAtom Atom, dateTime time.Time) ([]byte, error) {
-	// Find mvhd atom position in file
-	mvhdPos, err := findAtomPosition(data, "mvhd")
+// patchMovieHeaders sets the creation and modification times of the header
+// atoms in atoms (the mvhd atom, every track's tkhd and mdhd) and rewrites
+// an existing Apple creation date key, in place. Atom payloads are never
+// copied, so data may be a mapping of a multi-gigabyte file.
+func patchMovieHeaders(data []byte, dateTime time.Time, atoms videoAtomSet) error {
+	moov, err := findMoovPayload(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find mvhd position: %v", err)
+		return err
 	}
+	return patchMoov(moov, dateTime, atoms)
+}
 
-	// mvhd structure:
-	// - Header: 8 bytes (size + type)
-	// - Version: 1 byte (0 or 1)
-	// - Flags: 3 bytes
-	// - Creation time: 4 bytes (if version 0) or 8 bytes (if version 1)
-	// - Modification time: 4 bytes (if version 0) or 8 bytes (if version 1)
-	// - Timescale: 4 bytes
-	// - Duration: 4 bytes (if version 0) or 8 bytes (if version 1)
-	// - ... rest of mvhd data
-
-	if len(mvhdAtom.Data) < 4 {
-		return nil, fmt.Errorf("mvhd atom data too short")
+// findMoovPayload returns the payload of the moov atom holding a video's
+// headers, within data
+func findMoovPayload(data []byte) ([]byte, error) {
+	// Verify it's an MP4/MOV/3GP file (starts with ftyp atom)
+	if len(data) < 8 {
+		return nil, fmt.Errorf("file too short to be a valid MP4/MOV/3GP")
 	}
 
-	version := mvhdAtom.Data[0]
-	creationTimeOffset := 4 // After version (1) + flags (3)
-
-	// Convert dateTime to QuickTime timestamp
-	unixTime := dateTime.Unix()
-	qtTime := UnixToQuickTime(unixTime)
-
-	// Create new data copy
-	newData := make([]byte, len(data))
-	copy(newData, data)
+	// Check for ftyp atom (first atom should be ftyp)
+	firstType := string(data[4:8])
+	if firstType == "styp" {
+		return nil, fmt.Errorf("%w: file is a fragmented MP4 media segment without an init segment (no moov to update)", ErrUnsupportedContainer)
+	}
+	if firstType != "ftyp" {
+		return nil, fmt.Errorf("%w: file does not appear to be a valid MP4/MOV/3GP (missing ftyp atom)", ErrUnsupportedContainer)
+	}
 
-	if version == 0 {
-		// Version 0: 32-bit timestamps
-		if mvhdPos+8+creationTimeOffset+4 > len(newData) {
-			return nil, fmt.Errorf("mvhd atom extends beyond file")
+	// Find moov atom. Fragmented MP4s keep it in the init segment at the
+	// start of the file; the moof fragments carry no wall-clock dates (tfdt is
+	// a decode timestamp), so the init segment's mvhd is the one to update.
+	moovPos, moovSize, moovHeader, fragmented := -1, 0, 0, false
+	for pos := 0; pos+8 <= len(data); {
+		size, atomType, headerLen, err := readAtomHeader(data, pos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse MP4 atoms: %v", err)
 		}
-		binary.BigEndian.PutUint32(newData[mvhdPos+8+creationTimeOffset:mvhdPos+8+creationTimeOffset+4], qtTime)
-		// Also update modification time (4 bytes after creation time)
-		binary.BigEndian.PutUint32(newData[mvhdPos+8+creationTimeOffset+4:mvhdPos+8+creationTimeOffset+8], qtTime)
-	} else if version == 1 {
-		// Version 1: 64-bit timestamps
-		if mvhdPos+8+creationTimeOffset+8 > len(newData) {
-			return nil, fmt.Errorf("mvhd atom extends beyond file")
+		if atomType == "moov" && moovPos < 0 {
+			moovPos, moovSize, moovHeader = pos, int(size), headerLen
 		}
-		binary.BigEndian.PutUint64(newData[mvhdPos+8+creationTimeOffset:mvhdPos+8+creationTimeOffset+8], uint64(qtTime))
-		// Also update modification time (8 bytes after creation time)
-		binary.BigEndian.PutUint64(newData[mvhdPos+8+creationTimeOffset+8:mvhdPos+8+creationTimeOffset+16], uint64(qtTime))
-	} else {
-		return nil, fmt.Errorf("unsupported mvhd version: %d", version)
+		fragmented = fragmented || atomType == "moof"
+		pos += int(size)
 	}
-
-	return newData, nil
-}
-
-// findAtomPosition finds the byte position of an atom in the file
-func findAtomPosition(data []byte, atomType string) (int, error) {
-	pos := 0
-
-	for pos < len(data) {
-		if pos+8 > len(data) {
-			break
+	if moovPos < 0 {
+		if fragmented {
+			return nil, fmt.Errorf("fragmented MP4 has no moov init segment")
 		}
+		return nil, fmt.Errorf("moov %w", ErrAtomNotFound)
+	}
+	return data[moovPos+moovHeader : moovPos+moovSize], nil
+}
 
-		size := binary.BigEndian.Uint32(data[pos : pos+4])
-		currentType := string(data[pos+4 : pos+8])
-
-		if currentType == atomType {
-			return pos, nil
+// patchMoov patches the header atoms in atoms within a moov payload
+func patchMoov(moov []byte, dateTime time.Time, atoms videoAtomSet) error {
+	found, err := patchHeaderAtoms(moov, UnixToQuickTime(dateTime.Unix()), atoms)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("mvhd %w in moov", ErrAtomNotFound)
+	}
+	if atoms.keys {
+		if err := patchAppleCreationDate(moov, dateTime); err != nil {
+			return fmt.Errorf("failed to update keys: %w", err)
 		}
+	}
+	return nil
+}
 
-		if size == 0 {
+// patchHeaderAtoms patches the mvhd, tkhd and mdhd atoms in atoms among the
+// descendants of a moov (or trak, mdia) payload and reports whether an mvhd
+// was found, patched or not
+func patchHeaderAtoms(data []byte, qtTime uint32, atoms videoAtomSet) (bool, error) {
+	foundMvhd := false
+	for pos := 0; pos+8 <= len(data); {
+		size, atomType, headerLen, err := readAtomHeader(data, pos)
+		if err != nil {
 			break
-		} else if size == 1 {
-			return -1, fmt.Errorf("extended size atoms not supported")
 		}
-
-		// If it's a container atom, search recursively
-		if isContainerAtom(currentType) && size > 8 {
-			childPos, err := findAtomInChildren(data[pos+8:pos+int(size)], atomType)
-			if err == nil {
-				return pos + 8 + childPos, nil
+		payload := data[pos+headerLen : pos+int(size)]
+		switch atomType {
+		case "mvhd", "tkhd", "mdhd":
+			selected := atomType == "mvhd" && atoms.mvhd || atomType == "tkhd" && atoms.tkhd || atomType == "mdhd" && atoms.mdhd
+			if selected {
+				if err := patchHeaderTimes(payload, qtTime); err != nil {
+					return foundMvhd, fmt.Errorf("failed to update %s: %w", atomType, err)
+				}
+			}
+			foundMvhd = foundMvhd || atomType == "mvhd"
+		case "trak", "mdia":
+			if _, err := patchHeaderAtoms(payload, qtTime, atoms); err != nil {
+				return foundMvhd, err
 			}
 		}
-
 		pos += int(size)
 	}
-
-	return -1, fmt.Errorf("atom %s not found", atomType)
+	return foundMvhd, nil
 }
 
-// findAtomInChildren searches for an atom in child data
-func findAtomInChildren(data []byte, atomType string) (int, error) {
-	pos := 0
-
-	for pos < len(data) {
-		if pos+8 > len(data) {
-			break
-		}
-
-		size := binary.BigEndian.Uint32(data[pos : pos+4])
-		currentType := string(data[pos+4 : pos+8])
-
-		if currentType == atomType {
-			return pos, nil
-		}
+// patchHeaderTimes writes qtTime as the creation and modification time of an
+// mvhd, tkhd or mdhd payload
+func patchHeaderTimes(payload []byte, qtTime uint32) error {
+	// mvhd/tkhd/mdhd structure:
+	// - Version: 1 byte (0 or 1)
+	// - Flags: 3 bytes
+	// - Creation time: 4 bytes (if version 0) or 8 bytes (if version 1)
+	// - Modification time: 4 bytes (if version 0) or 8 bytes (if version 1)
+	// - ... rest of the atom data
+	if len(payload) < 4 {
+		return fmt.Errorf("atom data too short")
+	}
 
-		if size == 0 {
-			break
-		} else if size == 1 {
-			return -1, fmt.Errorf("extended size atoms not supported")
+	switch version := payload[0]; version {
+	case 0:
+		// Version 0: 32-bit timestamps
+		if len(payload) < 12 {
+			return fmt.Errorf("atom extends beyond file")
 		}
-
-		// Recursively search in children
-		if isContainerAtom(currentType) && size > 8 {
-			childPos, err := findAtomInChildren(data[pos+8:pos+int(size)], atomType)
-			if err == nil {
-				return pos + 8 + childPos, nil
-			}
+		binary.BigEndian.PutUint32(payload[4:8], qtTime)
+		binary.BigEndian.PutUint32(payload[8:12], qtTime)
+	case 1:
+		// Version 1: 64-bit timestamps
+		if len(payload) < 20 {
+			return fmt.Errorf("atom extends beyond file")
 		}
-
-		pos += int(size)
+		binary.BigEndian.PutUint64(payload[4:12], uint64(qtTime))
+		binary.BigEndian.PutUint64(payload[12:20], uint64(qtTime))
+	default:
+		return fmt.Errorf("%w: version %d", ErrUnsupportedContainer, version)
 	}
-
-	return -1, fmt.Errorf("atom %s not found in children", atomType)
-}
-
-// Helper functions to abstract file operations (for testing/mocking)
-var (
-	readFile   = readFileImpl
-	writeFile  = writeFileImpl
-	getFileInfo = getFileInfoImpl
-)
-
-func readFileImpl(path string) ([]byte, error) {
-	return os.ReadFile(path)
-}
-
-func writeFileImpl(path string, data []byte, mode os.FileMode) error {
-	return os.WriteFile(path, data, mode)
-}
-
-func getFileInfoImpl(path string) (os.FileInfo, error) {
-	return os.Stat(path)
+	return nil
 }
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apercova/wappd/scan"
+)
+
+// Media kinds a target can be restricted to
+const (
+	MediaImages    = "images"
+	MediaVideos    = "videos"
+	MediaAudio     = "audio"
+	MediaDocuments = "documents"
+)
+
+// ValidateMediaKind checks a target media kind ("" = all media)
+func ValidateMediaKind(kind string) error {
+	switch kind {
+	case "", MediaImages, MediaVideos, MediaAudio, MediaDocuments:
+		return nil
+	}
+	return fmt.Errorf("invalid media kind %q (must be images, videos, audio or documents)", kind)
+}
+
+// isMediaKind reports whether a file extension belongs to a media kind
+func isMediaKind(ext, kind string) bool {
+	switch kind {
+	case MediaImages:
+		return isImageFormat(ext)
+	case MediaVideos:
+		return isVideoFormat(ext)
+	case MediaAudio:
+		return isAudioFormat(ext)
+	case MediaDocuments:
+		return scan.IsDocument(ext)
+	}
+	return true
+}
+
+// FilterMediaKind keeps the files of one media kind ("" keeps everything)
+func FilterMediaKind(filePaths []string, kind string) []string {
+	if kind == "" {
+		return filePaths
+	}
+	var kept []string
+	for _, path := range filePaths {
+		if MatchesMediaKind(path, kind) {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
+// MatchesMediaKind reports whether FilterMediaKind keeps a file
+func MatchesMediaKind(path, kind string) bool {
+	return kind == "" || isMediaKind(strings.ToLower(filepath.Ext(path)), kind)
+}
+
+// whatsAppFolders maps the standard folders under WhatsApp/Media to the
+// media they hold. Other folders (.Statuses, Profile Photos, WallPaper...)
+// aren't chat media and are left alone.
+var whatsAppFolders = []struct {
+	name  string
+	media string
+}{
+	{"WhatsApp Images", MediaImages},
+	{"WhatsApp Video", MediaVideos},
+	{"WhatsApp Animated Gifs", MediaVideos},
+	{"WhatsApp Voice Notes", MediaAudio},
+	{"WhatsApp Audio", MediaAudio},
+	{"WhatsApp Documents", MediaDocuments},
+	{"WhatsApp Stickers", MediaImages},
+}
+
+// WhatsAppTargets finds the standard media folders of a WhatsApp tree and
+// returns one target per folder, restricted to the media it holds.
+// Documents folders enable document processing. root may be the WhatsApp
+// folder, its Media folder or the directory containing WhatsApp. WhatsApp
+// Business trees, whose folders are named "WhatsApp Business Images" and
+// so on, are recognized too, and a directory holding both a WhatsApp and a
+// WhatsApp Business folder gets the targets of both.
+func WhatsAppTargets(root string) ([]Target, error) {
+	for _, mediaDirs := range [][]string{
+		{filepath.Join(root, "Media")},
+		{filepath.Join(root, "WhatsApp", "Media"), filepath.Join(root, "WhatsApp Business", "Media")},
+		{root},
+	} {
+		var targets []Target
+		for _, mediaDir := range mediaDirs {
+			targets = append(targets, whatsAppMediaTargets(mediaDir)...)
+		}
+		if len(targets) > 0 {
+			return targets, nil
+		}
+	}
+	return nil, fmt.Errorf("no WhatsApp media folders (WhatsApp Images, WhatsApp Business Images, WhatsApp Video...) found under %s", root)
+}
+
+// whatsAppMediaTargets returns the targets of the standard folders found
+// directly in a Media directory
+func whatsAppMediaTargets(mediaDir string) []Target {
+	var targets []Target
+	for _, folder := range whatsAppFolders {
+		business := "WhatsApp Business" + strings.TrimPrefix(folder.name, "WhatsApp")
+		for _, name := range []string{folder.name, business} {
+			dir := filepath.Join(mediaDir, name)
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				continue
+			}
+			target := Target{Dir: dir, Media: folder.media}
+			if folder.media == MediaDocuments {
+				includeDocuments := true
+				target.IncludeDocuments = &includeDocuments
+			}
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Writers, as listed in Config.Writers: each one changes one kind of file
+// in one way, so they can be turned on one at a time
+const (
+	WriterJPEGExif = "jpegExif"  // EXIF (and XMP keywords) of JPEGs
+	WriterMP4Mvhd  = "mp4Mvhd"   // mvhd/tkhd dates of MP4, MOV, M4V and 3GP videos
+	WriterM4ADate  = "m4aDate"   // mvhd and ©day of M4A voice notes
+	WriterOpusDate = "opusDate"  // DATE comment of Opus voice notes
+	WriterPDFDate  = "pdfDate"   // CreationDate of PDFs (with IncludeDocuments)
+	WriterPNG      = "pngChunks" // eXIf/tEXt date chunks of PNGs (exiftool backend)
+	WriterWebP     = "webpExif"  // EXIF of WebP images (exiftool backend)
+	WriterHEIC     = "heicExif"  // EXIF of HEIC/HEIF images (exiftool backend)
+	WriterGIF      = "gifXmp"    // XMP of GIFs (exiftool backend)
+	WriterMtime    = "mtime"     // File modification (and access) times
+)
+
+// knownWriters lists the writers in the order ValidateWriters names them
+var knownWriters = []string{WriterJPEGExif, WriterMP4Mvhd, WriterM4ADate, WriterOpusDate, WriterPDFDate, WriterPNG, WriterWebP, WriterHEIC, WriterGIF, WriterMtime}
+
+// ValidateWriters checks that every name in a Writers list is a writer
+func ValidateWriters(names []string) error {
+	for _, name := range names {
+		known := false
+		for _, w := range knownWriters {
+			known = known || name == w
+		}
+		if !known {
+			return fmt.Errorf("unknown writer %q (expected %s)", name, strings.Join(knownWriters, ", "))
+		}
+	}
+	return nil
+}
+
+// formatWriter returns the writer that dates files of ext, whichever
+// backend runs it; "" for formats no writer covers
+func formatWriter(ext string) string {
+	switch {
+	case ext == ".jpg" || ext == ".jpeg":
+		return WriterJPEGExif
+	case isMovieFormat(ext):
+		return WriterMP4Mvhd
+	case ext == ".m4a":
+		return WriterM4ADate
+	case ext == ".opus":
+		return WriterOpusDate
+	case ext == ".pdf":
+		return WriterPDFDate
+	case ext == ".png":
+		return WriterPNG
+	case ext == ".webp":
+		return WriterWebP
+	case ext == ".heic" || ext == ".heif":
+		return WriterHEIC
+	case ext == ".gif":
+		return WriterGIF
+	}
+	return ""
+}
+
+// disabledWriter says why a file of ext keeps its metadata under Writers
+func disabledWriter(ext string) string {
+	if writer := formatWriter(ext); writer != "" {
+		return fmt.Sprintf("the %s writer isn't enabled", writer)
+	}
+	return fmt.Sprintf("no writer for %s can be enabled", ext)
+}
+
+// writerEnabled reports whether Writers lets writer run; every writer does
+// when the list is empty, and "" (a format no writer covers) never does
+// with a list
+func (p *Processor) writerEnabled(writer string) bool {
+	if len(p.config.Writers) == 0 {
+		return true
+	}
+	for _, w := range p.config.Writers {
+		if w == writer {
+			return true
+		}
+	}
+	return false
+}
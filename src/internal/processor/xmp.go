@@ -0,0 +1,142 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// xmpIdentifier starts the payload of an XMP APP1 segment
+const xmpIdentifier = "http://ns.adobe.com/xap/1.0/\x00"
+
+// ValidateTags checks that no keyword is blank
+func ValidateTags(tags []string) error {
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("tags must not be empty")
+		}
+	}
+	return nil
+}
+
+// BuildXMPPacket builds an XMP packet listing keywords as dc:subject, the
+// field photo managers import as tags
+func BuildXMPPacket(keywords []string) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n")
+	b.WriteString("   <dc:subject>\n    <rdf:Bag>\n")
+	for _, keyword := range keywords {
+		b.WriteString("     <rdf:li>")
+		xml.EscapeText(&b, []byte(keyword))
+		b.WriteString("</rdf:li>\n")
+	}
+	b.WriteString("    </rdf:Bag>\n   </dc:subject>\n")
+	b.WriteString("  </rdf:Description>\n")
+	b.WriteString(" </rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+// MergeXMPKeywords adds keywords missing from the dc:subject of an existing
+// XMP packet, creating dc:subject when there is none. The rest of the packet
+// (face regions, ratings, labels left by other tools) is kept byte for byte;
+// whitespace padding before the packet trailer absorbs the growth when there
+// is enough of it. A packet whose dc:subject isn't a plain rdf:Bag can't be
+// merged into and returns an error.
+func MergeXMPKeywords(packet []byte, keywords []string) ([]byte, error) {
+	text := string(packet)
+	var insert string
+	at := -1
+	if start := strings.Index(text, "<dc:subject"); start >= 0 {
+		bagOpen := strings.Index(text[start:], "<rdf:Bag>")
+		bagClose := strings.Index(text[start:], "</rdf:Bag>")
+		subjectClose := strings.Index(text[start:], "</dc:subject>")
+		if bagOpen < 0 || bagClose < bagOpen || subjectClose < bagClose {
+			return nil, fmt.Errorf("unsupported dc:subject in existing XMP")
+		}
+		existing := map[string]bool{}
+		var bag struct {
+			Items []string `xml:"li"`
+		}
+		if err := xml.Unmarshal([]byte(text[start+bagOpen:start+bagClose+len("</rdf:Bag>")]), &bag); err != nil {
+			return nil, fmt.Errorf("invalid dc:subject in existing XMP: %v", err)
+		}
+		for _, item := range bag.Items {
+			existing[strings.TrimSpace(item)] = true
+		}
+		var b bytes.Buffer
+		for _, keyword := range keywords {
+			if existing[keyword] {
+				continue
+			}
+			existing[keyword] = true
+			b.WriteString("<rdf:li>")
+			xml.EscapeText(&b, []byte(keyword))
+			b.WriteString("</rdf:li>")
+		}
+		insert, at = b.String(), start+bagClose
+	} else {
+		rdfClose := strings.Index(text, "</rdf:RDF>")
+		if rdfClose < 0 {
+			return nil, fmt.Errorf("existing XMP has no rdf:RDF element")
+		}
+		subject := BuildXMPPacket(keywords)
+		from := bytes.Index(subject, []byte("<rdf:Description"))
+		to := bytes.Index(subject, []byte("</rdf:Description>")) + len("</rdf:Description>")
+		insert, at = string(subject[from:to])+"\n", rdfClose
+	}
+	if insert == "" {
+		return packet, nil
+	}
+
+	// Give up padding before the trailer for the inserted text
+	merged := text[:at] + insert + text[at:]
+	if end := strings.LastIndex(merged, "<?xpacket end"); end >= 0 {
+		padding := len(merged[:end]) - len(strings.TrimRight(merged[:end], " \t\r\n"))
+		if trim := min(len(insert), padding-1); trim > 0 {
+			merged = merged[:end-trim] + merged[end:]
+		}
+	}
+	return []byte(merged), nil
+}
+
+// FindXMPSegment finds the XMP APP1 segment
+func FindXMPSegment(segments []JPEGSegment) (int, *JPEGSegment) {
+	for i, seg := range segments {
+		if seg.Marker == markerAPP1 && bytes.HasPrefix(seg.Payload, []byte(xmpIdentifier)) {
+			return i, &seg
+		}
+	}
+	return -1, nil
+}
+
+// InsertXMPSegment inserts or replaces the XMP APP1 segment of a JPEG. A new
+// segment goes right after the EXIF segment, where readers expect it.
+func InsertXMPSegment(data []byte, packet []byte) ([]byte, error) {
+	segments, err := ParseJPEGSegments(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JPEG: %v", err)
+	}
+
+	xmpSegment, err := newAPP1Segment("XMP packet", append([]byte(xmpIdentifier), packet...))
+	if err != nil {
+		return nil, err
+	}
+
+	if xmpIndex, _ := FindXMPSegment(segments); xmpIndex >= 0 {
+		segments[xmpIndex] = xmpSegment
+	} else {
+		at := 0
+		if exifIndex, _ := FindAPP1Segment(segments); exifIndex >= 0 {
+			at = exifIndex + 1
+		}
+		segments = append(segments[:at], append([]JPEGSegment{xmpSegment}, segments[at:]...)...)
+	}
+
+	return ReassembleJPEG(segments, data[jpegImageDataStart(data):]), nil
+}
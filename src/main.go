@@ -1,81 +1,193 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // Embed zone data so --timezone works on systems without it
 
 	"github.com/apercova/wappd/internal/processor"
+	"github.com/apercova/wappd/scan"
 	"github.com/apercova/wappd/version"
 )
 
 func main() {
-	// Define command-line flags
-	filePath := flag.String("f", "", "Path to a specific file to process")
-	dirPath := flag.String("d", ".", "Input directory (default: current directory)")
+	// Dispatch subcommands before parsing the main flag set
+	processArgs := os.Args[1:]
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "help":
+			os.Exit(runHelp(os.Args[2:]))
+		case "init":
+			os.Exit(runInit(os.Args[2:]))
+		case "doctor":
+			os.Exit(runDoctor(os.Args[2:]))
+		case "inspect":
+			os.Exit(runInspect(os.Args[2:]))
+		case "dates":
+			os.Exit(runDates(os.Args[2:]))
+		case "compare":
+			os.Exit(runCompare(os.Args[2:]))
+		case "verify":
+			os.Exit(runVerify(os.Args[2:]))
+		case "runs":
+			os.Exit(runRuns(os.Args[2:]))
+		case "restore-times":
+			os.Exit(runRestoreTimes(os.Args[2:]))
+		case "recover":
+			os.Exit(runRecover(os.Args[2:]))
+		case "gen-samples":
+			os.Exit(runGenSamples(os.Args[2:]))
+		case "bundle-debug":
+			os.Exit(runBundleDebug(os.Args[2:]))
+		case "pull-android":
+			processArgs = runPullAndroid(os.Args[2:])
+		case "retry":
+			processArgs = runRetry(os.Args[2:])
+		}
+	}
+
+	// Define command-line flags: a long name for each, with the short names
+	// they have always had
+	cli := newCLIFlags(flag.CommandLine)
+
+	cli.Group("Input")
+	filePath := cli.String("file,f", "", "Path to a specific file to process")
+	var dirPaths stringList
+	cli.Var(&dirPaths, "dir,d", "Input directory or sftp:// / smb:// URL; repeat to process several (default: current directory)")
+	filesFrom := cli.String("files-from", "", "Process the files listed in this file, one path per line (\"-\" for standard input), e.g. a --failed-list")
+	whatsappRoot := cli.String("whatsapp-root", "", "WhatsApp folder of a backup: process each standard media folder (Images, Video, Voice Notes...) with matching handling")
+	includeDocuments := cli.Bool("include-documents", false, "Also process WhatsApp documents (DOC-*.pdf etc.): set mtime and PDF CreationDate")
+	detectContent := cli.Bool("detect-content", false, "Also scan directories for files whose content is a known image or video container, whatever their extension")
+	extensions := cli.String("extensions", "", "Also scan directories for these extensions, or leave them out with a leading -; comma-separated (e.g. heic,-gif)")
+	maxDepth := cli.Int("max-depth", 0, "Directory levels to scan: 1 = the directory's own files only (0 = no limit)")
+	followSymlinks := cli.Bool("follow-symlinks", false, "Scan into symlinked directories (each real directory once)")
+	skipDirs := cli.String("skip-dirs", "", "Leave directories with these names out of scans, * and ? match any characters; comma-separated (e.g. .Statuses,Backup*)")
+	stickers := cli.String("stickers", "", "How to handle WhatsApp stickers: skip, mtime or process (default: skip)")
+	extraPatterns := cli.String("extra-patterns", "", "Also date files named by other sources, after the WhatsApp patterns: camera (IMG_20240501_123045.jpg); comma-separated")
+	strict := cli.Bool("strict", false, "Refuse to process anything if a filename matches no WhatsApp pattern")
+	ignoreUnmatched := cli.Bool("ignore-unmatched", false, "Skip filenames that match no WhatsApp pattern instead of counting them as failures")
+	settle := cli.String("settle", "", "Wait this long before processing and retry files still being written (e.g. by a sync client), e.g. 2s")
+
+	cli.Group("Configuration")
 	var configFile string
-	flag.StringVar(&configFile, "cf", "", "Path to config file (default: wappd.json in working directory)")
-	flag.StringVar(&configFile, "config-file", "", "Path to config file (alias for -cf)")
-	updateModified := flag.Bool("m", false, "Also update file's last modified date")
-	overwriteExif := flag.Bool("ow", false, "Overwrite existing EXIF data")
-	overrideOriginal := flag.Bool("o", false, "Override original files (don't add suffix)")
-	outputDir := flag.String("out", "", "Output directory for processed files")
-	verbose := flag.Bool("v", false, "Verbose output (show detailed processing information)")
-	dryRun := flag.Bool("dry-run", false, "Preview changes without modifying files")
-	showVersion := flag.Bool("version", false, "Show version information")
+	cli.StringVar(&configFile, "config-file,cf", "", "Path to config file (default: wappd.json in working directory)")
+	profile := cli.String("profile", "", "Apply the named profile from the config file's \"profiles\" on top of its other options")
+	preset := cli.String("preset", "", "Start from a built-in option set: "+strings.Join(processor.PresetNames(), ", ")+" (config files and flags override it)")
+
+	cli.Group("Output")
+	overrideOriginal := cli.Bool("override-original,o", false, "Override original files (don't add suffix)")
+	outputDir := cli.String("output,out", "", "Output directory or s3:// / gs:// URI for processed files (uploads are single PUTs, up to 5 GiB per file)")
+	outputTemplate := cli.String("output-template", "", "Output path of each file, e.g. \"{out}/{year}/{name}_fixed{ext}\" (placeholders: {dir} {out} {rel} {name} {ext} {year} {month} {day} {date} {hash})")
+	renameHash := cli.Bool("rename-hash", false, "Name outputs by date and content hash in year/month folders: {out}/{year}/{month}/{date}_{hash}{ext}")
+	sanitizeNames := cli.String("sanitize-names", "", "Make output names valid on another filesystem: windows (reserved names, <>:\"\\|?*) or macos (colons)")
+	fixExtensions := cli.Bool("fix-extensions", false, "Rename files whose content doesn't match their extension (e.g. MP4 saved as .gif)")
+	noCopy := cli.Bool("no-copy", false, "Refuse to write copies (_modified files, -out): with -o, only edit originals in place")
+	sidecarOnly := cli.Bool("sidecar-only", false, "Never modify media files: write each file's date to <file>.xmp and <file>.json sidecars (under -out when given)")
+	renameMap := cli.String("rename-map", "", "With --sidecar-only, write the renames the run would have made to this CSV file")
+	chown := cli.String("chown", "", "Give outputs and the directories created for them to user:group (names or IDs, Unix only)")
+	repack := cli.Bool("repack", false, "Repack processed media into a new archive when -f is an archive")
+
+	cli.Group("Dates")
+	timezone := cli.String("timezone", "", "IANA timezone filename times are local to, e.g. Europe/Madrid (default: UTC)")
+	offset := cli.String("offset", "", "Shift every extracted date to correct clock skew, e.g. +2h30m, -45m, +1d")
+	datePolicy := cli.String("date-policy", "", "Date to keep when a file's embedded date differs from its filename's: earliest, filename (like -ow) or existing")
+	inferDates := cli.Bool("infer-dates", false, "Date files no pattern matches from the matched files around them (e.g. edited copies)")
+	disambiguateTimes := cli.Bool("disambiguate-times", false, "Spread files that share a date (e.g. forwarded albums) one second apart, in name order")
+
+	cli.Group("Metadata")
+	updateModified := cli.Bool("update-modified,m", false, "Also update file's last modified date")
+	overwriteExif := cli.Bool("overwrite-exif,ow", false, "Overwrite existing EXIF data")
+	mtimeOnly := cli.Bool("mtime-only", false, "Only set file times (modification, access and, on Windows and macOS, creation) from the dates; never change file contents")
+	skipCorrect := cli.String("skip-correct", "", "Don't rewrite metadata whose embedded date is already within this tolerance, e.g. 1s")
+	zeroMvhd := cli.String("zero-mvhd", "", "Make videos follow -ow, counting an mvhd creation time of 0 or invalid as missing (written) or existing (kept)")
+	writers := cli.String("writers", "", "Comma-separated writers allowed to change files: jpegExif, mp4Mvhd, m4aDate, opusDate, pdfDate, pngChunks, webpExif, heicExif, gifXmp, mtime (default all)")
+	videoAtoms := cli.String("video-atoms", "", "Comma-separated MP4 atoms videos and M4A files get the date in: mvhd, tkhd, mdhd, day, keys (default mvhd,tkhd; M4A adds day)")
+	backend := cli.String("backend", "", "Metadata writer: native, exiftool or auto (default: native)")
+	allowFFmpeg := cli.Bool("allow-ffmpeg", false, "Remux videos with ffmpeg when native metadata editing fails")
+	var tags stringList
+	cli.Var(&tags, "tag", "XMP keyword (dc:subject) to add wherever metadata is written; repeat for several")
+	softwareTag := cli.Bool("software-tag", false, "Record \"wappd <version>\" in the EXIF Software tag of the metadata it writes")
+	tagSent := cli.Bool("tag-sent", false, "Record wappd:direction=sent in the metadata of files under a Sent folder")
+	gps := cli.String("gps", "", "Record this approximate position in JPEGs without one, lat,lon in decimal degrees, e.g. 40.4168,-3.7038")
+	gpx := cli.String("gpx", "", "Record the position of the nearest point (within an hour) of this GPX or KML track in JPEGs without one")
+	normalizeOrientation := cli.Bool("normalize-orientation", false, "Rotate JPEG pixels to match their EXIF Orientation and reset it to upright (re-encodes the image)")
+
+	cli.Group("Execution")
+	dryRun := cli.Bool("dry-run", false, "Preview changes without modifying files")
+	workers := cli.Int("workers", 1, "Number of files to process concurrently")
+	transaction := cli.Bool("transaction", false, "Stage all outputs and only move them into place if every file succeeds")
+	maxFailures := cli.String("max-failures", "", "Abort the batch after this many failed files, or this percentage of them (e.g. 5 or 10%)")
+	safeMode := cli.Bool("safe-mode", false, "Refuse to write anywhere symlinks lead outside the input and output directories")
+	memoryLimit := cli.String("memory-limit", "", "Cap the memory used for file buffers, e.g. 256MiB: fewer files run at once and videos are patched in place")
+
+	cli.Group("Reports")
+	verbose := cli.Bool("verbose,v", false, "Verbose output (show detailed processing information)")
+	timings := cli.Bool("timings,vv", false, "Also print how long each file spent parsing, reading, copying, writing and setting times (implies -v)")
+	noColor := cli.Bool("no-color", false, "Print status marks without color (also when NO_COLOR is set or output isn't a terminal)")
+	format := cli.String("format", "", "Print each file as a line of this template instead, e.g. \"{status} {input} -> {output} {date}\" (placeholders: {status} {input} {output} {name} {date} {backend} {error})")
+	sortOrder := cli.String("sort", "input", "Order results are listed and written to the manifest in: input, name, date or status")
+	failedList := cli.String("failed-list", "", "Write the paths of the files that failed to this file, one per line, for --files-from")
+	manifestPath := cli.String("manifest", "", "Write a SHA-256 manifest of processed files to this path")
+	auditLog := cli.String("audit-log", "", "Append a hash-chained JSON line for every file changed to this log")
+	duplicates := cli.Bool("duplicates", false, "Report byte-identical files and copies of the same WhatsApp media among the processed files (nothing is deleted)")
+	reportHTML := cli.String("report-html", "", "Write an HTML page with a thumbnail, the old and new date and the status of every file to this path")
+	runsDir := cli.String("runs-dir", processor.DefaultRunsDir, "Keep each run's report, journal and log in a timestamped directory under this path (\"\" to disable)")
+
+	cli.Group("Uploads")
+	uploadWorkers := cli.Int("upload-workers", 4, "Concurrent uploads when -out is an s3:// or gs:// URI")
+	uploadRetries := cli.Int("upload-retries", 3, "Retries per file for cloud uploads that failed on a network error, 5xx, 429 or 408")
+	immichURL := cli.String("immich-url", "", "Upload processed files to this Immich server")
+	immichAPIKey := cli.String("api-key", "", "Immich API key (used with -immich-url)")
+	photoprismURL := cli.String("photoprism-url", "", "Upload processed files to this PhotoPrism server")
+	photoprismToken := cli.String("photoprism-token", "", "PhotoPrism app password or access token")
+	photoprismUser := cli.String("photoprism-user", "", "PhotoPrism user UID to upload as")
+
+	cli.Group("Other")
+	showVersion := cli.Bool("version", false, "Show version information")
+
+	// Completion scripts are built from the flags defined above
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		os.Exit(runCompletion(os.Args[2:], cli))
+	}
 
 	// Set custom usage function
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "wappd - WhatsApp Photo Date Extractor\n\n")
 		fmt.Fprintf(os.Stderr, "Extracts creation dates from WhatsApp media filenames and restores EXIF/video metadata.\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "  wappd [flags]\n\n")
-		fmt.Fprintf(os.Stderr, "Flags:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nExamples:\n")
-		fmt.Fprintf(os.Stderr, "  # Process all media in current directory\n")
-		fmt.Fprintf(os.Stderr, "  wappd\n\n")
-		fmt.Fprintf(os.Stderr, "  # Process specific directory\n")
-		fmt.Fprintf(os.Stderr, "  wappd -d ./whatsapp_backup\n\n")
-		fmt.Fprintf(os.Stderr, "  # Process single file\n")
-		fmt.Fprintf(os.Stderr, "  wappd -f IMG-20250122-WA0003.jpg\n\n")
-		fmt.Fprintf(os.Stderr, "  # Update file modification time and EXIF\n")
-		fmt.Fprintf(os.Stderr, "  wappd -d ./media -m\n\n")
-		fmt.Fprintf(os.Stderr, "  # Override original files\n")
-		fmt.Fprintf(os.Stderr, "  wappd -d ./media -o\n\n")
-		fmt.Fprintf(os.Stderr, "  # Save to output directory\n")
-		fmt.Fprintf(os.Stderr, "  wappd -d ./media -out ./processed_media\n\n")
-		fmt.Fprintf(os.Stderr, "  # Overwrite existing EXIF data\n")
-		fmt.Fprintf(os.Stderr, "  wappd -d ./media -ow\n\n")
-		fmt.Fprintf(os.Stderr, "  # Verbose output\n")
-		fmt.Fprintf(os.Stderr, "  wappd -d ./media -v\n\n")
-		fmt.Fprintf(os.Stderr, "  # Dry-run mode (preview changes)\n")
-		fmt.Fprintf(os.Stderr, "  wappd -d ./media --dry-run\n\n")
-		fmt.Fprintf(os.Stderr, "  # Use custom config file\n")
-		fmt.Fprintf(os.Stderr, "  wappd -d ./media -cf ./my-config.json\n\n")
-		fmt.Fprintf(os.Stderr, "Configuration File:\n")
-		fmt.Fprintf(os.Stderr, "  Optional wappd.json file in the working directory can set defaults.\n")
-		fmt.Fprintf(os.Stderr, "  Use -cf or --config-file to specify a custom config file path.\n")
-		fmt.Fprintf(os.Stderr, "  CLI flags override config file values.\n")
-		fmt.Fprintf(os.Stderr, "  Example wappd.json:\n")
-		fmt.Fprintf(os.Stderr, "    {\n")
-		fmt.Fprintf(os.Stderr, "      \"updateModified\": true,\n")
-		fmt.Fprintf(os.Stderr, "      \"outputDir\": \"./processed\",\n")
-		fmt.Fprintf(os.Stderr, "      \"verbose\": false\n")
-		fmt.Fprintf(os.Stderr, "    }\n\n")
-		fmt.Fprintf(os.Stderr, "Supported Formats:\n")
-		fmt.Fprintf(os.Stderr, "  Images: JPG, JPEG, PNG, GIF, BMP, WebP\n")
-		fmt.Fprintf(os.Stderr, "  Videos: MP4, MOV, AVI, MKV, FLV, M4V, 3GP\n\n")
-		fmt.Fprintf(os.Stderr, "WhatsApp Filename Patterns:\n")
-		fmt.Fprintf(os.Stderr, "  Images: IMG-YYYYMMDD-WA####.ext\n")
-		fmt.Fprintf(os.Stderr, "  Videos: VID-YYYYMMDD-WA####.ext\n")
-		fmt.Fprintf(os.Stderr, "  Images: WhatsApp Image YYYY-MM-DD at H.MM.SS AM|PM.ext\n")
-		fmt.Fprintf(os.Stderr, "  Videos: WhatsApp Video YYYY-MM-DD at H.MM.SS AM|PM.ext\n\n")
-	}
-
-	flag.Parse()
+		fmt.Fprintf(os.Stderr, "  wappd [flags]\n")
+		fmt.Fprintf(os.Stderr, "  wappd help [examples|patterns|config|formats]\n")
+		fmt.Fprintf(os.Stderr, "  wappd init\n")
+		fmt.Fprintf(os.Stderr, "  wappd doctor [-f <file>]\n")
+		fmt.Fprintf(os.Stderr, "  wappd inspect [-json] <file>\n")
+		fmt.Fprintf(os.Stderr, "  wappd dates [-d <dir>] [-mismatches]\n")
+		fmt.Fprintf(os.Stderr, "  wappd compare [-json] <dir> <other-dir>\n")
+		fmt.Fprintf(os.Stderr, "  wappd verify --manifest <path> | --audit-log <path>\n")
+		fmt.Fprintf(os.Stderr, "  wappd runs list [-dir <path>]\n")
+		fmt.Fprintf(os.Stderr, "  wappd restore-times [-dry-run] <run-id>\n")
+		fmt.Fprintf(os.Stderr, "  wappd retry -run <run-id> [-- overriding flags]\n")
+		fmt.Fprintf(os.Stderr, "  wappd recover [-backup <dir>] [-dry-run]\n")
+		fmt.Fprintf(os.Stderr, "  wappd pull-android [flags] [-- processing flags]\n")
+		fmt.Fprintf(os.Stderr, "  wappd gen-samples <dir>\n")
+		fmt.Fprintf(os.Stderr, "  wappd bundle-debug [-o <zip>] <file>\n")
+		fmt.Fprintf(os.Stderr, "  wappd completion bash|zsh|fish\n\n")
+		cli.PrintDefaults(os.Stderr)
+		fmt.Fprintf(os.Stderr, "More help:\n")
+		for _, topic := range helpTopics {
+			fmt.Fprintf(os.Stderr, "  wappd help %-10s %s\n", topic.name, topic.summary)
+		}
+	}
+
+	flag.CommandLine.Parse(processArgs)
 
 	// Handle version flag
 	if *showVersion {
@@ -83,34 +195,620 @@ func main() {
 		os.Exit(0)
 	}
 
-	if *filePath != "" && *dirPath != "." {
+	if *filePath != "" && len(dirPaths) > 0 {
 		log.Println("Warning: -f flag is set, -d flag will be ignored")
 	}
+	if *whatsappRoot != "" && (*filePath != "" || len(dirPaths) > 0) {
+		log.Fatalf("Error: --whatsapp-root cannot be combined with -f or -d")
+	}
+	if *renameHash {
+		if *outputTemplate != "" {
+			log.Fatalf("Error: --rename-hash cannot be combined with --output-template (use {hash} in the template instead)")
+		}
+		*outputTemplate = processor.TemplateRenameHash
+	}
+	var err error
 
-	var inputPaths []string
+	// --files-from replaces the directory scan with a list of files
+	var listedFiles []string
+	if *filesFrom != "" {
+		if *filePath != "" || len(dirPaths) > 1 {
+			log.Fatalf("Error: --files-from cannot be combined with -f or several -d")
+		}
+		if len(dirPaths) == 1 && processor.IsRemoteSource(dirPaths[0]) {
+			log.Fatalf("Error: --files-from cannot be combined with a remote -d (listed files are read locally)")
+		}
+		listedFiles, err = processor.ReadFileList(*filesFrom)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if len(listedFiles) == 0 {
+			log.Fatalf("Error: %s lists no files", *filesFrom)
+		}
+	}
+	if err := processor.ValidateResultFormat(*format); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := processor.ValidateSortOrder(*sortOrder); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *preset != "" {
+		if _, err := processor.Preset(*preset); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	// Photo library credentials are checked before any file is touched
+	if *immichURL != "" && *immichAPIKey == "" {
+		log.Fatalf("Error: -api-key is required with -immich-url")
+	}
+	if *immichAPIKey != "" && *immichURL == "" {
+		log.Fatalf("Error: -api-key requires -immich-url")
+	}
+	if *photoprismURL != "" && (*photoprismToken == "" || *photoprismUser == "") {
+		log.Fatalf("Error: -photoprism-token and -photoprism-user are required with -photoprism-url")
+	}
+	if (*photoprismToken != "" || *photoprismUser != "") && *photoprismURL == "" {
+		log.Fatalf("Error: -photoprism-token and -photoprism-user require -photoprism-url")
+	}
+
+	// A config file given with -cf applies to every target; otherwise each
+	// directory may carry its own wappd.json
+	var sharedConfig *processor.ConfigFile
+	if configFile != "" {
+		sharedConfig, err = processor.LoadConfigFileFromPath(configFile)
+		if err != nil {
+			log.Fatalf("Failed to load config file %s: %v", configFile, err)
+		}
+		if *profile != "" && sharedConfig != nil {
+			sharedConfig, err = processor.ApplyProfile(sharedConfig, *profile)
+			if err != nil {
+				log.Fatalf("Error: %s: %v", configFile, err)
+			}
+		}
+	}
+
+	// Targets come from --whatsapp-root or repeated -d flags, else from the
+	// config file's targets array, else the current directory
+	var targets []processor.Target
+	switch {
+	case *whatsappRoot != "":
+		targets, err = processor.WhatsAppTargets(*whatsappRoot)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	case (*filePath != "" || len(listedFiles) > 0) && len(dirPaths) > 0:
+		targets = []processor.Target{{Dir: dirPaths[0]}}
+	case *filePath == "" && len(dirPaths) > 0:
+		for _, dir := range dirPaths {
+			targets = append(targets, processor.Target{Dir: dir})
+		}
+	case *filePath == "" && len(listedFiles) == 0 && sharedConfig != nil && len(sharedConfig.Targets) > 0:
+		targets = sharedConfig.Targets
+	default:
+		targets = []processor.Target{{Dir: "."}}
+	}
+
+	// A single -out shared by several targets is split into one
+	// subdirectory per target so their outputs can't collide
+	var sharedOutputs []string
+	if len(targets) > 1 && *outputDir != "" {
+		dirs := make([]string, len(targets))
+		for i, target := range targets {
+			dirs[i] = target.Dir
+		}
+		sharedOutputs = processor.TargetOutputDirs(*outputDir, dirs)
+	}
+
+	opts := runOptions{
+		filePath:        *filePath,
+		files:           listedFiles,
+		workers:         *workers,
+		sortOrder:       *sortOrder,
+		format:          *format,
+		uploadWorkers:   *uploadWorkers,
+		uploadRetries:   *uploadRetries,
+		immichURL:       *immichURL,
+		immichAPIKey:    *immichAPIKey,
+		photoprismURL:   *photoprismURL,
+		photoprismToken: *photoprismToken,
+		photoprismUser:  *photoprismUser,
+		repack:          *repack,
+		transaction:     *transaction,
+		console:         processor.NewConsole(os.Stdout, *noColor),
+		failedUploads:   new(int),
+		failedFiles:     new([]string),
+		// A -manifest given with several targets covers all of them and is
+		// written once at the end
+		combinedManifest: len(targets) > 1 && *manifestPath != "",
+	}
+
+	// Each run records its report, journal and log in its own directory
+	var run *processor.Run
+	if !*dryRun && *runsDir != "" {
+		run, err = processor.NewRun(*runsDir, processArgs, time.Now())
+		if err != nil {
+			log.Printf("Warning: run not recorded: %v", err)
+		}
+	}
+	if run != nil {
+		opts.logger = openRunLog(run)
+	}
+
+	// Remove files left behind by crashed runs, then journal this one's
+	opts.journal = openJournal(*dryRun, *verbose, run, *runsDir)
+
+	// Every target's config is loaded and checked before any is processed,
+	// so a bad wappd.json can't stop a run halfway through
+	fileConfigs := make([]*processor.ConfigFile, len(targets))
+	configPaths := make([]string, len(targets))
+	for i, target := range targets {
+		fileConfigs[i], configPaths[i] = sharedConfig, configFile
+		if configFile == "" {
+			configPaths[i] = filepath.Join(target.Dir, processor.ConfigFileName())
+			fileConfigs[i], err = processor.LoadConfigFile(target.Dir)
+			if err != nil {
+				log.Fatalf("Failed to load config file %s: %v", configPaths[i], err)
+			}
+			if *profile != "" && fileConfigs[i] != nil {
+				fileConfigs[i], err = processor.ApplyProfile(fileConfigs[i], *profile)
+				if err != nil {
+					log.Fatalf("Error: %s: %v", configPaths[i], err)
+				}
+			}
+		}
+		if err := processor.ValidateMediaKind(target.Media); err != nil {
+			log.Fatalf("Error: target %s: %v", target.Dir, err)
+		}
+	}
+	if *profile != "" && !anyConfigLoaded(fileConfigs) {
+		log.Fatalf("Error: --profile %s needs a config file defining it", *profile)
+	}
+
+	var allResults []processor.ProcessResult
+	renames := make(map[string][]processor.RenameEntry) // Rename map path → entries
+	for i, target := range targets {
+		fileConfig := fileConfigs[i]
+		configPath := configPaths[i]
+		loaded := fileConfig != nil
+		fileConfig = processor.OverlayConfigFile(fileConfig, &target.ConfigFile)
+		if *preset != "" {
+			fileConfig, _ = processor.ApplyPreset(fileConfig, *preset)
+		}
+
+		// Build CLI config
+		cliConfig := processor.Config{
+			UpdateModified:       *updateModified,
+			OverwriteExif:        *overwriteExif,
+			OverrideOriginal:     *overrideOriginal,
+			OutputDir:            *outputDir,
+			OutputTemplate:       *outputTemplate,
+			SanitizeNames:        *sanitizeNames,
+			InputDir:             target.Dir,
+			Verbose:              *verbose,
+			DryRun:               *dryRun,
+			ManifestPath:         *manifestPath,
+			AuditLog:             *auditLog,
+			Backend:              *backend,
+			AllowFFmpeg:          *allowFFmpeg,
+			Timezone:             *timezone,
+			Offset:               *offset,
+			IncludeDocuments:     *includeDocuments,
+			Stickers:             *stickers,
+			FixExtensions:        *fixExtensions,
+			MaxFailures:          *maxFailures,
+			Strict:               *strict,
+			IgnoreUnmatched:      *ignoreUnmatched,
+			TagSent:              *tagSent,
+			Tags:                 tags,
+			SoftwareTag:          *softwareTag,
+			InferDates:           *inferDates,
+			SpreadTimes:          *disambiguateTimes,
+			SafeMode:             *safeMode,
+			Chown:                *chown,
+			SkipCorrect:          *skipCorrect,
+			NormalizeOrientation: *normalizeOrientation,
+			MtimeOnly:            *mtimeOnly,
+			NoCopy:               *noCopy,
+			DatePolicy:           *datePolicy,
+			ZeroMvhd:             *zeroMvhd,
+			Writers:              splitList(*writers),
+			VideoAtoms:           splitList(*videoAtoms),
+			GPS:                  *gps,
+			GPX:                  *gpx,
+			ExtraPatterns:        splitList(*extraPatterns),
+			Extensions:           splitList(*extensions),
+			DetectContent:        *detectContent,
+			MaxDepth:             *maxDepth,
+			FollowSymlinks:       *followSymlinks,
+			SkipDirs:             splitList(*skipDirs),
+			Timings:              *timings,
+			Settle:               *settle,
+			MemoryLimit:          *memoryLimit,
+			SidecarOnly:          *sidecarOnly,
+			RenameMap:            *renameMap,
+		}
+		if sharedOutputs != nil {
+			cliConfig.OutputDir = sharedOutputs[i]
+		}
+
+		// Merge config file with CLI flags (CLI takes precedence)
+		config := processor.MergeConfig(fileConfig, cliConfig)
+		if config.Timings {
+			config.Verbose = true
+		}
+
+		if err := processor.ValidateBackend(config.Backend); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := processor.ValidateStickerMode(config.Stickers); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if _, err := processor.LoadTimezone(config.Timezone); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if _, err := processor.ParseClockOffset(config.Offset); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if _, err := processor.ParseFailureLimit(config.MaxFailures); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := processor.ValidateExtraPatterns(config.ExtraPatterns); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := scan.ValidateExtensions(config.Extensions); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := scan.ValidateSkipNames(config.SkipDirs); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if config.MaxDepth < 0 {
+			log.Fatalf("Error: --max-depth must be 0 (no limit) or more")
+		}
+		if err := processor.ValidateTags(config.Tags); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := processor.ValidateOutputTemplate(config.OutputTemplate); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := processor.ValidateSanitizeMode(config.SanitizeNames); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := processor.ValidateDatePolicy(config.DatePolicy); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := processor.ValidateZeroMvhd(config.ZeroMvhd); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := processor.ValidateWriters(config.Writers); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := processor.ValidateVideoAtoms(config.VideoAtoms); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if config.MtimeOnly && len(config.Writers) > 0 && !slices.Contains(config.Writers, processor.WriterMtime) {
+			log.Fatalf("Error: --mtime-only needs the mtime writer enabled")
+		}
+		if _, err := processor.ParseGPS(config.GPS); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if _, err := processor.LoadTrack(config.GPX); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if _, err := processor.ParseOwner(config.Chown); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if _, err := processor.ParseCorrectTolerance(config.SkipCorrect); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if _, err := processor.ParseSettleTime(config.Settle); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if _, err := processor.ParseMemoryLimit(config.MemoryLimit); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if config.Strict && config.IgnoreUnmatched {
+			log.Fatalf("Error: --strict and --ignore-unmatched cannot be combined")
+		}
+		if config.OverwriteExif && config.DatePolicy != "" && config.DatePolicy != processor.DatePolicyFilename {
+			log.Fatalf("Error: -ow contradicts --date-policy %s", config.DatePolicy)
+		}
+		if config.NoCopy && !config.OverrideOriginal {
+			log.Fatalf("Error: --no-copy requires -o: without it every file would be copied")
+		}
+		if config.NoCopy && config.OutputDir != "" {
+			log.Fatalf("Error: --no-copy cannot be combined with -out, which copies every file")
+		}
+		if config.NormalizeOrientation && config.MtimeOnly {
+			log.Fatalf("Error: --normalize-orientation and --mtime-only cannot be combined")
+		}
+		if config.NormalizeOrientation && config.Backend == processor.BackendExiftool {
+			log.Fatalf("Error: --normalize-orientation requires the native backend")
+		}
+		if config.SidecarOnly && (config.UpdateModified || config.MtimeOnly || config.NormalizeOrientation) {
+			log.Fatalf("Error: --sidecar-only never changes media files and cannot be combined with -m, --mtime-only or --normalize-orientation")
+		}
+		if config.SidecarOnly && processor.IsCloudTarget(config.OutputDir) {
+			log.Fatalf("Error: --sidecar-only cannot upload to a cloud -out")
+		}
+		if config.RenameMap != "" && !config.SidecarOnly {
+			log.Fatalf("Error: --rename-map requires --sidecar-only (other runs rename the files themselves)")
+		}
+		if config.Backend == processor.BackendExiftool && !processor.ExiftoolAvailable() {
+			log.Fatalf("Error: -backend exiftool requires exiftool on PATH")
+		}
+
+		// Each WhatsApp media folder processes the listed files it holds
+		targetOpts := opts
+		if *whatsappRoot != "" && len(listedFiles) > 0 {
+			targetOpts.files = processor.FilesUnder(target.Dir, listedFiles)
+			if len(targetOpts.files) == 0 {
+				continue
+			}
+		}
+
+		if len(targets) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			if target.Media != "" {
+				fmt.Printf("==> %s (%s)\n", target.Dir, target.Media)
+			} else {
+				fmt.Printf("==> %s\n", target.Dir)
+			}
+		}
+
+		// Show config file usage if loaded
+		if loaded && config.Verbose {
+			fmt.Printf("Loaded configuration from %s\n", configPath)
+		}
+
+		results := runTarget(config, target, targetOpts)
+		allResults = append(allResults, results...)
+		if config.RenameMap != "" {
+			renames[config.RenameMap] = append(renames[config.RenameMap], processor.BuildRenameMap(results)...)
+		}
+		if run != nil {
+			if err := run.AddResults(results); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+
+	// Write the combined checksum manifest for a multi-target run
+	if opts.combinedManifest && !*dryRun {
+		manifest := processor.BuildManifest(processor.SortResults(allResults, opts.sortOrder))
+		if err := processor.WriteManifest(*manifestPath, manifest); err != nil {
+			log.Fatalf("Error writing manifest: %v", err)
+		}
+		fmt.Printf("Manifest written to %s (%d entries)\n", *manifestPath, len(manifest.Entries))
+	}
+
+	// The failed files of every target, ready for --files-from
+	if *failedList != "" {
+		failed := *opts.failedFiles
+		if err := processor.WriteFileList(*failedList, failed); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if len(failed) > 0 {
+			fmt.Printf("Failed files listed in %s (retry them with --files-from %s)\n", *failedList, *failedList)
+		}
+	}
+
+	// Write the rename maps once every target has added its renames
+	if !*dryRun {
+		for path, entries := range renames {
+			if err := processor.WriteRenameMap(path, entries); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			fmt.Printf("Rename map written to %s (%d entries)\n", path, len(entries))
+		}
+	}
+
+	// Duplicates are looked for across every target
+	var dups []processor.DuplicateGroup
+	if *duplicates {
+		dups = processor.FindDuplicates(processor.DuplicatePaths(allResults))
+		printDuplicates(dups, *verbose)
+	}
+
+	// The HTML report covers every target, dry runs included
+	if *reportHTML != "" {
+		// Thumbnails are cached with the run, dry runs don't keep them
+		var thumbs *processor.Thumbnailer
+		if run != nil {
+			thumbs = processor.NewThumbnailer(filepath.Join(run.Dir(), processor.RunThumbsName), processor.DefaultThumbnailSize)
+		}
+		if err := processor.WriteHTMLReport(*reportHTML, processor.SortResults(allResults, opts.sortOrder), dups, time.Now(), *dryRun, thumbs); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Report written to %s\n", *reportHTML)
+	}
+
+	if opts.journal != nil {
+		opts.journal.Close()
+	}
+	if run != nil {
+		if err := run.Finish(time.Now()); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if *verbose {
+			fmt.Printf("Run %s recorded in %s\n", run.ID(), run.Dir())
+		}
+	}
+	if *opts.failedUploads > 0 {
+		log.Fatalf("Error: %d upload(s) failed", *opts.failedUploads)
+	}
+}
+
+// runOptions holds the flags that apply to every target unchanged
+type runOptions struct {
+	filePath        string
+	files           []string // Files listed by --files-from
+	workers         int
+	sortOrder       string // Order results are reported in (--sort)
+	format          string // Per-file line template replacing the default lines (--format)
+	uploadWorkers   int
+	uploadRetries   int
+	immichURL       string
+	immichAPIKey    string
+	photoprismURL   string
+	photoprismToken string
+	photoprismUser  string
+	repack          bool
+	transaction     bool
+	// combinedManifest defers the manifest to main, which writes one
+	// covering every target
+	combinedManifest bool
+	journal          *processor.Journal
+	failedUploads    *int              // Uploads that failed in every target, for the exit status
+	failedFiles      *[]string         // Originals of the files that failed in every target, for --failed-list
+	console          processor.Console // Colors and width of the per-file lines
+	logger           *log.Logger       // Verbose processing output (nil = standard output)
+}
+
+// streamBatch is how many files a streamed directory scan queues ahead of
+// the workers; room for each batch is checked before it's queued
+const streamBatch = 256
+
+// runTarget processes one input directory (or the -f file) with its merged
+// config, then uploads, repacks and writes its manifest as configured
+func runTarget(config processor.Config, target processor.Target, opts runOptions) []processor.ProcessResult {
 	var err error
 
-	if *filePath != "" {
-		inputPaths = []string{*filePath}
+	// Fetched and staged files live in temporary directories; log.Fatalf
+	// exits without running deferred calls, so fatalf removes them first
+	var tempDirs []string
+	defer func() {
+		for _, dir := range tempDirs {
+			os.RemoveAll(dir)
+		}
+	}()
+	fatalf := func(format string, v ...interface{}) {
+		for _, dir := range tempDirs {
+			os.RemoveAll(dir)
+		}
+		log.Fatalf(format, v...)
+	}
+
+	// Cloud outputs are staged locally, then uploaded after processing
+	var cloudTarget *processor.CloudTarget
+	var cloudCreds *processor.CloudCredentials
+	var cloudURIs map[string]string // Staged outputs by the URI they were uploaded to
+	if processor.IsCloudTarget(config.OutputDir) {
+		cloudTarget, err = processor.ParseCloudTarget(config.OutputDir)
+		if err != nil {
+			fatalf("Error: %v", err)
+		}
+		if !config.DryRun {
+			cloudCreds, err = processor.LoadCloudCredentials(cloudTarget.Scheme)
+			if err != nil {
+				fatalf("Error: %v", err)
+			}
+		}
+		stagingDir, err := os.MkdirTemp("", "wappd-upload-")
+		if err != nil {
+			fatalf("Error creating staging directory: %v", err)
+		}
+		tempDirs = append(tempDirs, stagingDir)
+		config.OutputDir = stagingDir
+		// Keys keep the input's subdirectories, so same-named files don't
+		// overwrite each other's objects
+		if config.OutputTemplate == "" {
+			config.OutputTemplate = processor.TemplateKeepTree
+		}
+	}
+
+	var inputPaths []string
+	archiveDir := ""
+	remoteSource := remoteInput(opts.filePath, target.Dir)
+	fetchDir := ""
+	// A directory scan is fed to the workers as the walk finds files, unless
+	// something needs the whole list first
+	streamDir := ""
+
+	if opts.filePath != "" && processor.IsArchive(opts.filePath) {
+		// Archive media is extracted once and then edited in place
+		archiveDir = config.OutputDir
+		if archiveDir == "" {
+			archiveDir = processor.ArchiveExtractDir(opts.filePath)
+		}
+		if err := processor.ValidateArchiveExtractDir(opts.filePath, archiveDir); err != nil {
+			fatalf("Error: %v", err)
+		}
+		if config.Verbose {
+			fmt.Printf("Extracting media from %s to %s...\n", opts.filePath, archiveDir)
+		}
+		if config.DryRun {
+			inputPaths, err = processor.ListArchiveMedia(opts.filePath, archiveDir)
+		} else {
+			inputPaths, err = processor.ExtractArchiveMedia(opts.filePath, archiveDir)
+		}
+		if err != nil {
+			fatalf("Error reading archive: %v", err)
+		}
+		config.InputDir = archiveDir
+		config.OutputDir = ""
+		config.OutputTemplate = ""
+		config.OverrideOriginal = true
+	} else if remoteSource != "" {
+		// Remote originals are never modified: fetch a copy, write outputs locally
+		fetchDir, err = os.MkdirTemp("", "wappd-remote-")
+		if err != nil {
+			fatalf("Error creating temp directory: %v", err)
+		}
+		tempDirs = append(tempDirs, fetchDir)
+
+		if config.Verbose {
+			fmt.Printf("Fetching %s to %s...\n", remoteSource, fetchDir)
+		}
+		if err := processor.FetchRemoteSource(remoteSource, fetchDir); err != nil {
+			fatalf("Error fetching remote source: %v", err)
+		}
+		inputPaths, err = processor.NewScanner(config).Files(fetchDir)
+		if err != nil {
+			fatalf("Error reading fetched files: %v", err)
+		}
+		config.InputDir = fetchDir
+		if config.OutputDir == "" {
+			config.OutputDir = processor.RemoteBaseName(remoteSource) + "_modified"
+		}
+	} else if len(opts.files) > 0 {
+		inputPaths = opts.files
+	} else if opts.filePath != "" {
+		inputPaths = []string{opts.filePath}
+		// Safe mode confines a single file's run to the file's own directory
+		if config.SafeMode {
+			config.InputDir = filepath.Dir(opts.filePath)
+		}
+	} else if !config.Strict && processor.New(config).Streams() {
+		if _, err := os.Stat(target.Dir); err != nil {
+			fatalf("Error reading directory: %v", err)
+		}
+		if config.Verbose {
+			fmt.Println("Scanning directory, processing media files as they are found...")
+		}
+		streamDir = target.Dir
 	} else {
-		if *verbose {
+		if config.Verbose {
 			fmt.Println("Scanning directory for media files...")
 		}
-		inputPaths, err = processor.GetImageVideoFiles(*dirPath)
+		inputPaths, err = processor.NewScanner(config).Files(target.Dir)
 		if err != nil {
-			log.Fatalf("Error reading directory: %v", err)
+			fatalf("Error reading directory: %v", err)
 		}
+		inputPaths = processor.FilterMediaKind(inputPaths, target.Media)
 	}
 
-	if len(inputPaths) == 0 {
+	if streamDir == "" && len(inputPaths) == 0 {
 		fmt.Println("No image or video files found to process")
-		return
+		return nil
 	}
 
-	if *verbose {
+	if config.Verbose && streamDir == "" {
 		fmt.Printf("Found %d file(s) to process\n", len(inputPaths))
+		dates := processor.New(config)
 		for i, p := range inputPaths {
-			dateStr, err := processor.ExtractDateFromFilename(filepath.Base(p))
+			dateStr, err := dates.FilenameDate(filepath.Base(p))
 			if err != nil {
 				fmt.Printf("  %d: %s (date extraction failed: %v)\n", i+1, p, err)
 			} else {
@@ -120,66 +818,250 @@ func main() {
 		fmt.Println()
 	}
 
-	// Load config file if specified or if default exists (optional)
-	var fileConfig *processor.ConfigFile
-	if configFile != "" {
-		// Use custom config file path
-		fileConfig, err = processor.LoadConfigFileFromPath(configFile)
-		if err != nil {
-			log.Fatalf("Failed to load config file %s: %v", configFile, err)
+	if config.DryRun {
+		fmt.Println("DRY-RUN MODE: No files will be modified")
+		fmt.Println()
+	}
+	if config.Verbose {
+		fmt.Println("Processing files...")
+	}
+	// Strict mode checks every filename before touching anything
+	if config.Strict {
+		if unmatched := processor.New(config).UnmatchedFiles(inputPaths); len(unmatched) > 0 {
+			fmt.Printf("%d file(s) match no WhatsApp filename pattern:\n", len(unmatched))
+			for _, f := range unmatched {
+				fmt.Printf("  ✗ %s\n", f)
+			}
+			fatalf("Error: --strict: no files were processed (rename them, or use --ignore-unmatched to skip them)")
 		}
-	} else {
-		// Try default config file in working directory
-		fileConfig, err = processor.LoadConfigFile(*dirPath)
+	}
+
+	// A read-only mount or a full filesystem would otherwise fail every
+	// file with a confusing error of its own
+	if !config.DryRun {
+		health := processor.CheckOutputFS(outputBase(config, opts.filePath))
+		needed := len(inputPaths)
+		if (config.OverrideOriginal && !opts.transaction) || streamDir != "" {
+			needed = 1 // Temporary files only, renamed over the originals, or checked per batch as they're found
+		}
+		if err := health.Check(needed); err != nil {
+			fatalf("Error: %v", err)
+		}
+		if config.Verbose && !health.CaseSensitive {
+			fmt.Printf("Note: %s is on a case-insensitive filesystem; outputs whose names differ only in case will collide\n\n", health.Dir)
+		}
+	}
+
+	// Only one run at a time may edit a directory's originals in place
+	if config.OverrideOriginal && !config.DryRun {
+		lockDir := config.InputDir
+		if opts.filePath != "" && archiveDir == "" && !processor.IsRemoteSource(opts.filePath) {
+			lockDir = filepath.Dir(opts.filePath)
+		}
+		dirLock, err := processor.LockDir(lockDir)
 		if err != nil {
-			log.Printf("Warning: Failed to load config file: %v", err)
+			fatalf("Error: %v (another wappd run is editing these files in place)", err)
 		}
+		defer dirLock.Unlock()
 	}
 
-	// Build CLI config
-	cliConfig := processor.Config{
-		UpdateModified:    *updateModified,
-		OverwriteExif:     *overwriteExif,
-		OverrideOriginal:  *overrideOriginal,
-		OutputDir:         *outputDir,
-		InputDir:          *dirPath,
-		Verbose:           *verbose,
-		DryRun:            *dryRun,
+	// In a transaction, outputs are staged and moved into place together
+	var tx *processor.Transaction
+	if opts.transaction && !config.DryRun {
+		stagingDir, err := transactionDir(config)
+		if err != nil {
+			fatalf("Error: failed to create staging directory: %v", err)
+		}
+		tx, err = processor.NewTransaction(processor.OSFS, stagingDir)
+		if err != nil {
+			fatalf("Error: %v", err)
+		}
+		owner, _ := processor.ParseOwner(config.Chown)
+		tx.SetOwner(owner)
 	}
 
-	// Merge config file with CLI flags (CLI takes precedence)
-	config := processor.MergeConfig(fileConfig, cliConfig)
+	procOpts := []processor.Option{processor.WithConcurrency(opts.workers), processor.WithJournal(opts.journal), processor.WithTransaction(tx), processor.WithLogger(opts.logger)}
 
-	// Show config file usage if loaded
-	if fileConfig != nil && config.Verbose {
-		configPath := configFile
-		if configPath == "" {
-			configPath = filepath.Join(*dirPath, processor.ConfigFileName())
+	// Changes are recorded in the audit log as they happen, or once they
+	// are final when they are committed or uploaded after the batch
+	var auditLog *processor.AuditLog
+	auditLater := tx != nil || cloudTarget != nil
+	if config.AuditLog != "" && !config.DryRun {
+		auditLog, err = processor.OpenAuditLog(config.AuditLog)
+		if err != nil {
+			fatalf("Error: %v", err)
+		}
+		defer auditLog.Close()
+		if !auditLater {
+			procOpts = append(procOpts, processor.WithAuditLog(auditLog))
 		}
-		fmt.Printf("Loaded configuration from %s\n", configPath)
 	}
 
-	if config.DryRun {
-		fmt.Println("DRY-RUN MODE: No files will be modified")
-		fmt.Println()
+	proc := processor.New(config, procOpts...)
+	var results []processor.ProcessResult
+	if streamDir != "" {
+		paths := make(chan string, streamBatch)
+		// The walk would otherwise find the outputs and staged files
+		// written into the tree it's still reading
+		scanner := processor.NewScanner(config)
+		stagingDir := ""
+		if tx != nil {
+			stagingDir = tx.Dir()
+		}
+		skipNamed, skipWritten := scanner.SkipDirs, scan.SkipPaths(config.OutputDir, stagingDir)
+		scanner.SkipDirs = func(path, name string) bool {
+			return skipWritten(path, name) || (skipNamed != nil && skipNamed(path, name))
+		}
+		// The files aren't counted up front, so room for them is checked
+		// before each batch is queued
+		checkRoom := !config.DryRun && !(config.OverrideOriginal && !opts.transaction)
+		var walkErr, roomErr error
+		go func() {
+			defer close(paths)
+			found := 0
+			walkErr = scanner.Walk(streamDir, func(path string) error {
+				if !processor.MatchesMediaKind(path, target.Media) {
+					return nil
+				}
+				if checkRoom && found%streamBatch == 0 {
+					if roomErr = processor.CheckOutputFS(outputBase(config, opts.filePath)).Check(streamBatch); roomErr != nil {
+						return roomErr
+					}
+				}
+				found++
+				paths <- path
+				return nil
+			})
+		}()
+		results = proc.ProcessStream(paths)
+		if roomErr != nil {
+			fmt.Printf("Warning: %v (only the files found before it were processed)\n", roomErr)
+		} else if walkErr != nil {
+			fmt.Printf("Warning: error reading directory: %v (only the files found before it were processed)\n", walkErr)
+		}
+		if len(results) == 0 {
+			if tx != nil {
+				tx.Rollback() // Nothing was staged; drop the staging directory
+			}
+			fmt.Println("No image or video files found to process")
+			return nil
+		}
+	} else {
+		results = proc.ProcessFiles(inputPaths)
 	}
-	if config.Verbose {
-		fmt.Println("Processing files...")
+	results = processor.SortResults(results, opts.sortOrder)
+
+	// --failed-list names the originals, not the copies extracted or
+	// fetched for this run
+	for _, path := range processor.FailedPaths(results) {
+		if archiveDir != "" {
+			*opts.failedFiles = append(*opts.failedFiles, opts.filePath)
+			break
+		}
+		if remoteSource != "" {
+			path = processor.RemoteOrigin(remoteSource, fetchDir, path)
+		}
+		*opts.failedFiles = append(*opts.failedFiles, path)
 	}
-	proc := processor.New(config)
-	results := proc.ProcessFiles(inputPaths)
 
 	successCount := 0
 	failCount := 0
+	skipCount := 0
+	sentCount := 0
+	inferredCount := 0
+	sanitizedCount := 0
+	correctCount := 0
+	keptCount := 0
+	unmatchedCount := 0
+	unsetCounts := map[string]int{}
+	// --format replaces every per-file line with one of its own
+	lines := opts.format == ""
 	for _, r := range results {
-		if r.Success {
+		if !lines {
+			fmt.Println(processor.FormatResult(opts.format, r))
+		}
+		if r.Skipped {
+			skipCount++
+			if config.Verbose && lines {
+				fmt.Println(opts.console.Line("-", "%s: skipped (%s)", []string{r.InputFile}, r.SkipReason))
+			}
+		} else if r.Success {
 			successCount++
-			if config.Verbose {
-				fmt.Printf("  ✓ %s → %s\n", r.InputFile, r.OutputFile)
+			if r.Sent {
+				sentCount++
+			}
+			if r.AlreadyCorrect {
+				correctCount++
+			}
+			if errors.Is(r.Kept, processor.ErrExifExists) {
+				keptCount++
+			}
+			if r.MvhdUnset != "" {
+				unsetCounts[r.MvhdUnset]++
+			}
+			// Inferred dates are guesses: always list them
+			if r.Inferred {
+				inferredCount++
+				if lines {
+					fmt.Println(opts.console.Line("~", "%s: date inferred from neighboring files → %s", []string{r.InputFile}, r.DateTime.Format("2006-01-02 15:04:05")))
+				}
+			}
+			// Renamed outputs are easy to miss: always list them
+			if r.Sanitized {
+				sanitizedCount++
+				if lines {
+					fmt.Println(opts.console.Line("!", "%s: renamed to %s (invalid on %s)", []string{r.InputFile}, filepath.Base(r.OutputFile), config.SanitizeNames))
+				}
+			}
+			if config.Verbose && lines {
+				if r.ActualExt != "" {
+					fmt.Println(opts.console.Line("!", "%s is really a %s file", []string{r.InputFile}, r.ActualExt))
+				}
+				sent := ""
+				if r.Sent {
+					sent = " (sent)"
+				}
+				// A video's length shows the right file was parsed
+				mediaPath := r.OutputFile
+				if config.DryRun {
+					mediaPath = r.InputFile
+				}
+				if d, err := processor.VideoDuration(mediaPath); err == nil && d > 0 {
+					sent = " " + processor.FormatDuration(d) + sent
+				} else if err != nil && !errors.Is(err, processor.ErrNoDuration) {
+					fmt.Println(opts.console.Line("!", "%s: %v (the container may be corrupt)", []string{r.InputFile}, err))
+				}
+				if r.KeptEmbedded {
+					sent = " (kept embedded date)" + sent
+				}
+				if r.MvhdUnset != "" {
+					sent = " (mvhd time was " + r.MvhdUnset + ")" + sent
+				}
+				if config.SidecarOnly {
+					if r.PlannedPath != r.InputFile {
+						sent = " (rename to " + r.PlannedPath + ")" + sent
+					}
+					if len(r.Sidecars) > 0 {
+						fmt.Println(opts.console.Line("✓", "%s → %s%s", []string{r.InputFile}, strings.Join(r.Sidecars, ", "), sent))
+					} else {
+						fmt.Println(opts.console.Line("✓", "%s → sidecars%s", []string{r.InputFile}, sent))
+					}
+				} else if r.AlreadyCorrect {
+					fmt.Println(opts.console.Line("=", "%s → %s (already correct)%s", []string{r.InputFile, r.OutputFile}, sent))
+				} else if r.Backend != "" {
+					fmt.Println(opts.console.Line("✓", "%s → %s [%s]%s", []string{r.InputFile, r.OutputFile}, r.Backend, sent))
+				} else {
+					fmt.Println(opts.console.Line("✓", "%s → %s%s", []string{r.InputFile, r.OutputFile}, sent))
+				}
 			}
 		} else {
 			failCount++
-			fmt.Printf("  ✗ %s: %v\n", r.InputFile, r.Error)
+			if errors.Is(r.Error, processor.ErrNoPatternMatch) {
+				unmatchedCount++
+			}
+			if lines {
+				fmt.Println(opts.console.Line("✗", "%s: %v", []string{r.InputFile}, r.Error))
+			}
 		}
 	}
 
@@ -188,6 +1070,9 @@ func main() {
 		if failCount > 0 {
 			fmt.Printf(", %d would fail", failCount)
 		}
+		if skipCount > 0 {
+			fmt.Printf(", %d skipped", skipCount)
+		}
 		fmt.Printf(" (out of %d total)\n", len(results))
 		fmt.Println("Run without --dry-run to apply changes")
 	} else {
@@ -195,6 +1080,380 @@ func main() {
 		if failCount > 0 {
 			fmt.Printf(", %d failed", failCount)
 		}
+		if skipCount > 0 {
+			fmt.Printf(", %d skipped", skipCount)
+		}
 		fmt.Printf(" (out of %d total)\n", len(results))
 	}
+	if config.SidecarOnly && successCount > 0 && !config.DryRun {
+		fmt.Println("Media files were left untouched; dates are in the .xmp and .json sidecars")
+	}
+	if inferredCount > 0 {
+		fmt.Printf("%d date(s) inferred from neighboring files (marked ~ above), please check them\n", inferredCount)
+	}
+	if sentCount > 0 {
+		fmt.Printf("%d of them sent from this phone, tagged %s\n", sentCount, processor.SentComment)
+	}
+	if correctCount > 0 {
+		fmt.Printf("%d of them already had the right date, metadata not rewritten\n", correctCount)
+	}
+	if keptCount > 0 {
+		fmt.Printf("%d of them already had a date, which was kept (use -ow to overwrite)\n", keptCount)
+	}
+	if n := unsetCounts[processor.MvhdZero]; n > 0 {
+		fmt.Printf("%d video(s) had an mvhd creation time of 0 (never set)\n", n)
+	}
+	if n := unsetCounts[processor.MvhdInvalid]; n > 0 {
+		fmt.Printf("%d video(s) had an invalid mvhd creation time (before 1970 or in the future)\n", n)
+	}
+	if unmatchedCount > 0 {
+		fmt.Printf("%d file(s) matched no filename pattern (use --ignore-unmatched to skip them)\n", unmatchedCount)
+	}
+	if sanitizedCount > 0 {
+		fmt.Printf("%d output name(s) changed to be valid on %s (marked ! above)\n", sanitizedCount, config.SanitizeNames)
+	}
+	if config.Timings {
+		fmt.Printf("Time by stage, over all files: %s\n", processor.SumTimings(results))
+	}
+
+	if tx != nil && !finishTransaction(tx, failCount, proc.Aborted()) {
+		fatalf("Error: --transaction: %d file(s) failed, no files were changed", failCount)
+	}
+
+	// Stop before uploads and repacking: the config is likely wrong
+	if proc.Aborted() {
+		fatalf("Error: aborted after %d failed files (--max-failures %s); the remaining files were not processed", failCount, config.MaxFailures)
+	}
+
+	// Upload staged outputs to the cloud target
+	if cloudTarget != nil && !config.DryRun {
+		var uploads []string
+		for _, r := range results {
+			if r.Success {
+				uploads = append(uploads, r.OutputFile)
+			}
+		}
+		if config.Verbose {
+			fmt.Printf("\nUploading %d file(s) to %s://%s...\n", len(uploads), cloudTarget.Scheme, cloudTarget.Bucket)
+		}
+		uploader := processor.NewUploader(cloudTarget, cloudCreds, opts.uploadWorkers, opts.uploadRetries)
+		uploader.Root = config.OutputDir
+		uploaded := uploader.UploadFiles(uploads)
+		*opts.failedUploads += printUploadResults("Upload", uploaded, config.Verbose)
+
+		// The staged copies are deleted: an uploaded file's output is its
+		// object, and a file that didn't make it has no output at all
+		cloudURIs = map[string]string{}
+		uploadErrs := map[string]error{}
+		for _, u := range uploaded {
+			if u.Error != nil {
+				uploadErrs[u.File] = u.Error
+			} else {
+				cloudURIs[u.File] = u.URI
+			}
+		}
+		for i := range results {
+			if err, ok := uploadErrs[results[i].OutputFile]; ok {
+				results[i].Success = false
+				results[i].Error = fmt.Errorf("upload failed: %w", err)
+			}
+		}
+	}
+
+	// Import into self-hosted photo libraries with the corrected dates
+	if !config.DryRun && (opts.immichURL != "" || opts.photoprismURL != "") {
+		assets := processor.LibraryAssetsFromResults(results)
+		if opts.immichURL != "" {
+			if config.Verbose {
+				fmt.Printf("\nUploading %d file(s) to Immich at %s...\n", len(assets), opts.immichURL)
+			}
+			client := processor.NewImmichClient(opts.immichURL, opts.immichAPIKey)
+			*opts.failedUploads += printUploadResults("Immich upload", client.UploadAssets(assets), config.Verbose)
+		}
+		if opts.photoprismURL != "" {
+			if config.Verbose {
+				fmt.Printf("\nUploading %d file(s) to PhotoPrism at %s...\n", len(assets), opts.photoprismURL)
+			}
+			client := processor.NewPhotoPrismClient(opts.photoprismURL, opts.photoprismToken, opts.photoprismUser)
+			*opts.failedUploads += printUploadResults("PhotoPrism upload", client.UploadAssets(assets), config.Verbose)
+		}
+	}
+	for i := range results {
+		if uri, ok := cloudURIs[results[i].OutputFile]; ok {
+			results[i].OutputFile = uri
+		}
+	}
+
+	// Repack extracted archive media if requested
+	if archiveDir != "" && opts.repack && !config.DryRun {
+		repackPath := processor.ArchiveRepackPath(opts.filePath, archiveDir)
+		if err := processor.PackArchive(repackPath, archiveDir, inputPaths); err != nil {
+			fatalf("Error repacking archive: %v", err)
+		}
+		fmt.Printf("Repacked %d file(s) into %s\n", len(inputPaths), repackPath)
+	}
+
+	// Record committed and uploaded changes in the audit log now they are
+	// final
+	if auditLog != nil {
+		if auditLater {
+			for _, r := range results {
+				if !r.Success {
+					continue
+				}
+				if _, err := auditLog.Append(r, proc.Now()); err != nil {
+					fatalf("Error: %v", err)
+				}
+			}
+		}
+		if config.Verbose {
+			fmt.Printf("Audit log %s: %d change(s) recorded\n", config.AuditLog, auditLog.Count())
+		}
+	}
+
+	// Write checksum manifest if requested (never in dry-run mode)
+	if config.ManifestPath != "" && !config.DryRun && !opts.combinedManifest {
+		manifest := processor.BuildManifestAt(results, proc.Now())
+		if err := processor.WriteManifest(config.ManifestPath, manifest); err != nil {
+			fatalf("Error writing manifest: %v", err)
+		}
+		fmt.Printf("Manifest written to %s (%d entries)\n", config.ManifestPath, len(manifest.Entries))
+	}
+
+	return results
+}
+
+// openJournal removes the leftovers of crashed runs, reports the originals
+// they left damaged, and starts this run's journal, in the run's directory
+// when it is recorded. Returns nil (no journaling) in dry-run mode or when
+// the journal directory can't be used.
+func openJournal(dryRun, verbose bool, run *processor.Run, runsDir string) *processor.Journal {
+	if dryRun {
+		return nil
+	}
+	dir, err := processor.DefaultJournalDir()
+	if err != nil && run == nil {
+		log.Printf("Warning: crash journal disabled: %v", err)
+		return nil
+	}
+
+	journalDirs := crashJournalDirs(runsDir)
+	var removed []string
+	for _, journalDir := range journalDirs {
+		files, err := processor.CleanupJournals(journalDir, processor.DefaultJournalStaleAfter, time.Now())
+		if err != nil {
+			log.Printf("Warning: failed to clean up after an interrupted run: %v", err)
+		}
+		removed = append(removed, files...)
+	}
+	if len(removed) > 0 {
+		fmt.Printf("Removed %d leftover file(s) from an interrupted run\n", len(removed))
+		if verbose {
+			for _, path := range removed {
+				fmt.Printf("  - %s\n", path)
+			}
+		}
+	}
+	if damaged := scanCrashJournals(journalDirs, processor.DefaultJournalStaleAfter); len(damaged) > 0 {
+		fmt.Printf("Warning: %d file(s) an interrupted run was editing in place no longer parse:\n", len(damaged))
+		for _, d := range damaged {
+			fmt.Printf("  ! %s: %v\n", d.Path, d.Problem)
+		}
+		recoverCmd := "wappd recover -backup <dir>"
+		if runsDir != processor.DefaultRunsDir {
+			recoverCmd += fmt.Sprintf(" -dir %q", runsDir)
+		}
+		fmt.Printf("Restore them with: %s\n", recoverCmd)
+	}
+
+	if run != nil {
+		dir = run.Dir()
+	}
+	journal, err := processor.NewJournal(dir)
+	if err != nil {
+		log.Printf("Warning: crash journal disabled: %v", err)
+		return nil
+	}
+	return journal
+}
+
+// openRunLog copies warnings and errors, and the verbose processing output
+// of the returned logger, to the run's log file
+func openRunLog(run *processor.Run) *log.Logger {
+	f, err := os.OpenFile(filepath.Join(run.Dir(), processor.RunLogName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("Warning: run log disabled: %v", err)
+		return nil
+	}
+	log.SetOutput(io.MultiWriter(os.Stderr, f))
+	return log.New(io.MultiWriter(os.Stdout, f), "", 0)
+}
+
+// anyConfigLoaded reports whether at least one target has a config file
+func anyConfigLoaded(configs []*processor.ConfigFile) bool {
+	for _, config := range configs {
+		if config != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// outputBase returns the directory outputs are written under: the output
+// directory, or where the inputs are when outputs go next to them
+func outputBase(config processor.Config, filePath string) string {
+	if config.OutputDir != "" {
+		return config.OutputDir
+	}
+	if filePath != "" && !processor.IsRemoteSource(filePath) && !processor.IsArchive(filePath) {
+		return filepath.Dir(filePath)
+	}
+	return config.InputDir
+}
+
+// transactionDir creates the directory a transaction stages its outputs in:
+// a new hidden directory in the output directory (or the closest existing
+// parent, so a rolled back run doesn't leave it behind). Being on the same
+// filesystem as the outputs, committing them is a rename.
+func transactionDir(config processor.Config) (string, error) {
+	base := config.OutputDir
+	if base == "" {
+		base = config.InputDir
+	}
+	if base == "" {
+		base = "."
+	}
+	for {
+		if _, err := os.Stat(base); err == nil || filepath.Dir(base) == base {
+			break
+		}
+		base = filepath.Dir(base)
+	}
+	return os.MkdirTemp(base, ".wappd-transaction-")
+}
+
+// finishTransaction moves a transaction's outputs into place when no file
+// failed. Otherwise, when run interactively and not aborted, it asks whether
+// to keep the successful ones; else it discards them all. Reports whether
+// the outputs were committed.
+func finishTransaction(tx *processor.Transaction, failed int, aborted bool) bool {
+	commit := failed == 0
+	if !commit && !aborted && isTerminal(os.Stdin) {
+		in := bufio.NewReader(os.Stdin)
+		commit = askYesNo(in, os.Stdout, fmt.Sprintf("%d file(s) failed. Move the successful outputs into place anyway?", failed), false)
+	}
+
+	if !commit {
+		if err := tx.Rollback(); err != nil {
+			log.Printf("Warning: rollback incomplete: %v", err)
+		}
+		fmt.Println("Transaction rolled back: no files were changed")
+		return false
+	}
+	committed, err := tx.Commit()
+	if err != nil {
+		log.Fatalf("Error: commit failed after moving %d output(s) into place: %v (remaining outputs are in %s)", committed, err, tx.Dir())
+	}
+	fmt.Printf("Transaction committed: %d output(s) moved into place\n", committed)
+	return true
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stringList collects the values of a repeatable string flag
+type stringList []string
+
+func (d *stringList) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *stringList) Set(value string) error {
+	*d = append(*d, value)
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// remoteInput returns the sftp:// or smb:// source given via -f or -d, if any
+func remoteInput(filePath, dirPath string) string {
+	if filePath != "" {
+		if processor.IsRemoteSource(filePath) {
+			return filePath
+		}
+		return ""
+	}
+	if processor.IsRemoteSource(dirPath) {
+		return dirPath
+	}
+	return ""
+}
+
+// printDuplicates lists duplicate groups, the files of similar ones only
+// in verbose mode, and sums up the redundancy they carry
+func printDuplicates(groups []processor.DuplicateGroup, verbose bool) {
+	fmt.Printf("\nDuplicates:\n")
+	var redundant int64
+	identical, similar := 0, 0
+	for _, g := range groups {
+		switch g.Kind {
+		case processor.DuplicateIdentical:
+			identical++
+			redundant += g.Redundant()
+			fmt.Printf("  = %d identical copies of %s (%s redundant):\n", len(g.Files), processor.FormatSize(g.Size), processor.FormatSize(g.Redundant()))
+		case processor.DuplicateSimilar:
+			similar++
+			if !verbose {
+				continue
+			}
+			fmt.Printf("  ~ %d versions of %s:\n", len(g.Files), g.Key)
+		}
+		for _, f := range g.Files {
+			fmt.Printf("      %s\n", f)
+		}
+	}
+	if identical == 0 && similar == 0 {
+		fmt.Printf("  none found\n")
+		return
+	}
+	fmt.Printf("%d set(s) of identical files carry %s of redundant copies", identical, processor.FormatSize(redundant))
+	if similar > 0 {
+		fmt.Printf("; %d WhatsApp media item(s) have differing versions", similar)
+		if !verbose {
+			fmt.Printf(" (-v lists them)")
+		}
+	}
+	fmt.Println()
+}
+
+// printUploadResults prints failed uploads (and successful ones in verbose
+// mode) followed by a one-line summary, and returns how many failed
+func printUploadResults(label string, uploads []processor.UploadResult, verbose bool) int {
+	failed := 0
+	for _, u := range uploads {
+		if u.Error != nil {
+			failed++
+			fmt.Printf("  ✗ %s: %v\n", u.File, u.Error)
+		} else if verbose {
+			fmt.Printf("  ✓ %s → %s (attempts: %d)\n", u.File, u.URI, u.Attempts)
+		}
+	}
+	fmt.Printf("%s complete: %d uploaded", label, len(uploads)-failed)
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println()
+	return failed
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runRecover implements the "recover" subcommand, which lists the originals
+// crashed runs left damaged while editing them in place and, with -backup,
+// restores them from a backup
+func runRecover(args []string) int {
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	runsDir := fs.String("dir", processor.DefaultRunsDir, "Runs directory (as given to -runs-dir)")
+	backupDir := fs.String("backup", "", "Directory holding backups of the damaged files, searched by name and size")
+	dryRun := fs.Bool("dry-run", false, "Show which backups would be restored without changing anything")
+	force := fs.Bool("force", false, "Take every journal whose run can't be checked for crashed, however recent")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd recover [-dir <path>] [-backup <dir>] [-dry-run] [-force]\n\n")
+		fmt.Fprintf(os.Stderr, "Lists the files an interrupted run was editing in place that no longer\n")
+		fmt.Fprintf(os.Stderr, "parse as JPEG or MP4 and, with -backup, copies their backups over them.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	staleAfter := processor.DefaultJournalStaleAfter
+	if *force {
+		staleAfter = 0
+	}
+	damaged := scanCrashJournals(crashJournalDirs(*runsDir), staleAfter)
+	if len(damaged) == 0 {
+		fmt.Println("No damaged files found")
+		return 0
+	}
+	if *backupDir == "" {
+		for _, d := range damaged {
+			fmt.Printf("  ! %s: %v\n", d.Path, d.Problem)
+		}
+		fmt.Printf("\n%d damaged file(s); restore them with -backup <dir>\n", len(damaged))
+		return 1
+	}
+
+	failed := 0
+	for _, d := range damaged {
+		backup, err := processor.RestoreFromBackup(d, *backupDir, *dryRun)
+		if err != nil {
+			failed++
+			fmt.Printf("  ✗ %s: %v\n", d.Path, err)
+			continue
+		}
+		fmt.Printf("  ✓ %s ← %s\n", d.Path, backup)
+	}
+
+	verb := "Restored"
+	if *dryRun {
+		verb = "Would restore"
+	}
+	fmt.Printf("\n%s %d file(s)", verb, len(damaged)-failed)
+	if failed > 0 {
+		fmt.Printf(", %d without a backup", failed)
+	}
+	fmt.Println()
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// crashJournalDirs returns the directories crashed runs may have left their
+// journal in: the default journal directory and each run directory
+func crashJournalDirs(runsDir string) []string {
+	var dirs []string
+	if dir, err := processor.DefaultJournalDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	if runsDir != "" {
+		runDirs, err := processor.RunDirs(runsDir)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		dirs = append(dirs, runDirs...)
+	}
+	return dirs
+}
+
+// scanCrashJournals returns the damaged originals listed in the crashed
+// runs' journals under dirs. Runs are told crashed by their journal's lock;
+// staleAfter only applies where that can't be checked (see ScanJournals).
+func scanCrashJournals(dirs []string, staleAfter time.Duration) []processor.DamagedFile {
+	var damaged []processor.DamagedFile
+	for _, dir := range dirs {
+		files, err := processor.ScanJournals(dir, staleAfter, time.Now())
+		if err != nil {
+			log.Printf("Warning: failed to scan journals: %v", err)
+		}
+		damaged = append(damaged, files...)
+	}
+	return damaged
+}
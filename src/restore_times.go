@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runRestoreTimes implements the "restore-times" subcommand, which puts
+// back the file modification times a recorded run changed. File contents
+// aren't restored.
+func runRestoreTimes(args []string) int {
+	fs := flag.NewFlagSet("restore-times", flag.ExitOnError)
+	runsDir := fs.String("dir", processor.DefaultRunsDir, "Runs directory (as given to -runs-dir)")
+	dryRun := fs.Bool("dry-run", false, "Show what would be restored without changing anything")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd restore-times [-dir <path>] [-dry-run] <run-id>\n\n")
+		fmt.Fprintf(os.Stderr, "Restores the modification times of the files a run edited in place.\n")
+		fmt.Fprintf(os.Stderr, "Only the times: metadata the run wrote into the files stays.\n")
+		fmt.Fprintf(os.Stderr, "List run IDs with \"wappd runs list\".\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	record, err := processor.LoadRun(filepath.Join(*runsDir, fs.Arg(0)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: run %s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+
+	results := processor.RestoreModTimes(record, *dryRun)
+	failed := 0
+	for _, r := range results {
+		if r.Error != nil {
+			failed++
+			fmt.Printf("  ✗ %s: %v\n", r.File, r.Error)
+			continue
+		}
+		fmt.Printf("  ✓ %s → %s\n", r.File, r.ModTime.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	verb := "Restored"
+	if *dryRun {
+		verb = "Would restore"
+	}
+	fmt.Printf("\n%s the modification time of %d file(s)", verb, len(results)-failed)
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println()
+	fmt.Println("File contents edited in place are not restored; copies written elsewhere can simply be deleted")
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
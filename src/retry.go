@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runRetry implements the "retry" subcommand. It lists the files a recorded
+// run failed in the run's directory and returns the arguments the regular
+// processing run should continue with: the run's own, selecting just those
+// files, followed by any overrides.
+func runRetry(args []string) []string {
+	fs := flag.NewFlagSet("retry", flag.ExitOnError)
+	runsDir := fs.String("dir", processor.DefaultRunsDir, "Runs directory (as given to -runs-dir)")
+	runID := fs.String("run", "", "ID of the run whose failed files to retry (see \"wappd runs list\")")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd retry [-dir <path>] -run <run-id> [-- overriding flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Reprocesses exactly the files a run failed, with the run's options, from the\n")
+		fmt.Fprintf(os.Stderr, "directory it ran in. Flags after -- are added after the run's own and\n")
+		fmt.Fprintf(os.Stderr, "override them.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExample:\n")
+		fmt.Fprintf(os.Stderr, "  wappd retry -run 20240501-120000 -- --extra-patterns camera\n")
+	}
+	fs.Parse(args)
+
+	if *runID == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	runDir := filepath.Join(*runsDir, *runID)
+	record, err := processor.LoadRun(runDir)
+	if err != nil {
+		log.Fatalf("Error: run %s: %v", *runID, err)
+	}
+	failed := record.Failed()
+	if len(failed) == 0 {
+		fmt.Printf("Run %s has no failed files to retry\n", record.ID)
+		os.Exit(0)
+	}
+
+	// The list lives with the run it came from; paths and options stay
+	// relative to the directory the run was started in
+	listPath, err := filepath.Abs(filepath.Join(runDir, processor.RunFailedName))
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := processor.WriteFileList(listPath, failed); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if record.WorkDir != "" {
+		if err := os.Chdir(record.WorkDir); err != nil {
+			log.Fatalf("Error: run %s ran in %s: %v", record.ID, record.WorkDir, err)
+		}
+	}
+	fmt.Printf("Retrying %d failed file(s) of run %s in %s\n\n", len(failed), record.ID, record.WorkDir)
+	if secrets := secretFlags(record.Args); len(secrets) > 0 {
+		fmt.Printf("Note: %s weren't recorded; pass them again after --\n\n", strings.Join(secrets, ", "))
+	}
+
+	retryArgs := append(retrySelection(record.Args), "--files-from", listPath)
+	return append(retryArgs, fs.Args()...)
+}
+
+// secretFlags returns the secret flags a run was given, which its record
+// doesn't hold the values of
+func secretFlags(args []string) []string {
+	var secrets []string
+	for _, arg := range args {
+		if secret, _ := processor.IsSecretFlag(arg); secret {
+			name, _, _ := strings.Cut(arg, "=")
+			secrets = append(secrets, name)
+		}
+	}
+	return secrets
+}
+
+// retrySelection returns a run's arguments without the ones choosing which
+// files it processed (-f, --files-from, and -d unless it named a single
+// input directory, which --files-from keeps using). --whatsapp-root stays,
+// so each listed file gets its media folder's handling again. Secret flags,
+// recorded redacted, are left out too.
+func retrySelection(args []string) []string {
+	dirs := 0
+	for _, arg := range args {
+		if name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "="); strings.HasPrefix(arg, "-") && (name == "d" || name == "dir") {
+			dirs++
+		}
+	}
+
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			kept = append(kept, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if secret, _ := processor.IsSecretFlag(arg); secret {
+			if !hasValue {
+				i++ // Skip the redacted value
+			}
+			continue
+		}
+		switch name {
+		case "f", "file", "files-from":
+		case "d", "dir":
+			if dirs == 1 {
+				kept = append(kept, arg)
+				if !hasValue && i+1 < len(args) {
+					kept = append(kept, args[i+1])
+				}
+			}
+		default:
+			kept = append(kept, arg)
+			continue
+		}
+		if !hasValue {
+			i++ // Skip the flag's value
+		}
+	}
+	return kept
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runRuns implements the "runs" subcommand, which lists the runs recorded
+// under the runs directory
+func runRuns(args []string) int {
+	fs := flag.NewFlagSet("runs", flag.ExitOnError)
+	runsDir := fs.String("dir", processor.DefaultRunsDir, "Runs directory (as given to -runs-dir)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd runs list [-dir <path>]\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "list" {
+		fs.Usage()
+		return 2
+	}
+	fs.Parse(args[1:])
+
+	records, err := processor.ListRuns(*runsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(records) == 0 {
+		fmt.Printf("No runs recorded in %s\n", *runsDir)
+		return 0
+	}
+
+	for _, r := range records {
+		success, failed, skipped := r.Counts()
+		status := "finished"
+		if r.Finished == "" {
+			status = "incomplete"
+		}
+		fmt.Printf("%s  %s  %-10s  %d ok, %d failed, %d skipped  wappd %s\n",
+			r.ID, r.Started, status, success, failed, skipped, strings.Join(r.Args, " "))
+	}
+	return 0
+}
@@ -0,0 +1,255 @@
+// Package scan finds the media files wappd processes under a directory.
+// It has no dependencies on the rest of wappd, so other tools can discover
+// files the same way.
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mediaExts are the image, video and audio extensions a Scanner finds
+var mediaExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true, ".webp": true,
+	".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".flv": true, ".m4v": true, ".3gp": true,
+	".opus": true, ".m4a": true, ".aac": true,
+}
+
+// documentExts are the documents WhatsApp shares as DOC-*
+var documentExts = map[string]bool{
+	".pdf": true, ".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+}
+
+// IsMedia reports whether a Scanner finds files with the (lower-case,
+// dotted) extension ext by default
+func IsMedia(ext string) bool {
+	return mediaExts[ext]
+}
+
+// IsDocument reports whether ext is a document extension, found with
+// IncludeDocuments
+func IsDocument(ext string) bool {
+	return documentExts[ext]
+}
+
+// Scanner finds the media files under a directory. The zero value finds
+// every image, video and audio file at any depth, without following
+// symlinked directories.
+type Scanner struct {
+	IncludeDocuments bool // Also find documents (PDF, Office files)
+	MaxDepth         int  // Directory levels to look in: 1 = the directory's own files only (0 = no limit)
+	FollowSymlinks   bool // Descend into symlinked directories (each real directory is walked once)
+
+	// Extensions adjusts the extensions found: "heic" (or ".heic") adds
+	// one, "-gif" leaves one out (see ValidateExtensions)
+	Extensions []string
+
+	// Detect, when set, is asked about each file its extension doesn't
+	// decide, e.g. to find files by their content. Extensions left out
+	// on purpose never reach it.
+	Detect func(path string) bool
+
+	// SkipDirs, when set, is asked about each directory below the root;
+	// returning true leaves it and everything under it out. name is the
+	// directory's base name.
+	SkipDirs func(path, name string) bool
+}
+
+// SkipPaths returns a SkipDirs that leaves out the given directories, e.g.
+// an output directory inside the input tree. Empty paths are ignored.
+func SkipPaths(dirs ...string) func(path, name string) bool {
+	skip := map[string]bool{}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			skip[abs] = true
+		}
+	}
+	return func(path, name string) bool {
+		abs, err := filepath.Abs(path)
+		return err == nil && skip[abs]
+	}
+}
+
+// SkipNames returns a SkipDirs that leaves out the directories whose base
+// name matches one of patterns (filepath.Match syntax, e.g. ".Statuses" or
+// "Backup*"; see ValidateSkipNames)
+func SkipNames(patterns ...string) func(path, name string) bool {
+	return func(path, name string) bool {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ValidateSkipNames checks a list of directory name patterns for SkipNames
+func ValidateSkipNames(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" || strings.ContainsAny(pattern, `/\`) {
+			return fmt.Errorf("invalid directory name pattern %q (expected a name such as .Statuses or Backup*)", pattern)
+		}
+	}
+	return nil
+}
+
+// ValidateExtensions checks a list of extensions to add ("heic", ".heic")
+// or leave out ("-gif")
+func ValidateExtensions(exts []string) error {
+	for _, ext := range exts {
+		name := strings.TrimPrefix(strings.TrimPrefix(ext, "-"), ".")
+		if name == "" || strings.ContainsAny(name, `./\ `) {
+			return fmt.Errorf("invalid extension %q (expected e.g. heic to add it or -gif to leave it out)", ext)
+		}
+	}
+	return nil
+}
+
+// Files returns the media files under dirPath, in the order Walk finds them
+func (s Scanner) Files(dirPath string) ([]string, error) {
+	var files []string
+	err := s.Walk(dirPath, func(path string) error {
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+// Walk calls fn with each media file under dirPath as it's found, without
+// building the list. Files come in lexicographic order of their full paths,
+// on every filesystem, so batches and reports are reproducible. An error
+// from fn stops the walk and is returned. A dirPath that is a file is
+// passed to fn if it's media.
+func (s Scanner) Walk(dirPath string, fn func(path string) error) error {
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return err
+	}
+	w := &scanWalk{scanner: s, fn: fn, exts: s.extensionOverrides()}
+	if !info.IsDir() {
+		return w.visit(dirPath)
+	}
+	if s.FollowSymlinks {
+		w.seen = map[string]bool{}
+		if !w.enter(dirPath) {
+			return nil
+		}
+	}
+	return w.walk(dirPath, 1)
+}
+
+// extensionOverrides maps the extensions Extensions adds to true and the
+// ones it leaves out to false (the last mention of an extension wins)
+func (s Scanner) extensionOverrides() map[string]bool {
+	overrides := map[string]bool{}
+	for _, ext := range s.Extensions {
+		add := !strings.HasPrefix(ext, "-")
+		overrides["."+strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(ext, "-"), "."))] = add
+	}
+	return overrides
+}
+
+// scanWalk is the state of one Scanner.Walk
+type scanWalk struct {
+	scanner Scanner
+	fn      func(path string) error
+	exts    map[string]bool // Extension overrides, from Scanner.Extensions
+	seen    map[string]bool // Real paths of the directories walked, when following symlinks
+}
+
+// visit passes path to fn if it's a file the scanner finds. Detect is only
+// asked about files their extension doesn't decide, and never about
+// extensions left out on purpose.
+func (w *scanWalk) visit(path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	found, overridden := w.exts[ext]
+	if !overridden {
+		found = mediaExts[ext] || (w.scanner.IncludeDocuments && documentExts[ext])
+		if !found && w.scanner.Detect != nil {
+			found = w.scanner.Detect(path)
+		}
+	}
+	if found {
+		return w.fn(path)
+	}
+	return nil
+}
+
+// enter reports whether a directory hasn't been walked yet, recording it
+func (w *scanWalk) enter(dir string) bool {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	if w.seen[resolved] {
+		return false
+	}
+	w.seen[resolved] = true
+	return true
+}
+
+// scanEntry is a directory entry, with symlinks resolved when followed
+type scanEntry struct {
+	name  string
+	isDir bool
+}
+
+// walk visits the files under dir, which is at depth (the root is at 1).
+// A directory sorts as its name plus a separator, which is where its files
+// fall among its siblings ("a.jpg" < "a/b.jpg" < "a0.jpg"); plain name
+// order would put "a/b.jpg" first.
+func (w *scanWalk) walk(dir string, depth int) error {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	entries := make([]scanEntry, 0, len(dirEntries))
+	for _, e := range dirEntries {
+		isDir := e.IsDir()
+		if w.scanner.FollowSymlinks && e.Type()&os.ModeSymlink != 0 {
+			// A dangling link is left to fail as a file, as without following
+			if info, err := os.Stat(filepath.Join(dir, e.Name())); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		entries = append(entries, scanEntry{e.Name(), isDir})
+	}
+	key := func(e scanEntry) string {
+		if e.isDir {
+			return e.name + string(filepath.Separator)
+		}
+		return e.name
+	}
+	sort.Slice(entries, func(i, j int) bool { return key(entries[i]) < key(entries[j]) })
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.name)
+		if !e.isDir {
+			err = w.visit(path)
+		} else if w.descend(path, e.name, depth+1) {
+			err = w.walk(path, depth+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// descend reports whether the walk goes into a subdirectory at depth
+func (w *scanWalk) descend(path, name string, depth int) bool {
+	if w.scanner.MaxDepth > 0 && depth > w.scanner.MaxDepth {
+		return false
+	}
+	if w.scanner.SkipDirs != nil && w.scanner.SkipDirs(path, name) {
+		return false
+	}
+	return w.seen == nil || w.enter(path)
+}
@@ -0,0 +1,31 @@
+package processor_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestAdbArgs(t *testing.T) {
+	got := processor.AdbArgs("", "pull", "-a", "/sdcard/WhatsApp/Media", "out")
+	want := []string{"pull", "-a", "/sdcard/WhatsApp/Media", "out"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AdbArgs() without serial = %q, want %q", got, want)
+	}
+
+	got = processor.AdbArgs("R58M123", "shell", "ls")
+	want = []string{"-s", "R58M123", "shell", "ls"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AdbArgs() with serial = %q, want %q", got, want)
+	}
+}
+
+func TestAndroidMediaDirs_ScopedStorageFirst(t *testing.T) {
+	if len(processor.AndroidMediaDirs) == 0 {
+		t.Fatal("AndroidMediaDirs should not be empty")
+	}
+	if processor.AndroidMediaDirs[0] != "/sdcard/Android/media/com.whatsapp/WhatsApp/Media" {
+		t.Errorf("AndroidMediaDirs[0] = %s, want the Android 11+ scoped storage path", processor.AndroidMediaDirs[0])
+	}
+}
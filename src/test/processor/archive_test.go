@@ -0,0 +1,234 @@
+package processor_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// writeTestZip creates a ZIP archive containing the given entries
+func writeTestZip(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	w := zip.NewWriter(f)
+	for name, data := range entries {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add zip entry %s: %v", name, err)
+		}
+		fw.Write(data)
+	}
+	w.Close()
+	f.Close()
+}
+
+func TestIsZipArchive(t *testing.T) {
+	if !processor.IsZipArchive("WhatsApp Chat.ZIP") {
+		t.Error("IsZipArchive() should match .ZIP case-insensitively")
+	}
+	if processor.IsZipArchive("IMG-20250122-WA0003.jpg") {
+		t.Error("IsZipArchive() should not match .jpg")
+	}
+}
+
+func TestExtractZipMedia(t *testing.T) {
+	tmpDir := t.TempDir()
+	zipPath := filepath.Join(tmpDir, "chat.zip")
+	writeTestZip(t, zipPath, map[string][]byte{
+		"IMG-20250122-WA0003.jpg":       minimalJPEG(),
+		"media/VID-20240415-WA0010.mp4": []byte("video"),
+		"_chat.txt":                     []byte("chat log"),
+	})
+
+	destDir := filepath.Join(tmpDir, "extracted")
+
+	listed, err := processor.ListZipMedia(zipPath, destDir)
+	if err != nil {
+		t.Fatalf("ListZipMedia() error = %v", err)
+	}
+	if _, err := os.Stat(destDir); !os.IsNotExist(err) {
+		t.Error("ListZipMedia() should not extract anything")
+	}
+
+	files, err := processor.ExtractZipMedia(zipPath, destDir)
+	if err != nil {
+		t.Fatalf("ExtractZipMedia() error = %v", err)
+	}
+
+	sort.Strings(listed)
+	sort.Strings(files)
+	want := []string{
+		filepath.Join(destDir, "IMG-20250122-WA0003.jpg"),
+		filepath.Join(destDir, "media", "VID-20240415-WA0010.mp4"),
+	}
+	if len(files) != len(want) || len(listed) != len(want) {
+		t.Fatalf("ExtractZipMedia() = %v, ListZipMedia() = %v, want %v", files, listed, want)
+	}
+	for i := range want {
+		if files[i] != want[i] || listed[i] != want[i] {
+			t.Errorf("file %d: extracted %s, listed %s, want %s", i, files[i], listed[i], want[i])
+		}
+		if _, err := os.Stat(want[i]); err != nil {
+			t.Errorf("extracted file missing: %v", err)
+		}
+	}
+}
+
+func TestExtractZipMedia_RejectsPathTraversal(t *testing.T) {
+	tmpDir := t.TempDir()
+	zipPath := filepath.Join(tmpDir, "evil.zip")
+	writeTestZip(t, zipPath, map[string][]byte{
+		"../IMG-20250122-WA0003.jpg": minimalJPEG(),
+	})
+
+	if _, err := processor.ExtractZipMedia(zipPath, filepath.Join(tmpDir, "out")); err == nil {
+		t.Error("ExtractZipMedia() should reject entries escaping the destination")
+	}
+}
+
+func TestPackZip(t *testing.T) {
+	tmpDir := t.TempDir()
+	baseDir := filepath.Join(tmpDir, "media")
+	os.MkdirAll(filepath.Join(baseDir, "sub"), 0755)
+	files := []string{
+		filepath.Join(baseDir, "IMG-20250122-WA0003.jpg"),
+		filepath.Join(baseDir, "sub", "IMG-20240415-WA0010.jpg"),
+	}
+	for _, f := range files {
+		os.WriteFile(f, minimalJPEG(), 0644)
+	}
+
+	zipPath := filepath.Join(tmpDir, "repacked.zip")
+	if err := processor.PackZip(zipPath, baseDir, files); err != nil {
+		t.Fatalf("PackZip() error = %v", err)
+	}
+
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to open repacked zip: %v", err)
+	}
+	defer r.Close()
+
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "IMG-20250122-WA0003.jpg" || names[1] != "sub/IMG-20240415-WA0010.jpg" {
+		t.Errorf("PackZip() entries = %v", names)
+	}
+}
+
+// writeTestTarGz creates a gzip-compressed tar archive containing the given entries
+func writeTestTarGz(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create tar.gz: %v", err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, data := range entries {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+}
+
+func TestArchiveExtractDir(t *testing.T) {
+	tests := []struct {
+		archive string
+		want    string
+	}{
+		{"chat.zip", "chat_modified"},
+		{"backup.tar", "backup_modified"},
+		{"backup.tar.gz", "backup_modified"},
+		{"backup.TGZ", "backup_modified"},
+	}
+	for _, tt := range tests {
+		if got := processor.ArchiveExtractDir(tt.archive); got != tt.want {
+			t.Errorf("ArchiveExtractDir(%s) = %s, want %s", tt.archive, got, tt.want)
+		}
+	}
+	if got := processor.ArchiveRepackPath("backup.tar.gz", "out"); got != "out.tar.gz" {
+		t.Errorf("ArchiveRepackPath() = %s, want out.tar.gz", got)
+	}
+	cwd, _ := os.Getwd()
+	if got := processor.ArchiveRepackPath("chat.zip", "."); got != cwd+".zip" {
+		t.Errorf("ArchiveRepackPath(.) = %s, want %s.zip", got, cwd)
+	}
+}
+
+func TestValidateArchiveExtractDir(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "exports", "chat.zip")
+	tests := []struct {
+		extractDir string
+		wantErr    bool
+	}{
+		{filepath.Join(dir, "exports", "chat_modified"), false},
+		{filepath.Join(dir, "out"), false},
+		{filepath.Join(dir, "exports"), true},
+		{dir, true},
+	}
+	for _, tt := range tests {
+		if err := processor.ValidateArchiveExtractDir(archive, tt.extractDir); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateArchiveExtractDir(%s) error = %v, want error %v", tt.extractDir, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExtractArchiveMedia_TarGz(t *testing.T) {
+	tmpDir := t.TempDir()
+	tarPath := filepath.Join(tmpDir, "backup.tar.gz")
+	writeTestTarGz(t, tarPath, map[string][]byte{
+		"WhatsApp/Media/WhatsApp Images/IMG-20250122-WA0003.jpg": minimalJPEG(),
+		"WhatsApp/Databases/msgstore.db.crypt14":                 []byte("db"),
+	})
+
+	destDir := filepath.Join(tmpDir, "out")
+	want := filepath.Join(destDir, "WhatsApp", "Media", "WhatsApp Images", "IMG-20250122-WA0003.jpg")
+
+	listed, err := processor.ListArchiveMedia(tarPath, destDir)
+	if err != nil {
+		t.Fatalf("ListArchiveMedia() error = %v", err)
+	}
+	if len(listed) != 1 || listed[0] != want {
+		t.Errorf("ListArchiveMedia() = %v, want [%s]", listed, want)
+	}
+
+	files, err := processor.ExtractArchiveMedia(tarPath, destDir)
+	if err != nil {
+		t.Fatalf("ExtractArchiveMedia() error = %v", err)
+	}
+	if len(files) != 1 || files[0] != want {
+		t.Fatalf("ExtractArchiveMedia() = %v, want [%s]", files, want)
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("extracted file missing: %v", err)
+	}
+
+	// Repack and extract again to confirm relative paths survive a round trip
+	repacked := filepath.Join(tmpDir, "repacked.tar.gz")
+	if err := processor.PackArchive(repacked, destDir, files); err != nil {
+		t.Fatalf("PackArchive() error = %v", err)
+	}
+	again, err := processor.ListArchiveMedia(repacked, destDir)
+	if err != nil {
+		t.Fatalf("ListArchiveMedia() on repacked archive error = %v", err)
+	}
+	if len(again) != 1 || again[0] != want {
+		t.Errorf("repacked archive entries = %v, want [%s]", again, want)
+	}
+}
@@ -0,0 +1,177 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// oggPage builds an Ogg page holding one complete packet (CRC left zero)
+func oggPage(headerType byte, sequence uint32, packet []byte) []byte {
+	var segments []byte
+	n := len(packet)
+	for n >= 255 {
+		segments = append(segments, 255)
+		n -= 255
+	}
+	segments = append(segments, byte(n))
+
+	page := make([]byte, 27)
+	copy(page[0:4], "OggS")
+	page[5] = headerType
+	binary.LittleEndian.PutUint32(page[14:18], 1) // Serial number
+	binary.LittleEndian.PutUint32(page[18:22], sequence)
+	page[26] = byte(len(segments))
+	page = append(page, segments...)
+	return append(page, packet...)
+}
+
+// opusTagsPacket builds an OpusTags packet with the given comments
+func opusTagsPacket(comments ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OpusTags")
+	binary.Write(&buf, binary.LittleEndian, uint32(len("WhatsApp")))
+	buf.WriteString("WhatsApp")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(c)))
+		buf.WriteString(c)
+	}
+	return buf.Bytes()
+}
+
+// minimalOpus builds OpusHead + OpusTags + one audio page
+func minimalOpus(comments ...string) []byte {
+	head := append([]byte("OpusHead"), 1, 1, 0x38, 0x01, 0x80, 0xBB, 0, 0, 0, 0, 0)
+	var data []byte
+	data = append(data, oggPage(0x02, 0, head)...)
+	data = append(data, oggPage(0x00, 1, opusTagsPacket(comments...))...)
+	data = append(data, oggPage(0x04, 2, []byte("audio frames"))...)
+	return data
+}
+
+func TestUpdateOpusDate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "PTT-20240501-WA0007.opus")
+	original := minimalOpus("ENCODER=test")
+	os.WriteFile(path, original, 0644)
+
+	dt := time.Date(2024, 5, 1, 10, 15, 30, 0, time.UTC)
+	updated, err := processor.UpdateOpusDate(path, dt, false)
+	if err != nil || !updated {
+		t.Fatalf("UpdateOpusDate() = %v, %v, want true, nil", updated, err)
+	}
+
+	date, err := processor.ReadOpusDate(path)
+	if err != nil {
+		t.Fatalf("ReadOpusDate() error = %v", err)
+	}
+	if date != "2024-05-01T10:15:30" {
+		t.Errorf("DATE = %q, want 2024-05-01T10:15:30", date)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !bytes.HasSuffix(data, []byte("audio frames")) {
+		t.Error("audio page was modified")
+	}
+}
+
+func TestUpdateOpusDate_KeepsExistingWithoutOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "PTT-20240501-WA0007.opus")
+	os.WriteFile(path, minimalOpus("DATE=2020-01-01"), 0644)
+
+	dt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if updated, _ := processor.UpdateOpusDate(path, dt, false); updated {
+		t.Error("UpdateOpusDate() replaced an existing DATE without overwrite")
+	}
+	if updated, err := processor.UpdateOpusDate(path, dt, true); err != nil || !updated {
+		t.Fatalf("UpdateOpusDate(overwrite) = %v, %v", updated, err)
+	}
+	if date, _ := processor.ReadOpusDate(path); date != "2024-05-01T00:00:00" {
+		t.Errorf("DATE = %q, want 2024-05-01T00:00:00", date)
+	}
+}
+
+// m4aWithMdatAfterMoov builds ftyp + moov(mvhd, trak(...stco)) + mdat with
+// the single chunk offset pointing at the mdat payload
+func m4aWithMdatAfterMoov() []byte {
+	build := func(offset uint32) []byte {
+		stco := make([]byte, 12)
+		binary.BigEndian.PutUint32(stco[4:8], 1)
+		binary.BigEndian.PutUint32(stco[8:12], offset)
+		stbl := box("stbl", box("stco", stco))
+		moov := box("moov", box("mvhd", mvhdV0()), box("trak", box("mdia", box("minf", stbl))))
+		var data []byte
+		data = append(data, box("ftyp", []byte("M4A "), make([]byte, 4))...)
+		data = append(data, moov...)
+		return append(data, box("mdat", []byte("aac frames"))...)
+	}
+	probe := build(0)
+	return build(uint32(len(probe) - len("aac frames")))
+}
+
+func TestUpdateM4AMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "AUD-20240501-WA0002.m4a")
+	os.WriteFile(path, m4aWithMdatAfterMoov(), 0644)
+
+	dt := time.Date(2024, 5, 1, 10, 15, 30, 0, time.UTC)
+	if err := processor.UpdateM4AMetadata(path, dt, false); err != nil {
+		t.Fatalf("UpdateM4AMetadata() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	day, err := processor.ReadQuickTimeDay(data)
+	if err != nil {
+		t.Fatalf("ReadQuickTimeDay() error = %v", err)
+	}
+	if day != "2024-05-01T10:15:30Z" {
+		t.Errorf("©day = %q, want 2024-05-01T10:15:30Z", day)
+	}
+
+	atoms, _ := processor.ParseMP4Atoms(data)
+	moov := processor.FindAtom(atoms, "moov")
+	mvhd := processor.FindAtomRecursive(*moov, "mvhd")
+	if got := processor.QuickTimeToUnix(binary.BigEndian.Uint32(mvhd.Data[4:8])); got != dt.Unix() {
+		t.Errorf("mvhd creation time = %d, want %d", got, dt.Unix())
+	}
+
+	// The chunk offset must still point at the (moved) media data
+	stco := processor.FindAtomRecursive(*moov, "stco")
+	offset := binary.BigEndian.Uint32(stco.Data[8:12])
+	if got := string(data[offset : offset+10]); got != "aac frames" {
+		t.Errorf("stco points at %q, want \"aac frames\"", got)
+	}
+}
+
+func TestUpdateM4AMetadata_KeepsExistingDayWithoutOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "AUD-20240501-WA0002.m4a")
+	os.WriteFile(path, m4aWithMdatAfterMoov(), 0644)
+
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	processor.UpdateM4AMetadata(path, first, false)
+	processor.UpdateM4AMetadata(path, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), false)
+
+	data, _ := os.ReadFile(path)
+	if day, _ := processor.ReadQuickTimeDay(data); day != "2020-01-01T00:00:00Z" {
+		t.Errorf("©day = %q, want existing 2020-01-01T00:00:00Z", day)
+	}
+}
+
+func TestGetImageVideoFiles_Audio(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"PTT-20240501-WA0007.opus", "AUD-20240501-WA0002.m4a", "AUD-20240501-WA0003.aac", "notes.txt"} {
+		os.WriteFile(filepath.Join(tmpDir, name), []byte("test content"), 0644)
+	}
+
+	files, err := processor.GetImageVideoFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("GetImageVideoFiles() error = %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("GetImageVideoFiles() returned %d files, want 3", len(files))
+	}
+}
@@ -0,0 +1,146 @@
+package processor_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestAuditLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	media := filepath.Join(tmpDir, "media")
+	os.Mkdir(media, 0755)
+	os.WriteFile(filepath.Join(media, "IMG-20240501-WA0001.jpg"), minimalJPEG(), 0644)
+	os.WriteFile(filepath.Join(media, "IMG-20240502-WA0002.jpg"), minimalJPEG(), 0644)
+	logPath := filepath.Join(tmpDir, "audit.jsonl")
+
+	config := processor.Config{InputDir: media, OverrideOriginal: true, UpdateModified: true, AuditLog: logPath}
+	proc := processor.New(config)
+	results := proc.ProcessFiles([]string{filepath.Join(media, "IMG-20240501-WA0001.jpg"), filepath.Join(media, "IMG-20240502-WA0002.jpg")})
+	n, err := processor.AppendAuditLog(logPath, results, time.Now())
+	if err != nil || n != 2 {
+		t.Fatalf("AppendAuditLog() = %d, %v, want 2 entries", n, err)
+	}
+
+	// A second run over the same files changes nothing and records nothing
+	results = proc.ProcessFiles([]string{filepath.Join(media, "IMG-20240501-WA0001.jpg")})
+	if n, err := processor.AppendAuditLog(logPath, results, time.Now()); err != nil || n != 0 {
+		t.Fatalf("AppendAuditLog() of an unchanged file = %d, %v, want 0", n, err)
+	}
+	// Re-dating the file is recorded, chained to the earlier entries
+	later := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(media, "IMG-20240501-WA0001.jpg"), later, later)
+	results = proc.ProcessFiles([]string{filepath.Join(media, "IMG-20240501-WA0001.jpg")})
+	if n, err := processor.AppendAuditLog(logPath, results, time.Now()); err != nil || n != 1 {
+		t.Fatalf("AppendAuditLog() of a re-timed file = %d, %v, want 1", n, err)
+	}
+
+	entries := readAuditLog(t, logPath)
+	if len(entries) != 3 {
+		t.Fatalf("audit log has %d entries, want 3", len(entries))
+	}
+	first := entries[0]
+	if first.Prev != "" || first.PreHash == "" || first.PreHash == first.PostHash || first.DateWritten != "2024-05-01T00:00:00" ||
+		first.ModTimeBefore == "" || !strings.HasPrefix(first.ModTimeAfter, "2024-05-01T00:00:00") {
+		t.Errorf("first entry = %+v", first)
+	}
+	if n, last, err := processor.VerifyAuditLog(logPath); err != nil || n != 3 || last == "" {
+		t.Fatalf("VerifyAuditLog() = %d, %q, %v, want 3 intact entries", n, last, err)
+	}
+
+	// Editing an earlier line breaks the chain at the next one
+	data, _ := os.ReadFile(logPath)
+	os.WriteFile(logPath, []byte(strings.Replace(string(data), "2024-05-02T00:00:00", "2024-05-03T00:00:00", 1)), 0644)
+	if n, _, err := processor.VerifyAuditLog(logPath); err == nil || n != 2 || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("VerifyAuditLog() of an edited log = %d, %v, want a broken chain at line 3", n, err)
+	}
+}
+
+func TestAuditLog_AppendsAsFilesAreDone(t *testing.T) {
+	tmpDir := t.TempDir()
+	paths := []string{filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg"), filepath.Join(tmpDir, "IMG-20240502-WA0002.jpg")}
+	for _, path := range paths {
+		os.WriteFile(path, minimalJPEG(), 0644)
+	}
+	logPath := filepath.Join(tmpDir, "logs", "audit.jsonl")
+	auditLog, err := processor.OpenAuditLog(logPath)
+	if err != nil {
+		t.Fatalf("OpenAuditLog() error = %v", err)
+	}
+	defer auditLog.Close()
+
+	config := processor.Config{InputDir: tmpDir, OverrideOriginal: true, UpdateModified: true, AuditLog: logPath}
+	proc := processor.New(config, processor.WithAuditLog(auditLog))
+	var recorded []int
+	proc.OnFileDone = func(processor.ProcessResult) {
+		recorded = append(recorded, len(readAuditLog(t, logPath)))
+	}
+	proc.ProcessFiles(paths)
+	if len(recorded) != 2 || recorded[0] != 1 || recorded[1] != 2 {
+		t.Errorf("entries when each file was done = %v, want [1 2]", recorded)
+	}
+	if auditLog.Count() != 2 {
+		t.Errorf("Count() = %d, want 2", auditLog.Count())
+	}
+}
+
+func TestAuditLog_ConcurrentRuns(t *testing.T) {
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "audit.jsonl")
+	original := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Two runs appending to the same log at once keep one chain
+	var wg sync.WaitGroup
+	for run := 0; run < 2; run++ {
+		auditLog, err := processor.OpenAuditLog(logPath)
+		if err != nil {
+			t.Fatalf("OpenAuditLog() error = %v", err)
+		}
+		defer auditLog.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				r := processor.ProcessResult{
+					InputFile: fmt.Sprintf("run%d-%d.jpg", run, i), OutputFile: fmt.Sprintf("run%d-%d.jpg", run, i),
+					Success: true, PreHash: "a", PostHash: "b", OriginalModTime: original,
+				}
+				if _, err := auditLog.Append(r, time.Now()); err != nil {
+					t.Errorf("Append() error = %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _, err := processor.VerifyAuditLog(logPath); err != nil || n != 200 {
+		t.Errorf("VerifyAuditLog() = %d, %v, want 200 chained entries", n, err)
+	}
+}
+
+// readAuditLog parses every line of an audit log
+func readAuditLog(t *testing.T, path string) []processor.AuditEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []processor.AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e processor.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
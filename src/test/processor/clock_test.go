@@ -0,0 +1,56 @@
+package processor_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := processor.NewFakeClock(start)
+	if !clock.Now().Equal(start) {
+		t.Errorf("Now() = %s, want %s", clock.Now(), start)
+	}
+	clock.Advance(90 * time.Second)
+	if want := start.Add(90 * time.Second); !clock.Now().Equal(want) {
+		t.Errorf("Now() after Advance = %s, want %s", clock.Now(), want)
+	}
+}
+
+// runInMemory processes one JPEG in a fresh MemFS and returns its manifest
+func runInMemory(clock processor.Clock) processor.Manifest {
+	fsys := processor.NewMemFS(clock)
+	path := filepath.Join("media", "IMG-20240501-WA0001.jpg")
+	fsys.WriteFile(path, minimalJPEG(), 0644)
+
+	proc := processor.New(processor.Config{InputDir: "media", OutputDir: "out", ManifestPath: "manifest.json"},
+		processor.WithFS(fsys), processor.WithClock(clock))
+	results := proc.ProcessFiles([]string{path})
+	return processor.BuildManifestAt(results, proc.Now())
+}
+
+func TestClock_ReproducibleRuns(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	first := runInMemory(processor.NewFakeClock(now))
+	second := runInMemory(processor.NewFakeClock(now))
+
+	if first.Generated != "2030-01-01T12:00:00Z" {
+		t.Errorf("Generated = %s, want 2030-01-01T12:00:00Z", first.Generated)
+	}
+	if len(first.Entries) != 1 || !reflect.DeepEqual(first, second) {
+		t.Errorf("runs differ:\n%+v\n%+v", first, second)
+	}
+}
+
+func TestMemFS_UsesClock(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	fsys := processor.NewMemFS(processor.NewFakeClock(now))
+	fsys.WriteFile("a.txt", []byte("x"), 0644)
+	if info, _ := fsys.Stat("a.txt"); !info.ModTime().Equal(now) {
+		t.Errorf("ModTime = %s, want %s", info.ModTime(), now)
+	}
+}
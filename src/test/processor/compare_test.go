@@ -0,0 +1,116 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestMediaHash_IgnoresMetadata(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, data, 0644)
+		return path
+	}
+
+	// A JPEG with and without EXIF holds the same image
+	bare := write("bare.jpg", minimalJPEG())
+	dated := write("dated.jpg", jpegDatedAt(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+	a, errA := processor.MediaHash(bare)
+	b, errB := processor.MediaHash(dated)
+	if errA != nil || errB != nil || a == "" || a != b {
+		t.Errorf("JPEG media hashes = %q (%v), %q (%v), want equal", a, errA, b, errB)
+	}
+
+	// A video whose mvhd date was written holds the same media
+	video := write("VID-20240501-WA0001.mp4", simpleMP4())
+	before, _ := processor.MediaHash(video)
+	if err := processor.UpdateVideoMetadata(video, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("UpdateVideoMetadata() error = %v", err)
+	}
+	if after, err := processor.MediaHash(video); err != nil || after != before {
+		t.Errorf("MP4 media hash changed with its date: %q → %q (%v)", before, after, err)
+	}
+
+	// Metadata that grows a moov ahead of the mdat moves the chunk offsets,
+	// not the media
+	faststart := func(udta []byte) []byte {
+		ftyp := box("ftyp", []byte("isom"), make([]byte, 4), []byte("isom"))
+		mdat := box("mdat", []byte("frame data"))
+		moovFor := func(chunk uint32) []byte {
+			stco := box("stco", make([]byte, 4), []byte{0, 0, 0, 1}, []byte{byte(chunk >> 24), byte(chunk >> 16), byte(chunk >> 8), byte(chunk)})
+			trak := box("trak", box("mdia", box("minf", box("stbl", stco))))
+			return box("moov", box("mvhd", headerV0(100, time.Time{})), trak, udta)
+		}
+		chunk := uint32(len(ftyp) + len(moovFor(0)) + 8)
+		return append(append(append([]byte{}, ftyp...), moovFor(chunk)...), mdat...)
+	}
+	plain, errA := processor.MediaHash(write("plain.mp4", faststart(nil)))
+	tagged, errB := processor.MediaHash(write("tagged.mp4", faststart(box("udta", box("\xa9day", []byte("2024-05-01T12:00:00Z"))))))
+	if errA != nil || errB != nil || plain != tagged {
+		t.Errorf("faststart MP4 media hashes = %q (%v), %q (%v), want equal", plain, errA, tagged, errB)
+	}
+
+	if h, err := processor.MediaHash(write("note.txt", []byte("hello"))); h != "" || err != nil {
+		t.Errorf("MediaHash(text) = %q, %v, want no hash", h, err)
+	}
+}
+
+func TestCompareDirs(t *testing.T) {
+	dir := t.TempDir()
+	local, phone := filepath.Join(dir, "archive"), filepath.Join(dir, "phone")
+	dated := jpegDatedAt(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	edited := append(minimalJPEG()[:len(minimalJPEG())-2], 0x00, 0xFF, 0xD9)
+	files := map[string][]byte{
+		// Processed archive: dated copies sorted into folders
+		"archive/2024/05/IMG-20240501-WA0001.jpg":  dated,
+		"archive/IMG-20240501-WA0002_modified.jpg": minimalJPEG(),
+		"archive/IMG-20240501-WA0003.jpg":          edited,
+		"archive/IMG-20240501-WA0004.jpg":          minimalJPEG(),
+		// Phone copy: the originals
+		"phone/IMG-20240501-WA0001.jpg": minimalJPEG(),
+		"phone/IMG-20240501-WA0002.jpg": minimalJPEG(),
+		"phone/IMG-20240501-WA0003.jpg": minimalJPEG(),
+		"phone/IMG-20240501-WA0005.jpg": minimalJPEG(),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, data, 0644)
+	}
+
+	entries, err := processor.CompareDirs(local, phone, false)
+	if err != nil {
+		t.Fatalf("CompareDirs() error = %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		path := e.Left
+		if path == "" {
+			path = e.Right
+		}
+		got[filepath.Base(path)] = e.Kind
+	}
+	want := map[string]string{
+		"IMG-20240501-WA0001.jpg":          processor.CompareMetadata,
+		"IMG-20240501-WA0002_modified.jpg": processor.CompareIdentical,
+		"IMG-20240501-WA0003.jpg":          processor.CompareContent,
+		"IMG-20240501-WA0004.jpg":          processor.CompareOnlyLeft,
+		"IMG-20240501-WA0005.jpg":          processor.CompareOnlyRight,
+	}
+	if len(entries) != len(want) {
+		t.Errorf("CompareDirs() = %+v, want %d entries", entries, len(want))
+	}
+	for name, kind := range want {
+		if got[name] != kind {
+			t.Errorf("%s: kind = %q, want %q", name, got[name], kind)
+		}
+	}
+	if entries[0].Kind != processor.CompareContent {
+		t.Errorf("first entry = %+v, want the content difference first", entries[0])
+	}
+}
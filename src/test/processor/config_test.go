@@ -1,8 +1,10 @@
 package processor_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/apercova/wappd/internal/processor"
@@ -160,12 +162,12 @@ func TestMergeConfig(t *testing.T) {
 				DryRun:           false,
 			},
 			want: processor.Config{
-				UpdateModified:   true,  // From config file
-				OverwriteExif:    false, // From config file
-				OverrideOriginal: true,  // From config file
+				UpdateModified:   true,          // From config file
+				OverwriteExif:    false,         // From config file
+				OverrideOriginal: true,          // From config file
 				OutputDir:        "./processed", // From config file
-				Verbose:          true,  // From config file
-				DryRun:           false, // Always from CLI
+				Verbose:          true,          // From config file
+				DryRun:           false,         // Always from CLI
 			},
 		},
 		{
@@ -178,29 +180,29 @@ func TestMergeConfig(t *testing.T) {
 				Verbose:          boolPtr(false),
 			},
 			cliConfig: processor.Config{
-				UpdateModified:   true,  // CLI explicitly set
-				OverwriteExif:    true,  // CLI explicitly set
-				OverrideOriginal: true,  // CLI explicitly set
+				UpdateModified:   true,       // CLI explicitly set
+				OverwriteExif:    true,       // CLI explicitly set
+				OverrideOriginal: true,       // CLI explicitly set
 				OutputDir:        "./custom", // CLI explicitly set
-				Verbose:          true,  // CLI explicitly set
+				Verbose:          true,       // CLI explicitly set
 				DryRun:           true,
 			},
 			want: processor.Config{
-				UpdateModified:   true,  // CLI wins
-				OverwriteExif:    true,  // CLI wins
-				OverrideOriginal: true,  // CLI wins
+				UpdateModified:   true,       // CLI wins
+				OverwriteExif:    true,       // CLI wins
+				OverrideOriginal: true,       // CLI wins
 				OutputDir:        "./custom", // CLI wins
-				Verbose:          true,  // CLI wins
-				DryRun:           true, // Always from CLI
+				Verbose:          true,       // CLI wins
+				DryRun:           true,       // Always from CLI
 			},
 		},
 		{
 			name: "Mixed: some CLI, some config",
 			fileConfig: &processor.ConfigFile{
-				UpdateModified:   boolPtr(true),
-				OverwriteExif:    boolPtr(false),
-				OutputDir:        "./processed",
-				Verbose:          boolPtr(true),
+				UpdateModified: boolPtr(true),
+				OverwriteExif:  boolPtr(false),
+				OutputDir:      "./processed",
+				Verbose:        boolPtr(true),
 			},
 			cliConfig: processor.Config{
 				UpdateModified:   true,  // CLI explicitly set to true
@@ -211,11 +213,11 @@ func TestMergeConfig(t *testing.T) {
 				DryRun:           false,
 			},
 			want: processor.Config{
-				UpdateModified:   true,  // CLI explicitly set
-				OverwriteExif:    false, // From config file (CLI false = use config)
-				OverrideOriginal: false, // Default (config not set)
+				UpdateModified:   true,          // CLI explicitly set
+				OverwriteExif:    false,         // From config file (CLI false = use config)
+				OverrideOriginal: false,         // Default (config not set)
 				OutputDir:        "./processed", // From config file (CLI empty = use config)
-				Verbose:          true,  // From config file (CLI false = use config)
+				Verbose:          true,          // From config file (CLI false = use config)
 				DryRun:           false,
 			},
 		},
@@ -257,3 +259,366 @@ func TestConfigFileName(t *testing.T) {
 		t.Errorf("ConfigFileName() = %v, want wappd.json", name)
 	}
 }
+
+func TestLoadConfigFile_Targets(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "batch.json")
+
+	configContent := `{
+		"updateModified": true,
+		"targets": [
+			{"dir": "/mnt/a", "outputDir": "./out/a", "timezone": "Europe/Madrid"},
+			{"dir": "/mnt/b", "updateModified": false}
+		]
+	}`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	config, err := processor.LoadConfigFileFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfigFileFromPath() error = %v", err)
+	}
+	if len(config.Targets) != 2 {
+		t.Fatalf("LoadConfigFileFromPath() targets = %d, want 2", len(config.Targets))
+	}
+
+	a, b := config.Targets[0], config.Targets[1]
+	if a.Dir != "/mnt/a" || a.OutputDir != "./out/a" || a.Timezone != "Europe/Madrid" {
+		t.Errorf("target[0] = %+v", a)
+	}
+	if b.Dir != "/mnt/b" || b.UpdateModified == nil || *b.UpdateModified {
+		t.Errorf("target[1] = %+v", b)
+	}
+}
+
+func TestOverlayConfigFile(t *testing.T) {
+	base := &processor.ConfigFile{
+		UpdateModified: boolPtr(true),
+		OutputDir:      "./processed",
+		Timezone:       "UTC",
+		Targets:        []processor.Target{{Dir: "/mnt/a"}},
+	}
+	override := &processor.ConfigFile{
+		UpdateModified: boolPtr(false),
+		Timezone:       "Europe/Madrid",
+		Offset:         "+1h",
+	}
+
+	result := processor.OverlayConfigFile(base, override)
+	if result.UpdateModified == nil || *result.UpdateModified {
+		t.Error("OverlayConfigFile() should let an explicit false override true")
+	}
+	if result.OutputDir != "./processed" {
+		t.Errorf("OverlayConfigFile() outputDir = %q, want inherited ./processed", result.OutputDir)
+	}
+	if result.Timezone != "Europe/Madrid" || result.Offset != "+1h" {
+		t.Errorf("OverlayConfigFile() timezone/offset = %q/%q", result.Timezone, result.Offset)
+	}
+	if result.Targets != nil {
+		t.Error("OverlayConfigFile() should not inherit targets")
+	}
+	if base.Timezone != "UTC" {
+		t.Error("OverlayConfigFile() modified base")
+	}
+
+	if processor.OverlayConfigFile(nil, nil) != nil {
+		t.Error("OverlayConfigFile(nil, nil) should return nil")
+	}
+	if got := processor.OverlayConfigFile(nil, override); got == nil || got.Offset != "+1h" {
+		t.Errorf("OverlayConfigFile(nil, override) = %+v", got)
+	}
+}
+
+func TestTargetOutputDirs(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		dirs   []string
+		expect []string
+	}{
+		{
+			name:   "distinct names",
+			out:    "./restored",
+			dirs:   []string{"/mnt/phone1", "/mnt/phone2/"},
+			expect: []string{"./restored/phone1", "./restored/phone2"},
+		},
+		{
+			name:   "name clash",
+			out:    "./restored/",
+			dirs:   []string{"/mnt/a/WhatsApp", "/mnt/b/WhatsApp", "/mnt/c/WhatsApp"},
+			expect: []string{"./restored/WhatsApp", "./restored/WhatsApp-2", "./restored/WhatsApp-3"},
+		},
+		{
+			name:   "cloud URI",
+			out:    "s3://bucket/backup",
+			dirs:   []string{"./media", "."},
+			expect: []string{"s3://bucket/backup/media", "s3://bucket/backup/target"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := processor.TargetOutputDirs(tt.out, tt.dirs)
+			for i := range tt.expect {
+				if got[i] != tt.expect[i] {
+					t.Errorf("TargetOutputDirs()[%d] = %q, want %q", i, got[i], tt.expect[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_Comments(t *testing.T) {
+	tmpDir := t.TempDir()
+	configContent := `{
+		// Upload processed files
+		"outputDir": "s3://bucket//whatsapp", // not a comment inside the string
+		"tags": ["family\"//"]
+	}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "wappd.json"), []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	config, err := processor.LoadConfigFile(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+	if config.OutputDir != "s3://bucket//whatsapp" {
+		t.Errorf("LoadConfigFile() outputDir = %q", config.OutputDir)
+	}
+	if len(config.Tags) != 1 || config.Tags[0] != `family"//` {
+		t.Errorf("LoadConfigFile() tags = %q", config.Tags)
+	}
+}
+
+func TestFormatCommentedConfig(t *testing.T) {
+	original := &processor.ConfigFile{
+		UpdateModified: boolPtr(true),
+		OverwriteExif:  boolPtr(false),
+		OutputDir:      "./processed",
+	}
+
+	data := processor.FormatCommentedConfig(original)
+	if !strings.Contains(string(data), "// Directory processed copies are written to") {
+		t.Errorf("FormatCommentedConfig() missing option comment:\n%s", data)
+	}
+	if strings.Contains(string(data), "timezone") {
+		t.Errorf("FormatCommentedConfig() should omit unset options:\n%s", data)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "wappd.json")
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	loaded, err := processor.LoadConfigFileFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfigFileFromPath() error = %v\n%s", err, data)
+	}
+	if loaded.UpdateModified == nil || !*loaded.UpdateModified {
+		t.Error("round trip lost updateModified")
+	}
+	if loaded.OverwriteExif == nil || *loaded.OverwriteExif {
+		t.Error("round trip lost overwriteExif")
+	}
+	if loaded.OutputDir != "./processed" {
+		t.Errorf("round trip outputDir = %q", loaded.OutputDir)
+	}
+
+	empty := processor.FormatCommentedConfig(&processor.ConfigFile{})
+	if err := os.WriteFile(configPath, empty, 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if _, err := processor.LoadConfigFileFromPath(configPath); err != nil {
+		t.Errorf("empty commented config should load: %v\n%s", err, empty)
+	}
+}
+
+func TestLoadConfigFile_SchemaErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "unknown key with suggestion",
+			content: `{"verbos": true}`,
+			want:    []string{`unknown key "verbos" (did you mean "verbose"?)`},
+		},
+		{
+			name:    "wrong case suggestion",
+			content: `{"allowFFmpeg": true}`,
+			want:    []string{`unknown key "allowFFmpeg" (did you mean "allowFfmpeg"?)`},
+		},
+		{
+			name:    "unknown key without suggestion",
+			content: `{"colour": "red"}`,
+			want:    []string{`unknown key "colour"`},
+		},
+		{
+			name:    "wrong types",
+			content: `{"verbose": "yes", "tags": "family", "outputDir": 3}`,
+			want: []string{
+				`"outputDir": expected a string, got a number`,
+				`"tags": expected an array of strings, got a string`,
+				`"verbose": expected true or false, got a string`,
+			},
+		},
+		{
+			name:    "target keys",
+			content: `{"targets": [{"dir": "/mnt/a", "medai": "images"}, "b"]}`,
+			want: []string{
+				`unknown key "targets[0].medai" (did you mean "media"?)`,
+				`"targets[1]": expected an object, got a string`,
+			},
+		},
+		{
+			name:    "invalid values",
+			content: `{"backend": "magic", "timezone": "Mars/Olympus", "offset": "soon", "maxFailures": "200%", "outputDir": "s3://"}`,
+			want: []string{
+				`"backend": unknown backend "magic"`,
+				`"timezone": invalid timezone "Mars/Olympus"`,
+				`"offset":`,
+				`"maxFailures":`,
+				`"outputDir": cloud URI has no bucket`,
+			},
+		},
+		{
+			name:    "invalid target values",
+			content: `{"targets": [{"media": "pictures", "stickers": "eat"}]}`,
+			want: []string{
+				`"targets[0].dir": every target needs a directory`,
+				`"targets[0].media": invalid media kind "pictures"`,
+				`"targets[0].stickers": unknown sticker mode "eat"`,
+			},
+		},
+		{
+			name:    "file in the way of the manifest directory",
+			content: `{"manifest": "/dev/null/reports/manifest.json"}`,
+			want:    []string{`"manifest": /dev/null is not a directory`},
+		},
+		{
+			name:    "missing track",
+			content: `{"gpx": "/nonexistent/walk.gpx"}`,
+			want:    []string{`"gpx": failed to open track`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "wappd.json")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+			_, err := processor.LoadConfigFileFromPath(configPath)
+			if err == nil {
+				t.Fatal("LoadConfigFileFromPath() should reject the config")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadConfigFileFromPath() error = %v, want it to mention %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_ReportDirsCreatedOnWrite(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "wappd.json")
+	reports := filepath.ToSlash(filepath.Join(dir, "reports", "2025"))
+	content := fmt.Sprintf(`{"manifest": %q, "auditLog": %q}`, reports+"/manifest.json", reports+"/audit.jsonl")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	if _, err := processor.LoadConfigFileFromPath(configPath); err != nil {
+		t.Errorf("LoadConfigFileFromPath() error = %v, want the missing directories accepted", err)
+	}
+}
+
+func TestLoadConfigFile_SchemaAcceptsNull(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "wappd.json")
+	content := `{"verbose": null, "outputDir": "./out", "targets": [{"dir": "/mnt/a", "media": "videos"}]}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	config, err := processor.LoadConfigFileFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfigFileFromPath() error = %v", err)
+	}
+	if config.Verbose != nil || len(config.Targets) != 1 {
+		t.Errorf("LoadConfigFileFromPath() = %+v", config)
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "wappd.json")
+	content := `{
+		"outputDir": "./processed",
+		"verbose": true,
+		"targets": [{"dir": "/mnt/a"}],
+		"profiles": {
+			"quick": {"verbose": false},
+			"archive": {"outputDir": "./archive", "updateModified": true, "tags": ["archive"]}
+		}
+	}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	config, err := processor.LoadConfigFileFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfigFileFromPath() error = %v", err)
+	}
+
+	archive, err := processor.ApplyProfile(config, "archive")
+	if err != nil {
+		t.Fatalf("ApplyProfile() error = %v", err)
+	}
+	if archive.OutputDir != "./archive" || archive.UpdateModified == nil || !*archive.UpdateModified {
+		t.Errorf("ApplyProfile() = %+v, want the profile's options", archive)
+	}
+	if archive.Verbose == nil || !*archive.Verbose {
+		t.Error("ApplyProfile() should keep options the profile doesn't set")
+	}
+	if len(archive.Targets) != 1 || archive.Profiles != nil {
+		t.Errorf("ApplyProfile() targets = %v, profiles = %v", archive.Targets, archive.Profiles)
+	}
+
+	quick, err := processor.ApplyProfile(config, "quick")
+	if err != nil {
+		t.Fatalf("ApplyProfile() error = %v", err)
+	}
+	if quick.Verbose == nil || *quick.Verbose {
+		t.Error("ApplyProfile() should let a profile turn an option off")
+	}
+
+	_, err = processor.ApplyProfile(config, "videos-only")
+	if err == nil || !strings.Contains(err.Error(), "available: archive, quick") {
+		t.Errorf("ApplyProfile() error = %v, want the available profiles listed", err)
+	}
+}
+
+func TestLoadConfigFile_ProfileSchemaErrors(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "wappd.json")
+	content := `{"profiles": {
+		"quick": {"verbos": true, "targets": []},
+		"odd": "yes",
+		"tz": {"timezone": "Mars/Olympus"}
+	}}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	_, err := processor.LoadConfigFileFromPath(configPath)
+	if err == nil {
+		t.Fatal("LoadConfigFileFromPath() should reject the profiles")
+	}
+	for _, want := range []string{
+		`unknown key "profiles.quick.verbos" (did you mean "verbose"?)`,
+		`"profiles.quick.targets": targets can only be listed at the top level`,
+		`"profiles.odd": expected an object, got a string`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadConfigFileFromPath() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package processor_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestConsole_Mark(t *testing.T) {
+	if got := (processor.Console{}).Mark("✓"); got != "✓" {
+		t.Errorf("Mark() without color = %q, want plain", got)
+	}
+	if got := (processor.Console{Color: true}).Mark("✗"); got != "\x1b[31m✗\x1b[0m" {
+		t.Errorf("Mark() with color = %q, want red", got)
+	}
+}
+
+func TestConsole_LineFitsWidth(t *testing.T) {
+	in := "./media/WhatsApp/Media/WhatsApp Images/Private/IMG-20240501-WA0001.jpg"
+	out := "./out/WhatsApp/Media/WhatsApp Images/Private/IMG-20240501-WA0001.jpg"
+	c := processor.Console{Width: 80}
+
+	line := c.Line("✓", "%s → %s [%s]", []string{in, out}, "native")
+	if n := utf8.RuneCountInString(line); n > 80 {
+		t.Errorf("line is %d columns, want at most 80: %q", n, line)
+	}
+	if !strings.HasSuffix(line, "IMG-20240501-WA0001.jpg [native]") || strings.Count(line, "…") != 2 {
+		t.Errorf("line = %q, want both paths shortened in the middle", line)
+	}
+
+	wide := processor.Console{Width: 200}.Line("✓", "%s → %s [%s]", []string{in, out}, "native")
+	if want := "  ✓ " + in + " → " + out + " [native]"; wide != want {
+		t.Errorf("line = %q, want %q", wide, want)
+	}
+}
+
+func TestConsole_LineKeepsShortPaths(t *testing.T) {
+	line := processor.Console{Width: 10}.Line("✗", "%s: %v", []string{"IMG-20240501-WA0001.jpg"}, "no date")
+	if line != "  ✗ IMG-20240501-WA0001.jpg: no date" {
+		t.Errorf("line = %q, want the path kept whole", line)
+	}
+}
+
+func TestNewConsole_NotATerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Setenv("COLUMNS", "")
+	if c := processor.NewConsole(f, false); c.Color || c.Width != 0 {
+		t.Errorf("NewConsole(file) = %+v, want no color and no width", c)
+	}
+	// COLUMNS is the terminal's width; a redirected run keeps full paths
+	t.Setenv("COLUMNS", "100")
+	if c := processor.NewConsole(f, false); c.Width != 0 {
+		t.Errorf("NewConsole(file) width = %d with COLUMNS set, want no width", c.Width)
+	}
+}
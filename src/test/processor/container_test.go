@@ -0,0 +1,100 @@
+package processor_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// simpleMP4 builds ftyp + moov(mvhd)
+func simpleMP4() []byte {
+	return append(box("ftyp", []byte("isom"), make([]byte, 4)), box("moov", box("mvhd", mvhdV0()))...)
+}
+
+func TestSniffContainer(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"JPEG", minimalJPEG(), processor.ContainerJPEG},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00"), processor.ContainerPNG},
+		{"GIF", []byte("GIF89a\x01\x00"), processor.ContainerGIF},
+		{"WebP", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), processor.ContainerWebP},
+		{"MP4", simpleMP4()[:12], processor.ContainerMP4},
+		{"HEIC", []byte("\x00\x00\x00\x18ftypheic"), ""},
+		{"Unknown", []byte("test content"), ""},
+	}
+	for _, tt := range tests {
+		if got := processor.SniffContainer(tt.header); got != tt.want {
+			t.Errorf("SniffContainer(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectContainerMismatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	gif := filepath.Join(tmpDir, "IMG-20240501-WA0009.gif")
+	os.WriteFile(gif, simpleMP4(), 0644)
+	mov := filepath.Join(tmpDir, "VID-20240501-WA0009.mov")
+	os.WriteFile(mov, simpleMP4(), 0644)
+
+	if got := processor.DetectContainerMismatch(gif); got != ".mp4" {
+		t.Errorf("DetectContainerMismatch(MP4 as .gif) = %q, want .mp4", got)
+	}
+	if got := processor.DetectContainerMismatch(mov); got != "" {
+		t.Errorf("DetectContainerMismatch(MP4 as .mov) = %q, want \"\"", got)
+	}
+
+	// Recovered files often have no extension at all
+	bare := filepath.Join(tmpDir, "IMG-20240501-WA0010")
+	os.WriteFile(bare, minimalJPEG(), 0644)
+	if got := processor.DetectContainerMismatch(bare); got != ".jpg" {
+		t.Errorf("DetectContainerMismatch(JPEG without extension) = %q, want .jpg", got)
+	}
+	opus := filepath.Join(tmpDir, "PTT-20240501-WA0010.opus")
+	os.WriteFile(opus, simpleMP4(), 0644)
+	if got := processor.DetectContainerMismatch(opus); got != "" {
+		t.Errorf("DetectContainerMismatch(MP4 as .opus) = %q, want \"\" (known extensions are trusted)", got)
+	}
+}
+
+func TestProcessFile_GIFAsMP4(t *testing.T) {
+	dt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("processed as video", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		path := filepath.Join(tmpDir, "IMG-20240501-WA0009.gif")
+		os.WriteFile(path, simpleMP4(), 0644)
+
+		r := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true}).ProcessFile(path)
+		if !r.Success || r.ActualExt != ".mp4" || r.OutputFile != path {
+			t.Fatalf("ProcessFile() = success %v, actual %q, output %q, error %v", r.Success, r.ActualExt, r.OutputFile, r.Error)
+		}
+		data, _ := os.ReadFile(path)
+		atoms, _ := processor.ParseMP4Atoms(data)
+		mvhd := processor.FindAtomRecursive(*processor.FindAtom(atoms, "moov"), "mvhd")
+		if got := processor.QuickTimeToUnix(binary.BigEndian.Uint32(mvhd.Data[4:8])); got != dt.Unix() {
+			t.Errorf("mvhd creation time = %d, want %d", got, dt.Unix())
+		}
+	})
+
+	t.Run("renamed", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		path := filepath.Join(tmpDir, "IMG-20240501-WA0009.gif")
+		os.WriteFile(path, simpleMP4(), 0644)
+
+		r := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, FixExtensions: true}).ProcessFile(path)
+		want := filepath.Join(tmpDir, "IMG-20240501-WA0009.mp4")
+		if !r.Success || r.OutputFile != want {
+			t.Fatalf("ProcessFile() = success %v, output %q, error %v; want output %q", r.Success, r.OutputFile, r.Error, want)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("original .gif should be replaced by the renamed file")
+		}
+	})
+}
@@ -0,0 +1,40 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// allocated returns the bytes allocated on disk for path
+func allocated(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	return int64(info.Sys().(*syscall.Stat_t).Blocks) * 512
+}
+
+func TestProcessFiles_CopyKeepsHoles(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "VID-20240501-WA0001.mp4")
+	size := writeLargeVideo(t, input)
+	if allocated(t, input) >= size {
+		t.Skip("filesystem doesn't support sparse files")
+	}
+
+	config := processor.Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out")}
+	r := processor.New(config).ProcessFiles([]string{input})[0]
+	if r.Error != nil {
+		t.Fatalf("ProcessFiles() error = %v", r.Error)
+	}
+	if got := allocated(t, r.OutputFile); got >= size/2 {
+		t.Errorf("copy allocates %d of %d bytes, want the mdat hole kept", got, size)
+	}
+}
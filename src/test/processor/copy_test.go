@@ -0,0 +1,54 @@
+package processor_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFiles_CopyProgress(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "VID-20240501-WA0001.mp4")
+	size := writeLargeVideo(t, input)
+
+	var calls int
+	var copied, total int64
+	config := processor.Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out")}
+	proc := processor.New(config)
+	proc.OnCopyProgress = func(filePath string, n, size int64) {
+		if filePath != input {
+			t.Errorf("OnCopyProgress path = %s, want %s", filePath, input)
+		}
+		if n < copied {
+			t.Errorf("OnCopyProgress went backwards: %d after %d", n, copied)
+		}
+		calls++
+		copied, total = n, size
+	}
+	r := proc.ProcessFiles([]string{input})[0]
+	if r.Error != nil {
+		t.Fatalf("ProcessFiles() error = %v", r.Error)
+	}
+	if calls < 2 || copied != size || total != size {
+		t.Errorf("OnCopyProgress called %d times, last %d/%d, want several calls ending at %d/%d", calls, copied, total, size, size)
+	}
+
+	// Only the header dates differ from the original
+	got, _ := os.ReadFile(r.OutputFile)
+	want, _ := os.ReadFile(input)
+	if len(got) != len(want) || !bytes.Equal(got[:len(largeVideoHeader)+64<<20], want[:len(largeVideoHeader)+64<<20]) {
+		t.Error("copy differs from the original outside the moov atom")
+	}
+
+	// Editing originals in place copies nothing
+	calls = 0
+	proc = processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true})
+	proc.OnCopyProgress = func(string, int64, int64) { calls++ }
+	proc.ProcessFiles([]string{input})
+	if calls != 0 {
+		t.Errorf("OnCopyProgress called %d times for an in-place edit", calls)
+	}
+}
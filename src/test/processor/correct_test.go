@@ -0,0 +1,87 @@
+package processor_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// jpegDatedAt returns minimalJPEG with an EXIF DateTimeOriginal of dt
+func jpegDatedAt(dt time.Time) []byte {
+	exif, _ := processor.CreateEXIFSegment(dt)
+	data, _ := processor.InsertEXIFSegment(minimalJPEG(), exif)
+	return data
+}
+
+func TestProcessFile_SkipCorrect(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		data   []byte
+		config processor.Config
+		want   bool
+	}{
+		{"same date", jpegDatedAt(day), processor.Config{SkipCorrect: "1s"}, true},
+		{"within tolerance", jpegDatedAt(day.Add(3 * time.Second)), processor.Config{SkipCorrect: "5s"}, true},
+		{"beyond tolerance", jpegDatedAt(day.Add(3 * time.Second)), processor.Config{SkipCorrect: "1s"}, false},
+		{"other day", jpegDatedAt(day.AddDate(0, 0, 1)), processor.Config{SkipCorrect: "1h"}, false},
+		{"no EXIF", minimalJPEG(), processor.Config{SkipCorrect: "1s"}, false},
+		{"keywords to add", jpegDatedAt(day), processor.Config{SkipCorrect: "1s", Tags: []string{"WhatsApp"}}, false},
+		{"off", jpegDatedAt(day), processor.Config{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := processor.NewMemFS()
+			fsys.WriteFile("media/IMG-20240501-WA0001.jpg", tt.data, 0644)
+			mtime := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+			fsys.Chtimes("media/IMG-20240501-WA0001.jpg", mtime, mtime)
+
+			config := tt.config
+			config.InputDir = "media"
+			config.OverrideOriginal = true
+			config.OverwriteExif = true
+			r := processor.New(config, processor.WithFS(fsys)).ProcessFile("media/IMG-20240501-WA0001.jpg")
+			if !r.Success {
+				t.Fatalf("ProcessFile() error = %v", r.Error)
+			}
+			if r.AlreadyCorrect != tt.want {
+				t.Errorf("AlreadyCorrect = %v, want %v", r.AlreadyCorrect, tt.want)
+			}
+			data, _ := fsys.ReadFile("media/IMG-20240501-WA0001.jpg")
+			info, _ := fsys.Stat("media/IMG-20240501-WA0001.jpg")
+			if untouched := bytes.Equal(data, tt.data) && info.ModTime().Equal(mtime); tt.want && !untouched {
+				t.Error("file already correct was rewritten")
+			}
+		})
+	}
+
+	if _, err := processor.ParseCorrectTolerance("-1s"); err == nil {
+		t.Error("ParseCorrectTolerance(\"-1s\") should fail")
+	}
+}
+
+func TestProcessFile_SkipCorrectVideoOnDisk(t *testing.T) {
+	// moov after a large mdat: only the moov is read to compare dates
+	mdat := box("mdat", make([]byte, 1<<20))
+	video := append(append(box("ftyp", []byte("isom")), mdat...), movieWithTracks()...)
+	path := filepath.Join(t.TempDir(), "VID-20240501-WA0002.mp4")
+	os.WriteFile(path, video, 0644)
+
+	config := processor.Config{InputDir: filepath.Dir(path), OverrideOriginal: true, SkipCorrect: "1s"}
+	if r := processor.New(config).ProcessFile(path); !r.Success || r.AlreadyCorrect {
+		t.Fatalf("first run = %+v, want the dates written", r)
+	}
+	dated, _ := os.ReadFile(path)
+
+	r := processor.New(config).ProcessFile(path)
+	if !r.Success || !r.AlreadyCorrect {
+		t.Fatalf("second run = %+v, want the video already correct", r)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, dated) {
+		t.Error("second run rewrote the video")
+	}
+}
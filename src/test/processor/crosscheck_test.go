@@ -0,0 +1,107 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestCrossCheckEntries(t *testing.T) {
+	out := []byte(`[
+  {"SourceFile": "a.jpg", "EXIF:DateTimeOriginal": "2024:05:01 10:00:00"},
+  {"SourceFile": "b.mp4", "QuickTime:CreateDate": "2024:05:01 08:00:00"},
+  {"SourceFile": "c.opus", "Vorbis:Date": "2024-05-01T10:00:00"},
+  {"SourceFile": "d.jpg", "EXIF:DateTimeOriginal": "2024:04:30 10:00:00"},
+  {"SourceFile": "e.jpg"},
+  {"SourceFile": "f.docx"},
+  {"SourceFile": "g.png", "EXIF:DateTimeOriginal": "2024:05:01 10:00:00"},
+  {"SourceFile": "h.png"},
+  {"SourceFile": "i.png", "EXIF:DateTimeOriginal": "2024:04:30 10:00:00"}
+]`)
+	read, err := processor.ParseExiftoolDates(out)
+	if err != nil {
+		t.Fatalf("ParseExiftoolDates() error = %v", err)
+	}
+
+	// Written at 10:00 in UTC+2: videos store the time in UTC
+	entry := func(file string) processor.ManifestEntry {
+		return processor.ManifestEntry{OutputFile: file, DateWritten: "2024-05-01T10:00:00", DateUTC: "2024-05-01T08:00:00Z"}
+	}
+	tests := []struct {
+		file string
+		want processor.VerifyStatus
+	}{
+		{"a.jpg", processor.VerifyOK},
+		{"b.mp4", processor.VerifyOK},
+		{"c.opus", processor.VerifyOK},
+		{"d.jpg", processor.VerifyMismatch},
+		{"e.jpg", processor.VerifyMismatch},
+		{"f.docx", processor.VerifyUnchecked},
+		{"g.png", processor.VerifyOK},
+		{"h.png", processor.VerifyUnchecked}, // Only exiftool writes PNG dates
+		{"i.png", processor.VerifyMismatch},
+		{"missing.jpg", processor.VerifyMissing},
+	}
+	var entries []processor.ManifestEntry
+	for _, tt := range tests {
+		entries = append(entries, entry(tt.file))
+	}
+	results := processor.CrossCheckEntries(entries, read)
+	for i, tt := range tests {
+		if results[i].Status != tt.want {
+			t.Errorf("%s: status = %s (read %q, expected %q), want %s", tt.file, results[i].Status, results[i].Read, results[i].Expected, tt.want)
+		}
+	}
+
+	if err := processor.ValidateReference("mediainfo"); err == nil {
+		t.Error("ValidateReference(mediainfo) should fail")
+	}
+}
+
+func TestCrossCheckManifest_Exiftool(t *testing.T) {
+	if !processor.ExiftoolAvailable() {
+		t.Skip("exiftool not installed")
+	}
+	path := filepath.Join(t.TempDir(), "IMG-20240501-WA0001.jpg")
+	os.WriteFile(path, minimalJPEG(), 0644)
+	r := processor.New(processor.Config{InputDir: filepath.Dir(path), OverrideOriginal: true, ManifestPath: "manifest.json"}).ProcessFile(path)
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+	manifest := processor.BuildManifestAt([]processor.ProcessResult{r}, time.Now())
+	results, err := processor.CrossCheckManifest(&manifest)
+	if err != nil {
+		t.Fatalf("CrossCheckManifest() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Status != processor.VerifyOK {
+		t.Errorf("CrossCheckManifest() = %+v, want exiftool to agree", results)
+	}
+}
+
+func TestCrossCheckEntries_KeptDate(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	exif, _ := processor.CreateEXIFSegment(time.Date(2020, 1, 1, 9, 30, 0, 0, time.UTC))
+	tagged, _ := processor.InsertEXIFSegment(minimalJPEG(), exif)
+	os.WriteFile(input, tagged, 0644)
+
+	// Without -ow the EXIF date already there is kept
+	config := processor.Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out"), ManifestPath: filepath.Join(tmpDir, "manifest.json")}
+	results := processor.New(config).ProcessFiles([]string{input})
+	if results[0].Kept == nil {
+		t.Fatalf("ProcessFiles() = %+v, want the EXIF date kept", results[0])
+	}
+	manifest := processor.BuildManifest(results)
+	entry := manifest.Entries[0]
+	if !entry.Kept || entry.DateWritten != "2020-01-01T09:30:00" {
+		t.Fatalf("manifest entry = %+v, want the kept date", entry)
+	}
+
+	read := map[string]map[string]string{entry.OutputFile: {"EXIF:DateTimeOriginal": "2020:01:01 09:30:00"}}
+	if got := processor.CrossCheckEntries(manifest.Entries, read); got[0].Status != processor.VerifyOK {
+		t.Errorf("CrossCheckEntries() = %s (expected %q), want ok against the kept date", got[0].Status, got[0].Expected)
+	}
+}
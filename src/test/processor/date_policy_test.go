@@ -0,0 +1,59 @@
+package processor_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFile_DatePolicy(t *testing.T) {
+	named := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	earlier := named.AddDate(0, 0, -10)
+	later := named.AddDate(0, 0, 10)
+	unreadable := bytes.ReplaceAll(jpegDatedAt(earlier), []byte(earlier.Format("2006:01:02")), []byte("0000:00:00"))
+	tests := []struct {
+		name     string
+		data     []byte
+		policy   string
+		want     time.Time
+		kept     bool
+		rewrites bool
+	}{
+		{"earliest keeps an earlier embedded date", jpegDatedAt(earlier), processor.DatePolicyEarliest, earlier, true, false},
+		{"earliest replaces a later embedded date", jpegDatedAt(later), processor.DatePolicyEarliest, named, false, true},
+		{"filename replaces any embedded date", jpegDatedAt(earlier), processor.DatePolicyFilename, named, false, true},
+		{"existing keeps a later embedded date", jpegDatedAt(later), processor.DatePolicyExisting, later, true, false},
+		{"existing without an embedded date", minimalJPEG(), processor.DatePolicyExisting, named, false, true},
+		{"earliest leaves EXIF without a readable date", unreadable, processor.DatePolicyEarliest, named, false, false},
+		{"filename leaves EXIF without a readable date", unreadable, processor.DatePolicyFilename, named, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := processor.NewMemFS()
+			fsys.WriteFile("media/IMG-20240501-WA0001.jpg", tt.data, 0644)
+
+			config := processor.Config{InputDir: "media", OverrideOriginal: true, UpdateModified: true, DatePolicy: tt.policy}
+			r := processor.New(config, processor.WithFS(fsys)).ProcessFile("media/IMG-20240501-WA0001.jpg")
+			if !r.Success {
+				t.Fatalf("ProcessFile() error = %v", r.Error)
+			}
+			if !r.DateTime.Equal(tt.want) || r.KeptEmbedded != tt.kept {
+				t.Errorf("DateTime = %v, KeptEmbedded = %v, want %v, %v", r.DateTime, r.KeptEmbedded, tt.want, tt.kept)
+			}
+			data, _ := fsys.ReadFile("media/IMG-20240501-WA0001.jpg")
+			if rewritten := !bytes.Equal(data, tt.data); rewritten != tt.rewrites {
+				t.Errorf("metadata rewritten = %v, want %v", rewritten, tt.rewrites)
+			}
+			if info, _ := fsys.Stat("media/IMG-20240501-WA0001.jpg"); !info.ModTime().Equal(tt.want) {
+				t.Errorf("modification time = %v, want %v", info.ModTime(), tt.want)
+			}
+		})
+	}
+
+	r := processor.New(processor.Config{DatePolicy: "latest"}).ProcessFile("IMG-20240501-WA0001.jpg")
+	if r.Success || r.Error == nil {
+		t.Error("unknown date policy accepted")
+	}
+}
@@ -0,0 +1,77 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestListDates(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.MkdirAll("media", 0755)
+	fsys.WriteFile("media/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	// The second JPEG has an EXIF date a day off its name
+	exif, _ := processor.CreateEXIFSegment(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	tagged, _ := processor.InsertEXIFSegment(minimalJPEG(), exif)
+	fsys.WriteFile("media/IMG-20240502-WA0002.jpg", tagged, 0644)
+	fsys.WriteFile("media/VID-20240503-WA0003.mp4", simpleMP4(), 0644)
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	for _, name := range []string{"IMG-20240501-WA0001.jpg", "IMG-20240502-WA0002.jpg", "VID-20240503-WA0003.mp4"} {
+		fsys.Chtimes("media/"+name, mtime, mtime)
+	}
+
+	proc := processor.New(processor.Config{InputDir: "media"}, processor.WithFS(fsys))
+	tests := []struct {
+		file     string
+		filename string
+		embedded string
+		source   string
+		mismatch bool
+	}{
+		{"media/IMG-20240501-WA0001.jpg", "2024-05-01", "", "", false},
+		{"media/IMG-20240502-WA0002.jpg", "2024-05-02", "2024:05:01 00:00:00", "EXIF DateTimeOriginal", true},
+		{"media/VID-20240503-WA0003.mp4", "2024-05-03", "", "", true},
+	}
+	for _, tt := range tests {
+		l, err := proc.ListDates(tt.file)
+		if err != nil {
+			t.Fatalf("ListDates(%s) error = %v", tt.file, err)
+		}
+		if l.FilenameDate != tt.filename || l.EmbeddedDate != tt.embedded || l.EmbeddedSource != tt.source ||
+			l.Mismatch != tt.mismatch || !l.ModTime.Equal(mtime) {
+			t.Errorf("ListDates(%s) = %+v", tt.file, l)
+		}
+	}
+
+	if _, err := proc.ListDates("media/missing.jpg"); err == nil {
+		t.Error("ListDates() should fail for missing files")
+	}
+}
+
+func TestListDates_OnDisk(t *testing.T) {
+	dir := t.TempDir()
+	exif, _ := processor.CreateEXIFSegment(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	tagged, _ := processor.InsertEXIFSegment(minimalJPEG(), exif)
+	jpeg := filepath.Join(dir, "IMG-20240501-WA0001.jpg")
+	os.WriteFile(jpeg, tagged, 0644)
+	broken := filepath.Join(dir, "VID-20240501-WA0002.mp4")
+	os.WriteFile(broken, box("ftyp", []byte("isom")), 0644)
+
+	proc := processor.New(processor.Config{InputDir: dir})
+	l, err := proc.ListDates(jpeg)
+	if err != nil || l.EmbeddedDate != "2024:05:01 00:00:00" {
+		t.Errorf("ListDates(jpeg) = %+v, %v", l, err)
+	}
+
+	// A file whose dates can't be read is listed with the reason
+	l, err = proc.ListDates(broken)
+	if err != nil {
+		t.Fatalf("ListDates(broken) error = %v", err)
+	}
+	if l.Error == "" || l.FilenameDate != "2024-05-01" {
+		t.Errorf("ListDates(broken) = %+v, want the filename date and an error", l)
+	}
+}
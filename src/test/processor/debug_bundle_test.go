@@ -0,0 +1,133 @@
+package processor_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// readBundle returns the files of a debug bundle zip by name
+func readBundle(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("bundle is not a zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestDebugBundle_WriteZip(t *testing.T) {
+	// Image data no bundle may contain
+	pixels := []byte("PRIVATE-PIXELS")
+	jpeg := append(jpegDatedAt(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)), pixels...)
+	inspection, err := processor.InspectData(jpeg)
+	if err != nil {
+		t.Fatalf("InspectData() error = %v", err)
+	}
+	verbose := true
+	bundle := processor.DebugBundle{
+		Created:     time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Environment: "Environment:\n  wappd: dev\n",
+		Diagnosis:   "File: IMG-20240501-WA0001.jpg\n",
+		Inspection:  inspection,
+		Config:      &processor.ConfigFile{Verbose: &verbose},
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.WriteZip(&buf); err != nil {
+		t.Fatalf("WriteZip() error = %v", err)
+	}
+	files := readBundle(t, buf.Bytes())
+	for _, name := range []string{"README.txt", "environment.txt", "diagnosis.txt", "structure.json", "config.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle lacks %s", name)
+		}
+	}
+	if !strings.Contains(files["structure.json"], `"DateTimeOriginal": "2024:05:01 12:00:00"`) {
+		t.Errorf("structure.json = %s, want the decoded EXIF date", files["structure.json"])
+	}
+	if !strings.Contains(files["config.json"], `"verbose": true`) {
+		t.Errorf("config.json = %s, want the config", files["config.json"])
+	}
+	for name, content := range files {
+		if strings.Contains(content, string(pixels)) {
+			t.Errorf("%s contains the file's image data", name)
+		}
+	}
+}
+
+func TestDebugBundle_RedactsLocation(t *testing.T) {
+	config := &processor.ConfigFile{
+		GPS:      "40.416800,-3.703800",
+		GPX:      "/home/ana/tracks/2024-05-01.gpx",
+		Profiles: map[string]processor.ConfigFile{"trip": {GPS: "48.856600,2.352200"}},
+		Targets:  []processor.Target{{Dir: "./media", ConfigFile: processor.ConfigFile{GPX: "/home/ana/tracks/paris.kml"}}},
+	}
+	var buf bytes.Buffer
+	if err := (processor.DebugBundle{Config: config}).WriteZip(&buf); err != nil {
+		t.Fatalf("WriteZip() error = %v", err)
+	}
+	got := readBundle(t, buf.Bytes())["config.json"]
+	for _, private := range []string{"40.416800", "2024-05-01.gpx", "48.856600", "paris.kml"} {
+		if strings.Contains(got, private) {
+			t.Errorf("config.json contains %q:\n%s", private, got)
+		}
+	}
+	if !strings.Contains(got, `"gps": "[redacted]"`) || !strings.Contains(got, `"dir": "./media"`) {
+		t.Errorf("config.json = %s, want gps redacted and the rest kept", got)
+	}
+	if config.GPS != "40.416800,-3.703800" || config.Profiles["trip"].GPS != "48.856600,2.352200" {
+		t.Error("WriteZip() changed the config it was given")
+	}
+}
+
+func TestDiagnosis_RedactLocation(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	proc := processor.New(processor.Config{InputDir: ".", OutputDir: "out", GPS: "40.4168,-3.7038"}, processor.WithFS(fsys))
+	d, err := proc.Diagnose("IMG-20240501-WA0001.jpg")
+	if err != nil {
+		t.Fatalf("Diagnose() error = %v", err)
+	}
+	if !strings.Contains(strings.Join(d.Actions, "\n"), "40.416800,-3.703800") {
+		t.Fatalf("Diagnose() actions = %q, want the position", d.Actions)
+	}
+	redacted := d.RedactLocation()
+	if got := strings.Join(redacted.Actions, "\n"); strings.Contains(got, "40.4168") || !strings.Contains(got, "record the GPS position [redacted]") {
+		t.Errorf("RedactLocation() actions = %q", redacted.Actions)
+	}
+	if !strings.Contains(strings.Join(d.Actions, "\n"), "40.416800,-3.703800") {
+		t.Error("RedactLocation() changed the diagnosis it was called on")
+	}
+}
+
+func TestDebugBundle_Uninspectable(t *testing.T) {
+	bundle := processor.DebugBundle{InspectErr: errors.New("unsupported container")}
+	var buf bytes.Buffer
+	if err := bundle.WriteZip(&buf); err != nil {
+		t.Fatalf("WriteZip() error = %v", err)
+	}
+	files := readBundle(t, buf.Bytes())
+	if !strings.Contains(files["structure.json"], `"error": "unsupported container"`) {
+		t.Errorf("structure.json = %s, want the inspection error", files["structure.json"])
+	}
+	if strings.TrimSpace(files["config.json"]) != "null" {
+		t.Errorf("config.json = %s, want null without a config file", files["config.json"])
+	}
+}
@@ -0,0 +1,108 @@
+package processor_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestReadEXIFDates(t *testing.T) {
+	dt := time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC)
+	payload, err := processor.CreateEXIFSegment(dt)
+	if err != nil {
+		t.Fatalf("CreateEXIFSegment() error = %v", err)
+	}
+
+	dates, err := processor.ReadEXIFDates(payload)
+	if err != nil {
+		t.Fatalf("ReadEXIFDates() error = %v", err)
+	}
+	if got := dates["DateTimeOriginal"]; got != "2024:05:01 14:30:00" {
+		t.Errorf("DateTimeOriginal = %q, want 2024:05:01 14:30:00", got)
+	}
+
+	if _, err := processor.ReadEXIFDates([]byte("Exif\x00\x00XX")); err == nil {
+		t.Error("ReadEXIFDates() should reject truncated data")
+	}
+}
+
+func TestReadMvhdTimes(t *testing.T) {
+	atoms, err := processor.ParseMP4Atoms(simpleMP4())
+	if err != nil {
+		t.Fatalf("ParseMP4Atoms() error = %v", err)
+	}
+	mvhd := processor.FindAtomRecursive(*processor.FindAtom(atoms, "moov"), "mvhd")
+
+	created, _, err := processor.ReadMvhdTimes(mvhd.Data)
+	if err != nil {
+		t.Fatalf("ReadMvhdTimes() error = %v", err)
+	}
+	if want := time.Unix(processor.QuickTimeToUnix(0), 0).UTC(); !created.Equal(want) {
+		t.Errorf("created = %s, want %s", created, want)
+	}
+
+	if _, _, err := processor.ReadMvhdTimes([]byte{2, 0, 0, 0}); err == nil {
+		t.Error("ReadMvhdTimes() should reject unknown versions")
+	}
+}
+
+func TestMatchDefaultPattern(t *testing.T) {
+	pattern, date, err := processor.MatchDefaultPattern("VID-20240501-WA0002.mp4")
+	if err != nil {
+		t.Fatalf("MatchDefaultPattern() error = %v", err)
+	}
+	if !strings.HasPrefix(pattern, "VID-") || date != "2024-05-01" {
+		t.Errorf("MatchDefaultPattern() = %q, %q", pattern, date)
+	}
+
+	if _, _, err := processor.MatchDefaultPattern("holiday.jpg"); err == nil {
+		t.Error("MatchDefaultPattern() should fail for non-WhatsApp names")
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.MkdirAll("media", 0755)
+	fsys.WriteFile("media/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	fsys.WriteFile("media/IMG-20240502-WA0002.gif", simpleMP4(), 0644)
+	fsys.WriteFile("media/STK-20240503-WA0003.webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), 0644)
+
+	proc := processor.New(processor.Config{InputDir: "media", OutputDir: "out", UpdateModified: true}, processor.WithFS(fsys))
+
+	d, err := proc.Diagnose("media/IMG-20240501-WA0001.jpg")
+	if err != nil {
+		t.Fatalf("Diagnose() error = %v", err)
+	}
+	if d.Container != processor.ContainerJPEG || d.FilenameDate != "2024-05-01" || d.Pattern == "" {
+		t.Errorf("Diagnose() = %+v", d)
+	}
+	if len(d.Actions) != 3 || !strings.Contains(d.Actions[0], "out/IMG-20240501-WA0001.jpg") ||
+		!strings.Contains(d.Actions[1], "EXIF DateTimeOriginal") || !strings.Contains(d.Actions[2], "modification time") {
+		t.Errorf("Diagnose() actions = %q", d.Actions)
+	}
+	if _, err := fsys.Stat("out/IMG-20240501-WA0001.jpg"); err == nil {
+		t.Error("Diagnose() wrote an output file")
+	}
+
+	d, err = proc.Diagnose("media/IMG-20240502-WA0002.gif")
+	if err != nil {
+		t.Fatalf("Diagnose() error = %v", err)
+	}
+	if d.ActualExt != ".mp4" || len(d.Dates) == 0 || d.Dates[0].Source != "mvhd creation_time" {
+		t.Errorf("Diagnose() mislabeled MP4 = %+v", d)
+	}
+
+	d, err = proc.Diagnose("media/STK-20240503-WA0003.webp")
+	if err != nil {
+		t.Fatalf("Diagnose() error = %v", err)
+	}
+	if !d.Plan.Skipped || len(d.Actions) != 1 || !strings.Contains(d.Actions[0], "sticker") {
+		t.Errorf("Diagnose() sticker actions = %q", d.Actions)
+	}
+
+	if _, err := proc.Diagnose("media/missing.jpg"); err == nil {
+		t.Error("Diagnose() should fail for missing files")
+	}
+}
@@ -0,0 +1,45 @@
+package processor_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestDisambiguateTimes(t *testing.T) {
+	dates := map[string]string{
+		"b/IMG-20240501-WA0002.jpg": "2024-05-01",
+		"a/IMG-20240501-WA0003.jpg": "2024-05-01",
+		"a/IMG-20240501-WA0001.jpg": "2024-05-01",
+		"a/IMG-20240502-WA0004.jpg": "2024-05-02",
+	}
+
+	got := processor.DisambiguateTimes(dates)
+	want := map[string]time.Duration{
+		"b/IMG-20240501-WA0002.jpg": time.Second,
+		"a/IMG-20240501-WA0003.jpg": 2 * time.Second,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DisambiguateTimes() = %v, want %v", got, want)
+	}
+}
+
+func TestProcessFiles_SpreadTimes(t *testing.T) {
+	fsys := processor.NewMemFS()
+	paths := []string{"IMG-20240501-WA0002.jpg", "IMG-20240501-WA0001.jpg", "IMG-20240502-WA0003.jpg"}
+	for _, path := range paths {
+		fsys.WriteFile(path, minimalJPEG(), 0644)
+	}
+
+	results := processor.New(processor.Config{SpreadTimes: true, DryRun: true}, processor.WithFS(fsys)).ProcessFiles(paths)
+	var got []string
+	for _, r := range results {
+		got = append(got, r.DateTime.Format("2006-01-02 15:04:05"))
+	}
+	want := []string{"2024-05-01 00:00:01", "2024-05-01 00:00:00", "2024-05-02 00:00:00"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dates = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"IMG-20240501-WA0001.jpg":        "photo",
+		"IMG-20240501-WA0001 (1).jpg":    "photo",
+		"IMG-20240501-WA0001-edited.jpg": "cropped photo",
+		"IMG-20240501-WA00012.jpg":       "other photo",
+		"VID-20240501-WA0001.mp4":        "a video",
+		"Backup/clip.mp4":                "a video",
+		"IMG-20240502-WA0002.jpg":        "unique",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+		paths = append(paths, path)
+	}
+
+	groups := processor.FindDuplicates(paths)
+	if len(groups) != 3 {
+		t.Fatalf("FindDuplicates() = %d groups, want 3: %+v", len(groups), groups)
+	}
+	// Identical groups first, the one with most redundancy leading
+	want := []struct {
+		kind  string
+		files []string
+	}{
+		{processor.DuplicateIdentical, []string{"Backup/clip.mp4", "VID-20240501-WA0001.mp4"}},
+		{processor.DuplicateIdentical, []string{"IMG-20240501-WA0001 (1).jpg", "IMG-20240501-WA0001.jpg"}},
+		{processor.DuplicateSimilar, []string{"IMG-20240501-WA0001 (1).jpg", "IMG-20240501-WA0001-edited.jpg", "IMG-20240501-WA0001.jpg"}},
+	}
+	for i, w := range want {
+		var got []string
+		for _, f := range groups[i].Files {
+			rel, _ := filepath.Rel(tmpDir, f)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if groups[i].Kind != w.kind || strings.Join(got, "|") != strings.Join(w.files, "|") {
+			t.Errorf("group %d = %s %v, want %s %v", i, groups[i].Kind, got, w.kind, w.files)
+		}
+	}
+	if groups[1].Redundant() != int64(len("photo")) || groups[2].Redundant() != 0 {
+		t.Errorf("Redundant() = %d, %d, want %d, 0", groups[1].Redundant(), groups[2].Redundant(), len("photo"))
+	}
+
+	// The HTML report lists them in a section of their own
+	path := filepath.Join(tmpDir, "report.html")
+	if err := processor.WriteHTMLReport(path, nil, groups, time.Now(), true, nil); err != nil {
+		t.Fatalf("WriteHTMLReport() error = %v", err)
+	}
+	html, _ := os.ReadFile(path)
+	if !strings.Contains(string(html), "<h2>Duplicates</h2>") || !strings.Contains(string(html), "3 versions of IMG-20240501-WA0001.jpg") {
+		t.Errorf("report has no duplicates section:\n%s", html)
+	}
+}
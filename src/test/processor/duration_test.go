@@ -0,0 +1,86 @@
+package processor_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// mvhdWithDuration builds version 0 mvhd data with a timescale and duration
+func mvhdWithDuration(timescale, duration uint32) []byte {
+	data := mvhdV0()
+	binary.BigEndian.PutUint32(data[12:16], timescale)
+	binary.BigEndian.PutUint32(data[16:20], duration)
+	return data
+}
+
+func TestVideoDuration(t *testing.T) {
+	tmpDir := t.TempDir()
+	ftyp := box("ftyp", []byte("isom"), make([]byte, 4))
+	files := map[string][]byte{
+		"clip.mp4":    append(ftyp, box("moov", box("mvhd", mvhdWithDuration(1000, 42500)))...),
+		"voice.m4a":   append(ftyp, box("moov", box("mvhd", mvhdWithDuration(44100, 44100*65)))...),
+		"corrupt.mp4": append(ftyp, box("moov", box("mvhd", mvhdWithDuration(0, 42500)))...),
+		"stream.mp4":  fragmentedMP4(),
+		"photo.jpg":   minimalJPEG(),
+	}
+	// A moov header claiming 4 GiB in a file of a few bytes
+	oversized := box("moov", box("mvhd", mvhdWithDuration(1000, 42500)))
+	binary.BigEndian.PutUint32(oversized[0:4], 0xFFFFFFF0)
+	files["oversized.mp4"] = append(append([]byte{}, ftyp...), oversized...)
+	for name, data := range files {
+		os.WriteFile(filepath.Join(tmpDir, name), data, 0644)
+	}
+
+	tests := []struct {
+		file    string
+		want    string
+		wantErr string
+	}{
+		{"clip.mp4", "0:43", ""},
+		{"voice.m4a", "1:05", ""},
+		{"stream.mp4", "0:00", ""},
+		{"corrupt.mp4", "", "timescale is 0"},
+		{"oversized.mp4", "", "invalid atom size"},
+		{"photo.jpg", "", processor.ErrNoDuration.Error()},
+	}
+	for _, tt := range tests {
+		d, err := processor.VideoDuration(filepath.Join(tmpDir, tt.file))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VideoDuration(%s) error = %v, want %q", tt.file, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || processor.FormatDuration(d) != tt.want {
+			t.Errorf("VideoDuration(%s) = %s, %v, want %s", tt.file, processor.FormatDuration(d), err, tt.want)
+		}
+	}
+	if _, err := processor.VideoDuration(filepath.Join(tmpDir, "photo.jpg")); !errors.Is(err, processor.ErrNoDuration) {
+		t.Errorf("VideoDuration(photo.jpg) error = %v, want ErrNoDuration", err)
+	}
+}
+
+func TestMovieDuration(t *testing.T) {
+	if d, err := processor.MovieDuration(600, 600*90); err != nil || d != 90*time.Second {
+		t.Errorf("MovieDuration(600, 54000) = %v, %v, want 1m30s", d, err)
+	}
+	if d, err := processor.MovieDuration(1_000_000_000, 3*3600*1_000_000_000+500_000_000); err != nil || d != 3*time.Hour+500*time.Millisecond {
+		t.Errorf("MovieDuration() at a nanosecond timescale = %v, %v, want 3h0m0.5s", d, err)
+	}
+	if _, err := processor.MovieDuration(1000, ^uint64(0)); err == nil {
+		t.Error("MovieDuration() of an unknown duration should fail")
+	}
+	if _, err := processor.MovieDuration(1, 30*24*3600); err == nil {
+		t.Error("MovieDuration() of a month should fail as implausible")
+	}
+	if got := processor.FormatDuration(3725 * time.Second); got != "1:02:05" {
+		t.Errorf("FormatDuration(3725s) = %s, want 1:02:05", got)
+	}
+}
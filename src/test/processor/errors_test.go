@@ -0,0 +1,78 @@
+package processor_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFile_SentinelErrors(t *testing.T) {
+	noMoov := append(box("ftyp", []byte("isom"), make([]byte, 4)), box("mdat", make([]byte, 64))...)
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		want    error
+		message string // Still part of the error text
+	}{
+		{"unmatched name", "holiday.jpg", minimalJPEG(), processor.ErrNoPatternMatch, "holiday.jpg"},
+		{"MP4 without moov", "VID-20240501-WA0001.mp4", noMoov, processor.ErrAtomNotFound, "moov atom not found"},
+		{"not an MP4", "VID-20240501-WA0002.mp4", []byte("RIFF\x00\x00\x00\x00AVI LIST"), processor.ErrUnsupportedContainer, "missing ftyp"},
+	}
+	for _, dryRun := range []bool{false, true} {
+		for _, tt := range tests {
+			fsys := processor.NewMemFS()
+			fsys.WriteFile(tt.file, tt.data, 0644)
+			p := processor.New(processor.Config{InputDir: ".", OverrideOriginal: true, DryRun: dryRun}, processor.WithFS(fsys))
+
+			r := p.ProcessFile(tt.file)
+			if !errors.Is(r.Error, tt.want) {
+				t.Errorf("%s (dry run %v): error = %v, want it to wrap %v", tt.name, dryRun, r.Error, tt.want)
+			} else if !strings.Contains(r.Error.Error(), tt.message) {
+				t.Errorf("%s (dry run %v): error = %v, want it to mention %q", tt.name, dryRun, r.Error, tt.message)
+			}
+		}
+	}
+}
+
+func TestProcessFile_KeptExif(t *testing.T) {
+	embedded := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, overwrite := range []bool{false, true} {
+		fsys := processor.NewMemFS()
+		fsys.WriteFile("IMG-20240501-WA0001.jpg", jpegDatedAt(embedded), 0644)
+		p := processor.New(processor.Config{InputDir: ".", OverrideOriginal: true, OverwriteExif: overwrite}, processor.WithFS(fsys))
+
+		r := p.ProcessFile("IMG-20240501-WA0001.jpg")
+		if !r.Success {
+			t.Fatalf("overwrite %v: ProcessFile() error = %v", overwrite, r.Error)
+		}
+		if kept := errors.Is(r.Kept, processor.ErrExifExists); kept == overwrite {
+			t.Errorf("overwrite %v: Kept = %v", overwrite, r.Kept)
+		}
+	}
+
+	// A dry run predicts the same success
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", jpegDatedAt(embedded), 0644)
+	p := processor.New(processor.Config{InputDir: ".", OverrideOriginal: true, DryRun: true}, processor.WithFS(fsys))
+	if r := p.ProcessFile("IMG-20240501-WA0001.jpg"); !r.Success {
+		t.Errorf("dry run: error = %v", r.Error)
+	}
+}
+
+func TestInspectData_UnsupportedContainer(t *testing.T) {
+	if _, err := processor.InspectData([]byte("GIF89a......")); !errors.Is(err, processor.ErrUnsupportedContainer) {
+		t.Errorf("InspectData(GIF) error = %v, want it to wrap ErrUnsupportedContainer", err)
+	}
+}
+
+func TestReadMvhdTimes_UnsupportedVersion(t *testing.T) {
+	mvhd := make([]byte, 100)
+	mvhd[0] = 2
+	if _, _, err := processor.ReadMvhdTimes(mvhd); !errors.Is(err, processor.ErrUnsupportedContainer) {
+		t.Errorf("ReadMvhdTimes(version 2) error = %v, want it to wrap ErrUnsupportedContainer", err)
+	}
+}
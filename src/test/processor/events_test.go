@@ -0,0 +1,40 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessor_EventHooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	good := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	bad := filepath.Join(tmpDir, "holiday.jpg")
+	os.WriteFile(good, minimalJPEG(), 0644)
+	os.WriteFile(bad, minimalJPEG(), 0644)
+
+	var started, done, failed []string
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true})
+	proc.OnFileStart = func(filePath string) { started = append(started, filePath) }
+	proc.OnFileDone = func(r processor.ProcessResult) { done = append(done, r.InputFile) }
+	proc.OnError = func(filePath string, err error) {
+		if err == nil {
+			t.Error("OnError called with nil error")
+		}
+		failed = append(failed, filePath)
+	}
+
+	proc.ProcessFiles([]string{good, bad})
+
+	if len(started) != 2 || started[0] != good || started[1] != bad {
+		t.Errorf("OnFileStart calls = %v", started)
+	}
+	if len(done) != 2 {
+		t.Errorf("OnFileDone calls = %v, want both files", done)
+	}
+	if len(failed) != 1 || failed[0] != bad {
+		t.Errorf("OnError calls = %v, want only %s", failed, bad)
+	}
+}
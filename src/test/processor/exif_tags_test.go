@@ -152,3 +152,34 @@ func TestCreateTIFFHeader_BigEndian(t *testing.T) {
 		t.Errorf("CreateTIFFHeader() magic = %d, want 42", magic)
 	}
 }
+
+func TestCreateEXIFSegmentWithOptions_Software(t *testing.T) {
+	dt := time.Date(2025, 1, 22, 15, 30, 45, 0, time.UTC)
+	payload, err := processor.CreateEXIFSegmentWithOptions(dt, processor.EXIFOptions{Software: "wappd v1.2.0", UserComment: "x"})
+	if err != nil {
+		t.Fatalf("CreateEXIFSegmentWithOptions() error = %v", err)
+	}
+
+	// IFD0 follows the 6-byte identifier and 8-byte TIFF header; Software
+	// (0x0131) sorts between Orientation and the ExifIFD pointer
+	tiff := payload[6:]
+	ifd0 := tiff[8:]
+	if count := binary.LittleEndian.Uint16(ifd0); count != 5 {
+		t.Fatalf("IFD0 entry count = %d, want 5", count)
+	}
+	entry := ifd0[2+3*12:]
+	if tag := binary.LittleEndian.Uint16(entry); tag != 0x0131 {
+		t.Fatalf("IFD0 entry 4 tag = 0x%04X, want 0x0131", tag)
+	}
+	count := binary.LittleEndian.Uint32(entry[4:])
+	offset := binary.LittleEndian.Uint32(entry[8:])
+	if got := string(tiff[offset : offset+count]); got != "wappd v1.2.0\x00" {
+		t.Errorf("Software = %q, want %q", got, "wappd v1.2.0\x00")
+	}
+
+	// The Exif sub-IFD must still be found through the shifted pointer
+	dates, err := processor.ReadEXIFDates(payload)
+	if err != nil || dates["DateTimeOriginal"] != "2025:01:22 15:30:45" {
+		t.Errorf("ReadEXIFDates() = %v, %v", dates, err)
+	}
+}
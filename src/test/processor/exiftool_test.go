@@ -0,0 +1,109 @@
+package processor_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestValidateBackend(t *testing.T) {
+	for _, b := range []string{"", "native", "exiftool", "auto"} {
+		if err := processor.ValidateBackend(b); err != nil {
+			t.Errorf("ValidateBackend(%q) error = %v", b, err)
+		}
+	}
+	if err := processor.ValidateBackend("ffmpeg"); err == nil {
+		t.Error("ValidateBackend(\"ffmpeg\") should fail")
+	}
+}
+
+func TestExiftoolArgs(t *testing.T) {
+	dt := time.Date(2025, 1, 22, 15, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		file      string
+		overwrite bool
+		want      []string
+	}{
+		{
+			name:      "Image overwrite",
+			file:      "IMG-20250122-WA0003.png",
+			overwrite: true,
+			want:      []string{"-overwrite_original", "-P", "-q", "-AllDates=2025:01:22 15:30:45", "IMG-20250122-WA0003.png"},
+		},
+		{
+			name:      "Image preserve existing tags",
+			file:      "IMG-20250122-WA0003.webp",
+			overwrite: false,
+			want:      []string{"-overwrite_original", "-P", "-q", "-wm", "cg", "-AllDates=2025:01:22 15:30:45", "IMG-20250122-WA0003.webp"},
+		},
+		{
+			name:      "Video uses QuickTime UTC tags",
+			file:      "VID-20250122-WA0003.MKV",
+			overwrite: true,
+			want: []string{"-overwrite_original", "-P", "-q", "-api", "QuickTimeUTC",
+				"-QuickTime:CreateDate=2025:01:22 15:30:45+00:00",
+				"-QuickTime:ModifyDate=2025:01:22 15:30:45+00:00",
+				"-QuickTime:TrackCreateDate=2025:01:22 15:30:45+00:00",
+				"-QuickTime:MediaCreateDate=2025:01:22 15:30:45+00:00",
+				"VID-20250122-WA0003.MKV"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := processor.ExiftoolArgs(tt.file, dt, tt.overwrite, false)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExiftoolArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExiftoolGPSArgs(t *testing.T) {
+	gps := &processor.GPSPoint{Lat: -33.8688, Lon: 151.2093}
+	want := []string{"-GPSLatitude=33.868800", "-GPSLatitudeRef=S", "-GPSLongitude=151.209300", "-GPSLongitudeRef=E"}
+	if got := processor.ExiftoolGPSArgs("IMG-20250122-WA0003.png", gps); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExiftoolGPSArgs() = %q, want %q", got, want)
+	}
+	if got := processor.ExiftoolGPSArgs("VID-20250122-WA0003.mp4", gps); got != nil {
+		t.Errorf("ExiftoolGPSArgs() for a video = %q, want none", got)
+	}
+	if got := processor.ExiftoolGPSArgs("IMG-20250122-WA0003.png", nil); got != nil {
+		t.Errorf("ExiftoolGPSArgs() without a position = %q, want none", got)
+	}
+
+	alt := -12.5
+	fix := time.Date(2025, 1, 22, 10, 30, 15, 0, time.UTC)
+	want = append(want, "-GPSAltitude=12.50", "-GPSAltitudeRef#=1", "-GPSDateStamp=2025:01:22", "-GPSTimeStamp=10:30:15.000")
+	if got := processor.ExiftoolGPSArgs("IMG-20250122-WA0003.png", &processor.GPSPoint{Lat: -33.8688, Lon: 151.2093, Alt: &alt, Time: &fix}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExiftoolGPSArgs() with altitude and time = %q, want %q", got, want)
+	}
+}
+
+func TestExiftoolUpdateArgs_KeepsExistingGPS(t *testing.T) {
+	dt := time.Date(2025, 1, 22, 0, 0, 0, 0, time.UTC)
+	gps := &processor.GPSPoint{Lat: -33.8688, Lon: 151.2093}
+	for _, overwrite := range []bool{false, true} {
+		args := processor.ExiftoolUpdateArgs("IMG-20250122-WA0003.png", dt, processor.Config{OverwriteExif: overwrite}, "", gps)
+		// Every command that writes the position must create tags only
+		keeps := false
+		for i, arg := range args {
+			switch {
+			case arg == "-execute":
+				keeps = false
+			case arg == "-wm" && i+1 < len(args) && args[i+1] == "cg":
+				keeps = true
+			case strings.HasPrefix(arg, "-GPS") && !keeps:
+				t.Errorf("overwrite %v: %s written without -wm cg in %q", overwrite, arg, args)
+			}
+		}
+		if !strings.Contains(strings.Join(args, " "), "-AllDates=2025:01:22 00:00:00") {
+			t.Errorf("overwrite %v: date not written in %q", overwrite, args)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package processor_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestParseFailureLimit(t *testing.T) {
+	tests := []struct {
+		input   string
+		total   int
+		want    int
+		wantErr bool
+	}{
+		{"", 100, -1, false},
+		{"0", 100, 0, false},
+		{"5", 100, 5, false},
+		{"10%", 100, 10, false},
+		{"10%", 15, 1, false},
+		{"2.5%", 200, 5, false},
+		{"100%", 7, 7, false},
+		{"-1", 0, 0, true},
+		{"0%", 0, 0, true},
+		{"150%", 0, 0, true},
+		{"many", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			limit, err := processor.ParseFailureLimit(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFailureLimit(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := limit.Max(tt.total); got != tt.want {
+				t.Errorf("ParseFailureLimit(%q).Max(%d) = %d, want %d", tt.input, tt.total, got, tt.want)
+			}
+			if got := limit.String(); got != tt.input {
+				t.Errorf("String() = %q, want %q", got, tt.input)
+			}
+		})
+	}
+}
+
+// failingBatch returns an in-memory batch of unmatched names followed by a
+// processable WhatsApp image
+func failingBatch(failing int) (*processor.MemFS, []string) {
+	fsys := processor.NewMemFS()
+	var paths []string
+	for i := 0; i < failing; i++ {
+		name := fmt.Sprintf("holiday-%d.jpg", i)
+		fsys.WriteFile(name, minimalJPEG(), 0644)
+		paths = append(paths, name)
+	}
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	return fsys, append(paths, "IMG-20240501-WA0001.jpg")
+}
+
+func TestProcessFiles_MaxFailuresAborts(t *testing.T) {
+	fsys, paths := failingBatch(5)
+	proc := processor.New(processor.Config{MaxFailures: "2"}, processor.WithFS(fsys))
+
+	results := proc.ProcessFiles(paths)
+	if !proc.Aborted() {
+		t.Fatal("Aborted() = false, want true")
+	}
+
+	failed, aborted := 0, 0
+	for _, r := range results {
+		switch {
+		case r.Error != nil:
+			failed++
+		case r.Skipped && r.SkipReason == processor.SkipReasonAborted:
+			aborted++
+		}
+	}
+	if failed != 3 || aborted != 3 {
+		t.Errorf("failed = %d, aborted = %d, want 3 and 3", failed, aborted)
+	}
+	if _, err := fsys.Stat("IMG-20240501-WA0001_modified.jpg"); err == nil {
+		t.Error("files after the abort were processed")
+	}
+}
+
+func TestProcessFiles_MaxFailuresConcurrent(t *testing.T) {
+	fsys, paths := failingBatch(20)
+	proc := processor.New(processor.Config{MaxFailures: "10%"}, processor.WithFS(fsys), processor.WithConcurrency(4))
+
+	results := proc.ProcessFiles(paths)
+	if !proc.Aborted() {
+		t.Fatal("Aborted() = false, want true")
+	}
+	aborted := 0
+	for _, r := range results {
+		if r.Skipped {
+			aborted++
+		}
+	}
+	if aborted == 0 {
+		t.Error("no files were left unprocessed after the abort")
+	}
+}
+
+func TestProcessFiles_WithinMaxFailures(t *testing.T) {
+	fsys, paths := failingBatch(2)
+	proc := processor.New(processor.Config{MaxFailures: "2"}, processor.WithFS(fsys))
+
+	results := proc.ProcessFiles(paths)
+	if proc.Aborted() {
+		t.Error("Aborted() = true with failures at the limit")
+	}
+	if last := results[len(results)-1]; !last.Success {
+		t.Errorf("last file error = %v", last.Error)
+	}
+}
+
+func TestProcessFiles_InvalidMaxFailures(t *testing.T) {
+	fsys, paths := failingBatch(0)
+	results := processor.New(processor.Config{MaxFailures: "lots"}, processor.WithFS(fsys)).ProcessFiles(paths)
+	if results[0].Error == nil {
+		t.Error("ProcessFiles() should report an invalid MaxFailures")
+	}
+}
+
+func TestProcessFiles_IgnoreUnmatched(t *testing.T) {
+	fsys, paths := failingBatch(3)
+	proc := processor.New(processor.Config{IgnoreUnmatched: true, MaxFailures: "1"}, processor.WithFS(fsys))
+
+	results := proc.ProcessFiles(paths)
+	if proc.Aborted() {
+		t.Error("ignored unmatched files counted towards --max-failures")
+	}
+	for _, r := range results[:3] {
+		if !r.Skipped || r.SkipReason != processor.SkipReasonUnmatched {
+			t.Errorf("%s: skipped = %v (%q), error = %v", r.InputFile, r.Skipped, r.SkipReason, r.Error)
+		}
+	}
+	if last := results[len(results)-1]; !last.Success {
+		t.Errorf("last file error = %v", last.Error)
+	}
+}
+
+func TestProcessFiles_UnmatchedError(t *testing.T) {
+	fsys, paths := failingBatch(1)
+	results := processor.New(processor.Config{}, processor.WithFS(fsys)).ProcessFiles(paths)
+	if !errors.Is(results[0].Error, processor.ErrNoPatternMatch) {
+		t.Errorf("error = %v, want ErrNoPatternMatch", results[0].Error)
+	}
+}
+
+func TestUnmatchedFiles(t *testing.T) {
+	paths := []string{
+		"IMG-20240501-WA0001.jpg",
+		"holiday.jpg",
+		"stickers/happy.webp",
+		"VID-20240501-WA0002.mp4",
+		"Screenshot.png",
+	}
+
+	got := processor.New(processor.Config{}).UnmatchedFiles(paths)
+	want := []string{"holiday.jpg", "Screenshot.png"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmatchedFiles() = %v, want %v", got, want)
+	}
+
+	// Stickers that would be processed must have a WhatsApp name too
+	got = processor.New(processor.Config{Stickers: processor.StickersProcess}).UnmatchedFiles(paths)
+	want = []string{"holiday.jpg", "stickers/happy.webp", "Screenshot.png"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmatchedFiles() with stickers = %v, want %v", got, want)
+	}
+}
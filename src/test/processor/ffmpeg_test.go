@@ -0,0 +1,50 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestFFmpegArgs(t *testing.T) {
+	dt := time.Date(2024, 4, 15, 10, 15, 30, 0, time.UTC)
+	got := processor.FFmpegArgs("in.mp4", "out.mp4", dt)
+	want := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", "in.mp4",
+		"-map", "0", "-c", "copy", "-map_metadata", "0",
+		"-metadata", "creation_time=2024-04-15T10:15:30.000000Z",
+		"out.mp4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FFmpegArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessFile_ReportsBackend(t *testing.T) {
+	tmpDir := t.TempDir()
+	jpegPath := filepath.Join(tmpDir, "IMG-20250122-WA0003.jpg")
+	pngPath := filepath.Join(tmpDir, "IMG-20250122-WA0004.png")
+	videoPath := filepath.Join(tmpDir, "VID-20250122-WA0005.mp4")
+	os.WriteFile(jpegPath, minimalJPEG(), 0644)
+	os.WriteFile(pngPath, []byte("png"), 0644)
+	os.WriteFile(videoPath, []byte("not really a video"), 0644)
+
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true})
+
+	if r := proc.ProcessFile(jpegPath); !r.Success || r.Backend != processor.BackendNative {
+		t.Errorf("JPEG result = %+v, want success with native backend", r)
+	}
+	if r := proc.ProcessFile(pngPath); !r.Success || r.Backend != "" {
+		t.Errorf("PNG result = %+v, want success with no backend", r)
+	}
+
+	// Without -allow-ffmpeg a broken video fails with the native backend
+	if r := proc.ProcessFile(videoPath); r.Success || r.Backend != processor.BackendNative {
+		t.Errorf("video result = %+v, want native failure", r)
+	}
+}
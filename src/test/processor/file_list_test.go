@@ -0,0 +1,60 @@
+package processor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestFailedPaths_RoundTrip(t *testing.T) {
+	results := []processor.ProcessResult{
+		{InputFile: "media/IMG-20240501-WA0001.jpg", Success: true},
+		{InputFile: "media/photo 1.jpg", Error: errors.New("no pattern")},
+		{InputFile: "media/STK-20240501-WA0001.webp", Skipped: true},
+		{InputFile: "media/VID-20240501-WA0002.mp4", Error: errors.New("moov atom not found")},
+	}
+	path := filepath.Join(t.TempDir(), "failed.txt")
+	if err := processor.WriteFileList(path, processor.FailedPaths(results)); err != nil {
+		t.Fatalf("WriteFileList() error = %v", err)
+	}
+	got, err := processor.ReadFileList(path)
+	want := []string{"media/photo 1.jpg", "media/VID-20240501-WA0002.mp4"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadFileList() = %q, %v, want %q", got, err, want)
+	}
+
+	// Nothing failed: the list is still written, empty
+	if err := processor.WriteFileList(path, processor.FailedPaths(results[:1])); err != nil {
+		t.Fatalf("WriteFileList() error = %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Errorf("empty failed list = %q, %v", data, err)
+	}
+}
+
+func TestReadFileList_SkipsBlankAndComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "files.txt")
+	os.WriteFile(path, []byte("# retry after fixing patterns\r\na.jpg\r\n\n  \nb.mp4\n"), 0644)
+	got, err := processor.ReadFileList(path)
+	if want := []string{"a.jpg", "b.mp4"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadFileList() = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestFilesUnder(t *testing.T) {
+	images := filepath.Join("backup", "Media", "WhatsApp Images")
+	paths := []string{
+		filepath.Join(images, "IMG-20240501-WA0001.jpg"),
+		filepath.Join(images, "Sent", "IMG-20240501-WA0002.jpg"),
+		filepath.Join("backup", "Media", "WhatsApp Images Extra", "IMG-20240501-WA0003.jpg"),
+		filepath.Join("backup", "Media", "WhatsApp Video", "VID-20240501-WA0001.mp4"),
+	}
+	got := processor.FilesUnder(images, paths)
+	if want := paths[:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("FilesUnder() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package processor_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// box builds an MP4 atom with a 32-bit size header
+func box(atomType string, payload ...[]byte) []byte {
+	var body []byte
+	for _, p := range payload {
+		body = append(body, p...)
+	}
+	buf := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(8+len(body)))
+	copy(buf[4:8], atomType)
+	return append(buf, body...)
+}
+
+// largeBox builds an MP4 atom with a 64-bit extended size header
+func largeBox(atomType string, payload []byte) []byte {
+	buf := make([]byte, 16, 16+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], 1)
+	copy(buf[4:8], atomType)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(16+len(payload)))
+	return append(buf, payload...)
+}
+
+// mvhdV0 builds a version 0 mvhd payload with zero timestamps
+func mvhdV0() []byte {
+	return make([]byte, 100) // version/flags + times + timescale/duration + rest
+}
+
+// fragmentedMP4 builds ftyp + moov(mvhd, mvex(trex)) + moof(mfhd, traf(tfdt)) + 64-bit mdat
+func fragmentedMP4() []byte {
+	var data []byte
+	data = append(data, box("ftyp", []byte("iso5"), make([]byte, 4), []byte("iso5dash"))...)
+	data = append(data, box("moov", box("mvhd", mvhdV0()), box("mvex", box("trex", make([]byte, 24))))...)
+	data = append(data, box("moof", box("mfhd", make([]byte, 8)), box("traf", box("tfdt", make([]byte, 8))))...)
+	data = append(data, largeBox("mdat", []byte("frame data"))...)
+	return data
+}
+
+func TestParseMP4Atoms_ExtendedSize(t *testing.T) {
+	atoms, err := processor.ParseMP4Atoms(fragmentedMP4())
+	if err != nil {
+		t.Fatalf("ParseMP4Atoms() error = %v", err)
+	}
+
+	mdat := processor.FindAtom(atoms, "mdat")
+	if mdat == nil {
+		t.Fatal("ParseMP4Atoms() did not find 64-bit mdat")
+	}
+	if string(mdat.Data) != "frame data" || mdat.Size != 26 {
+		t.Errorf("mdat = size %d data %q, want size 26 data \"frame data\"", mdat.Size, mdat.Data)
+	}
+}
+
+func TestIsFragmentedMP4(t *testing.T) {
+	atoms, _ := processor.ParseMP4Atoms(fragmentedMP4())
+	if !processor.IsFragmentedMP4(atoms) {
+		t.Error("IsFragmentedMP4() = false for fragmented file")
+	}
+
+	plain, _ := processor.ParseMP4Atoms(append(box("ftyp", []byte("isom")), box("moov", box("mvhd", mvhdV0()))...))
+	if processor.IsFragmentedMP4(plain) {
+		t.Error("IsFragmentedMP4() = true for non-fragmented file")
+	}
+}
+
+func TestUpdateVideoMetadata_FragmentedMP4(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "VID-20240415-WA0010.mp4")
+	original := fragmentedMP4()
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	dt := time.Date(2024, 4, 15, 10, 15, 30, 0, time.UTC)
+	if err := processor.UpdateVideoMetadata(path, dt); err != nil {
+		t.Fatalf("UpdateVideoMetadata() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	atoms, err := processor.ParseMP4Atoms(data)
+	if err != nil {
+		t.Fatalf("ParseMP4Atoms() after update error = %v", err)
+	}
+	mvhd := processor.FindAtomRecursive(*processor.FindAtom(atoms, "moov"), "mvhd")
+	created := binary.BigEndian.Uint32(mvhd.Data[4:8])
+	if got := processor.QuickTimeToUnix(created); got != dt.Unix() {
+		t.Errorf("mvhd creation time = %d, want %d", got, dt.Unix())
+	}
+
+	// Fragments must be left untouched
+	if len(data) != len(original) {
+		t.Errorf("file size changed: %d -> %d", len(original), len(data))
+	}
+	if string(processor.FindAtom(atoms, "mdat").Data) != "frame data" {
+		t.Error("mdat payload was modified")
+	}
+}
+
+func TestUpdateVideoMetadata_MediaSegment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "VID-20240415-WA0010.mp4")
+	segment := append(box("styp", []byte("msdh")), box("moof", box("mfhd", make([]byte, 8)))...)
+	os.WriteFile(path, segment, 0644)
+
+	if err := processor.UpdateVideoMetadata(path, time.Now()); err == nil {
+		t.Error("UpdateVideoMetadata() should fail for a media segment without init segment")
+	}
+}
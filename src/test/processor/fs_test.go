@@ -0,0 +1,79 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFiles_InMemory(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.MkdirAll("media", 0755)
+	fsys.WriteFile(filepath.Join("media", "IMG-20240501-WA0001.jpg"), minimalJPEG(), 0644)
+	fsys.WriteFile(filepath.Join("media", "VID-20240501-WA0002.mp4"), simpleMP4(), 0644)
+
+	proc := processor.New(processor.Config{InputDir: "media", OutputDir: "out", UpdateModified: true}, processor.WithFS(fsys))
+	results := proc.ProcessFiles([]string{
+		filepath.Join("media", "IMG-20240501-WA0001.jpg"),
+		filepath.Join("media", "VID-20240501-WA0002.mp4"),
+	})
+	for _, r := range results {
+		if !r.Success {
+			t.Fatalf("ProcessFile(%s) error = %v", r.InputFile, r.Error)
+		}
+	}
+
+	// Nothing may leak onto the real filesystem
+	if _, err := os.Stat("out"); !os.IsNotExist(err) {
+		t.Error("in-memory run created files on disk")
+	}
+
+	want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	jpeg, err := fsys.ReadFile(filepath.Join("out", "IMG-20240501-WA0001.jpg"))
+	if err != nil {
+		t.Fatalf("output JPEG missing: %v", err)
+	}
+	if !bytes.Contains(jpeg, []byte("2024:05:01 00:00:00")) {
+		t.Error("output JPEG has no DateTimeOriginal")
+	}
+	if info, _ := fsys.Stat(filepath.Join("out", "IMG-20240501-WA0001.jpg")); !info.ModTime().Equal(want) {
+		t.Errorf("ModTime = %s, want %s", info.ModTime(), want)
+	}
+
+	video, _ := fsys.ReadFile(filepath.Join("out", "VID-20240501-WA0002.mp4"))
+	atoms, _ := processor.ParseMP4Atoms(video)
+	mvhd := processor.FindAtomRecursive(*processor.FindAtom(atoms, "moov"), "mvhd")
+	if got := processor.QuickTimeToUnix(binary.BigEndian.Uint32(mvhd.Data[4:8])); got != want.Unix() {
+		t.Errorf("mvhd creation time = %d, want %d", got, want.Unix())
+	}
+
+	// Originals are untouched
+	original, _ := fsys.ReadFile(filepath.Join("media", "IMG-20240501-WA0001.jpg"))
+	if !bytes.Equal(original, minimalJPEG()) {
+		t.Error("original JPEG was modified")
+	}
+}
+
+func TestMemFS(t *testing.T) {
+	fsys := processor.NewMemFS()
+	if _, err := fsys.ReadFile("missing"); !os.IsNotExist(err) {
+		t.Errorf("ReadFile(missing) error = %v, want not-exist", err)
+	}
+
+	fsys.WriteFile("a.txt", []byte("hello"), 0600)
+	info, err := fsys.Stat("a.txt")
+	if err != nil || info.Size() != 5 || info.Mode().Perm() != 0600 {
+		t.Errorf("Stat(a.txt) = %v, %v", info, err)
+	}
+	if err := fsys.Remove("a.txt"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if files := fsys.Files(); len(files) != 0 {
+		t.Errorf("Files() after Remove = %v", files)
+	}
+}
@@ -0,0 +1,123 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// goldenScenarios are the configurations every sample is run through; each
+// has its own directory of expected outputs under testdata/golden
+var goldenScenarios = []struct {
+	name   string
+	config processor.Config
+}{
+	{"default", processor.Config{OverrideOriginal: true}},
+	{"overwrite", processor.Config{OverrideOriginal: true, OverwriteExif: true}},
+	{"timezone", processor.Config{OverrideOriginal: true, OverwriteExif: true, Timezone: "America/Mexico_City"}},
+}
+
+// TestGolden runs every sample in testdata/golden/input through the full
+// ProcessFile pipeline, byte-compares the result with the golden output and
+// checks it still decodes. Run with -update to regenerate the golden files
+// after an intended change to a writer.
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "golden", "input", "*"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no golden inputs found: %v", err)
+	}
+
+	for _, sc := range goldenScenarios {
+		for _, input := range inputs {
+			name := filepath.Base(input)
+			t.Run(sc.name+"/"+name, func(t *testing.T) {
+				original, err := os.ReadFile(input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tmpDir := t.TempDir()
+				path := filepath.Join(tmpDir, name)
+				os.WriteFile(path, original, 0644)
+
+				config := sc.config
+				config.InputDir = tmpDir
+				r := processor.New(config).ProcessFile(path)
+				if !r.Success {
+					t.Fatalf("ProcessFile() error = %v", r.Error)
+				}
+				got, _ := os.ReadFile(path)
+
+				goldenPath := filepath.Join("testdata", "golden", sc.name, name)
+				if *updateGolden {
+					os.MkdirAll(filepath.Dir(goldenPath), 0755)
+					if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(goldenPath)
+				if err != nil {
+					t.Fatalf("missing golden file (run go test -update): %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("output differs from %s (%d bytes, want %d)", goldenPath, len(got), len(want))
+				}
+
+				verifyStructure(t, name, original, got, r.DateTime)
+			})
+		}
+	}
+}
+
+// verifyStructure checks that a processed sample is still a valid file of
+// its kind and carries the expected date
+func verifyStructure(t *testing.T, name string, original, got []byte, dt time.Time) {
+	t.Helper()
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg":
+		before, err := jpeg.DecodeConfig(bytes.NewReader(original))
+		if err != nil {
+			t.Fatalf("input does not decode: %v", err)
+		}
+		after, err := jpeg.DecodeConfig(bytes.NewReader(got))
+		if err != nil {
+			t.Fatalf("output does not decode: %v", err)
+		}
+		if before.Width != after.Width || before.Height != after.Height {
+			t.Errorf("dimensions changed: %dx%d -> %dx%d", before.Width, before.Height, after.Width, after.Height)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(got)); err != nil {
+			t.Errorf("output image data does not decode: %v", err)
+		}
+	case ".mp4", ".3gp":
+		atoms, err := processor.ParseMP4Atoms(got)
+		if err != nil {
+			t.Fatalf("output does not parse: %v", err)
+		}
+		moov := processor.FindAtom(atoms, "moov")
+		mvhd := processor.FindAtomRecursive(*moov, "mvhd")
+		var created int64
+		if mvhd.Data[0] == 1 {
+			created = int64(binary.BigEndian.Uint64(mvhd.Data[4:12])) - 2082844800
+		} else {
+			created = processor.QuickTimeToUnix(binary.BigEndian.Uint32(mvhd.Data[4:8]))
+		}
+		if created != dt.Unix() {
+			t.Errorf("mvhd creation time = %d, want %d", created, dt.Unix())
+		}
+		stco := processor.FindAtomRecursive(*moov, "stco")
+		offset := binary.BigEndian.Uint32(stco.Data[8:12])
+		if !bytes.HasPrefix(got[offset:], []byte("\x00\x00\x00\x10fake video frame")) {
+			t.Error("stco no longer points at the media data")
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package processor_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// near reports whether two positions are within a metre or so
+func near(a processor.GPSPoint, lat, lon float64) bool {
+	return math.Abs(a.Lat-lat) < 1e-5 && math.Abs(a.Lon-lon) < 1e-5
+}
+
+func TestParseGPS(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"", false},
+		{"40.4168,-3.7038", false},
+		{" -33.8688 , 151.2093 ", false},
+		{"40.4168", true},
+		{"91,0", true},
+		{"0,-181", true},
+		{"north,east", true},
+	}
+	for _, tt := range tests {
+		if _, err := processor.ParseGPS(tt.in); (err != nil) != tt.wantErr {
+			t.Errorf("ParseGPS(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCreateEXIFSegmentWithOptions_GPS(t *testing.T) {
+	dt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, pos := range []processor.GPSPoint{{Lat: 40.416775, Lon: -3.70379}, {Lat: -33.8688, Lon: 151.2093}, {Lat: 0.9999999, Lon: 0}} {
+		exif, err := processor.CreateEXIFSegmentWithOptions(dt, processor.EXIFOptions{GPS: &pos, Software: "wappd"})
+		if err != nil {
+			t.Fatalf("CreateEXIFSegmentWithOptions() error = %v", err)
+		}
+		got, err := processor.ReadEXIFGPS(exif)
+		if err != nil || got == nil || !near(*got, pos.Lat, pos.Lon) {
+			t.Errorf("ReadEXIFGPS() = %v, %v, want %v", got, err, pos)
+		}
+		if got != nil && (got.Alt != nil || got.Time != nil) {
+			t.Errorf("ReadEXIFGPS() = %v, %v, want no altitude or time", got.Alt, got.Time)
+		}
+		dates, err := processor.ReadEXIFDates(exif)
+		if err != nil || dates["DateTimeOriginal"] != "2024:05:01 12:00:00" {
+			t.Errorf("ReadEXIFDates() = %v, %v", dates, err)
+		}
+	}
+
+	exif, _ := processor.CreateEXIFSegment(dt)
+	if got, err := processor.ReadEXIFGPS(exif); got != nil || err != nil {
+		t.Errorf("ReadEXIFGPS() without GPS = %v, %v, want nil", got, err)
+	}
+}
+
+func TestLoadTrack(t *testing.T) {
+	tmpDir := t.TempDir()
+	gpx := filepath.Join(tmpDir, "walk.gpx")
+	os.WriteFile(gpx, []byte(`<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><trkseg>
+    <trkpt lat="40.0" lon="-3.0"><time>2024-05-01T10:00:00Z</time></trkpt>
+    <trkpt lat="41.0" lon="-4.0"><time>2024-05-01T12:00:00Z</time></trkpt>
+    <trkpt lat="42.0" lon="-5.0"></trkpt>
+  </trkseg></trk>
+</gpx>`), 0644)
+	kml := filepath.Join(tmpDir, "walk.kml")
+	os.WriteFile(kml, []byte(`<?xml version="1.0"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+  <Placemark><gx:Track>
+    <when>2024-05-01T10:00:00Z</when>
+    <when>2024-05-01T12:00:00Z</when>
+    <gx:coord>-3.0 40.0 650</gx:coord>
+    <gx:coord>-4.0 41.0 700</gx:coord>
+  </gx:Track></Placemark>
+</kml>`), 0644)
+
+	for _, path := range []string{gpx, kml} {
+		track, err := processor.LoadTrack(path)
+		if err != nil {
+			t.Fatalf("LoadTrack(%s) error = %v", filepath.Base(path), err)
+		}
+		tests := []struct {
+			at       string
+			lat, lon float64
+			found    bool
+		}{
+			{"2024-05-01T10:20:00Z", 40, -3, true},
+			{"2024-05-01T11:10:00Z", 41, -4, true},
+			{"2024-05-01T09:30:00Z", 40, -3, true},
+			{"2024-05-01T13:30:00Z", 0, 0, false},
+		}
+		for _, tt := range tests {
+			at, _ := time.Parse(time.RFC3339, tt.at)
+			got := track.At(at)
+			if (got != nil) != tt.found || got != nil && !near(*got, tt.lat, tt.lon) {
+				t.Errorf("%s: At(%s) = %v, want %v,%v (found %v)", filepath.Base(path), tt.at, got, tt.lat, tt.lon, tt.found)
+			}
+		}
+	}
+
+	empty := filepath.Join(tmpDir, "empty.gpx")
+	os.WriteFile(empty, []byte(`<gpx><trk><trkseg></trkseg></trk></gpx>`), 0644)
+	if _, err := processor.LoadTrack(empty); err == nil {
+		t.Error("LoadTrack() of a track without timed points succeeded")
+	}
+}
+
+func TestProcessFile_GPS(t *testing.T) {
+	tmpDir := t.TempDir()
+	gpx := filepath.Join(tmpDir, "trip.gpx")
+	os.WriteFile(gpx, []byte(`<gpx><trk><trkseg>
+<trkpt lat="48.8584" lon="2.2945"><time>2024-05-01T00:10:00Z</time></trkpt>
+</trkseg></trk></gpx>`), 0644)
+
+	// The track point is close to the first file's date; the second falls
+	// back to the fixed position, and the third keeps the one it has, with
+	// its altitude and fix time
+	alt := 35.5
+	fix := time.Date(2024, 5, 3, 8, 59, 58, 250e6, time.UTC)
+	camera := processor.GPSPoint{Lat: 51.5007, Lon: -0.1246, Alt: &alt, Time: &fix}
+	recorded, _ := processor.CreateEXIFSegmentWithOptions(time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC), processor.EXIFOptions{GPS: &camera})
+	withGPS, _ := processor.InsertEXIFSegment(minimalJPEG(), recorded)
+	files := []struct {
+		name     string
+		data     []byte
+		lat, lon float64
+	}{
+		{"IMG-20240501-WA0001.jpg", minimalJPEG(), 48.8584, 2.2945},
+		{"IMG-20240502-WA0001.jpg", minimalJPEG(), 40.4168, -3.7038},
+		{"IMG-20240503-WA0001.jpg", withGPS, camera.Lat, camera.Lon},
+	}
+	fsys := processor.NewMemFS()
+	config := processor.Config{InputDir: "media", OverrideOriginal: true, OverwriteExif: true, GPS: "40.4168,-3.7038", GPX: gpx}
+	p := processor.New(config, processor.WithFS(fsys))
+	for _, f := range files {
+		path := "media/" + f.name
+		fsys.WriteFile(path, f.data, 0644)
+		if r := p.ProcessFile(path); !r.Success {
+			t.Fatalf("%s: ProcessFile() error = %v", f.name, r.Error)
+		}
+		data, _ := fsys.ReadFile(path)
+		segments, _ := processor.ParseJPEGSegments(data)
+		_, app1 := processor.FindAPP1Segment(segments)
+		if app1 == nil {
+			t.Fatalf("%s: no EXIF written", f.name)
+		}
+		got, err := processor.ReadEXIFGPS(app1.Payload)
+		if err != nil || got == nil || !near(*got, f.lat, f.lon) {
+			t.Errorf("%s: GPS = %v, %v, want %v,%v", f.name, got, err, f.lat, f.lon)
+		}
+		if f.lat == camera.Lat && got != nil {
+			if got.Alt == nil || *got.Alt != alt || got.Time == nil || !got.Time.Equal(fix) {
+				t.Errorf("%s: altitude %v and fix time %v not kept", f.name, got.Alt, got.Time)
+			}
+		}
+	}
+
+	if r := processor.New(processor.Config{GPX: filepath.Join(tmpDir, "missing.gpx")}).ProcessFile("IMG-20240501-WA0001.jpg"); r.Success {
+		t.Error("missing track accepted")
+	}
+}
@@ -0,0 +1,106 @@
+package processor_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestInferDates(t *testing.T) {
+	paths := []string{
+		"a/IMG-20240502-WA0004.jpg",
+		"a/IMG-20240501-edited-1.jpg",
+		"a/IMG-20240501-WA0001.jpg",
+		"a/IMG-20240501-edited-2.jpg",
+		"a/IMG-20240501-edited-3.jpg",
+		"a/notes.jpg",                 // after the last matched file
+		"b/IMG-20240501-edited-9.jpg", // no matched neighbors in its folder
+		"c/IMG-20240501-WA0001.jpg",
+		"c/PXL-edited-1.jpg", // between files of other sequences
+		"c/VID-20240601-WA0001.mp4",
+	}
+
+	got := processor.InferDates(paths)
+	want := map[string]string{
+		"a/IMG-20240501-edited-1.jpg": "2024-05-01T06:00:00",
+		"a/IMG-20240501-edited-2.jpg": "2024-05-01T12:00:00",
+		"a/IMG-20240501-edited-3.jpg": "2024-05-01T18:00:00",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InferDates() = %v, want %v", got, want)
+	}
+}
+
+func TestInferDates_NeighborsYearsApart(t *testing.T) {
+	paths := []string{"IMG-20000101-WA0001.jpg", "IMG-20240101-WA0001.jpg"}
+	for i := 1; i <= 13; i++ {
+		paths = append(paths, fmt.Sprintf("IMG-20000101-edited-%02d.jpg", i))
+	}
+
+	got := processor.InferDates(paths)
+	if len(got) != 13 {
+		t.Fatalf("InferDates() = %v, want 13 dates", got)
+	}
+	prev := "2000-01-01T00:00:00"
+	for i := 1; i <= 13; i++ {
+		date := got[fmt.Sprintf("IMG-20000101-edited-%02d.jpg", i)]
+		if date <= prev || date >= "2024-01-01T00:00:00" {
+			t.Errorf("edited-%02d inferred %s, want between %s and 2024-01-01T00:00:00", i, date, prev)
+		}
+		prev = date
+	}
+}
+
+func TestProcessFiles_InferDatesConfiguredPatterns(t *testing.T) {
+	fsys := processor.NewMemFS()
+	paths := []string{"PXL_20240501_100000.jpg", "PXL_20240501_edit.jpg", "PXL_20240503_100000.jpg"}
+	for _, path := range paths {
+		fsys.WriteFile(path, minimalJPEG(), 0644)
+	}
+
+	config := processor.Config{InferDates: true, ExtraPatterns: []string{processor.ExtraPatternsCamera}}
+	results := processor.New(config, processor.WithFS(fsys)).ProcessFiles(paths)
+	edited := results[1]
+	if !edited.Success || !edited.Inferred {
+		t.Fatalf("edited copy: success = %v, inferred = %v, error = %v", edited.Success, edited.Inferred, edited.Error)
+	}
+	if got := edited.DateTime.Format("2006-01-02 15:04:05"); got != "2024-05-02 10:00:00" {
+		t.Errorf("inferred date = %s, want 2024-05-02 10:00:00", got)
+	}
+	if unmatched := processor.New(config).UnmatchedFiles(paths); len(unmatched) != 0 {
+		t.Errorf("UnmatchedFiles() with InferDates = %v, want none", unmatched)
+	}
+}
+
+func TestProcessFiles_InferDates(t *testing.T) {
+	fsys := processor.NewMemFS()
+	paths := []string{"IMG-20240501-WA0001.jpg", "IMG-20240501-edited.jpg", "IMG-20240503-WA0002.jpg"}
+	for _, path := range paths {
+		fsys.WriteFile(path, minimalJPEG(), 0644)
+	}
+
+	results := processor.New(processor.Config{InferDates: true}, processor.WithFS(fsys)).ProcessFiles(paths)
+	edited := results[1]
+	if !edited.Success || !edited.Inferred {
+		t.Fatalf("edited copy: success = %v, inferred = %v, error = %v", edited.Success, edited.Inferred, edited.Error)
+	}
+	if got := edited.DateTime.Format("2006-01-02 15:04:05"); got != "2024-05-02 00:00:00" {
+		t.Errorf("inferred date = %s, want 2024-05-02 00:00:00", got)
+	}
+	if results[0].Inferred {
+		t.Error("matched file flagged as inferred")
+	}
+
+	// Without the mode the edited copy fails as before
+	results = processor.New(processor.Config{}, processor.WithFS(fsys)).ProcessFiles(paths)
+	if results[1].Error == nil {
+		t.Error("unmatched file processed without InferDates")
+	}
+
+	// Strict mode accepts files whose date can be inferred
+	if unmatched := processor.New(processor.Config{InferDates: true}).UnmatchedFiles(paths); len(unmatched) != 0 {
+		t.Errorf("UnmatchedFiles() with InferDates = %v, want none", unmatched)
+	}
+}
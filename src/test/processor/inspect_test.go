@@ -0,0 +1,91 @@
+package processor_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestInspectData_JPEG(t *testing.T) {
+	payload, err := processor.CreateEXIFSegment(time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("CreateEXIFSegment() error = %v", err)
+	}
+	data, err := processor.InsertEXIFSegment(minimalJPEG(), payload)
+	if err != nil {
+		t.Fatalf("InsertEXIFSegment() error = %v", err)
+	}
+
+	inspection, err := processor.InspectData(data)
+	if err != nil {
+		t.Fatalf("InspectData() error = %v", err)
+	}
+	if inspection.Container != processor.ContainerJPEG {
+		t.Errorf("Container = %q, want jpeg", inspection.Container)
+	}
+
+	nodes := inspection.Nodes
+	if len(nodes) < 3 || nodes[0].Name != "SOI" || nodes[1].Name != "APP1 (Exif)" {
+		t.Fatalf("Nodes = %+v", nodes)
+	}
+	if nodes[1].Offset != 2 || nodes[1].Size != uint64(len(payload))+4 {
+		t.Errorf("APP1 offset/size = %d/%d, want 2/%d", nodes[1].Offset, nodes[1].Size, len(payload)+4)
+	}
+	if got := nodes[1].Dates["DateTimeOriginal"]; got != "2024:05:01 14:30:00" {
+		t.Errorf("APP1 DateTimeOriginal = %q", got)
+	}
+
+	// Every byte is accounted for, in order
+	var next uint64
+	for _, n := range nodes {
+		if n.Offset != next {
+			t.Errorf("%s offset = %d, want %d", n.Name, n.Offset, next)
+		}
+		next = n.Offset + n.Size
+	}
+	if next != uint64(len(data)) {
+		t.Errorf("nodes end at %d, file is %d bytes", next, len(data))
+	}
+}
+
+func TestInspectData_MP4(t *testing.T) {
+	data := simpleMP4()
+
+	inspection, err := processor.InspectData(data)
+	if err != nil {
+		t.Fatalf("InspectData() error = %v", err)
+	}
+
+	var mvhd *processor.InspectNode
+	for i, n := range inspection.Nodes {
+		if n.Name == "moov" && len(n.Children) > 0 && n.Children[0].Name == "mvhd" {
+			mvhd = &inspection.Nodes[i].Children[0]
+		}
+	}
+	if mvhd == nil {
+		t.Fatalf("mvhd not found in %+v", inspection.Nodes)
+	}
+	if string(data[mvhd.Offset+4:mvhd.Offset+8]) != "mvhd" {
+		t.Errorf("mvhd offset %d does not point at the atom header", mvhd.Offset)
+	}
+	if _, ok := mvhd.Dates["creation_time"]; !ok {
+		t.Errorf("mvhd dates = %v", mvhd.Dates)
+	}
+
+	out, err := json.Marshal(inspection)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var decoded processor.Inspection
+	if err := json.Unmarshal(out, &decoded); err != nil || len(decoded.Nodes) != len(inspection.Nodes) {
+		t.Errorf("JSON round trip failed: %v", err)
+	}
+}
+
+func TestInspectData_Unsupported(t *testing.T) {
+	if _, err := processor.InspectData([]byte("GIF89a......")); err == nil {
+		t.Error("InspectData() should reject unsupported containers")
+	}
+}
@@ -0,0 +1,132 @@
+package processor_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestJournal_AddDoneClose(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := processor.NewJournal(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatalf("NewJournal() error = %v", err)
+	}
+
+	partial := filepath.Join(dir, "IMG-20240501-WA0001_modified.jpg")
+	if err := journal.Add(partial); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	data, _ := os.ReadFile(journal.Path())
+	if want := fmt.Sprintf("pid\t%d\n%s\n", os.Getpid(), partial); string(data) != want {
+		t.Errorf("journal = %q, want %q", data, want)
+	}
+
+	if err := journal.Done(partial); err != nil {
+		t.Fatalf("Done() error = %v", err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(journal.Path()); !os.IsNotExist(err) {
+		t.Error("Close() should remove an empty journal")
+	}
+}
+
+func TestCleanupJournals(t *testing.T) {
+	dir := t.TempDir()
+	journalDir := filepath.Join(dir, "journal")
+	now := time.Now()
+
+	// A crashed run's journal, whose lock is gone with it, and one from a
+	// run that is still going, however old
+	stale, _ := processor.NewJournal(journalDir)
+	leftover := filepath.Join(dir, "VID-20240501-WA0002_modified.mp4")
+	os.WriteFile(leftover, []byte("half a video"), 0644)
+	stale.Add(leftover)
+	stale.Add(filepath.Join(dir, "already-gone.jpg"))
+	stale.Close()
+
+	live, _ := processor.NewJournal(journalDir)
+	inProgress := filepath.Join(dir, "IMG-20240501-WA0001_modified.jpg")
+	os.WriteFile(inProgress, []byte("being written"), 0644)
+	live.Add(inProgress)
+	os.Chtimes(live.Path(), now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	defer live.Close()
+
+	removed, err := processor.CleanupJournals(journalDir, processor.DefaultJournalStaleAfter, now)
+	if err != nil {
+		t.Fatalf("CleanupJournals() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0] != leftover {
+		t.Errorf("CleanupJournals() removed %v, want [%s]", removed, leftover)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Error("stale leftover was not removed")
+	}
+	if _, err := os.Stat(stale.Path()); !os.IsNotExist(err) {
+		t.Error("stale journal was not removed")
+	}
+	if _, err := os.Stat(inProgress); err != nil {
+		t.Error("file of a live run was removed")
+	}
+	if _, err := os.Stat(live.Path()); err != nil {
+		t.Error("live journal was removed")
+	}
+
+	if removed, err := processor.CleanupJournals(filepath.Join(dir, "missing"), time.Hour, now); err != nil || removed != nil {
+		t.Errorf("CleanupJournals(missing dir) = %v, %v", removed, err)
+	}
+}
+
+func TestCleanupJournals_FreshJournalOfDeadRun(t *testing.T) {
+	dir := t.TempDir()
+	journalDir := filepath.Join(dir, "journal")
+
+	// Written a moment ago by a run that has already died
+	crashed, _ := processor.NewJournal(journalDir)
+	leftover := filepath.Join(dir, "IMG-20240501-WA0001_modified.jpg")
+	os.WriteFile(leftover, []byte("half an image"), 0644)
+	crashed.Add(leftover)
+	crashed.Close()
+
+	removed, err := processor.CleanupJournals(journalDir, processor.DefaultJournalStaleAfter, time.Now())
+	if err != nil {
+		t.Fatalf("CleanupJournals() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0] != leftover {
+		t.Errorf("CleanupJournals() removed %v, want [%s]", removed, leftover)
+	}
+}
+
+func TestProcessFile_JournalClearedAfterFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "IMG-20240501-WA0001.jpg")
+	os.WriteFile(input, minimalJPEG(), 0644)
+
+	journal, err := processor.NewJournal(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatalf("NewJournal() error = %v", err)
+	}
+
+	during := "unset"
+	proc := processor.New(processor.Config{InputDir: dir}, processor.WithJournal(journal))
+	proc.OnFileDone = func(processor.ProcessResult) {
+		data, _ := os.ReadFile(journal.Path())
+		during = string(data)
+	}
+	if r := proc.ProcessFile(input); !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+
+	if want := fmt.Sprintf("pid\t%d\n", os.Getpid()); during != want {
+		t.Errorf("journal after a finished file = %q, want %q", during, want)
+	}
+	if err := journal.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
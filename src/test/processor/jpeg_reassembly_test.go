@@ -0,0 +1,123 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// randomJPEG builds a structurally valid JPEG from r: SOI, a few random
+// APPn/DQT/DHT/COM segments (at most one EXIF), then the image data: a
+// frame header, scan and entropy-coded bytes (stuffed, with restart
+// markers), preceded by a few junk and fill bytes and usually ended by EOI.
+// It returns the file, its segments and its image data.
+func randomJPEG(r *rand.Rand) (data []byte, segments []processor.JPEGSegment, image []byte) {
+	segment := func(marker byte, payload []byte) []byte {
+		seg := []byte{0xFF, marker, 0, 0}
+		binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+		return append(seg, payload...)
+	}
+	randomBytes := func(n int) []byte {
+		b := make([]byte, n)
+		r.Read(b)
+		return b
+	}
+
+	data = []byte{0xFF, 0xD8}
+	hasEXIF := false
+	for i := r.Intn(6); i > 0; i-- {
+		markers := []byte{0xE0, 0xE1, 0xE2, 0xED, 0xEF, 0xDB, 0xC4, 0xFE}
+		marker := markers[r.Intn(len(markers))]
+		payload := randomBytes(r.Intn(300))
+		if marker == 0xE1 {
+			if hasEXIF || r.Intn(2) == 0 {
+				payload = append([]byte("http://ns.adobe.com/xap/1.0/\x00"), payload...)
+			} else {
+				payload = append([]byte("Exif\x00\x00"), payload...)
+				hasEXIF = true
+			}
+		}
+		data = append(data, segment(marker, payload)...)
+		segments = append(segments, processor.JPEGSegment{Marker: marker, Length: uint16(len(payload) + 2), Payload: payload})
+	}
+
+	// Junk (never 0xFF) and fill bytes between the segments and the frame
+	for i := r.Intn(4); i > 0; i-- {
+		image = append(image, byte(r.Intn(0xFF)))
+	}
+	for i := r.Intn(3); i > 0; i-- {
+		image = append(image, 0xFF)
+	}
+	frames := []byte{0xC0, 0xC1, 0xC2, 0xC3, 0xC9, 0xCA}
+	image = append(image, segment(frames[r.Intn(len(frames))], randomBytes(6+r.Intn(12)))...)
+	image = append(image, segment(0xC4, randomBytes(r.Intn(40)))...)
+	image = append(image, segment(0xDA, randomBytes(6+r.Intn(8)))...)
+	for i := r.Intn(400); i > 0; i-- {
+		switch b := byte(r.Intn(256)); {
+		case b == 0xFF:
+			image = append(image, 0xFF, 0x00) // Stuffed
+		case r.Intn(50) == 0:
+			image = append(image, 0xFF, 0xD0+byte(r.Intn(8))) // Restart marker
+		default:
+			image = append(image, b)
+		}
+	}
+	if r.Intn(5) > 0 {
+		image = append(image, 0xFF, 0xD9)
+	}
+	return append(data, image...), segments, image
+}
+
+func TestInsertEXIFSegment_RandomJPEGs(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		data, segments, image := randomJPEG(r)
+		exif := append([]byte("Exif\x00\x00"), bytes.Repeat([]byte{byte(i)}, r.Intn(200))...)
+
+		out, err := processor.InsertEXIFSegment(data, exif)
+		if err != nil {
+			t.Fatalf("case %d: InsertEXIFSegment() error = %v", i, err)
+		}
+
+		// The segments are the original ones with the EXIF one replaced in
+		// place, or the new one first
+		var want []processor.JPEGSegment
+		replaced := false
+		for _, seg := range segments {
+			if seg.Marker == 0xE1 && bytes.HasPrefix(seg.Payload, []byte("Exif\x00\x00")) {
+				seg = processor.JPEGSegment{Marker: 0xE1, Payload: exif}
+				replaced = true
+			}
+			want = append(want, seg)
+		}
+		if !replaced {
+			want = append([]processor.JPEGSegment{{Marker: 0xE1, Payload: exif}}, want...)
+		}
+		got, err := processor.ParseJPEGSegments(out)
+		if err != nil {
+			t.Fatalf("case %d: output doesn't parse: %v", i, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("case %d: got %d segments, want %d", i, len(got), len(want))
+		}
+		var size int
+		for j := range got {
+			if got[j].Marker != want[j].Marker || !bytes.Equal(got[j].Payload, want[j].Payload) || int(got[j].Length) != len(want[j].Payload)+2 {
+				t.Fatalf("case %d: segment %d = %X (%d bytes), want %X (%d bytes)", i, j, got[j].Marker, len(got[j].Payload), want[j].Marker, len(want[j].Payload))
+			}
+			size += 4 + len(got[j].Payload)
+		}
+
+		// Every byte after the segments, junk and fill bytes included, comes
+		// through (with EOI added when missing)
+		if !bytes.HasSuffix(image, []byte{0xFF, 0xD9}) {
+			image = append(image, 0xFF, 0xD9)
+		}
+		if rest := out[2+size:]; !bytes.Equal(rest, image) {
+			t.Fatalf("case %d: image data is %d bytes, want %d", i, len(rest), len(image))
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package processor_test
 
 import (
+	"bytes"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -153,3 +156,40 @@ func TestCreateEXIFSegment(t *testing.T) {
 		t.Error("CreateEXIFSegment() payload too short")
 	}
 }
+
+func TestInsertEXIFSegment_SizeLimit(t *testing.T) {
+	// The largest payload fits a length field of 0xFFFF
+	payload := append([]byte("Exif\x00\x00"), bytes.Repeat([]byte{0}, 65533-6)...)
+	data, err := processor.InsertEXIFSegment(minimalJPEG(), payload)
+	if err != nil {
+		t.Fatalf("InsertEXIFSegment(65533 bytes) error = %v", err)
+	}
+	segments, err := processor.ParseJPEGSegments(data)
+	if err != nil {
+		t.Fatalf("ParseJPEGSegments() error = %v", err)
+	}
+	if _, seg := processor.FindAPP1Segment(segments); seg == nil || seg.Length != 0xFFFF {
+		t.Errorf("APP1 segment = %+v, want length 0xFFFF", seg)
+	}
+
+	// One byte more would wrap the length around
+	_, err = processor.InsertEXIFSegment(minimalJPEG(), append(payload, 0))
+	if !errors.Is(err, processor.ErrSegmentTooLarge) || !strings.Contains(err.Error(), "65534 bytes") {
+		t.Errorf("InsertEXIFSegment(65534 bytes) error = %v, want ErrSegmentTooLarge naming the size", err)
+	}
+}
+
+func TestCreateEXIFSegment_CommentTooLarge(t *testing.T) {
+	dateTime := time.Date(2025, 1, 22, 15, 30, 45, 0, time.UTC)
+	_, err := processor.CreateEXIFSegmentWithComment(dateTime, false, strings.Repeat("x", 70000))
+	if !errors.Is(err, processor.ErrSegmentTooLarge) || !strings.Contains(err.Error(), "70000-byte UserComment") {
+		t.Errorf("CreateEXIFSegmentWithComment() error = %v, want ErrSegmentTooLarge naming the comment", err)
+	}
+}
+
+func TestInsertXMPSegment_TooLarge(t *testing.T) {
+	_, err := processor.InsertXMPSegment(minimalJPEG(), bytes.Repeat([]byte(" "), 70000))
+	if !errors.Is(err, processor.ErrSegmentTooLarge) {
+		t.Errorf("InsertXMPSegment() error = %v, want ErrSegmentTooLarge", err)
+	}
+}
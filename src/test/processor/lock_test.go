@@ -0,0 +1,81 @@
+package processor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG-20240501-WA0001.jpg")
+	if err := os.WriteFile(path, minimalJPEG(), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	lock, err := processor.LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile() error = %v", err)
+	}
+	if lock == nil {
+		t.Skip("advisory locks not supported here")
+	}
+	if _, err := processor.LockFile(path); !errors.Is(err, processor.ErrLocked) {
+		t.Errorf("second LockFile() error = %v, want ErrLocked", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	again, err := processor.LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile() after Unlock() error = %v", err)
+	}
+	again.Unlock()
+}
+
+func TestLockDir(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := processor.LockDir(dir)
+	if err != nil {
+		t.Fatalf("LockDir() error = %v", err)
+	}
+	if lock == nil {
+		t.Skip("advisory locks not supported here")
+	}
+	if _, err := processor.LockDir(dir); !errors.Is(err, processor.ErrLocked) {
+		t.Errorf("second LockDir() error = %v, want ErrLocked", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".wappd.lock")); !os.IsNotExist(err) {
+		t.Error("Unlock() should remove the directory lock file")
+	}
+	again, err := processor.LockDir(dir)
+	if err != nil {
+		t.Fatalf("LockDir() after Unlock() error = %v", err)
+	}
+	again.Unlock()
+}
+
+func TestProcessFiles_LockedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "IMG-20240501-WA0001.jpg")
+	if err := os.WriteFile(path, minimalJPEG(), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	lock, err := processor.LockFile(path)
+	if err != nil || lock == nil {
+		t.Skipf("advisory locks not available: %v", err)
+	}
+	defer lock.Unlock()
+
+	r := processor.New(processor.Config{InputDir: dir, OverrideOriginal: true}).ProcessFiles([]string{path})[0]
+	if !errors.Is(r.Error, processor.ErrLocked) {
+		t.Errorf("ProcessFiles() error = %v, want ErrLocked", r.Error)
+	}
+}
@@ -0,0 +1,162 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// minimalJPEG returns a tiny JPEG without EXIF: SOI + SOF0 + EOI
+func minimalJPEG() []byte {
+	return []byte{
+		0xFF, 0xD8, // SOI
+		0xFF, 0xC0, 0x00, 0x0B, 0x08, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x11, 0x00, // SOF0
+		0xFF, 0xD9, // EOI
+	}
+}
+
+func TestProcessFile_ManifestHashes(t *testing.T) {
+	tmpDir := t.TempDir()
+	outDir := filepath.Join(tmpDir, "out")
+	inputPath := filepath.Join(tmpDir, "IMG-20250122-WA0003.jpg")
+	if err := os.WriteFile(inputPath, minimalJPEG(), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	proc := processor.New(processor.Config{
+		InputDir:     tmpDir,
+		OutputDir:    outDir,
+		ManifestPath: filepath.Join(tmpDir, "manifest.json"),
+	})
+	result := proc.ProcessFile(inputPath)
+	if !result.Success {
+		t.Fatalf("ProcessFile() failed: %v", result.Error)
+	}
+
+	if result.PreHash == "" || result.PostHash == "" {
+		t.Fatal("ProcessFile() should populate PreHash and PostHash when a manifest is requested")
+	}
+	if result.PreHash == result.PostHash {
+		t.Error("ProcessFile() PostHash should differ from PreHash after EXIF insertion")
+	}
+
+	wantPre, _ := processor.HashFile(inputPath)
+	if result.PreHash != wantPre {
+		t.Errorf("ProcessFile() PreHash = %s, want %s", result.PreHash, wantPre)
+	}
+}
+
+func TestManifest_WriteLoadVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+	outDir := filepath.Join(tmpDir, "out")
+	manifestPath := filepath.Join(tmpDir, "reports", "2025", "manifest.json") // Created on write
+
+	inputs := []string{
+		filepath.Join(tmpDir, "IMG-20250122-WA0003.jpg"),
+		filepath.Join(tmpDir, "IMG-20240415-WA0010.jpg"),
+	}
+	for _, p := range inputs {
+		if err := os.WriteFile(p, minimalJPEG(), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	proc := processor.New(processor.Config{InputDir: tmpDir, OutputDir: outDir, ManifestPath: manifestPath})
+	manifest := processor.BuildManifest(proc.ProcessFiles(inputs))
+	if len(manifest.Entries) != 2 {
+		t.Fatalf("BuildManifest() returned %d entries, want 2", len(manifest.Entries))
+	}
+	if manifest.Entries[0].DateWritten != "2025-01-22T00:00:00" {
+		t.Errorf("BuildManifest() DateWritten = %s, want 2025-01-22T00:00:00", manifest.Entries[0].DateWritten)
+	}
+
+	if err := processor.WriteManifest(manifestPath, manifest); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+	loaded, err := processor.LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+
+	for _, r := range processor.VerifyManifest(loaded) {
+		if r.Status != processor.VerifyOK {
+			t.Errorf("VerifyManifest() %s status = %s, want ok", r.Entry.OutputFile, r.Status)
+		}
+	}
+
+	// Tamper with one output and remove the other
+	if err := os.WriteFile(loaded.Entries[0].OutputFile, []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with output: %v", err)
+	}
+	os.Remove(loaded.Entries[1].OutputFile)
+
+	results := processor.VerifyManifest(loaded)
+	if results[0].Status != processor.VerifyMismatch {
+		t.Errorf("VerifyManifest() tampered status = %s, want mismatch", results[0].Status)
+	}
+	if results[1].Status != processor.VerifyMissing {
+		t.Errorf("VerifyManifest() removed status = %s, want missing", results[1].Status)
+	}
+}
+
+// A run over relative paths and a verify from another directory, like
+// "wappd -d photos -manifest photos/m.json" then "wappd verify --manifest mt/photos/m.json"
+func TestManifest_VerifyFromAnotherDirectory(t *testing.T) {
+	root := t.TempDir()
+	runDir := filepath.Join(root, "mt")
+	if err := os.MkdirAll(filepath.Join(runDir, "photos"), 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	t.Chdir(runDir)
+
+	input := filepath.Join("photos", "IMG-20250122-WA0003.jpg")
+	if err := os.WriteFile(input, minimalJPEG(), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	manifestPath := filepath.Join("photos", "m.json")
+	proc := processor.New(processor.Config{InputDir: "photos", OutputDir: "out", ManifestPath: manifestPath})
+	if err := processor.WriteManifest(manifestPath, processor.BuildManifest(proc.ProcessFiles([]string{input}))); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+
+	written, err := processor.LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	raw, _ := os.ReadFile(manifestPath)
+	if !strings.Contains(string(raw), `"outputFile": "../out/IMG-20250122-WA0003.jpg"`) {
+		t.Errorf("WriteManifest() should store paths relative to the manifest, got:\n%s", raw)
+	}
+	if want := filepath.Join("out", "IMG-20250122-WA0003.jpg"); written.Entries[0].OutputFile != want {
+		t.Errorf("LoadManifest() OutputFile = %s, want %s", written.Entries[0].OutputFile, want)
+	}
+
+	t.Chdir(root)
+	loaded, err := processor.LoadManifest(filepath.Join("mt", "photos", "m.json"))
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	results := processor.VerifyManifest(loaded)
+	if len(results) != 1 || results[0].Status != processor.VerifyOK {
+		t.Fatalf("VerifyManifest() from another directory = %+v, want one ok entry", results)
+	}
+	if want := filepath.Join("mt", "photos", "IMG-20250122-WA0003.jpg"); loaded.Entries[0].InputFile != want {
+		t.Errorf("LoadManifest() InputFile = %s, want %s", loaded.Entries[0].InputFile, want)
+	}
+}
+
+func TestBuildManifest_SkipsDryRunAndFailures(t *testing.T) {
+	results := []processor.ProcessResult{
+		{InputFile: "a.jpg", OutputFile: "a.jpg", Success: true},                              // dry-run: no hashes
+		{InputFile: "b.jpg", Success: false, PreHash: "x"},                                    // failure
+		{InputFile: "c.jpg", OutputFile: "c.jpg", Success: true, PreHash: "p", PostHash: "q"}, // written
+	}
+
+	manifest := processor.BuildManifest(results)
+	if len(manifest.Entries) != 1 || manifest.Entries[0].InputFile != "c.jpg" {
+		t.Errorf("BuildManifest() entries = %+v, want only c.jpg", manifest.Entries)
+	}
+}
@@ -0,0 +1,70 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestParseMemoryLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"256MiB", 256 << 20},
+		{"512M", 512 << 20},
+		{"1G", 1 << 30},
+		{"2gb", 2 << 30},
+		{"4096KiB", 4 << 20},
+		{"1048576", 1 << 20},
+	}
+	for _, tt := range tests {
+		if got, err := processor.ParseMemoryLimit(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseMemoryLimit(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"lots", "256MiBs", "-1G", "10K", "1T"} {
+		if _, err := processor.ParseMemoryLimit(in); err == nil {
+			t.Errorf("ParseMemoryLimit(%q) should fail", in)
+		}
+	}
+}
+
+func TestProcessFiles_MemoryLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Each file's buffers take 3 x 200KiB, so only one fits in 1MiB
+	var paths []string
+	for _, name := range []string{"IMG-20240501-WA0001.jpg", "IMG-20240501-WA0002.jpg", "IMG-20240501-WA0003.jpg", "IMG-20240501-WA0004.jpg"} {
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, append(minimalJPEG(), make([]byte, 200<<10)...), 0644)
+		paths = append(paths, path)
+	}
+
+	config := processor.Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out"), MemoryLimit: "1MiB"}
+	proc := processor.New(config, processor.WithConcurrency(4))
+	var mu sync.Mutex
+	running, peak := 0, 0
+	proc.OnFileStart = func(string) {
+		mu.Lock()
+		defer mu.Unlock()
+		running++
+		peak = max(peak, running)
+	}
+	proc.OnFileDone = func(processor.ProcessResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		running--
+	}
+	for _, r := range proc.ProcessFiles(paths) {
+		if !r.Success {
+			t.Errorf("%s: %v", r.InputFile, r.Error)
+		}
+	}
+	if peak != 1 {
+		t.Errorf("%d files ran at once, want 1 within the memory limit", peak)
+	}
+}
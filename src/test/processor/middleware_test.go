@@ -0,0 +1,177 @@
+package processor_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestValidateStage(t *testing.T) {
+	for _, stage := range []processor.Stage{processor.StageExtract, processor.StageTransform, processor.StageValidate, processor.StageWrite, processor.StageVerify} {
+		if err := processor.ValidateStage(stage); err != nil {
+			t.Errorf("ValidateStage(%q) error = %v", stage, err)
+		}
+	}
+	if err := processor.ValidateStage("publish"); err == nil {
+		t.Error("ValidateStage(publish) accepted")
+	}
+}
+
+func TestProcessFile_Stages(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("media/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+
+	var order []processor.Stage
+	record := func(ctx *processor.FileContext) error {
+		order = append(order, ctx.Stage)
+		return nil
+	}
+	moved := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	opts := []processor.Option{processor.WithFS(fsys), processor.WithOutputDir("out")}
+	for _, stage := range []processor.Stage{processor.StageVerify, processor.StageWrite, processor.StageValidate, processor.StageTransform, processor.StageExtract} {
+		opts = append(opts, processor.WithStage(stage, record))
+	}
+	opts = append(opts,
+		processor.WithStage(processor.StageExtract, func(ctx *processor.FileContext) error {
+			ctx.DateTime = moved
+			return nil
+		}),
+		processor.WithStage(processor.StageTransform, func(ctx *processor.FileContext) error {
+			ctx.OutputPath = strings.Replace(ctx.OutputPath, "IMG-", "Photo-", 1)
+			return nil
+		}),
+	)
+
+	r := processor.New(processor.Config{InputDir: "media"}, opts...).ProcessFile("media/IMG-20240501-WA0001.jpg")
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+	want := []processor.Stage{processor.StageExtract, processor.StageTransform, processor.StageValidate, processor.StageWrite, processor.StageVerify}
+	if len(order) != len(want) {
+		t.Fatalf("stages ran %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("stages ran %v, want %v", order, want)
+		}
+	}
+	if !r.DateTime.Equal(moved) {
+		t.Errorf("DateTime = %v, want %v from the extract stage", r.DateTime, moved)
+	}
+	if !strings.Contains(r.OutputFile, "Photo-20240501-WA0001.jpg") {
+		t.Errorf("OutputFile = %q, want the transform stage's name", r.OutputFile)
+	}
+	if _, err := fsys.Stat(r.OutputFile); err != nil {
+		t.Errorf("output not written: %v", err)
+	}
+}
+
+func TestProcessFile_TransformStageChecked(t *testing.T) {
+	input := "media/IMG-20240501-WA0001.jpg"
+	tests := []struct {
+		name   string
+		config processor.Config
+		move   func(ctx *processor.FileContext)
+		want   string
+	}{
+		{
+			"onto the original without -o",
+			processor.Config{InputDir: "media", OutputDir: "out"},
+			func(ctx *processor.FileContext) { ctx.OutputPath = ctx.InputPath },
+			"onto itself",
+		},
+		{
+			"a copy with no-copy",
+			processor.Config{InputDir: "media", OverrideOriginal: true, NoCopy: true},
+			func(ctx *processor.FileContext) { ctx.OutputPath = "out/photo.jpg" },
+			"--no-copy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := processor.NewMemFS()
+			fsys.WriteFile(input, minimalJPEG(), 0644)
+			stage := processor.WithStage(processor.StageTransform, func(ctx *processor.FileContext) error {
+				tt.move(ctx)
+				return nil
+			})
+			r := processor.New(tt.config, processor.WithFS(fsys), stage).ProcessFile(input)
+			if r.Success || r.Error == nil || !strings.Contains(r.Error.Error(), tt.want) {
+				t.Errorf("ProcessFile() error = %v, want %q", r.Error, tt.want)
+			}
+			if files := fsys.Files(); len(files) != 1 {
+				t.Errorf("files = %v, want only the untouched original", files)
+			}
+		})
+	}
+
+	// An in-place edit moved elsewhere is a copy: the original stays
+	fsys := processor.NewMemFS()
+	fsys.WriteFile(input, minimalJPEG(), 0644)
+	stage := processor.WithStage(processor.StageTransform, func(ctx *processor.FileContext) error {
+		ctx.OutputPath = "out/photo.jpg"
+		return nil
+	})
+	r := processor.New(processor.Config{InputDir: "media", OverrideOriginal: true}, processor.WithFS(fsys), stage).ProcessFile(input)
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+	if _, err := fsys.Stat(input); err != nil {
+		t.Errorf("original removed after the stage moved its output: %v", err)
+	}
+}
+
+func TestProcessFile_StageRefuses(t *testing.T) {
+	refuse := func(*processor.FileContext) error { return errors.New("not allowed") }
+	for _, stage := range []processor.Stage{processor.StageValidate, processor.StageWrite, processor.StageVerify} {
+		fsys := processor.NewMemFS()
+		fsys.WriteFile("media/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+		p := processor.New(processor.Config{InputDir: "media"}, processor.WithFS(fsys), processor.WithOutputDir("out"), processor.WithStage(stage, refuse))
+
+		r := p.ProcessFile("media/IMG-20240501-WA0001.jpg")
+		if r.Success || r.Error == nil || !strings.Contains(r.Error.Error(), string(stage)+" stage: not allowed") {
+			t.Errorf("%s: error = %v, want the stage's error", stage, r.Error)
+		}
+		// A refused file leaves no copy behind
+		if files := fsys.Files(); len(files) != 1 {
+			t.Errorf("%s: files = %v, want only the original", stage, files)
+		}
+	}
+}
+
+func TestProcessFile_StagesDryRun(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("media/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	var ran []processor.Stage
+	record := func(ctx *processor.FileContext) error {
+		if !ctx.DryRun {
+			t.Errorf("%s: DryRun = false", ctx.Stage)
+		}
+		ran = append(ran, ctx.Stage)
+		return nil
+	}
+	opts := []processor.Option{processor.WithFS(fsys), processor.WithDryRun(true)}
+	for _, stage := range []processor.Stage{processor.StageExtract, processor.StageTransform, processor.StageValidate, processor.StageWrite, processor.StageVerify} {
+		opts = append(opts, processor.WithStage(stage, record))
+	}
+	r := processor.New(processor.Config{InputDir: "media", OverrideOriginal: true}, opts...).ProcessFile("media/IMG-20240501-WA0001.jpg")
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+	if len(ran) != 3 || ran[2] != processor.StageValidate {
+		t.Errorf("dry run ran stages %v, want extract, transform and validate", ran)
+	}
+}
+
+func TestProcessFile_UnknownStage(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("media/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	p := processor.New(processor.Config{InputDir: "media", OverrideOriginal: true}, processor.WithFS(fsys),
+		processor.WithStage("publish", func(*processor.FileContext) error { return nil }))
+	if r := p.ProcessFile("media/IMG-20240501-WA0001.jpg"); r.Success || r.Error == nil {
+		t.Errorf("unknown stage: success = %v, error = %v", r.Success, r.Error)
+	}
+}
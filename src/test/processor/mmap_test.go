@@ -0,0 +1,124 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// movieWithTracks builds moov(mvhd, trak(tkhd), trak(tkhd)) with zero timestamps
+func movieWithTracks() []byte {
+	tkhd := make([]byte, 84)
+	return box("moov", box("mvhd", mvhdV0()), box("trak", box("tkhd", tkhd)), box("trak", box("tkhd", tkhd)))
+}
+
+// checkHeaderTimes asserts that mvhd and every tkhd in data carry dt
+func checkHeaderTimes(t *testing.T, data []byte, dt time.Time) {
+	t.Helper()
+	atoms, err := processor.ParseMP4Atoms(data)
+	if err != nil {
+		t.Fatalf("ParseMP4Atoms() error = %v", err)
+	}
+	moov := processor.FindAtom(atoms, "moov")
+	if moov == nil {
+		t.Fatal("moov atom not found")
+	}
+	headers := []processor.Atom{*processor.FindAtomRecursive(*moov, "mvhd")}
+	for _, trak := range moov.Children {
+		if trak.Type == "trak" {
+			headers = append(headers, *processor.FindAtomRecursive(trak, "tkhd"))
+		}
+	}
+	if len(headers) != 3 {
+		t.Fatalf("found %d header atoms, want 3", len(headers))
+	}
+	for _, atom := range headers {
+		created, modified, err := processor.ReadMvhdTimes(atom.Data)
+		if err != nil {
+			t.Fatalf("%s: %v", atom.Type, err)
+		}
+		if !created.Equal(dt) || !modified.Equal(dt) {
+			t.Errorf("%s times = %v / %v, want %v", atom.Type, created, modified, dt)
+		}
+	}
+}
+
+func TestUpdateVideoMetadata_TrackHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "VID-20240415-WA0010.mp4")
+	os.WriteFile(path, append(box("ftyp", []byte("isom")), movieWithTracks()...), 0644)
+
+	dt := time.Date(2024, 4, 15, 10, 15, 30, 0, time.UTC)
+	if err := processor.UpdateVideoMetadata(path, dt); err != nil {
+		t.Fatalf("UpdateVideoMetadata() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	checkHeaderTimes(t, data, dt)
+}
+
+// largeVideoHeader is the ftyp written by writeLargeVideo
+var largeVideoHeader = box("ftyp", []byte("isom"))
+
+// writeLargeVideo writes ftyp + a sparse 64 MiB mdat starting with "frame
+// data" + moov at the end, as cameras write them, and returns the file size
+func writeLargeVideo(t *testing.T, path string) int64 {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer f.Close()
+	const mdatSize = 64<<20 + 8
+	mdat := make([]byte, 8)
+	binary.BigEndian.PutUint32(mdat[0:4], mdatSize)
+	copy(mdat[4:8], "mdat")
+	f.Write(append(largeVideoHeader, mdat...))
+	f.Write([]byte("frame data"))
+	moov := movieWithTracks()
+	f.WriteAt(moov, int64(len(largeVideoHeader)+mdatSize))
+	return int64(len(largeVideoHeader) + mdatSize + len(moov))
+}
+
+func TestProcessFile_MappedPatchFailureLeavesFile(t *testing.T) {
+	copied := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	ftyp := box("ftyp", []byte("isom"), make([]byte, 4), []byte("isom"))
+	// Large enough to be mapped under the memory limit. mvhd patches, then
+	// the creation date key can't be.
+	video := append(ftyp, box("mdat", make([]byte, 512<<10))...)
+	video = append(video, box("moov", box("mvhd", headerV0(100, copied)), appleKeysMeta("2025:01:15"))...)
+	path := filepath.Join(t.TempDir(), "VID-20240501-WA0001.mp4")
+	os.WriteFile(path, video, 0644)
+
+	config := processor.Config{InputDir: filepath.Dir(path), OverrideOriginal: true, MemoryLimit: "1MiB", VideoAtoms: []string{"mvhd", "keys"}}
+	if result := processor.New(config).ProcessFile(path); result.Success {
+		t.Fatal("ProcessFile() succeeded with an unknown creation date layout")
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, video) {
+		t.Error("failed mapped patch changed the file")
+	}
+}
+
+func TestUpdateVideoMetadata_LargeVideo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "VID-20240415-WA0011.mp4")
+	size := writeLargeVideo(t, path)
+
+	dt := time.Date(2024, 4, 15, 10, 15, 30, 0, time.UTC)
+	if err := processor.UpdateVideoMetadata(path, dt); err != nil {
+		t.Fatalf("UpdateVideoMetadata() error = %v", err)
+	}
+
+	info, _ := os.Stat(path)
+	if info.Size() != size {
+		t.Errorf("file size = %d, want %d", info.Size(), size)
+	}
+	data, _ := os.ReadFile(path)
+	payload := len(largeVideoHeader) + 8
+	if got := string(data[payload : payload+10]); got != "frame data" {
+		t.Errorf("mdat payload = %q, want it untouched", got)
+	}
+	checkHeaderTimes(t, data, dt)
+}
@@ -0,0 +1,47 @@
+package processor_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFile_MtimeOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string][]byte{
+		"IMG-20240501-WA0001.jpg":  minimalJPEG(),
+		"VID-20240502-WA0001.mp4":  simpleMP4(),
+		"PTT-20240503-WA0001.opus": minimalOpus(),
+	}
+	config := processor.Config{InputDir: tmpDir, OverrideOriginal: true, MtimeOnly: true}
+	for name, data := range files {
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, data, 0644)
+
+		r := processor.New(config).ProcessFile(path)
+		if !r.Success {
+			t.Fatalf("%s: ProcessFile() error = %v", name, r.Error)
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+			t.Errorf("%s: content changed with MtimeOnly", name)
+		}
+		info, _ := os.Stat(path)
+		if !info.ModTime().Equal(r.DateTime) || r.DateTime.IsZero() {
+			t.Errorf("%s: modification time = %v, want %v", name, info.ModTime(), r.DateTime)
+		}
+		if r.Backend != "" {
+			t.Errorf("%s: Backend = %q, want no metadata writer", name, r.Backend)
+		}
+	}
+
+	// Dry runs don't predict metadata writes that won't happen
+	broken := filepath.Join(tmpDir, "IMG-20240504-WA0001.jpg")
+	os.WriteFile(broken, []byte("not a JPEG"), 0644)
+	config.DryRun = true
+	if r := processor.New(config).ProcessFile(broken); !r.Success {
+		t.Errorf("dry run of a broken JPEG with MtimeOnly: %v, want success", r.Error)
+	}
+}
@@ -0,0 +1,87 @@
+package processor_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// mp4CreatedAt returns simpleMP4 with an mvhd creation time of created, or
+// 0 when created is zero
+func mp4CreatedAt(created time.Time) []byte {
+	mvhd := mvhdV0()
+	if !created.IsZero() {
+		binary.BigEndian.PutUint32(mvhd[4:8], processor.UnixToQuickTime(created.Unix()))
+	}
+	return append(box("ftyp", []byte("isom"), make([]byte, 4)), box("moov", box("mvhd", mvhd))...)
+}
+
+func TestClassifyMvhdTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		created time.Time
+		want    string
+	}{
+		{time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC), processor.MvhdZero},
+		{time.Date(1958, 3, 2, 0, 0, 0, 0, time.UTC), processor.MvhdInvalid},
+		{now.AddDate(0, 1, 0), processor.MvhdInvalid},
+		{time.Date(2023, 7, 14, 10, 0, 0, 0, time.UTC), ""},
+		{now.Add(time.Hour), ""},
+	}
+	for _, tt := range tests {
+		if got := processor.ClassifyMvhdTime(tt.created, now); got != tt.want {
+			t.Errorf("ClassifyMvhdTime(%v) = %q, want %q", tt.created, got, tt.want)
+		}
+	}
+}
+
+func TestProcessFile_ZeroMvhd(t *testing.T) {
+	named := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	set := time.Date(2023, 7, 14, 10, 0, 0, 0, time.UTC)
+	invalid := time.Date(1958, 3, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		created   time.Time
+		policy    string
+		overwrite bool
+		written   bool
+		unset     string
+	}{
+		{"no policy rewrites a real date", set, "", false, true, ""},
+		{"no policy reports a zero time", time.Time{}, "", false, true, processor.MvhdZero},
+		{"missing keeps a real date", set, processor.ZeroMvhdMissing, false, false, ""},
+		{"missing writes a zero time", time.Time{}, processor.ZeroMvhdMissing, false, true, processor.MvhdZero},
+		{"missing writes an invalid time", invalid, processor.ZeroMvhdMissing, false, true, processor.MvhdInvalid},
+		{"existing keeps a zero time", time.Time{}, processor.ZeroMvhdExisting, false, false, processor.MvhdZero},
+		{"existing with -ow writes a zero time", time.Time{}, processor.ZeroMvhdExisting, true, true, processor.MvhdZero},
+		{"missing with -ow writes a real date", set, processor.ZeroMvhdMissing, true, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := processor.NewMemFS()
+			fsys.WriteFile("media/VID-20240501-WA0001.mp4", mp4CreatedAt(tt.created), 0644)
+
+			config := processor.Config{InputDir: "media", OverrideOriginal: true, OverwriteExif: tt.overwrite, ZeroMvhd: tt.policy}
+			r := processor.New(config, processor.WithFS(fsys)).ProcessFile("media/VID-20240501-WA0001.mp4")
+			if !r.Success {
+				t.Fatalf("ProcessFile() error = %v", r.Error)
+			}
+			if r.MvhdUnset != tt.unset {
+				t.Errorf("MvhdUnset = %q, want %q", r.MvhdUnset, tt.unset)
+			}
+			data, _ := fsys.ReadFile("media/VID-20240501-WA0001.mp4")
+			atoms, _ := processor.ParseMP4Atoms(data)
+			mvhd := processor.FindAtomRecursive(*processor.FindAtom(atoms, "moov"), "mvhd")
+			created, _, _ := processor.ReadMvhdTimes(mvhd.Data)
+			if written := created.Equal(named); written != tt.written {
+				t.Errorf("mvhd creation time = %v, written = %v, want %v", created, written, tt.written)
+			}
+		})
+	}
+
+	if r := processor.New(processor.Config{ZeroMvhd: "ignore"}).ProcessFile("VID-20240501-WA0001.mp4"); r.Success {
+		t.Error("unknown zero mvhd policy accepted")
+	}
+}
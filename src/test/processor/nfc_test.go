@@ -0,0 +1,54 @@
+package processor_test
+
+import (
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestNormalizeNFC(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ascii", "IMG-20240501-WA0001.jpg", "IMG-20240501-WA0001.jpg"},
+		{"acute", "Vidéo WhatsApp", "Vidéo WhatsApp"},
+		{"already composed", "Vidéo WhatsApp", "Vidéo WhatsApp"},
+		{"two marks", "Ệ", "Ệ"},           // Dot below, then circumflex
+		{"blocked", "á́", "á́"},            // No single character for a doubled accent
+		{"dakuten", "ビデオ", "ビデオ"},         // Japanese katakana
+		{"hangul", "사진", "사진"},           // Jamo to syllables
+		{"lone mark", "́IMG", "́IMG"},       // Nothing to compose with
+		{"starter after mark", "ẹe", "ẹe"}, // A starter never joins across a mark
+		{"cyrillic", "Ймаге", "Ймаге"},     // Breve
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processor.NormalizeNFC(tt.in); got != tt.want {
+				t.Errorf("NormalizeNFC(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilenameDate_DecomposedNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		filename string
+	}{
+		{"decomposed name", "Vidéo WhatsApp {date}", "Vidéo WhatsApp 20240501.mp4"},
+		{"decomposed pattern", "Vidéo WhatsApp {date}", "Vidéo WhatsApp 20240501.mp4"},
+		{"regex", `^Imagen de WhatsApp del (?P<date>\d{8}) \(año\)`, "Imagen de WhatsApp del 20240501 (año).jpg"},
+		{"japanese", "ビデオ_{date}", "ビデオ_20240501.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := processor.New(processor.Config{Patterns: []processor.FilenamePattern{{Pattern: tt.pattern}}})
+			if date, err := p.FilenameDate(tt.filename); err != nil || date != "2024-05-01" {
+				t.Errorf("FilenameDate(%q) = %q, %v, want 2024-05-01", tt.filename, date, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFile_NoCopy(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	os.WriteFile(path, minimalJPEG(), 0644)
+
+	// In place: the original is the output
+	r := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, NoCopy: true}).ProcessFile(path)
+	if !r.Success || r.OutputFile != path {
+		t.Fatalf("NoCopy with OverrideOriginal: success = %v, output = %q, error = %v", r.Success, r.OutputFile, r.Error)
+	}
+
+	// Templates that write elsewhere are refused before anything is written
+	for _, config := range []processor.Config{
+		{InputDir: tmpDir, NoCopy: true},
+		{InputDir: tmpDir, OverrideOriginal: true, NoCopy: true, OutputTemplate: "{out}/{year}/{name}{ext}"},
+	} {
+		r := processor.New(config).ProcessFile(path)
+		if r.Success || r.Error == nil || !strings.Contains(r.Error.Error(), "--no-copy") {
+			t.Errorf("template %q: error = %v, want a --no-copy error", config.OutputTemplate, r.Error)
+		}
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("NoCopy left %d entries in the input directory, want 1", len(entries))
+	}
+}
@@ -0,0 +1,69 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestParseClockOffset(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"+2h30m", 2*time.Hour + 30*time.Minute, false},
+		{"2h30m", 2*time.Hour + 30*time.Minute, false},
+		{"-45m", -45 * time.Minute, false},
+		{"+1d", 24 * time.Hour, false},
+		{"-1d6h", -30 * time.Hour, false},
+		{"+90s", 90 * time.Second, false},
+		{"two hours", 0, true},
+		{"+xd", 0, true},
+		{"+1h-30m", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := processor.ParseClockOffset(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseClockOffset(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseClockOffset(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestProcessFile_Offset(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "WhatsApp Image 2025-01-22 at 11.00.00 PM.jpg")
+	os.WriteFile(path, minimalJPEG(), 0644)
+
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, Offset: "+2h30m"})
+	r := proc.ProcessFile(path)
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+
+	// 23:00 + 2h30m crosses midnight
+	want := time.Date(2025, 1, 23, 1, 30, 0, 0, time.UTC)
+	if !r.DateTime.Equal(want) {
+		t.Errorf("ProcessFile() DateTime = %s, want %s", r.DateTime, want)
+	}
+}
+
+func TestProcessFile_InvalidOffset(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "IMG-20250122-WA0003.jpg")
+	os.WriteFile(path, minimalJPEG(), 0644)
+
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, Offset: "soon"})
+	if r := proc.ProcessFile(path); r.Success {
+		t.Error("ProcessFile() should fail with an invalid offset")
+	}
+}
@@ -0,0 +1,75 @@
+package processor_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// fixedClock is a Clock that always returns the same instant
+type fixedClock struct{ t time.Time }
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+func TestNew_OptionsOverrideConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	outDir := filepath.Join(tmpDir, "out")
+	path := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	os.WriteFile(path, minimalJPEG(), 0644)
+
+	proc := processor.New(processor.Config{InputDir: tmpDir}, processor.WithOutputDir(outDir), processor.WithDryRun(true))
+	r := proc.ProcessFile(path)
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+	if want := filepath.Join(outDir, "IMG-20240501-WA0001.jpg"); r.OutputFile != want {
+		t.Errorf("OutputFile = %q, want %q", r.OutputFile, want)
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Error("WithDryRun(true) should not create the output directory")
+	}
+}
+
+func TestWithConcurrency_KeepsInputOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	var paths []string
+	for i := 1; i <= 20; i++ {
+		path := filepath.Join(tmpDir, fmt.Sprintf("IMG-202405%02d-WA0001.jpg", i))
+		os.WriteFile(path, minimalJPEG(), 0644)
+		paths = append(paths, path)
+	}
+
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true}, processor.WithConcurrency(4))
+	results := proc.ProcessFiles(paths)
+	for i, r := range results {
+		if r.InputFile != paths[i] || !r.Success {
+			t.Errorf("results[%d] = %s (success %v), want %s", i, r.InputFile, r.Success, paths[i])
+		}
+	}
+}
+
+func TestWithLoggerAndClock(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	os.WriteFile(path, minimalJPEG(), 0644)
+
+	var buf bytes.Buffer
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, Verbose: true},
+		processor.WithLogger(log.New(&buf, "", 0)), processor.WithClock(fixedClock{now}))
+	r := proc.ProcessFile(path)
+
+	if !strings.Contains(buf.String(), "Updated EXIF DateTimeOriginal") {
+		t.Errorf("logger output = %q, want verbose EXIF message", buf.String())
+	}
+	if !r.ProcessedAt.Equal(now) {
+		t.Errorf("ProcessedAt = %s, want %s", r.ProcessedAt, now)
+	}
+}
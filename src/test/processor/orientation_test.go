@@ -0,0 +1,110 @@
+package processor_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// orientedJPEG returns a 40x20 JPEG, red on the left half and blue on the
+// right, with an EXIF Orientation tag
+func orientedJPEG(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 20 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	exif, _ := processor.CreateEXIFSegmentWithOptions(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), processor.EXIFOptions{Orientation: orientation})
+	data, err := processor.InsertEXIFSegment(buf.Bytes(), exif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// processOriented writes data as a WhatsApp JPEG, processes it with config
+// and returns the result's image, its EXIF Orientation and DateTimeOriginal
+func processOriented(t *testing.T, data []byte, config processor.Config) (image.Image, int, string) {
+	t.Helper()
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("media/IMG-20240501-WA0001.jpg", data, 0644)
+	config.InputDir = "media"
+	config.OverrideOriginal = true
+	config.OverwriteExif = true
+	if r := processor.New(config, processor.WithFS(fsys)).ProcessFile("media/IMG-20240501-WA0001.jpg"); !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+
+	out, _ := fsys.ReadFile("media/IMG-20240501-WA0001.jpg")
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("output isn't a valid JPEG: %v", err)
+	}
+	segments, _ := processor.ParseJPEGSegments(out)
+	_, app1 := processor.FindAPP1Segment(segments)
+	if app1 == nil {
+		t.Fatal("output has no EXIF")
+	}
+	orientation, _ := processor.ReadEXIFOrientation(app1.Payload)
+	dates, _ := processor.ReadEXIFDates(app1.Payload)
+	return img, orientation, dates["DateTimeOriginal"]
+}
+
+func TestProcessFile_PreservesOrientation(t *testing.T) {
+	img, orientation, date := processOriented(t, orientedJPEG(t, 6), processor.Config{})
+	if orientation != 6 {
+		t.Errorf("Orientation = %d, want 6 kept", orientation)
+	}
+	if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 20 {
+		t.Errorf("image is %dx%d, want the pixels untouched", b.Dx(), b.Dy())
+	}
+	if date != "2024:05:01 00:00:00" {
+		t.Errorf("DateTimeOriginal = %q", date)
+	}
+}
+
+func TestProcessFile_NormalizeOrientation(t *testing.T) {
+	tests := []struct {
+		orientation uint16
+		w, h        int
+		red, blue   image.Point // A pixel that should be red and one blue
+	}{
+		{1, 40, 20, image.Pt(5, 10), image.Pt(35, 10)},
+		{2, 40, 20, image.Pt(35, 10), image.Pt(5, 10)},
+		{3, 40, 20, image.Pt(35, 10), image.Pt(5, 10)},
+		{6, 20, 40, image.Pt(10, 5), image.Pt(10, 35)},
+		{8, 20, 40, image.Pt(10, 35), image.Pt(10, 5)},
+	}
+	for _, tt := range tests {
+		img, orientation, date := processOriented(t, orientedJPEG(t, tt.orientation), processor.Config{NormalizeOrientation: true})
+		if orientation != 1 {
+			t.Errorf("Orientation %d: tag = %d after normalizing, want 1", tt.orientation, orientation)
+		}
+		if date != "2024:05:01 00:00:00" {
+			t.Errorf("Orientation %d: DateTimeOriginal = %q", tt.orientation, date)
+		}
+		if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("Orientation %d: image is %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		if r, _, b, _ := img.At(tt.red.X, tt.red.Y).RGBA(); r < b {
+			t.Errorf("Orientation %d: pixel %v isn't red", tt.orientation, tt.red)
+		}
+		if r, _, b, _ := img.At(tt.blue.X, tt.blue.Y).RGBA(); b < r {
+			t.Errorf("Orientation %d: pixel %v isn't blue", tt.orientation, tt.blue)
+		}
+	}
+}
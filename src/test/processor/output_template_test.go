@@ -0,0 +1,170 @@
+package processor_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestValidateOutputTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  string
+	}{
+		{"", ""},
+		{"{out}/{year}/{month}/{name}{ext}", ""},
+		{"{dir}/{name}_fixed{ext}", ""},
+		{"{out}/{rel}/{date}-{name}{ext}", ""},
+		{"{out}/{yaer}/{name}{ext}", "unknown placeholder {yaer}"},
+		{processor.TemplateRenameHash, ""},
+		{"{out}/{year}/photo{ext}", "must include {name} or {hash}"},
+	}
+	for _, tt := range tests {
+		err := processor.ValidateOutputTemplate(tt.template)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("ValidateOutputTemplate(%q) error = %v", tt.template, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("ValidateOutputTemplate(%q) error = %v, want %q", tt.template, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProcessFiles_OutputTemplate(t *testing.T) {
+	input := filepath.Join("media", "Sent", "IMG-20240501-WA0001.jpg")
+	sum := sha256.Sum256(minimalJPEG())
+	tests := []struct {
+		name   string
+		config processor.Config
+		want   string
+	}{
+		{"suffix by default", processor.Config{}, filepath.Join("media", "Sent", "IMG-20240501-WA0001_modified.jpg")},
+		{"override original", processor.Config{OverrideOriginal: true}, input},
+		{"flat output directory", processor.Config{OutputDir: "out"}, filepath.Join("out", "IMG-20240501-WA0001.jpg")},
+		{"output directory is the input", processor.Config{OutputDir: "media"}, filepath.Join("media", "Sent", "IMG-20240501-WA0001_modified.jpg")},
+		{
+			"organize by date",
+			processor.Config{OutputDir: "out", OutputTemplate: "{out}/{year}/{month}/{name}{ext}"},
+			filepath.Join("out", "2024", "05", "IMG-20240501-WA0001.jpg"),
+		},
+		{
+			"keep relative directories",
+			processor.Config{OutputDir: "out", OutputTemplate: "{out}/{rel}/{date}_{name}{ext}"},
+			filepath.Join("out", "Sent", "2024-05-01_IMG-20240501-WA0001.jpg"),
+		},
+		{
+			"suffix in place without -out",
+			processor.Config{OutputTemplate: "{out}/{name}_fixed{ext}"},
+			filepath.Join("media", "Sent", "IMG-20240501-WA0001_fixed.jpg"),
+		},
+		{
+			"content-addressed names",
+			processor.Config{OutputDir: "out", OutputTemplate: processor.TemplateRenameHash},
+			filepath.Join("out", "2024", "05", "2024-05-01_"+hex.EncodeToString(sum[:])[:12]+".jpg"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := processor.NewMemFS()
+			fsys.WriteFile(input, minimalJPEG(), 0644)
+			config := tt.config
+			config.InputDir = "media"
+			config.DryRun = true
+			r := processor.New(config, processor.WithFS(fsys)).ProcessFiles([]string{input})[0]
+			if r.Error != nil {
+				t.Fatalf("ProcessFiles() error = %v", r.Error)
+			}
+			if r.OutputFile != tt.want {
+				t.Errorf("OutputFile = %s, want %s", r.OutputFile, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessFiles_OutputTemplateCreatesDirectories(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+
+	config := processor.Config{InputDir: ".", OutputDir: "out", OutputTemplate: "{out}/{year}/{name}{ext}"}
+	r := processor.New(config, processor.WithFS(fsys)).ProcessFiles([]string{"IMG-20240501-WA0001.jpg"})[0]
+	if r.Error != nil {
+		t.Fatalf("ProcessFiles() error = %v", r.Error)
+	}
+	if _, err := fsys.Stat(filepath.Join("out", "2024", "IMG-20240501-WA0001.jpg")); err != nil {
+		t.Errorf("output not written: %v", err)
+	}
+	if _, err := fsys.Stat("IMG-20240501-WA0001.jpg"); err != nil {
+		t.Errorf("original should be kept: %v", err)
+	}
+}
+
+func TestProcessFiles_OutputTemplateOntoOriginal(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+
+	config := processor.Config{InputDir: ".", DryRun: true, OutputTemplate: "{dir}/{name}{ext}"}
+	r := processor.New(config, processor.WithFS(fsys)).ProcessFiles([]string{"IMG-20240501-WA0001.jpg"})[0]
+	if r.Error == nil || !strings.Contains(r.Error.Error(), "onto itself") {
+		t.Errorf("ProcessFiles() error = %v, want a refusal to overwrite the original", r.Error)
+	}
+
+	config.OverrideOriginal = true
+	r = processor.New(config, processor.WithFS(fsys)).ProcessFiles([]string{"IMG-20240501-WA0001.jpg"})[0]
+	if r.Error != nil || r.OutputFile != "IMG-20240501-WA0001.jpg" {
+		t.Errorf("ProcessFiles() = %s, %v, want the original edited in place", r.OutputFile, r.Error)
+	}
+}
+
+func TestProcessFiles_OutputCollisions(t *testing.T) {
+	inputs := []string{
+		filepath.Join("media", "a", "IMG-20240501-WA0001.jpg"),
+		filepath.Join("media", "b", "IMG-20240501-WA0001.jpg"),
+	}
+	sum := sha256.Sum256(minimalJPEG())
+	hashed := filepath.Join("out", "2024", "05", "2024-05-01_"+hex.EncodeToString(sum[:])[:12]+".jpg")
+
+	run := func(template string) []processor.ProcessResult {
+		fsys := processor.NewMemFS()
+		for _, input := range inputs {
+			fsys.WriteFile(input, minimalJPEG(), 0644)
+		}
+		config := processor.Config{InputDir: "media", OutputDir: "out", OutputTemplate: template}
+		return processor.New(config, processor.WithFS(fsys), processor.WithConcurrency(2)).ProcessFiles(inputs)
+	}
+
+	// Identical files with content-addressed names: one is written, the
+	// other is a duplicate of it
+	written, skipped := 0, 0
+	for _, r := range run(processor.TemplateRenameHash) {
+		switch {
+		case r.Success && r.OutputFile == hashed:
+			written++
+		case r.Skipped && strings.HasPrefix(r.SkipReason, processor.SkipReasonDuplicateOutput):
+			skipped++
+		default:
+			t.Errorf("%s: unexpected result %+v", r.InputFile, r)
+		}
+	}
+	if written != 1 || skipped != 1 {
+		t.Errorf("hash names: %d written, %d skipped, want 1 and 1", written, skipped)
+	}
+
+	// Names that collide otherwise get a suffix
+	outputs := map[string]bool{}
+	for _, r := range run("{out}/{name}{ext}") {
+		if !r.Success {
+			t.Fatalf("%s: ProcessFiles() error = %v", r.InputFile, r.Error)
+		}
+		outputs[r.OutputFile] = true
+	}
+	for _, want := range []string{filepath.Join("out", "IMG-20240501-WA0001.jpg"), filepath.Join("out", "IMG-20240501-WA0001_2.jpg")} {
+		if !outputs[want] {
+			t.Errorf("outputs %v, want %s", outputs, want)
+		}
+	}
+}
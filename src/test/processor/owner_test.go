@@ -0,0 +1,137 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package processor_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// ownerOf returns the uid and gid of path
+func ownerOf(t *testing.T, path string) (int, int) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	return int(st.Uid), int(st.Gid)
+}
+
+func TestParseOwner(t *testing.T) {
+	tests := []struct {
+		spec string
+		want *processor.Owner
+	}{
+		{"", nil},
+		{"1000:100", &processor.Owner{UID: 1000, GID: 100}},
+		{"1000", &processor.Owner{UID: 1000, GID: -1}},
+		{":100", &processor.Owner{UID: -1, GID: 100}},
+		{"root:0", &processor.Owner{UID: 0, GID: 0}},
+	}
+	for _, tt := range tests {
+		got, err := processor.ParseOwner(tt.spec)
+		if err != nil {
+			t.Errorf("ParseOwner(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ParseOwner(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+	for _, spec := range []string{":", "-1:0", "no-such-user-wappd:0", "0:no-such-group-wappd"} {
+		if _, err := processor.ParseOwner(spec); err == nil {
+			t.Errorf("ParseOwner(%q) should fail", spec)
+		}
+	}
+}
+
+func TestProcessFiles_CopyKeepsPermissions(t *testing.T) {
+	defer syscall.Umask(syscall.Umask(022))
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	os.WriteFile(input, minimalJPEG(), 0644)
+	os.Chmod(input, 0664)
+
+	config := processor.Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out")}
+	r := processor.New(config).ProcessFiles([]string{input})[0]
+	if r.Error != nil {
+		t.Fatalf("ProcessFiles() error = %v", r.Error)
+	}
+	info, _ := os.Stat(r.OutputFile)
+	if info.Mode().Perm() != 0664 {
+		t.Errorf("output mode = %v, want -rw-rw-r--", info.Mode().Perm())
+	}
+}
+
+func TestProcessFiles_CopyKeepsOwner(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("giving files away needs root")
+	}
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	os.WriteFile(input, minimalJPEG(), 0644)
+	os.Chown(input, 4242, 4343)
+
+	config := processor.Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out")}
+	r := processor.New(config).ProcessFiles([]string{input})[0]
+	if r.Error != nil {
+		t.Fatalf("ProcessFiles() error = %v", r.Error)
+	}
+	if uid, gid := ownerOf(t, r.OutputFile); uid != 4242 || gid != 4343 {
+		t.Errorf("output owner = %d:%d, want 4242:4343", uid, gid)
+	}
+}
+
+func TestProcessFiles_Chown(t *testing.T) {
+	// Without root, files can only be "given" to their own user
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 4242, 4343
+	}
+	for _, transaction := range []bool{false, true} {
+		t.Run(fmt.Sprintf("transaction=%v", transaction), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			input := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+			os.WriteFile(input, minimalJPEG(), 0644)
+			output := filepath.Join(tmpDir, "out")
+
+			config := processor.Config{
+				InputDir:       tmpDir,
+				OutputDir:      output,
+				OutputTemplate: "{out}/{year}/{name}{ext}",
+				Chown:          fmt.Sprintf("%d:%d", uid, gid),
+			}
+			var opts []processor.Option
+			var tx *processor.Transaction
+			if transaction {
+				var err error
+				if tx, err = processor.NewTransaction(processor.OSFS, filepath.Join(tmpDir, "staging")); err != nil {
+					t.Fatalf("NewTransaction() error = %v", err)
+				}
+				owner, _ := processor.ParseOwner(config.Chown)
+				tx.SetOwner(owner)
+				opts = append(opts, processor.WithTransaction(tx))
+			}
+			r := processor.New(config, opts...).ProcessFiles([]string{input})[0]
+			if r.Error != nil {
+				t.Fatalf("ProcessFiles() error = %v", r.Error)
+			}
+			if tx != nil {
+				if _, err := tx.Commit(); err != nil {
+					t.Fatalf("Commit() error = %v", err)
+				}
+			}
+			for _, path := range []string{output, filepath.Join(output, "2024"), r.OutputFile} {
+				if u, g := ownerOf(t, path); u != uid || g != gid {
+					t.Errorf("%s owner = %d:%d, want %d:%d", path, u, g, uid, gid)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// curatedRegions is the face region data a photo manager leaves in XMP
+const curatedRegions = `<mwg-rs:Regions rdf:parseType="Resource">
+    <mwg-rs:RegionList><rdf:Bag><rdf:li rdf:parseType="Resource">
+     <mwg-rs:Name>Grandma</mwg-rs:Name><mwg-rs:Type>Face</mwg-rs:Type>
+     <mwg-rs:Area stArea:x="0.41" stArea:y="0.32" stArea:w="0.12" stArea:h="0.18" stArea:unit="normalized"/>
+    </rdf:li></rdf:Bag></mwg-rs:RegionList>
+   </mwg-rs:Regions>`
+
+// curatedXMP returns an XMP packet with face regions, a rating and, when
+// subject is set, a dc:subject bag, padded like writers leave them
+func curatedXMP(subject bool) []byte {
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\"")
+	b.WriteString(" xmlns:mwg-rs=\"http://www.metadataworkinggroup.com/schemas/regions/\" xmlns:stArea=\"http://ns.adobe.com/xmp/sType/Area#\">\n")
+	b.WriteString("   <xmp:Rating>4</xmp:Rating>\n   " + curatedRegions + "\n")
+	if subject {
+		b.WriteString("   <dc:subject><rdf:Bag><rdf:li>family</rdf:li></rdf:Bag></dc:subject>\n")
+	}
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(strings.Repeat(" ", 2048) + "\n<?xpacket end=\"w\"?>")
+	return []byte(b.String())
+}
+
+// subjects returns the dc:subject keywords of an XMP packet
+func subjects(t *testing.T, packet []byte) []string {
+	t.Helper()
+	var doc struct {
+		Descriptions []struct {
+			Subject []string `xml:"subject>Bag>li"`
+		} `xml:"RDF>Description"`
+	}
+	if err := xml.Unmarshal(bytes.TrimSuffix(bytes.TrimSpace(packet[bytes.Index(packet, []byte("<x:xmpmeta")):]), []byte(`<?xpacket end="w"?>`)), &doc); err != nil {
+		t.Fatalf("merged packet isn't well-formed: %v\n%s", err, packet)
+	}
+	var all []string
+	for _, d := range doc.Descriptions {
+		all = append(all, d.Subject...)
+	}
+	return all
+}
+
+func TestMergeXMPKeywords(t *testing.T) {
+	for _, subject := range []bool{true, false} {
+		packet := curatedXMP(subject)
+		merged, err := processor.MergeXMPKeywords(packet, []string{"family", "whatsapp"})
+		if err != nil {
+			t.Fatalf("MergeXMPKeywords(subject %v) error = %v", subject, err)
+		}
+		got := strings.Join(subjects(t, merged), ",")
+		if want := "family,whatsapp"; got != want {
+			t.Errorf("subject %v: keywords = %q, want %q", subject, got, want)
+		}
+		for _, kept := range []string{curatedRegions, "<xmp:Rating>4</xmp:Rating>"} {
+			if !bytes.Contains(merged, []byte(kept)) {
+				t.Errorf("subject %v: curated data lost:\n%s", subject, merged)
+			}
+		}
+		if len(merged) != len(packet) {
+			t.Errorf("subject %v: packet grew from %d to %d bytes despite its padding", subject, len(packet), len(merged))
+		}
+
+		// Keywords already there change nothing
+		again, err := processor.MergeXMPKeywords(merged, []string{"whatsapp"})
+		if err != nil || !bytes.Equal(again, merged) {
+			t.Errorf("subject %v: merging present keywords changed the packet (%v)", subject, err)
+		}
+	}
+
+	if _, err := processor.MergeXMPKeywords([]byte(`<x:xmpmeta><rdf:RDF><rdf:Description><dc:subject>odd</dc:subject></rdf:Description></rdf:RDF></x:xmpmeta>`), []string{"x"}); err == nil {
+		t.Error("MergeXMPKeywords() merged into a dc:subject without an rdf:Bag")
+	}
+}
+
+func TestProcessFile_PreservesCuratedMetadata(t *testing.T) {
+	// A photo rated in Windows (EXIF Rating) and tagged with faces (XMP)
+	dt := time.Date(2023, 1, 1, 9, 0, 0, 0, time.UTC)
+	home := processor.GPSPoint{Lat: 40.4168, Lon: -3.7038}
+	exif, _ := processor.CreateEXIFSegmentWithOptions(dt, processor.EXIFOptions{Rating: 4, RatingPercent: 75, GPS: &home})
+	data, _ := processor.InsertEXIFSegment(minimalJPEG(), exif)
+	data, _ = processor.InsertXMPSegment(data, curatedXMP(true))
+
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("media/IMG-20240501-WA0001.jpg", data, 0644)
+	config := processor.Config{InputDir: "media", OverrideOriginal: true, OverwriteExif: true, Tags: []string{"whatsapp"}}
+	if r := processor.New(config, processor.WithFS(fsys)).ProcessFile("media/IMG-20240501-WA0001.jpg"); !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+
+	out, _ := fsys.ReadFile("media/IMG-20240501-WA0001.jpg")
+	segments, _ := processor.ParseJPEGSegments(out)
+	_, app1 := processor.FindAPP1Segment(segments)
+	_, xmp := processor.FindXMPSegment(segments)
+	if app1 == nil || xmp == nil {
+		t.Fatal("EXIF or XMP segment missing after processing")
+	}
+	if dates, _ := processor.ReadEXIFDates(app1.Payload); dates["DateTimeOriginal"] != "2024:05:01 00:00:00" {
+		t.Errorf("DateTimeOriginal = %q, want the filename date", dates["DateTimeOriginal"])
+	}
+	if rating, percent, err := processor.ReadEXIFRating(app1.Payload); rating != 4 || percent != 75 || err != nil {
+		t.Errorf("ReadEXIFRating() = %d, %d, %v, want 4, 75", rating, percent, err)
+	}
+	if gps, err := processor.ReadEXIFGPS(app1.Payload); gps == nil || !near(*gps, home.Lat, home.Lon) {
+		t.Errorf("ReadEXIFGPS() = %v, %v, want the recorded position", gps, err)
+	}
+	if !bytes.Contains(xmp.Payload, []byte(curatedRegions)) || !bytes.Contains(xmp.Payload, []byte("<xmp:Rating>4</xmp:Rating>")) {
+		t.Error("XMP face regions or rating were not kept")
+	}
+	if got := strings.Join(subjects(t, xmp.Payload), ","); got != "family,whatsapp" {
+		t.Errorf("keywords = %q, want family,whatsapp", got)
+	}
+}
@@ -0,0 +1,191 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestValidateFilenamePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern processor.FilenamePattern
+		wantErr string
+	}{
+		{"placeholder", processor.FilenamePattern{Pattern: "Screenshot_{date}"}, ""},
+		{"regex", processor.FilenamePattern{Pattern: `^PXL_(?P<date>\d{8})_`, Time: "12:00", Timezone: "Europe/Madrid"}, ""},
+		{"empty", processor.FilenamePattern{}, "must not be empty"},
+		{"no date group", processor.FilenamePattern{Pattern: `PXL_(\d{8})`}, "no {date} placeholder"},
+		{"bad regex", processor.FilenamePattern{Pattern: `PXL_(?P<date>\d{8}`}, "invalid pattern"},
+		{"bad time", processor.FilenamePattern{Pattern: "Screenshot_{date}", Time: "noon"}, "invalid time"},
+		{"bad timezone", processor.FilenamePattern{Pattern: "Screenshot_{date}", Timezone: "Mars/Olympus"}, "invalid timezone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := processor.ValidateFilenamePattern(tt.pattern)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ValidateFilenamePattern() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ValidateFilenamePattern() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessFiles_PatternTimeAndTimezone(t *testing.T) {
+	fsys := processor.NewMemFS()
+	paths := []string{
+		"Screenshot_20240501.jpg", // Custom pattern with time and timezone
+		"PXL_20240502_093000.jpg", // Custom regex, date only
+		"IMG-20240503-WA0001.jpg", // Built-in pattern, global timezone
+		"WhatsApp Image 2024-05-04 at 3.30.45 PM.jpg",
+	}
+	for _, path := range paths {
+		fsys.WriteFile(path, minimalJPEG(), 0644)
+	}
+
+	config := processor.Config{
+		DryRun:   true,
+		Timezone: "America/New_York",
+		Patterns: []processor.FilenamePattern{
+			{Pattern: "Screenshot_{date}", Time: "12:00", Timezone: "Europe/Madrid"},
+			{Pattern: `^PXL_(?P<date>\d{8})_`, Time: "08:15:30"},
+		},
+	}
+	results := processor.New(config, processor.WithFS(fsys)).ProcessFiles(paths)
+
+	want := []string{
+		"2024-05-01T12:00:00+02:00",
+		"2024-05-02T08:15:30-04:00",
+		"2024-05-03T00:00:00-04:00",
+		"2024-05-04T15:30:45-04:00",
+	}
+	for i, r := range results {
+		if r.Error != nil {
+			t.Fatalf("%s: error = %v", r.InputFile, r.Error)
+		}
+		if got := r.DateTime.Format(time.RFC3339); got != want[i] {
+			t.Errorf("%s: date = %s, want %s", r.InputFile, got, want[i])
+		}
+	}
+}
+
+func TestProcessFiles_PatternOverridesBuiltin(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+
+	config := processor.Config{
+		DryRun:   true,
+		Patterns: []processor.FilenamePattern{{Pattern: "IMG-{date}-WA", Time: "12:00"}},
+	}
+	p := processor.New(config, processor.WithFS(fsys))
+	if date, err := p.FilenameDate("IMG-20240501-WA0001.jpg"); err != nil || date != "2024-05-01T12:00:00" {
+		t.Errorf("FilenameDate() = %q, %v, want 2024-05-01T12:00:00", date, err)
+	}
+	r := p.ProcessFiles([]string{"IMG-20240501-WA0001.jpg"})[0]
+	if got := r.DateTime.Format(time.RFC3339); got != "2024-05-01T12:00:00Z" {
+		t.Errorf("date = %s, want 2024-05-01T12:00:00Z", got)
+	}
+}
+
+func TestFilenameDate_ExtraPatterns(t *testing.T) {
+	camera := processor.New(processor.Config{ExtraPatterns: []string{processor.ExtraPatternsCamera}})
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"IMG_20240501_123045.jpg", "2024-05-01T12:30:45"},
+		{"VID_20240501_080000.mp4", "2024-05-01T08:00:00"},
+		{"PXL_20240501_123045123.jpg", "2024-05-01T12:30:45"},
+		{"IMG_20240501_123045_1.jpg", "2024-05-01T12:30:45"},
+		{"IMG-20240502-WA0001.jpg", "2024-05-02"}, // WhatsApp names still win
+		{"IMG_20240501_256045.jpg", ""},           // Not a time of day
+		{"holiday_IMG_20240501_123045.jpg", ""},
+	}
+	for _, tt := range tests {
+		got, err := camera.FilenameDate(tt.filename)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("FilenameDate(%s) = %q, want no match", tt.filename, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("FilenameDate(%s) = %q, %v, want %s", tt.filename, got, err, tt.want)
+		}
+	}
+
+	// Off by default
+	if _, err := processor.New(processor.Config{}).FilenameDate("IMG_20240501_123045.jpg"); err == nil {
+		t.Error("camera names should only match with the camera pattern set")
+	}
+	if err := processor.ValidateExtraPatterns([]string{"scanner"}); err == nil {
+		t.Error("ValidateExtraPatterns(scanner) should fail")
+	}
+}
+
+func TestFilenameDate_PatternTimeGroup(t *testing.T) {
+	config := processor.Config{Patterns: []processor.FilenamePattern{{Pattern: `^Scan (?P<date>\d{8})(?: (?P<time>\d{6}))?`, Time: "12:00"}}}
+	p := processor.New(config)
+	if date, err := p.FilenameDate("Scan 20240501 093000.jpg"); err != nil || date != "2024-05-01T09:30:00" {
+		t.Errorf("FilenameDate() = %q, %v, want the captured time", date, err)
+	}
+	if date, err := p.FilenameDate("Scan 20240501.jpg"); err != nil || date != "2024-05-01T12:00:00" {
+		t.Errorf("FilenameDate() = %q, %v, want the pattern's time without a captured one", date, err)
+	}
+}
+
+func TestProcessFiles_InvalidPattern(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+
+	config := processor.Config{DryRun: true, Patterns: []processor.FilenamePattern{{Pattern: "no-date"}}}
+	r := processor.New(config, processor.WithFS(fsys)).ProcessFiles([]string{"IMG-20240501-WA0001.jpg"})[0]
+	if r.Error == nil {
+		t.Error("ProcessFiles() should fail with an invalid pattern")
+	}
+}
+
+func TestLoadConfigFile_Patterns(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "wappd.json")
+	content := `{"patterns": [
+		{"pattern": "Screenshot_{date}", "time": "12:00", "timezone": "Europe/Madrid"},
+		{"patern": "x"},
+		{"pattern": "PXL_(\\d{8})"}
+	]}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	_, err := processor.LoadConfigFileFromPath(configPath)
+	if err == nil {
+		t.Fatal("LoadConfigFileFromPath() should reject the patterns")
+	}
+	for _, want := range []string{
+		`"patterns[1]": missing "pattern"`,
+		`unknown key "patterns[1].patern" (did you mean "pattern"?)`,
+		`"patterns[2]": pattern "PXL_(\\d{8})" has no {date} placeholder`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadConfigFileFromPath() error = %v, want it to mention %s", err, want)
+		}
+	}
+
+	content = `{"patterns": [{"pattern": "Screenshot_{date}", "time": "12:00"}]}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	fileConfig, err := processor.LoadConfigFileFromPath(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfigFileFromPath() error = %v", err)
+	}
+	config := processor.MergeConfig(fileConfig, processor.Config{})
+	if len(config.Patterns) != 1 || config.Patterns[0].Time != "12:00" {
+		t.Errorf("MergeConfig() patterns = %+v", config.Patterns)
+	}
+}
@@ -0,0 +1,133 @@
+package processor_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// minimalPDF builds a one-page PDF with a classic xref table. When info is
+// non-empty it becomes object 4 and is referenced from the trailer.
+func minimalPDF(info string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	}
+	if info != "" {
+		objects = append(objects, info)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R", len(objects)+1)
+	if info != "" {
+		buf.WriteString(" /Info 4 0 R")
+	}
+	fmt.Fprintf(&buf, " >>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+func TestFormatPDFDate(t *testing.T) {
+	utc := time.Date(2024, 5, 1, 10, 15, 30, 0, time.UTC)
+	if got := processor.FormatPDFDate(utc); got != "D:20240501101530Z" {
+		t.Errorf("FormatPDFDate(UTC) = %q", got)
+	}
+	local := time.Date(2024, 5, 1, 10, 15, 30, 0, time.FixedZone("", -(5*3600+30*60)))
+	if got := processor.FormatPDFDate(local); got != "D:20240501101530-05'30'" {
+		t.Errorf("FormatPDFDate(-05:30) = %q", got)
+	}
+}
+
+func TestUpdatePDFCreationDate_AddsInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "DOC-20240501-WA0004.pdf")
+	original := minimalPDF("")
+	os.WriteFile(path, original, 0644)
+
+	dt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	updated, err := processor.UpdatePDFCreationDate(path, dt, false)
+	if err != nil || !updated {
+		t.Fatalf("UpdatePDFCreationDate() = %v, %v, want true, nil", updated, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(data, original) {
+		t.Error("incremental update modified the original bytes")
+	}
+	got, err := processor.ReadPDFCreationDate(data)
+	if err != nil {
+		t.Fatalf("ReadPDFCreationDate() error = %v", err)
+	}
+	if got != "D:20240501000000Z" {
+		t.Errorf("CreationDate = %q, want D:20240501000000Z", got)
+	}
+	if !bytes.Contains(data, []byte("/Root 1 0 R")) || !bytes.Contains(data, []byte(fmt.Sprintf("/Prev %d", bytes.Index(original, []byte("xref"))))) {
+		t.Error("new trailer does not carry /Root and /Prev forward")
+	}
+}
+
+func TestUpdatePDFCreationDate_ExistingDate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "DOC-20240501-WA0004.pdf")
+	os.WriteFile(path, minimalPDF("<< /Producer (Scanner) /CreationDate (D:20200101000000Z) >>"), 0644)
+
+	dt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if updated, _ := processor.UpdatePDFCreationDate(path, dt, false); updated {
+		t.Error("UpdatePDFCreationDate() replaced an existing date without overwrite")
+	}
+
+	if updated, err := processor.UpdatePDFCreationDate(path, dt, true); err != nil || !updated {
+		t.Fatalf("UpdatePDFCreationDate(overwrite) = %v, %v", updated, err)
+	}
+	data, _ := os.ReadFile(path)
+	if got, _ := processor.ReadPDFCreationDate(data); got != "D:20240501000000Z" {
+		t.Errorf("CreationDate = %q, want D:20240501000000Z", got)
+	}
+	if !bytes.Contains(data[bytes.LastIndex(data, []byte("4 0 obj")):], []byte("/Producer (Scanner)")) {
+		t.Error("other Info entries were not preserved")
+	}
+}
+
+func TestProcessFile_IncludeDocumentsSetsModTime(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "DOC-20240501-WA0004.pdf")
+	os.WriteFile(path, minimalPDF(""), 0644)
+
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, IncludeDocuments: true})
+	if r := proc.ProcessFile(path); !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+
+	info, _ := os.Stat(path)
+	if want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC); !info.ModTime().Equal(want) {
+		t.Errorf("ModTime = %s, want %s", info.ModTime(), want)
+	}
+}
+
+func TestGetMediaFiles_Documents(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"IMG-20240501-WA0001.jpg", "DOC-20240501-WA0004.pdf", "DOC-20240501-WA0005.docx"} {
+		os.WriteFile(filepath.Join(tmpDir, name), []byte("test content"), 0644)
+	}
+
+	if files, _ := processor.GetMediaFiles(tmpDir, false); len(files) != 1 {
+		t.Errorf("GetMediaFiles(false) returned %d files, want 1", len(files))
+	}
+	if files, _ := processor.GetMediaFiles(tmpDir, true); len(files) != 3 {
+		t.Errorf("GetMediaFiles(true) returned %d files, want 3", len(files))
+	}
+}
@@ -0,0 +1,179 @@
+package processor_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestLibraryAssetsFromResults(t *testing.T) {
+	dt := time.Date(2025, 1, 22, 0, 0, 0, 0, time.UTC)
+	results := []processor.ProcessResult{
+		{InputFile: "a.jpg", OutputFile: "out/a.jpg", Success: true, DateTime: dt},
+		{InputFile: "b.jpg", Success: false},
+	}
+
+	assets := processor.LibraryAssetsFromResults(results)
+	if len(assets) != 1 || assets[0].File != "out/a.jpg" || !assets[0].DateTime.Equal(dt) {
+		t.Errorf("LibraryAssetsFromResults() = %+v", assets)
+	}
+}
+
+func TestImmichClient_UploadAssets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/assets" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "secret" {
+			t.Errorf("x-api-key = %q, want secret", r.Header.Get("x-api-key"))
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm() error = %v", err)
+		}
+		if got := r.FormValue("fileCreatedAt"); got != "2025-01-22T15:30:45Z" {
+			t.Errorf("fileCreatedAt = %s, want 2025-01-22T15:30:45Z", got)
+		}
+		if got := r.FormValue("deviceId"); got != "wappd" {
+			t.Errorf("deviceId = %s, want wappd", got)
+		}
+		f, header, err := r.FormFile("assetData")
+		if err != nil {
+			t.Fatalf("missing assetData: %v", err)
+		}
+		data, _ := io.ReadAll(f)
+		if header.Filename != "IMG-20250122-WA0003.jpg" || string(data) != "jpeg" {
+			t.Errorf("assetData = %s (%q)", header.Filename, data)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc-123","status":"created"}`))
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "IMG-20250122-WA0003.jpg")
+	os.WriteFile(file, []byte("jpeg"), 0644)
+
+	client := processor.NewImmichClient(server.URL+"/", "secret")
+	results := client.UploadAssets([]processor.LibraryAsset{
+		{File: file, DateTime: time.Date(2025, 1, 22, 15, 30, 45, 0, time.UTC)},
+	})
+
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("UploadAssets() = %+v", results)
+	}
+	if results[0].URI != server.URL+"/photos/abc-123" {
+		t.Errorf("UploadAssets() URI = %s", results[0].URI)
+	}
+}
+
+func TestPhotoPrismClient_UploadAssets(t *testing.T) {
+	var uploads, imports int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		switch r.Method {
+		case http.MethodPost:
+			if _, _, err := r.FormFile("files"); err != nil {
+				t.Errorf("missing files part: %v", err)
+			}
+			uploads++
+		case http.MethodPut:
+			imports++
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	var assets []processor.LibraryAsset
+	for _, name := range []string{"IMG-20250122-WA0003.jpg", "VID-20240415-WA0010.mp4"} {
+		file := filepath.Join(tmpDir, name)
+		os.WriteFile(file, []byte(name), 0644)
+		assets = append(assets, processor.LibraryAsset{File: file})
+	}
+
+	client := processor.NewPhotoPrismClient(server.URL, "token", "uqxyz")
+	for _, r := range client.UploadAssets(assets) {
+		if r.Error != nil {
+			t.Errorf("UploadAssets() %s error = %v", r.File, r.Error)
+		}
+	}
+	if uploads != 2 || imports != 1 {
+		t.Errorf("uploads = %d, imports = %d, want 2 and 1", uploads, imports)
+	}
+}
+
+func TestPhotoPrismClient_ImportFailureMarksUploads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			http.Error(w, "import failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "IMG-20250122-WA0003.jpg")
+	os.WriteFile(file, []byte("jpeg"), 0644)
+
+	client := processor.NewPhotoPrismClient(server.URL, "token", "uqxyz")
+	results := client.UploadAssets([]processor.LibraryAsset{{File: file}})
+	if results[0].Error == nil {
+		t.Error("UploadAssets() should report the import failure for uploaded files")
+	}
+}
+
+func TestImmichClient_StreamsLargeFiles(t *testing.T) {
+	const size = 64 << 20
+	var received int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength <= size || len(r.TransferEncoding) > 0 {
+			t.Errorf("Content-Length = %d, transfer encoding %v; want the full length up front", r.ContentLength, r.TransferEncoding)
+		}
+		mr, err := r.MultipartReader()
+		if err != nil {
+			t.Fatalf("MultipartReader() error = %v", err)
+		}
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FormName() == "assetData" {
+				received, _ = io.Copy(io.Discard, part)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"big"}`))
+	}))
+	defer server.Close()
+
+	// A sparse file: reading it costs no memory unless the upload copies it
+	file := filepath.Join(t.TempDir(), "VID-20250122-WA0001.mp4")
+	f, _ := os.Create(file)
+	f.Truncate(size)
+	f.Close()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	results := processor.NewImmichClient(server.URL, "secret").UploadAssets([]processor.LibraryAsset{{File: file, DateTime: time.Now()}})
+	runtime.ReadMemStats(&after)
+
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("UploadAssets() = %+v", results)
+	}
+	if received != size {
+		t.Errorf("server received %d bytes of the file, want %d", received, size)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Errorf("upload allocated %d bytes for a %d-byte file, want it streamed", allocated, size)
+	}
+}
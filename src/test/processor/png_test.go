@@ -0,0 +1,86 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// pngWithChunks returns a PNG signature, IHDR, the given chunks (type then
+// data, alternating), IDAT and IEND
+func pngWithChunks(chunks ...string) []byte {
+	data := []byte("\x89PNG\r\n\x1a\n")
+	add := func(chunkType, payload string) {
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(len(payload)))
+		data = append(data, header...)
+		body := []byte(chunkType + payload)
+		data = append(data, body...)
+		data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(body))
+	}
+	add("IHDR", "\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00")
+	for i := 0; i+1 < len(chunks); i += 2 {
+		add(chunks[i], chunks[i+1])
+	}
+	add("IDAT", "\x78\x9c\x63\x60\x60\x60\x00\x00\x00\x04\x00\x01")
+	add("IEND", "")
+	return data
+}
+
+func TestReadPNGDates(t *testing.T) {
+	exif, _ := processor.CreateEXIFSegment(time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC))
+	tiff := string(bytes.TrimPrefix(exif, []byte("Exif\x00\x00")))
+
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []processor.MetadataDate
+	}{
+		{"none", nil, nil},
+		{"eXIf", []string{"eXIf", tiff}, []processor.MetadataDate{{Source: "EXIF DateTimeOriginal", Value: "2024:05:01 10:30:00"}}},
+		{"tEXt RFC 1123", []string{"tEXt", "Creation Time\x00Wed, 01 May 2024 10:30:00 GMT"}, []processor.MetadataDate{{Source: "PNG Creation Time", Value: "2024-05-01T10:30:00Z"}}},
+		{"tEXt exiftool", []string{"tEXt", "Creation Time\x002024:05:01 10:30:00"}, []processor.MetadataDate{{Source: "PNG Creation Time", Value: "2024:05:01 10:30:00"}}},
+		{"other tEXt", []string{"tEXt", "Software\x00wappd"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := processor.ReadPNGDates(pngWithChunks(tt.chunks...))
+			if err != nil {
+				t.Fatalf("ReadPNGDates() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadPNGDates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPNGDates_Invalid(t *testing.T) {
+	if _, err := processor.ReadPNGDates(minimalJPEG()); err == nil {
+		t.Error("ReadPNGDates(JPEG) should fail")
+	}
+	truncated := pngWithChunks("tEXt", "Creation Time\x002024:05:01 10:30:00")
+	if _, err := processor.ReadPNGDates(truncated[:45]); err == nil || !strings.Contains(err.Error(), "extends beyond file") {
+		t.Errorf("ReadPNGDates(truncated) error = %v, want chunk beyond file", err)
+	}
+}
+
+func TestListDates_PNG(t *testing.T) {
+	exif, _ := processor.CreateEXIFSegment(time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC))
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240502-WA0001.png", pngWithChunks("eXIf", string(exif[6:])), 0644)
+
+	l, err := processor.New(processor.Config{}, processor.WithFS(fsys)).ListDates("IMG-20240502-WA0001.png")
+	if err != nil {
+		t.Fatalf("ListDates() error = %v", err)
+	}
+	if l.EmbeddedDate != "2024:05:01 10:30:00" || l.EmbeddedSource != "EXIF DateTimeOriginal" || !l.Mismatch {
+		t.Errorf("ListDates() = %+v, want the eXIf date, a day off the name", l)
+	}
+}
@@ -0,0 +1,41 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestCheckOutputFS(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// An output directory yet to be created is checked through its parent
+	h := processor.CheckOutputFS(filepath.Join(tmpDir, "out", "2024"))
+	if h.Dir != tmpDir || !h.Writable {
+		t.Fatalf("CheckOutputFS() = %+v, want a writable %s", h, tmpDir)
+	}
+	if err := h.Check(10); err != nil {
+		t.Errorf("Check(10) error = %v", err)
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Errorf("CheckOutputFS() left %d file(s) behind", len(entries))
+	}
+	if h.FreeInodes >= 0 {
+		if err := h.Check(int(h.FreeInodes) + 1); err == nil || !strings.Contains(err.Error(), "inodes") {
+			t.Errorf("Check() beyond the free inodes error = %v, want inodes exhausted", err)
+		}
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	readOnly := filepath.Join(tmpDir, "ro")
+	os.Mkdir(readOnly, 0555)
+	h = processor.CheckOutputFS(readOnly)
+	if err := h.Check(1); h.Writable || err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Errorf("Check() of a read-only directory = %v, want not writable", err)
+	}
+}
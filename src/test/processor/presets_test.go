@@ -0,0 +1,93 @@
+package processor_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestPresetNames(t *testing.T) {
+	got := strings.Join(processor.PresetNames(), ",")
+	if got != "aggressive,archive,safe" {
+		t.Errorf("PresetNames() = %s, want aggressive,archive,safe", got)
+	}
+}
+
+func TestPresets_Valid(t *testing.T) {
+	for _, name := range processor.PresetNames() {
+		preset, err := processor.Preset(name)
+		if err != nil {
+			t.Fatalf("Preset(%s) error = %v", name, err)
+		}
+		if problems := processor.ValidateConfigFile(preset); len(problems) > 0 {
+			t.Errorf("preset %s: %v", name, problems)
+		}
+	}
+}
+
+func TestPreset_Unknown(t *testing.T) {
+	_, err := processor.ApplyPreset(nil, "yolo")
+	if err == nil || !strings.Contains(err.Error(), "available: aggressive, archive, safe") {
+		t.Errorf("ApplyPreset(yolo) error = %v, want the available presets", err)
+	}
+}
+
+func TestApplyPreset(t *testing.T) {
+	// Without a config file the preset alone applies
+	config, err := processor.ApplyPreset(nil, "aggressive")
+	if err != nil {
+		t.Fatalf("ApplyPreset() error = %v", err)
+	}
+	merged := processor.MergeConfig(config, processor.Config{})
+	if !merged.OverrideOriginal || !merged.OverwriteExif || !merged.FixExtensions || merged.Backend != processor.BackendAuto {
+		t.Errorf("aggressive preset = %+v", merged)
+	}
+
+	// The config file wins over the preset, flags over both
+	off := false
+	file := &processor.ConfigFile{
+		OverwriteExif: &off,
+		Targets:       []processor.Target{{Dir: "media"}},
+		Profiles:      map[string]processor.ConfigFile{"quick": {}},
+	}
+	config, err = processor.ApplyPreset(file, "aggressive")
+	if err != nil {
+		t.Fatalf("ApplyPreset() error = %v", err)
+	}
+	if len(config.Targets) != 1 || config.Profiles != nil {
+		t.Errorf("targets = %v, profiles = %v, want the file's targets only", config.Targets, config.Profiles)
+	}
+	merged = processor.MergeConfig(config, processor.Config{Backend: processor.BackendNative})
+	if merged.OverwriteExif {
+		t.Error("preset overrode the config file's overwriteExif")
+	}
+	if !merged.OverrideOriginal {
+		t.Error("preset's overrideOriginal not applied under the config file")
+	}
+	if merged.Backend != processor.BackendNative {
+		t.Errorf("Backend = %q, want the flag's native", merged.Backend)
+	}
+}
+
+func TestSafePreset_StopsAtFirstFailure(t *testing.T) {
+	preset, err := processor.ApplyPreset(nil, "safe")
+	if err != nil {
+		t.Fatalf("ApplyPreset() error = %v", err)
+	}
+	config := processor.MergeConfig(preset, processor.Config{InputDir: "media"})
+	config.SafeMode = false // MemFS paths can't be resolved
+
+	fsys := processor.NewMemFS()
+	files := []string{"media/IMG-20240501-WA0001.jpg", "media/IMG-20240501-WA0002.jpg"}
+	for _, f := range files {
+		fsys.WriteFile(f, []byte("not a jpeg"), 0644)
+	}
+	results := processor.New(config, processor.WithFS(fsys)).ProcessFiles(files)
+	if results[0].Error == nil {
+		t.Fatalf("first file succeeded: %+v", results[0])
+	}
+	if !results[1].Skipped || results[1].SkipReason != processor.SkipReasonAborted {
+		t.Errorf("second file = %+v, want it skipped after the first failure", results[1])
+	}
+}
@@ -53,6 +53,19 @@ func TestExtractDateFromFilename_DefaultPatterns(t *testing.T) {
 			want:     "2024-04-15",
 			wantErr:  false,
 		},
+		// Voice note and audio pattern tests
+		{
+			name:     "WhatsApp voice note pattern",
+			filename: "PTT-20240501-WA0007.opus",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp audio pattern",
+			filename: "AUD-20240501-WA0002.m4a",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
 		// WhatsApp Image with time pattern tests
 		{
 			name:     "WhatsApp Image with time pattern PM",
@@ -140,6 +153,74 @@ func TestExtractDateFromFilename_DefaultPatterns(t *testing.T) {
 			want:     "2025-01-22T15:30:45",
 			wantErr:  false,
 		},
+		// Suffixes appended by gallery apps and file managers
+		{
+			name:     "WhatsApp image edited",
+			filename: "IMG-20240501-WA0012-edited.jpg",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp image bracketed counter",
+			filename: "IMG-20240501-WA0012(1).jpg",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp image spaced counter",
+			filename: "IMG-20240501-WA0012 (2).jpg",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp image tilde counter",
+			filename: "IMG-20240501-WA0012~2.jpg",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp video edited with counter",
+			filename: "VID-20240501-WA0004-edited(1).mp4",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp image space-separated counter",
+			filename: "IMG-20240501-WA0000 1.jpg",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		// WhatsApp Business variants
+		{
+			name:     "WhatsApp Business counter",
+			filename: "IMG-20240501-WABusiness0001.jpg",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp Business voice note",
+			filename: "PTT-20240501-WA0001 1.opus",
+			want:     "2024-05-01",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp Business Image with time",
+			filename: "WhatsApp Business Image 2025-01-22 at 3.30.45 PM.jpeg",
+			want:     "2025-01-22T15:30:45",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp Business Video with time",
+			filename: "WhatsApp Business Video 2025-01-22 at 9.05.00 AM.mp4",
+			want:     "2025-01-22T09:05:00",
+			wantErr:  false,
+		},
+		{
+			name:     "WhatsApp Image with time and counter",
+			filename: "WhatsApp Image 2025-01-22 at 3.30.45 PM (1).jpg",
+			want:     "2025-01-22T15:30:45",
+			wantErr:  false,
+		},
 		// Invalid cases
 		{
 			name:     "Invalid filename",
@@ -296,3 +377,29 @@ func TestGetImageVideoFiles_3GP(t *testing.T) {
 		t.Errorf("GetImageVideoFiles() returned %d files, want 4", len(files))
 	}
 }
+
+func TestGetMediaFiles_PathOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Name order within each directory would put a/b.jpg before a.jpg
+	for _, name := range []string{"a0.jpg", "a/b.jpg", "a.jpg", "B.jpg", "a/c/d.jpg", "a/ca.jpg", "a-b.jpg"} {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("test content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	files, err := processor.GetMediaFiles(tmpDir, false)
+	if err != nil {
+		t.Fatalf("GetMediaFiles() error = %v", err)
+	}
+	want := []string{"B.jpg", "a-b.jpg", "a.jpg", "a/b.jpg", "a/c/d.jpg", "a/ca.jpg", "a0.jpg"}
+	if len(files) != len(want) {
+		t.Fatalf("GetMediaFiles() returned %d files, want %d", len(files), len(want))
+	}
+	for i, file := range files {
+		if file != filepath.Join(tmpDir, want[i]) {
+			t.Errorf("files[%d] = %s, want %s", i, file, want[i])
+		}
+	}
+}
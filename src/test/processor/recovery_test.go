@@ -0,0 +1,178 @@
+package processor_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// crashedRun leaves a journal in journalDir listing originals edited in
+// place, unlocked and stale, as a run killed mid-write would
+func crashedRun(t *testing.T, journalDir string, edited ...string) *processor.Journal {
+	t.Helper()
+	journal, err := processor.NewJournal(journalDir)
+	if err != nil {
+		t.Fatalf("NewJournal() error = %v", err)
+	}
+	for _, path := range edited {
+		if err := journal.Edit(path, int64(len(simpleMP4()))); err != nil {
+			t.Fatalf("Edit() error = %v", err)
+		}
+	}
+	journal.Close()
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(journal.Path(), old, old)
+	return journal
+}
+
+func TestJournal_Edit(t *testing.T) {
+	dir := t.TempDir()
+	journal, _ := processor.NewJournal(filepath.Join(dir, "journal"))
+	original := filepath.Join(dir, "VID-20240501-WA0001.mp4")
+
+	journal.Edit(original, 1234)
+	data, _ := os.ReadFile(journal.Path())
+	if want := fmt.Sprintf("pid\t%d\nedit\t1234\t%s\n", os.Getpid(), original); string(data) != want {
+		t.Errorf("journal = %q, want %q", data, want)
+	}
+	journal.Close()
+	if _, err := os.Stat(journal.Path()); err != nil {
+		t.Error("Close() removed a journal with an edit in progress")
+	}
+	journal.Done(original)
+	if err := journal.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestCleanupJournals_KeepsDamagedOriginals(t *testing.T) {
+	dir := t.TempDir()
+	journalDir := filepath.Join(dir, "journal")
+	intact := filepath.Join(dir, "VID-20240501-WA0001.mp4")
+	damaged := filepath.Join(dir, "VID-20240501-WA0002.mp4")
+	os.WriteFile(intact, simpleMP4(), 0644)
+	os.WriteFile(damaged, simpleMP4()[:20], 0644)
+	journal := crashedRun(t, journalDir, intact, damaged)
+
+	if _, err := processor.CleanupJournals(journalDir, processor.DefaultJournalStaleAfter, time.Now()); err != nil {
+		t.Fatalf("CleanupJournals() error = %v", err)
+	}
+	for _, path := range []string{intact, damaged} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("original %s was removed", filepath.Base(path))
+		}
+	}
+	data, _ := os.ReadFile(journal.Path())
+	if strings.Contains(string(data), intact) || !strings.Contains(string(data), damaged) {
+		t.Errorf("journal after cleanup = %q, want only the damaged original", data)
+	}
+
+	found, err := processor.ScanJournals(journalDir, processor.DefaultJournalStaleAfter, time.Now())
+	if err != nil {
+		t.Fatalf("ScanJournals() error = %v", err)
+	}
+	if len(found) != 1 || found[0].Path != damaged || found[0].Problem == nil {
+		t.Fatalf("ScanJournals() = %+v, want %s", found, damaged)
+	}
+
+	// Journals of runs still going aren't scanned, however old
+	liveDir := filepath.Join(dir, "live")
+	live, _ := processor.NewJournal(liveDir)
+	defer live.Close()
+	live.Edit(damaged, int64(len(simpleMP4())))
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(live.Path(), old, old)
+	if found, _ := processor.ScanJournals(liveDir, processor.DefaultJournalStaleAfter, time.Now()); len(found) != 0 {
+		t.Errorf("ScanJournals() of a live journal = %+v", found)
+	}
+}
+
+func TestRestoreFromBackup(t *testing.T) {
+	dir := t.TempDir()
+	journalDir := filepath.Join(dir, "journal")
+	damaged := filepath.Join(dir, "media", "VID-20240501-WA0002.mp4")
+	os.MkdirAll(filepath.Dir(damaged), 0755)
+	os.WriteFile(damaged, simpleMP4()[:20], 0644)
+	journal := crashedRun(t, journalDir, damaged)
+
+	found, _ := processor.ScanJournals(journalDir, processor.DefaultJournalStaleAfter, time.Now())
+	if len(found) != 1 {
+		t.Fatalf("ScanJournals() = %+v", found)
+	}
+
+	// Nothing to restore from
+	backups := filepath.Join(dir, "backup")
+	os.MkdirAll(filepath.Join(backups, "2024"), 0755)
+	os.WriteFile(filepath.Join(backups, "VID-20240501-WA0002.mp4"), []byte("wrong size"), 0644)
+	if _, err := processor.RestoreFromBackup(found[0], backups, false); err == nil || !strings.Contains(err.Error(), "no backup") {
+		t.Errorf("RestoreFromBackup() without a backup error = %v", err)
+	}
+
+	backup := filepath.Join(backups, "2024", "VID-20240501-WA0002.mp4")
+	os.WriteFile(backup, simpleMP4(), 0644)
+	got, err := processor.RestoreFromBackup(found[0], backups, true)
+	if err != nil || got != backup {
+		t.Fatalf("RestoreFromBackup(dry run) = %q, %v, want %s", got, err, backup)
+	}
+	if data, _ := os.ReadFile(damaged); len(data) != 20 {
+		t.Error("dry run restored the file")
+	}
+
+	if _, err := processor.RestoreFromBackup(found[0], backups, false); err != nil {
+		t.Fatalf("RestoreFromBackup() error = %v", err)
+	}
+	if data, _ := os.ReadFile(damaged); !bytes.Equal(data, simpleMP4()) {
+		t.Error("damaged file not restored from its backup")
+	}
+	if _, err := os.Stat(journal.Path()); !os.IsNotExist(err) {
+		t.Error("journal of the repaired run was not removed")
+	}
+}
+
+func TestFindBackup_Ambiguous(t *testing.T) {
+	dir := t.TempDir()
+	d := processor.DamagedFile{JournalEdit: processor.JournalEdit{Path: filepath.Join(dir, "VID-20240501-WA0002.mp4"), Size: int64(len(simpleMP4()))}}
+	other := simpleMP4()
+	other[len(other)-1] ^= 0xFF
+	for i, data := range [][]byte{simpleMP4(), other} {
+		sub := filepath.Join(dir, "backup", string(rune('a'+i)))
+		os.MkdirAll(sub, 0755)
+		os.WriteFile(filepath.Join(sub, "VID-20240501-WA0002.mp4"), data, 0644)
+	}
+	if _, err := processor.FindBackup(d, filepath.Join(dir, "backup")); err == nil || !strings.Contains(err.Error(), "different backups") {
+		t.Errorf("FindBackup() error = %v, want different backups", err)
+	}
+}
+
+func TestProcessFile_JournalsEditInPlace(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "VID-20240501-WA0001.mp4")
+	os.WriteFile(input, simpleMP4(), 0644)
+	journal, _ := processor.NewJournal(filepath.Join(dir, "journal"))
+
+	during := ""
+	proc := processor.New(processor.Config{InputDir: dir, OverrideOriginal: true}, processor.WithJournal(journal),
+		processor.WithStage(processor.StageWrite, func(*processor.FileContext) error {
+			data, _ := os.ReadFile(journal.Path())
+			during = string(data)
+			return nil
+		}))
+	if r := proc.ProcessFile(input); !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+	if !strings.Contains(during, "\nedit\t") || !strings.Contains(during, input) {
+		t.Errorf("journal while writing = %q, want the original as an edit", during)
+	}
+	if err := journal.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(journal.Path()); !os.IsNotExist(err) {
+		t.Error("journal kept after the edit finished")
+	}
+}
@@ -0,0 +1,68 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFiles_DryRunPredictsFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		wantErr string
+	}{
+		{"valid JPEG", "IMG-20240501-WA0001.jpg", minimalJPEG(), ""},
+		{"valid MP4", "VID-20240501-WA0001.mp4", simpleMP4(), ""},
+		{"not a JPEG", "IMG-20240501-WA0002.jpg", []byte("<html>not a photo</html>"), "not a valid JPEG"},
+		{"MP4 without moov", "VID-20240501-WA0002.mp4", append(box("ftyp", []byte("isom"), make([]byte, 4)), box("mdat", make([]byte, 64))...), "moov atom not found"},
+		{"unsupported container", "VID-20240501-WA0003.mp4", []byte("RIFF\x00\x00\x00\x00AVI LIST"), "missing ftyp"},
+	}
+	fsys := processor.NewMemFS()
+	var paths []string
+	for _, tt := range tests {
+		fsys.WriteFile(tt.file, tt.data, 0644)
+		paths = append(paths, tt.file)
+	}
+
+	dryRun := processor.New(processor.Config{InputDir: ".", OverrideOriginal: true, DryRun: true}, processor.WithFS(fsys))
+	real := processor.New(processor.Config{InputDir: ".", OverrideOriginal: true}, processor.WithFS(fsys))
+	predicted := dryRun.ProcessFiles(paths)
+	actual := real.ProcessFiles(paths)
+	for i, tt := range tests {
+		err := predicted[i].Error
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: dry run error = %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: dry run error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if predicted[i].Success != actual[i].Success {
+			t.Errorf("%s: dry run success = %v, real run = %v (%v)", tt.name, predicted[i].Success, actual[i].Success, actual[i].Error)
+		}
+	}
+}
+
+func TestProcessFiles_DryRunChecksLargeVideoHeaders(t *testing.T) {
+	// Under a 1MiB memory limit, videos from 1/3 MiB on are patched in place,
+	// so the dry run only reads their atom headers
+	tmpDir := t.TempDir()
+	good := filepath.Join(tmpDir, "VID-20240501-WA0001.mp4")
+	bad := filepath.Join(tmpDir, "VID-20240501-WA0002.mp4")
+	mdat := box("mdat", make([]byte, 400<<10))
+	os.WriteFile(good, append(simpleMP4(), mdat...), 0644)
+	os.WriteFile(bad, append(box("ftyp", []byte("isom"), make([]byte, 4)), mdat...), 0644)
+
+	config := processor.Config{InputDir: tmpDir, OverrideOriginal: true, DryRun: true, MemoryLimit: "1MiB"}
+	results := processor.New(config).ProcessFiles([]string{good, bad})
+	if !results[0].Success {
+		t.Errorf("large video with a moov: %v, want success", results[0].Error)
+	}
+	if results[1].Success || results[1].Error == nil || !strings.Contains(results[1].Error.Error(), "moov atom not found") {
+		t.Errorf("large video without a moov: %v, want moov atom not found", results[1].Error)
+	}
+}
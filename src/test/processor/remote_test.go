@@ -0,0 +1,124 @@
+package processor_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestIsRemoteSource(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"sftp://phone/DCIM", true},
+		{"SMB://nas/share", true},
+		{"./media", false},
+		{"/backup/sftp://not-a-url", false},
+	}
+	for _, tt := range tests {
+		if got := processor.IsRemoteSource(tt.input); got != tt.want {
+			t.Errorf("IsRemoteSource(%s) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteBaseName(t *testing.T) {
+	if got := processor.RemoteBaseName("sftp://phone/DCIM/"); got != "DCIM" {
+		t.Errorf("RemoteBaseName() = %s, want DCIM", got)
+	}
+	if got := processor.RemoteBaseName("smb://nas"); got != "nas" {
+		t.Errorf("RemoteBaseName() = %s, want nas", got)
+	}
+}
+
+func TestRemoteFetchCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		wantName string
+		wantArgs []string
+		wantErr  bool
+	}{
+		{
+			name:     "SFTP with user and port",
+			source:   "sftp://me@phone:2222/sdcard/DCIM",
+			wantName: "scp",
+			wantArgs: []string{"-r", "-q", "-p", "-P", "2222", "me@phone:/sdcard/DCIM", "/tmp/dest"},
+		},
+		{
+			name:     "SFTP without user",
+			source:   "sftp://phone/DCIM",
+			wantName: "scp",
+			wantArgs: []string{"-r", "-q", "-p", "phone:/DCIM", "/tmp/dest"},
+		},
+		{
+			name:     "SMB share with subdirectory",
+			source:   "smb://me@nas/photos/WhatsApp/Media",
+			wantName: "smbclient",
+			wantArgs: []string{"//nas/photos", "-U", "me", "-c", `prompt OFF; recurse ON; lcd "/tmp/dest"; cd "WhatsApp/Media"; mget *`},
+		},
+		{
+			name:     "SMB guest access",
+			source:   "smb://nas/photos",
+			wantName: "smbclient",
+			wantArgs: []string{"//nas/photos", "-N", "-c", `prompt OFF; recurse ON; lcd "/tmp/dest"; mget *`},
+		},
+		{
+			name:    "SMB path with a command separator",
+			source:  "smb://nas/photos/x;rm%20*",
+			wantErr: true,
+		},
+		{
+			name:    "SMB path with a quote",
+			source:  "smb://nas/photos/a%22b",
+			wantErr: true,
+		},
+		{
+			name:    "SMB without share",
+			source:  "smb://nas",
+			wantErr: true,
+		},
+		{
+			name:    "Missing host",
+			source:  "sftp:///DCIM",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args, err := processor.RemoteFetchCommand(tt.source, "/tmp/dest")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RemoteFetchCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if name != tt.wantName {
+				t.Errorf("RemoteFetchCommand() name = %s, want %s", name, tt.wantName)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("RemoteFetchCommand() args = %q, want %q", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRemoteOrigin(t *testing.T) {
+	tests := []struct {
+		source  string
+		fetched string
+		want    string
+	}{
+		{"sftp://user@phone/DCIM/", "/tmp/fetch/DCIM/Camera/IMG-20240501-WA0001.jpg", "sftp://user@phone/DCIM/Camera/IMG-20240501-WA0001.jpg"},
+		{"sftp://phone/DCIM/IMG-20240501-WA0001.jpg", "/tmp/fetch/IMG-20240501-WA0001.jpg", "sftp://phone/DCIM/IMG-20240501-WA0001.jpg"},
+		{"smb://nas/share/photos", "/tmp/fetch/2024/IMG-20240501-WA0001.jpg", "smb://nas/share/photos/2024/IMG-20240501-WA0001.jpg"},
+	}
+	for _, tt := range tests {
+		if got := processor.RemoteOrigin(tt.source, "/tmp/fetch", tt.fetched); got != tt.want {
+			t.Errorf("RemoteOrigin(%s, %s) = %s, want %s", tt.source, tt.fetched, got, tt.want)
+		}
+	}
+}
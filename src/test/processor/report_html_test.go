@@ -0,0 +1,52 @@
+package processor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	tmpDir := t.TempDir()
+	photo := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	sample, err := os.ReadFile(filepath.Join("testdata", "golden", "input", "IMG-20240501-WA0001.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(photo, sample, 0644)
+	before := time.Date(2024, 6, 1, 9, 0, 0, 0, time.Local)
+
+	results := []processor.ProcessResult{
+		{InputFile: photo, OutputFile: photo, Success: true, DateTime: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local), OriginalModTime: before},
+		{InputFile: filepath.Join(tmpDir, "VID-20240501-WA0002.mp4"), Error: errors.New("moov atom <not> found")},
+		{InputFile: filepath.Join(tmpDir, "STK-20240501-WA0003.webp"), Skipped: true, SkipReason: "sticker"},
+	}
+	path := filepath.Join(tmpDir, "report.html")
+	if err := processor.WriteHTMLReport(path, results, nil, time.Now(), false, nil); err != nil {
+		t.Fatalf("WriteHTMLReport() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	page := string(data)
+
+	for _, want := range []string{
+		`src="data:image/jpeg;base64,`,
+		"Before: 2024-06-01 09:00:00",
+		"After: 2024-05-01 00:00:00",
+		"moov atom &lt;not&gt; found",
+		`class="card skipped"`,
+		"1 updated",
+		"1 failed",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report doesn't contain %q", want)
+		}
+	}
+	if strings.Count(page, "data:image/jpeg") != 1 {
+		t.Error("only the photo should have a thumbnail")
+	}
+}
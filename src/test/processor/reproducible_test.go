@@ -0,0 +1,117 @@
+package processor_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// reproducibleSamples returns one sample of every natively written format,
+// keyed by file name
+func reproducibleSamples(t *testing.T) map[string][]byte {
+	t.Helper()
+	samples := map[string][]byte{
+		"AUD-20240501-WA0005.m4a":  m4aWithMdatAfterMoov(),
+		"PTT-20240501-WA0006.opus": minimalOpus("ENCODER=test"),
+		"DOC-20240501-WA0007.pdf":  minimalPDF("<< /Producer (Scanner) >>"),
+	}
+	inputs, _ := filepath.Glob(filepath.Join("testdata", "golden", "input", "*"))
+	for _, input := range inputs {
+		data, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples[filepath.Base(input)] = data
+	}
+	return samples
+}
+
+// processSamples writes samples to a fresh input directory, processes them
+// to an output directory and returns the output bytes by file name
+func processSamples(t *testing.T, samples map[string][]byte, config processor.Config, opts ...processor.Option) map[string][]byte {
+	t.Helper()
+	tmpDir := t.TempDir()
+	var paths []string
+	for name, data := range samples {
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, data, 0644)
+		paths = append(paths, path)
+	}
+	config.InputDir = tmpDir
+	config.OutputDir = filepath.Join(tmpDir, "out")
+	outputs := map[string][]byte{}
+	for _, r := range processor.New(config, opts...).ProcessFiles(paths) {
+		if !r.Success {
+			t.Fatalf("%s: %v", r.InputFile, r.Error)
+		}
+		data, err := os.ReadFile(r.OutputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs[filepath.Base(r.InputFile)] = data
+	}
+	return outputs
+}
+
+// TestProcessFiles_Reproducible checks that the same inputs give
+// byte-identical outputs whatever the directory, concurrency or time of the
+// run, so checksum-based backups don't see spurious changes
+func TestProcessFiles_Reproducible(t *testing.T) {
+	samples := reproducibleSamples(t)
+	config := processor.Config{
+		OverwriteExif:    true,
+		IncludeDocuments: true,
+		Timezone:         "America/Mexico_City",
+		Tags:             []string{"WhatsApp", "Family"},
+		SoftwareTag:      true,
+	}
+
+	first := processSamples(t, samples, config)
+	later := processor.NewFakeClock(time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC))
+	second := processSamples(t, samples, config, processor.WithConcurrency(4), processor.WithClock(later))
+	for name, want := range first {
+		if !bytes.Equal(second[name], want) {
+			t.Errorf("%s: second run wrote different bytes (%d, want %d)", name, len(second[name]), len(want))
+		}
+	}
+
+	// Processing the outputs again changes nothing, even when overwriting
+	again := processSamples(t, first, config)
+	for name, want := range first {
+		if !bytes.Equal(again[name], want) {
+			t.Errorf("%s: re-run changed the output (%d bytes, want %d)", name, len(again[name]), len(want))
+		}
+	}
+}
+
+// TestUpdateVideoMetadata_MappedMatchesRewrite checks that patching a large
+// video through a memory mapping gives the same bytes as rewriting it whole,
+// as platforms without mmap do
+func TestUpdateVideoMetadata_MappedMatchesRewrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	mapped := filepath.Join(tmpDir, "VID-20240415-WA0012.mp4")
+	writeLargeVideo(t, mapped)
+	original, _ := os.ReadFile(mapped)
+
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("VID-20240415-WA0012.mp4", original, 0644)
+	config := processor.Config{OverrideOriginal: true}
+	rewritten := processor.New(config, processor.WithFS(fsys)).ProcessFile("VID-20240415-WA0012.mp4")
+	if !rewritten.Success {
+		t.Fatalf("ProcessFile(MemFS) error = %v", rewritten.Error)
+	}
+	config.InputDir = tmpDir
+	if r := processor.New(config).ProcessFile(mapped); !r.Success {
+		t.Fatalf("ProcessFile(OSFS) error = %v", r.Error)
+	}
+
+	want, _ := fsys.ReadFile("VID-20240415-WA0012.mp4")
+	got, _ := os.ReadFile(mapped)
+	if !bytes.Equal(got, want) {
+		t.Error("mapped patch and full rewrite wrote different bytes")
+	}
+}
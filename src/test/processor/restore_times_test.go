@@ -0,0 +1,62 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestRestoreModTimes(t *testing.T) {
+	tmpDir := t.TempDir()
+	original := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	inPlace := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	copied := filepath.Join(tmpDir, "IMG-20240502-WA0002.jpg")
+	for _, path := range []string{inPlace, copied} {
+		os.WriteFile(path, minimalJPEG(), 0644)
+		os.Chtimes(path, original, original)
+	}
+
+	// Edit one file in place with -m, copy the other to -out with -m
+	results := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, UpdateModified: true}).ProcessFiles([]string{inPlace})
+	outConfig := processor.Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out"), UpdateModified: true}
+	results = append(results, processor.New(outConfig).ProcessFiles([]string{copied})...)
+	for _, r := range results {
+		if r.Error != nil {
+			t.Fatalf("ProcessFiles() error = %v", r.Error)
+		}
+		if !r.OriginalModTime.Equal(original) {
+			t.Errorf("%s: OriginalModTime = %v, want %v", r.InputFile, r.OriginalModTime, original)
+		}
+	}
+
+	runsDir := filepath.Join(tmpDir, "runs")
+	run, err := processor.NewRun(runsDir, nil, time.Now())
+	if err != nil {
+		t.Fatalf("NewRun() error = %v", err)
+	}
+	run.AddResults(results)
+	record, err := processor.LoadRun(run.Dir())
+	if err != nil {
+		t.Fatalf("LoadRun() error = %v", err)
+	}
+
+	// A dry run changes nothing
+	restored := processor.RestoreModTimes(record, true)
+	if info, _ := os.Stat(inPlace); info.ModTime().Equal(original) {
+		t.Error("dry-run restore changed the modification time")
+	}
+
+	restored = processor.RestoreModTimes(record, false)
+	if len(restored) != 1 || restored[0].File != inPlace || restored[0].Error != nil {
+		t.Fatalf("RestoreModTimes() = %+v, want only the in-place file", restored)
+	}
+	if info, _ := os.Stat(inPlace); !info.ModTime().Equal(original) {
+		t.Errorf("modification time = %v, want %v", info.ModTime(), original)
+	}
+	if info, _ := os.Stat(results[1].OutputFile); info.ModTime().Equal(original) {
+		t.Error("restore changed the copy written to -out")
+	}
+}
@@ -0,0 +1,48 @@
+package processor_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestFormatResult(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	format := `{status}\t{name} -> {output} {date}{error}`
+	tests := []struct {
+		result processor.ProcessResult
+		want   string
+	}{
+		{processor.ProcessResult{InputFile: "in/IMG-20240501-WA0001.jpg", OutputFile: "out/IMG-20240501-WA0001.jpg", Success: true, DateTime: date},
+			"ok\tIMG-20240501-WA0001.jpg -> out/IMG-20240501-WA0001.jpg 2024-05-01 12:30:00"},
+		{processor.ProcessResult{InputFile: "in/a.jpg", OutputFile: "in/a.jpg", Success: true, AlreadyCorrect: true, DateTime: date},
+			"correct\ta.jpg -> in/a.jpg 2024-05-01 12:30:00"},
+		{processor.ProcessResult{InputFile: "in/STK-20240501-WA0001.webp", Skipped: true, SkipReason: "sticker"},
+			"skipped\tSTK-20240501-WA0001.webp ->  sticker"},
+		{processor.ProcessResult{InputFile: "in/photo.jpg", OutputFile: "in/photo.jpg", Error: errors.New("no pattern")},
+			"failed\tphoto.jpg ->  no pattern"},
+	}
+	for _, tt := range tests {
+		if got := processor.FormatResult(format, tt.result); got != tt.want {
+			t.Errorf("FormatResult(%+v) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+
+	// Escapes in the format are expanded, backslashes in values are not
+	windows := processor.ProcessResult{InputFile: `C:\temp\new\IMG-20240501-WA0001.jpg`, Success: true, OutputFile: `C:\temp\new\out.jpg`}
+	if got, want := processor.FormatResult(`{input}\n{output}`, windows), "C:\\temp\\new\\IMG-20240501-WA0001.jpg\nC:\\temp\\new\\out.jpg"; got != want {
+		t.Errorf("FormatResult() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateResultFormat(t *testing.T) {
+	if err := processor.ValidateResultFormat("{status} {input} -> {output} {date} {backend}"); err != nil {
+		t.Errorf("ValidateResultFormat() error = %v", err)
+	}
+	if err := processor.ValidateResultFormat("{status} {size}"); err == nil || !strings.Contains(err.Error(), "{size}") {
+		t.Errorf("ValidateResultFormat({size}) error = %v", err)
+	}
+}
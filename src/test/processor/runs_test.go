@@ -0,0 +1,105 @@
+package processor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestRun_Record(t *testing.T) {
+	runsDir := filepath.Join(t.TempDir(), ".wappd", "runs")
+	started := time.Date(2024, 5, 1, 15, 30, 45, 0, time.UTC)
+
+	first, err := processor.NewRun(runsDir, []string{"-d", "media", "-o"}, started)
+	if err != nil {
+		t.Fatalf("NewRun() error = %v", err)
+	}
+	if first.ID() != "20240501-153045" {
+		t.Errorf("ID() = %s, want 20240501-153045", first.ID())
+	}
+	// A second run started in the same second gets its own directory
+	second, err := processor.NewRun(runsDir, nil, started)
+	if err != nil {
+		t.Fatalf("NewRun() error = %v", err)
+	}
+	if second.ID() != "20240501-153045-2" {
+		t.Errorf("ID() = %s, want 20240501-153045-2", second.ID())
+	}
+
+	first.AddResults([]processor.ProcessResult{
+		{InputFile: "media/IMG-20240501-WA0001.jpg", OutputFile: "media/IMG-20240501-WA0001.jpg", Success: true, DateTime: started},
+		{InputFile: "media/holiday.jpg", Error: errors.New("no default pattern matched filename")},
+		{InputFile: "media/STK-20240501-WA0002.webp", Skipped: true, SkipReason: "sticker"},
+	})
+	if err := first.Finish(started.Add(time.Minute)); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+
+	records, err := processor.ListRuns(runsDir)
+	if err != nil {
+		t.Fatalf("ListRuns() error = %v", err)
+	}
+	if len(records) != 2 || records[0].ID != first.ID() || records[1].ID != second.ID() {
+		t.Fatalf("ListRuns() = %+v, want both runs oldest first", records)
+	}
+	r := records[0]
+	if r.Finished != "2024-05-01T15:31:45Z" || len(r.Args) != 3 {
+		t.Errorf("record = %+v", r)
+	}
+	if success, failed, skipped := r.Counts(); success != 1 || failed != 1 || skipped != 1 {
+		t.Errorf("Counts() = %d, %d, %d, want 1, 1, 1", success, failed, skipped)
+	}
+	if failed := r.Failed(); len(failed) != 1 || failed[0] != "media/holiday.jpg" {
+		t.Errorf("Failed() = %v, want the unmatched file only", failed)
+	}
+	if r.Entries[1].Error == "" || r.Entries[0].Date != "2024-05-01T15:30:45Z" {
+		t.Errorf("entries = %+v", r.Entries)
+	}
+	if records[1].Finished != "" {
+		t.Errorf("unfinished run has Finished = %s", records[1].Finished)
+	}
+}
+
+func TestListRuns_Empty(t *testing.T) {
+	dir := t.TempDir()
+	if records, err := processor.ListRuns(filepath.Join(dir, "missing")); err != nil || len(records) != 0 {
+		t.Errorf("ListRuns() = %v, %v, want no runs", records, err)
+	}
+	// Directories without a report are ignored
+	os.MkdirAll(filepath.Join(dir, "20240501-153045"), 0755)
+	if records, err := processor.ListRuns(dir); err != nil || len(records) != 0 {
+		t.Errorf("ListRuns() = %v, %v, want no runs", records, err)
+	}
+}
+
+func TestNewRun_RedactsSecrets(t *testing.T) {
+	runsDir := t.TempDir()
+	args := []string{"-d", "photos", "-immich-url", "http://immich:2283", "-api-key", "x", "--photoprism-token=hunter2"}
+	run, err := processor.NewRun(runsDir, args, time.Date(2024, 5, 1, 15, 30, 45, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("NewRun() error = %v", err)
+	}
+	report, err := os.ReadFile(filepath.Join(run.Dir(), processor.RunReportName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{`"x"`, "hunter2"} {
+		if strings.Contains(string(report), secret) {
+			t.Errorf("report.json contains %s:\n%s", secret, report)
+		}
+	}
+	record, _ := processor.LoadRun(run.Dir())
+	want := []string{"-d", "photos", "-immich-url", "http://immich:2283", "-api-key", "[redacted]", "--photoprism-token=[redacted]"}
+	if !reflect.DeepEqual(record.Args, want) {
+		t.Errorf("Args = %q, want %q", record.Args, want)
+	}
+	if args[5] != "x" {
+		t.Error("NewRun() changed the arguments it was given")
+	}
+}
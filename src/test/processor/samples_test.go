@@ -0,0 +1,53 @@
+package processor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestGenerateSamples(t *testing.T) {
+	dir := t.TempDir()
+	written, err := processor.GenerateSamples(dir)
+	if err != nil {
+		t.Fatalf("GenerateSamples() error = %v", err)
+	}
+	if len(written) != len(processor.Samples)+1 {
+		t.Fatalf("wrote %d file(s), want the %d samples and %s", len(written), len(processor.Samples), processor.SamplesReadme)
+	}
+	for _, path := range written {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("sample %s: %v", path, err)
+		}
+		if !info.ModTime().Equal(processor.SamplesModTime) {
+			t.Errorf("%s modified %v, want %v", path, info.ModTime(), processor.SamplesModTime)
+		}
+	}
+
+	// Existing samples are never overwritten
+	if _, err := processor.GenerateSamples(dir); err == nil {
+		t.Error("GenerateSamples() over existing samples succeeded, want an error")
+	}
+
+	files, err := processor.GetMediaFiles(dir, false)
+	if err != nil {
+		t.Fatalf("GetMediaFiles() error = %v", err)
+	}
+	results := processor.New(processor.Config{InputDir: dir, OutputDir: filepath.Join(t.TempDir(), "out")}).ProcessFiles(files)
+	failed := map[string]bool{}
+	for _, r := range results {
+		if !r.Success {
+			if !errors.Is(r.Error, processor.ErrNoPatternMatch) {
+				t.Errorf("%s: %v", r.InputFile, r.Error)
+			}
+			failed[filepath.Base(r.InputFile)] = true
+		}
+	}
+	if len(results) != len(processor.Samples) || len(failed) != 2 || !failed["holiday.jpg"] || !failed["IMG_20240505_143012.jpg"] {
+		t.Errorf("processed %d sample(s), unmatched %v; want all %d, with only the two undated names failing", len(results), failed, len(processor.Samples))
+	}
+}
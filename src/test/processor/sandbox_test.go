@@ -0,0 +1,104 @@
+package processor_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// symlink links newname to oldname, skipping the test where that isn't allowed
+func symlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
+
+func TestProcessFiles_SafeModeSymlinkedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "media")
+	elsewhere := filepath.Join(tmpDir, "elsewhere")
+	os.MkdirAll(input, 0755)
+	os.MkdirAll(elsewhere, 0755)
+	target := filepath.Join(elsewhere, "IMG-20240501-WA0001.jpg")
+	os.WriteFile(target, minimalJPEG(), 0644)
+	symlink(t, target, filepath.Join(input, "IMG-20240501-WA0001.jpg"))
+
+	files, err := processor.GetMediaFiles(input, false)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("GetMediaFiles() = %v, %v, want the symlink", files, err)
+	}
+	for _, safe := range []bool{true, false} {
+		config := processor.Config{InputDir: input, OverrideOriginal: true, SafeMode: safe}
+		r := processor.New(config).ProcessFiles(files)[0]
+		if safe {
+			if r.Error == nil || !strings.Contains(r.Error.Error(), "outside") {
+				t.Fatalf("safe mode error = %v, want the write refused", r.Error)
+			}
+			data, _ := os.ReadFile(target)
+			if !bytes.Equal(data, minimalJPEG()) {
+				t.Error("file outside the input directory was modified")
+			}
+		} else if r.Error != nil {
+			t.Fatalf("ProcessFiles() without safe mode error = %v", r.Error)
+		}
+	}
+}
+
+func TestProcessFiles_SafeModeSymlinkedOutputDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "media")
+	output := filepath.Join(tmpDir, "out")
+	elsewhere := filepath.Join(tmpDir, "elsewhere")
+	for _, dir := range []string{input, output, elsewhere} {
+		os.MkdirAll(dir, 0755)
+	}
+	os.WriteFile(filepath.Join(input, "IMG-20240501-WA0001.jpg"), minimalJPEG(), 0644)
+	symlink(t, elsewhere, filepath.Join(output, "2024"))
+
+	config := processor.Config{
+		InputDir:       input,
+		OutputDir:      output,
+		OutputTemplate: "{out}/{year}/{name}{ext}",
+		SafeMode:       true,
+	}
+	r := processor.New(config).ProcessFiles([]string{filepath.Join(input, "IMG-20240501-WA0001.jpg")})[0]
+	if r.Error == nil {
+		t.Fatal("ProcessFiles() wrote through a symlink leaving the output directory")
+	}
+	if entries, _ := os.ReadDir(elsewhere); len(entries) != 0 {
+		t.Errorf("files written outside the output directory: %v", entries)
+	}
+}
+
+func TestProcessFiles_SafeModeSymlinkedRoots(t *testing.T) {
+	tmpDir := t.TempDir()
+	realInput := filepath.Join(tmpDir, "real-media")
+	realOutput := filepath.Join(tmpDir, "real-out")
+	os.MkdirAll(realInput, 0755)
+	os.MkdirAll(realOutput, 0755)
+	os.WriteFile(filepath.Join(realInput, "IMG-20240501-WA0001.jpg"), minimalJPEG(), 0644)
+	input := filepath.Join(tmpDir, "media")
+	output := filepath.Join(tmpDir, "out")
+	symlink(t, realInput, input)
+	symlink(t, realOutput, output)
+
+	// Roots that are themselves symlinks are where the user meant to write
+	config := processor.Config{
+		InputDir:       input,
+		OutputDir:      output,
+		OutputTemplate: "{out}/{year}/{name}{ext}",
+		SafeMode:       true,
+	}
+	r := processor.New(config).ProcessFiles([]string{filepath.Join(input, "IMG-20240501-WA0001.jpg")})[0]
+	if r.Error != nil {
+		t.Fatalf("ProcessFiles() error = %v", r.Error)
+	}
+	if _, err := os.Stat(filepath.Join(realOutput, "2024", "IMG-20240501-WA0001.jpg")); err != nil {
+		t.Errorf("output not written through the output symlink: %v", err)
+	}
+}
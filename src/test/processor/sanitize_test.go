@@ -0,0 +1,93 @@
+package processor_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestSanitizeName(t *testing.T) {
+	long := strings.Repeat("é", 200) + ".jpg"
+	tests := []struct {
+		name, mode, want string
+	}{
+		{"IMG-20240501-WA0001.jpg", processor.SanitizeWindows, "IMG-20240501-WA0001.jpg"},
+		{"Photo 15:30:45.jpg", processor.SanitizeWindows, "Photo 15_30_45.jpg"},
+		{`a<b>c"d|e?f*g\h.jpg`, processor.SanitizeWindows, "a_b_c_d_e_f_g_h.jpg"},
+		{"CON.jpg", processor.SanitizeWindows, "CON_.jpg"},
+		{"lpt1", processor.SanitizeWindows, "lpt1_"},
+		{"CONSOLE.jpg", processor.SanitizeWindows, "CONSOLE.jpg"},
+		{"trailing. ", processor.SanitizeWindows, "trailing"},
+		{"...", processor.SanitizeWindows, "_"},
+		{"Photo 15:30:45.jpg", processor.SanitizeMacOS, "Photo 15_30_45.jpg"},
+		{"CON.jpg", processor.SanitizeMacOS, "CON.jpg"},
+		{"Photo 15:30:45.jpg", "", "Photo 15:30:45.jpg"},
+		{long, processor.SanitizeMacOS, strings.Repeat("é", 125) + ".jpg"},
+	}
+	for _, tt := range tests {
+		if got := processor.SanitizeName(tt.name, tt.mode); got != tt.want {
+			t.Errorf("SanitizeName(%q, %q) = %q, want %q", tt.name, tt.mode, got, tt.want)
+		}
+	}
+	if err := processor.ValidateSanitizeMode("linux"); err == nil {
+		t.Error("ValidateSanitizeMode(\"linux\") should fail")
+	}
+}
+
+func TestProcessFiles_SanitizeNames(t *testing.T) {
+	input := filepath.Join("media", "IMG-20240501-WA0001 at 15:30.jpg")
+	tests := []struct {
+		name   string
+		config processor.Config
+		want   string
+	}{
+		{"output directory", processor.Config{OutputDir: "out"}, filepath.Join("out", "IMG-20240501-WA0001 at 15_30.jpg")},
+		{"user directories untouched", processor.Config{OutputDir: "out:1"}, filepath.Join("out:1", "IMG-20240501-WA0001 at 15_30.jpg")},
+		{
+			"template directories",
+			processor.Config{OutputDir: "out", OutputTemplate: "{out}/CON/{date}|{name}{ext}"},
+			filepath.Join("out", "CON_", "2024-05-01_IMG-20240501-WA0001 at 15_30.jpg"),
+		},
+		{"suffix", processor.Config{}, filepath.Join("media", "IMG-20240501-WA0001 at 15_30_modified.jpg")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := processor.NewMemFS()
+			fsys.WriteFile(input, minimalJPEG(), 0644)
+			config := tt.config
+			config.InputDir = "media"
+			config.DryRun = true
+			config.SanitizeNames = processor.SanitizeWindows
+			r := processor.New(config, processor.WithFS(fsys)).ProcessFiles([]string{input})[0]
+			if r.Error != nil {
+				t.Fatalf("ProcessFiles() error = %v", r.Error)
+			}
+			if r.OutputFile != tt.want || !r.Sanitized {
+				t.Errorf("OutputFile = %s (sanitized %v), want %s", r.OutputFile, r.Sanitized, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessFiles_SanitizeNamesInPlace(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001:1.jpg", minimalJPEG(), 0644)
+	fsys.WriteFile("IMG-20240501-WA0002.jpg", minimalJPEG(), 0644)
+
+	config := processor.Config{InputDir: ".", OverrideOriginal: true, SanitizeNames: processor.SanitizeMacOS}
+	results := processor.New(config, processor.WithFS(fsys)).ProcessFiles([]string{"IMG-20240501-WA0001:1.jpg", "IMG-20240501-WA0002.jpg"})
+	for _, r := range results {
+		if r.Error != nil {
+			t.Fatalf("ProcessFiles() error = %v", r.Error)
+		}
+	}
+	if !results[0].Sanitized || results[1].Sanitized {
+		t.Errorf("Sanitized = %v, %v, want only the first file renamed", results[0].Sanitized, results[1].Sanitized)
+	}
+	want := []string{"IMG-20240501-WA0001_1.jpg", "IMG-20240501-WA0002.jpg"}
+	if got := fsys.Files(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
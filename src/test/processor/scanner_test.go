@@ -0,0 +1,98 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+	"github.com/apercova/wappd/scan"
+)
+
+// scanTree creates files (paths relative to the returned directory)
+func scanTree(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// scanRel returns what scanner finds under dir, relative to dir
+func scanRel(t *testing.T, scanner scan.Scanner, dir string) []string {
+	t.Helper()
+	files, err := scanner.Files(dir)
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	var rel []string
+	for _, file := range files {
+		r, _ := filepath.Rel(dir, file)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+func TestNewScanner_Config(t *testing.T) {
+	dir := scanTree(t, "IMG-1.jpg", "Sent/IMG-2.jpg", "Sent/old/IMG-3.jpg", ".Statuses/IMG-4.jpg")
+
+	depth, follow := 2, true
+	config := processor.MergeConfig(&processor.ConfigFile{MaxDepth: &depth, FollowSymlinks: &follow, SkipDirs: []string{".Statuses"}}, processor.Config{})
+	if !config.FollowSymlinks {
+		t.Error("followSymlinks not merged")
+	}
+	want := []string{"IMG-1.jpg", "Sent/IMG-2.jpg"}
+	if got := scanRel(t, processor.NewScanner(config), dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Flags win over the file
+	config = processor.MergeConfig(&processor.ConfigFile{MaxDepth: &depth, SkipDirs: []string{".Statuses"}}, processor.Config{MaxDepth: 3, SkipDirs: []string{"old"}})
+	want = []string{".Statuses/IMG-4.jpg", "IMG-1.jpg", "Sent/IMG-2.jpg"}
+	if got := scanRel(t, processor.NewScanner(config), dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("with flags: got %v, want %v", got, want)
+	}
+}
+
+func TestScanner_DetectContent(t *testing.T) {
+	dir := scanTree(t, "notes.txt")
+	for name, data := range map[string][]byte{
+		"IMG-20240501-WA0001":     minimalJPEG(),
+		"f0001234.dat":            simpleMP4(),
+		"STK-20240501-WA0002.gif": minimalJPEG(),
+	} {
+		os.WriteFile(filepath.Join(dir, name), data, 0644)
+	}
+
+	if got, want := scanRel(t, processor.NewScanner(processor.Config{}), dir), []string{"STK-20240501-WA0002.gif"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without detection: got %v, want %v", got, want)
+	}
+	want := []string{"IMG-20240501-WA0001", "STK-20240501-WA0002.gif", "f0001234.dat"}
+	if got := scanRel(t, processor.NewScanner(processor.Config{DetectContent: true}), dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("with detection: got %v, want %v", got, want)
+	}
+	// Extensions left out on purpose aren't brought back by their content
+	want = []string{"IMG-20240501-WA0001", "f0001234.dat"}
+	if got := scanRel(t, processor.NewScanner(processor.Config{DetectContent: true, Extensions: []string{"-gif"}}), dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("with detection and -gif: got %v, want %v", got, want)
+	}
+}
+
+func TestProcessFile_ExtensionlessJPEG(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "IMG-20240501-WA0001")
+	os.WriteFile(path, minimalJPEG(), 0644)
+
+	r := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, FixExtensions: true}).ProcessFile(path)
+	if want := path + ".jpg"; !r.Success || r.OutputFile != want {
+		t.Fatalf("ProcessFile() = success %v, output %q, error %v; want output %q", r.Success, r.OutputFile, r.Error, want)
+	}
+	if r.Backend != processor.BackendNative {
+		t.Errorf("Backend = %q, want %q (written as a JPEG)", r.Backend, processor.BackendNative)
+	}
+}
@@ -0,0 +1,93 @@
+package processor_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestIsSent(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"WhatsApp Images/Sent/IMG-20240501-WA0001.jpg", true},
+		{"/backup/WhatsApp Video/sent/VID-20240501-WA0001.mp4", true},
+		{"WhatsApp Images/IMG-20240501-WA0001.jpg", false},
+		{"WhatsApp Images/Sent.jpg", false},
+		{"Sentimental/IMG-20240501-WA0001.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := processor.IsSent(tt.path); got != tt.want {
+			t.Errorf("IsSent(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCreateEXIFSegmentWithComment(t *testing.T) {
+	dt := time.Date(2024, 5, 1, 14, 30, 0, 0, time.FixedZone("", 2*3600))
+	payload, err := processor.CreateEXIFSegmentWithComment(dt, true, processor.SentComment)
+	if err != nil {
+		t.Fatalf("CreateEXIFSegmentWithComment() error = %v", err)
+	}
+	if !bytes.HasSuffix(payload, []byte("ASCII\x00\x00\x00"+processor.SentComment)) {
+		t.Error("payload does not end with the ASCII UserComment")
+	}
+
+	dates, err := processor.ReadEXIFDates(payload)
+	if err != nil {
+		t.Fatalf("ReadEXIFDates() error = %v", err)
+	}
+	if dates["DateTimeOriginal"] != "2024:05:01 14:30:00" || dates["OffsetTimeOriginal"] != "+02:00" {
+		t.Errorf("ReadEXIFDates() = %v", dates)
+	}
+}
+
+func TestProcessFile_TagSent(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("Sent/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	fsys.WriteFile("IMG-20240501-WA0002.jpg", minimalJPEG(), 0644)
+
+	proc := processor.New(processor.Config{TagSent: true}, processor.WithFS(fsys))
+	sent := proc.ProcessFile("Sent/IMG-20240501-WA0001.jpg")
+	received := proc.ProcessFile("IMG-20240501-WA0002.jpg")
+	if !sent.Success || !received.Success {
+		t.Fatalf("ProcessFile() errors = %v, %v", sent.Error, received.Error)
+	}
+	if !sent.Sent || received.Sent {
+		t.Errorf("Sent = %v, %v, want true, false", sent.Sent, received.Sent)
+	}
+
+	comment := []byte(processor.SentComment)
+	if data, _ := fsys.ReadFile(sent.OutputFile); !bytes.Contains(data, comment) {
+		t.Error("sent file has no UserComment")
+	}
+	if data, _ := fsys.ReadFile(received.OutputFile); bytes.Contains(data, comment) {
+		t.Error("received file was tagged as sent")
+	}
+}
+
+func TestProcessFile_SentUntaggedByDefault(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("Sent/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+
+	result := processor.New(processor.Config{}, processor.WithFS(fsys)).ProcessFile("Sent/IMG-20240501-WA0001.jpg")
+	if result.Sent {
+		t.Error("Sent = true without TagSent")
+	}
+	if data, _ := fsys.ReadFile(result.OutputFile); bytes.Contains(data, []byte(processor.SentComment)) {
+		t.Error("file was tagged as sent without TagSent")
+	}
+}
+
+func TestExiftoolCommentArgs(t *testing.T) {
+	if got, want := processor.ExiftoolCommentArgs("a.png", "x"), []string{"-EXIF:UserComment=x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExiftoolCommentArgs(png) = %q, want %q", got, want)
+	}
+	if got, want := processor.ExiftoolCommentArgs("a.MOV", "x"), []string{"-XMP-exif:UserComment=x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExiftoolCommentArgs(mov) = %q, want %q", got, want)
+	}
+}
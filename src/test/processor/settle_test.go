@@ -0,0 +1,71 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFiles_Settle(t *testing.T) {
+	tmpDir := t.TempDir()
+	done := filepath.Join(tmpDir, "IMG-20240501-WA0001.jpg")
+	late := filepath.Join(tmpDir, "IMG-20240502-WA0002.jpg")
+	growing := filepath.Join(tmpDir, "IMG-20240503-WA0003.jpg")
+	for _, path := range []string{done, late, growing} {
+		os.WriteFile(path, minimalJPEG(), 0644)
+	}
+
+	// late finishes during the first settle wait; growing never does
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		time.Sleep(20 * time.Millisecond)
+		appendByte(late)
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(5 * time.Millisecond):
+				appendByte(growing)
+			}
+		}
+	}()
+
+	config := processor.Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out"), Settle: "100ms"}
+	results := processor.New(config).ProcessFiles([]string{done, late, growing})
+	close(stop)
+	wg.Wait()
+
+	if !results[0].Success {
+		t.Errorf("complete file: %+v, want processed", results[0])
+	}
+	if !results[1].Success {
+		t.Errorf("file finished during the wait: %+v, want processed on retry", results[1])
+	}
+	if !results[2].Skipped || results[2].SkipReason != processor.SkipReasonGrowing {
+		t.Errorf("growing file: %+v, want skipped as %q", results[2], processor.SkipReasonGrowing)
+	}
+
+	if _, err := processor.ParseSettleTime("soon"); err == nil {
+		t.Error("ParseSettleTime(soon) should fail")
+	}
+}
+
+// appendByte grows a file by one byte, as a transfer in progress would
+func appendByte(path string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	f.Write([]byte{0})
+	f.Close()
+}
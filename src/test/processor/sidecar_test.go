@@ -0,0 +1,103 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFile_SidecarOnly(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "media", "IMG-20240501-WA0001.jpg")
+	os.MkdirAll(filepath.Dir(input), 0755)
+	os.WriteFile(input, minimalJPEG(), 0644)
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	os.Chtimes(input, old, old)
+
+	out := filepath.Join(dir, "dates")
+	proc := processor.New(processor.Config{
+		InputDir:       filepath.Dir(input),
+		OutputDir:      out,
+		OutputTemplate: "{out}/{year}/{name}{ext}",
+		SidecarOnly:    true,
+		OverwriteExif:  true,
+		Tags:           []string{"family"},
+		GPS:            "40.5,-3.25",
+	})
+	r := proc.ProcessFile(input)
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+
+	// The original is bit-identical, times included
+	if data, _ := os.ReadFile(input); !bytes.Equal(data, minimalJPEG()) {
+		t.Error("sidecar-only run changed the media bytes")
+	}
+	if info, _ := os.Stat(input); !info.ModTime().Equal(old) {
+		t.Errorf("sidecar-only run changed the modification time to %v", info.ModTime())
+	}
+	if r.OutputFile != input || r.PlannedPath != filepath.Join(out, "2024", "IMG-20240501-WA0001.jpg") {
+		t.Errorf("OutputFile = %s, PlannedPath = %s", r.OutputFile, r.PlannedPath)
+	}
+	if _, err := os.Stat(r.PlannedPath); !os.IsNotExist(err) {
+		t.Error("sidecar-only run wrote the media to its output path")
+	}
+
+	base := filepath.Join(out, "IMG-20240501-WA0001.jpg")
+	if len(r.Sidecars) != 2 || r.Sidecars[0] != base+".xmp" || r.Sidecars[1] != base+".json" {
+		t.Fatalf("Sidecars = %v, want %s.xmp and .json", r.Sidecars, base)
+	}
+	xmp, _ := os.ReadFile(base + ".xmp")
+	for _, want := range []string{"<exif:DateTimeOriginal>2024-05-01T00:00:00<", "<rdf:li>family</rdf:li>", "<exif:GPSLatitude>40,30.0000N<", "<exif:GPSLongitude>3,15.0000W<"} {
+		if !strings.Contains(string(xmp), want) {
+			t.Errorf("XMP sidecar lacks %q:\n%s", want, xmp)
+		}
+	}
+	var sidecar processor.Sidecar
+	data, _ := os.ReadFile(base + ".json")
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		t.Fatalf("JSON sidecar doesn't parse: %v", err)
+	}
+	hash, _ := processor.HashFile(input)
+	if sidecar.SHA256 != hash || sidecar.Date != "2024-05-01T00:00:00" || sidecar.RenameTo != r.PlannedPath {
+		t.Errorf("JSON sidecar = %+v", sidecar)
+	}
+}
+
+func TestProcessFile_SidecarOnlyDryRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "VID-20240501-WA0001.mp4")
+	os.WriteFile(input, simpleMP4(), 0644)
+
+	proc := processor.New(processor.Config{InputDir: dir, SidecarOnly: true, DryRun: true})
+	if r := proc.ProcessFile(input); !r.Success || len(r.Sidecars) != 0 {
+		t.Fatalf("ProcessFile() = %+v", r)
+	}
+	if _, err := os.Stat(input + ".xmp"); !os.IsNotExist(err) {
+		t.Error("dry run wrote a sidecar")
+	}
+}
+
+func TestWriteRenameMap(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	results := []processor.ProcessResult{
+		{InputFile: "in/a,b.jpg", PlannedPath: "out/2024/a,b.jpg", Success: true, DateTime: date},
+		{InputFile: "in/same.jpg", PlannedPath: "in/same.jpg", Success: true, DateTime: date},
+		{InputFile: "in/failed.jpg", PlannedPath: "out/failed.jpg", DateTime: date},
+	}
+	path := filepath.Join(t.TempDir(), "renames.csv")
+	if err := processor.WriteRenameMap(path, processor.BuildRenameMap(results)); err != nil {
+		t.Fatalf("WriteRenameMap() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "original,renamed,date\n\"in/a,b.jpg\",\"out/2024/a,b.jpg\",2024-05-01T12:00:00\n"
+	if string(data) != want {
+		t.Errorf("rename map = %q, want %q", data, want)
+	}
+}
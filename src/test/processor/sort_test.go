@@ -0,0 +1,75 @@
+package processor_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFiles_InputOrderWithConcurrency(t *testing.T) {
+	fsys := processor.NewMemFS()
+	var paths []string
+	for i := 0; i < 50; i++ {
+		// Descending dates and names, so no accidental order matches input
+		path := fmt.Sprintf("IMG-202405%02d-WA%04d.jpg", 28-i/2, 100-i)
+		fsys.WriteFile(path, minimalJPEG(), 0644)
+		paths = append(paths, path)
+	}
+
+	config := processor.Config{DryRun: true}
+	results := processor.New(config, processor.WithFS(fsys), processor.WithConcurrency(8)).ProcessFiles(paths)
+	for i, r := range results {
+		if r.InputFile != paths[i] {
+			t.Fatalf("results[%d] = %s, want %s", i, r.InputFile, paths[i])
+		}
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	results := []processor.ProcessResult{
+		{InputFile: "c.jpg", Success: true, DateTime: day(3)},
+		{InputFile: "a.jpg", Error: errors.New("no date")},
+		{InputFile: "d.jpg", Skipped: true},
+		{InputFile: "b.jpg", Success: true, DateTime: day(1)},
+		{InputFile: "e.jpg", Success: true, DateTime: day(3)},
+	}
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{processor.SortInput, []string{"c.jpg", "a.jpg", "d.jpg", "b.jpg", "e.jpg"}},
+		{"", []string{"c.jpg", "a.jpg", "d.jpg", "b.jpg", "e.jpg"}},
+		{processor.SortName, []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg"}},
+		{processor.SortDate, []string{"b.jpg", "c.jpg", "e.jpg", "a.jpg", "d.jpg"}},
+		{processor.SortStatus, []string{"a.jpg", "d.jpg", "c.jpg", "b.jpg", "e.jpg"}},
+	}
+	for _, tt := range tests {
+		sorted := processor.SortResults(results, tt.order)
+		for i, r := range sorted {
+			if r.InputFile != tt.want[i] {
+				t.Errorf("SortResults(%q) = %v, want %v", tt.order, names(sorted), tt.want)
+				break
+			}
+		}
+	}
+	if results[0].InputFile != "c.jpg" {
+		t.Error("SortResults() modified its input")
+	}
+
+	if err := processor.ValidateSortOrder("size"); err == nil {
+		t.Error("ValidateSortOrder(\"size\") should fail")
+	}
+}
+
+// names lists the input files of results
+func names(results []processor.ProcessResult) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.InputFile)
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package processor_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestIsSticker(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"STK-20240501-WA0003.webp", true},
+		{filepath.Join("Media", "WhatsApp Stickers", "a1b2c3.webp"), true},
+		{filepath.Join("backup", "Stickers", "x.webp"), true},
+		{filepath.Join("Media", "WhatsApp Images", "IMG-20240501-WA0001.jpg"), false},
+		{"IMG-20240501-WA0001.webp", false},
+	}
+	for _, tt := range tests {
+		if got := processor.IsSticker(tt.path); got != tt.want {
+			t.Errorf("IsSticker(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidateStickerMode(t *testing.T) {
+	for _, mode := range []string{"", "skip", "mtime", "process"} {
+		if err := processor.ValidateStickerMode(mode); err != nil {
+			t.Errorf("ValidateStickerMode(%q) error = %v", mode, err)
+		}
+	}
+	if err := processor.ValidateStickerMode("ignore"); err == nil {
+		t.Error("ValidateStickerMode(\"ignore\") should fail")
+	}
+}
+
+func TestProcessFile_StickerModes(t *testing.T) {
+	want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("skip by default", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		path := filepath.Join(tmpDir, "STK-20240501-WA0003.webp")
+		os.WriteFile(path, []byte("RIFF....WEBP"), 0644)
+
+		r := processor.New(processor.Config{InputDir: tmpDir}).ProcessFile(path)
+		if !r.Skipped || r.Error != nil {
+			t.Errorf("ProcessFile() = skipped %v, error %v; want skipped without error", r.Skipped, r.Error)
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "STK-20240501-WA0003_modified.webp")); !os.IsNotExist(err) {
+			t.Error("skipped sticker produced an output file")
+		}
+	})
+
+	t.Run("mtime only", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		path := filepath.Join(tmpDir, "STK-20240501-WA0003.jpg")
+		os.WriteFile(path, minimalJPEG(), 0644)
+
+		r := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, Stickers: processor.StickersMtime}).ProcessFile(path)
+		if !r.Success {
+			t.Fatalf("ProcessFile() error = %v", r.Error)
+		}
+		data, _ := os.ReadFile(path)
+		if !bytes.Equal(data, minimalJPEG()) {
+			t.Error("mtime mode modified the sticker content")
+		}
+		if info, _ := os.Stat(path); !info.ModTime().Equal(want) {
+			t.Errorf("ModTime = %s, want %s", info.ModTime(), want)
+		}
+	})
+
+	t.Run("process", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		path := filepath.Join(tmpDir, "STK-20240501-WA0003.jpg")
+		os.WriteFile(path, minimalJPEG(), 0644)
+
+		r := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, Stickers: processor.StickersProcess}).ProcessFile(path)
+		if !r.Success {
+			t.Fatalf("ProcessFile() error = %v", r.Error)
+		}
+		data, _ := os.ReadFile(path)
+		if bytes.Equal(data, minimalJPEG()) {
+			t.Error("process mode did not write EXIF")
+		}
+	})
+}
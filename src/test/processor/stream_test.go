@@ -0,0 +1,129 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// sendPaths returns a closed channel holding paths
+func sendPaths(paths []string) <-chan string {
+	ch := make(chan string, len(paths))
+	for _, path := range paths {
+		ch <- path
+	}
+	close(ch)
+	return ch
+}
+
+func TestWalkMediaFiles_MatchesGetMediaFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"IMG-20240501-WA0001.jpg", "notes.txt", "sub/VID-20240502-WA0001.mp4", "sub/report.pdf"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0644)
+	}
+
+	for _, docs := range []bool{false, true} {
+		want, err := processor.GetMediaFiles(dir, docs)
+		if err != nil {
+			t.Fatalf("GetMediaFiles() error = %v", err)
+		}
+		var got []string
+		err = processor.WalkMediaFiles(dir, docs, func(path string) error {
+			got = append(got, path)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkMediaFiles() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("WalkMediaFiles(documents=%v) = %v, want %v", docs, got, want)
+		}
+	}
+}
+
+func TestProcessStream_ResultsInSendOrder(t *testing.T) {
+	fsys := processor.NewMemFS()
+	var paths []string
+	for _, name := range []string{"IMG-20240501-WA0001.jpg", "holiday.jpg", "IMG-20240502-WA0001.jpg", "IMG-20240503-WA0001.jpg"} {
+		fsys.WriteFile(name, minimalJPEG(), 0644)
+		paths = append(paths, name)
+	}
+	proc := processor.New(processor.Config{}, processor.WithFS(fsys), processor.WithConcurrency(3))
+
+	results := proc.ProcessStream(sendPaths(paths))
+	if len(results) != len(paths) {
+		t.Fatalf("got %d results, want %d", len(results), len(paths))
+	}
+	for i, r := range results {
+		if r.InputFile != paths[i] {
+			t.Errorf("results[%d].InputFile = %q, want %q", i, r.InputFile, paths[i])
+		}
+		if wantOK := paths[i] != "holiday.jpg"; r.Success != wantOK {
+			t.Errorf("%s: Success = %v, want %v (error %v)", paths[i], r.Success, wantOK, r.Error)
+		}
+	}
+}
+
+func TestProcessStream_MaxFailuresAborts(t *testing.T) {
+	fsys, paths := failingBatch(5)
+	proc := processor.New(processor.Config{MaxFailures: "2"}, processor.WithFS(fsys))
+
+	results := proc.ProcessStream(sendPaths(paths))
+	if !proc.Aborted() {
+		t.Fatal("Aborted() = false, want true")
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("got %d results, want %d (the rest of the stream is still drained)", len(results), len(paths))
+	}
+	last := results[len(results)-1]
+	if !last.Skipped || last.SkipReason != processor.SkipReasonAborted {
+		t.Errorf("last result = %+v, want skipped as aborted", last)
+	}
+}
+
+func TestProcessor_Streams(t *testing.T) {
+	tests := []struct {
+		name   string
+		config processor.Config
+		want   bool
+	}{
+		{"plain", processor.Config{}, true},
+		{"failure count", processor.Config{MaxFailures: "3"}, true},
+		{"failure percentage", processor.Config{MaxFailures: "10%"}, false},
+		{"infer dates", processor.Config{InferDates: true}, false},
+		{"spread times", processor.Config{SpreadTimes: true}, false},
+		{"settle", processor.Config{Settle: "2s"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processor.New(tt.config).Streams(); got != tt.want {
+				t.Errorf("Streams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessStream_WholeBatchFallback(t *testing.T) {
+	fsys := processor.NewMemFS()
+	paths := []string{"IMG-20240501-WA0001.jpg", "IMG-20240502-WA0001.jpg"}
+	for _, path := range paths {
+		fsys.WriteFile(path, minimalJPEG(), 0644)
+	}
+	proc := processor.New(processor.Config{InferDates: true}, processor.WithFS(fsys))
+
+	got := proc.ProcessStream(sendPaths(paths))
+	want := processor.New(processor.Config{InferDates: true}, processor.WithFS(fsys)).ProcessFiles(paths)
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].InputFile != want[i].InputFile || got[i].Success != want[i].Success {
+			t.Errorf("results[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
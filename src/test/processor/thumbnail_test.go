@@ -0,0 +1,78 @@
+package processor_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestThumbnailer(t *testing.T) {
+	tmpDir := t.TempDir()
+	photo := filepath.Join(tmpDir, "IMG-20240501-WA0001.png")
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 200)))
+	os.WriteFile(photo, buf.Bytes(), 0644)
+
+	cacheDir := filepath.Join(tmpDir, "thumbnails")
+	thumbs := processor.NewThumbnailer(cacheDir, 100)
+	data, err := thumbs.Thumbnail(photo)
+	if err != nil {
+		t.Fatalf("Thumbnail() error = %v", err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("thumbnail isn't a JPEG: %v", err)
+	}
+	if cfg.Width != 100 || cfg.Height != 50 {
+		t.Errorf("thumbnail is %dx%d, want 100x50", cfg.Width, cfg.Height)
+	}
+
+	// The second call is served from the cache...
+	entries, _ := os.ReadDir(cacheDir)
+	if len(entries) != 1 {
+		t.Fatalf("cache holds %d files, want 1", len(entries))
+	}
+	cached := filepath.Join(cacheDir, entries[0].Name())
+	os.WriteFile(cached, []byte("cached"), 0644)
+	if data, _ := thumbs.Thumbnail(photo); string(data) != "cached" {
+		t.Error("Thumbnail() didn't use the cache")
+	}
+	// ...until the file changes
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(photo, later, later)
+	if data, _ := thumbs.Thumbnail(photo); string(data) == "cached" {
+		t.Error("Thumbnail() used the cache of a modified file")
+	}
+
+	notes := filepath.Join(tmpDir, "notes.txt")
+	os.WriteFile(notes, []byte("hello"), 0644)
+	if _, err := thumbs.Thumbnail(notes); !errors.Is(err, processor.ErrNoThumbnail) {
+		t.Errorf("Thumbnail(notes.txt) error = %v, want ErrNoThumbnail", err)
+	}
+	if !processor.FFmpegAvailable() {
+		video := filepath.Join(tmpDir, "VID-20240501-WA0002.mp4")
+		os.WriteFile(video, simpleMP4(), 0644)
+		if _, err := thumbs.Thumbnail(video); !errors.Is(err, processor.ErrNoThumbnail) {
+			t.Errorf("Thumbnail(video) without ffmpeg error = %v, want ErrNoThumbnail", err)
+		}
+	}
+}
+
+func TestFFmpegThumbnailArgs(t *testing.T) {
+	video := processor.FFmpegThumbnailArgs("in.mp4", 160, true)
+	if !slices.Contains(video, "nokey") || !slices.Contains(video, "in.mp4") || video[len(video)-1] != "-" {
+		t.Errorf("FFmpegThumbnailArgs(video) = %v", video)
+	}
+	if photo := processor.FFmpegThumbnailArgs("in.webp", 160, false); slices.Contains(photo, "nokey") {
+		t.Errorf("FFmpegThumbnailArgs(photo) = %v, shouldn't skip to a keyframe", photo)
+	}
+}
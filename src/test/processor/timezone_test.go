@@ -0,0 +1,153 @@
+package processor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestLoadTimezone(t *testing.T) {
+	loc, err := processor.LoadTimezone("")
+	if err != nil || loc != nil {
+		t.Errorf("LoadTimezone(\"\") = %v, %v, want nil, nil", loc, err)
+	}
+	if _, err := processor.LoadTimezone("Europe/Madrid"); err != nil {
+		t.Errorf("LoadTimezone(Europe/Madrid) error = %v", err)
+	}
+	if _, err := processor.LoadTimezone("Mars/Olympus_Mons"); err == nil {
+		t.Error("LoadTimezone() should reject unknown zones")
+	}
+}
+
+func TestResolveLocalTime_DST(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		name    string
+		naive   time.Time
+		loc     *time.Location
+		wantUTC time.Time
+		wantOff string
+	}{
+		{
+			name:    "Winter time",
+			naive:   time.Date(2025, 1, 22, 15, 30, 45, 0, time.UTC),
+			loc:     berlin,
+			wantUTC: time.Date(2025, 1, 22, 14, 30, 45, 0, time.UTC),
+			wantOff: "+01:00",
+		},
+		{
+			name:    "Summer time",
+			naive:   time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC),
+			loc:     berlin,
+			wantUTC: time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC),
+			wantOff: "+02:00",
+		},
+		{
+			name:    "Spring-forward gap moves forward",
+			naive:   time.Date(2025, 3, 30, 2, 30, 0, 0, time.UTC),
+			loc:     berlin,
+			wantUTC: time.Date(2025, 3, 30, 1, 30, 0, 0, time.UTC), // 03:30 CEST
+			wantOff: "+02:00",
+		},
+		{
+			name:    "Fall-back overlap resolves to first occurrence",
+			naive:   time.Date(2025, 10, 26, 2, 30, 0, 0, time.UTC),
+			loc:     berlin,
+			wantUTC: time.Date(2025, 10, 26, 0, 30, 0, 0, time.UTC), // 02:30 CEST
+			wantOff: "+02:00",
+		},
+		{
+			name:    "Just after fall-back overlap",
+			naive:   time.Date(2025, 10, 26, 3, 0, 0, 0, time.UTC),
+			loc:     berlin,
+			wantUTC: time.Date(2025, 10, 26, 2, 0, 0, 0, time.UTC),
+			wantOff: "+01:00",
+		},
+		{
+			name:    "US spring-forward gap",
+			naive:   time.Date(2025, 3, 9, 2, 15, 0, 0, time.UTC),
+			loc:     newYork,
+			wantUTC: time.Date(2025, 3, 9, 7, 15, 0, 0, time.UTC), // 03:15 EDT
+			wantOff: "-04:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := processor.ResolveLocalTime(tt.naive, tt.loc)
+			if !got.Equal(tt.wantUTC) {
+				t.Errorf("ResolveLocalTime() = %s, want %s", got.UTC(), tt.wantUTC)
+			}
+			if off := got.Format("-07:00"); off != tt.wantOff {
+				t.Errorf("ResolveLocalTime() offset = %s, want %s", off, tt.wantOff)
+			}
+		})
+	}
+}
+
+func TestFormatOffsetTime(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	got := processor.FormatOffsetTime(time.Date(2025, 1, 22, 0, 0, 0, 0, kolkata))
+	if got != "+05:30\x00" {
+		t.Errorf("FormatOffsetTime() = %q, want \"+05:30\\x00\"", got)
+	}
+}
+
+func TestProcessFile_TimezoneWritesLocalExifAndUTCVideo(t *testing.T) {
+	tmpDir := t.TempDir()
+	jpegPath := filepath.Join(tmpDir, "WhatsApp Image 2025-07-01 at 12.00.00 PM.jpg")
+	videoPath := filepath.Join(tmpDir, "WhatsApp Video 2025-07-01 at 12.00.00 PM.mp4")
+	os.WriteFile(jpegPath, minimalJPEG(), 0644)
+	os.WriteFile(videoPath, append(box("ftyp", []byte("isom")), box("moov", box("mvhd", mvhdV0()))...), 0644)
+
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, UpdateModified: true, Timezone: "Europe/Berlin"})
+
+	// JPEG: local wall-clock time plus offset
+	r := proc.ProcessFile(jpegPath)
+	if !r.Success {
+		t.Fatalf("ProcessFile() JPEG error = %v", r.Error)
+	}
+	data, _ := os.ReadFile(jpegPath)
+	if !bytes.Contains(data, []byte("2025:07:01 12:00:00\x00")) {
+		t.Error("EXIF DateTimeOriginal should hold the local wall-clock time")
+	}
+	if !bytes.Contains(data, []byte("+02:00\x00")) {
+		t.Error("EXIF OffsetTimeOriginal should hold the zone offset")
+	}
+	info, _ := os.Stat(jpegPath)
+	if want := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC); !info.ModTime().Equal(want) {
+		t.Errorf("mtime = %s, want %s", info.ModTime().UTC(), want)
+	}
+
+	// MP4: UTC instant
+	r = proc.ProcessFile(videoPath)
+	if !r.Success {
+		t.Fatalf("ProcessFile() video error = %v", r.Error)
+	}
+	data, _ = os.ReadFile(videoPath)
+	atoms, _ := processor.ParseMP4Atoms(data)
+	mvhd := processor.FindAtomRecursive(*processor.FindAtom(atoms, "moov"), "mvhd")
+	created := processor.QuickTimeToUnix(binary.BigEndian.Uint32(mvhd.Data[4:8]))
+	if want := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC).Unix(); created != want {
+		t.Errorf("mvhd creation time = %d, want %d", created, want)
+	}
+}
+
+func TestProcessFile_InvalidTimezone(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "IMG-20250122-WA0003.jpg")
+	os.WriteFile(path, minimalJPEG(), 0644)
+
+	proc := processor.New(processor.Config{InputDir: tmpDir, OverrideOriginal: true, Timezone: "Not/AZone"})
+	if r := proc.ProcessFile(path); r.Success {
+		t.Error("ProcessFile() should fail with an invalid timezone")
+	}
+}
@@ -0,0 +1,53 @@
+package processor_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestProcessFile_Timings(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("in/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+
+	var buf bytes.Buffer
+	proc := processor.New(processor.Config{OutputDir: "out", UpdateModified: true, Timings: true},
+		processor.WithFS(fsys), processor.WithLogger(log.New(&buf, "", 0)))
+	r := proc.ProcessFile("in/IMG-20240501-WA0001.jpg")
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+	if r.Timings == nil {
+		t.Fatal("Timings = nil, want the file's stage timings")
+	}
+	stages := r.Timings.Parse + r.Timings.Read + r.Timings.Copy + r.Timings.Write + r.Timings.Chtimes
+	if r.Timings.Total <= 0 || stages > r.Timings.Total {
+		t.Errorf("stages add up to %s, total %s; want a positive total covering them", stages, r.Timings.Total)
+	}
+	if !strings.Contains(buf.String(), "Timing IMG-20240501-WA0001.jpg: parse ") {
+		t.Errorf("log = %q, want a timing line for the file", buf.String())
+	}
+
+	// Without Timings nothing is measured
+	r = processor.New(processor.Config{OutputDir: "out2"}, processor.WithFS(fsys)).ProcessFile("in/IMG-20240501-WA0001.jpg")
+	if r.Timings != nil {
+		t.Errorf("Timings = %+v without Timings, want nil", r.Timings)
+	}
+}
+
+func TestSumTimings(t *testing.T) {
+	results := []processor.ProcessResult{
+		{Timings: &processor.StageTimings{Parse: time.Millisecond, Write: 2 * time.Millisecond, Total: 4 * time.Millisecond}},
+		{},
+		{Timings: &processor.StageTimings{Read: 3 * time.Millisecond, Chtimes: time.Microsecond, Total: 5 * time.Millisecond}},
+	}
+	got := processor.SumTimings(results).String()
+	want := "parse 1ms, read 3ms, copy 0s, write 2ms, chtimes 1µs, total 9ms"
+	if got != want {
+		t.Errorf("SumTimings() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package processor_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// transactionBatch processes two WhatsApp images and one broken JPEG into
+// out/ inside a transaction, without finishing it
+func transactionBatch(t *testing.T) (*processor.MemFS, *processor.Transaction, []processor.ProcessResult) {
+	t.Helper()
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("in/IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	fsys.WriteFile("in/IMG-20240501-WA0002.jpg", minimalJPEG(), 0644)
+	fsys.WriteFile("in/IMG-20240501-WA0003.jpg", []byte("not a jpeg"), 0644)
+
+	tx, err := processor.NewTransaction(fsys, "staging")
+	if err != nil {
+		t.Fatalf("NewTransaction() error = %v", err)
+	}
+	proc := processor.New(processor.Config{OutputDir: "out", InputDir: "in"}, processor.WithFS(fsys), processor.WithTransaction(tx))
+	results := proc.ProcessFiles([]string{"in/IMG-20240501-WA0001.jpg", "in/IMG-20240501-WA0002.jpg", "in/IMG-20240501-WA0003.jpg"})
+	return fsys, tx, results
+}
+
+func TestTransaction_StagesUntilCommit(t *testing.T) {
+	fsys, tx, results := transactionBatch(t)
+	if !results[0].Success || results[2].Success {
+		t.Fatalf("results = %v, %v", results[0].Error, results[2].Error)
+	}
+
+	// Nothing is in place before the commit; results name the final paths
+	if _, err := fsys.Stat(results[0].OutputFile); err == nil {
+		t.Fatal("output was written in place before the commit")
+	}
+
+	committed, err := tx.Commit()
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if committed != 2 {
+		t.Errorf("Commit() moved %d outputs, want 2 (failed files are not committed)", committed)
+	}
+	data, err := fsys.ReadFile(results[0].OutputFile)
+	if err != nil || bytes.Equal(data, minimalJPEG()) {
+		t.Errorf("committed output missing or unmodified (err = %v)", err)
+	}
+	if _, err := fsys.Stat("out/IMG-20240501-WA0003.jpg"); err == nil {
+		t.Error("failed file was committed")
+	}
+	if _, err := fsys.Stat("staging"); err == nil {
+		t.Error("staging directory was not removed")
+	}
+}
+
+func TestTransaction_Rollback(t *testing.T) {
+	fsys, tx, _ := transactionBatch(t)
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+
+	for _, name := range fsys.Files() {
+		if name[:3] != "in/" {
+			t.Errorf("%s left behind after rollback", name)
+		}
+	}
+	if data, _ := fsys.ReadFile("in/IMG-20240501-WA0001.jpg"); !bytes.Equal(data, minimalJPEG()) {
+		t.Error("original changed by a rolled back transaction")
+	}
+}
+
+func TestTransaction_OverrideOriginal(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	tx, err := processor.NewTransaction(fsys, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proc := processor.New(processor.Config{OverrideOriginal: true}, processor.WithFS(fsys), processor.WithTransaction(tx))
+	result := proc.ProcessFile("IMG-20240501-WA0001.jpg")
+	if !result.Success || result.OutputFile != "IMG-20240501-WA0001.jpg" {
+		t.Fatalf("ProcessFile() = %s, %v", result.OutputFile, result.Error)
+	}
+	if data, _ := fsys.ReadFile("IMG-20240501-WA0001.jpg"); !bytes.Equal(data, minimalJPEG()) {
+		t.Fatal("original edited before the commit")
+	}
+	if _, err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if data, _ := fsys.ReadFile("IMG-20240501-WA0001.jpg"); bytes.Equal(data, minimalJPEG()) {
+		t.Error("original not replaced by the commit")
+	}
+}
@@ -0,0 +1,60 @@
+package processor_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// corruptingFS truncates the first file written through it, simulating a
+// writer bug or a failing disk
+type corruptingFS struct {
+	*processor.MemFS
+	corrupted bool
+}
+
+func (c *corruptingFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !c.corrupted {
+		c.corrupted = true
+		data = data[:len(data)/2]
+	}
+	return c.MemFS.WriteFile(name, data, perm)
+}
+
+func TestProcessFile_WriteValidationRestoresOriginal(t *testing.T) {
+	for _, name := range []string{"IMG-20240501-WA0001.jpg", "VID-20240501-WA0002.mp4"} {
+		t.Run(name, func(t *testing.T) {
+			original, err := os.ReadFile(filepath.Join("testdata", "golden", "input", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fsys := &corruptingFS{MemFS: processor.NewMemFS()}
+			fsys.MemFS.WriteFile(name, original, 0644)
+
+			r := processor.New(processor.Config{OverrideOriginal: true}, processor.WithFS(fsys)).ProcessFile(name)
+			if r.Success || !errors.Is(r.Error, processor.ErrWriteValidation) {
+				t.Fatalf("ProcessFile() error = %v, want ErrWriteValidation", r.Error)
+			}
+			got, _ := fsys.ReadFile(name)
+			if !bytes.Equal(got, original) {
+				t.Error("original bytes were not restored")
+			}
+		})
+	}
+}
+
+func TestProcessFile_WriteValidationPasses(t *testing.T) {
+	original, _ := os.ReadFile(filepath.Join("testdata", "golden", "input", "IMG-20240501-WA0001.jpg"))
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", original, 0644)
+
+	r := processor.New(processor.Config{OverrideOriginal: true}, processor.WithFS(fsys)).ProcessFile("IMG-20240501-WA0001.jpg")
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+}
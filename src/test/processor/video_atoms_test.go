@@ -0,0 +1,149 @@
+package processor_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// appleKeysMeta builds a QuickTime moov/meta holding an Apple creation date
+// key with value
+func appleKeysMeta(value string) []byte {
+	hdlr := append(make([]byte, 8), "mdta"...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+	key := box("mdta", []byte("com.apple.quicktime.creationdate"))
+	keys := box("keys", make([]byte, 4), []byte{0, 0, 0, 1}, key)
+	data := box("data", []byte{0, 0, 0, 1}, make([]byte, 4), []byte(value))
+	ilst := box("ilst", box("\x00\x00\x00\x01", data))
+	return box("meta", box("hdlr", hdlr), keys, ilst)
+}
+
+// processVideoAtoms processes a VID-20240501 video with the given atoms and
+// returns its result and the bytes written
+func processVideoAtoms(t *testing.T, video []byte, atoms ...string) (processor.ProcessResult, []byte) {
+	t.Helper()
+	fsys := processor.NewMemFS()
+	name := "VID-20240501-WA0001.mp4"
+	fsys.WriteFile(name, video, 0644)
+	p := processor.New(processor.Config{InputDir: ".", OverrideOriginal: true, Timezone: "UTC", VideoAtoms: atoms}, processor.WithFS(fsys))
+	result := p.ProcessFile(name)
+	data, _ := fsys.ReadFile(name)
+	return result, data
+}
+
+// headerTimes returns the creation time of each header atom in a file,
+// keyed by the atom's path in moov
+func headerTimes(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	atoms, err := processor.ParseMP4Atoms(data)
+	if err != nil {
+		t.Fatalf("ParseMP4Atoms() error = %v", err)
+	}
+	moov := processor.FindAtom(atoms, "moov")
+	if moov == nil {
+		t.Fatal("no moov")
+	}
+	c := processor.CheckVideoHeaders(processor.ManifestEntry{DateWritten: "2024-05-01T00:00:00"}, data[moov.Offset:moov.Offset+moov.Size])
+	times := map[string]string{}
+	for _, h := range c.Headers {
+		times[h.Atom] = h.Created
+	}
+	return times
+}
+
+func TestVideoAtoms_SelectsHeaders(t *testing.T) {
+	copied := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	ftyp := box("ftyp", []byte("isom"), make([]byte, 4), []byte("isom"))
+	trak := box("trak", box("tkhd", headerV0(84, copied)), box("mdia", box("mdhd", headerV0(24, copied))))
+	video := append(ftyp, box("moov", box("mvhd", headerV0(100, copied)), trak)...)
+
+	tests := []struct {
+		name  string
+		atoms []string
+		want  map[string]string
+		day   bool
+	}{
+		{"default", nil, map[string]string{"mvhd": "2024-05-01T00:00:00", "trak[1]/tkhd": "2024-05-01T00:00:00", "trak[1]/mdia/mdhd": "2025-01-15T09:00:00"}, false},
+		{"mdhd and day", []string{"mdhd", "day"}, map[string]string{"mvhd": "2025-01-15T09:00:00", "trak[1]/tkhd": "2025-01-15T09:00:00", "trak[1]/mdia/mdhd": "2024-05-01T00:00:00"}, true},
+		{"all headers", []string{"mvhd", "tkhd", "mdhd"}, map[string]string{"mvhd": "2024-05-01T00:00:00", "trak[1]/tkhd": "2024-05-01T00:00:00", "trak[1]/mdia/mdhd": "2024-05-01T00:00:00"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, data := processVideoAtoms(t, append([]byte{}, video...), tt.atoms...)
+			if !result.Success {
+				t.Fatalf("ProcessFile() error = %v", result.Error)
+			}
+			got := headerTimes(t, data)
+			for atom, want := range tt.want {
+				if got[atom] != want {
+					t.Errorf("%s created %q, want %q", atom, got[atom], want)
+				}
+			}
+			day, err := processor.ReadQuickTimeDay(data)
+			if tt.day && day != "2024-05-01T00:00:00Z" {
+				t.Errorf("©day = %q (error %v), want 2024-05-01T00:00:00Z", day, err)
+			}
+			if !tt.day && err == nil {
+				t.Errorf("©day = %q, want none", day)
+			}
+		})
+	}
+}
+
+func TestVideoAtoms_RewritesAppleCreationDate(t *testing.T) {
+	copied := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	ftyp := box("ftyp", []byte("qt  "), make([]byte, 4), []byte("qt  "))
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"2025-01-15T10:00:00+0100", "2024-05-01T00:00:00+0000"},
+		{"2025-01-15T10:00:00+01:00", "2024-05-01T00:00:00+00:00"},
+		{"2025-01-15T09:00:00Z", "2024-05-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		video := append(append([]byte{}, ftyp...), box("moov", box("mvhd", headerV0(100, copied)), appleKeysMeta(tt.value))...)
+		result, data := processVideoAtoms(t, video, "mvhd", "keys")
+		if !result.Success {
+			t.Fatalf("%s: ProcessFile() error = %v", tt.value, result.Error)
+		}
+		if strings.Contains(string(data), tt.value) {
+			t.Errorf("%s: creation date key not rewritten", tt.value)
+		}
+		if !strings.Contains(string(data), tt.want) {
+			t.Errorf("%s: creation date key doesn't hold %s", tt.value, tt.want)
+		}
+	}
+
+	// Without keys in the set the value stays
+	video := append(append([]byte{}, ftyp...), box("moov", box("mvhd", headerV0(100, copied)), appleKeysMeta("2025-01-15T10:00:00+0100"))...)
+	if _, data := processVideoAtoms(t, video); !strings.Contains(string(data), "2025-01-15T10:00:00+0100") {
+		t.Error("creation date key rewritten without keys in the set")
+	}
+
+	// A layout that can't be rewritten in place is an error
+	video = append(append([]byte{}, ftyp...), box("moov", box("mvhd", headerV0(100, copied)), appleKeysMeta("2025:01:15"))...)
+	if result, _ := processVideoAtoms(t, video, "keys"); result.Success {
+		t.Error("ProcessFile() succeeded with an unknown creation date layout")
+	}
+}
+
+func TestValidateVideoAtoms(t *testing.T) {
+	if err := processor.ValidateVideoAtoms([]string{"mvhd", "tkhd", "mdhd", "day", "keys"}); err != nil {
+		t.Errorf("ValidateVideoAtoms(all) error = %v", err)
+	}
+	err := processor.ValidateVideoAtoms([]string{"mvhd", "elst"})
+	if err == nil || !strings.Contains(err.Error(), `"elst"`) {
+		t.Errorf("ValidateVideoAtoms(elst) error = %v, want it named", err)
+	}
+
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("VID-20240501-WA0001.mp4", simpleMP4(), 0644)
+	p := processor.New(processor.Config{InputDir: ".", OverrideOriginal: true, VideoAtoms: []string{"udta"}}, processor.WithFS(fsys))
+	if result := p.ProcessFile("VID-20240501-WA0001.mp4"); result.Success {
+		t.Error("ProcessFile() succeeded with an unknown video atom")
+	}
+}
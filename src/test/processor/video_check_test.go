@@ -0,0 +1,138 @@
+package processor_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// headerV1 builds a version 1 mvhd/tkhd/mdhd payload of size bytes with
+// 64-bit creation and modification times of t
+func headerV1(size int, t time.Time) []byte {
+	payload := make([]byte, size)
+	payload[0] = 1
+	qt := uint64(processor.UnixToQuickTime(t.Unix()))
+	binary.BigEndian.PutUint64(payload[4:12], qt)
+	binary.BigEndian.PutUint64(payload[12:20], qt)
+	return payload
+}
+
+// headerV0 builds a version 0 header payload of size bytes with 32-bit
+// times of t
+func headerV0(size int, t time.Time) []byte {
+	payload := make([]byte, size)
+	qt := processor.UnixToQuickTime(t.Unix())
+	binary.BigEndian.PutUint32(payload[4:8], qt)
+	binary.BigEndian.PutUint32(payload[8:12], qt)
+	return payload
+}
+
+func TestCheckVideoDates(t *testing.T) {
+	dir := t.TempDir()
+	copied := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	ftyp := box("ftyp", []byte("isom"), make([]byte, 4), []byte("isom"))
+	trak := box("trak", box("tkhd", headerV0(84, copied)), box("mdia", box("mdhd", headerV0(24, copied))))
+	files := map[string][]byte{
+		"VID-20240501-WA0001.mp4": append(append([]byte{}, ftyp...), box("moov", box("mvhd", headerV0(100, copied)), trak)...),
+		// 64-bit times in an extended-size moov
+		"VID-20240502-WA0002.mp4": append(append([]byte{}, ftyp...), largeBox("moov", append(box("mvhd", headerV1(112, copied)), box("trak", box("tkhd", headerV1(96, copied)))...))...),
+		"VID-20240503-WA0003.mp4": fragmentedMP4(),
+		"IMG-20240504-WA0004.jpg": minimalJPEG(),
+	}
+	var results []processor.ProcessResult
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, data, 0644)
+		// UTC+2 in May: the headers hold 22:00 the day before
+		config := processor.Config{InputDir: dir, OverrideOriginal: true, Timezone: "Europe/Madrid", ManifestPath: "manifest.json"}
+		r := processor.New(config).ProcessFile(path)
+		if !r.Success {
+			t.Fatalf("%s: ProcessFile() error = %v", name, r.Error)
+		}
+		results = append(results, r)
+	}
+	manifest := processor.BuildManifestAt(results, time.Now())
+
+	checks := processor.CheckVideoDates(&manifest)
+	if len(checks) != 3 {
+		t.Fatalf("got %d checks, want the 3 videos", len(checks))
+	}
+	for _, c := range checks {
+		name := filepath.Base(c.Entry.OutputFile)
+		if c.Status != processor.VerifyOK {
+			t.Errorf("%s: status = %s (error %v, headers %v), want ok", name, c.Status, c.Error, c.Headers)
+		}
+		if name == "VID-20240501-WA0001.mp4" {
+			mdhd := c.Headers[len(c.Headers)-1]
+			if mdhd.Atom != "trak[1]/mdia/mdhd" || mdhd.Written || mdhd.Created != "2025-01-15T09:00:00" {
+				t.Errorf("%s: last header = %+v, want the untouched mdhd", name, mdhd)
+			}
+			if c.Expected != "2024-04-30T22:00:00" {
+				t.Errorf("%s: expected = %s, want 2024-04-30T22:00:00 (UTC)", name, c.Expected)
+			}
+		}
+	}
+}
+
+func TestCheckVideoHeaders_Mismatch(t *testing.T) {
+	written := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	moov := box("moov", box("mvhd", headerV0(100, written)), box("trak", box("tkhd", headerV0(84, written.Add(time.Hour)))))
+	entry := processor.ManifestEntry{OutputFile: "VID-20240501-WA0001.mp4", DateWritten: "2024-05-01T10:00:00", DateUTC: "2024-05-01T08:00:00Z"}
+
+	c := processor.CheckVideoHeaders(entry, moov)
+	if c.Status != processor.VerifyMismatch || len(c.Headers) != 2 || c.Headers[1].Created != "2024-05-01T09:00:00" {
+		t.Errorf("CheckVideoHeaders() = %+v, want the tkhd an hour off", c)
+	}
+
+	// Manifests from before DateUTC was recorded wrote UTC dates
+	entry.DateWritten, entry.DateUTC = "2024-05-01T08:00:00", ""
+	if c := processor.CheckVideoHeaders(entry, box("moov", box("mvhd", headerV0(100, written)))); c.Status != processor.VerifyOK {
+		t.Errorf("CheckVideoHeaders(legacy entry) = %+v, want ok", c)
+	}
+
+	if c := processor.CheckVideoHeaders(entry, box("ftyp", []byte("isom"))); c.Status != processor.VerifyMissing || c.Error == nil {
+		t.Errorf("CheckVideoHeaders(no moov) = %+v, want an error", c)
+	}
+}
+
+func TestCheckVideoDates_VideoAtoms(t *testing.T) {
+	dir := t.TempDir()
+	copied := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	ftyp := box("ftyp", []byte("isom"), make([]byte, 4), []byte("isom"))
+	trak := box("trak", box("tkhd", headerV0(84, copied)), box("mdia", box("mdhd", headerV0(24, copied))))
+	path := filepath.Join(dir, "VID-20240501-WA0001.mp4")
+	os.WriteFile(path, append(ftyp, box("moov", box("mvhd", headerV0(100, copied)), trak)...), 0644)
+
+	config := processor.Config{InputDir: dir, OverrideOriginal: true, Timezone: "UTC", ManifestPath: "manifest.json", VideoAtoms: []string{"mvhd", "mdhd"}}
+	r := processor.New(config).ProcessFile(path)
+	if !r.Success {
+		t.Fatalf("ProcessFile() error = %v", r.Error)
+	}
+	manifest := processor.BuildManifestAt([]processor.ProcessResult{r}, time.Now())
+	if got := manifest.Entries[0].VideoAtoms; len(got) != 2 || got[0] != "mvhd" || got[1] != "mdhd" {
+		t.Fatalf("manifest entry atoms = %v, want mvhd and mdhd", got)
+	}
+
+	// The untouched tkhd is shown, the dated mdhd compared
+	checks := processor.CheckVideoDates(&manifest)
+	if len(checks) != 1 || checks[0].Status != processor.VerifyOK {
+		t.Fatalf("CheckVideoDates() = %+v, want ok", checks)
+	}
+	written := map[string]bool{}
+	for _, h := range checks[0].Headers {
+		written[h.Atom] = h.Written
+	}
+	if !written["mvhd"] || written["trak[1]/tkhd"] || !written["trak[1]/mdia/mdhd"] {
+		t.Errorf("headers compared = %v, want mvhd and mdhd", written)
+	}
+
+	// Checked against the default atoms, the tkhd left alone disagrees
+	manifest.Entries[0].VideoAtoms = nil
+	if checks := processor.CheckVideoDates(&manifest); checks[0].Status != processor.VerifyMismatch {
+		t.Errorf("CheckVideoDates(default atoms) = %s, want mismatch", checks[0].Status)
+	}
+}
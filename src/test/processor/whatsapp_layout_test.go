@@ -0,0 +1,120 @@
+package processor_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// makeWhatsAppTree creates the given folders under root/WhatsApp/Media
+func makeWhatsAppTree(t *testing.T, folders ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, folder := range folders {
+		if err := os.MkdirAll(filepath.Join(root, "WhatsApp", "Media", folder), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestWhatsAppTargets(t *testing.T) {
+	root := makeWhatsAppTree(t, "WhatsApp Images", "WhatsApp Voice Notes", "WhatsApp Documents", ".Statuses")
+	media := filepath.Join(root, "WhatsApp", "Media")
+
+	// The same folders are found from every accepted root
+	for _, dir := range []string{root, filepath.Join(root, "WhatsApp"), media} {
+		targets, err := processor.WhatsAppTargets(dir)
+		if err != nil {
+			t.Fatalf("WhatsAppTargets(%s) error = %v", dir, err)
+		}
+		if len(targets) != 3 {
+			t.Fatalf("WhatsAppTargets(%s) = %d targets, want 3", dir, len(targets))
+		}
+
+		want := []struct{ dir, media string }{
+			{"WhatsApp Images", processor.MediaImages},
+			{"WhatsApp Voice Notes", processor.MediaAudio},
+			{"WhatsApp Documents", processor.MediaDocuments},
+		}
+		for i, w := range want {
+			if targets[i].Dir != filepath.Join(media, w.dir) || targets[i].Media != w.media {
+				t.Errorf("target %d = %s (%s), want %s (%s)", i, targets[i].Dir, targets[i].Media, w.dir, w.media)
+			}
+		}
+		if docs := targets[2].IncludeDocuments; docs == nil || !*docs {
+			t.Error("documents target should enable includeDocuments")
+		}
+		if targets[0].IncludeDocuments != nil {
+			t.Error("images target should not set includeDocuments")
+		}
+	}
+}
+
+func TestWhatsAppTargets_Business(t *testing.T) {
+	root := t.TempDir()
+	media := filepath.Join(root, "WhatsApp Business", "Media")
+	for _, folder := range []string{"WhatsApp Business Images", "WhatsApp Business Voice Notes"} {
+		os.MkdirAll(filepath.Join(media, folder), 0755)
+	}
+
+	targets, err := processor.WhatsAppTargets(root)
+	if err != nil {
+		t.Fatalf("WhatsAppTargets() error = %v", err)
+	}
+	if len(targets) != 2 ||
+		targets[0].Dir != filepath.Join(media, "WhatsApp Business Images") || targets[0].Media != processor.MediaImages ||
+		targets[1].Dir != filepath.Join(media, "WhatsApp Business Voice Notes") || targets[1].Media != processor.MediaAudio {
+		t.Errorf("WhatsAppTargets() = %+v, want the Business images and voice notes", targets)
+	}
+}
+
+func TestWhatsAppTargets_BothApps(t *testing.T) {
+	root := t.TempDir()
+	personal := filepath.Join(root, "WhatsApp", "Media", "WhatsApp Images")
+	business := filepath.Join(root, "WhatsApp Business", "Media", "WhatsApp Business Video")
+	os.MkdirAll(personal, 0755)
+	os.MkdirAll(business, 0755)
+
+	targets, err := processor.WhatsAppTargets(root)
+	if err != nil {
+		t.Fatalf("WhatsAppTargets() error = %v", err)
+	}
+	if len(targets) != 2 || targets[0].Dir != personal || targets[1].Dir != business || targets[1].Media != processor.MediaVideos {
+		t.Errorf("WhatsAppTargets() = %+v, want the folders of both apps", targets)
+	}
+}
+
+func TestWhatsAppTargets_NoLayout(t *testing.T) {
+	root := makeWhatsAppTree(t, "Holidays")
+	if _, err := processor.WhatsAppTargets(root); err == nil {
+		t.Error("WhatsAppTargets() should fail without WhatsApp media folders")
+	}
+}
+
+func TestFilterMediaKind(t *testing.T) {
+	paths := []string{"IMG-1.jpg", "VID-1.mp4", "PTT-1.opus", "DOC-1.pdf", "IMG-2.WEBP"}
+
+	tests := []struct {
+		kind string
+		want []string
+	}{
+		{"", paths},
+		{processor.MediaImages, []string{"IMG-1.jpg", "IMG-2.WEBP"}},
+		{processor.MediaVideos, []string{"VID-1.mp4"}},
+		{processor.MediaAudio, []string{"PTT-1.opus"}},
+		{processor.MediaDocuments, []string{"DOC-1.pdf"}},
+	}
+	for _, tt := range tests {
+		if got := processor.FilterMediaKind(paths, tt.kind); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FilterMediaKind(%q) = %v, want %v", tt.kind, got, tt.want)
+		}
+	}
+
+	if err := processor.ValidateMediaKind("voice"); err == nil {
+		t.Error("ValidateMediaKind(\"voice\") should fail")
+	}
+}
@@ -0,0 +1,93 @@
+package processor_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+func TestValidateWriters(t *testing.T) {
+	if err := processor.ValidateWriters([]string{"jpegExif", "mp4Mvhd", "m4aDate", "opusDate", "pdfDate", "pngChunks", "webpExif", "heicExif", "gifXmp", "mtime"}); err != nil {
+		t.Errorf("ValidateWriters() of every writer error = %v", err)
+	}
+	if err := processor.ValidateWriters(nil); err != nil {
+		t.Errorf("ValidateWriters(nil) error = %v", err)
+	}
+	for _, name := range []string{"pngChunk", "JPEGExif", ""} {
+		if err := processor.ValidateWriters([]string{name}); err == nil {
+			t.Errorf("ValidateWriters(%q) accepted", name)
+		}
+	}
+}
+
+func TestProcessFile_Writers(t *testing.T) {
+	named := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	files := map[string][]byte{
+		"IMG-20240501-WA0001.jpg": minimalJPEG(),
+		"VID-20240501-WA0001.mp4": simpleMP4(),
+	}
+	tests := []struct {
+		writers []string
+		changed map[string]bool // Content rewritten, by file
+		mtime   bool
+	}{
+		{nil, map[string]bool{"IMG-20240501-WA0001.jpg": true, "VID-20240501-WA0001.mp4": true}, true},
+		{[]string{"mp4Mvhd"}, map[string]bool{"VID-20240501-WA0001.mp4": true}, false},
+		{[]string{"jpegExif", "mtime"}, map[string]bool{"IMG-20240501-WA0001.jpg": true}, true},
+		{[]string{"mtime"}, map[string]bool{}, true},
+	}
+	for _, tt := range tests {
+		fsys := processor.NewMemFS()
+		config := processor.Config{InputDir: "media", OverrideOriginal: true, UpdateModified: true, Writers: tt.writers}
+		p := processor.New(config, processor.WithFS(fsys))
+		for name, data := range files {
+			path := "media/" + name
+			fsys.WriteFile(path, data, 0644)
+			fsys.Chtimes(path, before, before)
+			if r := p.ProcessFile(path); !r.Success {
+				t.Fatalf("writers %v, %s: ProcessFile() error = %v", tt.writers, name, r.Error)
+			}
+			got, _ := fsys.ReadFile(path)
+			if changed := !bytes.Equal(got, data); changed != tt.changed[name] {
+				t.Errorf("writers %v, %s: content changed = %v, want %v", tt.writers, name, changed, tt.changed[name])
+			}
+			info, _ := fsys.Stat(path)
+			if timed := info.ModTime().Equal(named); timed != tt.mtime {
+				t.Errorf("writers %v, %s: modification time = %v, want set = %v", tt.writers, name, info.ModTime(), tt.mtime)
+			}
+		}
+	}
+
+	if r := processor.New(processor.Config{Writers: []string{"tiffExif"}}).ProcessFile("IMG-20240501-WA0001.jpg"); r.Success {
+		t.Error("unknown writer accepted")
+	}
+}
+
+func TestDiagnose_WritersCoverEveryFormat(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("media/IMG-20240501-WA0001.png", []byte("\x89PNG\r\n\x1a\n"), 0644)
+	fsys.WriteFile("media/IMG-20240501-WA0002.bmp", []byte("BM"), 0644)
+	tests := []struct {
+		file    string
+		writers []string
+		want    string
+	}{
+		{"media/IMG-20240501-WA0001.png", []string{"jpegExif"}, "the pngChunks writer isn't enabled"},
+		{"media/IMG-20240501-WA0002.bmp", []string{"jpegExif"}, "no writer for .bmp can be enabled"},
+		{"media/IMG-20240501-WA0002.bmp", nil, "no metadata writer for .bmp"},
+	}
+	for _, tt := range tests {
+		p := processor.New(processor.Config{InputDir: "media", OverrideOriginal: true, Writers: tt.writers}, processor.WithFS(fsys))
+		d, err := p.Diagnose(tt.file)
+		if err != nil {
+			t.Fatalf("%s: Diagnose() error = %v", tt.file, err)
+		}
+		if !strings.Contains(strings.Join(d.Actions, "\n"), tt.want) {
+			t.Errorf("%s with writers %v: actions = %q, want %q", tt.file, tt.writers, d.Actions, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package processor_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apercova/wappd/internal/processor"
+	"github.com/apercova/wappd/version"
+)
+
+func TestBuildXMPPacket(t *testing.T) {
+	packet := string(processor.BuildXMPPacket([]string{"family-archive", "Tom & Jerry"}))
+
+	for _, want := range []string{
+		"<dc:subject>",
+		"<rdf:li>family-archive</rdf:li>",
+		"<rdf:li>Tom &amp; Jerry</rdf:li>",
+		`<?xpacket end="w"?>`,
+	} {
+		if !strings.Contains(packet, want) {
+			t.Errorf("packet is missing %q:\n%s", want, packet)
+		}
+	}
+}
+
+func TestInsertXMPSegment(t *testing.T) {
+	exif, err := processor.CreateEXIFSegment(time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := processor.InsertEXIFSegment(minimalJPEG(), exif)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new segment goes right after EXIF
+	tagged, err := processor.InsertXMPSegment(data, processor.BuildXMPPacket([]string{"one"}))
+	if err != nil {
+		t.Fatalf("InsertXMPSegment() error = %v", err)
+	}
+	segments, err := processor.ParseJPEGSegments(tagged)
+	if err != nil {
+		t.Fatalf("ParseJPEGSegments() error = %v", err)
+	}
+	exifIndex, _ := processor.FindAPP1Segment(segments)
+	xmpIndex, xmp := processor.FindXMPSegment(segments)
+	if xmp == nil || xmpIndex != exifIndex+1 {
+		t.Fatalf("XMP segment at %d, EXIF at %d", xmpIndex, exifIndex)
+	}
+	if !bytes.HasSuffix(tagged, []byte{0xFF, 0xD9}) {
+		t.Error("image data was not preserved")
+	}
+
+	// An existing segment is replaced, not duplicated
+	retagged, err := processor.InsertXMPSegment(tagged, processor.BuildXMPPacket([]string{"two"}))
+	if err != nil {
+		t.Fatalf("InsertXMPSegment() error = %v", err)
+	}
+	if bytes.Contains(retagged, []byte(">one<")) || !bytes.Contains(retagged, []byte(">two<")) {
+		t.Error("existing XMP segment was not replaced")
+	}
+	if len(retagged) != len(tagged) {
+		t.Errorf("len = %d after replacing, want %d", len(retagged), len(tagged))
+	}
+}
+
+func TestProcessFile_Tags(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+
+	proc := processor.New(processor.Config{Tags: []string{"family-archive"}}, processor.WithFS(fsys))
+	result := proc.ProcessFile("IMG-20240501-WA0001.jpg")
+	if !result.Success {
+		t.Fatalf("ProcessFile() error = %v", result.Error)
+	}
+	data, _ := fsys.ReadFile(result.OutputFile)
+	if !bytes.Contains(data, []byte("<rdf:li>family-archive</rdf:li>")) {
+		t.Error("output has no dc:subject keyword")
+	}
+}
+
+func TestExiftoolKeywordArgs(t *testing.T) {
+	got := processor.ExiftoolKeywordArgs([]string{"a", "b c"})
+	want := []string{"-XMP-dc:Subject+=a", "-XMP-dc:Subject+=b c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExiftoolKeywordArgs() = %q, want %q", got, want)
+	}
+	if err := processor.ValidateTags([]string{"ok", " "}); err == nil {
+		t.Error("ValidateTags() should reject a blank tag")
+	}
+}
+
+func TestProcessFile_SoftwareTag(t *testing.T) {
+	fsys := processor.NewMemFS()
+	fsys.WriteFile("IMG-20240501-WA0001.jpg", minimalJPEG(), 0644)
+	fsys.WriteFile("IMG-20240501-WA0002.jpg", minimalJPEG(), 0644)
+
+	software := []byte(version.Get().Software() + "\x00")
+	tagged := processor.New(processor.Config{SoftwareTag: true}, processor.WithFS(fsys)).ProcessFile("IMG-20240501-WA0001.jpg")
+	plain := processor.New(processor.Config{}, processor.WithFS(fsys)).ProcessFile("IMG-20240501-WA0002.jpg")
+	if !tagged.Success || !plain.Success {
+		t.Fatalf("ProcessFile() errors = %v, %v", tagged.Error, plain.Error)
+	}
+	if data, _ := fsys.ReadFile(tagged.OutputFile); !bytes.Contains(data, software) {
+		t.Errorf("output has no %q Software tag", software)
+	}
+	if data, _ := fsys.ReadFile(plain.OutputFile); bytes.Contains(data, software) {
+		t.Error("Software tag written without SoftwareTag")
+	}
+}
+
+func TestVersionSoftware(t *testing.T) {
+	for v, want := range map[string]string{"1.2.0": "wappd v1.2.0", "v1.2.0": "wappd v1.2.0", "dev": "wappd dev"} {
+		if got := (version.Info{Version: v}).Software(); got != want {
+			t.Errorf("Software() for %q = %q, want %q", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package scan_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apercova/wappd/scan"
+)
+
+// scanTree creates files (paths relative to the returned directory)
+func scanTree(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// scanRel returns what scanner finds under dir, relative to dir
+func scanRel(t *testing.T, scanner scan.Scanner, dir string) []string {
+	t.Helper()
+	files, err := scanner.Files(dir)
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	var rel []string
+	for _, file := range files {
+		r, _ := filepath.Rel(dir, file)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+func TestScanner_MaxDepth(t *testing.T) {
+	dir := scanTree(t, "top.jpg", "a/one.jpg", "a/b/two.jpg", "a/b/c/three.jpg")
+
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{0, []string{"a/b/c/three.jpg", "a/b/two.jpg", "a/one.jpg", "top.jpg"}},
+		{1, []string{"top.jpg"}},
+		{2, []string{"a/one.jpg", "top.jpg"}},
+		{3, []string{"a/b/two.jpg", "a/one.jpg", "top.jpg"}},
+	}
+	for _, tt := range tests {
+		if got := scanRel(t, scan.Scanner{MaxDepth: tt.depth}, dir); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MaxDepth %d: got %v, want %v", tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestScanner_SkipDirs(t *testing.T) {
+	dir := scanTree(t, "IMG-1.jpg", ".Statuses/IMG-2.jpg", "Sent/IMG-3.jpg", "Sent/.Statuses/IMG-4.jpg")
+
+	var asked []string
+	scanner := scan.Scanner{SkipDirs: func(path, name string) bool {
+		rel, _ := filepath.Rel(dir, path)
+		asked = append(asked, filepath.ToSlash(rel))
+		return name == ".Statuses"
+	}}
+	want := []string{"IMG-1.jpg", "Sent/IMG-3.jpg"}
+	if got := scanRel(t, scanner, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if wantAsked := []string{".Statuses", "Sent", "Sent/.Statuses"}; !reflect.DeepEqual(asked, wantAsked) {
+		t.Errorf("SkipDirs asked about %v, want %v", asked, wantAsked)
+	}
+}
+
+func TestScanner_FollowSymlinks(t *testing.T) {
+	dir := scanTree(t, "media/IMG-1.jpg")
+	other := scanTree(t, "IMG-2.jpg")
+	if err := os.Symlink(other, filepath.Join(dir, "media", "linked")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	// A link back up would loop forever if followed blindly
+	os.Symlink(dir, filepath.Join(dir, "media", "loop"))
+
+	if got, want := scanRel(t, scan.Scanner{}, dir), []string{"media/IMG-1.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without following: got %v, want %v", got, want)
+	}
+	want := []string{"media/IMG-1.jpg", "media/linked/IMG-2.jpg"}
+	if got := scanRel(t, scan.Scanner{FollowSymlinks: true}, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("following: got %v, want %v", got, want)
+	}
+}
+
+func TestScanner_Documents(t *testing.T) {
+	dir := scanTree(t, "IMG-1.jpg", "notes.txt", "report.pdf")
+
+	if got, want := scanRel(t, scan.Scanner{}, dir), []string{"IMG-1.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := scanRel(t, scan.Scanner{IncludeDocuments: true}, dir), []string{"IMG-1.jpg", "report.pdf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with documents: got %v, want %v", got, want)
+	}
+}
+
+func TestScanner_Extensions(t *testing.T) {
+	dir := scanTree(t, "IMG-1.jpg", "IMG-2.HEIC", "STK-3.gif", "notes.txt")
+
+	tests := []struct {
+		exts []string
+		want []string
+	}{
+		{nil, []string{"IMG-1.jpg", "STK-3.gif"}},
+		{[]string{"heic", "-gif"}, []string{"IMG-1.jpg", "IMG-2.HEIC"}},
+		{[]string{".HEIC", "-.jpg"}, []string{"IMG-2.HEIC", "STK-3.gif"}},
+		{[]string{"-gif", "gif"}, []string{"IMG-1.jpg", "STK-3.gif"}}, // The last mention wins
+	}
+	for _, tt := range tests {
+		if got := scanRel(t, scan.Scanner{Extensions: tt.exts}, dir); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Extensions %v: got %v, want %v", tt.exts, got, tt.want)
+		}
+	}
+}
+
+func TestValidateExtensions(t *testing.T) {
+	for _, exts := range [][]string{nil, {"heic"}, {".heic", "-gif", "-.bmp"}} {
+		if err := scan.ValidateExtensions(exts); err != nil {
+			t.Errorf("ValidateExtensions(%q) error = %v", exts, err)
+		}
+	}
+	for _, exts := range [][]string{{""}, {"-"}, {"tar.gz"}, {"a/b"}, {"he ic"}} {
+		if err := scan.ValidateExtensions(exts); err == nil {
+			t.Errorf("ValidateExtensions(%q) = nil, want an error", exts)
+		}
+	}
+}
+
+func TestScanner_Detect(t *testing.T) {
+	dir := scanTree(t, "IMG-1.jpg", "f0001234.dat", "STK-2.gif", "notes.txt")
+
+	var asked []string
+	detect := func(path string) bool {
+		asked = append(asked, filepath.Base(path))
+		return filepath.Ext(path) == ".dat"
+	}
+	want := []string{"IMG-1.jpg", "f0001234.dat"}
+	if got := scanRel(t, scan.Scanner{Detect: detect, Extensions: []string{"-gif"}}, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Extensions found or left out on purpose are never asked about
+	if wantAsked := []string{"f0001234.dat", "notes.txt"}; !reflect.DeepEqual(asked, wantAsked) {
+		t.Errorf("Detect asked about %v, want %v", asked, wantAsked)
+	}
+}
+
+func TestSkipPaths(t *testing.T) {
+	dir := scanTree(t, "IMG-1.jpg", "out/IMG-1.jpg", "Sent/out/IMG-2.jpg", ".wappd-transaction-1/IMG-1.jpg")
+
+	scanner := scan.Scanner{SkipDirs: scan.SkipPaths(filepath.Join(dir, "out"), "", filepath.Join(dir, ".wappd-transaction-1"))}
+	want := []string{"IMG-1.jpg", "Sent/out/IMG-2.jpg"}
+	if got := scanRel(t, scanner, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSkipNames(t *testing.T) {
+	dir := scanTree(t, "IMG-1.jpg", ".Statuses/IMG-2.jpg", "Backup 2024/IMG-3.jpg", "Sent/IMG-4.jpg")
+
+	want := []string{"IMG-1.jpg", "Sent/IMG-4.jpg"}
+	if got := scanRel(t, scan.Scanner{SkipDirs: scan.SkipNames(".Statuses", "Backup*")}, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestValidateSkipNames(t *testing.T) {
+	if err := scan.ValidateSkipNames([]string{".Statuses", "Backup*", "IMG-?"}); err != nil {
+		t.Errorf("ValidateSkipNames() error = %v", err)
+	}
+	for _, patterns := range [][]string{{""}, {"Sent/.Statuses"}, {"[a-"}} {
+		if err := scan.ValidateSkipNames(patterns); err == nil {
+			t.Errorf("ValidateSkipNames(%q) = nil, want an error", patterns)
+		}
+	}
+}
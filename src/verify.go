@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/apercova/wappd/internal/processor"
+)
+
+// runVerify implements the "verify" subcommand, which re-hashes the files
+// listed in a manifest and reports any that changed or went missing, or
+// checks that an audit log wasn't tampered with
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	manifestPath := fs.String("manifest", "", "Path to a manifest written with -manifest")
+	auditLog := fs.String("audit-log", "", "Path to an audit log written with -audit-log")
+	against := fs.String("against", "", "Also check that this tool reads the dates written to the manifest's files (exiftool)")
+	videoDates := fs.Bool("video-dates", false, "Also decode the mvhd/tkhd/mdhd creation times of the manifest's videos and compare them with the dates written")
+	verbose := fs.Bool("v", false, "Verbose output (list files that verified OK)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  wappd verify --manifest <path> [--against exiftool] [--video-dates]\n")
+		fmt.Fprintf(os.Stderr, "  wappd verify --audit-log <path>\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *auditLog != "" && *manifestPath == "" {
+		return verifyAuditLog(*auditLog)
+	}
+	if *manifestPath == "" || *auditLog != "" {
+		fs.Usage()
+		return 2
+	}
+
+	if *against != "" {
+		if err := processor.ValidateReference(*against); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	manifest, err := processor.LoadManifest(*manifestPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results := processor.VerifyManifest(manifest)
+
+	okCount := 0
+	badCount := 0
+	for _, r := range results {
+		switch r.Status {
+		case processor.VerifyOK:
+			okCount++
+			if *verbose {
+				fmt.Printf("  ✓ %s\n", r.Entry.OutputFile)
+			}
+		case processor.VerifyMismatch:
+			badCount++
+			fmt.Printf("  ✗ %s: hash mismatch (expected %s, got %s)\n", r.Entry.OutputFile, r.Entry.PostHash, r.ActualHash)
+		case processor.VerifyMissing:
+			badCount++
+			fmt.Printf("  ✗ %s: %v\n", r.Entry.OutputFile, r.Error)
+		}
+	}
+
+	fmt.Printf("\nVerification complete: %d ok", okCount)
+	if badCount > 0 {
+		fmt.Printf(", %d failed", badCount)
+	}
+	fmt.Printf(" (out of %d total)\n", len(results))
+
+	if *against != "" && crossCheck(manifest, *verbose) > 0 {
+		badCount++
+	}
+	if *videoDates && checkVideoDates(manifest, *verbose) > 0 {
+		badCount++
+	}
+	if badCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// crossCheck compares the dates in a manifest with the ones exiftool reads
+// from its files and returns how many disagree
+func crossCheck(manifest *processor.Manifest, verbose bool) int {
+	results, err := processor.CrossCheckManifest(manifest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --against exiftool: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\nCross-checking dates with exiftool...\n")
+	okCount, badCount, uncheckedCount := 0, 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case processor.VerifyOK:
+			okCount++
+			if verbose {
+				fmt.Printf("  ✓ %s: %s %s\n", r.Entry.OutputFile, r.Tag, r.Read)
+			}
+		case processor.VerifyMismatch:
+			badCount++
+			read := r.Read
+			if read == "" {
+				read = "nothing"
+			}
+			fmt.Printf("  ✗ %s: %s is %s, wappd wrote %s\n", r.Entry.OutputFile, r.Tag, read, r.Expected)
+		case processor.VerifyMissing:
+			badCount++
+			fmt.Printf("  ✗ %s: exiftool could not read the file\n", r.Entry.OutputFile)
+		case processor.VerifyUnchecked:
+			uncheckedCount++
+			if verbose {
+				fmt.Printf("  - %s: no embedded date to compare\n", r.Entry.OutputFile)
+			}
+		}
+	}
+
+	fmt.Printf("\nCross-check complete: %d agree", okCount)
+	if badCount > 0 {
+		fmt.Printf(", %d disagree", badCount)
+	}
+	if uncheckedCount > 0 {
+		fmt.Printf(", %d without an embedded date", uncheckedCount)
+	}
+	fmt.Printf(" (out of %d total)\n", len(results))
+	return badCount
+}
+
+// checkVideoDates compares the header times of the videos in a manifest
+// with the dates written and returns how many disagree or can't be read
+func checkVideoDates(manifest *processor.Manifest, verbose bool) int {
+	results := processor.CheckVideoDates(manifest)
+
+	fmt.Printf("\nChecking video header dates...\n")
+	okCount, badCount := 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case processor.VerifyOK:
+			okCount++
+			if verbose {
+				fmt.Printf("  ✓ %s: %sZ\n", r.Entry.OutputFile, r.Expected)
+			}
+		case processor.VerifyMismatch:
+			badCount++
+			for _, h := range r.Headers {
+				if h.Written && h.Created != r.Expected {
+					fmt.Printf("  ✗ %s: %s is %sZ, wappd wrote %sZ\n", r.Entry.OutputFile, h.Atom, h.Created, r.Expected)
+				}
+			}
+		case processor.VerifyMissing:
+			badCount++
+			fmt.Printf("  ✗ %s: %v\n", r.Entry.OutputFile, r.Error)
+		}
+		// Headers outside the atoms the file was dated in: shown, never compared
+		if verbose {
+			for _, h := range r.Headers {
+				if !h.Written {
+					fmt.Printf("      %s (not dated by wappd)\n", h)
+				}
+			}
+		}
+	}
+
+	fmt.Printf("\nVideo date check complete: %d agree", okCount)
+	if badCount > 0 {
+		fmt.Printf(", %d disagree or couldn't be read", badCount)
+	}
+	fmt.Printf(" (out of %d videos)\n", len(results))
+	return badCount
+}
+
+// verifyAuditLog checks the hash chain of an audit log and prints the hash
+// of its last entry, to be noted somewhere safe and compared next time
+func verifyAuditLog(path string) int {
+	n, last, err := processor.VerifyAuditLog(path)
+	if err != nil {
+		fmt.Printf("  ✗ %s: %v\n", path, err)
+		fmt.Printf("\nVerification failed after %d intact entries\n", n)
+		return 1
+	}
+	fmt.Printf("Audit log intact: %d entries\n", n)
+	if last != "" {
+		fmt.Printf("Last entry hash: %s\n", last)
+	}
+	return 0
+}
@@ -37,6 +37,15 @@ func (i Info) String() string {
 		i.Version, i.GitCommit, i.BuildDate, i.GoVersion)
 }
 
+// Software returns the value wappd records in the EXIF Software tag, e.g.
+// "wappd v1.2.0"
+func (i Info) Software() string {
+	if i.Version != "" && i.Version[0] >= '0' && i.Version[0] <= '9' {
+		return "wappd v" + i.Version
+	}
+	return "wappd " + i.Version
+}
+
 // Short returns a short version string
 func (i Info) Short() string {
 	return fmt.Sprintf("wappd version %s", i.Version)